@@ -0,0 +1,49 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+)
+
+// Action is a side effect attached to a transition, such as persisting the
+// new state or publishing a notification. It runs when the transition fires;
+// returning an error aborts the transition.
+type Action[S State, E Event] func(ctx context.Context, from, to S, event E) error
+
+// TransitionOption configures a transition added with AddTransition
+type TransitionOption[S State, E Event] func(*edge[S, E])
+
+// WithAction attaches an action to a transition. A transition may have
+// several actions; they run in the order they were attached and stop at the
+// first error.
+func WithAction[S State, E Event](fn Action[S, E]) TransitionOption[S, E] {
+	return func(e *edge[S, E]) {
+		e.actions = append(e.actions, fn)
+	}
+}
+
+// runActions runs the edge's actions for a transition between the given
+// states, each wrapped in mw
+func (e *edge[S, E]) runActions(ctx context.Context, from, to S, event E, mw []ActionMiddleware[S, E]) error {
+	for _, fn := range e.actions {
+		for i := len(mw) - 1; i >= 0; i-- {
+			fn = mw[i](fn)
+		}
+		if err := checkContext(ctx, from, event); err != nil {
+			return err
+		}
+		if err := fn(ctx, from, to, event); err != nil {
+			return fmt.Errorf("action for event '%s' from state '%s' failed: %w", Name(event), Name(from), err)
+		}
+	}
+	return nil
+}
+
+// checkContext returns an error wrapping the context's if ctx is done, to
+// abandon a transition
+func checkContext[S State, E Event](ctx context.Context, from S, event E) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("transition for event '%s' from state '%s' abandoned: %w", Name(event), Name(from), err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStateMachine_TransitionActions(t *testing.T) {
+	sm := NewStateMachine[UserState, UserEvent]()
+
+	var calls []string
+	sm.AddTransition(UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification,
+		WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+			calls = append(calls, "persist "+string(from)+"->"+string(to))
+			return nil
+		}),
+		WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+			calls = append(calls, "notify "+string(event))
+			return nil
+		}),
+	)
+	sm.OnEnter(UserStateEmailPendingVerification, func(ctx context.Context, from, to UserState, event UserEvent) {
+		calls = append(calls, "enter")
+	})
+
+	got, err := sm.Transition(UserStateInitial, UserEventSubmitSignUp)
+	if err != nil {
+		t.Fatalf("Transition() error = %v", err)
+	}
+	if got != UserStateEmailPendingVerification {
+		t.Errorf("Transition() = %v, want %v", got, UserStateEmailPendingVerification)
+	}
+
+	want := []string{"persist Initial->EmailPendingVerification", "notify SubmitSignup", "enter"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestStateMachine_FailingActionAbortsTransition(t *testing.T) {
+	sm := NewStateMachine[UserState, UserEvent]()
+	errMailDown := errors.New("mail server down")
+
+	secondRan, entered := false, false
+	sm.AddTransition(UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification,
+		WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+			return errMailDown
+		}),
+		WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+			secondRan = true
+			return nil
+		}),
+	)
+	sm.OnEnter(UserStateEmailPendingVerification, func(ctx context.Context, from, to UserState, event UserEvent) {
+		entered = true
+	})
+
+	got, err := sm.Transition(UserStateInitial, UserEventSubmitSignUp)
+	if !errors.Is(err, errMailDown) {
+		t.Fatalf("Transition() error = %v, want it to wrap %v", err, errMailDown)
+	}
+	if got != "" {
+		t.Errorf("Transition() returned state %q on failure, want zero value", got)
+	}
+	if secondRan || entered {
+		t.Errorf("later action ran = %v, entry callback ran = %v, want neither", secondRan, entered)
+	}
+
+	inst := sm.NewInstanceAt(UserStateInitial)
+	if err := inst.Fire(context.Background(), UserEventSubmitSignUp); err == nil {
+		t.Fatalf("Fire() expected error from failing action")
+	}
+	if !inst.Is(UserStateInitial) {
+		t.Errorf("instance moved to %v despite failing action", inst.Current())
+	}
+}
+
+func TestStateMachine_ActionsNotRunByValidation(t *testing.T) {
+	sm := NewStateMachine[UserState, UserEvent]()
+	ran := false
+	sm.AddTransition(UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification,
+		WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+			ran = true
+			return nil
+		}),
+	)
+
+	if _, err := sm.ValidateTransitionPath(UserStateInitial, []UserEvent{UserEventSubmitSignUp}); err != nil {
+		t.Fatalf("ValidateTransitionPath() error = %v", err)
+	}
+	if ran {
+		t.Errorf("ValidateTransitionPath() ran a transition action")
+	}
+}
+
+func TestStateMachine_ContextCancellation(t *testing.T) {
+	tests := []struct {
+		name      string
+		cancelIn  string // "before", "guard", "first action" or "last action"
+		deadline  bool
+		wantCalls []string
+		wantErr   error
+	}{
+		{
+			name:     "done before the transition",
+			cancelIn: "before",
+			wantErr:  context.Canceled,
+		},
+		{
+			name:      "deadline passes while checking guards",
+			cancelIn:  "guard",
+			deadline:  true,
+			wantCalls: []string{"guard"},
+			wantErr:   context.DeadlineExceeded,
+		},
+		{
+			name:      "cancelled between actions",
+			cancelIn:  "first action",
+			wantCalls: []string{"guard", "first action"},
+			wantErr:   context.Canceled,
+		},
+		{
+			name:      "cancelled after the last action",
+			cancelIn:  "last action",
+			wantCalls: []string{"guard", "first action", "last action", "enter"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.deadline {
+				ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+			}
+			defer cancel()
+			var calls []string
+			step := func(name string) {
+				calls = append(calls, name)
+				if name == tt.cancelIn && !tt.deadline {
+					cancel()
+				}
+			}
+
+			sm := NewStateMachine[UserState, UserEvent]()
+			sm.AddTransition(UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification,
+				WithGuard(func(ctx context.Context, from UserState, event UserEvent) bool {
+					step("guard")
+					if tt.deadline {
+						<-ctx.Done()
+					}
+					return ctx.Err() == nil
+				}),
+				WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+					step("first action")
+					return nil
+				}),
+				WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+					step("last action")
+					return nil
+				}))
+			sm.OnEnter(UserStateEmailPendingVerification, func(ctx context.Context, from, to UserState, event UserEvent) {
+				calls = append(calls, "enter")
+			})
+			if tt.cancelIn == "before" {
+				cancel()
+			}
+
+			_, err := sm.TransitionContext(ctx, UserStateInitial, UserEventSubmitSignUp)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("TransitionContext() error = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrGuardRejected) {
+				t.Errorf("TransitionContext() error = %v, reported as a guard rejection", err)
+			}
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("ran %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
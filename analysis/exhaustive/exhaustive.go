@@ -0,0 +1,286 @@
+// Package exhaustive defines an analyzer that checks state and event enums
+// used with statemachine.StateMachine are fully wired.
+//
+// For every StateMachine[S, E] instantiated in a package, where S and E are
+// named types declared in that package, the analyzer reports:
+//
+//   - constants of type S or E that never appear in a transition registered
+//     with the machine, and
+//   - switch statements over S that do not have a case for every constant.
+//
+// Machines built from definitions (FromDefinition, FromDefinitionWithGuards,
+// LoadFS, MustLoadFS, LoadYAML, LoadJSON, LoadSCXML and smcel.LoadYAML) are
+// not checked for unused constants, as their transitions are not visible in
+// the source.
+package exhaustive
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// statemachinePath is the import path of the statemachine package, and
+// smcelPath that of its CEL guards package
+const (
+	statemachinePath = "github.com/richardbowden/statemachine"
+	smcelPath        = statemachinePath + "/smcel"
+)
+
+const doc = `check state machine state and event enums are exhaustively wired
+
+Reports constants of a machine's state or event type that are never used in
+a transition, and switch statements over a state type missing cases.`
+
+// Analyzer reports unwired enum values and non-exhaustive state switches
+var Analyzer = &analysis.Analyzer{
+	Name:     "smexhaustive",
+	Doc:      doc,
+	Flags:    flags(),
+	Run:      run,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+}
+
+var defaultSignifiesExhaustive bool
+
+func flags() flag.FlagSet {
+	fs := flag.NewFlagSet("smexhaustive", flag.ExitOnError)
+	fs.BoolVar(&defaultSignifiesExhaustive, "default-signifies-exhaustive", false,
+		"treat a switch with a default case as exhaustive")
+	return *fs
+}
+
+// enum is a named type declared in the package being analyzed and its constants
+type enum struct {
+	typ     *types.Named
+	members []*types.Const
+}
+
+// machine is one state/event type pair a StateMachine was instantiated with
+type machine struct {
+	state, event *types.Named
+	fromFiles    bool
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	machines := findMachines(pass)
+	if len(machines) == 0 {
+		return nil, nil
+	}
+
+	states := make(map[*types.Named]*enum)
+	wired := make(map[*types.Named]*enum)
+	skipUnused := make(map[*types.Named]bool)
+	for _, m := range machines {
+		for _, typ := range []*types.Named{m.state, m.event} {
+			e := enumOf(pass.Pkg, typ)
+			if e == nil {
+				continue
+			}
+			wired[typ] = e
+			if m.fromFiles {
+				skipUnused[typ] = true
+			}
+		}
+		if e := enumOf(pass.Pkg, m.state); e != nil {
+			states[m.state] = e
+		}
+	}
+
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	used := make(map[*types.Const]bool)
+	ins.Preorder([]ast.Node{(*ast.CompositeLit)(nil), (*ast.CallExpr)(nil)}, func(n ast.Node) {
+		if !registersTransitions(pass, n) {
+			return
+		}
+		ast.Inspect(n, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if c, ok := pass.TypesInfo.Uses[id].(*types.Const); ok {
+					used[c] = true
+				}
+			}
+			return true
+		})
+	})
+
+	for _, typ := range sortedNamed(wired) {
+		if skipUnused[typ] {
+			continue
+		}
+		for _, c := range wired[typ].members {
+			if !used[c] {
+				pass.Reportf(c.Pos(), "%s %s is never used in a transition", kindOf(typ, states), c.Name())
+			}
+		}
+	}
+
+	ins.Preorder([]ast.Node{(*ast.SwitchStmt)(nil)}, func(n ast.Node) {
+		checkSwitch(pass, n.(*ast.SwitchStmt), states)
+	})
+
+	return nil, nil
+}
+
+func kindOf(typ *types.Named, states map[*types.Named]*enum) string {
+	if _, ok := states[typ]; ok {
+		return "state"
+	}
+	return "event"
+}
+
+// findMachines returns every StateMachine instantiation in the package,
+// whether through the type itself or one of the constructors
+func findMachines(pass *analysis.Pass) []machine {
+	seen := make(map[[2]*types.Named]int)
+	var machines []machine
+	for id, inst := range pass.TypesInfo.Instances {
+		obj := pass.TypesInfo.Uses[id]
+		if obj == nil || obj.Pkg() == nil || inst.TypeArgs.Len() < 2 {
+			continue
+		}
+		fromFiles := false
+		switch path := obj.Pkg().Path(); {
+		case path == smcelPath && obj.Name() == "LoadYAML":
+			fromFiles = true
+		case path != statemachinePath:
+			continue
+		default:
+			switch obj.Name() {
+			case "StateMachine", "NewStateMachine", "NewIndexedStateMachine", "PayloadStateMachine", "NewPayloadStateMachine", "Builder", "NewBuilder":
+			case "FromDefinition", "FromDefinitionWithGuards", "LoadFS", "MustLoadFS", "LoadYAML", "LoadJSON", "LoadSCXML":
+				fromFiles = true
+			default:
+				continue
+			}
+		}
+
+		state, _ := types.Unalias(inst.TypeArgs.At(0)).(*types.Named)
+		event, _ := types.Unalias(inst.TypeArgs.At(1)).(*types.Named)
+		if state == nil || event == nil {
+			continue
+		}
+
+		key := [2]*types.Named{state, event}
+		if i, ok := seen[key]; ok {
+			machines[i].fromFiles = machines[i].fromFiles || fromFiles
+			continue
+		}
+		seen[key] = len(machines)
+		machines = append(machines, machine{state: state, event: event, fromFiles: fromFiles})
+	}
+	return machines
+}
+
+// enumOf returns the constants of typ when it is declared in pkg
+func enumOf(pkg *types.Package, typ *types.Named) *enum {
+	if typ.Obj().Pkg() != pkg {
+		return nil
+	}
+	e := &enum{typ: typ}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if c, ok := scope.Lookup(name).(*types.Const); ok && types.Identical(c.Type(), typ) {
+			e.members = append(e.members, c)
+		}
+	}
+	if len(e.members) == 0 {
+		return nil
+	}
+	sort.Slice(e.members, func(i, j int) bool { return e.members[i].Pos() < e.members[j].Pos() })
+	return e
+}
+
+// registersTransitions reports whether n is a Transition literal, a call to
+// one of the machine's methods that add transitions or part of a Builder
+// chain
+func registersTransitions(pass *analysis.Pass, n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.CompositeLit:
+		named, ok := types.Unalias(pass.TypesInfo.TypeOf(n)).(*types.Named)
+		return ok && isStatemachineObj(named.Obj()) && named.Obj().Name() == "Transition"
+	case *ast.CallExpr:
+		sel, ok := n.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return false
+		}
+		fn, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func)
+		if !ok || !isStatemachineObj(fn) {
+			return false
+		}
+		switch fn.Name() {
+		case "AddTransition", "AddTransitions", "AddTransitionStrict", "AddChoice",
+			"From", "On", "To", "Choose":
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+func isStatemachineObj(obj types.Object) bool {
+	return obj != nil && obj.Pkg() != nil && obj.Pkg().Path() == statemachinePath
+}
+
+func checkSwitch(pass *analysis.Pass, sw *ast.SwitchStmt, states map[*types.Named]*enum) {
+	if sw.Tag == nil {
+		return
+	}
+	named, ok := types.Unalias(pass.TypesInfo.TypeOf(sw.Tag)).(*types.Named)
+	if !ok {
+		return
+	}
+	e, ok := states[named]
+	if !ok {
+		return
+	}
+
+	covered := make(map[string]bool)
+	hasDefault := false
+	for _, stmt := range sw.Body.List {
+		clause := stmt.(*ast.CaseClause)
+		if clause.List == nil {
+			hasDefault = true
+			continue
+		}
+		for _, expr := range clause.List {
+			if tv, ok := pass.TypesInfo.Types[expr]; ok && tv.Value != nil {
+				covered[tv.Value.ExactString()] = true
+			}
+		}
+	}
+	if hasDefault && defaultSignifiesExhaustive {
+		return
+	}
+
+	var missing []string
+	for _, c := range e.members {
+		if !covered[c.Val().ExactString()] {
+			missing = append(missing, c.Name())
+		}
+	}
+	if len(missing) > 0 {
+		pass.Reportf(sw.Pos(), "missing cases in switch of type %s: %s",
+			typeName(named), strings.Join(missing, ", "))
+	}
+}
+
+func typeName(t *types.Named) string {
+	return fmt.Sprintf("%s.%s", t.Obj().Pkg().Name(), t.Obj().Name())
+}
+
+func sortedNamed(m map[*types.Named]*enum) []*types.Named {
+	out := make([]*types.Named, 0, len(m))
+	for t := range m {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Obj().Pos() < out[j].Obj().Pos() })
+	return out
+}
@@ -0,0 +1,22 @@
+package exhaustive_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/richardbowden/statemachine/analysis/exhaustive"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), exhaustive.Analyzer, "a", "b", "d", "e", "f", "g", "h")
+}
+
+func TestAnalyzer_DefaultSignifiesExhaustive(t *testing.T) {
+	if err := exhaustive.Analyzer.Flags.Set("default-signifies-exhaustive", "true"); err != nil {
+		t.Fatal(err)
+	}
+	defer exhaustive.Analyzer.Flags.Set("default-signifies-exhaustive", "false")
+
+	analysistest.Run(t, analysistest.TestData(), exhaustive.Analyzer, "c")
+}
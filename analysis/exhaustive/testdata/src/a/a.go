@@ -0,0 +1,78 @@
+package a
+
+import sm "github.com/richardbowden/statemachine"
+
+type OrderState string
+
+const (
+	Pending   OrderState = "Pending"
+	Shipped   OrderState = "Shipped"
+	Cancelled OrderState = "Cancelled"
+	Refunded  OrderState = "Refunded" // want `state Refunded is never used in a transition`
+)
+
+func (s OrderState) String() string { return string(s) }
+
+type OrderEvent string
+
+const (
+	Ship   OrderEvent = "Ship"
+	Cancel OrderEvent = "Cancel"
+	Refund OrderEvent = "Refund" // want `event Refund is never used in a transition`
+)
+
+func (e OrderEvent) String() string { return string(e) }
+
+func NewOrderMachine() *sm.StateMachine[OrderState, OrderEvent] {
+	m := sm.NewStateMachine[OrderState, OrderEvent]()
+	m.AddTransitions([]sm.Transition[OrderState, OrderEvent]{
+		{From: Pending, Event: Ship, To: Shipped},
+	})
+	m.AddTransition(Pending, Cancel, Cancelled)
+	// declaring a state final does not wire it either
+	m.AddFinalState(Refunded)
+	return m
+}
+
+func fire(m *sm.StateMachine[OrderState, OrderEvent]) {
+	// using a value outside a transition does not count as wiring it
+	m.Transition(Refunded, Refund)
+}
+
+func describe(s OrderState) string {
+	switch s { // want `missing cases in switch of type a.OrderState: Cancelled, Refunded`
+	case Pending:
+		return "waiting"
+	case Shipped:
+		return "on its way"
+	}
+	return ""
+}
+
+func describeWithDefault(s OrderState) string {
+	switch s { // want `missing cases in switch of type a.OrderState: Refunded`
+	case Pending, Shipped:
+		return "active"
+	case Cancelled:
+		return "cancelled"
+	default:
+		return ""
+	}
+}
+
+func describeAll(s OrderState) string {
+	switch s {
+	case Pending, Shipped, Cancelled, Refunded:
+		return "known"
+	}
+	return ""
+}
+
+func describeEvent(e OrderEvent) string {
+	// switches over event types are not checked
+	switch e {
+	case Ship:
+		return "ship"
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package b
+
+import (
+	"embed"
+
+	sm "github.com/richardbowden/statemachine"
+)
+
+type DocState string
+
+const (
+	Draft     DocState = "Draft"
+	Published DocState = "Published"
+)
+
+func (s DocState) String() string { return string(s) }
+
+type DocEvent string
+
+const Publish DocEvent = "Publish"
+
+func (e DocEvent) String() string { return string(e) }
+
+var files embed.FS
+
+// transitions live in the definition files, so unused values are not reported
+var machine, _ = sm.LoadFS(files, []DocState{Draft, Published}, []DocEvent{Publish}, "*.json")
+
+func describe(s DocState) string {
+	switch s { // want `missing cases in switch of type b.DocState: Published`
+	case Draft:
+		return "draft"
+	}
+	return ""
+}
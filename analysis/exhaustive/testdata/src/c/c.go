@@ -0,0 +1,44 @@
+package c
+
+import sm "github.com/richardbowden/statemachine"
+
+type Light string
+
+const (
+	Red   Light = "Red"
+	Green Light = "Green"
+)
+
+func (l Light) String() string { return string(l) }
+
+type Signal string
+
+const Next Signal = "Next"
+
+func (s Signal) String() string { return string(s) }
+
+type LightMachine = sm.StateMachine[Light, Signal]
+
+func New() *LightMachine {
+	m := sm.NewStateMachine[Light, Signal]()
+	m.AddTransition(Red, Next, Green)
+	m.AddTransition(Green, Next, Red)
+	return m
+}
+
+func withDefault(l Light) bool {
+	switch l {
+	case Red:
+		return false
+	default:
+		return true
+	}
+}
+
+func withoutDefault(l Light) bool {
+	switch l { // want `missing cases in switch of type c.Light: Green`
+	case Red:
+		return false
+	}
+	return true
+}
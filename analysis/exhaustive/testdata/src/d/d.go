@@ -0,0 +1,31 @@
+package d
+
+import sm "github.com/richardbowden/statemachine"
+
+type DocState string
+
+const (
+	Draft     DocState = "Draft"
+	Submitted DocState = "Submitted"
+	Approved  DocState = "Approved"
+	Rejected  DocState = "Rejected"
+	Archived  DocState = "Archived" // want `state Archived is never used in a transition`
+)
+
+func (s DocState) String() string { return string(s) }
+
+type DocEvent string
+
+const (
+	Submit DocEvent = "Submit"
+	Review DocEvent = "Review"
+)
+
+func (e DocEvent) String() string { return string(e) }
+
+func New() {
+	b := sm.NewBuilder[DocState, DocEvent]()
+	b.From(Draft).On(Submit).To(Submitted)
+	b.From(Submitted).On(Review).Choose(func(DocState, DocEvent) DocState { return Approved }, Approved, Rejected)
+	b.Build()
+}
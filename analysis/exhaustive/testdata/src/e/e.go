@@ -0,0 +1,69 @@
+package e
+
+import (
+	"strings"
+
+	sm "github.com/richardbowden/statemachine"
+)
+
+// each machine is loaded from a definition, so unused values are not reported
+
+type YAMLState string
+
+const (
+	YAMLDraft     YAMLState = "Draft"
+	YAMLPublished YAMLState = "Published"
+)
+
+type YAMLEvent string
+
+const YAMLPublish YAMLEvent = "Publish"
+
+func loadYAML() (*sm.StateMachine[YAMLState, YAMLEvent], error) {
+	return sm.LoadYAML(strings.NewReader(""), []YAMLState{YAMLDraft}, []YAMLEvent{YAMLPublish}, nil)
+}
+
+type JSONState string
+
+const (
+	JSONDraft     JSONState = "Draft"
+	JSONPublished JSONState = "Published"
+)
+
+type JSONEvent string
+
+const JSONPublish JSONEvent = "Publish"
+
+func loadJSON() (*sm.StateMachine[JSONState, JSONEvent], error) {
+	return sm.LoadJSON(strings.NewReader(""), []JSONState{JSONDraft}, []JSONEvent{JSONPublish})
+}
+
+type GuardedState string
+
+const (
+	GuardedDraft     GuardedState = "Draft"
+	GuardedPublished GuardedState = "Published"
+)
+
+type GuardedEvent string
+
+const GuardedPublish GuardedEvent = "Publish"
+
+func loadGuarded() (*sm.StateMachine[GuardedState, GuardedEvent], error) {
+	return sm.FromDefinitionWithGuards(&sm.Definition{}, []GuardedState{GuardedDraft}, []GuardedEvent{GuardedPublish}, nil)
+}
+
+type SCXMLState string
+
+const (
+	SCXMLDraft     SCXMLState = "Draft"
+	SCXMLPublished SCXMLState = "Published"
+)
+
+type SCXMLEvent string
+
+const SCXMLPublish SCXMLEvent = "Publish"
+
+func loadSCXML() (*sm.StateMachine[SCXMLState, SCXMLEvent], error) {
+	return sm.LoadSCXML(strings.NewReader(""), []SCXMLState{SCXMLDraft}, []SCXMLEvent{SCXMLPublish}, nil)
+}
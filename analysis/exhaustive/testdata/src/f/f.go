@@ -0,0 +1,25 @@
+package f
+
+import (
+	"strings"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smcel"
+)
+
+type OrderState string
+
+const (
+	Pending  OrderState = "Pending"
+	Approved OrderState = "Approved"
+	Review   OrderState = "Review"
+)
+
+type OrderEvent string
+
+const Confirm OrderEvent = "Confirm"
+
+// transitions live in the YAML definition, so unused values are not reported
+func load() (*statemachine.StateMachine[OrderState, OrderEvent], error) {
+	return smcel.LoadYAML(strings.NewReader(""), []OrderState{Pending}, []OrderEvent{Confirm}, nil)
+}
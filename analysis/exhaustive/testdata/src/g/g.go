@@ -0,0 +1,26 @@
+package g
+
+import sm "github.com/richardbowden/statemachine"
+
+type Light int
+
+const (
+	Red Light = iota
+	Green
+	Amber
+	Flashing // want `state Flashing is never used in a transition`
+)
+
+type Signal int
+
+const (
+	Next  Signal = iota
+	Fault        // want `event Fault is never used in a transition`
+)
+
+func New() {
+	m := sm.NewIndexedStateMachine[Light, Signal]()
+	m.AddTransition(Red, Next, Green)
+	m.AddTransition(Green, Next, Amber)
+	m.AddTransition(Amber, Next, Red)
+}
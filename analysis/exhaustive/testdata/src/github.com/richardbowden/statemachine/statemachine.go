@@ -0,0 +1,94 @@
+// Package statemachine is a minimal stand in for the real package, covering
+// only the API the analyzer looks at.
+package statemachine
+
+import (
+	"context"
+	"io"
+	"io/fs"
+)
+
+type State interface {
+	comparable
+}
+
+type Event interface {
+	comparable
+}
+
+type StateMachine[S State, E Event] struct{}
+
+type Transition[S State, E Event] struct {
+	From  S
+	Event E
+	To    S
+}
+
+type Definition struct{}
+
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+type Guard[S State, E Event] func(ctx context.Context, from S, event E) bool
+
+func NewStateMachine[S State, E Event]() *StateMachine[S, E] { return nil }
+
+func NewIndexedStateMachine[S, E Integer]() *StateMachine[S, E] { return nil }
+
+type PayloadStateMachine[S State, E Event, P any] struct {
+	*StateMachine[S, E]
+}
+
+func NewPayloadStateMachine[S State, E Event, P any]() *PayloadStateMachine[S, E, P] { return nil }
+
+func (sm *StateMachine[S, E]) AddTransition(from S, event E, to S) {}
+
+func (sm *StateMachine[S, E]) AddTransitions(transitions []Transition[S, E]) {}
+
+func (sm *StateMachine[S, E]) AddFinalState(state S) {}
+
+func (sm *StateMachine[S, E]) Transition(from S, event E) (S, error) { return from, nil }
+
+func LoadFS[S State, E Event](fsys fs.FS, states []S, events []E, patterns ...string) (*StateMachine[S, E], error) {
+	return nil, nil
+}
+
+func FromDefinitionWithGuards[S State, E Event](def *Definition, states []S, events []E, guards map[string]Guard[S, E]) (*StateMachine[S, E], error) {
+	return nil, nil
+}
+
+func LoadYAML[S State, E Event](r io.Reader, states []S, events []E, guards map[string]Guard[S, E]) (*StateMachine[S, E], error) {
+	return nil, nil
+}
+
+func LoadJSON[S State, E Event](r io.Reader, states []S, events []E) (*StateMachine[S, E], error) {
+	return nil, nil
+}
+
+func LoadSCXML[S State, E Event](r io.Reader, states []S, events []E, guards map[string]Guard[S, E]) (*StateMachine[S, E], error) {
+	return nil, nil
+}
+
+type Builder[S State, E Event] struct{}
+
+type FromBuilder[S State, E Event] struct{}
+
+type EventBuilder[S State, E Event] struct{}
+
+type TransitionBuilder[S State, E Event] struct{}
+
+func NewBuilder[S State, E Event]() *Builder[S, E] { return nil }
+
+func (b *Builder[S, E]) From(state S) *FromBuilder[S, E] { return nil }
+
+func (b *Builder[S, E]) Build() (*StateMachine[S, E], error) { return nil, nil }
+
+func (f *FromBuilder[S, E]) On(event E) *EventBuilder[S, E] { return nil }
+
+func (e *EventBuilder[S, E]) To(state S) *TransitionBuilder[S, E] { return nil }
+
+func (e *EventBuilder[S, E]) Choose(resolve func(S, E) S, targets ...S) *TransitionBuilder[S, E] {
+	return nil
+}
@@ -0,0 +1,27 @@
+package h
+
+import sm "github.com/richardbowden/statemachine"
+
+type OrderState string
+
+const (
+	Pending OrderState = "Pending"
+	Shipped OrderState = "Shipped"
+	Lost    OrderState = "Lost" // want `state Lost is never used in a transition`
+)
+
+type OrderEvent string
+
+const (
+	Ship   OrderEvent = "Ship"
+	Misset OrderEvent = "Misset" // want `event Misset is never used in a transition`
+)
+
+type ShipmentInfo struct {
+	Carrier string
+}
+
+func New() {
+	m := sm.NewPayloadStateMachine[OrderState, OrderEvent, ShipmentInfo]()
+	m.AddTransition(Pending, Ship, Shipped)
+}
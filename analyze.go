@@ -0,0 +1,128 @@
+package statemachine
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Report is the result of Analyze. Each list is sorted by name.
+type Report[S State, E Event] struct {
+	// Unreachable and Unused are the states Validate reports
+	Unreachable []S
+	Unused      []S
+
+	// Traps are states from which no final state can be reached, so an
+	// instance entering one can never finish. Final states themselves are
+	// not traps.
+	Traps []S
+
+	// UnusedEvents are events declared with DeclareEvents, or in a
+	// definition, that no transition is triggered by
+	UnusedEvents []E
+}
+
+// IsEmpty reports whether the analysis found no problems
+func (r *Report[S, E]) IsEmpty() bool {
+	return len(r.Unreachable) == 0 && len(r.Unused) == 0 &&
+		len(r.Traps) == 0 && len(r.UnusedEvents) == 0
+}
+
+// String formats the report, one problem per line:
+//
+//	unreachable state Refunded
+//	unused state Archived
+//	trap state Cancelled
+//	unused event Return
+func (r *Report[S, E]) String() string {
+	var b strings.Builder
+	for _, s := range r.Unreachable {
+		fmt.Fprintf(&b, "unreachable state %s\n", Name(s))
+	}
+	for _, s := range r.Unused {
+		fmt.Fprintf(&b, "unused state %s\n", Name(s))
+	}
+	for _, s := range r.Traps {
+		fmt.Fprintf(&b, "trap state %s\n", Name(s))
+	}
+	for _, e := range r.UnusedEvents {
+		fmt.Fprintf(&b, "unused event %s\n", Name(e))
+	}
+	return b.String()
+}
+
+// DeclareEvents records events as part of the machine even if no transition
+// uses them yet, so Analyze can report those that are never fired.
+// FromDefinition declares every event in the definition.
+func (sm *StateMachine[S, E]) DeclareEvents(events ...E) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	for _, e := range events {
+		sm.events[e] = true
+	}
+}
+
+// Analyze runs the checks done by Validate along with deeper ones, and
+// returns everything found as a Report, suitable for failing a CI build:
+//
+//	if report := sm.Analyze(); !report.IsEmpty() {
+//		t.Errorf("order workflow has problems:\n%s", report)
+//	}
+//
+// Traps are only looked for once at least one final state has been added with
+// AddFinalState. As with Validate, guards are assumed to pass.
+func (sm *StateMachine[S, E]) Analyze() *Report[S, E] {
+	sm.rlock()
+	defer sm.runlock()
+
+	verr := sm.validate()
+	r := &Report[S, E]{
+		Unreachable: verr.Unreachable,
+		Unused:      verr.Unused,
+	}
+
+	if len(sm.final) > 0 {
+		for _, s := range sm.allStates() {
+			if !sm.canFinish(s) {
+				r.Traps = append(r.Traps, s)
+			}
+		}
+	}
+
+	fired := make(map[E]bool)
+	for _, byEvent := range sm.transitions {
+		for event := range byEvent {
+			fired[event] = true
+		}
+	}
+	for e := range sm.events {
+		if !fired[e] {
+			r.UnusedEvents = append(r.UnusedEvents, e)
+		}
+	}
+
+	sortStates(r.Traps)
+	sortEvents(r.UnusedEvents)
+	return r
+}
+
+// canFinish reports whether a final state can be reached from state. A
+// composite state can finish if any state within it can.
+func (sm *StateMachine[S, E]) canFinish(state S) bool {
+	within := []S{state}
+	for child := range sm.parents {
+		if slices.Contains(sm.lineage(child)[1:], state) {
+			within = append(within, child)
+		}
+	}
+	for _, start := range within {
+		for s := range sm.reachableFrom(start) {
+			if sm.final[s] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package statemachine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(sm *StateMachine[OrderState, OrderEvent])
+		want  *Report[OrderState, OrderEvent]
+	}{
+		{
+			name:  "no final states",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {},
+			want:  &Report[OrderState, OrderEvent]{},
+		},
+		{
+			name: "every state can finish",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.AddFinalState(OrderStateRefunded)
+			},
+			want: &Report[OrderState, OrderEvent]{},
+		},
+		{
+			name: "traps",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.AddFinalState(OrderStateDelivered)
+			},
+			want: &Report[OrderState, OrderEvent]{
+				Traps: []OrderState{OrderStateCancelled, OrderStateRefunded},
+			},
+		},
+		{
+			name: "unused events",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.DeclareEvents(OrderEventShip, OrderEvent("Return"), OrderEvent("Hold"))
+			},
+			want: &Report[OrderState, OrderEvent]{
+				UnusedEvents: []OrderEvent{"Hold", "Return"},
+			},
+		},
+		{
+			name: "includes validation",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.SetInitialState(OrderStateShipped)
+				sm.AddFinalState(OrderStateDelivered)
+				sm.DeclareStates(OrderState("Archived"))
+			},
+			want: &Report[OrderState, OrderEvent]{
+				Unreachable: []OrderState{"Archived", OrderStateAwaiting, OrderStateCancelled, OrderStatePacking, OrderStatePending, OrderStateProcessing},
+				Unused:      []OrderState{"Archived"},
+				Traps:       []OrderState{"Archived", OrderStateCancelled, OrderStateRefunded},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewOrderStateMachine()
+			tt.setup(sm)
+			got := sm.Analyze()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+			if got.IsEmpty() != reflect.DeepEqual(tt.want, &Report[OrderState, OrderEvent]{}) {
+				t.Errorf("IsEmpty() = %v", got.IsEmpty())
+			}
+		})
+	}
+}
+
+func TestReport_String(t *testing.T) {
+	r := &Report[OrderState, OrderEvent]{
+		Unreachable:  []OrderState{OrderStateRefunded},
+		Unused:       []OrderState{"Archived"},
+		Traps:        []OrderState{OrderStateCancelled},
+		UnusedEvents: []OrderEvent{"Return"},
+	}
+	want := "unreachable state Refunded\nunused state Archived\ntrap state Cancelled\nunused event Return\n"
+	if got := r.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestAnalyze_DefinitionEvents(t *testing.T) {
+	def, err := ParseDefinition("user.json", []byte(`{
+		"states": [{"name": "Initial"}, {"name": "SignUpComplete"}],
+		"events": [{"name": "SubmitSignup"}, {"name": "SignUpFailed"}],
+		"transitions": [{"from": "Initial", "event": "SubmitSignup", "to": "SignUpComplete"}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseDefinition() error = %v", err)
+	}
+	sm, err := FromDefinition(def, allUserStates, allUserEvents)
+	if err != nil {
+		t.Fatalf("FromDefinition() error = %v", err)
+	}
+	if got := sm.Analyze().UnusedEvents; !reflect.DeepEqual(got, []UserEvent{UserEventSignupFailed}) {
+		t.Errorf("UnusedEvents = %v, want [SignUpFailed]", got)
+	}
+}
@@ -0,0 +1,94 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrNotAuthorized is matched by the AuthorizationError returned for a
+// transition its principal lacks a permission for
+var ErrNotAuthorized = errors.New("not authorized")
+
+// Principal is who a transition is made on behalf of, checked against the
+// permissions a transition requires. Applications map their users' roles to
+// permissions however they like.
+type Principal interface {
+	HasPermission(permission string) bool
+}
+
+// Permissions is a Principal holding a fixed set of permissions
+type Permissions []string
+
+// HasPermission reports whether permission is one of p
+func (p Permissions) HasPermission(permission string) bool {
+	return slices.Contains(p, permission)
+}
+
+// principalKey is the context key principals are stored under
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal a transition is
+// made on behalf of, for transitions that RequirePermission:
+//
+//	ctx = statemachine.WithPrincipal(ctx, statemachine.Permissions{"orders:ship"})
+//	newState, err := sm.TransitionContext(ctx, order.State, OrderEventShip)
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal carried by ctx, if there is one
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// RequirePermission makes a transition require every one of the given
+// permissions of the principal carried by the transition's context. It is
+// checked once the transition's guards have passed, before its actions run;
+// a transition without a principal, or whose principal lacks a permission,
+// returns an AuthorizationError matching ErrNotAuthorized.
+//
+// Like guards, permissions are only checked when transitioning.
+// CanTransition and GetValidEvents consider the transition table alone.
+func RequirePermission[S State, E Event](permissions ...string) TransitionOption[S, E] {
+	return func(e *edge[S, E]) {
+		e.permissions = append(e.permissions, permissions...)
+	}
+}
+
+// AuthorizationError is returned when a transition's principal lacks a
+// permission it requires
+type AuthorizationError[S State, E Event] struct {
+	From  S
+	Event E
+
+	// Permission is the first of the transition's required permissions the
+	// principal lacks
+	Permission string
+}
+
+func (e *AuthorizationError[S, E]) Error() string {
+	return fmt.Sprintf("not authorized: event '%s' from state '%s' requires permission '%s'", Name(e.Event), Name(e.From), e.Permission)
+}
+
+// Is reports whether target is ErrNotAuthorized
+func (e *AuthorizationError[S, E]) Is(target error) bool {
+	return target == ErrNotAuthorized
+}
+
+// authorize returns an AuthorizationError if the principal carried by ctx
+// lacks one of the edge's permissions
+func (e *edge[S, E]) authorize(ctx context.Context, from S, event E) error {
+	if len(e.permissions) == 0 {
+		return nil
+	}
+	p, _ := PrincipalFrom(ctx)
+	for _, permission := range e.permissions {
+		if p == nil || !p.HasPermission(permission) {
+			return &AuthorizationError[S, E]{From: from, Event: event, Permission: permission}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRequirePermission(t *testing.T) {
+	actionRan := false
+	sm := NewOrderStateMachine()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking,
+		RequirePermission[OrderState, OrderEvent]("orders:confirm", "orders:write"),
+		WithAction(func(ctx context.Context, from, to OrderState, event OrderEvent) error {
+			actionRan = true
+			return nil
+		}),
+	)
+
+	tests := []struct {
+		name           string
+		principal      Principal
+		wantPermission string
+	}{
+		{name: "no principal", wantPermission: "orders:confirm"},
+		{name: "missing one permission", principal: Permissions{"orders:confirm"}, wantPermission: "orders:write"},
+		{name: "every permission", principal: Permissions{"orders:write", "orders:confirm"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actionRan = false
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = WithPrincipal(ctx, tt.principal)
+			}
+			got, err := sm.TransitionContext(ctx, OrderStatePending, OrderEventConfirm)
+			if tt.wantPermission == "" {
+				if err != nil || got != OrderStatePacking {
+					t.Errorf("TransitionContext() = %v, %v, want %v", got, err, OrderStatePacking)
+				}
+				return
+			}
+
+			var ae *AuthorizationError[OrderState, OrderEvent]
+			if !errors.Is(err, ErrNotAuthorized) || !errors.As(err, &ae) || ae.Permission != tt.wantPermission {
+				t.Fatalf("TransitionContext() error = %v, want %v for %s", err, ErrNotAuthorized, tt.wantPermission)
+			}
+			if actionRan {
+				t.Error("action ran for an unauthorized transition")
+			}
+		})
+	}
+
+	// other transitions need no principal
+	if _, err := sm.Transition(OrderStatePending, OrderEventCancel); err != nil {
+		t.Errorf("Transition() error = %v for a transition requiring no permission", err)
+	}
+}
+
+func TestRequirePermission_Instance(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.AddTransition(OrderStatePending, OrderEventCancel, OrderStateCancelled,
+		RequirePermission[OrderState, OrderEvent]("orders:cancel"))
+	inst := sm.NewInstanceAt(OrderStatePending)
+
+	if err := inst.Fire(WithPrincipal(context.Background(), Permissions{"orders:read"}), OrderEventCancel); !errors.Is(err, ErrNotAuthorized) {
+		t.Errorf("Fire() error = %v, want %v", err, ErrNotAuthorized)
+	}
+	if !inst.Is(OrderStatePending) {
+		t.Errorf("Current() = %v after a denied event, want %v", inst.Current(), OrderStatePending)
+	}
+	if err := inst.Fire(WithPrincipal(context.Background(), Permissions{"orders:cancel"}), OrderEventCancel); err != nil {
+		t.Errorf("Fire() error = %v", err)
+	}
+	if p, ok := PrincipalFrom(WithPrincipal(context.Background(), Permissions{"x"})); !ok || !p.HasPermission("x") {
+		t.Errorf("PrincipalFrom() = %v, %v", p, ok)
+	}
+}
+
+func TestTransitionBuilder_RequirePermission(t *testing.T) {
+	b := NewBuilder[OrderState, OrderEvent]()
+	b.From(OrderStateAwaiting).On(OrderEventShip).To(OrderStateShipped).RequirePermission("orders:ship")
+	sm := b.MustBuild()
+
+	if _, err := sm.Transition(OrderStateAwaiting, OrderEventShip); !errors.Is(err, ErrNotAuthorized) {
+		t.Errorf("Transition() error = %v, want %v", err, ErrNotAuthorized)
+	}
+	ctx := WithPrincipal(context.Background(), Permissions{"orders:ship"})
+	if got, err := sm.TransitionContext(ctx, OrderStateAwaiting, OrderEventShip); err != nil || got != OrderStateShipped {
+		t.Errorf("TransitionContext() = %v, %v, want %v", got, err, OrderStateShipped)
+	}
+}
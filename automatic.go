@@ -0,0 +1,229 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	// ErrAutomaticLoop is returned for a transition whose automatic
+	// transitions would enter a state they had already entered
+	ErrAutomaticLoop = errors.New("automatic transitions loop")
+
+	// ErrAutomaticLimit is returned for a transition followed by more
+	// automatic transitions than the machine allows
+	ErrAutomaticLimit = errors.New("too many automatic transitions")
+)
+
+// DefaultMaxAutomatic is how many automatic transitions may follow a
+// transition on a machine not created WithMaxAutomatic
+const DefaultMaxAutomatic = 10
+
+// WithAutomatic makes a transition automatic: rather than waiting for its
+// event, it fires by itself as soon as a transition enters its state, if
+// its guards pass, as when a document is published on approval once
+// auto-publishing is enabled:
+//
+//	sm.AddTransition(DocumentStateApproved, DocumentEventPublish, DocumentStatePublished,
+//		statemachine.WithAutomatic[DocumentState, DocumentEvent](),
+//		statemachine.WithGuard(autoPublishEnabled))
+//
+// The automatic transition is part of the transition that entered its
+// state, which ends in the state the automatic transitions come to rest in.
+// Its actions and callbacks run with its own event. The callbacks of the
+// transition and of every automatic transition following it run once all
+// of them have been taken, so guards of automatic transitions are checked
+// before any callbacks run. If a veto or action of one fails, or the
+// automatic transitions loop back to a state they entered or exceed the
+// machine's limit, the transition as a whole fails with the error and no
+// callbacks run, though actions that already ran are not undone.
+// Subscribers, logging and statistics see the whole transition, from the
+// state it started in to the state it ends in.
+//
+// A state's automatic transitions are tried in the order they were added,
+// before those it inherits from its parents. The event can still be fired
+// as usual, from the state or any other the transition table allows.
+// Entering the initial state of a new instance does not fire automatic
+// transitions.
+func WithAutomatic[S State, E Event]() TransitionOption[S, E] {
+	return func(e *edge[S, E]) {
+		e.automatic = true
+	}
+}
+
+// WithMaxAutomatic limits how many automatic transitions may follow a
+// transition to n, failing it with an error matching ErrAutomaticLimit
+// beyond that. It panics if n is not positive.
+func WithMaxAutomatic(n int) Option {
+	if n <= 0 {
+		panic("statemachine: WithMaxAutomatic needs a positive limit")
+	}
+	return func(c *config) {
+		c.maxAutomatic = n
+	}
+}
+
+// noteAutomatic records that e, added for event from a state, is
+// automatic. sm must be locked.
+func (sm *StateMachine[S, E]) noteAutomatic(from S, event E, e *edge[S, E]) {
+	if !e.automatic || slices.Contains(sm.automatic[from], event) {
+		return
+	}
+	if sm.automatic == nil {
+		sm.automatic = make(map[S][]E)
+	}
+	sm.automatic[from] = append(sm.automatic[from], event)
+}
+
+// settle takes the automatic transitions whose guards pass from the state
+// first, a prepared transition, entered, until none do, and then commits
+// first and each of them, returning the state it comes to rest in. If an
+// automatic transition fails, nothing is committed, so no callbacks run and
+// h is left as it was.
+func (sm *StateMachine[S, E]) settle(ctx context.Context, first taken[S, E], h *history[S]) (S, error) {
+	sm.rlock()
+	none := len(sm.automatic) == 0
+	sm.runlock()
+	if none {
+		// no chain to follow, so none is built, keeping the transition
+		// free of allocations
+		sm.commit(ctx, first, h)
+		return first.to, nil
+	}
+
+	var zero S
+	chain, err := sm.follow(ctx, first, h, sm.prepare, true)
+	if err != nil {
+		return zero, err
+	}
+	for _, t := range chain {
+		sm.commit(ctx, t, h)
+	}
+	return chain[len(chain)-1].to, nil
+}
+
+// follow returns first followed by the automatic transitions whose guards
+// pass from the state it enters, until none do, readying each with prepare
+// as it is taken. h, which is not changed, resolves history transitions
+// along the chain. Guards are logged if logGuards is set.
+func (sm *StateMachine[S, E]) follow(ctx context.Context, first taken[S, E], h *history[S], prepare func(context.Context, taken[S, E]) error, logGuards bool) ([]taken[S, E], error) {
+	sm.rlock()
+	limit := sm.maxAutomatic
+	none := len(sm.automatic) == 0
+	sm.runlock()
+	chain := []taken[S, E]{first}
+	if none {
+		return chain, nil
+	}
+
+	// work is h as it will be once the chain so far is committed, for
+	// resolving history transitions along it
+	work := h.clone()
+	sm.noteHistory(work, first)
+	state := first.to
+	for {
+		e, event := sm.automaticEdge(ctx, state, logGuards)
+		if e == nil {
+			return chain, nil
+		}
+		if len(chain) > limit {
+			return nil, fmt.Errorf("automatic transition via '%s' from state '%s': %w (limit %d)", Name(event), Name(state), ErrAutomaticLimit, limit)
+		}
+		to, err := sm.target(ctx, e, state, event, work)
+		if err == nil && slices.ContainsFunc(chain, func(t taken[S, E]) bool { return t.to == to }) {
+			err = fmt.Errorf("%w: state '%s' entered again", ErrAutomaticLoop, Name(to))
+		}
+		t := taken[S, E]{e: e, from: state, to: to, event: event}
+		if err == nil {
+			err = prepare(ctx, t)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("automatic transition via '%s' from state '%s': %w", Name(event), Name(state), err)
+		}
+		sm.noteHistory(work, t)
+		chain = append(chain, t)
+		state = to
+	}
+}
+
+// automaticEdge returns the first automatic transition from state whose
+// guards pass, along with its event, or nil if there is none. The guards
+// are logged if logGuards is set.
+func (sm *StateMachine[S, E]) automaticEdge(ctx context.Context, state S, logGuards bool) (*edge[S, E], E) {
+	sm.rlock()
+	candidates := sm.automaticCandidates(state)
+	guardMW := sm.guardMW
+	sm.runlock()
+
+	for _, c := range candidates {
+		ok := c.e.allowed(ctx, state, c.event, guardMW)
+		if logGuards && len(c.e.guards) > 0 {
+			sm.logGuards(ctx, state, c.event, c.e.to, ok)
+		}
+		if ok {
+			return c.e, c.event
+		}
+	}
+	var zero E
+	return nil, zero
+}
+
+// automaticCandidate is an automatic transition along with its event
+type automaticCandidate[S State, E Event] struct {
+	e     *edge[S, E]
+	event E
+}
+
+// automaticCandidates returns the automatic transitions from state in the
+// order they are tried. sm must be locked.
+func (sm *StateMachine[S, E]) automaticCandidates(state S) []automaticCandidate[S, E] {
+	var candidates []automaticCandidate[S, E]
+	for s, ok := state, true; ok; s, ok = sm.parents[s] {
+		for _, event := range sm.automatic[s] {
+			for _, e := range sm.transitions[s][event] {
+				if e.automatic {
+					candidates = append(candidates, automaticCandidate[S, E]{e, event})
+				}
+			}
+		}
+	}
+	return candidates
+}
+
+// lookupAutomatic follows the automatic transitions from state as lookup
+// does events, taking the first from each state as if its guards pass, and
+// returns the state they come to rest in. sm must be locked.
+func (sm *StateMachine[S, E]) lookupAutomatic(state S) (S, error) {
+	var zero S
+	entered := []S{state}
+	for {
+		candidates := sm.automaticCandidates(state)
+		if len(candidates) == 0 {
+			return state, nil
+		}
+		c := candidates[0]
+		if len(entered) > sm.maxAutomatic {
+			return zero, fmt.Errorf("automatic transition via '%s' from state '%s': %w (limit %d)", Name(c.event), Name(state), ErrAutomaticLimit, sm.maxAutomatic)
+		}
+		to := sm.resolveTarget(c.e.to, NoHistory, nil)
+		if slices.Contains(entered, to) {
+			return zero, fmt.Errorf("automatic transition via '%s' from state '%s': %w: state '%s' entered again", Name(c.event), Name(state), ErrAutomaticLoop, Name(to))
+		}
+		entered = append(entered, to)
+		state = to
+	}
+}
+
+// cloneAutomatic copies the automatic events of each state
+func cloneAutomatic[S State, E Event](automatic map[S][]E) map[S][]E {
+	if automatic == nil {
+		return nil
+	}
+	c := make(map[S][]E, len(automatic))
+	for state, events := range automatic {
+		c[state] = slices.Clone(events)
+	}
+	return c
+}
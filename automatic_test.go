@@ -0,0 +1,210 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWithAutomatic(t *testing.T) {
+	autoPublish := false
+	var calls []string
+	sm := NewStateMachine[fulfilState, fulfilEvent]()
+	sm.AddTransition("Reviewing", "Approve", "Approved")
+	sm.AddTransition("Approved", "Publish", "Published",
+		WithAutomatic[fulfilState, fulfilEvent](),
+		WithGuard(func(ctx context.Context, from fulfilState, event fulfilEvent) bool { return autoPublish }))
+	for _, s := range []fulfilState{"Approved", "Published"} {
+		sm.OnEnter(s, func(ctx context.Context, from, to fulfilState, event fulfilEvent) {
+			calls = append(calls, fmt.Sprintf("enter %s via %s", to, event))
+		})
+	}
+	var notified []string
+	sm.Subscribe(func(ctx context.Context, from, to fulfilState, event fulfilEvent, at time.Time) {
+		notified = append(notified, fmt.Sprintf("%s --%s--> %s", from, event, to))
+	})
+
+	tests := []struct {
+		autoPublish bool
+		want        fulfilState
+		wantCalls   []string
+	}{
+		{false, "Approved", []string{"enter Approved via Approve"}},
+		{true, "Published", []string{"enter Approved via Approve", "enter Published via Publish"}},
+	}
+	for _, tt := range tests {
+		autoPublish = tt.autoPublish
+		calls, notified = nil, nil
+		got, err := sm.Transition("Reviewing", "Approve")
+		if err != nil || got != tt.want {
+			t.Errorf("Transition() with autoPublish %v = %v, %v, want %v", tt.autoPublish, got, err, tt.want)
+		}
+		if !slices.Equal(calls, tt.wantCalls) {
+			t.Errorf("callbacks = %q, want %q", calls, tt.wantCalls)
+		}
+		if want := []string{"Reviewing --Approve--> " + string(tt.want)}; !slices.Equal(notified, want) {
+			t.Errorf("subscribers notified of %q, want %q", notified, want)
+		}
+	}
+
+	// the event can still be fired by hand
+	autoPublish = false
+	if got, err := sm.Transition("Approved", "Publish"); err == nil {
+		t.Errorf("Transition(Approved, Publish) = %v, want the guard to reject it", got)
+	}
+}
+
+func TestWithAutomatic_Chains(t *testing.T) {
+	b := NewBuilder[fulfilState, fulfilEvent]()
+	b.From("Open").On("Pay").To("Paid")
+	b.From("Paid").On("Pick").To("Picking").Automatic()
+	b.From("Picking").On("Pack").To("Packed").Automatic()
+	sm := b.MustBuild()
+	if err := sm.SetParent("Packed", "Warehouse"); err != nil {
+		t.Fatal(err)
+	}
+	// inherited by Packed from Warehouse
+	sm.AddTransition("Warehouse", "Dispatch", "Dispatched", WithAutomatic[fulfilState, fulfilEvent]())
+	sm.AddTransition("Dispatched", "Deliver", "Delivered")
+	sm.Freeze()
+
+	if got, err := sm.ValidateTransitionPath("Open", []fulfilEvent{"Pay", "Deliver"}); err != nil || got != "Delivered" {
+		t.Errorf("ValidateTransitionPath() = %v, %v, want Delivered", got, err)
+	}
+	all := sm.NewInstanceAt("Open")
+	if err := all.FireAll(context.Background(), "Pay", "Deliver"); err != nil || all.Current() != "Delivered" {
+		t.Errorf("FireAll() error = %v, in %v, want it to end in Delivered", err, all.Current())
+	}
+
+	inst := sm.NewInstanceAt("Open")
+	if err := inst.Fire(context.Background(), "Pay"); err != nil {
+		t.Fatal(err)
+	}
+	if got := inst.Current(); got != "Dispatched" {
+		t.Errorf("Current() = %v, want Dispatched", got)
+	}
+	if got := inst.Version(); got != 1 {
+		t.Errorf("Version() = %d, want 1", got)
+	}
+}
+
+func TestWithAutomatic_Fails(t *testing.T) {
+	errCourier := errors.New("no courier")
+	tests := []struct {
+		name    string
+		opts    []Option
+		build   func(sm *StateMachine[fulfilState, fulfilEvent])
+		wantErr error
+
+		// wantPathErr is the error simulating or validating the event
+		// gives, without running actions
+		wantPathErr error
+	}{
+		{
+			name: "loop",
+			build: func(sm *StateMachine[fulfilState, fulfilEvent]) {
+				sm.AddTransition("B", "Next", "C", WithAutomatic[fulfilState, fulfilEvent]())
+				sm.AddTransition("C", "Back", "B", WithAutomatic[fulfilState, fulfilEvent]())
+			},
+			wantErr:     ErrAutomaticLoop,
+			wantPathErr: ErrAutomaticLoop,
+		},
+		{
+			name: "limit",
+			opts: []Option{WithMaxAutomatic(2)},
+			build: func(sm *StateMachine[fulfilState, fulfilEvent]) {
+				sm.AddTransition("B", "Next", "C", WithAutomatic[fulfilState, fulfilEvent]())
+				sm.AddTransition("C", "Next", "D", WithAutomatic[fulfilState, fulfilEvent]())
+				sm.AddTransition("D", "Next", "E", WithAutomatic[fulfilState, fulfilEvent]())
+			},
+			wantErr:     ErrAutomaticLimit,
+			wantPathErr: ErrAutomaticLimit,
+		},
+		{
+			name: "action",
+			build: func(sm *StateMachine[fulfilState, fulfilEvent]) {
+				sm.AddTransition("B", "Ship", "Shipped", WithAutomatic[fulfilState, fulfilEvent](),
+					WithAction(func(ctx context.Context, from, to fulfilState, event fulfilEvent) error { return errCourier }))
+			},
+			wantErr: errCourier,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewStateMachine[fulfilState, fulfilEvent](tt.opts...)
+			sm.AddTransition("A", "Go", "B")
+			tt.build(sm)
+			inst := sm.NewInstanceAt("A")
+
+			err := inst.Fire(context.Background(), "Go")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Fire() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := inst.Current(); got != "A" {
+				t.Errorf("Current() = %v, want A", got)
+			}
+			if _, err := sm.Simulate("A", []fulfilEvent{"Go"}); !errors.Is(err, tt.wantPathErr) {
+				t.Errorf("Simulate() error = %v, want %v", err, tt.wantPathErr)
+			}
+			if _, err := sm.ValidateTransitionPath("A", []fulfilEvent{"Go"}); !errors.Is(err, tt.wantPathErr) {
+				t.Errorf("ValidateTransitionPath() error = %v, want %v", err, tt.wantPathErr)
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithMaxAutomatic(0) did not panic")
+		}
+	}()
+	WithMaxAutomatic(0)
+}
+
+func TestWithAutomatic_FailsBeforeCallbacks(t *testing.T) {
+	errCourier := errors.New("no courier")
+	var actions, calls []string
+	action := func(ctx context.Context, from, to fulfilState, event fulfilEvent) error {
+		actions = append(actions, string(event))
+		if event == "Ship" {
+			return errCourier
+		}
+		return nil
+	}
+	sm := NewStateMachine[fulfilState, fulfilEvent]()
+	if err := sm.SetParent("Open", "Cart"); err != nil {
+		t.Fatal(err)
+	}
+	sm.AddTransition("Open", "Pay", "Paid", WithAction(action))
+	sm.AddTransition("Paid", "Pack", "Packed", WithAutomatic[fulfilState, fulfilEvent](), WithAction(action))
+	sm.AddTransition("Packed", "Ship", "Shipped", WithAutomatic[fulfilState, fulfilEvent](), WithAction(action))
+	for _, s := range []fulfilState{"Open", "Cart", "Paid", "Packed", "Shipped"} {
+		sm.OnEnter(s, func(ctx context.Context, from, to fulfilState, event fulfilEvent) {
+			calls = append(calls, "enter "+string(s))
+		})
+		sm.OnExit(s, func(ctx context.Context, from, to fulfilState, event fulfilEvent) {
+			calls = append(calls, "exit "+string(s))
+		})
+	}
+
+	inst := sm.NewInstanceAt("Open")
+	err := inst.Fire(context.Background(), "Pay")
+	if !errors.Is(err, errCourier) {
+		t.Fatalf("Fire() error = %v, want %v", err, errCourier)
+	}
+	if want := []string{"Pay", "Pack", "Ship"}; !slices.Equal(actions, want) {
+		t.Errorf("actions run = %q, want %q", actions, want)
+	}
+	if len(calls) != 0 {
+		t.Errorf("callbacks run = %q, want none", calls)
+	}
+	if got := inst.Current(); got != "Open" {
+		t.Errorf("Current() = %v, want Open", got)
+	}
+	if got := inst.Snapshot().History; len(got) != 0 {
+		t.Errorf("history = %v, want Cart's left unrecorded", got)
+	}
+}
@@ -0,0 +1,167 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrBatchAborted is matched by the results of an atomic batch's items
+	// that were not saved because another item failed
+	ErrBatchAborted = errors.New("batch aborted")
+
+	// ErrNotAtomic is returned by TransitionBatch for an atomic batch on a
+	// store that is not a BatchStore
+	ErrNotAtomic = errors.New("store cannot save a batch atomically")
+)
+
+// EntityEvent is an event for an entity, an item of a batch
+type EntityEvent[E Event] struct {
+	EntityID string
+	Event    E
+}
+
+// Change is a saved transition of an entity, as StateStore.Save takes it
+type Change[S State] struct {
+	EntityID string
+	From, To S
+	Version  int64
+}
+
+// BatchStore is a StateStore that can save many entities at once, all or
+// nothing, such as in a single database transaction
+type BatchStore[S State] interface {
+	StateStore[S]
+
+	// SaveAll saves every change as Save would, or none of them, failing
+	// with an error matching ErrConcurrentModification if any entity is
+	// no longer at its change's version
+	SaveAll(ctx context.Context, changes []Change[S]) error
+}
+
+// BatchOption configures a batch run by TransitionBatch
+type BatchOption func(*batchConfig)
+
+type batchConfig struct {
+	atomic bool
+}
+
+// Atomic makes a batch all or nothing: every item is transitioned first,
+// and only if all of them succeed are they saved, together, with the
+// store's SaveAll. The store must be a BatchStore.
+func Atomic() BatchOption {
+	return func(c *batchConfig) {
+		c.atomic = true
+	}
+}
+
+// TransitionBatch transitions many entities in one call, for bulk
+// operations such as cancelling stale orders, returning a result for each
+// item in order:
+//
+//	items := make([]statemachine.EntityEvent[OrderEvent], len(stale))
+//	for i, id := range stale {
+//		items[i] = statemachine.EntityEvent[OrderEvent]{EntityID: id, Event: OrderEventCancel}
+//	}
+//	results, err := orders.TransitionBatch(ctx, items)
+//
+// Each item is loaded, transitioned and saved in turn, and one failing does
+// not stop the rest. An entity may appear more than once, its events
+// applied in order.
+//
+// An Atomic batch still transitions every item, so each result says
+// whether its item is valid, but saves nothing unless all of them succeed.
+// The results of the items that succeeded then match ErrBatchAborted. If
+// saving the batch fails, TransitionBatch returns that error along with the
+// results, which match it too. Listeners subscribed to the machine are
+// only notified of the batch's transitions once all of them are saved.
+func (m *Manager[S, E]) TransitionBatch(ctx context.Context, items []EntityEvent[E], opts ...BatchOption) ([]Result[S, E], error) {
+	var cfg batchConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	results := make([]Result[S, E], len(items))
+	if !cfg.atomic {
+		for i, item := range items {
+			results[i] = Result[S, E]{EntityID: item.EntityID, Event: item.Event}
+			results[i].State, results[i].Err = m.Fire(ctx, item.EntityID, item.Event)
+		}
+		return results, nil
+	}
+
+	store, ok := m.store.(BatchStore[S])
+	if !ok {
+		return nil, ErrNotAtomic
+	}
+	// listeners hear of the batch's transitions only once all are saved
+	fireCtx, notices := holdNotices(ctx, &m.sm.listeners)
+	// changes holds each entity's net change, in the order first seen
+	var changes []Change[S]
+	index := make(map[string]int)
+	failed := false
+	for i, item := range items {
+		r := Result[S, E]{EntityID: item.EntityID, Event: item.Event}
+		n, seen := index[item.EntityID]
+		if !seen {
+			from, version, err := m.store.Load(ctx, item.EntityID)
+			if err != nil {
+				r.Err = err
+				results[i] = r
+				failed = true
+				continue
+			}
+			n = len(changes)
+			index[item.EntityID] = n
+			changes = append(changes, Change[S]{EntityID: item.EntityID, From: from, To: from, Version: version})
+		}
+		r.State, r.Err = m.sm.TransitionContext(WithEntityID(fireCtx, item.EntityID), changes[n].To, item.Event)
+		if r.Err != nil {
+			r.State = changes[n].To
+			failed = true
+		} else {
+			changes[n].To = r.State
+		}
+		results[i] = r
+	}
+
+	abort := func(err error) {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].State = changes[index[results[i].EntityID]].From
+				results[i].Err = err
+			}
+		}
+	}
+	if failed {
+		abort(ErrBatchAborted)
+		return results, nil
+	}
+	if err := store.SaveAll(ctx, changes); err != nil {
+		err = fmt.Errorf("cannot save batch: %w", err)
+		abort(err)
+		return results, err
+	}
+	notices.release()
+	return results, nil
+}
+
+// SaveAll saves every change if every entity is still at its change's
+// version
+func (s *MemoryStore[S]) SaveAll(ctx context.Context, changes []Change[S]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range changes {
+		if current := s.entities[c.EntityID].version; current != c.Version {
+			return fmt.Errorf("entity %s is at version %d, not %d: %w", c.EntityID, current, c.Version, ErrConcurrentModification)
+		}
+	}
+	if s.entities == nil {
+		s.entities = make(map[string]storedState[S])
+	}
+	for _, c := range changes {
+		s.entities[c.EntityID] = storedState[S]{state: c.To, version: c.Version + 1}
+	}
+	return nil
+}
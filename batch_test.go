@@ -0,0 +1,150 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// plainStore hides MemoryStore's SaveAll
+type plainStore struct {
+	StateStore[OrderState]
+}
+
+func TestManager_TransitionBatch(t *testing.T) {
+	ctx := context.Background()
+	items := []EntityEvent[OrderEvent]{
+		{EntityID: "1", Event: OrderEventConfirm},
+		{EntityID: "2", Event: OrderEventConfirm},
+		{EntityID: "1", Event: OrderEventPack},
+		{EntityID: "3", Event: OrderEventConfirm},
+	}
+
+	tests := []struct {
+		name      string
+		opts      []BatchOption
+		conflict  bool
+		want      []OrderState
+		wantErrs  []error
+		wantErr   error
+		wantSaved map[string]OrderState
+		// wantHeard lists the transitions listeners are told of
+		wantHeard []string
+	}{
+		{
+			name:     "applies each item",
+			want:     []OrderState{OrderStatePacking, OrderStateCancelled, OrderStateAwaiting, ""},
+			wantErrs: []error{nil, ErrInvalidTransition, nil, ErrNotFound},
+			wantSaved: map[string]OrderState{
+				"1": OrderStateAwaiting,
+				"2": OrderStateCancelled,
+			},
+			wantHeard: []string{"1 Confirm", "1 Pack"},
+		},
+		{
+			name:     "atomic batch with a failing item saves nothing",
+			opts:     []BatchOption{Atomic()},
+			want:     []OrderState{OrderStatePending, OrderStateCancelled, OrderStatePending, ""},
+			wantErrs: []error{ErrBatchAborted, ErrInvalidTransition, ErrBatchAborted, ErrNotFound},
+			wantSaved: map[string]OrderState{
+				"1": OrderStatePending,
+				"2": OrderStateCancelled,
+			},
+		},
+		{
+			name:      "atomic batch that fails to save",
+			opts:      []BatchOption{Atomic()},
+			conflict:  true,
+			wantErr:   ErrConcurrentModification,
+			wantSaved: map[string]OrderState{"1": OrderStatePending},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, store := newOrderManager()
+			if err := orders.Create(ctx, "1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Save(ctx, "2", "", OrderStateCancelled, 0); err != nil {
+				t.Fatal(err)
+			}
+			var heard []string
+			orders.sm.Subscribe(func(ctx context.Context, from, to OrderState, event OrderEvent, at time.Time) {
+				id, _ := EntityIDFrom(ctx)
+				heard = append(heard, id+" "+string(event))
+			})
+			batch := items
+			if tt.conflict {
+				batch = items[:1]
+				orders.sm.OnEnter(OrderStatePacking, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+					// another writer gets in while the batch is transitioning
+					if err := store.Save(ctx, "1", OrderStatePending, OrderStatePending, 1); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+
+			results, err := orders.TransitionBatch(ctx, batch, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TransitionBatch() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(results) != 1 || !errors.Is(results[0].Err, tt.wantErr) || results[0].State != OrderStatePending {
+					t.Errorf("results = %+v, want each to report %v", results, tt.wantErr)
+				}
+			}
+			for i, want := range tt.want {
+				r := results[i]
+				if r.EntityID != items[i].EntityID || r.Event != items[i].Event || r.State != want || !errors.Is(r.Err, tt.wantErrs[i]) {
+					t.Errorf("result %d = %+v, want %v, %v", i, r, want, tt.wantErrs[i])
+				}
+			}
+			for id, want := range tt.wantSaved {
+				if got, _ := orders.State(ctx, id); got != want {
+					t.Errorf("entity %s saved in %v, want %v", id, got, want)
+				}
+			}
+			if !reflect.DeepEqual(heard, tt.wantHeard) {
+				t.Errorf("listeners heard %v, want %v", heard, tt.wantHeard)
+			}
+		})
+	}
+}
+
+func TestManager_TransitionBatchAtomicNotifiesOnceSaved(t *testing.T) {
+	ctx := context.Background()
+	orders, _ := newOrderManager()
+	if err := orders.Create(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	var heard []string
+	orders.sm.Subscribe(func(ctx context.Context, from, to OrderState, event OrderEvent, at time.Time) {
+		if got, _ := orders.State(ctx, "1"); got != OrderStateAwaiting {
+			t.Errorf("listener told of %s while entity 1 is saved in %v", event, got)
+		}
+		id, _ := EntityIDFrom(ctx)
+		heard = append(heard, id+" "+string(event))
+	})
+
+	items := []EntityEvent[OrderEvent]{
+		{EntityID: "1", Event: OrderEventConfirm},
+		{EntityID: "1", Event: OrderEventPack},
+	}
+	if _, err := orders.TransitionBatch(ctx, items, Atomic()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1 Confirm", "1 Pack"}; !reflect.DeepEqual(heard, want) {
+		t.Errorf("listeners heard %v, want %v", heard, want)
+	}
+}
+
+func TestManager_TransitionBatchNotAtomic(t *testing.T) {
+	orders := NewManager(NewOrderStateMachine(), StateStore[OrderState](plainStore{&MemoryStore[OrderState]{}}))
+	_, err := orders.TransitionBatch(context.Background(), []EntityEvent[OrderEvent]{{EntityID: "1", Event: OrderEventConfirm}}, Atomic())
+	if !errors.Is(err, ErrNotAtomic) {
+		t.Errorf("TransitionBatch() error = %v, want %v", err, ErrNotAtomic)
+	}
+}
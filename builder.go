@@ -0,0 +1,353 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Builder assembles a state machine one transition at a time with a fluent
+// API, which reads better than transition slices for large machines:
+//
+//	b := NewBuilder[DocumentState, DocumentEvent]()
+//	b.From(Draft).On(Submit).To(Submitted)
+//	b.From(Reviewing).On(Approve).To(Approved).
+//		WithGuard(hasTwoReviews).
+//		WithAction(notifyAuthor)
+//	sm, err := b.Build()
+//
+// Problems such as incomplete or conflicting transitions are collected and
+// reported together by Build.
+type Builder[S State, E Event] struct {
+	rules   []*TransitionBuilder[S, E]
+	onEnter []stateCallback[S, E]
+	onExit  []stateCallback[S, E]
+	onFinal []Callback[S, E]
+	vetoes  []Veto[S, E]
+
+	initial    S
+	hasInitial bool
+	final      []S
+	middleware []Middleware[S, E]
+	stateMeta  []stateMeta[S]
+	deferred   []stateEvents[S, E]
+}
+
+type stateMeta[S State] struct {
+	state S
+	meta  Metadata
+}
+
+type stateEvents[S State, E Event] struct {
+	state  S
+	events []E
+}
+
+type stateCallback[S State, E Event] struct {
+	state S
+	fn    Callback[S, E]
+}
+
+// NewBuilder creates an empty builder
+func NewBuilder[S State, E Event]() *Builder[S, E] {
+	return &Builder[S, E]{}
+}
+
+// From starts a transition from the given state
+func (b *Builder[S, E]) From(state S) *FromBuilder[S, E] {
+	rule := &TransitionBuilder[S, E]{from: state}
+	b.rules = append(b.rules, rule)
+	return &FromBuilder[S, E]{rule: rule}
+}
+
+// Initial sets the state new instances start in
+func (b *Builder[S, E]) Initial(state S) *Builder[S, E] {
+	b.initial = state
+	b.hasInitial = true
+	return b
+}
+
+// Final declares the given states final
+func (b *Builder[S, E]) Final(states ...S) *Builder[S, E] {
+	b.final = append(b.final, states...)
+	return b
+}
+
+// StateMeta attaches metadata to a state. See StateMachine.SetStateMeta.
+func (b *Builder[S, E]) StateMeta(state S, m Metadata) *Builder[S, E] {
+	b.stateMeta = append(b.stateMeta, stateMeta[S]{state, m})
+	return b
+}
+
+// Use adds middleware run around every transition. See StateMachine.Use.
+func (b *Builder[S, E]) Use(mw ...Middleware[S, E]) *Builder[S, E] {
+	b.middleware = append(b.middleware, mw...)
+	return b
+}
+
+// OnEnter registers a callback to run whenever a transition enters state
+func (b *Builder[S, E]) OnEnter(state S, fn Callback[S, E]) *Builder[S, E] {
+	b.onEnter = append(b.onEnter, stateCallback[S, E]{state, fn})
+	return b
+}
+
+// OnExit registers a callback to run whenever a transition leaves state
+func (b *Builder[S, E]) OnExit(state S, fn Callback[S, E]) *Builder[S, E] {
+	b.onExit = append(b.onExit, stateCallback[S, E]{state, fn})
+	return b
+}
+
+// OnFinal registers a callback to run whenever a transition enters a final
+// state. See StateMachine.OnFinal.
+func (b *Builder[S, E]) OnFinal(fn Callback[S, E]) *Builder[S, E] {
+	b.onFinal = append(b.onFinal, fn)
+	return b
+}
+
+// BeforeTransition registers a hook that can veto any transition. See
+// StateMachine.BeforeTransition.
+func (b *Builder[S, E]) BeforeTransition(fn Veto[S, E]) *Builder[S, E] {
+	b.vetoes = append(b.vetoes, fn)
+	return b
+}
+
+// Defer makes instances in state hold on to the given events. See
+// StateMachine.Defer.
+func (b *Builder[S, E]) Defer(state S, events ...E) *Builder[S, E] {
+	b.deferred = append(b.deferred, stateEvents[S, E]{state, events})
+	return b
+}
+
+// FromBuilder is a transition with a source state, waiting for its event
+type FromBuilder[S State, E Event] struct {
+	rule *TransitionBuilder[S, E]
+}
+
+// On sets the event that triggers the transition
+func (f *FromBuilder[S, E]) On(event E) *EventBuilder[S, E] {
+	f.rule.event = event
+	f.rule.hasEvent = true
+	return &EventBuilder[S, E]{rule: f.rule}
+}
+
+// EventBuilder is a transition with a source state and event, waiting for
+// its target state
+type EventBuilder[S State, E Event] struct {
+	rule *TransitionBuilder[S, E]
+}
+
+// To sets the state the transition leads to
+func (e *EventBuilder[S, E]) To(state S) *TransitionBuilder[S, E] {
+	e.rule.to = state
+	e.rule.hasTo = true
+	return e.rule
+}
+
+// Choose makes the transition a choice, with resolve picking one of targets
+// when it fires. See StateMachine.AddChoice.
+func (e *EventBuilder[S, E]) Choose(resolve Resolver[S, E], targets ...S) *TransitionBuilder[S, E] {
+	e.rule.hasTo = true
+	switch {
+	case resolve == nil:
+		e.rule.errs = append(e.rule.errs, errors.New("nil resolver"))
+	case len(targets) == 0:
+		e.rule.errs = append(e.rule.errs, errors.New("choice has no targets"))
+	default:
+		e.rule.to = targets[0]
+		e.rule.opts = append(e.rule.opts, withChoice(resolve, targets))
+	}
+	return e.rule
+}
+
+// TransitionBuilder is a complete transition that guards and actions can be
+// attached to
+type TransitionBuilder[S State, E Event] struct {
+	from     S
+	event    E
+	to       S
+	hasEvent bool
+	hasTo    bool
+	guarded  bool
+	opts     []TransitionOption[S, E]
+	errs     []error
+}
+
+// WithGuard attaches a guard that must pass for the transition to fire
+func (t *TransitionBuilder[S, E]) WithGuard(fn Guard[S, E]) *TransitionBuilder[S, E] {
+	if fn == nil {
+		t.errs = append(t.errs, errors.New("nil guard"))
+		return t
+	}
+	t.opts = append(t.opts, WithGuard(fn))
+	t.guarded = true
+	return t
+}
+
+// WithAction attaches an action to run when the transition fires
+func (t *TransitionBuilder[S, E]) WithAction(fn Action[S, E]) *TransitionBuilder[S, E] {
+	if fn == nil {
+		t.errs = append(t.errs, errors.New("nil action"))
+		return t
+	}
+	t.opts = append(t.opts, WithAction(fn))
+	return t
+}
+
+// WithCompensation attaches an action undoing the transition, run by a Saga
+// if a later step fails
+func (t *TransitionBuilder[S, E]) WithCompensation(fn Action[S, E]) *TransitionBuilder[S, E] {
+	if fn == nil {
+		t.errs = append(t.errs, errors.New("nil compensation"))
+		return t
+	}
+	t.opts = append(t.opts, WithCompensation(fn))
+	return t
+}
+
+// WithHistory makes the transition enter its composite target state via
+// history, resuming the substate that was last active
+func (t *TransitionBuilder[S, E]) WithHistory(kind HistoryKind) *TransitionBuilder[S, E] {
+	t.opts = append(t.opts, WithHistory[S, E](kind))
+	return t
+}
+
+// WithMeta attaches metadata to the transition. See GetTransitionMeta.
+func (t *TransitionBuilder[S, E]) WithMeta(m Metadata) *TransitionBuilder[S, E] {
+	t.opts = append(t.opts, WithMeta[S, E](m))
+	return t
+}
+
+// WithPriority sets the order the transition is tried in when others share
+// its state and event. See WithPriority.
+func (t *TransitionBuilder[S, E]) WithPriority(n int) *TransitionBuilder[S, E] {
+	t.opts = append(t.opts, WithPriority[S, E](n))
+	return t
+}
+
+// Automatic makes the transition fire by itself once its state is entered,
+// if its guards pass. See WithAutomatic.
+func (t *TransitionBuilder[S, E]) Automatic() *TransitionBuilder[S, E] {
+	t.opts = append(t.opts, WithAutomatic[S, E]())
+	return t
+}
+
+// RequirePermission makes the transition require the given permissions of
+// its principal. See RequirePermission.
+func (t *TransitionBuilder[S, E]) RequirePermission(permissions ...string) *TransitionBuilder[S, E] {
+	t.opts = append(t.opts, RequirePermission[S, E](permissions...))
+	return t
+}
+
+// describe names the transition for error messages
+func (t *TransitionBuilder[S, E]) describe(n int) string {
+	if !t.hasEvent {
+		return fmt.Sprintf("transition %d (from '%s')", n, Name(t.from))
+	}
+	return fmt.Sprintf("transition %d (event '%s' from '%s')", n, Name(t.event), Name(t.from))
+}
+
+// Build validates every transition and returns the assembled machine, or an
+// error listing all the problems found. Options are passed to NewStateMachine.
+//
+// Transitions may share a state and event as long as at most one of them has
+// no guard. Transitions the options forbid, such as one that would create a
+// cycle in a machine created WithAcyclic, are reported too.
+func (b *Builder[S, E]) Build(opts ...Option) (*StateMachine[S, E], error) {
+	type key struct {
+		from  S
+		event E
+	}
+	unguarded := make(map[key]int, len(b.rules))
+
+	var errs []error
+	for i, rule := range b.rules {
+		n := i + 1
+		switch {
+		case !rule.hasEvent:
+			errs = append(errs, fmt.Errorf("%s: no event given, call On", rule.describe(n)))
+			continue
+		case !rule.hasTo:
+			errs = append(errs, fmt.Errorf("%s: no target state given, call To", rule.describe(n)))
+			continue
+		}
+		for _, err := range rule.errs {
+			errs = append(errs, fmt.Errorf("%s: %w", rule.describe(n), err))
+		}
+		if rule.guarded {
+			continue
+		}
+		k := key{rule.from, rule.event}
+		if prev, exists := unguarded[k]; exists {
+			errs = append(errs, fmt.Errorf("%s: already defined by transition %d", rule.describe(n), prev))
+			continue
+		}
+		unguarded[k] = n
+	}
+	for _, cb := range append(b.onEnter, b.onExit...) {
+		if cb.fn == nil {
+			errs = append(errs, fmt.Errorf("nil callback for state '%s'", Name(cb.state)))
+		}
+	}
+	for i, fn := range b.onFinal {
+		if fn == nil {
+			errs = append(errs, fmt.Errorf("final callback %d is nil", i+1))
+		}
+	}
+	for i, fn := range b.vetoes {
+		if fn == nil {
+			errs = append(errs, fmt.Errorf("before transition hook %d is nil", i+1))
+		}
+	}
+	for i, mw := range b.middleware {
+		if mw == nil {
+			errs = append(errs, fmt.Errorf("middleware %d is nil", i+1))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid state machine definition: %w", errors.Join(errs...))
+	}
+
+	sm := NewStateMachine[S, E](opts...)
+	for i, rule := range b.rules {
+		if err := sm.addTransition(rule.from, rule.event, rule.to, rule.opts, sm.strict); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", rule.describe(i+1), err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid state machine definition: %w", errors.Join(errs...))
+	}
+	if b.hasInitial {
+		sm.SetInitialState(b.initial)
+	}
+	sm.Use(b.middleware...)
+	for _, m := range b.stateMeta {
+		sm.SetStateMeta(m.state, m.meta)
+	}
+	for _, s := range b.final {
+		sm.AddFinalState(s)
+	}
+	for _, d := range b.deferred {
+		sm.Defer(d.state, d.events...)
+	}
+	for _, cb := range b.onEnter {
+		sm.OnEnter(cb.state, cb.fn)
+	}
+	for _, cb := range b.onExit {
+		sm.OnExit(cb.state, cb.fn)
+	}
+	for _, fn := range b.onFinal {
+		sm.OnFinal(fn)
+	}
+	for _, fn := range b.vetoes {
+		sm.BeforeTransition(fn)
+	}
+	return sm, nil
+}
+
+// MustBuild is like Build but panics if the definition is invalid
+func (b *Builder[S, E]) MustBuild(opts ...Option) *StateMachine[S, E] {
+	sm, err := b.Build(opts...)
+	if err != nil {
+		panic("statemachine: " + err.Error())
+	}
+	return sm
+}
@@ -0,0 +1,113 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	var calls []string
+	allow := true
+
+	b := NewBuilder[UserState, UserEvent]()
+	b.From(UserStateInitial).On(UserEventSubmitSignUp).To(UserStateEmailPendingVerification).
+		WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+			calls = append(calls, "send email")
+			return nil
+		})
+	b.From(UserStateEmailPendingVerification).On(UserEventClickVerificationLink).To(UserStateEmailVerified).
+		WithGuard(func(ctx context.Context, from UserState, event UserEvent) bool { return allow })
+	b.OnEnter(UserStateEmailVerified, func(ctx context.Context, from, to UserState, event UserEvent) {
+		calls = append(calls, "enter verified")
+	})
+	b.OnFinal(func(ctx context.Context, from, to UserState, event UserEvent) {
+		calls = append(calls, "finished")
+	})
+	b.Initial(UserStateInitial).Final(UserStateSignUpComplete)
+
+	sm, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if !sm.IsFinalState(UserStateSignUpComplete) {
+		t.Errorf("IsFinalState(%v) = false, want true", UserStateSignUpComplete)
+	}
+
+	inst := sm.NewInstance()
+	ctx := context.Background()
+	if err := inst.Fire(ctx, UserEventSubmitSignUp); err != nil {
+		t.Fatalf("Fire(SubmitSignUp) error = %v", err)
+	}
+
+	allow = false
+	if err := inst.Fire(ctx, UserEventClickVerificationLink); !errors.Is(err, ErrGuardRejected) {
+		t.Fatalf("Fire(ClickVerificationLink) error = %v, want ErrGuardRejected", err)
+	}
+
+	allow = true
+	if err := inst.Fire(ctx, UserEventClickVerificationLink); err != nil {
+		t.Fatalf("Fire(ClickVerificationLink) error = %v", err)
+	}
+	if !inst.Is(UserStateEmailVerified) {
+		t.Errorf("Current() = %v, want %v", inst.Current(), UserStateEmailVerified)
+	}
+	if strings.Join(calls, ",") != "send email,enter verified" {
+		t.Errorf("calls = %v", calls)
+	}
+}
+
+func TestBuilder_BuildReportsAllProblems(t *testing.T) {
+	b := NewBuilder[UserState, UserEvent]()
+	b.From(UserStateInitial).On(UserEventSubmitSignUp).To(UserStateEmailPendingVerification)
+	b.From(UserStateInitial).On(UserEventSubmitSignUp).To(UserStateRejected)
+	b.From(UserStateEmailVerified)
+	b.From(UserStateEmailVerified).On(UserEventCompleteProfile)
+	b.From(UserStateEmailPendingVerification).On(UserEventSignupFailed).To(UserStateRejected).WithGuard(nil)
+	b.OnFinal(nil)
+
+	sm, err := b.Build()
+	if err == nil {
+		t.Fatalf("Build() expected error, got machine %v", sm)
+	}
+
+	for _, want := range []string{
+		"transition 2 (event 'SubmitSignup' from 'Initial'): already defined by transition 1",
+		"transition 3 (from 'EmailVerified'): no event given, call On",
+		"transition 4 (event 'CompleteProfile' from 'EmailVerified'): no target state given, call To",
+		"transition 5 (event 'SignUpFailed' from 'EmailPendingVerification'): nil guard",
+		"final callback 1 is nil",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Build() error missing %q\n%v", want, err)
+		}
+	}
+}
+
+func TestBuilder_BuildReportsOptionViolations(t *testing.T) {
+	b := NewBuilder[UserState, UserEvent]()
+	b.From(UserStateInitial).On(UserEventSubmitSignUp).To(UserStateEmailPendingVerification)
+	b.From(UserStateEmailPendingVerification).On(UserEventSignupFailed).To(UserStateInitial)
+
+	sm, err := b.Build(WithAcyclic())
+	if !errors.Is(err, ErrCycle) {
+		t.Fatalf("Build(WithAcyclic()) = %v, %v, want an error matching %v", sm, err, ErrCycle)
+	}
+	if want := "transition 2 (event 'SignUpFailed' from 'EmailPendingVerification')"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Build() error missing %q\n%v", want, err)
+	}
+}
+
+func TestBuilder_MustBuildPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustBuild() did not panic for an incomplete transition")
+		}
+	}()
+
+	b := NewBuilder[UserState, UserEvent]()
+	b.From(UserStateInitial)
+	b.MustBuild()
+}
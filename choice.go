@@ -0,0 +1,68 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+)
+
+// Resolver picks the target of a choice transition at runtime, for example
+// approving or rejecting a review depending on its score
+type Resolver[S State, E Event] func(ctx context.Context, from S, event E) (S, error)
+
+// AddChoice adds a transition whose target is chosen by resolve when it
+// fires. targets lists every state resolve may return; returning any other
+// state, or an error, aborts the transition.
+//
+//	sm.AddChoice(ReviewStateScoring, ReviewEventScore, func(ctx context.Context, from ReviewState, event ReviewEvent) (ReviewState, error) {
+//		if scoreFrom(ctx) >= 7 {
+//			return ReviewStateApproved, nil
+//		}
+//		return ReviewStateRejected, nil
+//	}, []ReviewState{ReviewStateApproved, ReviewStateRejected})
+//
+// The resolver runs after guards and before actions. Queries that do not fire
+// the transition, such as GetNextState, GetTransitions and
+// ValidateTransitionPath, cannot run it and report the first target instead.
+// AddChoice panics if no targets are given.
+func (sm *StateMachine[S, E]) AddChoice(from S, event E, resolve Resolver[S, E], targets []S, opts ...TransitionOption[S, E]) {
+	if len(targets) == 0 {
+		panic(fmt.Sprintf("statemachine: choice for event '%s' from state '%s' has no targets", Name(event), Name(from)))
+	}
+	opts = append([]TransitionOption[S, E]{withChoice(resolve, targets)}, opts...)
+	sm.AddTransition(from, event, targets[0], opts...)
+}
+
+func withChoice[S State, E Event](resolve Resolver[S, E], targets []S) TransitionOption[S, E] {
+	return func(e *edge[S, E]) {
+		e.resolve = resolve
+		e.choices = append([]S(nil), targets...)
+	}
+}
+
+// targets returns every state the edge may lead to
+func (e *edge[S, E]) targets() []S {
+	if e.resolve != nil {
+		return e.choices
+	}
+	return []S{e.to}
+}
+
+// choose returns the state the edge leads to, running its resolver if it is
+// a choice
+func (e *edge[S, E]) choose(ctx context.Context, from S, event E) (S, error) {
+	if e.resolve == nil {
+		return e.to, nil
+	}
+	to, err := e.resolve(ctx, from, event)
+	if err != nil {
+		var zero S
+		return zero, fmt.Errorf("choice for event '%s' from state '%s' failed: %w", Name(event), Name(from), err)
+	}
+	for _, s := range e.choices {
+		if s == to {
+			return to, nil
+		}
+	}
+	var zero S
+	return zero, fmt.Errorf("choice for event '%s' from state '%s' returned '%s', which is not one of its targets", Name(event), Name(from), Name(to))
+}
@@ -0,0 +1,120 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	docApproved docState = "Approved"
+	docRejected docState = "Rejected"
+
+	docScore docEvent = "Score"
+)
+
+type scoreKey struct{}
+
+func scoreResolver(ctx context.Context, from docState, event docEvent) (docState, error) {
+	score, ok := ctx.Value(scoreKey{}).(int)
+	switch {
+	case !ok:
+		return "", errors.New("no score")
+	case score >= 7:
+		return docApproved, nil
+	case score >= 0:
+		return docRejected, nil
+	default:
+		// not one of the declared targets
+		return docDraft, nil
+	}
+}
+
+func TestAddChoice(t *testing.T) {
+	sm := NewStateMachine[docState, docEvent]()
+	var actionTo docState
+	sm.AddChoice(docReview, docScore, scoreResolver,
+		[]docState{docApproved, docRejected},
+		WithAction(func(ctx context.Context, from, to docState, event docEvent) error {
+			actionTo = to
+			return nil
+		}),
+	)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    docState
+		wantErr string
+	}{
+		{"high score approves", context.WithValue(context.Background(), scoreKey{}, 9), docApproved, ""},
+		{"low score rejects", context.WithValue(context.Background(), scoreKey{}, 3), docRejected, ""},
+		{"resolver error", context.Background(), "", "choice for event 'Score' from state 'Review' failed: no score"},
+		{"undeclared target", context.WithValue(context.Background(), scoreKey{}, -1), "", "returned 'Draft', which is not one of its targets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actionTo = ""
+			got, err := sm.TransitionContext(tt.ctx, docReview, docScore)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("TransitionContext() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				if actionTo != "" {
+					t.Errorf("action ran after failed choice")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TransitionContext() error = %v", err)
+			}
+			if got != tt.want || actionTo != tt.want {
+				t.Errorf("TransitionContext() = %v, action saw %v, want %v", got, actionTo, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddChoice_StaticQueries(t *testing.T) {
+	sm := NewStateMachine[docState, docEvent]()
+	sm.AddChoice(docReview, docScore, scoreResolver,
+		[]docState{docApproved, docRejected})
+
+	if next, ok := sm.GetNextState(docReview, docScore); !ok || next != docApproved {
+		t.Errorf("GetNextState() = %v, %v, want first target %v", next, ok, docApproved)
+	}
+	states := sm.GetAllStates()
+	if len(states) != 3 {
+		t.Errorf("GetAllStates() = %v, want Review and both targets", states)
+	}
+}
+
+func TestAddChoice_PanicsWithoutTargets(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("AddChoice() expected panic with no targets")
+		}
+	}()
+	NewStateMachine[docState, docEvent]().AddChoice(docReview, docScore, scoreResolver, nil)
+}
+
+func TestBuilder_Choose(t *testing.T) {
+	b := NewBuilder[docState, docEvent]()
+	b.From(docReview).On(docScore).Choose(scoreResolver, docApproved, docRejected)
+	sm, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	ctx := context.WithValue(context.Background(), scoreKey{}, 2)
+	if got, err := sm.TransitionContext(ctx, docReview, docScore); err != nil || got != docRejected {
+		t.Errorf("TransitionContext() = %v, %v, want %v", got, err, docRejected)
+	}
+
+	b = NewBuilder[docState, docEvent]()
+	b.From(docReview).On(docScore).Choose(nil)
+	if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), "nil resolver") {
+		t.Errorf("Build() error = %v, want nil resolver", err)
+	}
+}
@@ -0,0 +1,101 @@
+package statemachine
+
+import "maps"
+
+// Clone returns an independent copy of the machine: its transitions,
+// hierarchy, declared events, declared, initial and final states, timeouts,
+// crons, metadata, deferred events, callbacks and middleware. Changes to the
+// copy do not affect the original, so a shared base machine can be
+// specialised, for example per tenant:
+//
+//	tenantMachine := baseMachine.Clone()
+//	tenantMachine.AddTransition(OrderStateShipped, OrderEventReturn, OrderStateReturned)
+//
+// The copy is never frozen, even if the original is, and keeps its locking,
+// strict, acyclic, coverage, dwell stats, stats, reversible, timeline, logger
+// and clock options and its dead-letter sink, though its coverage and
+// statistics start empty.
+// Subscriptions are not copied; they belong to the original.
+func (sm *StateMachine[S, E]) Clone() *StateMachine[S, E] {
+	sm.rlock()
+	defer sm.runlock()
+
+	c := &StateMachine[S, E]{
+		transitions:     make(map[S]map[E][]*edge[S, E], len(sm.transitions)),
+		onEnter:         cloneCallbacks(sm.onEnter),
+		onExit:          cloneCallbacks(sm.onExit),
+		onFinal:         append([]Callback[S, E](nil), sm.onFinal...),
+		vetoes:          append([]Veto[S, E](nil), sm.vetoes...),
+		parents:         maps.Clone(sm.parents),
+		initial:         maps.Clone(sm.initial),
+		final:           maps.Clone(sm.final),
+		declared:        maps.Clone(sm.declared),
+		events:          maps.Clone(sm.events),
+		stateMeta:       make(map[S]Metadata, len(sm.stateMeta)),
+		middleware:      append([]Middleware[S, E](nil), sm.middleware...),
+		guardMW:         append([]GuardMiddleware[S, E](nil), sm.guardMW...),
+		actionMW:        append([]ActionMiddleware[S, E](nil), sm.actionMW...),
+		initialState:    sm.initialState,
+		hasInitialState: sm.hasInitialState,
+		version:         sm.version,
+		locking:         sm.locking,
+		strict:          sm.strict,
+		acyclic:         sm.acyclic,
+		logger:          sm.logger,
+		timeouts:        maps.Clone(sm.timeouts),
+		crons:           append([]cronTrigger[S, E](nil), sm.crons...),
+		clock:           sm.clock,
+		undoDepth:       sm.undoDepth,
+		timelineDepth:   sm.timelineDepth,
+		automatic:       cloneAutomatic(sm.automatic),
+		deferred:        cloneAutomatic(sm.deferred),
+		deadLetters:     sm.deadLetters,
+		maxAutomatic:    sm.maxAutomatic,
+		stateIndex:      sm.stateIndex,
+		eventIndex:      sm.eventIndex,
+	}
+	for from, byEvent := range sm.transitions {
+		c.transitions[from] = make(map[E][]*edge[S, E], len(byEvent))
+		for event, edges := range byEvent {
+			copies := make([]*edge[S, E], len(edges))
+			for i, e := range edges {
+				copies[i] = e.clone()
+			}
+			c.transitions[from][event] = copies
+		}
+	}
+	for state, m := range sm.stateMeta {
+		c.stateMeta[state] = m.clone()
+	}
+	if sm.coverage != nil {
+		c.coverage = &coverage[S, E]{taken: make(map[*edge[S, E]]bool)}
+	}
+	if sm.dwell != nil {
+		c.dwell = &dwellStats[S]{stats: make(map[S]*DwellStat[S])}
+	}
+	if sm.stats != nil {
+		c.stats = newStats[S, E]()
+	}
+	return c
+}
+
+// clone returns a copy of e that shares no slices with it
+func (e *edge[S, E]) clone() *edge[S, E] {
+	c := *e
+	c.guards = append([]Guard[S, E](nil), e.guards...)
+	c.guardNames = append([]string(nil), e.guardNames...)
+	c.actions = append([]Action[S, E](nil), e.actions...)
+	c.permissions = append([]string(nil), e.permissions...)
+	c.compensations = append([]Action[S, E](nil), e.compensations...)
+	c.choices = append([]S(nil), e.choices...)
+	c.meta = e.meta.clone()
+	return &c
+}
+
+func cloneCallbacks[S State, E Event](m map[S][]Callback[S, E]) map[S][]Callback[S, E] {
+	c := make(map[S][]Callback[S, E], len(m))
+	for state, fns := range m {
+		c[state] = append([]Callback[S, E](nil), fns...)
+	}
+	return c
+}
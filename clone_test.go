@@ -0,0 +1,75 @@
+package statemachine
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClone_IsIndependent(t *testing.T) {
+	base := NewOrderStateMachine()
+	base.SetInitialState(OrderStatePending)
+	base.AddFinalState(OrderStateRefunded)
+	base.SetStateMeta(OrderStateShipped, Metadata{Label: "Shipped", Tags: []string{"visible"}})
+	base.Freeze()
+
+	tenant := base.Clone()
+	if tenant.IsFrozen() {
+		t.Fatalf("Clone() of a frozen machine is frozen")
+	}
+	tenant.AddTransition(OrderStateDelivered, OrderEventCancel, OrderStateCancelled)
+	tenant.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateShipped)
+	tenant.SetStateMeta(OrderStateShipped, Metadata{Label: "Dispatched"})
+
+	if base.CanTransition(OrderStateDelivered, OrderEventCancel) {
+		t.Errorf("transition added to clone is visible in the original")
+	}
+	if got, _ := base.Transition(OrderStatePending, OrderEventConfirm); got != OrderStatePacking {
+		t.Errorf("original Transition(Pending, Confirm) = %v, want %v", got, OrderStatePacking)
+	}
+	if got, _ := tenant.Transition(OrderStatePending, OrderEventConfirm); got != OrderStateShipped {
+		t.Errorf("clone Transition(Pending, Confirm) = %v, want %v", got, OrderStateShipped)
+	}
+	if m, _ := base.GetStateMeta(OrderStateShipped); m.Label != "Shipped" {
+		t.Errorf("original state label = %q, want %q", m.Label, "Shipped")
+	}
+
+	// everything else carries over
+	if initial, ok := tenant.InitialState(); !ok || initial != OrderStatePending {
+		t.Errorf("clone InitialState() = %v, %v, want %v", initial, ok, OrderStatePending)
+	}
+	if !tenant.IsFinalState(OrderStateRefunded) {
+		t.Errorf("clone IsFinalState(Refunded) = false, want true")
+	}
+	if !tenant.IsSubstateOf(OrderStatePacking, OrderStateProcessing) {
+		t.Errorf("clone lost the state hierarchy")
+	}
+}
+
+func TestClone_CopiesBehaviour(t *testing.T) {
+	var calls []string
+	base := NewOrderStateMachine()
+	base.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped,
+		WithAction(func(ctx context.Context, from, to OrderState, event OrderEvent) error {
+			calls = append(calls, "action")
+			return nil
+		}))
+	base.OnEnter(OrderStateShipped, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+		calls = append(calls, "enter")
+	})
+
+	tenant := base.Clone()
+	tenant.OnEnter(OrderStateShipped, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+		calls = append(calls, "tenant enter")
+	})
+
+	base.Transition(OrderStateAwaiting, OrderEventShip)
+	if len(calls) != 2 {
+		t.Errorf("original ran %v, want action and its own callback only", calls)
+	}
+
+	calls = nil
+	tenant.Transition(OrderStateAwaiting, OrderEventShip)
+	if len(calls) != 3 {
+		t.Errorf("clone ran %v, want action and both callbacks", calls)
+	}
+}
@@ -0,0 +1,21 @@
+// Command smexhaustive reports state and event constants that are never
+// wired into a state machine, and switches over state types missing cases.
+//
+// Usage:
+//
+//	smexhaustive [-default-signifies-exhaustive] ./...
+//
+// It can also be run through go vet:
+//
+//	go vet -vettool=$(which smexhaustive) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/richardbowden/statemachine/analysis/exhaustive"
+)
+
+func main() {
+	singlechecker.Main(exhaustive.Analyzer)
+}
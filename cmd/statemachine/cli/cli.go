@@ -0,0 +1,236 @@
+// Package cli is the statemachine command, as a package so that a program
+// can register its own machines in a statemachine.Registry and run the
+// commands against them with -machine:
+//
+//	func main() {
+//		var machines statemachine.Registry
+//		statemachine.Register(&machines, "orders", orders.NewStateMachine())
+//		os.Exit(cli.Run(&machines, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+//	}
+//
+// A registered machine is worked with as ToDefinition describes it, so the
+// commands see its states, events and transitions and the names of guards
+// resolved by name, but run none of its code.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/richardbowden/statemachine"
+)
+
+const usage = `usage: statemachine <command> [flags]
+
+commands:
+  repl        explore a definition interactively by firing events
+  validate    check a definition for mistakes
+  visualize   draw a definition as a diagram
+  simulate    run a sequence of events read from standard input
+  generate    write Go types and a constructor for a definition
+
+Each command reads its machine from definition files given with -def, or,
+in a program running the commands with machines of its own, from one named
+with -machine. Run 'statemachine <command> -h' for command flags.
+`
+
+// Main runs the command given by args, the arguments after the program
+// name, and returns the exit code
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	return Run(nil, args, stdin, stdout, stderr)
+}
+
+// Run is like Main, but -machine names a machine registered in machines,
+// which may be nil
+func Run(machines *statemachine.Registry, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "repl":
+		err = runREPL(machines, args[1:], stdin, stdout, stderr)
+	case "validate":
+		err = runValidate(machines, args[1:], stdout, stderr)
+	case "visualize":
+		err = runVisualize(machines, args[1:], stdout, stderr)
+	case "simulate":
+		err = runSimulate(machines, args[1:], stdin, stdout, stderr)
+	case "generate":
+		err = runGenerate(machines, args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "statemachine: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "statemachine: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// name is the state and event type used for machines built from definition
+// files, where there are no Go types to resolve names against
+type name string
+
+func (n name) String() string {
+	return string(n)
+}
+
+// definitionFlag collects repeated -def flags
+type definitionFlag []string
+
+func (d *definitionFlag) String() string {
+	return strings.Join(*d, ",")
+}
+
+func (d *definitionFlag) Set(v string) error {
+	*d = append(*d, v)
+	return nil
+}
+
+// source is where a command finds its machine: the definition files given
+// with -def, or the machine registered in machines under the name given
+// with -machine
+type source struct {
+	machines *statemachine.Registry
+	defs     definitionFlag
+	machine  string
+}
+
+func (s *source) register(fs *flag.FlagSet) {
+	fs.Var(&s.defs, "def", "definition file or glob pattern, may be repeated")
+	fs.StringVar(&s.machine, "machine", "", "name of a registered machine, instead of -def")
+}
+
+// load returns the definition of the machine
+func (s *source) load() (*statemachine.Definition, error) {
+	if s.machine == "" {
+		return loadDefinition(s.defs)
+	}
+	if len(s.defs) > 0 {
+		return nil, errors.New("use -def or -machine, not both")
+	}
+	if s.machines == nil {
+		return nil, fmt.Errorf("no machine %q registered: -machine needs a program that runs the commands with cli.Run", s.machine)
+	}
+	m, ok := s.machines.Machine(s.machine)
+	if !ok {
+		return nil, fmt.Errorf("no machine %q registered, have %v", s.machine, s.machines.Names())
+	}
+	def := m.Definition()
+	if def.Name == "" {
+		def.Name = s.machine
+	}
+	return def, nil
+}
+
+// names describes where the machine was read from, for generated files
+func (s *source) names() []string {
+	if s.machine != "" {
+		return []string{"machine " + s.machine}
+	}
+	return s.defs
+}
+
+// loadDefinition reads and merges the definition files matching patterns,
+// JSON or YAML. Relative patterns are resolved against the working directory
+// so that error messages show the paths as they were given.
+func loadDefinition(patterns []string) (*statemachine.Definition, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no definition given, use -def or -machine")
+	}
+
+	relative := true
+	for _, p := range patterns {
+		if filepath.IsAbs(p) || strings.HasPrefix(filepath.Clean(p), "..") {
+			relative = false
+			break
+		}
+	}
+	if relative {
+		cleaned := make([]string, len(patterns))
+		for i, p := range patterns {
+			cleaned[i] = filepath.ToSlash(filepath.Clean(p))
+		}
+		return statemachine.ParseDefinitionFS(os.DirFS("."), cleaned...)
+	}
+
+	rooted := make([]string, len(patterns))
+	for i, p := range patterns {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		rooted[i] = strings.TrimPrefix(filepath.ToSlash(abs), "/")
+	}
+	return statemachine.ParseDefinitionFS(os.DirFS("/"), rooted...)
+}
+
+// buildMachine creates a machine from a definition using the declared names.
+// Guards are code, so every guard the definition names is taken to pass.
+func buildMachine(def *statemachine.Definition) (*statemachine.StateMachine[name, name], error) {
+	return buildMachineWithGuards(def, func(string) bool { return true })
+}
+
+// buildMachineWithGuards is like buildMachine, but each guard the definition
+// names passes or fails as pass reports for its name
+func buildMachineWithGuards(def *statemachine.Definition, pass func(guard string) bool) (*statemachine.StateMachine[name, name], error) {
+	states := make([]name, len(def.States))
+	for i, s := range def.States {
+		states[i] = name(s.Name)
+	}
+	events := make([]name, len(def.Events))
+	for i, e := range def.Events {
+		events[i] = name(e.Name)
+	}
+	guards := make(map[string]statemachine.Guard[name, name])
+	for _, t := range def.Transitions {
+		for _, g := range t.Guards {
+			guards[g] = func(context.Context, name, name) bool { return pass(g) }
+		}
+	}
+	return statemachine.FromDefinitionWithGuards(def, states, events, guards)
+}
+
+// startState returns the state named by a -start flag, or else the
+// definition's initial state, or else its first declared state
+func startState(def *statemachine.Definition, start string) (name, error) {
+	switch {
+	case start != "":
+		if !declared(def, name(start)) {
+			return "", fmt.Errorf("start state %q is not declared", start)
+		}
+		return name(start), nil
+	case def.Initial != "":
+		return name(def.Initial), nil
+	case len(def.States) > 0:
+		return name(def.States[0].Name), nil
+	default:
+		return "", errors.New("definition declares no states")
+	}
+}
+
+func declared(def *statemachine.Definition, state name) bool {
+	for _, s := range def.States {
+		if s.Name == string(state) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/richardbowden/statemachine"
+)
+
+const orderDefinition = `{
+  "name": "order",
+  "states": [
+    {"name": "Pending"},
+    {"name": "Processing"},
+    {"name": "Shipped"}
+  ],
+  "events": [
+    {"name": "Confirm"},
+    {"name": "Cancel"},
+    {"name": "Ship"}
+  ],
+  "transitions": [
+    {"from": "Pending", "event": "Confirm", "to": "Processing"},
+    {"from": "Pending", "event": "Cancel", "to": "Shipped"},
+    {"from": "Processing", "event": "Ship", "to": "Shipped"}
+  ]
+}`
+
+func writeDefinition(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "order.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefinition_YAMLGlob(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"order.yaml": `name: order
+initial: Pending
+states:
+  - name: Pending
+  - name: Processing
+  - name: Shipped
+    final: true
+events:
+  - name: Confirm
+  - name: Ship
+`,
+		"transitions.yml": `transitions:
+  - {from: Pending, event: Confirm, to: Processing}
+  - {from: Processing, event: Ship, to: Shipped}
+`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"simulate", "-def", filepath.Join(dir, "*.yaml"), "-def", filepath.Join(dir, "*.yml"), "-final"}
+	if code := Main(args, strings.NewReader("Confirm\nShip\n"), &stdout, &stderr); code != 0 {
+		t.Fatalf("Main() exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if want := "ended in Shipped\n"; !strings.HasSuffix(stdout.String(), want) {
+		t.Errorf("stdout = %q, want it to end with %q", stdout.String(), want)
+	}
+}
+
+func TestRun_Machine(t *testing.T) {
+	sm := statemachine.NewStateMachine[name, name]()
+	sm.AddTransition("Pending", "Confirm", "Processing")
+	sm.AddTransition("Processing", "Ship", "Shipped")
+	sm.SetInitialState("Pending")
+	sm.AddFinalState("Shipped")
+	var machines statemachine.Registry
+	if err := statemachine.Register(&machines, "cli_test/order", sm); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"simulate", "-machine", "cli_test/order", "-final"}
+	if code := Run(&machines, args, strings.NewReader("Confirm\nShip\n"), &stdout, &stderr); code != 0 {
+		t.Fatalf("Main() exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if want := "ended in Shipped\n"; !strings.HasSuffix(stdout.String(), want) {
+		t.Errorf("stdout = %q, want it to end with %q", stdout.String(), want)
+	}
+
+	stderr.Reset()
+	if code := Run(&machines, []string{"validate", "-machine", "cli_test/invoice"}, strings.NewReader(""), &stdout, &stderr); code != 1 {
+		t.Errorf("Run() of an unregistered machine exit code = %d, want 1", code)
+	}
+	if want := `no machine "cli_test/invoice" registered, have [cli_test/order]`; !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr = %q, want it to contain %q", stderr.String(), want)
+	}
+
+	stderr.Reset()
+	if code := Main([]string{"validate", "-machine", "cli_test/order"}, strings.NewReader(""), &stdout, &stderr); code != 1 {
+		t.Errorf("Main() with -machine exit code = %d, want 1", code)
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	if code := Main(nil, strings.NewReader(""), &stdout, &stderr); code != 2 {
+		t.Errorf("Main() exit code = %d, want 2", code)
+	}
+	if code := Main([]string{"bogus"}, strings.NewReader(""), &stdout, &stderr); code != 2 {
+		t.Errorf("Main(bogus) exit code = %d, want 2", code)
+	}
+}
+
+func TestREPL_Session(t *testing.T) {
+	path := writeDefinition(t, orderDefinition)
+	stdin := strings.NewReader("Ship\nConfirm\nfire Ship\ntrace\nreset\nquit\n")
+	var stdout, stderr bytes.Buffer
+
+	if code := Main([]string{"repl", "-def", path}, stdin, &stdout, &stderr); code != 0 {
+		t.Fatalf("Main() exit code = %d, stderr = %s", code, stderr.String())
+	}
+
+	out := stdout.String()
+	for _, want := range []string{
+		"machine order",
+		"current state: Pending",
+		"valid events: Cancel -> Shipped, Confirm -> Processing",
+		"rejected: invalid transition: cannot process event 'Ship' from state 'Pending'",
+		"1. Pending --Confirm--> Processing",
+		"   exit  Pending\n   enter Processing",
+		"2. Processing --Ship--> Shipped",
+		"no events are valid, this state is terminal",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("repl output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestREPL_Guards(t *testing.T) {
+	path := writeDefinition(t, strings.Replace(orderDefinition, `{"from": "Processing", "event": "Ship", "to": "Shipped"}`,
+		`{"from": "Processing", "event": "Ship", "to": "Shipped", "guards": ["paid"]}`, 1))
+	stdin := strings.NewReader("Confirm\nfail paid\nShip\npass paid\nShip\nfail unknown\nquit\n")
+	var stdout, stderr bytes.Buffer
+
+	if code := Main([]string{"repl", "-def", path}, stdin, &stdout, &stderr); code != 0 {
+		t.Fatalf("Main() exit code = %d, stderr = %s", code, stderr.String())
+	}
+
+	out := stdout.String()
+	for _, want := range []string{
+		"guards: paid fails\n",
+		"rejected: ",
+		"   guard paid failed (to Shipped)\n",
+		"guards: paid passes\n",
+		"2. Processing --Ship--> Shipped\n   guard paid passed (to Shipped)\n   exit  Processing\n   enter Shipped\n",
+		`unknown guard "unknown"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("repl output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestREPL_StartState(t *testing.T) {
+	path := writeDefinition(t, orderDefinition)
+	var stdout, stderr bytes.Buffer
+
+	code := Main([]string{"repl", "-def", path, "-start", "Processing"}, strings.NewReader("quit\n"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Main() exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "valid events: Ship -> Shipped") {
+		t.Errorf("repl output = %s, want to start in Processing", stdout.String())
+	}
+
+	stderr.Reset()
+	code = Main([]string{"repl", "-def", path, "-start", "Nowhere"}, strings.NewReader(""), &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), `start state "Nowhere" is not declared`) {
+		t.Errorf("Main() exit code = %d, stderr = %s", code, stderr.String())
+	}
+}
+
+func TestREPL_InitialAndFinalStates(t *testing.T) {
+	def := strings.Replace(orderDefinition, `"name": "order",`, `"name": "order",
+  "initial": "Processing",`, 1)
+	def = strings.Replace(def, `{"name": "Shipped"}`, `{"name": "Shipped", "final": true}`, 1)
+	path := writeDefinition(t, def)
+	var stdout, stderr bytes.Buffer
+
+	code := Main([]string{"repl", "-def", path}, strings.NewReader("Ship\nquit\n"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Main() exit code = %d, stderr = %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "current state: Processing") {
+		t.Errorf("repl output = %s, want to start in the initial state", out)
+	}
+	if !strings.Contains(out, "current state: Shipped\nthis state is final") {
+		t.Errorf("repl output = %s, want Shipped reported final", out)
+	}
+}
+
+func TestREPL_InvalidDefinition(t *testing.T) {
+	path := writeDefinition(t, "{\n  \"transitions\": [\n    {\"from\": \"A\", \"event\": \"B\", \"to\": \"C\"}\n  ]\n}")
+	var stdout, stderr bytes.Buffer
+
+	if code := Main([]string{"repl", "-def", path}, strings.NewReader(""), &stdout, &stderr); code != 1 {
+		t.Fatalf("Main() exit code = %d, want 1", code)
+	}
+	if !strings.Contains(stderr.String(), "order.json:3: transition from undeclared state") {
+		t.Errorf("stderr = %s, want file and line context", stderr.String())
+	}
+}
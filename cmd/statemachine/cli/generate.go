@@ -0,0 +1,293 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/richardbowden/statemachine"
+)
+
+func runGenerate(machines *statemachine.Registry, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: statemachine generate (-def <file> [-def <file>...] | -machine <name>) [-type <name>] [-package <name>] [-o <file>]")
+		fs.PrintDefaults()
+	}
+	input := source{machines: machines}
+	input.register(fs)
+	typeName := fs.String("type", "", "prefix for the generated names (default: the definition's name)")
+	pkg := fs.String("package", os.Getenv("GOPACKAGE"), "package of the generated file (default: $GOPACKAGE, set by go generate)")
+	out := fs.String("o", "", "file to write (default: standard output)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	def, err := input.load()
+	if err != nil {
+		return err
+	}
+	g := generator{def: def, pkg: *pkg, prefix: identifier(*typeName), sources: input.names()}
+	if g.prefix == "" {
+		g.prefix = identifier(def.Name)
+	}
+	src, err := g.generate()
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
+
+// generator writes the Go source for a definition: typed state and event
+// constants, their String methods and a constructor for the machine
+type generator struct {
+	def     *statemachine.Definition
+	pkg     string
+	prefix  string
+	sources []string
+
+	buf bytes.Buffer
+}
+
+func (g *generator) generate() ([]byte, error) {
+	if g.pkg == "" {
+		return nil, errors.New("no package given, use -package or run from go generate")
+	}
+	if g.prefix == "" {
+		return nil, errors.New("definition has no name, use -type")
+	}
+
+	stateType, eventType := g.prefix+"State", g.prefix+"Event"
+	states, err := constNames(stateType, g.def.States, func(s statemachine.StateDefinition) string { return s.Name })
+	if err != nil {
+		return nil, err
+	}
+	events, err := constNames(eventType, g.def.Events, func(e statemachine.EventDefinition) string { return e.Name })
+	if err != nil {
+		return nil, err
+	}
+	guards, guardFields, err := g.guards()
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]string, len(g.sources))
+	for i, s := range g.sources {
+		sources[i] = filepath.ToSlash(s)
+	}
+	g.printf("// Code generated by statemachine generate from %s. DO NOT EDIT.\n\n", strings.Join(sources, ", "))
+	g.printf("package %s\n\n", g.pkg)
+	g.printf("import \"github.com/richardbowden/statemachine\"\n\n")
+
+	g.printf("// %s is a state of the %s state machine\n", stateType, g.machineName())
+	g.printf("type %s string\n\nconst (\n", stateType)
+	for _, s := range g.def.States {
+		g.comment(s.Description)
+		g.printf("%s %s = %q\n", states[s.Name], stateType, s.Name)
+	}
+	g.printf(")\n\nfunc (s %s) String() string {\nreturn string(s)\n}\n\n", stateType)
+
+	g.printf("// %s is an event of the %s state machine\n", eventType, g.machineName())
+	g.printf("type %s string\n\nconst (\n", eventType)
+	for _, e := range g.def.Events {
+		g.comment(e.Description)
+		g.printf("%s %s = %q\n", events[e.Name], eventType, e.Name)
+	}
+	g.printf(")\n\nfunc (e %s) String() string {\nreturn string(e)\n}\n\n", eventType)
+
+	g.printf("// All%sStates lists every state in the order it is declared\n", g.prefix)
+	g.printf("var All%sStates = []%s{\n", g.prefix, stateType)
+	for _, s := range g.def.States {
+		g.printf("%s,\n", states[s.Name])
+	}
+	g.printf("}\n\n")
+	g.printf("// All%sEvents lists every event in the order it is declared\n", g.prefix)
+	g.printf("var All%sEvents = []%s{\n", g.prefix, eventType)
+	for _, e := range g.def.Events {
+		g.printf("%s,\n", events[e.Name])
+	}
+	g.printf("}\n\n")
+
+	if len(guards) > 0 {
+		g.printf("// %sGuards supplies the guards named by the definition; every field must be set\n", g.prefix)
+		g.printf("type %sGuards struct {\n", g.prefix)
+		for _, name := range guards {
+			g.printf("%s statemachine.Guard[%s, %s]\n", guardFields[name], stateType, eventType)
+		}
+		g.printf("}\n\n")
+	}
+
+	g.printf("// New%sStateMachine creates the %s state machine\n", g.prefix, g.machineName())
+	if len(guards) > 0 {
+		g.printf("func New%sStateMachine(guards %sGuards, opts ...statemachine.Option) *statemachine.StateMachine[%s, %s] {\n", g.prefix, g.prefix, stateType, eventType)
+		for _, name := range guards {
+			g.printf("if guards.%s == nil {\npanic(%q)\n}\n", guardFields[name], "statemachine: guard "+name+" is not set")
+		}
+	} else {
+		g.printf("func New%sStateMachine(opts ...statemachine.Option) *statemachine.StateMachine[%s, %s] {\n", g.prefix, stateType, eventType)
+	}
+	g.printf("sm := statemachine.NewStateMachine[%s, %s](opts...)\n", stateType, eventType)
+	if g.def.Version != 0 {
+		g.printf("sm.SetVersion(%d)\n", g.def.Version)
+	}
+	g.printf("sm.DeclareStates(All%sStates...)\n", g.prefix)
+	g.printf("sm.DeclareEvents(All%sEvents...)\n\n", g.prefix)
+
+	for _, t := range g.def.Transitions {
+		g.printf("sm.AddTransition(%s, %s, %s", states[t.From], events[t.Event], states[t.To])
+		if m := metaLiteral(t.Label, t.Description, t.Tags); m != "" {
+			g.printf(",\nstatemachine.WithMeta[%s, %s](%s)", stateType, eventType, m)
+		}
+		for _, name := range t.Guards {
+			g.printf(",\nstatemachine.WithGuard(guards.%s)", guardFields[name])
+		}
+		g.printf(")\n")
+	}
+
+	if g.def.Initial != "" {
+		g.printf("\nsm.SetInitialState(%s)\n", states[g.def.Initial])
+	}
+	for _, s := range g.def.States {
+		if s.Final {
+			g.printf("sm.AddFinalState(%s)\n", states[s.Name])
+		}
+	}
+	for _, s := range g.def.States {
+		if m := metaLiteral(s.Label, s.Description, s.Tags); m != "" {
+			g.printf("sm.SetStateMeta(%s, %s)\n", states[s.Name], m)
+		}
+	}
+	g.printf("return sm\n}\n")
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid Go source: %w", err)
+	}
+	return src, nil
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// comment writes a description as a comment on the declaration that follows
+func (g *generator) comment(text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			g.printf("// %s\n", line)
+		}
+	}
+}
+
+func (g *generator) machineName() string {
+	if g.def.Name != "" {
+		return g.def.Name
+	}
+	return g.prefix
+}
+
+// guards returns the guard names used by the definition's transitions in the
+// order they first appear, with the field each is supplied by
+func (g *generator) guards() ([]string, map[string]string, error) {
+	var names []string
+	fields := make(map[string]string)
+	used := make(map[string]string)
+	for _, t := range g.def.Transitions {
+		for _, name := range t.Guards {
+			if _, ok := fields[name]; ok {
+				continue
+			}
+			field := identifier(name)
+			if field == "" {
+				return nil, nil, fmt.Errorf("guard %q has no letters to name a field after", name)
+			}
+			if other, ok := used[field]; ok {
+				return nil, nil, fmt.Errorf("guards %q and %q would both be named %s", other, name, field)
+			}
+			names = append(names, name)
+			fields[name] = field
+			used[field] = name
+		}
+	}
+	return names, fields, nil
+}
+
+// constNames returns the constant declared for each state or event, named
+// after it with the type as a prefix
+func constNames[T any](typeName string, items []T, nameOf func(T) string) (map[string]string, error) {
+	names := make(map[string]string, len(items))
+	used := make(map[string]string, len(items))
+	for _, item := range items {
+		name := nameOf(item)
+		id := identifier(name)
+		if id == "" {
+			return nil, fmt.Errorf("%q has no letters to name a constant after", name)
+		}
+		id = typeName + id
+		if other, ok := used[id]; ok {
+			return nil, fmt.Errorf("%q and %q would both be named %s", other, name, id)
+		}
+		names[name] = id
+		used[id] = name
+	}
+	return names, nil
+}
+
+// identifier turns a name such as "awaiting-courier" into an exported Go
+// identifier such as "AwaitingCourier", dropping characters that cannot
+// appear in one
+func identifier(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteByte('X')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// metaLiteral returns a Metadata composite literal for the label,
+// description and tags of a state or transition, or "" if none are set
+func metaLiteral(label, description string, tags []string) string {
+	var fields []string
+	if label != "" {
+		fields = append(fields, fmt.Sprintf("Label: %q", label))
+	}
+	if description != "" {
+		fields = append(fields, fmt.Sprintf("Description: %q", description))
+	}
+	if len(tags) > 0 {
+		quoted := make([]string, len(tags))
+		for i, tag := range tags {
+			quoted[i] = fmt.Sprintf("%q", tag)
+		}
+		fields = append(fields, fmt.Sprintf("Tags: []string{%s}", strings.Join(quoted, ", ")))
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return "statemachine.Metadata{" + strings.Join(fields, ", ") + "}"
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerate_Example checks the generated ticket machine in the example
+// package is up to date with its definition
+func TestGenerate_Example(t *testing.T) {
+	dir := filepath.Join("..", "..", "..", "example")
+	want, err := os.ReadFile(filepath.Join(dir, "ticket_statemachine.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Chdir(dir)
+	var stdout, stderr bytes.Buffer
+	code := Main([]string{"generate", "-def", "ticket.yaml", "-package", "example"}, strings.NewReader(""), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Main() exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if stdout.String() != string(want) {
+		t.Errorf("example/ticket_statemachine.go is out of date, run go generate ./example\ngot:\n%s", stdout.String())
+	}
+}
+
+func TestGenerate_Output(t *testing.T) {
+	def := strings.Replace(orderDefinition, `{"name": "Shipped"}`, `{"name": "Shipped", "final": true}`, 1)
+	path := writeDefinition(t, def)
+	out := filepath.Join(t.TempDir(), "order_statemachine.go")
+	var stdout, stderr bytes.Buffer
+
+	code := Main([]string{"generate", "-def", path, "-package", "orders", "-o", out}, strings.NewReader(""), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Main() exit code = %d, stderr = %s", code, stderr.String())
+	}
+	src, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"// Code generated by statemachine generate from ",
+		"package orders\n",
+		"type OrderState string",
+		`OrderStatePending    OrderState = "Pending"`,
+		"func (e OrderEvent) String() string",
+		"var AllOrderEvents = []OrderEvent{",
+		"func NewOrderStateMachine(opts ...statemachine.Option) *statemachine.StateMachine[OrderState, OrderEvent] {",
+		"sm.AddTransition(OrderStatePending, OrderEventCancel, OrderStateShipped)",
+		"sm.AddFinalState(OrderStateShipped)",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source missing %q\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		args []string
+		want string
+	}{
+		{
+			name: "no package",
+			def:  orderDefinition,
+			want: "no package given",
+		},
+		{
+			name: "no name",
+			def:  strings.Replace(orderDefinition, `"name": "order",`, "", 1),
+			args: []string{"-package", "orders"},
+			want: "definition has no name, use -type",
+		},
+		{
+			name: "clashing constants",
+			def:  strings.Replace(orderDefinition, `{"name": "Shipped"}`, `{"name": "Shipped"}, {"name": "shipped"}`, 1),
+			args: []string{"-package", "orders"},
+			want: `"Shipped" and "shipped" would both be named OrderStateShipped`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GOPACKAGE", "")
+			path := writeDefinition(t, tt.def)
+			var stdout, stderr bytes.Buffer
+
+			args := append([]string{"generate", "-def", path}, tt.args...)
+			if code := Main(args, strings.NewReader(""), &stdout, &stderr); code != 1 {
+				t.Fatalf("Main() exit code = %d, want 1", code)
+			}
+			if !strings.Contains(stderr.String(), tt.want) {
+				t.Errorf("stderr = %s, want %q", stderr.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Pending", "Pending"},
+		{"awaiting-courier", "AwaitingCourier"},
+		{"in progress", "InProgress"},
+		{"2fa_required", "X2faRequired"},
+		{"--", ""},
+	}
+
+	for _, tt := range tests {
+		if got := identifier(tt.name); got != tt.want {
+			t.Errorf("identifier(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/richardbowden/statemachine"
+)
+
+const replHelp = `commands:
+  <event>          fire an event from the current state
+  fire <event>     same as above
+  events           list the events valid from the current state
+  state            show the current state
+  goto <state>     jump to a state without firing an event
+  guards           list the guards and whether they pass
+  fail <guard>     make a guard fail
+  pass <guard>     make a guard pass again
+  reset            return to the start state and clear the trace
+  trace            print every step taken so far
+  help             show this help
+  quit             leave the repl
+`
+
+// replStep is one fired event in the session trace
+type replStep = statemachine.TraceStep[name, name]
+
+type repl struct {
+	def     *statemachine.Definition
+	sm      *statemachine.StateMachine[name, name]
+	start   name
+	current name
+	trace   []replStep
+	out     io.Writer
+
+	// failing holds the guards made to fail; every other guard passes
+	failing map[string]bool
+}
+
+func runREPL(machines *statemachine.Registry, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("repl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: statemachine repl (-def <file> [-def <file>...] | -machine <name>) [-start <state>]")
+		fs.PrintDefaults()
+	}
+	input := source{machines: machines}
+	input.register(fs)
+	start := fs.String("start", "", "state to start in (default: the initial state, or the first declared state)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	def, err := input.load()
+	if err != nil {
+		return err
+	}
+	r := &repl{def: def, out: stdout, failing: make(map[string]bool)}
+	r.sm, err = buildMachineWithGuards(def, func(guard string) bool { return !r.failing[guard] })
+	if err != nil {
+		return err
+	}
+	if r.start, err = startState(def, *start); err != nil {
+		return err
+	}
+	r.current = r.start
+
+	return r.run(stdin)
+}
+
+func (r *repl) run(in io.Reader) error {
+	if r.def.Name != "" {
+		fmt.Fprintf(r.out, "machine %s\n", r.def.Name)
+	}
+	r.printStatus()
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch cmd, arg := fields[0], strings.Join(fields[1:], " "); cmd {
+		case "quit", "exit":
+			return nil
+		case "help":
+			fmt.Fprint(r.out, replHelp)
+		case "state":
+			fmt.Fprintf(r.out, "current state: %s\n", r.current)
+		case "events":
+			r.printEvents()
+		case "fire":
+			r.fire(name(arg))
+		case "goto":
+			r.jump(name(arg))
+		case "guards":
+			r.printGuards()
+		case "fail":
+			r.setGuard(arg, false)
+		case "pass":
+			r.setGuard(arg, true)
+		case "reset":
+			r.current = r.start
+			r.trace = nil
+			r.printStatus()
+		case "trace":
+			r.printTrace()
+		default:
+			r.fire(name(strings.Join(fields, " ")))
+		}
+	}
+}
+
+func (r *repl) fire(event name) {
+	if event == "" {
+		fmt.Fprintln(r.out, "fire needs an event")
+		return
+	}
+
+	trace, err := r.sm.Simulate(r.current, []name{event})
+	if err != nil {
+		// report the transition error, not Simulate's path position
+		fmt.Fprintf(r.out, "rejected: %v\n", errors.Unwrap(err))
+		r.printCandidates(trace[0])
+		r.printEvents()
+		return
+	}
+
+	step := trace[0]
+	r.trace = append(r.trace, step)
+	r.current = step.To
+
+	r.printStep(len(r.trace), step)
+	r.printStatus()
+}
+
+// setGuard makes a guard the definition names pass or fail
+func (r *repl) setGuard(guard string, pass bool) {
+	if !slices.Contains(r.guards(), guard) {
+		fmt.Fprintf(r.out, "unknown guard %q\n", guard)
+		return
+	}
+	r.failing[guard] = !pass
+	r.printGuards()
+}
+
+// guards returns the names of the guards the definition names, sorted
+func (r *repl) guards() []string {
+	var guards []string
+	for _, t := range r.def.Transitions {
+		guards = append(guards, t.Guards...)
+	}
+	slices.Sort(guards)
+	return slices.Compact(guards)
+}
+
+func (r *repl) printGuards() {
+	guards := r.guards()
+	if len(guards) == 0 {
+		fmt.Fprintln(r.out, "guards: none")
+		return
+	}
+	parts := make([]string, len(guards))
+	for i, g := range guards {
+		parts[i] = g + " passes"
+		if r.failing[g] {
+			parts[i] = g + " fails"
+		}
+	}
+	fmt.Fprintf(r.out, "guards: %s\n", strings.Join(parts, ", "))
+}
+
+func (r *repl) jump(state name) {
+	if !declared(r.def, state) {
+		fmt.Fprintf(r.out, "unknown state %q\n", state)
+		return
+	}
+	r.current = state
+	r.printStatus()
+}
+
+func (r *repl) validEvents() []name {
+	events := r.sm.GetValidEvents(r.current)
+	sort.Slice(events, func(i, j int) bool { return events[i] < events[j] })
+	return events
+}
+
+func (r *repl) printStatus() {
+	fmt.Fprintf(r.out, "current state: %s\n", r.current)
+	if r.sm.IsFinalState(r.current) {
+		fmt.Fprintln(r.out, "this state is final")
+	}
+	if r.sm.IsTerminalState(r.current) {
+		fmt.Fprintln(r.out, "no events are valid, this state is terminal")
+		return
+	}
+	r.printEvents()
+}
+
+func (r *repl) printEvents() {
+	events := r.validEvents()
+	if len(events) == 0 {
+		fmt.Fprintln(r.out, "valid events: none")
+		return
+	}
+	parts := make([]string, len(events))
+	for i, e := range events {
+		to, _ := r.sm.GetNextState(r.current, e)
+		parts[i] = fmt.Sprintf("%s -> %s", e, to)
+	}
+	fmt.Fprintf(r.out, "valid events: %s\n", strings.Join(parts, ", "))
+}
+
+// printStep describes one transition: the guards evaluated to choose it and
+// the states exited and entered, including the composite states around them
+func (r *repl) printStep(n int, step replStep) {
+	fmt.Fprintf(r.out, "%d. %s --%s--> %s\n", n, step.From, step.Event, step.To)
+	r.printCandidates(step)
+	for _, s := range step.Exited {
+		fmt.Fprintf(r.out, "   exit  %s\n", s)
+	}
+	for _, s := range step.Entered {
+		fmt.Fprintf(r.out, "   enter %s\n", s)
+	}
+}
+
+// printCandidates lists the guards evaluated for each transition tried
+func (r *repl) printCandidates(step replStep) {
+	for _, c := range step.Candidates {
+		for i, passed := range c.Guards {
+			result := "failed"
+			if passed {
+				result = "passed"
+			}
+			fmt.Fprintf(r.out, "   guard %s %s (to %s)\n", c.GuardNames[i], result, joinNames(c.Targets))
+		}
+	}
+}
+
+func joinNames(names []name) string {
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = string(n)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r *repl) printTrace() {
+	if len(r.trace) == 0 {
+		fmt.Fprintf(r.out, "no events fired, still in %s\n", r.start)
+		return
+	}
+	for i, step := range r.trace {
+		r.printStep(i+1, step)
+	}
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/richardbowden/statemachine"
+)
+
+func runSimulate(machines *statemachine.Registry, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: statemachine simulate (-def <file> [-def <file>...] | -machine <name>) [-start <state>] < events")
+		fs.PrintDefaults()
+	}
+	input := source{machines: machines}
+	input.register(fs)
+	start := fs.String("start", "", "state to start in (default: the initial state, or the first declared state)")
+	final := fs.Bool("final", false, "fail unless the events end in a final state")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	def, err := input.load()
+	if err != nil {
+		return err
+	}
+	sm, err := buildMachine(def)
+	if err != nil {
+		return err
+	}
+	from, err := startState(def, *start)
+	if err != nil {
+		return err
+	}
+	events, err := readEvents(stdin)
+	if err != nil {
+		return err
+	}
+
+	trace, err := sm.Simulate(from, events)
+	for i, step := range trace {
+		if i == len(trace)-1 && err != nil {
+			fmt.Fprintf(stdout, "%d. %s --%s--> rejected\n", i+1, step.From, step.Event)
+			break
+		}
+		fmt.Fprintf(stdout, "%d. %s --%s--> %s\n", i+1, step.From, step.Event, step.To)
+	}
+	if err != nil {
+		return err
+	}
+
+	end := from
+	if len(trace) > 0 {
+		end = trace[len(trace)-1].To
+	}
+	fmt.Fprintf(stdout, "ended in %s\n", end)
+	if *final && !sm.IsFinalState(end) {
+		return fmt.Errorf("state %q is not final", end)
+	}
+	return nil
+}
+
+// readEvents reads event names, one per line. Blank lines and lines starting
+// with # are skipped.
+func readEvents(r io.Reader) ([]name, error) {
+	var events []name
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		events = append(events, name(line))
+	}
+	return events, scanner.Err()
+}
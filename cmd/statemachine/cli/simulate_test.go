@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	guarded := strings.Replace(orderDefinition, `{"from": "Processing", "event": "Ship", "to": "Shipped"}`,
+		`{"from": "Processing", "event": "Ship", "to": "Shipped", "guards": ["paid"]}`, 1)
+	withFinal := strings.Replace(orderDefinition, `{"name": "Shipped"}`, `{"name": "Shipped", "final": true}`, 1)
+
+	tests := []struct {
+		name      string
+		def       string
+		args      []string
+		stdin     string
+		wantCode  int
+		wantOut   string
+		wantError string
+	}{
+		{
+			name:    "valid path",
+			def:     orderDefinition,
+			stdin:   "# happy path\nConfirm\n\nShip\n",
+			wantOut: "1. Pending --Confirm--> Processing\n2. Processing --Ship--> Shipped\nended in Shipped\n",
+		},
+		{
+			name:    "guards pass",
+			def:     guarded,
+			stdin:   "Confirm\nShip\n",
+			wantOut: "1. Pending --Confirm--> Processing\n2. Processing --Ship--> Shipped\nended in Shipped\n",
+		},
+		{
+			name:      "rejected event",
+			def:       orderDefinition,
+			stdin:     "Confirm\nConfirm\n",
+			wantCode:  1,
+			wantOut:   "1. Pending --Confirm--> Processing\n2. Processing --Confirm--> rejected\n",
+			wantError: "invalid path at step 2",
+		},
+		{
+			name:      "not final",
+			def:       withFinal,
+			args:      []string{"-final"},
+			stdin:     "Confirm\n",
+			wantCode:  1,
+			wantOut:   "1. Pending --Confirm--> Processing\nended in Processing\n",
+			wantError: `state "Processing" is not final`,
+		},
+		{
+			name:    "start state",
+			def:     orderDefinition,
+			args:    []string{"-start", "Processing"},
+			stdin:   "Ship\n",
+			wantOut: "1. Processing --Ship--> Shipped\nended in Shipped\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeDefinition(t, tt.def)
+			var stdout, stderr bytes.Buffer
+
+			args := append([]string{"simulate", "-def", path}, tt.args...)
+			if code := Main(args, strings.NewReader(tt.stdin), &stdout, &stderr); code != tt.wantCode {
+				t.Fatalf("Main() exit code = %d, want %d, stderr = %s", code, tt.wantCode, stderr.String())
+			}
+			if stdout.String() != tt.wantOut {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantOut)
+			}
+			if !strings.Contains(stderr.String(), tt.wantError) {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/richardbowden/statemachine"
+)
+
+func runValidate(machines *statemachine.Registry, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: statemachine validate (-def <file> [-def <file>...] | -machine <name>) [-strict]")
+		fs.PrintDefaults()
+	}
+	input := source{machines: machines}
+	input.register(fs)
+	strict := fs.Bool("strict", false, "also fail on trap states and unused events")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	def, err := input.load()
+	if err != nil {
+		return err
+	}
+	sm, err := buildMachine(def)
+	if err != nil {
+		return err
+	}
+
+	// unreachable and unused states are what Validate rejects; the rest of
+	// the analysis only fails the check when asked to
+	report := sm.Analyze()
+	errs, warnings := len(report.Unreachable)+len(report.Unused), len(report.Traps)+len(report.UnusedEvents)
+	if *strict {
+		errs, warnings = errs+warnings, 0
+	}
+	fmt.Fprint(stdout, report.String())
+
+	switch {
+	case errs > 0:
+		return errors.New("definition is invalid")
+	case warnings > 0:
+		fmt.Fprintf(stdout, "ok, with %d warnings\n", warnings)
+	default:
+		fmt.Fprintln(stdout, "ok")
+	}
+	return nil
+}
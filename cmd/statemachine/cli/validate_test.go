@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	unreachable := strings.Replace(orderDefinition, `{"name": "Shipped"}`, `{"name": "Shipped"}, {"name": "Lost"}`, 1)
+	unreachable = strings.Replace(unreachable, `{"from": "Processing", "event": "Ship", "to": "Shipped"}`,
+		`{"from": "Processing", "event": "Ship", "to": "Shipped"},
+    {"from": "Lost", "event": "Ship", "to": "Shipped"}`, 1)
+	withFinal := strings.Replace(orderDefinition, `{"name": "Shipped"}`, `{"name": "Shipped", "final": true}`, 1)
+	withFinal = strings.Replace(withFinal, `{"name": "Ship"}`, `{"name": "Ship"}, {"name": "Return"}`, 1)
+
+	tests := []struct {
+		name     string
+		def      string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{
+			name:    "valid",
+			def:     orderDefinition,
+			wantOut: "ok\n",
+		},
+		{
+			name:     "unreachable state",
+			def:      strings.Replace(unreachable, `"name": "order",`, `"name": "order", "initial": "Pending",`, 1),
+			wantCode: 1,
+			wantOut:  "unreachable state Lost\n",
+		},
+		{
+			name:    "warnings",
+			def:     withFinal,
+			wantOut: "unused event Return\nok, with 1 warnings\n",
+		},
+		{
+			name:     "warnings when strict",
+			def:      withFinal,
+			args:     []string{"-strict"},
+			wantCode: 1,
+			wantOut:  "unused event Return\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeDefinition(t, tt.def)
+			var stdout, stderr bytes.Buffer
+
+			args := append([]string{"validate", "-def", path}, tt.args...)
+			if code := Main(args, strings.NewReader(""), &stdout, &stderr); code != tt.wantCode {
+				t.Fatalf("Main() exit code = %d, want %d, stderr = %s", code, tt.wantCode, stderr.String())
+			}
+			if stdout.String() != tt.wantOut {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantOut)
+			}
+			if tt.wantCode != 0 && !strings.Contains(stderr.String(), "definition is invalid") {
+				t.Errorf("stderr = %q, want the definition reported invalid", stderr.String())
+			}
+		})
+	}
+}
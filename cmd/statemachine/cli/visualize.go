@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/richardbowden/statemachine"
+)
+
+func runVisualize(machines *statemachine.Registry, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("visualize", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: statemachine visualize (-def <file> [-def <file>...] | -machine <name>) [-format dot|mermaid|plantuml|html|markdown|scxml|json] [-o <file>]")
+		fs.PrintDefaults()
+	}
+	input := source{machines: machines}
+	input.register(fs)
+	format := fs.String("format", "dot", "output format: dot, mermaid, plantuml, html, markdown, scxml or json")
+	out := fs.String("o", "", "file to write (default: standard output)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	def, err := input.load()
+	if err != nil {
+		return err
+	}
+	sm, err := buildMachine(def)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch *format {
+	case "dot":
+		err = sm.ExportDOT(&buf, statemachine.DOTOptions{Name: def.Name})
+	case "mermaid":
+		err = sm.ExportMermaid(&buf)
+	case "plantuml":
+		err = sm.ExportPlantUML(&buf)
+	case "html":
+		err = sm.ExportHTML(&buf, statemachine.HTMLOptions{Title: def.Name})
+	case "markdown":
+		err = sm.ExportMarkdownTable(&buf)
+	case "scxml":
+		err = sm.ExportSCXML(&buf)
+	case "json":
+		err = sm.ExportJSON(&buf)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(*out, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVisualize(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"dot", `digraph "order" {`},
+		{"mermaid", "stateDiagram-v2"},
+		{"plantuml", "@startuml"},
+		{"html", "<title>order</title>"},
+		{"markdown", "| Pending | Confirm | Processing |  |  |"},
+		{"scxml", `<state id="Pending">`},
+		{"json", `"from": "Pending",`},
+	}
+
+	path := writeDefinition(t, orderDefinition)
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := Main([]string{"visualize", "-def", path, "-format", tt.format}, strings.NewReader(""), &stdout, &stderr)
+			if code != 0 {
+				t.Fatalf("Main() exit code = %d, stderr = %s", code, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.want) {
+				t.Errorf("output missing %q\n%s", tt.want, stdout.String())
+			}
+		})
+	}
+}
+
+func TestVisualize_OutputFile(t *testing.T) {
+	path := writeDefinition(t, orderDefinition)
+	out := filepath.Join(t.TempDir(), "order.mmd")
+	var stdout, stderr bytes.Buffer
+
+	code := Main([]string{"visualize", "-def", path, "-format", "mermaid", "-o", out}, strings.NewReader(""), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Main() exit code = %d, stderr = %s", code, stderr.String())
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Pending --> Processing: Confirm") {
+		t.Errorf("%s = %s, want the mermaid diagram", out, data)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want nothing when writing a file", stdout.String())
+	}
+
+	code = Main([]string{"visualize", "-def", path, "-format", "svg"}, strings.NewReader(""), &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), `unknown format "svg"`) {
+		t.Errorf("Main(-format svg) exit code = %d, stderr = %s", code, stderr.String())
+	}
+}
@@ -0,0 +1,27 @@
+// Command statemachine works with state machine definition files.
+//
+// Usage:
+//
+//	statemachine <command> [flags]
+//
+// The commands are:
+//
+//	repl        explore a definition interactively by firing events
+//	validate    check a definition for mistakes
+//	visualize   draw a definition as a diagram
+//	simulate    run a sequence of events read from standard input
+//	generate    write Go types and a constructor for a definition
+//
+// The commands live in package cli, which a program can run with machines
+// of its own registered; see there.
+package main
+
+import (
+	"os"
+
+	"github.com/richardbowden/statemachine/cmd/statemachine/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
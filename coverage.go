@@ -0,0 +1,115 @@
+package statemachine
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// WithCoverage makes the machine record which of its transitions have been
+// taken, for CoverageReport. It is meant for tests, to check that every
+// transition is driven by at least one.
+func WithCoverage() Option {
+	return func(c *config) {
+		c.coverage = true
+	}
+}
+
+// coverage records the transitions taken on a machine created WithCoverage.
+// It has its own lock, so that transitions are recorded even once frozen.
+type coverage[S State, E Event] struct {
+	mu    sync.Mutex
+	taken map[*edge[S, E]]bool
+}
+
+// record notes that e was taken. It does nothing on a nil coverage.
+func (c *coverage[S, E]) record(e *edge[S, E]) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.taken[e] = true
+}
+
+// CoverageReport lists which of a machine's transitions have been taken.
+// Each list is sorted by source state and event name. Where several
+// transitions compete for a state and event, each is listed separately; a
+// choice is listed with its first target.
+type CoverageReport[S State, E Event] struct {
+	Covered   []Transition[S, E]
+	Uncovered []Transition[S, E]
+}
+
+// Complete reports whether every transition has been taken
+func (r CoverageReport[S, E]) Complete() bool {
+	return len(r.Uncovered) == 0
+}
+
+// Percent returns the share of transitions taken, from 0 to 100. A machine
+// with no transitions is fully covered.
+func (r CoverageReport[S, E]) Percent() float64 {
+	total := len(r.Covered) + len(r.Uncovered)
+	if total == 0 {
+		return 100
+	}
+	return float64(len(r.Covered)) * 100 / float64(total)
+}
+
+// String lists the transitions not yet taken, one per line
+func (r CoverageReport[S, E]) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d transitions covered (%.1f%%)\n", len(r.Covered), len(r.Covered)+len(r.Uncovered), r.Percent())
+	for _, t := range r.Uncovered {
+		fmt.Fprintf(&b, "not covered: %s --%s--> %s\n", Name(t.From), Name(t.Event), Name(t.To))
+	}
+	return b.String()
+}
+
+// CoverageReport returns the transitions taken so far on a machine created
+// WithCoverage, and those that have not been. Run it after a test suite to
+// fail the build when a transition has no test:
+//
+//	var orderMachine = NewOrderStateMachine(statemachine.WithCoverage())
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		if report := orderMachine.CoverageReport(); !report.Complete() {
+//			fmt.Print(report)
+//			code = 1
+//		}
+//		os.Exit(code)
+//	}
+//
+// A machine created without WithCoverage reports every transition as not
+// covered.
+func (sm *StateMachine[S, E]) CoverageReport() CoverageReport[S, E] {
+	sm.rlock()
+	defer sm.runlock()
+
+	taken := make(map[*edge[S, E]]bool)
+	if c := sm.coverage; c != nil {
+		c.mu.Lock()
+		for e := range c.taken {
+			taken[e] = true
+		}
+		c.mu.Unlock()
+	}
+
+	var r CoverageReport[S, E]
+	for from, byEvent := range sm.transitions {
+		for event, edges := range byEvent {
+			for _, e := range edges {
+				t := Transition[S, E]{From: from, Event: event, To: e.to}
+				if taken[e] {
+					r.Covered = append(r.Covered, t)
+				} else {
+					r.Uncovered = append(r.Uncovered, t)
+				}
+			}
+		}
+	}
+	sortTransitions(r.Covered)
+	sortTransitions(r.Uncovered)
+	return r
+}
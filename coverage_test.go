@@ -0,0 +1,66 @@
+package statemachine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCoverageReport(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent](WithCoverage())
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateCancelled,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }))
+	sm.AddTransition(OrderStatePacking, OrderEventPack, OrderStateAwaiting)
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped)
+	sm.Freeze()
+
+	inst := sm.NewInstanceAt(OrderStatePending)
+	if err := inst.Fire(context.Background(), OrderEventConfirm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.Transition(OrderStatePacking, OrderEventPack); err != nil {
+		t.Fatal(err)
+	}
+	// a rejected transition is not covered
+	sm.Transition(OrderStateAwaiting, OrderEventCancel)
+
+	got := sm.CoverageReport()
+	want := CoverageReport[OrderState, OrderEvent]{
+		Covered: []Transition[OrderState, OrderEvent]{
+			{OrderStatePacking, OrderEventPack, OrderStateAwaiting},
+			{OrderStatePending, OrderEventConfirm, OrderStatePacking},
+		},
+		Uncovered: []Transition[OrderState, OrderEvent]{
+			{OrderStateAwaiting, OrderEventShip, OrderStateShipped},
+			{OrderStatePending, OrderEventConfirm, OrderStateCancelled},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CoverageReport() = %+v, want %+v", got, want)
+	}
+	if got.Complete() || got.Percent() != 50 {
+		t.Errorf("Complete() = %v, Percent() = %v, want false, 50", got.Complete(), got.Percent())
+	}
+
+	wantString := "2 of 4 transitions covered (50.0%)\n" +
+		"not covered: AwaitingCourier --Ship--> Shipped\n" +
+		"not covered: Pending --Confirm--> Cancelled\n"
+	if s := got.String(); s != wantString {
+		t.Errorf("String() = %q, want %q", s, wantString)
+	}
+
+	if c := sm.Clone().CoverageReport(); len(c.Covered) != 0 || len(c.Uncovered) != 4 {
+		t.Errorf("Clone().CoverageReport() = %+v, want nothing covered", c)
+	}
+}
+
+func TestCoverageReport_Disabled(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.Transition(OrderStatePending, OrderEventConfirm)
+
+	r := sm.CoverageReport()
+	if len(r.Covered) != 0 || r.Complete() {
+		t.Errorf("CoverageReport() without WithCoverage = %+v, want nothing covered", r)
+	}
+}
@@ -0,0 +1,201 @@
+package statemachine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression: the standard five fields of
+// minute, hour, day of month, month and day of week, as in
+//
+//	0 2 * * *      every day at 02:00
+//	*/15 9-17 * * MON-FRI
+//
+// Fields accept *, numbers, ranges, lists and /steps, and months and days
+// of the week may be named by their first three letters. Sunday is 0 or 7.
+// When both day fields are restricted, a day matching either one matches,
+// as in cron. The shorthands @yearly, @monthly, @weekly, @daily, @midnight
+// and @hourly are accepted too.
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// domAny and dowAny record a day field given as *, which leaves the
+	// other to decide which days match
+	domAny, dowAny bool
+}
+
+var cronShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+	dayNames   = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+)
+
+// ParseCron parses a cron expression
+func ParseCron(spec string) (*CronSchedule, error) {
+	expr := strings.TrimSpace(spec)
+	if s, ok := cronShorthands[expr]; ok {
+		expr = s
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: want 5 fields, got %d", spec, len(fields))
+	}
+
+	var c CronSchedule
+	var err error
+	parse := func(field string, first, last int, names []string, nameBase int) uint64 {
+		if err != nil {
+			return 0
+		}
+		var set uint64
+		set, err = parseCronField(field, first, last, names, nameBase)
+		if err != nil {
+			err = fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+		return set
+	}
+	c.minute = parse(fields[0], 0, 59, nil, 0)
+	c.hour = parse(fields[1], 0, 23, nil, 0)
+	c.dom = parse(fields[2], 1, 31, nil, 0)
+	c.month = parse(fields[3], 1, 12, monthNames, 1)
+	c.dow = parse(fields[4], 0, 7, dayNames, 0)
+	if err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.domAny = fields[2] == "*" || fields[2] == "?"
+	c.dowAny = fields[4] == "*" || fields[4] == "?"
+	return &c, nil
+}
+
+// parseCronField returns the set of values a field matches, as bits
+func parseCronField(field string, first, last int, names []string, nameBase int) (uint64, error) {
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				return i + nameBase, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < first || n > last {
+			return 0, fmt.Errorf("%q is not a value from %d to %d", s, first, last)
+		}
+		return n, nil
+	}
+
+	var set uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, fmt.Errorf("%q is not a valid step", stepText)
+			}
+		}
+
+		lo, hi := first, last
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			from, to, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(from); err != nil {
+				return 0, err
+			}
+			if hi, err = value(to); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("range %q runs backwards", rng)
+			}
+		default:
+			n, err := value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo = n
+			if !hasStep {
+				hi = n
+			}
+		}
+		for n := lo; n <= hi; n += step {
+			set |= 1 << n
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first time after t that the schedule matches, in t's
+// location, or the zero time if it never does, as for the 30th of February
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, t.Location())
+	// any schedule that matches at all does so within a leap year cycle
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<t.Weekday()) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// cronTrigger fires an event on instances in a state on a schedule
+type cronTrigger[S State, E Event] struct {
+	state    S
+	schedule *CronSchedule
+	event    E
+}
+
+// AddCron fires event on instances in state, or in a state nested inside
+// it, whenever the cron expression spec matches, for example escalating
+// documents that are still in review every night:
+//
+//	err := sm.AddCron(DocStateReviewing, "0 2 * * *", DocEventEscalate)
+//
+// Timers fires crons on the instances it watches, in the time zone of the
+// machine's clock. AddCron returns an error if spec is not a valid cron
+// expression.
+func (sm *StateMachine[S, E]) AddCron(state S, spec string, event E) error {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.crons = append(sm.crons, cronTrigger[S, E]{state: state, schedule: schedule, event: event})
+	return nil
+}
@@ -0,0 +1,139 @@
+package statemachine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr string
+	}{
+		{spec: "* * * * *"},
+		{spec: "*/15 9-17 * * MON-FRI"},
+		{spec: "0 0 1,15 jan-jun/2 ?"},
+		{spec: "@daily"},
+		{spec: "0 2 * *", wantErr: `invalid cron expression "0 2 * *": want 5 fields, got 4`},
+		{spec: "60 * * * *", wantErr: `invalid cron expression "60 * * * *": "60" is not a value from 0 to 59`},
+		{spec: "0 0 0 * *", wantErr: `invalid cron expression "0 0 0 * *": "0" is not a value from 1 to 31`},
+		{spec: "*/0 * * * *", wantErr: `invalid cron expression "*/0 * * * *": "0" is not a valid step`},
+		{spec: "0 17-9 * * *", wantErr: `invalid cron expression "0 17-9 * * *": range "17-9" runs backwards`},
+		{spec: "0 0 * * FUN", wantErr: `invalid cron expression "0 0 * * FUN": "FUN" is not a value from 0 to 7`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParseCron(tt.spec)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("ParseCron() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("ParseCron() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronSchedule_Next(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2024, 5, 1, 12, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{spec: "* * * * *", want: time.Date(2024, 5, 1, 12, 31, 0, 0, time.UTC)},
+		{spec: "30 12 * * *", want: time.Date(2024, 5, 2, 12, 30, 0, 0, time.UTC)},
+		{spec: "0 2 * * *", want: time.Date(2024, 5, 2, 2, 0, 0, 0, time.UTC)},
+		{spec: "*/20 13 * * *", want: time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)},
+		{spec: "0 9 * * MON-FRI", want: time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 9 * * sat,7", want: time.Date(2024, 5, 4, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 0 31 * *", want: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 13 * FRI", want: time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 2 *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "@monthly", want: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 30 2 *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			c, err := ParseCron(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronSchedule_NextLocation(t *testing.T) {
+	kolkata := time.FixedZone("IST", 5*60*60+30*60)
+	c, err := ParseCron("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2024, 5, 1, 12, 10, 0, 0, kolkata)
+	if got, want := c.Next(from), time.Date(2024, 5, 1, 13, 0, 0, 0, kolkata); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v on the local hour", got, want)
+	}
+}
+
+func TestTimers_Cron(t *testing.T) {
+	// Wednesday 1 May 2024, 12:00
+	clock := &fakeClock{now: epoch}
+	sm := NewStateMachine[docState, docEvent](WithClock(clock))
+	sm.AddTransition(docDraft, docSubmit, docFirstReview)
+	sm.AddTransition(docFirstReview, docAdvance, docLegal)
+	sm.AddTransition(docReview, "Escalate", docPaused)
+	if err := sm.SetParent(docFirstReview, docReview); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.SetParent(docLegal, docReview); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.AddCron(docReview, "0 1 * * *", "Unknown"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.AddCron(docReview, "0 2 * * *", "Escalate"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.AddCron(docReview, "every night", "Escalate"); err == nil {
+		t.Error("AddCron() accepted an invalid expression")
+	}
+
+	timers := NewTimers(sm)
+	defer timers.Stop()
+	var failed int
+	timers.OnError = func(inst *Instance[docState, docEvent], err error) { failed++ }
+	drafts := sm.NewInstanceAt(docDraft)
+	reviewing := sm.NewInstanceAt(docFirstReview)
+	legal := sm.NewInstanceAt(docLegal)
+	for _, inst := range []*Instance[docState, docEvent]{drafts, reviewing, legal} {
+		timers.Watch(inst)
+	}
+
+	clock.advance(14 * time.Hour)
+	if !drafts.Is(docDraft) || !reviewing.Is(docPaused) || !legal.Is(docPaused) {
+		t.Errorf("after the first night, at %v, %v and %v", drafts.Current(), reviewing.Current(), legal.Current())
+	}
+	if failed != 2 {
+		t.Errorf("OnError called %d times, want 2 for the unknown event", failed)
+	}
+
+	if err := timers.Fire(context.Background(), drafts, docSubmit); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(24 * time.Hour)
+	if !drafts.Is(docPaused) {
+		t.Errorf("after the second night, at %v, want %v", drafts.Current(), docPaused)
+	}
+
+	timers.Stop()
+	if n := len(clock.timers); n != 0 {
+		t.Errorf("%d timers left scheduled after Stop(), want none", n)
+	}
+}
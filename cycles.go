@@ -0,0 +1,148 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrCycle is returned when a transition would create a cycle in a machine
+// created WithAcyclic
+var ErrCycle = errors.New("transition would create a cycle")
+
+// WithAcyclic makes the machine reject transitions that would let a state be
+// re-entered, for workflows such as approvals that must only ever move
+// forward. AddTransition and AddTransitions panic on such a transition,
+// AddTransitionStrict returns an error matching ErrCycle, and Merge fails.
+//
+// Cycles are found among transitions as declared: a transition inherited
+// from a parent state, or leading into a composite state, is only followed
+// from and to the states it names.
+func WithAcyclic() Option {
+	return func(c *config) {
+		c.acyclic = true
+	}
+}
+
+// DetectCycles returns every cycle in the machine's transitions, as declared,
+// with each cycle listing its states in order starting from the one with the
+// lowest name. A transition from a state to itself is a cycle of one state.
+// Guards are ignored and every target of a choice is followed. The cycles
+// are sorted by their states' names.
+func (sm *StateMachine[S, E]) DetectCycles() [][]S {
+	sm.rlock()
+	defer sm.runlock()
+
+	return findCycles(sm.stateGraph())
+}
+
+// stateGraph returns, for each state with transitions, the states they lead
+// to, sorted by name
+func (sm *StateMachine[S, E]) stateGraph() map[S][]S {
+	graph := make(map[S][]S, len(sm.transitions))
+	for from, byEvent := range sm.transitions {
+		for _, edges := range byEvent {
+			for _, e := range edges {
+				graph[from] = append(graph[from], e.targets()...)
+			}
+		}
+	}
+	for from := range graph {
+		graph[from] = uniqueStates(graph[from])
+	}
+	return graph
+}
+
+// uniqueStates sorts states by name and removes duplicates
+func uniqueStates[S State](states []S) []S {
+	sortStates(states)
+	out := states[:0]
+	for i, s := range states {
+		if i == 0 || s != states[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// findCycles returns the elementary cycles of graph. Each cycle is found
+// from its lowest-named state, searching only through higher-named states,
+// so that it is reported once.
+func findCycles[S State](graph map[S][]S) [][]S {
+	starts := make([]S, 0, len(graph))
+	for s := range graph {
+		starts = append(starts, s)
+	}
+	sortStates(starts)
+
+	var cycles [][]S
+	for _, start := range starts {
+		var path []S
+		onPath := make(map[S]bool)
+		var visit func(s S)
+		visit = func(s S) {
+			path = append(path, s)
+			onPath[s] = true
+			for _, next := range graph[s] {
+				switch {
+				case next == start:
+					cycles = append(cycles, append([]S(nil), path...))
+				case !onPath[next] && Name(next) > Name(start):
+					visit(next)
+				}
+			}
+			path = path[:len(path)-1]
+			onPath[s] = false
+		}
+		visit(start)
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return joinStates(cycles[i]) < joinStates(cycles[j])
+	})
+	return cycles
+}
+
+// reaches reports whether to can be reached from from in graph
+func reaches[S State](graph map[S][]S, from, to S) bool {
+	seen := map[S]bool{from: true}
+	queue := []S{from}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if s == to {
+			return true
+		}
+		for _, next := range graph[s] {
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return false
+}
+
+// checkAcyclic returns an error if adding e from a state would create a
+// cycle. Callers must hold the write lock.
+func (sm *StateMachine[S, E]) checkAcyclic(from S, event E, e *edge[S, E]) error {
+	graph := sm.stateGraph()
+	for _, to := range e.targets() {
+		if reaches(graph, to, from) {
+			return fmt.Errorf("%w: event '%s' from state '%s' leads to '%s', from which '%s' can be reached",
+				ErrCycle, Name(event), Name(from), Name(to), Name(from))
+		}
+	}
+	return nil
+}
+
+// describeCycle formats a cycle as A -> B -> A
+func describeCycle[S State](cycle []S) string {
+	names := make([]string, 0, len(cycle)+1)
+	for _, s := range cycle {
+		names = append(names, "'"+Name(s)+"'")
+	}
+	names = append(names, "'"+Name(cycle[0])+"'")
+	return strings.Join(names, " -> ")
+}
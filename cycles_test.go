@@ -0,0 +1,88 @@
+package statemachine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectCycles(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(sm *StateMachine[OrderState, OrderEvent])
+		want  [][]OrderState
+	}{
+		{
+			name:  "acyclic",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {},
+		},
+		{
+			name: "self loop",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.AddTransition(OrderStateShipped, OrderEventShip, OrderStateShipped)
+			},
+			want: [][]OrderState{{OrderStateShipped}},
+		},
+		{
+			name: "overlapping cycles",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.AddTransition(OrderStateShipped, OrderEventCancel, OrderStatePending)
+				sm.AddTransition(OrderStateRefunded, OrderEventConfirm, OrderStatePending)
+			},
+			want: [][]OrderState{
+				{OrderStateAwaiting, OrderStateShipped, OrderStateDelivered, OrderStateRefunded, OrderStatePending, OrderStatePacking},
+				{OrderStateAwaiting, OrderStateShipped, OrderStatePending, OrderStatePacking},
+				{OrderStateCancelled, OrderStateRefunded, OrderStatePending},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewOrderStateMachine()
+			tt.setup(sm)
+			if got := sm.DetectCycles(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DetectCycles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithAcyclic(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent](WithAcyclic())
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+	sm.AddTransition(OrderStatePacking, OrderEventShip, OrderStateShipped)
+	// a second way forward is not a cycle
+	sm.AddTransition(OrderStatePending, OrderEventShip, OrderStateShipped)
+
+	err := sm.AddTransitionStrict(OrderStateShipped, OrderEventCancel, OrderStatePending)
+	if !errors.Is(err, ErrCycle) {
+		t.Fatalf("AddTransitionStrict() error = %v, want ErrCycle", err)
+	}
+	if sm.CanTransition(OrderStateShipped, OrderEventCancel) {
+		t.Errorf("rejected transition was added")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("AddTransition() of a self loop did not panic")
+		}
+	}()
+	sm.AddTransition(OrderStateShipped, OrderEventShip, OrderStateShipped)
+}
+
+func TestWithAcyclic_Merge(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent](WithAcyclic())
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+
+	other := NewStateMachine[OrderState, OrderEvent]()
+	other.AddTransition(OrderStatePacking, OrderEventCancel, OrderStatePending)
+
+	err := sm.Merge(other)
+	if !errors.Is(err, ErrCycle) || !strings.Contains(err.Error(), "'Packing' -> 'Pending' -> 'Packing'") {
+		t.Fatalf("Merge() error = %v, want a cycle through Packing and Pending", err)
+	}
+	if sm.CanTransition(OrderStatePacking, OrderEventCancel) {
+		t.Errorf("Merge() changed the machine despite failing")
+	}
+}
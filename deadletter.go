@@ -0,0 +1,178 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+// DeadLetter is an event rejected as invalid, with what is needed to look
+// into it and fire it again later
+type DeadLetter[S State, E Event] struct {
+	// EntityID is the ID of the entity the event was fired for, given with
+	// WithEntityID, or empty
+	EntityID string
+
+	// From is the state the event was rejected in
+	From S
+
+	// Event is the event rejected
+	Event E
+
+	// Payload is the event's payload, given with WithPayload or FireWith,
+	// or nil
+	Payload any
+
+	// Data is a copy of the data stored with SetData on the instance the
+	// event was fired on, or nil
+	Data map[string]any
+
+	// Err is the error the transition failed with, matching
+	// ErrInvalidTransition
+	Err error
+
+	// At is when the event was rejected
+	At time.Time
+}
+
+// DeadLetterSink receives the events a machine rejects as invalid, for
+// example to publish them to a queue that asynchronous pipelines can
+// inspect and replay
+type DeadLetterSink[S State, E Event] interface {
+	// DeadLetter receives a rejected event along with the context of the
+	// transition that rejected it. It runs synchronously, before the
+	// transition's error is returned.
+	DeadLetter(ctx context.Context, letter DeadLetter[S, E])
+}
+
+// DeadLetterFunc is a function usable as a DeadLetterSink
+type DeadLetterFunc[S State, E Event] func(ctx context.Context, letter DeadLetter[S, E])
+
+// DeadLetter calls f
+func (f DeadLetterFunc[S, E]) DeadLetter(ctx context.Context, letter DeadLetter[S, E]) {
+	f(ctx, letter)
+}
+
+// SetDeadLetterSink makes the machine send sink every event rejected as
+// invalid, because it is not valid from the state or the guards of its
+// transitions refused it, including those TryTransition turns down. The
+// transition still fails with the error as usual. Transitions that fail for
+// other reasons, such as an action returning an error or ctx being done,
+// are not dead-lettered. A nil sink turns dead-lettering off.
+func (sm *StateMachine[S, E]) SetDeadLetterSink(sink DeadLetterSink[S, E]) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.deadLetters = sink
+}
+
+// deadLetter sends a failed transition to the machine's dead-letter sink, if
+// it has one and the event was rejected as invalid
+func (sm *StateMachine[S, E]) deadLetter(ctx context.Context, from S, event E, err error) {
+	sm.rlock()
+	sink := sm.deadLetters
+	sm.runlock()
+	if sink == nil || !errors.Is(err, ErrInvalidTransition) {
+		return
+	}
+
+	letter := DeadLetter[S, E]{From: from, Event: event, Err: err, At: sm.clock.Now()}
+	letter.EntityID, _ = EntityIDFrom(ctx)
+	letter.Payload, _ = PayloadFrom[any](ctx)
+	if data, ok := DataFrom(ctx); ok {
+		letter.Data = maps.Clone(data)
+	}
+	sink.DeadLetter(ctx, letter)
+}
+
+// deadLetterRejected sends an event TryTransitionContext turned down to the
+// machine's dead-letter sink. The error describing the rejection is only
+// built when there is a sink to receive it.
+func (sm *StateMachine[S, E]) deadLetterRejected(ctx context.Context, from S, event E) {
+	sm.rlock()
+	if sm.deadLetters == nil {
+		sm.runlock()
+		return
+	}
+	err := sm.transitionError(from, event)
+	sm.runlock()
+	sm.deadLetter(ctx, from, event, err)
+}
+
+// DeadLetterQueue is a DeadLetterSink holding the events it receives in
+// memory, in the order they were rejected, until they are replayed. It is
+// safe for concurrent use.
+type DeadLetterQueue[S State, E Event] struct {
+	mu      sync.Mutex
+	letters []DeadLetter[S, E]
+}
+
+type replayKey struct{}
+
+// DeadLetter adds letter to the queue, unless it was rejected again while
+// being replayed
+func (q *DeadLetterQueue[S, E]) DeadLetter(ctx context.Context, letter DeadLetter[S, E]) {
+	if ctx.Value(replayKey{}) == q {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.letters = append(q.letters, letter)
+}
+
+// Letters returns the letters in the queue, oldest first
+func (q *DeadLetterQueue[S, E]) Letters() []DeadLetter[S, E] {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]DeadLetter[S, E](nil), q.letters...)
+}
+
+// Len returns the number of letters in the queue
+func (q *DeadLetterQueue[S, E]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.letters)
+}
+
+// Replay takes the letters out of the queue and hands each to fire, oldest
+// first, typically to fire its event again once the entity it belongs to
+// can handle it:
+//
+//	err := queue.Replay(ctx, func(ctx context.Context, l statemachine.DeadLetter[OrderState, OrderEvent]) error {
+//		if l.Payload != nil {
+//			ctx = statemachine.WithPayload(ctx, l.Payload)
+//		}
+//		_, err := orders.Fire(ctx, l.EntityID, l.Event)
+//		return err
+//	})
+//
+// A letter fire fails for goes back in the queue, and the errors are
+// returned joined. fire should use the context it is given, so that an
+// event rejected again is not added to the queue a second time.
+func (q *DeadLetterQueue[S, E]) Replay(ctx context.Context, fire func(ctx context.Context, letter DeadLetter[S, E]) error) error {
+	q.mu.Lock()
+	letters := q.letters
+	q.letters = nil
+	q.mu.Unlock()
+
+	ctx = context.WithValue(ctx, replayKey{}, q)
+	var failed []DeadLetter[S, E]
+	var errs []error
+	for _, letter := range letters {
+		if err := fire(ctx, letter); err != nil {
+			failed = append(failed, letter)
+			errs = append(errs, err)
+		}
+	}
+
+	q.mu.Lock()
+	q.letters = append(failed, q.letters...)
+	q.mu.Unlock()
+	return errors.Join(errs...)
+}
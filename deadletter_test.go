@@ -0,0 +1,118 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestStateMachine_SetDeadLetterSink(t *testing.T) {
+	errCourier := errors.New("no courier")
+	sm := NewStateMachine[OrderState, OrderEvent](WithClock(&fakeClock{now: epoch}))
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped,
+		WithAction(func(ctx context.Context, from, to OrderState, event OrderEvent) error { return errCourier }))
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }))
+	sm.AddTransition(OrderStateShipped, OrderEventDeliver, OrderStateDelivered)
+	var letters []DeadLetter[OrderState, OrderEvent]
+	sm.SetDeadLetterSink(DeadLetterFunc[OrderState, OrderEvent](func(ctx context.Context, l DeadLetter[OrderState, OrderEvent]) {
+		letters = append(letters, l)
+	}))
+
+	tests := []struct {
+		name        string
+		from        OrderState
+		event       OrderEvent
+		payload     any
+		data        map[string]any
+		wantLetter  bool
+		wantGuarded bool
+	}{
+		{"invalid event", OrderStateShipped, OrderEventRefund, nil, nil, true, false},
+		{"guard rejected", OrderStatePending, OrderEventConfirm, "rush", map[string]any{"customer": "c-42"}, true, true},
+		{"action failed", OrderStateAwaiting, OrderEventShip, nil, nil, false, false},
+		{"completed", OrderStateShipped, OrderEventDeliver, nil, nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			letters = nil
+			inst := sm.NewInstanceAt(tt.from)
+			for k, v := range tt.data {
+				inst.SetData(k, v)
+			}
+			err := inst.FireWith(WithEntityID(context.Background(), "order-1"), tt.event, tt.payload)
+
+			if !tt.wantLetter {
+				if len(letters) != 0 {
+					t.Errorf("dead letters = %v, want none", letters)
+				}
+				return
+			}
+			if len(letters) != 1 {
+				t.Fatalf("got %d dead letters, want 1", len(letters))
+			}
+			l := letters[0]
+			if l.EntityID != "order-1" || l.From != tt.from || l.Event != tt.event || l.Payload != tt.payload || !l.At.Equal(epoch) {
+				t.Errorf("dead letter = %+v, want order-1 %v on %v with payload %v at %v", l, tt.from, tt.event, tt.payload, epoch)
+			}
+			if !maps.Equal(l.Data, tt.data) {
+				t.Errorf("dead letter data = %v, want %v", l.Data, tt.data)
+			}
+			if l.Err != err || !errors.Is(l.Err, ErrInvalidTransition) || errors.Is(l.Err, ErrGuardRejected) != tt.wantGuarded {
+				t.Errorf("dead letter error = %v, want the %v returned", l.Err, err)
+			}
+		})
+	}
+
+	if _, err := sm.Clone().Transition(OrderStateShipped, OrderEventRefund); err == nil || len(letters) != 1 {
+		t.Errorf("clone sent %d dead letters, want its rejection sent to the original's sink", len(letters))
+	}
+
+	letters = nil
+	ctx := WithPayload(WithEntityID(context.Background(), "order-2"), "damaged")
+	if _, ok := sm.TryTransitionContext(ctx, OrderStateShipped, OrderEventRefund); ok || len(letters) != 1 {
+		t.Fatalf("TryTransitionContext() = %v and sent %d dead letters, want it rejected and 1 sent", ok, len(letters))
+	}
+	if l := letters[0]; l.EntityID != "order-2" || l.Payload != "damaged" || l.From != OrderStateShipped || l.Event != OrderEventRefund || !errors.Is(l.Err, ErrInvalidTransition) {
+		t.Errorf("TryTransitionContext() dead letter = %+v", l)
+	}
+}
+
+func TestDeadLetterQueue_Replay(t *testing.T) {
+	ctx := context.Background()
+	sm := NewOrderStateMachine()
+	var q DeadLetterQueue[OrderState, OrderEvent]
+	sm.SetDeadLetterSink(&q)
+	order := sm.NewInstanceAt(OrderStateShipped)
+	replay := func(ctx context.Context, l DeadLetter[OrderState, OrderEvent]) error {
+		return order.FireWith(ctx, l.Event, l.Payload)
+	}
+
+	if err := order.FireWith(ctx, OrderEventRefund, "damaged"); err == nil {
+		t.Fatal("Fire(Refund) from Shipped succeeded")
+	}
+	if got := q.Letters(); len(got) != 1 || got[0].Event != OrderEventRefund || got[0].Payload != "damaged" {
+		t.Fatalf("Letters() = %+v, want the rejected Refund", got)
+	}
+
+	if err := q.Replay(ctx, replay); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Replay() while Shipped error = %v, want %v", err, ErrInvalidTransition)
+	}
+	if got := q.Len(); got != 1 {
+		t.Errorf("Len() after a failed replay = %d, want 1", got)
+	}
+
+	if err := order.Fire(ctx, OrderEventDeliver); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Replay(ctx, replay); err != nil {
+		t.Errorf("Replay() once Delivered error = %v", err)
+	}
+	if got := q.Len(); got != 0 {
+		t.Errorf("Len() after replaying = %d, want 0", got)
+	}
+	if got := order.Current(); got != OrderStateRefunded {
+		t.Errorf("Current() = %v, want Refunded", got)
+	}
+}
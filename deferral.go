@@ -0,0 +1,104 @@
+package statemachine
+
+import (
+	"context"
+	"slices"
+)
+
+// Defer makes instances in state hold on to the given events when state
+// does not handle them, rather than failing, and deliver them once a
+// transition reaches a state where they are no longer deferred. A refund
+// asked for while an order is still on its way waits for the delivery:
+//
+//	sm.Defer(OrderStateShipped, OrderEventRefund)
+//	...
+//	order.Fire(ctx, OrderEventRefund)  // nil; the order stays Shipped
+//	order.Fire(ctx, OrderEventDeliver) // delivered, then refunded
+//
+// Events deferred in a composite state are deferred in its substates too.
+// Deferral only applies to instances; StateMachine.Transition fails for an
+// event the state does not handle as usual.
+func (sm *StateMachine[S, E]) Defer(state S, events ...E) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	if sm.deferred == nil {
+		sm.deferred = make(map[S][]E)
+	}
+	for _, event := range events {
+		if !slices.Contains(sm.deferred[state], event) {
+			sm.deferred[state] = append(sm.deferred[state], event)
+		}
+	}
+}
+
+// IsDeferred reports whether event is deferred in state, or in a composite
+// state it is nested in
+func (sm *StateMachine[S, E]) IsDeferred(state S, event E) bool {
+	sm.rlock()
+	defer sm.runlock()
+
+	return sm.isDeferred(state, event)
+}
+
+func (sm *StateMachine[S, E]) isDeferred(state S, event E) bool {
+	if len(sm.deferred) == 0 {
+		return false
+	}
+	for s, ok := state, true; ok; s, ok = sm.parents[s] {
+		if slices.Contains(sm.deferred[s], event) {
+			return true
+		}
+	}
+	return false
+}
+
+// deferring reports whether the instance should hold on to event rather
+// than fire it: it is deferred in the current state, which has no
+// transition for it
+func (i *Instance[S, E]) deferring(event E) bool {
+	sm := i.sm
+	sm.rlock()
+	defer sm.runlock()
+
+	return sm.isDeferred(i.current, event) && len(sm.edgesFor(i.current, event)) == 0
+}
+
+// hold keeps event, fired with ctx, until the instance reaches a state
+// where it is no longer deferred. The event keeps ctx's values but not its
+// cancellation, since it may be delivered long after its caller has gone.
+func (i *Instance[S, E]) hold(ctx context.Context, event E) {
+	i.deferred = append(i.deferred, queuedEvent[E]{ctx: context.WithoutCancel(ctx), event: event})
+}
+
+// release queues the deferred events no longer deferred in the current
+// state, ahead of any events already queued, for the run under way to
+// deliver
+func (i *Instance[S, E]) release() {
+	if len(i.deferred) == 0 {
+		return
+	}
+	i.sm.rlock()
+	var ready, held []queuedEvent[E]
+	for _, d := range i.deferred {
+		if i.sm.isDeferred(i.current, d.event) {
+			held = append(held, d)
+		} else {
+			ready = append(ready, d)
+		}
+	}
+	i.sm.runlock()
+	i.deferred = held
+	i.queue = append(ready, i.queue...)
+}
+
+// Deferred returns the events the instance is holding on to, in the order
+// they were fired
+func (i *Instance[S, E]) Deferred() []E {
+	events := make([]E, len(i.deferred))
+	for n, d := range i.deferred {
+		events[n] = d.event
+	}
+	return events
+}
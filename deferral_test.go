@@ -0,0 +1,119 @@
+package statemachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestStateMachine_Defer(t *testing.T) {
+	ctx := context.Background()
+	sm := NewOrderStateMachine()
+	sm.Defer(OrderStateShipped, OrderEventRefund)
+	sm.Defer(OrderStateProcessing, OrderEventRefund)
+
+	tests := []struct {
+		name   string
+		start  OrderState
+		events []OrderEvent
+		want   OrderState
+		held   []OrderEvent
+	}{
+		{"held while shipped", OrderStateShipped, []OrderEvent{OrderEventRefund}, OrderStateShipped, []OrderEvent{OrderEventRefund}},
+		{"delivered on delivery", OrderStateShipped, []OrderEvent{OrderEventRefund, OrderEventDeliver}, OrderStateRefunded, []OrderEvent{}},
+		{"held through substates", OrderStatePacking, []OrderEvent{OrderEventRefund, OrderEventPack}, OrderStateAwaiting, []OrderEvent{OrderEventRefund}},
+		{"held until no longer deferred", OrderStatePacking, []OrderEvent{OrderEventRefund, OrderEventPack, OrderEventShip, OrderEventDeliver}, OrderStateRefunded, []OrderEvent{}},
+		{"handled where not deferred", OrderStateDelivered, []OrderEvent{OrderEventRefund}, OrderStateRefunded, []OrderEvent{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := sm.NewInstanceAt(tt.start)
+			for _, event := range tt.events {
+				if err := inst.Fire(ctx, event); err != nil {
+					t.Fatalf("Fire(%v) error = %v", event, err)
+				}
+			}
+			if got := inst.Current(); got != tt.want {
+				t.Errorf("Current() = %v, want %v", got, tt.want)
+			}
+			if got := inst.Deferred(); !slices.Equal(got, tt.held) {
+				t.Errorf("Deferred() = %v, want %v", got, tt.held)
+			}
+		})
+	}
+
+	if !sm.IsDeferred(OrderStateAwaiting, OrderEventRefund) {
+		t.Error("IsDeferred(Awaiting, Refund) = false, want it inherited from Processing")
+	}
+	if sm.IsDeferred(OrderStateDelivered, OrderEventRefund) {
+		t.Error("IsDeferred(Delivered, Refund) = true")
+	}
+	if _, err := sm.Transition(OrderStateShipped, OrderEventRefund); err == nil {
+		t.Error("Transition(Shipped, Refund) succeeded, want deferral to apply to instances only")
+	}
+}
+
+func TestStateMachine_DeferUnhandled(t *testing.T) {
+	ctx := context.Background()
+	b := NewBuilder[OrderState, OrderEvent]()
+	b.From(OrderStateShipped).On(OrderEventDeliver).To(OrderStateDelivered)
+	b.From(OrderStateShipped).On(OrderEventCancel).To(OrderStateCancelled)
+	b.From(OrderStateDelivered).On(OrderEventRefund).To(OrderStateRefunded)
+	b.Defer(OrderStateShipped, OrderEventRefund)
+	sm := b.MustBuild()
+
+	inst := sm.NewInstanceAt(OrderStateShipped)
+	if err := inst.Fire(ctx, OrderEventRefund); err != nil {
+		t.Fatal(err)
+	}
+	err := inst.Fire(ctx, OrderEventCancel)
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Fire(Cancel) error = %v, want the released Refund to fail with %v", err, ErrInvalidTransition)
+	}
+	if got := inst.Current(); got != OrderStateCancelled {
+		t.Errorf("Current() = %v, want Cancelled", got)
+	}
+	if got := inst.Deferred(); len(got) != 0 {
+		t.Errorf("Deferred() = %v, want none", got)
+	}
+}
+
+func TestStateMachine_DeferSnapshot(t *testing.T) {
+	ctx := context.Background()
+	sm := NewOrderStateMachine()
+	sm.Defer(OrderStateShipped, OrderEventRefund)
+
+	inst := sm.NewInstanceAt(OrderStateShipped)
+	if err := inst.Fire(ctx, OrderEventRefund); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(inst.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap Snapshot[OrderState]
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := sm.NewInstanceAt(OrderStatePending)
+	if err := restored.Restore(snap); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if got := restored.Deferred(); !slices.Equal(got, []OrderEvent{OrderEventRefund}) {
+		t.Errorf("Deferred() = %v, want [Refund]", got)
+	}
+	if err := restored.Fire(ctx, OrderEventDeliver); err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Current(); got != OrderStateRefunded {
+		t.Errorf("Current() = %v, want Refunded", got)
+	}
+
+	snap.Deferred = []string{"Teleport"}
+	if err := restored.Restore(snap); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Restore() error = %v, want %v", err, ErrUnknownEvent)
+	}
+}
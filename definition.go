@@ -0,0 +1,542 @@
+package statemachine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// Definition is a state machine described as data rather than code.
+// A definition may be spread over several files; states and events declared
+// in one file can be referenced by transitions in another.
+type Definition struct {
+	Schema      string                 `json:"$schema,omitempty" yaml:"$schema,omitempty"`
+	Name        string                 `json:"name,omitempty" yaml:"name,omitempty"`
+	Version     int                    `json:"version,omitempty" yaml:"version,omitempty"`
+	Initial     string                 `json:"initial,omitempty" yaml:"initial,omitempty"`
+	States      []StateDefinition      `json:"states" yaml:"states"`
+	Events      []EventDefinition      `json:"events" yaml:"events"`
+	Transitions []TransitionDefinition `json:"transitions" yaml:"transitions"`
+
+	initialPos position
+}
+
+// StateDefinition declares a state by name
+type StateDefinition struct {
+	Name        string   `json:"name" yaml:"name"`
+	Label       string   `json:"label,omitempty" yaml:"label,omitempty"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Final       bool     `json:"final,omitempty" yaml:"final,omitempty"`
+
+	// RenamedFrom lists names the state had in earlier versions of the
+	// definition, so stored entities can be migrated. See MigrationPlan.
+	RenamedFrom []string `json:"renamedFrom,omitempty" yaml:"renamedFrom,omitempty"`
+
+	// Timeout, if set, fires an event once an entity has been in the state
+	// for a while. See SetTimeout.
+	Timeout *TimeoutDefinition `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	pos position
+}
+
+// TimeoutDefinition describes a state's timeout
+type TimeoutDefinition struct {
+	// After is how long an entity may stay in the state, as accepted by
+	// time.ParseDuration, such as "48h"
+	After string `json:"after" yaml:"after"`
+	Event string `json:"event" yaml:"event"`
+}
+
+// EventDefinition declares an event by name
+type EventDefinition struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	pos position
+}
+
+// TransitionDefinition describes a single transition rule by state and event names
+type TransitionDefinition struct {
+	From        string   `json:"from" yaml:"from"`
+	Event       string   `json:"event" yaml:"event"`
+	To          string   `json:"to" yaml:"to"`
+	Label       string   `json:"label,omitempty" yaml:"label,omitempty"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// Guards names the guards the transition is subject to, resolved when
+	// the definition is loaded with FromDefinitionWithGuards or LoadYAML,
+	// or gives them as CEL expressions for the smcel package to compile.
+	// Guarded transitions may compete for the same state and event.
+	Guards []string `json:"guards,omitempty" yaml:"guards,omitempty"`
+
+	pos position
+}
+
+// position records where an element of a definition was read from
+type position struct {
+	file string
+	line int
+}
+
+// DefinitionError reports a problem with a definition file, including the
+// file and line the problem was found on when known.
+type DefinitionError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *DefinitionError) Error() string {
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	case e.File != "":
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	default:
+		return e.Err.Error()
+	}
+}
+
+func (e *DefinitionError) Unwrap() error {
+	return e.Err
+}
+
+func definitionErrorf(pos position, format string, args ...any) error {
+	return &DefinitionError{File: pos.file, Line: pos.line, Err: fmt.Errorf(format, args...)}
+}
+
+// ParseDefinition parses and validates a single definition document.
+// The name is only used to give errors file context.
+func ParseDefinition(name string, data []byte) (*Definition, error) {
+	def, err := decodeDefinition(name, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := def.validate(); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// ParseDefinitionFS reads every file in fsys matching the given glob patterns
+// and merges them into one validated definition. Files are read in lexical
+// order so errors are reported deterministically. Files named .yaml or .yml
+// are read as YAML, and any others as JSON.
+func ParseDefinitionFS(fsys fs.FS, patterns ...string) (*Definition, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no definition file patterns given")
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid definition pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("definition pattern %q matched no files", pattern)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				files = append(files, m)
+				seen[m] = true
+			}
+		}
+	}
+	sort.Strings(files)
+
+	merged := &Definition{}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, &DefinitionError{File: file, Err: err}
+		}
+		decode := decodeDefinition
+		if ext := path.Ext(file); ext == ".yaml" || ext == ".yml" {
+			decode = decodeYAMLDefinition
+		}
+		def, err := decode(file, data)
+		if err != nil {
+			return nil, err
+		}
+		if def.Name != "" {
+			if merged.Name != "" && merged.Name != def.Name {
+				return nil, definitionErrorf(position{file: file}, "name %q conflicts with %q declared in another file", def.Name, merged.Name)
+			}
+			merged.Name = def.Name
+		}
+		if def.Version != 0 {
+			if merged.Version != 0 && merged.Version != def.Version {
+				return nil, definitionErrorf(position{file: file}, "version %d conflicts with version %d declared in another file", def.Version, merged.Version)
+			}
+			merged.Version = def.Version
+		}
+		if def.Initial != "" {
+			if merged.Initial != "" && merged.Initial != def.Initial {
+				return nil, definitionErrorf(position{file: file}, "initial state %q conflicts with %q declared in another file", def.Initial, merged.Initial)
+			}
+			merged.Initial = def.Initial
+			merged.initialPos = position{file: file}
+		}
+		merged.States = append(merged.States, def.States...)
+		merged.Events = append(merged.Events, def.Events...)
+		merged.Transitions = append(merged.Transitions, def.Transitions...)
+	}
+
+	if err := merged.validate(); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// decodeDefinition decodes one definition document and records the line each
+// state, event and transition starts on
+func decodeDefinition(name string, data []byte) (*Definition, error) {
+	def := &Definition{initialPos: position{file: name}}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(def); err != nil {
+		return nil, jsonDefinitionError(name, data, err)
+	}
+
+	lines, err := elementLines(data, "states", "events", "transitions")
+	if err != nil {
+		return nil, &DefinitionError{File: name, Err: err}
+	}
+	for i := range def.States {
+		def.States[i].pos = position{file: name, line: lines["states"][i]}
+	}
+	for i := range def.Events {
+		def.Events[i].pos = position{file: name, line: lines["events"][i]}
+	}
+	for i := range def.Transitions {
+		def.Transitions[i].pos = position{file: name, line: lines["transitions"][i]}
+	}
+	return def, nil
+}
+
+// jsonDefinitionError converts a decoding error into a DefinitionError with a
+// line number where the json package reports an offset
+func jsonDefinitionError(name string, data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &DefinitionError{File: name, Line: lineAt(data, syntaxErr.Offset), Err: err}
+	case errors.As(err, &typeErr):
+		return &DefinitionError{File: name, Line: lineAt(data, typeErr.Offset), Err: err}
+	default:
+		return &DefinitionError{File: name, Err: err}
+	}
+}
+
+// elementLines walks the top level object in data and returns, for each of
+// the given keys holding an array, the line each array element starts on
+func elementLines(data []byte, keys ...string) (map[string][]int, error) {
+	wanted := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		wanted[k] = true
+	}
+	lines := make(map[string][]int, len(keys))
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		if !wanted[key] {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		tok, err = dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if tok != json.Delim('[') {
+			// null or an empty value, nothing to record
+			continue
+		}
+		for dec.More() {
+			offset := skipSeparators(data, dec.InputOffset())
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+			lines[key] = append(lines[key], lineAt(data, offset))
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+// skipSeparators advances offset past whitespace and commas
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// lineAt returns the 1-based line number of the byte at offset
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte{'\n'}) + 1
+}
+
+// validate checks the definition is internally consistent: every name is
+// declared once, every transition refers to declared states and events, and
+// no two unguarded transitions share a state and event
+func (d *Definition) validate() error {
+	states := make(map[string]position, len(d.States))
+	for _, s := range d.States {
+		if s.Name == "" {
+			return definitionErrorf(s.pos, "state has no name")
+		}
+		if prev, exists := states[s.Name]; exists {
+			return definitionErrorf(s.pos, "state %q already declared at %s", s.Name, prev)
+		}
+		states[s.Name] = s.pos
+	}
+
+	renamed := make(map[string]string)
+	for _, s := range d.States {
+		for _, old := range s.RenamedFrom {
+			if _, exists := states[old]; exists {
+				return definitionErrorf(s.pos, "state %q renamed from %q, which is still declared", s.Name, old)
+			}
+			if prev, exists := renamed[old]; exists {
+				return definitionErrorf(s.pos, "state %q renamed from %q, which %q was already renamed from", s.Name, old, prev)
+			}
+			renamed[old] = s.Name
+		}
+	}
+
+	events := make(map[string]position, len(d.Events))
+	for _, e := range d.Events {
+		if e.Name == "" {
+			return definitionErrorf(e.pos, "event has no name")
+		}
+		if prev, exists := events[e.Name]; exists {
+			return definitionErrorf(e.pos, "event %q already declared at %s", e.Name, prev)
+		}
+		events[e.Name] = e.pos
+	}
+
+	for _, s := range d.States {
+		if s.Timeout == nil {
+			continue
+		}
+		if after, err := time.ParseDuration(s.Timeout.After); err != nil || after <= 0 {
+			return definitionErrorf(s.pos, "state %q times out after %q, which is not a positive duration", s.Name, s.Timeout.After)
+		}
+		if _, ok := events[s.Timeout.Event]; !ok {
+			return definitionErrorf(s.pos, "state %q times out on undeclared event %q", s.Name, s.Timeout.Event)
+		}
+	}
+
+	if d.Initial != "" {
+		if _, ok := states[d.Initial]; !ok {
+			return definitionErrorf(d.initialPos, "initial state %q is not declared", d.Initial)
+		}
+	}
+
+	type edge struct{ from, event string }
+	edges := make(map[edge]position, len(d.Transitions))
+	for _, t := range d.Transitions {
+		if _, ok := states[t.From]; !ok {
+			return definitionErrorf(t.pos, "transition from undeclared state %q", t.From)
+		}
+		if _, ok := events[t.Event]; !ok {
+			return definitionErrorf(t.pos, "transition on undeclared event %q", t.Event)
+		}
+		if _, ok := states[t.To]; !ok {
+			return definitionErrorf(t.pos, "transition to undeclared state %q", t.To)
+		}
+		if len(t.Guards) > 0 {
+			continue
+		}
+		key := edge{t.From, t.Event}
+		if prev, exists := edges[key]; exists {
+			return definitionErrorf(t.pos, "transition from %q on %q already declared at %s", t.From, t.Event, prev)
+		}
+		edges[key] = t.pos
+	}
+	return nil
+}
+
+func (p position) String() string {
+	switch {
+	case p.file != "" && p.line > 0:
+		return fmt.Sprintf("%s:%d", p.file, p.line)
+	case p.line > 0:
+		return fmt.Sprintf("line %d", p.line)
+	default:
+		return p.file
+	}
+}
+
+// FromDefinition builds a state machine from a definition, resolving the
+// names used in the definition against the given state and event values by
+// their names, as Name gives them. Every name in the definition must resolve.
+func FromDefinition[S State, E Event](def *Definition, states []S, events []E) (*StateMachine[S, E], error) {
+	return FromDefinitionWithGuards(def, states, events, nil)
+}
+
+// FromDefinitionWithGuards is like FromDefinition, but also resolves the
+// guards named by transitions against guards, so that guard logic written in
+// Go can be attached to transitions described as data:
+//
+//	sm, err := statemachine.FromDefinitionWithGuards(def, allStates, allEvents,
+//		map[string]statemachine.Guard[OrderState, OrderEvent]{
+//			"paymentCleared": paymentCleared,
+//		})
+//
+// Every guard named must be in guards.
+func FromDefinitionWithGuards[S State, E Event](def *Definition, states []S, events []E, guards map[string]Guard[S, E]) (*StateMachine[S, E], error) {
+	stateByName := make(map[string]S, len(states))
+	for _, s := range states {
+		stateByName[Name(s)] = s
+	}
+	eventByName := make(map[string]E, len(events))
+	for _, e := range events {
+		eventByName[Name(e)] = e
+	}
+
+	for _, s := range def.States {
+		if _, ok := stateByName[s.Name]; !ok {
+			return nil, definitionErrorf(s.pos, "unknown state %q", s.Name)
+		}
+	}
+	if def.Initial != "" {
+		if _, ok := stateByName[def.Initial]; !ok {
+			return nil, definitionErrorf(def.initialPos, "unknown state %q", def.Initial)
+		}
+	}
+	for _, e := range def.Events {
+		if _, ok := eventByName[e.Name]; !ok {
+			return nil, definitionErrorf(e.pos, "unknown event %q", e.Name)
+		}
+	}
+
+	sm := NewStateMachine[S, E]()
+	sm.SetVersion(def.Version)
+	for _, s := range def.States {
+		sm.DeclareStates(stateByName[s.Name])
+	}
+	for _, e := range def.Events {
+		sm.DeclareEvents(eventByName[e.Name])
+	}
+	for _, t := range def.Transitions {
+		from, ok := stateByName[t.From]
+		if !ok {
+			return nil, definitionErrorf(t.pos, "unknown state %q", t.From)
+		}
+		event, ok := eventByName[t.Event]
+		if !ok {
+			return nil, definitionErrorf(t.pos, "unknown event %q", t.Event)
+		}
+		to, ok := stateByName[t.To]
+		if !ok {
+			return nil, definitionErrorf(t.pos, "unknown state %q", t.To)
+		}
+		var opts []TransitionOption[S, E]
+		if m, ok := definitionMeta(t.Label, t.Description, t.Tags); ok {
+			opts = append(opts, WithMeta[S, E](m))
+		}
+		for _, name := range t.Guards {
+			guard, ok := guards[name]
+			if !ok {
+				return nil, definitionErrorf(t.pos, "unknown guard %q", name)
+			}
+			opts = append(opts, withNamedGuard(name, guard))
+		}
+		sm.AddTransition(from, event, to, opts...)
+	}
+	if def.Initial != "" {
+		sm.SetInitialState(stateByName[def.Initial])
+	}
+	for _, s := range def.States {
+		if s.Final {
+			sm.AddFinalState(stateByName[s.Name])
+		}
+		if m, ok := definitionMeta(s.Label, s.Description, s.Tags); ok {
+			sm.SetStateMeta(stateByName[s.Name], m)
+		}
+		if s.Timeout != nil {
+			event, ok := eventByName[s.Timeout.Event]
+			if !ok {
+				return nil, definitionErrorf(s.pos, "unknown event %q", s.Timeout.Event)
+			}
+			after, err := time.ParseDuration(s.Timeout.After)
+			if err != nil {
+				return nil, definitionErrorf(s.pos, "invalid timeout: %w", err)
+			}
+			sm.SetTimeout(stateByName[s.Name], after, event)
+		}
+	}
+	return sm, nil
+}
+
+// definitionMeta returns the metadata declared for a state or transition in a
+// definition, if any was
+func definitionMeta(label, description string, tags []string) (Metadata, bool) {
+	if label == "" && description == "" && len(tags) == 0 {
+		return Metadata{}, false
+	}
+	return Metadata{Label: label, Description: description, Tags: tags}, true
+}
+
+// LoadFS builds a state machine from the definition files in fsys matching
+// the given glob patterns. It is designed for use with go:embed so that
+// definitions ship inside the binary:
+//
+//	//go:embed workflows/order/*.json
+//	var orderFiles embed.FS
+//
+//	sm, err := statemachine.LoadFS(orderFiles, allStates, allEvents, "workflows/order/*.json")
+func LoadFS[S State, E Event](fsys fs.FS, states []S, events []E, patterns ...string) (*StateMachine[S, E], error) {
+	def, err := ParseDefinitionFS(fsys, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return FromDefinition(def, states, events)
+}
+
+// MustLoadFS is like LoadFS but panics if the definition cannot be loaded.
+// It is intended for package level variables and program start up, so that
+// a broken definition fails fast with file and line context.
+func MustLoadFS[S State, E Event](fsys fs.FS, states []S, events []E, patterns ...string) *StateMachine[S, E] {
+	sm, err := LoadFS(fsys, states, events, patterns...)
+	if err != nil {
+		panic("statemachine: " + err.Error())
+	}
+	return sm
+}
@@ -0,0 +1,230 @@
+package statemachine
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var allUserStates = []UserState{
+	UserStateInitial,
+	UserStateEmailPendingVerification,
+	UserStateEmailVerified,
+	UserStateSignUpComplete,
+	UserStateRejected,
+}
+
+var allUserEvents = []UserEvent{
+	UserEventSubmitSignUp,
+	UserEventClickVerificationLink,
+	UserEventSignupFailed,
+	UserEventCompleteProfile,
+}
+
+const userStatesFile = `{
+  "name": "signup",
+  "states": [
+    {"name": "Initial"},
+    {"name": "EmailPendingVerification"},
+    {"name": "EmailVerified"},
+    {"name": "SignUpComplete"},
+    {"name": "SignupRejected"}
+  ],
+  "events": [
+    {"name": "SubmitSignup"},
+    {"name": "ClickVerificationLink"},
+    {"name": "SignUpFailed"},
+    {"name": "CompleteProfile"}
+  ]
+}`
+
+const userTransitionsFile = `{
+  "transitions": [
+    {"from": "Initial", "event": "SubmitSignup", "to": "EmailPendingVerification"},
+    {"from": "EmailPendingVerification", "event": "ClickVerificationLink", "to": "EmailVerified"},
+    {"from": "EmailVerified", "event": "CompleteProfile", "to": "SignUpComplete"}
+  ]
+}`
+
+func TestLoadFS_MultipleFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defs/signup/states.json":      {Data: []byte(userStatesFile)},
+		"defs/signup/transitions.json": {Data: []byte(userTransitionsFile)},
+	}
+
+	sm, err := LoadFS(fsys, allUserStates, allUserEvents, "defs/signup/*.json")
+	if err != nil {
+		t.Fatalf("LoadFS() error = %v", err)
+	}
+
+	final, err := sm.ValidateTransitionPath(UserStateInitial, []UserEvent{
+		UserEventSubmitSignUp,
+		UserEventClickVerificationLink,
+		UserEventCompleteProfile,
+	})
+	if err != nil {
+		t.Fatalf("ValidateTransitionPath() error = %v", err)
+	}
+	if final != UserStateSignUpComplete {
+		t.Errorf("ValidateTransitionPath() = %v, want %v", final, UserStateSignUpComplete)
+	}
+}
+
+func TestLoadFS_YAMLTimeouts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"signup/states.json": {Data: []byte(userStatesFile)},
+		"signup/timeouts.yaml": {Data: []byte(`states:
+  - name: Unverified
+    timeout: {after: 48h, event: SignUpFailed}
+transitions:
+  - {from: Unverified, event: SignUpFailed, to: SignupRejected}
+`)},
+	}
+	states := slices.Concat(allUserStates, []UserState{"Unverified"})
+
+	sm, err := LoadFS(fsys, states, allUserEvents, "signup/*")
+	if err != nil {
+		t.Fatalf("LoadFS() error = %v", err)
+	}
+	after, event, ok := sm.GetTimeout("Unverified")
+	if !ok || after != 48*time.Hour || event != UserEventSignupFailed {
+		t.Errorf("GetTimeout() = %v, %v, %v, want 48h and %v", after, event, ok, UserEventSignupFailed)
+	}
+	if got := sm.ToDefinition().States; !slices.ContainsFunc(got, func(s StateDefinition) bool {
+		return s.Timeout != nil && *s.Timeout == TimeoutDefinition{After: "48h0m0s", Event: "SignUpFailed"}
+	}) {
+		t.Errorf("ToDefinition() states = %+v, want the timeout", got)
+	}
+}
+
+func TestLoadFS_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		pattern string
+		wantErr string
+	}{
+		{
+			name:    "no matching files",
+			files:   fstest.MapFS{},
+			pattern: "*.json",
+			wantErr: `definition pattern "*.json" matched no files`,
+		},
+		{
+			name: "syntax error has line",
+			files: fstest.MapFS{
+				"bad.json": {Data: []byte("{\n  \"states\": [\n    {\"name\": }\n  ]\n}")},
+			},
+			pattern: "*.json",
+			wantErr: "bad.json:3:",
+		},
+		{
+			name: "unknown field",
+			files: fstest.MapFS{
+				"bad.json": {Data: []byte(`{"stats": []}`)},
+			},
+			pattern: "*.json",
+			wantErr: `bad.json: json: unknown field "stats"`,
+		},
+		{
+			name: "duplicate transition",
+			files: fstest.MapFS{
+				"a_states.json": {Data: []byte(userStatesFile)},
+				"b_transitions.json": {Data: []byte(`{
+  "transitions": [
+    {"from": "Initial", "event": "SubmitSignup", "to": "EmailPendingVerification"},
+    {"from": "Initial", "event": "SubmitSignup", "to": "SignupRejected"}
+  ]
+}`)},
+			},
+			pattern: "*.json",
+			wantErr: `b_transitions.json:4: transition from "Initial" on "SubmitSignup" already declared at b_transitions.json:3`,
+		},
+		{
+			name: "typo in target state",
+			files: fstest.MapFS{
+				"a_states.json": {Data: []byte(userStatesFile)},
+				"b_transitions.json": {Data: []byte(`{
+  "transitions": [
+    {"from": "Initial", "event": "SubmitSignup", "to": "EmailPending"}
+  ]
+}`)},
+			},
+			pattern: "*.json",
+			wantErr: `b_transitions.json:3: transition to undeclared state "EmailPending"`,
+		},
+		{
+			name: "duplicate state across files",
+			files: fstest.MapFS{
+				"a.json": {Data: []byte(userStatesFile)},
+				"b.json": {Data: []byte("{\n  \"states\": [{\"name\": \"Initial\"}]\n}")},
+			},
+			pattern: "*.json",
+			wantErr: `b.json:2: state "Initial" already declared at a.json:4`,
+		},
+		{
+			name: "timeout without a duration",
+			files: fstest.MapFS{
+				"a.json": {Data: []byte("{\n  \"states\": [\n    {\"name\": \"Initial\", \"timeout\": {\"after\": \"soon\", \"event\": \"SignUpFailed\"}}\n  ],\n  \"events\": [{\"name\": \"SignUpFailed\"}]\n}")},
+			},
+			pattern: "*.json",
+			wantErr: `a.json:3: state "Initial" times out after "soon", which is not a positive duration`,
+		},
+		{
+			name: "timeout on an undeclared event",
+			files: fstest.MapFS{
+				"a.yml": {Data: []byte("states:\n  - {name: Initial, timeout: {after: 1h, event: Expire}}\n")},
+			},
+			pattern: "*.yml",
+			wantErr: `a.yml:2: state "Initial" times out on undeclared event "Expire"`,
+		},
+		{
+			name: "name not known to Go types",
+			files: fstest.MapFS{
+				"a.json": {Data: []byte("{\n  \"states\": [\n    {\"name\": \"Archived\"}\n  ]\n}")},
+			},
+			pattern: "*.json",
+			wantErr: `a.json:3: unknown state "Archived"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFS(tt.files, allUserStates, allUserEvents, tt.pattern)
+			if err == nil {
+				t.Fatalf("LoadFS() expected error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadFS() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFS_DefinitionErrorContext(t *testing.T) {
+	fsys := fstest.MapFS{
+		"order.json": {Data: []byte("{\n  \"transitions\": [\n    {\"from\": \"A\", \"event\": \"B\", \"to\": \"C\"}\n  ]\n}")},
+	}
+
+	_, err := ParseDefinitionFS(fsys, "order.json")
+
+	var defErr *DefinitionError
+	if !errors.As(err, &defErr) {
+		t.Fatalf("ParseDefinitionFS() error = %v, want *DefinitionError", err)
+	}
+	if defErr.File != "order.json" || defErr.Line != 3 {
+		t.Errorf("DefinitionError at %s:%d, want order.json:3", defErr.File, defErr.Line)
+	}
+}
+
+func TestMustLoadFS_Panics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustLoadFS() expected panic for missing files")
+		}
+	}()
+	MustLoadFS(fstest.MapFS{}, allUserStates, allUserEvents, "*.json")
+}
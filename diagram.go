@@ -0,0 +1,112 @@
+package statemachine
+
+import (
+	"sort"
+	"strings"
+)
+
+// diagram is a snapshot of a machine's structure, in a stable order, that
+// the exporters render
+type diagram[S State, E Event] struct {
+	// roots are the states with no parent, sorted by name
+	roots []S
+	// children lists the substates of each composite state, sorted by name
+	children map[S][]S
+	edges    []diagramEdge[S, E]
+
+	initial         S
+	hasInitial      bool
+	initialSubstate map[S]S
+	final           map[S]bool
+	stateMeta       map[S]Metadata
+}
+
+// diagramEdge is a single arrow: one per target of each declared transition
+type diagramEdge[S State, E Event] struct {
+	from    S
+	event   E
+	to      S
+	guarded bool
+	choice  bool
+	history HistoryKind
+	meta    Metadata
+
+	// src is the transition the edge is drawn for
+	src *edge[S, E]
+}
+
+// diagram takes a snapshot of the machine for rendering. Callers must hold
+// the read lock.
+func (sm *StateMachine[S, E]) diagram() diagram[S, E] {
+	d := diagram[S, E]{
+		children:        make(map[S][]S),
+		initial:         sm.initialState,
+		hasInitial:      sm.hasInitialState,
+		initialSubstate: sm.initial,
+		final:           sm.final,
+		stateMeta:       sm.stateMeta,
+	}
+
+	states := sm.allStates()
+	sortStates(states)
+	for _, s := range states {
+		if parent, ok := sm.parents[s]; ok {
+			d.children[parent] = append(d.children[parent], s)
+		} else {
+			d.roots = append(d.roots, s)
+		}
+	}
+
+	for from, byEvent := range sm.transitions {
+		for event, edges := range byEvent {
+			for _, e := range edges {
+				for _, to := range e.targets() {
+					d.edges = append(d.edges, diagramEdge[S, E]{
+						from:    from,
+						event:   event,
+						to:      to,
+						guarded: len(e.guards) > 0,
+						choice:  e.resolve != nil,
+						history: e.history,
+						meta:    e.meta,
+						src:     e,
+					})
+				}
+			}
+		}
+	}
+	// edges for the same state and event keep the order they are tried in
+	sort.SliceStable(d.edges, func(i, j int) bool {
+		return transitionLess(d.edges[i].from, d.edges[i].event, d.edges[j].from, d.edges[j].event)
+	})
+	return d
+}
+
+// label returns the label to show for a state: its metadata label if it has
+// one, otherwise its name
+func (d diagram[S, E]) label(s S) string {
+	if m, ok := d.stateMeta[s]; ok && m.Label != "" {
+		return m.Label
+	}
+	return Name(s)
+}
+
+// eventLabel returns the label to show for an edge: its metadata label if it
+// has one, otherwise the event name
+func (e diagramEdge[S, E]) eventLabel() string {
+	if e.meta.Label != "" {
+		return e.meta.Label
+	}
+	return Name(e.event)
+}
+
+// diagramID returns the state's name with anything diagram languages do not
+// accept in an identifier replaced by an underscore
+func diagramID[S State](s S) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, Name(s))
+}
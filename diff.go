@@ -0,0 +1,152 @@
+package statemachine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MachineDiff is the structural difference between two machines, as
+// returned by Diff. Each list is sorted by state and event name.
+type MachineDiff[S State, E Event] struct {
+	AddedStates           []S
+	RemovedStates         []S
+	AddedTransitions      []Transition[S, E]
+	RemovedTransitions    []Transition[S, E]
+	RetargetedTransitions []RetargetedTransition[S, E]
+}
+
+// RetargetedTransition is a transition present in both machines that leads
+// to a different state in each
+type RetargetedTransition[S State, E Event] struct {
+	From  S
+	Event E
+	OldTo S
+	NewTo S
+}
+
+// Diff compares two machines, typically two releases of the same workflow,
+// and reports the states and transitions added, removed or retargeted in b
+// relative to a. Transitions are compared as declared, not as inherited
+// through the state hierarchy. Where several guarded transitions compete for
+// a state and event, the target of the first tried is compared.
+func Diff[S State, E Event](a, b *StateMachine[S, E]) MachineDiff[S, E] {
+	a.rlock()
+	aStates, aEdges := a.allStates(), a.declaredTargets()
+	a.runlock()
+	b.rlock()
+	bStates, bEdges := b.allStates(), b.declaredTargets()
+	b.runlock()
+
+	var d MachineDiff[S, E]
+	d.AddedStates = missingFrom(bStates, aStates)
+	d.RemovedStates = missingFrom(aStates, bStates)
+
+	for k, oldTo := range aEdges {
+		newTo, ok := bEdges[k]
+		switch {
+		case !ok:
+			d.RemovedTransitions = append(d.RemovedTransitions, Transition[S, E]{From: k.from, Event: k.event, To: oldTo})
+		case newTo != oldTo:
+			d.RetargetedTransitions = append(d.RetargetedTransitions, RetargetedTransition[S, E]{From: k.from, Event: k.event, OldTo: oldTo, NewTo: newTo})
+		}
+	}
+	for k, to := range bEdges {
+		if _, ok := aEdges[k]; !ok {
+			d.AddedTransitions = append(d.AddedTransitions, Transition[S, E]{From: k.from, Event: k.event, To: to})
+		}
+	}
+
+	sortStates(d.AddedStates)
+	sortStates(d.RemovedStates)
+	sortTransitions(d.AddedTransitions)
+	sortTransitions(d.RemovedTransitions)
+	sort.Slice(d.RetargetedTransitions, func(i, j int) bool {
+		x, y := d.RetargetedTransitions[i], d.RetargetedTransitions[j]
+		return transitionLess(x.From, x.Event, y.From, y.Event)
+	})
+	return d
+}
+
+// IsEmpty reports whether the two machines compared are structurally the same
+func (d MachineDiff[S, E]) IsEmpty() bool {
+	return len(d.AddedStates) == 0 && len(d.RemovedStates) == 0 &&
+		len(d.AddedTransitions) == 0 && len(d.RemovedTransitions) == 0 &&
+		len(d.RetargetedTransitions) == 0
+}
+
+// String formats the diff for review, one change per line prefixed with +
+// for additions, - for removals and ~ for retargeted transitions, such as
+// "~ Packing --Ship--> Shipped (was AwaitingCourier)".
+func (d MachineDiff[S, E]) String() string {
+	var b strings.Builder
+	for _, s := range d.AddedStates {
+		fmt.Fprintf(&b, "+ state %s\n", Name(s))
+	}
+	for _, s := range d.RemovedStates {
+		fmt.Fprintf(&b, "- state %s\n", Name(s))
+	}
+	for _, t := range d.AddedTransitions {
+		fmt.Fprintf(&b, "+ %s --%s--> %s\n", Name(t.From), Name(t.Event), Name(t.To))
+	}
+	for _, t := range d.RemovedTransitions {
+		fmt.Fprintf(&b, "- %s --%s--> %s\n", Name(t.From), Name(t.Event), Name(t.To))
+	}
+	for _, t := range d.RetargetedTransitions {
+		fmt.Fprintf(&b, "~ %s --%s--> %s (was %s)\n", Name(t.From), Name(t.Event), Name(t.NewTo), Name(t.OldTo))
+	}
+	return b.String()
+}
+
+type transitionKey[S State, E Event] struct {
+	from  S
+	event E
+}
+
+// declaredTargets returns the target of every declared transition, taking
+// the first tried where transitions compete
+func (sm *StateMachine[S, E]) declaredTargets() map[transitionKey[S, E]]S {
+	targets := make(map[transitionKey[S, E]]S)
+	for from, byEvent := range sm.transitions {
+		for event, edges := range byEvent {
+			targets[transitionKey[S, E]{from, event}] = edges[0].to
+		}
+	}
+	return targets
+}
+
+// missingFrom returns the states in a that are not in b
+func missingFrom[S State](a, b []S) []S {
+	inB := make(map[S]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+	var missing []S
+	for _, s := range a {
+		if !inB[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+func sortStates[S State](states []S) {
+	sort.Slice(states, func(i, j int) bool { return Name(states[i]) < Name(states[j]) })
+}
+
+func sortEvents[E Event](events []E) {
+	sort.Slice(events, func(i, j int) bool { return Name(events[i]) < Name(events[j]) })
+}
+
+func sortTransitions[S State, E Event](ts []Transition[S, E]) {
+	sort.Slice(ts, func(i, j int) bool {
+		return transitionLess(ts[i].From, ts[i].Event, ts[j].From, ts[j].Event)
+	})
+}
+
+func transitionLess[S State, E Event](fromA S, eventA E, fromB S, eventB E) bool {
+	if Name(fromA) != Name(fromB) {
+		return Name(fromA) < Name(fromB)
+	}
+	return Name(eventA) < Name(eventB)
+}
@@ -0,0 +1,65 @@
+package statemachine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	const returned OrderState = "Returned"
+	const returnEvent OrderEvent = "Return"
+
+	v1 := NewOrderStateMachine()
+	v2 := v1.Clone()
+	v2.AddTransition(OrderStateShipped, returnEvent, returned)
+	v2.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateAwaiting)
+	v1.AddTransition(OrderStateRefunded, OrderEventCancel, OrderStateCancelled)
+
+	d := Diff(v1, v2)
+
+	if want := []OrderState{returned}; !reflect.DeepEqual(d.AddedStates, want) {
+		t.Errorf("AddedStates = %v, want %v", d.AddedStates, want)
+	}
+	if len(d.RemovedStates) != 0 {
+		t.Errorf("RemovedStates = %v, want none", d.RemovedStates)
+	}
+	if want := []Transition[OrderState, OrderEvent]{{From: OrderStateShipped, Event: returnEvent, To: returned}}; !reflect.DeepEqual(d.AddedTransitions, want) {
+		t.Errorf("AddedTransitions = %v, want %v", d.AddedTransitions, want)
+	}
+	if want := []Transition[OrderState, OrderEvent]{{From: OrderStateRefunded, Event: OrderEventCancel, To: OrderStateCancelled}}; !reflect.DeepEqual(d.RemovedTransitions, want) {
+		t.Errorf("RemovedTransitions = %v, want %v", d.RemovedTransitions, want)
+	}
+	want := []RetargetedTransition[OrderState, OrderEvent]{{From: OrderStatePending, Event: OrderEventConfirm, OldTo: OrderStatePacking, NewTo: OrderStateAwaiting}}
+	if !reflect.DeepEqual(d.RetargetedTransitions, want) {
+		t.Errorf("RetargetedTransitions = %v, want %v", d.RetargetedTransitions, want)
+	}
+
+	wantString := "+ state Returned\n" +
+		"+ Shipped --Return--> Returned\n" +
+		"- Refunded --Cancel--> Cancelled\n" +
+		"~ Pending --Confirm--> AwaitingCourier (was Packing)\n"
+	if got := d.String(); got != wantString {
+		t.Errorf("String() = %q, want %q", got, wantString)
+	}
+	if d.IsEmpty() {
+		t.Errorf("IsEmpty() = true, want false")
+	}
+}
+
+func TestDiff_Identical(t *testing.T) {
+	if d := Diff(NewOrderStateMachine(), NewOrderStateMachine()); !d.IsEmpty() {
+		t.Errorf("Diff() of identical machines =\n%s", d)
+	}
+}
+
+func TestDiff_RemovedStatesSorted(t *testing.T) {
+	v1 := NewOrderStateMachine()
+	v2 := NewStateMachine[OrderState, OrderEvent]()
+	v2.AddTransition(OrderStatePending, OrderEventCancel, OrderStateCancelled)
+
+	d := Diff(v1, v2)
+	want := []OrderState{OrderStateAwaiting, OrderStateDelivered, OrderStatePacking, OrderStateProcessing, OrderStateRefunded, OrderStateShipped}
+	if !reflect.DeepEqual(d.RemovedStates, want) {
+		t.Errorf("RemovedStates = %v, want %v", d.RemovedStates, want)
+	}
+}
@@ -0,0 +1,130 @@
+package statemachine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DOTOptions configures ExportDOT
+type DOTOptions struct {
+	// Name is the name of the graph; it defaults to "statemachine"
+	Name string
+
+	// RankDir sets the direction the graph is laid out in: "TB", "LR", "BT"
+	// or "RL". It defaults to "LR".
+	RankDir string
+}
+
+// ExportDOT writes the machine to w as a Graphviz DOT digraph, so diagrams
+// can be generated from the code rather than kept up to date by hand:
+//
+//	var buf bytes.Buffer
+//	sm.ExportDOT(&buf, statemachine.DOTOptions{Name: "documents"})
+//	// dot -Tsvg documents.dot > documents.svg
+//
+// States are labelled with their metadata label if they have one, and
+// transitions with their event, or their metadata label. The initial state
+// is marked with an arrow from a point, final states are drawn with a double
+// border, and substates are drawn inside a cluster for their parent state.
+// Guarded transitions are dashed. The output is stable: the same machine
+// always produces the same text.
+func (sm *StateMachine[S, E]) ExportDOT(w io.Writer, opts DOTOptions) error {
+	sm.rlock()
+	d := sm.diagram()
+	sm.runlock()
+
+	if opts.Name == "" {
+		opts.Name = "statemachine"
+	}
+	if opts.RankDir == "" {
+		opts.RankDir = "LR"
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", strconv.Quote(opts.Name))
+	fmt.Fprintf(bw, "\trankdir=%s;\n", opts.RankDir)
+	if len(d.children) > 0 {
+		bw.WriteString("\tcompound=true;\n")
+	}
+	bw.WriteString("\tnode [shape=box, style=rounded];\n")
+
+	for _, s := range d.roots {
+		writeDOTState(bw, d, s, "\t")
+	}
+
+	if d.hasInitial {
+		bw.WriteString("\t\"__start\" [shape=point, label=\"\"];\n")
+		to, lhead := dotEndpoint(d, d.initial)
+		fmt.Fprintf(bw, "\t\"__start\" -> %s%s;\n", strconv.Quote(to), dotAttrs(lhead))
+	}
+	for _, e := range d.edges {
+		from, ltail := dotEndpoint(d, e.from)
+		to, lhead := dotEndpoint(d, e.to)
+
+		attrs := []string{"label=" + strconv.Quote(e.eventLabel())}
+		if e.guarded {
+			attrs = append(attrs, "style=dashed")
+		}
+		if ltail != "" {
+			attrs = append(attrs, "ltail="+strconv.Quote(ltail))
+		}
+		if lhead != "" {
+			attrs = append(attrs, "lhead="+strconv.Quote(lhead))
+		}
+		fmt.Fprintf(bw, "\t%s -> %s [%s];\n", strconv.Quote(from), strconv.Quote(to), strings.Join(attrs, ", "))
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// writeDOTState writes a state, or the cluster for a composite state and its
+// substates
+func writeDOTState[S State, E Event](w *bufio.Writer, d diagram[S, E], s S, indent string) {
+	children, composite := d.children[s]
+	if !composite {
+		attrs := "label=" + strconv.Quote(d.label(s))
+		if d.final[s] {
+			attrs += ", peripheries=2"
+		}
+		fmt.Fprintf(w, "%s%s [%s];\n", indent, strconv.Quote(Name(s)), attrs)
+		return
+	}
+
+	fmt.Fprintf(w, "%ssubgraph %s {\n", indent, strconv.Quote("cluster_"+Name(s)))
+	fmt.Fprintf(w, "%s\tlabel=%s;\n", indent, strconv.Quote(d.label(s)))
+	if d.final[s] {
+		fmt.Fprintf(w, "%s\tperipheries=2;\n", indent)
+	}
+	for _, child := range children {
+		writeDOTState(w, d, child, indent+"\t")
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+// dotEndpoint returns the node an arrow to or from state should be drawn
+// at. A composite state is drawn as a cluster, so arrows are drawn at its
+// first substate and clipped to the cluster, which is returned too.
+func dotEndpoint[S State, E Event](d diagram[S, E], state S) (node, cluster string) {
+	s := state
+	for {
+		children, ok := d.children[s]
+		if !ok {
+			break
+		}
+		s = children[0]
+	}
+	if s == state {
+		return Name(s), ""
+	}
+	return Name(s), "cluster_" + Name(state)
+}
+
+func dotAttrs(lhead string) string {
+	if lhead == "" {
+		return ""
+	}
+	return " [lhead=" + strconv.Quote(lhead) + "]"
+}
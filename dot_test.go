@@ -0,0 +1,69 @@
+package statemachine
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExportDOT(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateCancelled,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }))
+	sm.AddTransition(OrderStatePacking, OrderEventPack, OrderStateAwaiting,
+		WithMeta[OrderState, OrderEvent](Metadata{Label: "Packed"}))
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped)
+	sm.AddTransition(OrderStateProcessing, OrderEventCancel, OrderStateCancelled)
+	sm.SetParent(OrderStatePacking, OrderStateProcessing)
+	sm.SetParent(OrderStateAwaiting, OrderStateProcessing)
+	sm.SetStateMeta(OrderStateAwaiting, Metadata{Label: "Awaiting courier"})
+	sm.SetInitialState(OrderStatePending)
+	sm.AddFinalState(OrderStateShipped)
+
+	var b strings.Builder
+	if err := sm.ExportDOT(&b, DOTOptions{Name: "orders"}); err != nil {
+		t.Fatalf("ExportDOT() error = %v", err)
+	}
+
+	want := `digraph "orders" {
+	rankdir=LR;
+	compound=true;
+	node [shape=box, style=rounded];
+	"Cancelled" [label="Cancelled"];
+	"Pending" [label="Pending"];
+	subgraph "cluster_Processing" {
+		label="Processing";
+		"AwaitingCourier" [label="Awaiting courier"];
+		"Packing" [label="Packing"];
+	}
+	"Shipped" [label="Shipped", peripheries=2];
+	"__start" [shape=point, label=""];
+	"__start" -> "Pending";
+	"AwaitingCourier" -> "Shipped" [label="Ship"];
+	"Packing" -> "AwaitingCourier" [label="Packed"];
+	"Pending" -> "Cancelled" [label="Confirm", style=dashed];
+	"Pending" -> "Packing" [label="Confirm"];
+	"AwaitingCourier" -> "Cancelled" [label="Cancel", ltail="cluster_Processing"];
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("ExportDOT() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExportDOT_Defaults(t *testing.T) {
+	var b strings.Builder
+	if err := NewOrderStateMachine().ExportDOT(&b, DOTOptions{RankDir: "TB"}); err != nil {
+		t.Fatalf("ExportDOT() error = %v", err)
+	}
+	got := b.String()
+	for _, want := range []string{`digraph "statemachine" {`, "rankdir=TB;", `"Shipped" -> "Delivered" [label="Deliver"];`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ExportDOT() missing %q in\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "__start") {
+		t.Errorf("ExportDOT() drew an initial state for a machine without one")
+	}
+}
@@ -0,0 +1,126 @@
+package statemachine
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithDwellStats makes the machine gather how long its instances stay in
+// each state, for DwellStats. A stay is counted when an instance leaves the
+// state, so entities driven through a Manager, which transitions the
+// machine directly, are not counted.
+func WithDwellStats() Option {
+	return func(c *config) {
+		c.dwell = true
+	}
+}
+
+// DwellStat sums up the stays instances of a machine have made in a state
+type DwellStat[S State] struct {
+	State S
+
+	// Count is how many stays have ended, and Total, Min and Max their
+	// combined, shortest and longest durations
+	Count int
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// Mean returns the average length of a stay in the state
+func (d DwellStat[S]) Mean() time.Duration {
+	if d.Count == 0 {
+		return 0
+	}
+	return d.Total / time.Duration(d.Count)
+}
+
+// dwellStats gathers the stays made on a machine created WithDwellStats. It
+// has its own lock, so that stays are recorded even once frozen.
+type dwellStats[S State] struct {
+	mu    sync.Mutex
+	stats map[S]*DwellStat[S]
+}
+
+// record counts a stay of d in state. It does nothing on a nil dwellStats.
+func (s *dwellStats[S]) record(state S, d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.stats[state]
+	if !ok {
+		st = &DwellStat[S]{State: state, Min: d, Max: d}
+		s.stats[state] = st
+	}
+	st.Count++
+	st.Total += d
+	st.Min = min(st.Min, d)
+	st.Max = max(st.Max, d)
+}
+
+// DwellStats returns how long instances of a machine created
+// WithDwellStats have stayed in each state they have left, sorted by
+// state name, for dashboards and alerts on workflows that stall:
+//
+//	for _, st := range orderMachine.DwellStats() {
+//		dwellSeconds.WithLabelValues(st.State.String()).Set(st.Mean().Seconds())
+//	}
+//
+// States are counted as Instance.Current reports them, so time spent in a
+// composite state is counted against its substates. A machine created
+// without WithDwellStats returns nothing.
+func (sm *StateMachine[S, E]) DwellStats() []DwellStat[S] {
+	s := sm.dwell
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]DwellStat[S], 0, len(s.stats))
+	for _, st := range s.stats {
+		stats = append(stats, *st)
+	}
+	slices.SortFunc(stats, func(a, b DwellStat[S]) int {
+		return strings.Compare(Name(a.State), Name(b.State))
+	})
+	return stats
+}
+
+// EnteredAt returns when the instance entered its current state, by the
+// machine's clock. An instance restored from a Snapshot without the time
+// counts from the restore.
+func (i *Instance[S, E]) EnteredAt() time.Time {
+	return i.enteredAt
+}
+
+// TimeInCurrentState returns how long the instance has been in its current
+// state
+func (i *Instance[S, E]) TimeInCurrentState() time.Duration {
+	return i.sm.clock.Now().Sub(i.enteredAt)
+}
+
+// TimeInState returns how long the instance has spent in state altogether,
+// over every stay including the current one
+func (i *Instance[S, E]) TimeInState(state S) time.Duration {
+	d := i.dwell[state]
+	if state == i.current {
+		d += i.TimeInCurrentState()
+	}
+	return d
+}
+
+// leave counts the stay in the current state that ends at now
+func (i *Instance[S, E]) leave(now time.Time) {
+	d := now.Sub(i.enteredAt)
+	if i.dwell == nil {
+		i.dwell = make(map[S]time.Duration)
+	}
+	i.dwell[i.current] += d
+	i.sm.dwell.record(i.current, d)
+}
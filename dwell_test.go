@@ -0,0 +1,91 @@
+package statemachine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInstance_TimeInState(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := NewStateMachine[fulfilState, fulfilEvent](WithClock(clock))
+	sm.AddTransition("Open", "Hold", "OnHold")
+	sm.AddTransition("OnHold", "Release", "Open")
+	sm.AddTransition("Open", "Touch", "Open")
+	inst := sm.NewInstanceAt("Open")
+	ctx := context.Background()
+
+	steps := []struct {
+		wait  time.Duration
+		event fulfilEvent
+	}{
+		{2 * time.Hour, "Hold"},
+		{30 * time.Minute, "Release"},
+		{time.Hour, "Touch"},
+	}
+	for _, step := range steps {
+		clock.advance(step.wait)
+		if err := inst.Fire(ctx, step.event); err != nil {
+			t.Fatalf("Fire(%v) error = %v", step.event, err)
+		}
+	}
+	clock.advance(15 * time.Minute)
+
+	if got, want := inst.EnteredAt(), epoch.Add(3*time.Hour+30*time.Minute); !got.Equal(want) {
+		t.Errorf("EnteredAt() = %v, want %v", got, want)
+	}
+	if got := inst.TimeInCurrentState(); got != 15*time.Minute {
+		t.Errorf("TimeInCurrentState() = %v, want 15m", got)
+	}
+	for state, want := range map[fulfilState]time.Duration{
+		"Open":   3*time.Hour + 15*time.Minute,
+		"OnHold": 30 * time.Minute,
+		"Closed": 0,
+	} {
+		if got := inst.TimeInState(state); got != want {
+			t.Errorf("TimeInState(%v) = %v, want %v", state, got, want)
+		}
+	}
+}
+
+func TestStateMachine_DwellStats(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := NewOrderStateMachine()
+	if got := sm.DwellStats(); got != nil {
+		t.Errorf("DwellStats() without WithDwellStats = %v, want nil", got)
+	}
+
+	sm = NewStateMachine[OrderState, OrderEvent](WithClock(clock), WithDwellStats())
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+	sm.AddTransition(OrderStatePacking, OrderEventPack, OrderStateAwaiting)
+	sm.Freeze()
+	ctx := context.Background()
+
+	for _, wait := range []time.Duration{time.Hour, 3 * time.Hour} {
+		inst := sm.NewInstanceAt(OrderStatePending)
+		clock.advance(wait)
+		if err := inst.Fire(ctx, OrderEventConfirm); err != nil {
+			t.Fatal(err)
+		}
+		clock.advance(wait / 2)
+		if err := inst.Fire(ctx, OrderEventPack); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []DwellStat[OrderState]{
+		{State: OrderStatePacking, Count: 2, Total: 2 * time.Hour, Min: 30 * time.Minute, Max: 90 * time.Minute},
+		{State: OrderStatePending, Count: 2, Total: 4 * time.Hour, Min: time.Hour, Max: 3 * time.Hour},
+	}
+	got := sm.DwellStats()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("DwellStats() = %+v, want %+v", got, want)
+	}
+	if mean := got[1].Mean(); mean != 2*time.Hour {
+		t.Errorf("Mean() = %v, want 2h", mean)
+	}
+	if len(sm.Clone().DwellStats()) != 0 {
+		t.Error("Clone() copied the dwell stats gathered so far")
+	}
+}
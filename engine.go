@@ -0,0 +1,220 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"runtime"
+	"sync"
+)
+
+// ErrEngineClosed is returned for events submitted to an Engine after it
+// was closed
+var ErrEngineClosed = errors.New("engine closed")
+
+// engineQueueSize is how many events each of an engine's workers holds
+// before Submit blocks
+const engineQueueSize = 64
+
+// Engine processes events for many entities concurrently, through a
+// Manager, on a pool of workers. Events for the same entity always go to
+// the same worker, so they are processed one at a time in the order they
+// were submitted, while events for different entities run in parallel:
+//
+//	engine := statemachine.NewEngine(users, 8)
+//	defer engine.Close()
+//	results, err := engine.Submit(ctx, userID, UserEventVerifyEmail)
+//	...
+//	result := <-results
+//
+// An entity's events never race one another for its version, but they can
+// still conflict with writers outside the engine.
+type Engine[S State, E Event] struct {
+	// OnResult, if set, is called with the result of every event, on the
+	// worker that processed it. It must be set before events are
+	// submitted.
+	OnResult func(Result[S, E])
+
+	manager *Manager[S, E]
+	shards  []chan engineJob[S, E]
+	wg      sync.WaitGroup
+
+	// mu guards closed, and is held for reading while submitting so that
+	// Close does not close a shard being sent to
+	mu     sync.RWMutex
+	closed bool
+
+	events     chan EventEnvelope[S, E]
+	eventsOnce sync.Once
+}
+
+// Result is the outcome of an event processed by an Engine or in a batch
+type Result[S State, E Event] struct {
+	EntityID string
+	Event    E
+
+	// State is the entity's state after the event. If Err is set, it is
+	// the state the entity stayed in, or the zero state if the entity was
+	// not loaded.
+	State S
+	Err   error
+}
+
+// EventEnvelope is an event sent to an Engine through its Events channel
+type EventEnvelope[S State, E Event] struct {
+	// Context is passed to the transition. If nil, context.Background is
+	// used.
+	Context context.Context
+
+	EntityID string
+	Event    E
+
+	// IdempotencyKey, if set, is added to Context with WithIdempotencyKey,
+	// for a Manager with a DedupStore
+	IdempotencyKey string
+
+	// Reply, if set, receives the event's result. The entity's worker
+	// waits for it to be received, so it should be buffered or read
+	// promptly.
+	Reply chan<- Result[S, E]
+}
+
+type engineJob[S State, E Event] struct {
+	ctx     context.Context
+	id      string
+	event   E
+	results chan Result[S, E]
+	reply   chan<- Result[S, E]
+}
+
+// NewEngine starts an engine processing events through m on the given
+// number of workers, or one per CPU if workers is not positive
+func NewEngine[S State, E Event](m *Manager[S, E], workers int) *Engine[S, E] {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	e := &Engine[S, E]{manager: m, shards: make([]chan engineJob[S, E], workers)}
+	for n := range e.shards {
+		e.shards[n] = make(chan engineJob[S, E], engineQueueSize)
+		e.wg.Go(func() {
+			for job := range e.shards[n] {
+				e.process(job)
+			}
+		})
+	}
+	return e
+}
+
+// Submit queues event for the entity with the given ID, returning a
+// channel that receives its result once processed. It blocks while the
+// entity's worker is full, failing with the context's error if ctx is done
+// first, and fails with ErrEngineClosed once the engine is closed. ctx is
+// also passed to the transition, which fails with the context's error if
+// ctx is done before it starts.
+func (e *Engine[S, E]) Submit(ctx context.Context, id string, event E) (<-chan Result[S, E], error) {
+	job := engineJob[S, E]{ctx: ctx, id: id, event: event, results: make(chan Result[S, E], 1)}
+	if err := e.submit(job); err != nil {
+		return nil, err
+	}
+	return job.results, nil
+}
+
+func (e *Engine[S, E]) submit(job engineJob[S, E]) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.closed {
+		return ErrEngineClosed
+	}
+	select {
+	case e.shards[e.shard(job.id)] <- job:
+		return nil
+	case <-job.ctx.Done():
+		return job.ctx.Err()
+	}
+}
+
+// Events returns a channel for pushing events into the engine, for
+// pipelines built from channels:
+//
+//	replies := make(chan statemachine.Result[OrderState, OrderEvent], 100)
+//	events := engine.Events()
+//	for msg := range incoming {
+//		events <- statemachine.EventEnvelope[OrderState, OrderEvent]{EntityID: msg.OrderID, Event: msg.Event, Reply: replies}
+//	}
+//	close(events)
+//
+// Events are submitted in the order they are received, so each entity's
+// are processed in that order. An event that cannot be submitted, because
+// the engine is closed or its context is done, is reported on its Reply
+// channel. The engine never closes the channel; close it once nothing more
+// will be sent, to stop the goroutine reading it.
+func (e *Engine[S, E]) Events() chan<- EventEnvelope[S, E] {
+	e.eventsOnce.Do(func() {
+		e.events = make(chan EventEnvelope[S, E])
+		go func() {
+			for env := range e.events {
+				job := engineJob[S, E]{ctx: env.Context, id: env.EntityID, event: env.Event, reply: env.Reply}
+				if job.ctx == nil {
+					job.ctx = context.Background()
+				}
+				if env.IdempotencyKey != "" {
+					job.ctx = WithIdempotencyKey(job.ctx, env.IdempotencyKey)
+				}
+				if err := e.submit(job); err != nil && env.Reply != nil {
+					env.Reply <- Result[S, E]{EntityID: env.EntityID, Event: env.Event, Err: err}
+				}
+			}
+		}()
+	})
+	return e.events
+}
+
+// Fire submits event for the entity with the given ID and waits for its
+// result
+func (e *Engine[S, E]) Fire(ctx context.Context, id string, event E) (S, error) {
+	results, err := e.Submit(ctx, id, event)
+	if err != nil {
+		var zero S
+		return zero, err
+	}
+	r := <-results
+	return r.State, r.Err
+}
+
+// Close stops the engine accepting events, and waits for those already
+// submitted to be processed
+func (e *Engine[S, E]) Close() {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		for _, shard := range e.shards {
+			close(shard)
+		}
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+}
+
+// shard picks the worker for an entity
+func (e *Engine[S, E]) shard(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(len(e.shards)))
+}
+
+func (e *Engine[S, E]) process(job engineJob[S, E]) {
+	r := Result[S, E]{EntityID: job.id, Event: job.event}
+	if r.Err = job.ctx.Err(); r.Err == nil {
+		r.State, r.Err = e.manager.Fire(job.ctx, job.id, job.event)
+	}
+	if e.OnResult != nil {
+		e.OnResult(r)
+	}
+	if job.results != nil {
+		job.results <- r
+	}
+	if job.reply != nil {
+		job.reply <- r
+	}
+}
@@ -0,0 +1,135 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newCounterManager counts each entity up from 0 to steps, one Next at a
+// time, so events processed out of order or concurrently fail
+func newCounterManager(t *testing.T, steps int) *Manager[fulfilState, fulfilEvent] {
+	t.Helper()
+	sm := NewStateMachine[fulfilState, fulfilEvent]()
+	sm.SetInitialState("0")
+	for n := range steps {
+		sm.AddTransition(fulfilState(strconv.Itoa(n)), "Next", fulfilState(strconv.Itoa(n+1)))
+	}
+	return NewManager(sm, &MemoryStore[fulfilState]{})
+}
+
+func TestEngine(t *testing.T) {
+	const entities, steps = 20, 50
+	m := newCounterManager(t, steps)
+	ctx := context.Background()
+	for n := range entities {
+		if err := m.Create(ctx, strconv.Itoa(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	engine := NewEngine(m, 4)
+	var processed atomic.Int64
+	engine.OnResult = func(r Result[fulfilState, fulfilEvent]) { processed.Add(1) }
+
+	var wg sync.WaitGroup
+	for n := range entities {
+		id := strconv.Itoa(n)
+		wg.Go(func() {
+			var results []<-chan Result[fulfilState, fulfilEvent]
+			for range steps {
+				r, err := engine.Submit(ctx, id, "Next")
+				if err != nil {
+					t.Errorf("Submit() error = %v", err)
+					return
+				}
+				results = append(results, r)
+			}
+			for i, r := range results {
+				got := <-r
+				if want := fulfilState(strconv.Itoa(i + 1)); got.Err != nil || got.State != want || got.EntityID != id {
+					t.Errorf("entity %s event %d: got %+v, want %v", id, i, got, want)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	engine.Close()
+
+	if got := processed.Load(); got != entities*steps {
+		t.Errorf("OnResult called %d times, want %d", got, entities*steps)
+	}
+	if _, err := engine.Submit(ctx, "0", "Next"); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("Submit() after Close() error = %v, want %v", err, ErrEngineClosed)
+	}
+	engine.Close()
+}
+
+func TestEngine_Fire(t *testing.T) {
+	m := newCounterManager(t, 1)
+	engine := NewEngine(m, 0)
+	defer engine.Close()
+	ctx := context.Background()
+
+	if _, err := engine.Fire(ctx, "missing", "Next"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Fire() on an unknown entity error = %v, want %v", err, ErrNotFound)
+	}
+	if err := m.Create(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := engine.Fire(ctx, "1", "Next"); err != nil || state != "1" {
+		t.Errorf("Fire() = %v, %v, want 1", state, err)
+	}
+	if state, err := engine.Fire(ctx, "1", "Next"); !errors.Is(err, ErrInvalidTransition) || state != "1" {
+		t.Errorf("Fire() past the end = %v, %v, want 1 and %v", state, err, ErrInvalidTransition)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := engine.Fire(cancelled, "1", "Next"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Fire() with a cancelled context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestEngine_Events(t *testing.T) {
+	const steps = 10
+	m := newCounterManager(t, steps)
+	ctx := context.Background()
+	for _, id := range []string{"a", "b"} {
+		if err := m.Create(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine := NewEngine(m, 2)
+
+	replies := make(chan Result[fulfilState, fulfilEvent], 2*steps+1)
+	events := engine.Events()
+	if engine.Events() != events {
+		t.Error("Events() returned a different channel the second time")
+	}
+	for range steps {
+		for _, id := range []string{"a", "b"} {
+			events <- EventEnvelope[fulfilState, fulfilEvent]{EntityID: id, Event: "Next", Reply: replies}
+		}
+	}
+
+	last := map[string]int{}
+	for range 2 * steps {
+		r := <-replies
+		n, _ := strconv.Atoi(r.State.String())
+		if r.Err != nil || n != last[r.EntityID]+1 {
+			t.Fatalf("got %+v after %s reached %d", r, r.EntityID, last[r.EntityID])
+		}
+		last[r.EntityID] = n
+	}
+
+	engine.Close()
+	events <- EventEnvelope[fulfilState, fulfilEvent]{EntityID: "a", Event: "Next", Reply: replies}
+	if r := <-replies; !errors.Is(r.Err, ErrEngineClosed) || r.EntityID != "a" {
+		t.Errorf("reply after Close() = %+v, want %v", r, ErrEngineClosed)
+	}
+	close(events)
+}
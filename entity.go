@@ -0,0 +1,40 @@
+package statemachine
+
+import "context"
+
+// entityIDKey is the context key entity IDs are stored under
+type entityIDKey struct{}
+
+// WithEntityID returns a copy of ctx naming the entity a transition is for,
+// such as an order ID. The machine itself has no use for it, but guards,
+// actions, callbacks and listeners run with ctx can read it with
+// EntityIDFrom, so integrations such as webhooks can say which entity
+// changed:
+//
+//	ctx = statemachine.WithEntityID(ctx, strconv.FormatInt(order.ID, 10))
+//	newState, err := sm.TransitionContext(ctx, order.State, OrderEventShip)
+func WithEntityID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, entityIDKey{}, id)
+}
+
+// EntityIDFrom returns the entity ID carried by ctx, if there is one
+func EntityIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(entityIDKey{}).(string)
+	return id, ok
+}
+
+// actorKey is the context key actors are stored under
+type actorKey struct{}
+
+// WithActor returns a copy of ctx naming who is making a transition, such as
+// a user ID, for the same readers as WithEntityID. The history package
+// records it alongside each transition.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor carried by ctx, if there is one
+func ActorFrom(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
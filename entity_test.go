@@ -0,0 +1,40 @@
+package statemachine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEntityID(t *testing.T) {
+	if _, ok := EntityIDFrom(context.Background()); ok {
+		t.Error("EntityIDFrom() found an ID in an empty context")
+	}
+
+	sm := NewOrderStateMachine()
+	var got string
+	sm.Subscribe(func(ctx context.Context, from, to OrderState, event OrderEvent, at time.Time) {
+		got, _ = EntityIDFrom(ctx)
+	})
+
+	ctx := WithEntityID(context.Background(), "order-42")
+	if _, err := sm.TransitionContext(ctx, OrderStatePending, OrderEventConfirm); err != nil {
+		t.Fatal(err)
+	}
+	if got != "order-42" {
+		t.Errorf("listener entity ID = %q, want %q", got, "order-42")
+	}
+}
+
+func TestActor(t *testing.T) {
+	if _, ok := ActorFrom(context.Background()); ok {
+		t.Error("ActorFrom() found an actor in an empty context")
+	}
+	ctx := WithActor(WithEntityID(context.Background(), "order-42"), "alice")
+	if got, ok := ActorFrom(ctx); !ok || got != "alice" {
+		t.Errorf("ActorFrom() = %q, %v, want alice", got, ok)
+	}
+	if got, _ := EntityIDFrom(ctx); got != "order-42" {
+		t.Errorf("EntityIDFrom() = %q, want the entity ID kept", got)
+	}
+}
@@ -0,0 +1,140 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotEquivalent is matched by the *EquivalenceError returned by Equivalent
+// when two machines behave differently
+var ErrNotEquivalent = errors.New("machines are not equivalent")
+
+// EquivalenceError describes a difference in behaviour between two machines:
+// after the events in Path, which both machines accept, either Event is
+// accepted by only one of them, or, if Event is unset, one machine is in a
+// final state and the other is not.
+type EquivalenceError[E Event] struct {
+	Path []E
+
+	// Event is the event accepted by only one machine; it is unset when the
+	// machines differ in whether Path finishes
+	Event    E
+	HasEvent bool
+
+	// InFirst reports which machine accepts Event, or is in a final state,
+	// after Path: the first one passed to Equivalent, or the second
+	InFirst bool
+}
+
+func (e *EquivalenceError[E]) Error() string {
+	which := "second"
+	if e.InFirst {
+		which = "first"
+	}
+	after := "from the initial state"
+	if len(e.Path) > 0 {
+		names := make([]string, len(e.Path))
+		for i, ev := range e.Path {
+			names[i] = Name(ev)
+		}
+		after = "after " + strings.Join(names, ", ")
+	}
+	if e.HasEvent {
+		return fmt.Sprintf("%s: %s, event '%s' is only accepted by the %s machine", ErrNotEquivalent, after, Name(e.Event), which)
+	}
+	return fmt.Sprintf("%s: %s, only the %s machine is in a final state", ErrNotEquivalent, after, which)
+}
+
+func (e *EquivalenceError[E]) Unwrap() error {
+	return ErrNotEquivalent
+}
+
+// Equivalent checks that two machines behave the same, whatever their
+// states are called: starting from their initial states, they accept exactly
+// the same sequences of events, and reach a final state after the same
+// ones. It is meant for refactoring, such as renaming or collapsing states:
+//
+//	if err := statemachine.Equivalent(before, after); err != nil {
+//		t.Fatal(err) // machines are not equivalent: after Confirm, event 'Ship' is only accepted by the first machine
+//	}
+//
+// The machines may use different state types. Transitions are followed as
+// GetNextState would follow them, so guards are ignored, and a choice is
+// taken to lead to its first target. Equivalent returns nil when the
+// machines are equivalent, an *EquivalenceError with the shortest sequence
+// of events telling them apart when they are not, or an error if either has
+// no initial state.
+func Equivalent[SA, SB State, E Event](a *StateMachine[SA, E], b *StateMachine[SB, E]) error {
+	a.rlock()
+	defer a.runlock()
+	if a != any(b) {
+		b.rlock()
+		defer b.runlock()
+	}
+
+	if !a.hasInitialState || !b.hasInitialState {
+		return errors.New("cannot compare state machines: both need an initial state")
+	}
+
+	type pair struct {
+		a SA
+		b SB
+	}
+	type step struct {
+		prev  pair
+		event E
+	}
+	start := pair{
+		a.resolveTarget(a.initialState, NoHistory, nil),
+		b.resolveTarget(b.initialState, NoHistory, nil),
+	}
+	steps := make(map[pair]step)
+	seen := map[pair]bool{start: true}
+	queue := []pair{start}
+	path := func(p pair) []E {
+		var events []E
+		for p != start {
+			events = append(events, steps[p].event)
+			p = steps[p].prev
+		}
+		for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+			events[i], events[j] = events[j], events[i]
+		}
+		return events
+	}
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		if finalA, finalB := a.final[p.a], b.final[p.b]; finalA != finalB {
+			return &EquivalenceError[E]{Path: path(p), InFirst: finalA}
+		}
+
+		eventsA, eventsB := a.effectiveTransitions(p.a), b.effectiveTransitions(p.b)
+		for _, event := range a.validEvents(p.a) {
+			if _, ok := eventsB[event]; !ok {
+				return &EquivalenceError[E]{Path: path(p), Event: event, HasEvent: true, InFirst: true}
+			}
+		}
+		for _, event := range b.validEvents(p.b) {
+			if _, ok := eventsA[event]; !ok {
+				return &EquivalenceError[E]{Path: path(p), Event: event, HasEvent: true}
+			}
+		}
+
+		for _, event := range a.validEvents(p.a) {
+			next := pair{
+				a.resolveTarget(eventsA[event].to, NoHistory, nil),
+				b.resolveTarget(eventsB[event].to, NoHistory, nil),
+			}
+			if !seen[next] {
+				seen[next] = true
+				steps[next] = step{prev: p, event: event}
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package statemachine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// renamedState gives the order workflow different state names
+type renamedState int
+
+func (s renamedState) String() string {
+	return [...]string{"New", "Picking", "Ready", "OnTheWay", "Done", "Void", "Repaid"}[s]
+}
+
+func newRenamedOrderMachine() *StateMachine[renamedState, OrderEvent] {
+	sm := NewStateMachine[renamedState, OrderEvent]()
+	sm.AddTransitions([]Transition[renamedState, OrderEvent]{
+		{0, OrderEventConfirm, 1},
+		{1, OrderEventPack, 2},
+		{2, OrderEventShip, 3},
+		{3, OrderEventDeliver, 4},
+		{0, OrderEventCancel, 5},
+		{1, OrderEventCancel, 5},
+		{2, OrderEventCancel, 5},
+		{4, OrderEventRefund, 6},
+		{5, OrderEventRefund, 6},
+	})
+	sm.SetInitialState(0)
+	return sm
+}
+
+func TestEquivalent(t *testing.T) {
+	orders := NewOrderStateMachine()
+	orders.SetInitialState(OrderStatePending)
+
+	if err := Equivalent(orders, newRenamedOrderMachine()); err != nil {
+		t.Errorf("Equivalent() = %v, want nil", err)
+	}
+	if err := Equivalent(orders, orders); err != nil {
+		t.Errorf("Equivalent() of a machine with itself = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name  string
+		setup func(sm *StateMachine[renamedState, OrderEvent])
+		want  *EquivalenceError[OrderEvent]
+		msg   string
+	}{
+		{
+			name: "extra event",
+			setup: func(sm *StateMachine[renamedState, OrderEvent]) {
+				sm.AddTransition(3, OrderEventCancel, 5)
+			},
+			want: &EquivalenceError[OrderEvent]{
+				Path:     []OrderEvent{OrderEventConfirm, OrderEventPack, OrderEventShip},
+				Event:    OrderEventCancel,
+				HasEvent: true,
+			},
+			msg: "machines are not equivalent: after Confirm, Pack, Ship, event 'Cancel' is only accepted by the second machine",
+		},
+		{
+			name: "different target",
+			setup: func(sm *StateMachine[renamedState, OrderEvent]) {
+				sm.AddTransition(1, OrderEventPack, 3)
+			},
+			want: &EquivalenceError[OrderEvent]{
+				Path:     []OrderEvent{OrderEventConfirm, OrderEventPack},
+				Event:    OrderEventCancel,
+				HasEvent: true,
+				InFirst:  true,
+			},
+			msg: "machines are not equivalent: after Confirm, Pack, event 'Cancel' is only accepted by the first machine",
+		},
+		{
+			name: "final state",
+			setup: func(sm *StateMachine[renamedState, OrderEvent]) {
+				sm.AddFinalState(0)
+			},
+			want: &EquivalenceError[OrderEvent]{},
+			msg:  "machines are not equivalent: from the initial state, only the second machine is in a final state",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := newRenamedOrderMachine()
+			tt.setup(other)
+
+			err := Equivalent(orders, other)
+			if !errors.Is(err, ErrNotEquivalent) {
+				t.Fatalf("Equivalent() = %v, want ErrNotEquivalent", err)
+			}
+			var eerr *EquivalenceError[OrderEvent]
+			if !errors.As(err, &eerr) || !reflect.DeepEqual(eerr, tt.want) {
+				t.Errorf("Equivalent() = %#v, want %#v", err, tt.want)
+			}
+			if err.Error() != tt.msg {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.msg)
+			}
+		})
+	}
+}
+
+func TestEquivalent_NoInitialState(t *testing.T) {
+	if err := Equivalent(NewOrderStateMachine(), newRenamedOrderMachine()); err == nil || errors.Is(err, ErrNotEquivalent) {
+		t.Errorf("Equivalent() = %v, want an error about the initial state", err)
+	}
+}
@@ -0,0 +1,89 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidTransition is matched by every TransitionError
+	ErrInvalidTransition = errors.New("invalid transition")
+
+	// ErrUnknownState is matched by a TransitionError whose from state does
+	// not appear anywhere in the machine
+	ErrUnknownState = errors.New("unknown state")
+
+	// ErrGuardRejected is matched by a TransitionError for a transition that
+	// exists but whose guard did not pass
+	ErrGuardRejected = errors.New("guard rejected transition")
+
+	// ErrUnknownEvent is returned for an event named that the machine does
+	// not have, such as by Restore for a snapshot that schedules one
+	ErrUnknownEvent = errors.New("unknown event")
+
+	// ErrConflictingTransition is returned by AddTransitionStrict when a state
+	// and event already lead to a different state
+	ErrConflictingTransition = errors.New("conflicting transition")
+)
+
+// TransitionError is returned when an event cannot be processed from a state.
+// Its message lists the events that were valid, so it can be shown to
+// whoever sent the event as it is:
+//
+//	invalid transition: cannot process event 'Ship' from state 'Pending'; valid events: Cancel, Confirm
+//
+// Use errors.Is with ErrInvalidTransition, ErrUnknownState or ErrGuardRejected
+// to branch on the kind of failure, or errors.As to inspect the details.
+//
+// A frozen machine returns the same TransitionError each time a known state
+// and event are rejected, so one must not be modified.
+type TransitionError[S State, E Event] struct {
+	From  S
+	Event E
+
+	// ValidEvents are the events that could have been processed from From,
+	// sorted by name
+	ValidEvents []E
+
+	unknownState  bool
+	guardRejected bool
+}
+
+func (e *TransitionError[S, E]) Error() string {
+	var msg string
+	switch {
+	case e.guardRejected:
+		msg = fmt.Sprintf("invalid transition: guard rejected event '%s' from state '%s'", Name(e.Event), Name(e.From))
+	case e.unknownState:
+		return fmt.Sprintf("invalid transition: cannot process event '%s' from unknown state '%s'", Name(e.Event), Name(e.From))
+	default:
+		msg = fmt.Sprintf("invalid transition: cannot process event '%s' from state '%s'", Name(e.Event), Name(e.From))
+	}
+	if len(e.ValidEvents) == 0 {
+		return msg
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	b.WriteString("; valid events: ")
+	for i, event := range e.ValidEvents {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(Name(event))
+	}
+	return b.String()
+}
+
+// Is reports whether target is one of the sentinel errors describing e
+func (e *TransitionError[S, E]) Is(target error) bool {
+	switch target {
+	case ErrInvalidTransition:
+		return true
+	case ErrUnknownState:
+		return e.unknownState
+	case ErrGuardRejected:
+		return e.guardRejected
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package statemachine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransitionError_Sentinels(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	tests := []struct {
+		name        string
+		from        UserState
+		event       UserEvent
+		wantUnknown bool
+		wantValid   int
+	}{
+		{
+			name:      "known state, wrong event",
+			from:      UserStateInitial,
+			event:     UserEventCompleteProfile,
+			wantValid: 2,
+		},
+		{
+			name:      "terminal state",
+			from:      UserStateSignUpComplete,
+			event:     UserEventSubmitSignUp,
+			wantValid: 0,
+		},
+		{
+			name:        "unknown state",
+			from:        UserState("Banned"),
+			event:       UserEventSubmitSignUp,
+			wantUnknown: true,
+			wantValid:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sm.Transition(tt.from, tt.event)
+
+			if !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("errors.Is(err, ErrInvalidTransition) = false for %v", err)
+			}
+			if got := errors.Is(err, ErrUnknownState); got != tt.wantUnknown {
+				t.Errorf("errors.Is(err, ErrUnknownState) = %v, want %v", got, tt.wantUnknown)
+			}
+
+			var te *TransitionError[UserState, UserEvent]
+			if !errors.As(err, &te) {
+				t.Fatalf("errors.As() failed for %T", err)
+			}
+			if te.From != tt.from || te.Event != tt.event {
+				t.Errorf("TransitionError = {%v, %v}, want {%v, %v}", te.From, te.Event, tt.from, tt.event)
+			}
+			if len(te.ValidEvents) != tt.wantValid {
+				t.Errorf("ValidEvents = %v, want %d events", te.ValidEvents, tt.wantValid)
+			}
+		})
+	}
+}
+
+func TestTransitionError_WrappedInPath(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	_, err := sm.ValidateTransitionPath(UserStateInitial, []UserEvent{UserEventSubmitSignUp, UserEventCompleteProfile})
+
+	var te *TransitionError[UserState, UserEvent]
+	if !errors.As(err, &te) {
+		t.Fatalf("errors.As() failed for %v", err)
+	}
+	if te.From != UserStateEmailPendingVerification {
+		t.Errorf("TransitionError.From = %v, want %v", te.From, UserStateEmailPendingVerification)
+	}
+}
+
+func TestTransitionError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *TransitionError[OrderState, OrderEvent]
+		want string
+	}{
+		{
+			name: "valid events",
+			err:  &TransitionError[OrderState, OrderEvent]{From: OrderStatePending, Event: OrderEventShip, ValidEvents: []OrderEvent{OrderEventCancel, OrderEventConfirm}},
+			want: "invalid transition: cannot process event 'Ship' from state 'Pending'; valid events: Cancel, Confirm",
+		},
+		{
+			name: "no valid events",
+			err:  &TransitionError[OrderState, OrderEvent]{From: OrderStateRefunded, Event: OrderEventShip},
+			want: "invalid transition: cannot process event 'Ship' from state 'Refunded'",
+		},
+		{
+			name: "guard rejected",
+			err:  &TransitionError[OrderState, OrderEvent]{From: OrderStateShipped, Event: OrderEventDeliver, ValidEvents: []OrderEvent{OrderEventDeliver}, guardRejected: true},
+			want: "invalid transition: guard rejected event 'Deliver' from state 'Shipped'; valid events: Deliver",
+		},
+		{
+			name: "unknown state",
+			err:  &TransitionError[OrderState, OrderEvent]{From: "Lost", Event: OrderEventShip, unknownState: true},
+			want: "invalid transition: cannot process event 'Ship' from unknown state 'Lost'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
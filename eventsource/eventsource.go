@@ -0,0 +1,171 @@
+// Package eventsource keeps an entity's state as an append-only log of the
+// events it has processed, rather than a value updated in place. The current
+// state is derived by replaying the log, and an event is only appended once
+// the machine accepts it:
+//
+//	users := eventsource.NewStream(NewUserStateMachine(), log)
+//	state, err := users.Append(ctx, userID, UserEventVerifyEmail)
+//	...
+//	state, err = users.State(ctx, userID)
+//
+// Each Entry records the state its event led to as well as the event, since
+// guards and choices decided that when it was appended and cannot be
+// consulted again on replay. Replay still checks every event was valid from
+// the state before it and could have led to the state recorded, so a log
+// that has been edited or reordered, or outlived a change to the machine, is
+// caught.
+//
+// Appending only simulates the transition: guards and choice resolvers run,
+// but actions, callbacks, middleware and subscribers do not. Side effects
+// belong to whatever reads the log.
+package eventsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// ErrConflict is returned by a Log when an entity's log has grown since it
+// was read, because another event was appended concurrently. Reading the
+// state again and retrying resolves it.
+var ErrConflict = errors.New("event log changed concurrently")
+
+// Entry is an event in an entity's log
+type Entry[S statemachine.State, E statemachine.Event] struct {
+	Event E
+
+	// To is the state the event led to
+	To S
+
+	At time.Time
+}
+
+// Log stores the entries of each entity in order
+type Log[S statemachine.State, E statemachine.Event] interface {
+	// Load returns an entity's entries, oldest first; an entity with no
+	// log has none
+	Load(ctx context.Context, id string) ([]Entry[S, E], error)
+
+	// Append adds an entry to an entity's log, provided the log holds
+	// version entries, and returns ErrConflict otherwise
+	Append(ctx context.Context, id string, version int, entry Entry[S, E]) error
+}
+
+// Replay returns the state reached by the entries from start. It fails,
+// matching statemachine.ErrInvalidTransition, at the first entry whose event
+// the machine would not have accepted, or whose state the event could not
+// have led to.
+func Replay[S statemachine.State, E statemachine.Event](sm *statemachine.StateMachine[S, E], start S, entries []Entry[S, E]) (S, error) {
+	current := start
+	for i, entry := range entries {
+		if _, err := sm.ValidateTransitionPath(current, []E{entry.Event}); err != nil {
+			return current, fmt.Errorf("cannot replay entry %d: %w", i+1, errors.Unwrap(err))
+		}
+		if !slices.Contains(sm.GetTargets(current, entry.Event), entry.To) {
+			return current, fmt.Errorf("cannot replay entry %d: %w: event '%s' from state '%s' cannot lead to '%s'",
+				i+1, statemachine.ErrInvalidTransition, statemachine.Name(entry.Event), statemachine.Name(current), statemachine.Name(entry.To))
+		}
+		current = entry.To
+	}
+	return current, nil
+}
+
+// Stream derives the state of entities from their logs. Create one with
+// NewStream.
+type Stream[S statemachine.State, E statemachine.Event] struct {
+	Machine *statemachine.StateMachine[S, E]
+	Log     Log[S, E]
+
+	// Start is the state of an entity before its first event
+	Start S
+}
+
+// NewStream returns a stream keeping the logs of sm's entities in log,
+// starting each entity in the machine's initial state. It panics if the
+// machine has none.
+func NewStream[S statemachine.State, E statemachine.Event](sm *statemachine.StateMachine[S, E], log Log[S, E]) *Stream[S, E] {
+	start, ok := sm.InitialState()
+	if !ok {
+		panic("eventsource: NewStream called on a machine with no initial state")
+	}
+	return &Stream[S, E]{Machine: sm, Log: log, Start: start}
+}
+
+// State replays an entity's log to return its current state
+func (s *Stream[S, E]) State(ctx context.Context, id string) (S, error) {
+	state, _, err := s.load(ctx, id)
+	return state, err
+}
+
+// Append validates event against the entity's current state and, if the
+// machine accepts it, adds it to the log. It returns the new state, or an
+// error from the machine, such as a TransitionError, or from the Log.
+func (s *Stream[S, E]) Append(ctx context.Context, id string, event E) (S, error) {
+	from, version, err := s.load(ctx, id)
+	if err != nil {
+		return from, err
+	}
+
+	ctx = statemachine.WithEntityID(ctx, id)
+	trace, err := s.Machine.SimulateContext(ctx, from, []E{event})
+	if err != nil {
+		// drop Simulate's mention of the step, there being only one
+		if inner := errors.Unwrap(err); inner != nil {
+			err = inner
+		}
+		return from, err
+	}
+	to := trace[0].To
+
+	if err := s.Log.Append(ctx, id, version, Entry[S, E]{Event: event, To: to, At: s.Machine.Clock().Now()}); err != nil {
+		return from, err
+	}
+	return to, nil
+}
+
+// load returns an entity's state and the length of its log
+func (s *Stream[S, E]) load(ctx context.Context, id string) (S, int, error) {
+	entries, err := s.Log.Load(ctx, id)
+	if err != nil {
+		return s.Start, 0, err
+	}
+	state, err := Replay(s.Machine, s.Start, entries)
+	if err != nil {
+		return state, 0, fmt.Errorf("entity %s: %w", id, err)
+	}
+	return state, len(entries), nil
+}
+
+// MemoryLog is a Log held in memory, for tests and short-lived processes.
+// The zero value is ready to use.
+type MemoryLog[S statemachine.State, E statemachine.Event] struct {
+	mu      sync.Mutex
+	entries map[string][]Entry[S, E]
+}
+
+// Load returns an entity's entries
+func (l *MemoryLog[S, E]) Load(ctx context.Context, id string) ([]Entry[S, E], error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Entry[S, E](nil), l.entries[id]...), nil
+}
+
+// Append adds an entry to an entity's log if it holds version entries
+func (l *MemoryLog[S, E]) Append(ctx context.Context, id string, version int, entry Entry[S, E]) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries[id]) != version {
+		return ErrConflict
+	}
+	if l.entries == nil {
+		l.entries = make(map[string][]Entry[S, E])
+	}
+	l.entries[id] = append(l.entries[id], entry)
+	return nil
+}
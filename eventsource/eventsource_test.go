@@ -0,0 +1,166 @@
+package eventsource_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/eventsource"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+// clock is a statemachine.Clock stopped at a fixed time
+type clock struct {
+	now time.Time
+}
+
+func (c clock) Now() time.Time { return c.now }
+
+func (c clock) AfterFunc(d time.Duration, f func()) statemachine.Timer { return time.AfterFunc(d, f) }
+
+var epoch = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newMachine(actions *int) *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event](statemachine.WithClock(clock{epoch}))
+	sm.SetInitialState("Unverified")
+	sm.AddTransition("Unverified", "Verify", "Active",
+		statemachine.WithAction(func(ctx context.Context, from, to state, e event) error {
+			*actions++
+			return nil
+		}))
+	sm.AddTransition("Active", "Suspend", "Suspended",
+		statemachine.WithGuard(statemachine.PayloadGuard(func(ctx context.Context, from state, e event, reason string) bool {
+			return reason != ""
+		})))
+	sm.AddTransition("Suspended", "Reinstate", "Active")
+	sm.AddChoice("Active", "Review", func(ctx context.Context, from state, e event) (state, error) {
+		return "Suspended", nil
+	}, []state{"Active", "Suspended"})
+	return sm
+}
+
+func TestStream(t *testing.T) {
+	var actions int
+	log := &eventsource.MemoryLog[state, event]{}
+	users := eventsource.NewStream(newMachine(&actions), log)
+	ctx := context.Background()
+
+	if got, err := users.State(ctx, "u1"); err != nil || got != "Unverified" {
+		t.Fatalf("State() of new entity = %v, %v, want Unverified", got, err)
+	}
+
+	steps := []struct {
+		ctx     context.Context
+		event   event
+		want    state
+		wantErr error
+	}{
+		{ctx, "Verify", "Active", nil},
+		{ctx, "Verify", "Active", statemachine.ErrInvalidTransition},
+		{ctx, "Suspend", "Active", statemachine.ErrGuardRejected},
+		{statemachine.WithPayload(ctx, "spam"), "Suspend", "Suspended", nil},
+		{ctx, "Reinstate", "Active", nil},
+		{ctx, "Review", "Suspended", nil},
+	}
+	for i, step := range steps {
+		got, err := users.Append(step.ctx, "u1", step.event)
+		if !errors.Is(err, step.wantErr) || got != step.want {
+			t.Fatalf("step %d: Append(%s) = %v, %v, want %v, %v", i+1, step.event, got, err, step.want, step.wantErr)
+		}
+	}
+
+	if got, err := users.State(ctx, "u1"); err != nil || got != "Suspended" {
+		t.Errorf("State() = %v, %v, want Suspended", got, err)
+	}
+	entries, _ := log.Load(ctx, "u1")
+	if len(entries) != 4 || entries[3].To != "Suspended" || !entries[3].At.Equal(epoch) {
+		t.Errorf("log = %+v, want the four accepted events at the machine's time", entries)
+	}
+	if actions != 0 {
+		t.Errorf("actions ran %d times, want none", actions)
+	}
+}
+
+func TestStream_Conflict(t *testing.T) {
+	var actions int
+	log := &eventsource.MemoryLog[state, event]{}
+	users := eventsource.NewStream(newMachine(&actions), log)
+	ctx := context.Background()
+
+	// another writer appends between our read and write
+	if err := log.Append(ctx, "u1", 0, eventsource.Entry[state, event]{Event: "Verify", To: "Active"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Append(ctx, "u1", 0, eventsource.Entry[state, event]{Event: "Verify", To: "Active"}); !errors.Is(err, eventsource.ErrConflict) {
+		t.Errorf("Append() at stale version error = %v, want %v", err, eventsource.ErrConflict)
+	}
+	if got, err := users.Append(ctx, "u1", "Review"); err != nil || got != "Suspended" {
+		t.Errorf("Append() = %v, %v, want Suspended", got, err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var actions int
+	sm := newMachine(&actions)
+	type entry = eventsource.Entry[state, event]
+
+	tests := []struct {
+		name    string
+		entries []entry
+		want    state
+		wantErr string
+	}{
+		{name: "empty", want: "Unverified"},
+		{
+			name:    "valid",
+			entries: []entry{{Event: "Verify", To: "Active"}, {Event: "Review", To: "Active"}, {Event: "Suspend", To: "Suspended"}},
+			want:    "Suspended",
+		},
+		{
+			name:    "reordered",
+			entries: []entry{{Event: "Verify", To: "Active"}, {Event: "Reinstate", To: "Active"}},
+			want:    "Active",
+			wantErr: "cannot replay entry 2: invalid transition: cannot process event 'Reinstate' from state 'Active'",
+		},
+		{
+			name:    "state the event cannot lead to",
+			entries: []entry{{Event: "Verify", To: "Active"}, {Event: "Suspend", To: "Unverified"}},
+			want:    "Active",
+			wantErr: "cannot replay entry 2: invalid transition: event 'Suspend' from state 'Active' cannot lead to 'Unverified'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := eventsource.Replay(sm, "Unverified", tt.entries)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Replay() error = %v, want %q", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, statemachine.ErrInvalidTransition) {
+				t.Errorf("Replay() error = %v, want it to match ErrInvalidTransition", err)
+			}
+			if got != tt.want {
+				t.Errorf("Replay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewStream_NoInitialState(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("NewStream() did not panic for a machine with no initial state")
+		}
+	}()
+	eventsource.NewStream(statemachine.NewStateMachine[state, event](), &eventsource.MemoryLog[state, event]{})
+}
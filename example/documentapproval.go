@@ -18,10 +18,6 @@ const (
 	DocumentStateArchived  DocumentState = "Archived"
 )
 
-func (s DocumentState) String() string {
-	return string(s)
-}
-
 type DocumentEvent string
 
 const (
@@ -34,10 +30,6 @@ const (
 	DocumentEventRevise  DocumentEvent = "Revise"
 )
 
-func (e DocumentEvent) String() string {
-	return string(e)
-}
-
 func NewDocumentStateMachine() *ss.StateMachine[DocumentState, DocumentEvent] {
 	sm := ss.NewStateMachine[DocumentState, DocumentEvent]()
 
@@ -20,10 +20,6 @@ const (
 	OrderStateRefunded   OrderState = "Refunded"
 )
 
-func (s OrderState) String() string {
-	return string(s)
-}
-
 type OrderEvent string
 
 const (
@@ -34,10 +30,6 @@ const (
 	OrderEventRefund  OrderEvent = "Refund"
 )
 
-func (e OrderEvent) String() string {
-	return string(e)
-}
-
 // Order represents an order in your system
 type Order struct {
 	ID     int64
@@ -139,4 +131,7 @@ func RunAllExamples() {
 	fmt.Println("\n=== Document State Machine ===")
 	ExampleDocumentStateMachine()
 
+	fmt.Println("\n=== Ticket State Machine ===")
+	ExampleTicketStateMachine()
+
 }
@@ -0,0 +1,66 @@
+package example
+
+import (
+	"context"
+	"fmt"
+
+	ss "github.com/richardbowden/statemachine"
+)
+
+// ==================== EXAMPLE: GENERATED TICKET STATE MACHINE ====================
+
+// The ticket states, events and NewTicketStateMachine are generated from
+// ticket.yaml; run go generate after editing the definition.
+//go:generate go run github.com/richardbowden/statemachine/cmd/statemachine generate -def ticket.yaml -o ticket_statemachine.go
+
+// Ticket represents a support ticket in your system
+type Ticket struct {
+	ID        int64
+	State     TicketState
+	OpenTasks int
+}
+
+type ticketKey struct{}
+
+type TicketService struct {
+	stateMachine *ss.StateMachine[TicketState, TicketEvent]
+}
+
+func NewTicketService() *TicketService {
+	return &TicketService{
+		stateMachine: NewTicketStateMachine(TicketGuards{
+			// A ticket can only be resolved once its tasks are done
+			AllTasksDone: func(ctx context.Context, from TicketState, event TicketEvent) bool {
+				ticket, ok := ctx.Value(ticketKey{}).(*Ticket)
+				return ok && ticket.OpenTasks == 0
+			},
+		}),
+	}
+}
+
+func (ts *TicketService) TransitionTicket(ctx context.Context, ticket *Ticket, event TicketEvent) error {
+	ctx = context.WithValue(ctx, ticketKey{}, ticket)
+	newState, err := ts.stateMachine.TransitionContext(ctx, ticket.State, event)
+	if err != nil {
+		return err
+	}
+	ticket.State = newState
+	return nil
+}
+
+func ExampleTicketStateMachine() {
+	ts := NewTicketService()
+	ticket := &Ticket{ID: 1, State: TicketStateOpen, OpenTasks: 1}
+
+	for _, event := range []TicketEvent{TicketEventAssign, TicketEventResolve} {
+		if err := ts.TransitionTicket(context.Background(), ticket, event); err != nil {
+			fmt.Printf("Expected error: %v\n", err) // tasks still open
+		}
+	}
+
+	ticket.OpenTasks = 0
+	if err := ts.TransitionTicket(context.Background(), ticket, TicketEventResolve); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	fmt.Printf("Ticket state: %s\n", ticket.State) // Resolved
+}
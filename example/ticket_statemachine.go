@@ -0,0 +1,79 @@
+// Code generated by statemachine generate from ticket.yaml. DO NOT EDIT.
+
+package example
+
+import "github.com/richardbowden/statemachine"
+
+// TicketState is a state of the ticket state machine
+type TicketState string
+
+const (
+	// Raised by a customer and waiting for an agent.
+	TicketStateOpen              TicketState = "Open"
+	TicketStateInProgress        TicketState = "InProgress"
+	TicketStateWaitingOnCustomer TicketState = "WaitingOnCustomer"
+	TicketStateResolved          TicketState = "Resolved"
+)
+
+func (s TicketState) String() string {
+	return string(s)
+}
+
+// TicketEvent is an event of the ticket state machine
+type TicketEvent string
+
+const (
+	TicketEventAssign          TicketEvent = "Assign"
+	TicketEventAskCustomer     TicketEvent = "AskCustomer"
+	TicketEventCustomerReplied TicketEvent = "CustomerReplied"
+	// The agent has fixed the problem.
+	TicketEventResolve TicketEvent = "Resolve"
+)
+
+func (e TicketEvent) String() string {
+	return string(e)
+}
+
+// AllTicketStates lists every state in the order it is declared
+var AllTicketStates = []TicketState{
+	TicketStateOpen,
+	TicketStateInProgress,
+	TicketStateWaitingOnCustomer,
+	TicketStateResolved,
+}
+
+// AllTicketEvents lists every event in the order it is declared
+var AllTicketEvents = []TicketEvent{
+	TicketEventAssign,
+	TicketEventAskCustomer,
+	TicketEventCustomerReplied,
+	TicketEventResolve,
+}
+
+// TicketGuards supplies the guards named by the definition; every field must be set
+type TicketGuards struct {
+	AllTasksDone statemachine.Guard[TicketState, TicketEvent]
+}
+
+// NewTicketStateMachine creates the ticket state machine
+func NewTicketStateMachine(guards TicketGuards, opts ...statemachine.Option) *statemachine.StateMachine[TicketState, TicketEvent] {
+	if guards.AllTasksDone == nil {
+		panic("statemachine: guard allTasksDone is not set")
+	}
+	sm := statemachine.NewStateMachine[TicketState, TicketEvent](opts...)
+	sm.DeclareStates(AllTicketStates...)
+	sm.DeclareEvents(AllTicketEvents...)
+
+	sm.AddTransition(TicketStateOpen, TicketEventAssign, TicketStateInProgress)
+	sm.AddTransition(TicketStateInProgress, TicketEventAskCustomer, TicketStateWaitingOnCustomer)
+	sm.AddTransition(TicketStateWaitingOnCustomer, TicketEventCustomerReplied, TicketStateInProgress)
+	sm.AddTransition(TicketStateInProgress, TicketEventResolve, TicketStateResolved,
+		statemachine.WithMeta[TicketState, TicketEvent](statemachine.Metadata{Label: "Resolve ticket"}),
+		statemachine.WithGuard(guards.AllTasksDone))
+
+	sm.SetInitialState(TicketStateOpen)
+	sm.AddFinalState(TicketStateResolved)
+	sm.SetStateMeta(TicketStateOpen, statemachine.Metadata{Description: "Raised by a customer and waiting for an agent."})
+	sm.SetStateMeta(TicketStateResolved, statemachine.Metadata{Tags: []string{"closed"}})
+	return sm
+}
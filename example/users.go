@@ -18,11 +18,6 @@ const (
 	UserStateRejected                 UserState = "SignupRejected"
 )
 
-// String implements the State interface
-func (s UserState) String() string {
-	return string(s)
-}
-
 type UserEvent string
 
 const (
@@ -32,11 +27,6 @@ const (
 	UserEventCompleteProfile       UserEvent = "CompleteProfile"
 )
 
-// String implements the Event interface
-func (e UserEvent) String() string {
-	return string(e)
-}
-
 type UserStateMachine = ss.StateMachine[UserState, UserEvent]
 
 func NewUserStateMachine() *UserStateMachine {
@@ -45,18 +35,21 @@ func NewUserStateMachine() *UserStateMachine {
 	// Define all valid transitions using the generic AddTransitions method
 	sm.AddTransitions([]ss.Transition[UserState, UserEvent]{
 		// From Initial state
-		{UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification},
-		{UserStateInitial, UserEventSignupFailed, UserStateRejected},
+		{From: UserStateInitial, Event: UserEventSubmitSignUp, To: UserStateEmailPendingVerification},
 
 		// From EmailPendingVerification state
-		{UserStateEmailPendingVerification, UserEventClickVerificationLink, UserStateEmailVerified},
-		{UserStateEmailPendingVerification, UserEventSignupFailed, UserStateRejected},
+		{From: UserStateEmailPendingVerification, Event: UserEventClickVerificationLink, To: UserStateEmailVerified},
 
 		// From EmailVerified state
-		{UserStateEmailVerified, UserEventCompleteProfile, UserStateSignUpComplete},
-		{UserStateEmailVerified, UserEventSignupFailed, UserStateRejected},
+		{From: UserStateEmailVerified, Event: UserEventCompleteProfile, To: UserStateSignUpComplete},
 	})
 
+	// Rejecting a signup, from any state before it completes, needs permission
+	for _, from := range []UserState{UserStateInitial, UserStateEmailPendingVerification, UserStateEmailVerified} {
+		sm.AddTransition(from, UserEventSignupFailed, UserStateRejected,
+			ss.RequirePermission[UserState, UserEvent](PermissionRejectSignup))
+	}
+
 	return sm
 }
 
@@ -78,6 +71,7 @@ type User struct {
 	Username       string
 	EMail          string
 	State          UserState
+	Version        int64
 	HashedPassword string
 	Enabled        bool
 	CreatedOn      time.Time
@@ -99,7 +93,12 @@ type UserRepository interface {
 	DoesUserExist(ctx context.Context, email string, username string) (bool, bool, error)
 	GetByID(ctx context.Context, id int64) (User, error)
 	GetByEmail(ctx context.Context, email string) (User, error)
-	UpdateState(ctx context.Context, userID int64, newState UserState) error
+
+	// UpdateState sets a user's state if the user is still at version,
+	// incrementing it, and returns ss.ErrConcurrentModification otherwise:
+	//
+	//	UPDATE users SET state = $1, version = version + 1 WHERE id = $2 AND version = $3
+	UpdateState(ctx context.Context, userID int64, version int64, newState UserState) error
 }
 
 type NewUserRequest struct {
@@ -112,7 +111,30 @@ type NewUserRequest struct {
 	Password2  string `json:"pwd2" validate:"eqfieldsecure=Password1"`
 }
 
-type Authz struct{}
+// PermissionRejectSignup is needed to reject a signup
+const PermissionRejectSignup = "users:reject"
+
+// Authz maps the roles users hold to the permissions they grant
+type Authz struct {
+	roles map[string][]string
+}
+
+func NewAuthz(roles map[string][]string) *Authz {
+	return &Authz{roles: roles}
+}
+
+// Principal returns who a user holding the given roles is to the state
+// machine, to carry in the context of the events they process:
+//
+//	ctx = ss.WithPrincipal(ctx, authz.Principal("support"))
+//	err := users.RejectSignup(ctx, userID)
+func (a *Authz) Principal(roles ...string) ss.Principal {
+	var p ss.Permissions
+	for _, role := range roles {
+		p = append(p, a.roles[role]...)
+	}
+	return p
+}
 
 type UserService struct {
 	repo         UserRepository
@@ -129,19 +151,23 @@ func NewUserService(repo UserRepository, authz *Authz) (*UserService, error) {
 	return us, nil
 }
 
-// ProcessEvent handles state transitions for users
+// ProcessEvent handles state transitions for users. If another request
+// changed the user after it was read, it fails with
+// ss.ErrConcurrentModification rather than overwriting that change. Events
+// needing a permission the principal in ctx lacks fail with
+// ss.ErrNotAuthorized.
 func (us *UserService) ProcessEvent(ctx context.Context, userID int64, event UserEvent) error {
 	user, err := us.repo.GetByID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 
-	newState, err := us.stateMachine.Transition(user.State, event)
+	newState, err := us.stateMachine.TransitionContext(ctx, user.State, event)
 	if err != nil {
 		return fmt.Errorf("invalid state transition: %w", err)
 	}
 
-	err = us.repo.UpdateState(ctx, userID, newState)
+	err = us.repo.UpdateState(ctx, userID, user.Version, newState)
 	if err != nil {
 		return fmt.Errorf("failed to update user state: %w", err)
 	}
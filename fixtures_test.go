@@ -0,0 +1,56 @@
+package statemachine
+
+type OrderState string
+
+const (
+	OrderStatePending    OrderState = "Pending"
+	OrderStateProcessing OrderState = "Processing"
+	OrderStatePacking    OrderState = "Packing"
+	OrderStateAwaiting   OrderState = "AwaitingCourier"
+	OrderStateShipped    OrderState = "Shipped"
+	OrderStateDelivered  OrderState = "Delivered"
+	OrderStateCancelled  OrderState = "Cancelled"
+	OrderStateRefunded   OrderState = "Refunded"
+)
+
+func (s OrderState) String() string {
+	return string(s)
+}
+
+type OrderEvent string
+
+const (
+	OrderEventConfirm OrderEvent = "Confirm"
+	OrderEventPack    OrderEvent = "Pack"
+	OrderEventShip    OrderEvent = "Ship"
+	OrderEventDeliver OrderEvent = "Deliver"
+	OrderEventCancel  OrderEvent = "Cancel"
+	OrderEventRefund  OrderEvent = "Refund"
+)
+
+func (e OrderEvent) String() string {
+	return string(e)
+}
+
+// NewOrderStateMachine builds an order workflow where Packing and
+// AwaitingCourier are substates of Processing
+func NewOrderStateMachine() *StateMachine[OrderState, OrderEvent] {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+
+	sm.AddTransitions([]Transition[OrderState, OrderEvent]{
+		{From: OrderStatePending, Event: OrderEventConfirm, To: OrderStatePacking},
+		{From: OrderStatePacking, Event: OrderEventPack, To: OrderStateAwaiting},
+		{From: OrderStateAwaiting, Event: OrderEventShip, To: OrderStateShipped},
+		{From: OrderStateShipped, Event: OrderEventDeliver, To: OrderStateDelivered},
+
+		{From: OrderStatePending, Event: OrderEventCancel, To: OrderStateCancelled},
+		{From: OrderStateProcessing, Event: OrderEventCancel, To: OrderStateCancelled},
+
+		{From: OrderStateDelivered, Event: OrderEventRefund, To: OrderStateRefunded},
+		{From: OrderStateCancelled, Event: OrderEventRefund, To: OrderStateRefunded},
+	})
+	sm.SetParent(OrderStatePacking, OrderStateProcessing)
+	sm.SetParent(OrderStateAwaiting, OrderStateProcessing)
+
+	return sm
+}
@@ -0,0 +1,269 @@
+package statemachine
+
+import "slices"
+
+// Freeze makes the machine read-only and returns it. Once frozen, adding
+// transitions or callbacks panics, and reads no longer take the lock used by
+// WithLocking, so a single frozen definition can be shared by any number of
+// goroutines without contention. Freezing is permanent; use it once the
+// machine is fully built:
+//
+//	var orderMachine = NewOrderStateMachine().Freeze()
+//
+// A frozen machine also works out ahead of time the errors, callbacks and
+// inherited transitions its transitions would otherwise assemble as they are
+// made, so Transition, CanTransition and GetNextState do not allocate on it,
+// unless middleware, listeners, logging, history or automatic transitions
+// are involved. On a machine without middleware, vetoes, automatic
+// transitions, logging or statistics, a transition with no guards, actions
+// or permissions goes straight to its callbacks. Freezing a machine created
+// by NewIndexedStateMachine builds its table too.
+func (sm *StateMachine[S, E]) Freeze() *StateMachine[S, E] {
+	sm.lock()
+	defer sm.unlock()
+
+	if !sm.frozen.Load() {
+		sm.buildIndex()
+		sm.buildCache()
+	}
+	sm.frozen.Store(true)
+	return sm
+}
+
+// IsFrozen reports whether Freeze has been called on the machine
+func (sm *StateMachine[S, E]) IsFrozen() bool {
+	return sm.frozen.Load()
+}
+
+// checkMutable panics if the machine has been frozen. Callers must hold the
+// write lock.
+func (sm *StateMachine[S, E]) checkMutable() {
+	if sm.frozen.Load() {
+		panic("statemachine: cannot modify a frozen state machine")
+	}
+}
+
+// readCache holds what transitioning a frozen machine would otherwise
+// allocate each time
+type readCache[S State, E Event] struct {
+	// rejected holds the error for each known state and event with no
+	// transition, and guarded the error for each whose transitions are all
+	// guarded, for when the guards refuse
+	rejected map[transitionKey[S, E]]*TransitionError[S, E]
+	guarded  map[transitionKey[S, E]]*TransitionError[S, E]
+
+	// merged holds the transitions for each state and event that both the
+	// state and its ancestors, or several ancestors, have transitions for,
+	// which edgesFor would otherwise join each time
+	merged map[transitionKey[S, E]][]*edge[S, E]
+
+	// paths holds the callbacks run moving between nested states, for each
+	// move a transition makes when entering its target without history
+	paths map[statePair[S]]pathCallbacks[S, E]
+
+	// direct holds, for each state and event whose transition has nothing
+	// to run but callbacks, the state it leads to and those callbacks, so
+	// it can be made without checking guards, actions and the like. It is
+	// nil when the machine has anything that must see every transition,
+	// such as middleware or a logger. A machine with a table keeps them in
+	// indexed instead, laid out as the table is.
+	direct  map[transitionKey[S, E]]*directTransition[S, E]
+	indexed []directTransition[S, E]
+}
+
+// directTransition is a transition with nothing to run but callbacks
+type directTransition[S State, E Event] struct {
+	to                 S
+	exit, enter, final []Callback[S, E]
+
+	// leaves is set when the transition leaves a composite state, whose
+	// history an instance must record
+	leaves bool
+
+	// ok is set on the entries of readCache.indexed that hold a transition
+	ok bool
+}
+
+type statePair[S State] struct {
+	from, to S
+}
+
+type pathCallbacks[S State, E Event] struct {
+	exit, enter []Callback[S, E]
+}
+
+// buildCache fills the machine's readCache. Errors are only cached while
+// there are at most maxIndexSize pairs of state and event. sm must be locked.
+func (sm *StateMachine[S, E]) buildCache() {
+	c := &readCache[S, E]{}
+	states, events := sm.allStates(), sm.allEvents()
+	if len(states)*len(events) <= maxIndexSize {
+		c.direct, c.indexed = sm.directTransitions(states, events)
+		c.rejected = make(map[transitionKey[S, E]]*TransitionError[S, E])
+		c.guarded = make(map[transitionKey[S, E]]*TransitionError[S, E])
+		for _, s := range states {
+			valid := sm.validEvents(s)
+			for _, e := range events {
+				k := transitionKey[S, E]{s, e}
+				edges := sm.edgesFor(s, e)
+				switch {
+				case len(edges) == 0:
+					c.rejected[k] = &TransitionError[S, E]{From: s, Event: e, ValidEvents: valid}
+				case !slices.ContainsFunc(edges, func(e *edge[S, E]) bool { return len(e.guards) == 0 }):
+					c.guarded[k] = &TransitionError[S, E]{From: s, Event: e, ValidEvents: valid, guardRejected: true}
+				}
+			}
+		}
+	}
+	if len(sm.parents) > 0 {
+		c.merged = make(map[transitionKey[S, E]][]*edge[S, E])
+		for _, s := range states {
+			for _, e := range events {
+				levels := 0
+				for _, state := range sm.lineage(s) {
+					if len(sm.transitions[state][e]) > 0 {
+						levels++
+					}
+				}
+				if levels > 1 {
+					c.merged[transitionKey[S, E]{s, e}] = sm.edgesFor(s, e)
+				}
+			}
+		}
+		c.paths = make(map[statePair[S]]pathCallbacks[S, E])
+		for _, s := range states {
+			for _, e := range events {
+				for _, edge := range sm.edgesFor(s, e) {
+					for _, target := range edge.targets() {
+						p := statePair[S]{s, sm.resolveTarget(target, NoHistory, nil)}
+						if _, done := c.paths[p]; done {
+							continue
+						}
+						var cb pathCallbacks[S, E]
+						cb.exit, cb.enter = sm.callbacksFor(p.from, p.to)
+						c.paths[p] = cb
+					}
+				}
+			}
+		}
+	}
+	sm.cache = c
+}
+
+// directTransitions returns the transitions between states and events that
+// can be made directly, in a map or, if the machine has a table, laid out as
+// the table is. Both are nil if the machine has middleware, vetoes or
+// automatic transitions, or records transitions for logging, statistics or
+// coverage. sm must be locked.
+func (sm *StateMachine[S, E]) directTransitions(states []S, events []E) (map[transitionKey[S, E]]*directTransition[S, E], []directTransition[S, E]) {
+	if len(sm.middleware) > 0 || len(sm.vetoes) > 0 || len(sm.automatic) > 0 ||
+		sm.logger != nil || sm.stats != nil || sm.coverage != nil {
+		return nil, nil
+	}
+	t := sm.table
+	var direct map[transitionKey[S, E]]*directTransition[S, E]
+	var indexed []directTransition[S, E]
+	if t != nil {
+		indexed = make([]directTransition[S, E], len(t.edges))
+	} else {
+		direct = make(map[transitionKey[S, E]]*directTransition[S, E])
+	}
+	for _, from := range states {
+		for _, event := range events {
+			edges, ok := sm.indexedEdges(from, event)
+			if !ok {
+				edges = sm.edgesFor(from, event)
+			}
+			if len(edges) != 1 || !edges[0].plain() {
+				continue
+			}
+			to, ok := sm.indexedTarget(edges[0].to)
+			if !ok {
+				to = sm.resolveTarget(edges[0].to, NoHistory, nil)
+			}
+			d := directTransition[S, E]{to: to, ok: true}
+			d.exit, d.enter = sm.callbacksFor(from, to)
+			if sm.final[to] {
+				d.final = sm.onFinal
+			}
+			var h history[S]
+			sm.recordHistory(&h, from, to)
+			d.leaves = h.shallow != nil
+			if t != nil {
+				indexed[sm.stateIndex(from)*t.events+sm.eventIndex(event)] = d
+			} else {
+				direct[transitionKey[S, E]{from, event}] = &d
+			}
+		}
+	}
+	return direct, indexed
+}
+
+// plain reports whether e has nothing to run or decide, beyond the
+// callbacks of the states it leaves and enters
+func (e *edge[S, E]) plain() bool {
+	return len(e.guards) == 0 && len(e.actions) == 0 && len(e.permissions) == 0 && len(e.compensations) == 0 &&
+		e.resolve == nil && e.history == NoHistory && !e.automatic
+}
+
+// directTransition returns the cached transition for a state and event that
+// can be made directly
+func (sm *StateMachine[S, E]) directTransition(from S, event E) (*directTransition[S, E], bool) {
+	c := sm.cache
+	switch {
+	case c == nil:
+		return nil, false
+	case c.indexed != nil:
+		t := sm.table
+		i, j := sm.stateIndex(from), sm.eventIndex(event)
+		if i < 0 || i >= t.states || j < 0 || j >= t.events {
+			return nil, false
+		}
+		d := &c.indexed[i*t.events+j]
+		return d, d.ok
+	case c.direct != nil:
+		d, ok := c.direct[transitionKey[S, E]{from, event}]
+		return d, ok
+	}
+	return nil, false
+}
+
+// rejection returns the cached error for a state and event with no
+// transition
+func (c *readCache[S, E]) rejection(from S, event E) (*TransitionError[S, E], bool) {
+	if c == nil {
+		return nil, false
+	}
+	err, ok := c.rejected[transitionKey[S, E]{from, event}]
+	return err, ok
+}
+
+// mergedEdges returns the cached transitions for a state and event joined
+// from more than one state
+func (c *readCache[S, E]) mergedEdges(from S, event E) ([]*edge[S, E], bool) {
+	if c == nil {
+		return nil, false
+	}
+	edges, ok := c.merged[transitionKey[S, E]{from, event}]
+	return edges, ok
+}
+
+// guardRejection returns the cached error for a state and event whose
+// transitions' guards all refused
+func (c *readCache[S, E]) guardRejection(from S, event E) (*TransitionError[S, E], bool) {
+	if c == nil {
+		return nil, false
+	}
+	err, ok := c.guarded[transitionKey[S, E]{from, event}]
+	return err, ok
+}
+
+// callbacks returns the cached callbacks run moving from one state to
+// another
+func (c *readCache[S, E]) callbacks(from, to S) (pathCallbacks[S, E], bool) {
+	if c == nil {
+		return pathCallbacks[S, E]{}, false
+	}
+	cb, ok := c.paths[statePair[S]{from, to}]
+	return cb, ok
+}
@@ -0,0 +1,221 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFreeze_RejectsChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(sm *UserStateMachine)
+	}{
+		{
+			name: "AddTransition",
+			modify: func(sm *UserStateMachine) {
+				sm.AddTransition(UserStateRejected, UserEventSubmitSignUp, UserStateInitial)
+			},
+		},
+		{
+			name: "AddTransitions",
+			modify: func(sm *UserStateMachine) {
+				sm.AddTransitions([]Transition[UserState, UserEvent]{
+					{From: UserStateRejected, Event: UserEventSubmitSignUp, To: UserStateInitial},
+				})
+			},
+		},
+		{
+			name: "OnEnter",
+			modify: func(sm *UserStateMachine) {
+				sm.OnEnter(UserStateRejected, func(ctx context.Context, from, to UserState, event UserEvent) {})
+			},
+		},
+		{
+			name: "OnExit",
+			modify: func(sm *UserStateMachine) {
+				sm.OnExit(UserStateInitial, func(ctx context.Context, from, to UserState, event UserEvent) {})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewUserStateMachine().Freeze()
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s on a frozen machine did not panic", tt.name)
+				}
+			}()
+			tt.modify(sm)
+		})
+	}
+}
+
+func TestFreeze_ReadsStillWork(t *testing.T) {
+	sm := NewUserStateMachine()
+	if sm.IsFrozen() {
+		t.Fatalf("IsFrozen() = true before Freeze")
+	}
+	if sm.Freeze() != sm {
+		t.Errorf("Freeze() did not return the same machine")
+	}
+	if !sm.IsFrozen() {
+		t.Fatalf("IsFrozen() = false after Freeze")
+	}
+
+	got, err := sm.Transition(UserStateInitial, UserEventSubmitSignUp)
+	if err != nil || got != UserStateEmailPendingVerification {
+		t.Errorf("Transition() = %v, %v, want %v", got, err, UserStateEmailPendingVerification)
+	}
+}
+
+func TestFreeze_WhileReadingWithLocking(t *testing.T) {
+	sm := NewStateMachine[UserState, UserEvent](WithLocking())
+	sm.AddTransition(UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				sm.CanTransition(UserStateInitial, UserEventSubmitSignUp)
+			}
+		}()
+	}
+	sm.Freeze()
+	wg.Wait()
+
+	// a leaked read lock would block this forever
+	sm.mu.Lock()
+	sm.mu.Unlock()
+}
+
+// newCallbackOrderMachine returns the order machine with a guarded
+// transition, and callbacks on every state appending to log
+func newCallbackOrderMachine(log *[]string) *StateMachine[OrderState, OrderEvent] {
+	sm := NewOrderStateMachine()
+	sm.AddTransition(OrderStateShipped, OrderEventRefund, OrderStateRefunded,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }))
+	for _, s := range allOrderStates {
+		exit, enter := "exit "+string(s), "enter "+string(s)
+		sm.OnExit(s, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+			*log = append(*log, exit)
+		})
+		sm.OnEnter(s, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+			*log = append(*log, enter)
+		})
+	}
+	return sm
+}
+
+func TestFreeze_SameResults(t *testing.T) {
+	var thawedLog, frozenLog []string
+	thawed := newCallbackOrderMachine(&thawedLog)
+	frozen := newCallbackOrderMachine(&frozenLog).Freeze()
+
+	for _, from := range append(allOrderStates, "Unknown") {
+		for _, event := range append(allOrderEvents, "Unknown") {
+			t.Run(fmt.Sprintf("%s/%s", from, event), func(t *testing.T) {
+				thawedLog, frozenLog = nil, nil
+				want, wantErr := thawed.Transition(from, event)
+				got, err := frozen.Transition(from, event)
+				if got != want || fmt.Sprint(err) != fmt.Sprint(wantErr) {
+					t.Fatalf("Transition() = %v, %v, want %v, %v", got, err, want, wantErr)
+				}
+				for _, target := range []error{ErrInvalidTransition, ErrUnknownState, ErrGuardRejected} {
+					if errors.Is(err, target) != errors.Is(wantErr, target) {
+						t.Errorf("errors.Is(%v, %v) = %v, want %v", err, target, errors.Is(err, target), errors.Is(wantErr, target))
+					}
+				}
+				var terr, wantTErr *TransitionError[OrderState, OrderEvent]
+				if errors.As(err, &terr) && errors.As(wantErr, &wantTErr) && !reflect.DeepEqual(terr.ValidEvents, wantTErr.ValidEvents) {
+					t.Errorf("ValidEvents = %v, want %v", terr.ValidEvents, wantTErr.ValidEvents)
+				}
+				if !reflect.DeepEqual(frozenLog, thawedLog) {
+					t.Errorf("callbacks ran %v, want %v", frozenLog, thawedLog)
+				}
+			})
+		}
+	}
+}
+
+func TestFreeze_SameInstanceResults(t *testing.T) {
+	ctx := context.Background()
+	var thawedLog, frozenLog []string
+	thawed := newCallbackOrderMachine(&thawedLog)
+	frozen := newCallbackOrderMachine(&frozenLog).Freeze()
+	var notified []string
+	frozen.Subscribe(func(ctx context.Context, from, to OrderState, event OrderEvent, at time.Time) {
+		notified = append(notified, string(to))
+	})
+
+	want, got := thawed.NewInstanceAt(OrderStatePending), frozen.NewInstanceAt(OrderStatePending)
+	// Ship leaves Processing, whose history the instances record
+	for _, event := range []OrderEvent{OrderEventConfirm, OrderEventPack, OrderEventShip, OrderEventDeliver} {
+		if err := want.Fire(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+		if err := got.Fire(ctx, event); err != nil {
+			t.Fatalf("Fire(%v) on the frozen machine error = %v", event, err)
+		}
+	}
+	if got.Current() != want.Current() || !reflect.DeepEqual(got.Snapshot().History, want.Snapshot().History) {
+		t.Errorf("frozen instance in %v with history %v, want %v with %v", got.Current(), got.Snapshot().History, want.Current(), want.Snapshot().History)
+	}
+	if !reflect.DeepEqual(frozenLog, thawedLog) {
+		t.Errorf("callbacks ran %v, want %v", frozenLog, thawedLog)
+	}
+	if wantNotified := []string{"Packing", "AwaitingCourier", "Shipped", "Delivered"}; !reflect.DeepEqual(notified, wantNotified) {
+		t.Errorf("listener notified of %v, want %v", notified, wantNotified)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if to, err := frozen.TransitionContext(cancelled, OrderStatePending, OrderEventConfirm); to != "" || !errors.Is(err, context.Canceled) {
+		t.Errorf("TransitionContext() with a cancelled context = %v, %v, want %v", to, err, context.Canceled)
+	}
+}
+
+func TestFreeze_DoesNotAllocate(t *testing.T) {
+	var log []string
+	sm := newCallbackOrderMachine(&log)
+	sm.Freeze()
+	// the callbacks appending to log would allocate
+	log = make([]string, 0, 1<<16)
+	phases := newPhaseMachine(false)
+	guarded := NewStateMachine[OrderState, OrderEvent]()
+	guarded.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true }),
+		WithAction(func(ctx context.Context, from, to OrderState, event OrderEvent) error { return nil }))
+	guarded.Freeze()
+
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"Transition", func() { sm.Transition(OrderStatePending, OrderEventConfirm) }},
+		{"Transition between nested states", func() { sm.Transition(OrderStatePacking, OrderEventPack) }},
+		{"Transition inherited", func() { sm.Transition(OrderStateAwaiting, OrderEventCancel) }},
+		{"Transition rejected", func() { sm.Transition(OrderStatePending, OrderEventShip) }},
+		{"Transition rejected by guard", func() { sm.Transition(OrderStateShipped, OrderEventRefund) }},
+		{"Transition with a guard and action", func() { guarded.Transition(OrderStatePending, OrderEventConfirm) }},
+		{"Transition past a refusing guard", func() { phases.Transition(phaseRunning, signalStop) }},
+		{"CanTransition", func() { sm.CanTransition(OrderStatePacking, OrderEventCancel) }},
+		{"GetNextState", func() { sm.GetNextState(OrderStatePending, OrderEventConfirm) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log = log[:0]
+			if allocs := testing.AllocsPerRun(100, tt.fn); allocs != 0 {
+				t.Errorf("%s allocated %v times", tt.name, allocs)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package statemachine
+
+import "context"
+
+// Guard is a condition that must hold for a transition to fire. Guards are
+// evaluated when the transition is attempted, before any actions run.
+type Guard[S State, E Event] func(ctx context.Context, from S, event E) bool
+
+// WithGuard attaches a guard to a transition. A transition may have several
+// guards; all of them must pass for it to fire. A transition rejected by a
+// guard returns a TransitionError matching ErrGuardRejected.
+//
+// Guards are only evaluated when transitioning. CanTransition, GetValidEvents
+// and ValidateTransitionPath consider the transition table alone.
+func WithGuard[S State, E Event](fn Guard[S, E]) TransitionOption[S, E] {
+	return func(e *edge[S, E]) {
+		e.guards = append(e.guards, fn)
+	}
+}
+
+// withNamedGuard attaches a guard a loader resolved by name, keeping the
+// name so exporters can write it back out
+func withNamedGuard[S State, E Event](name string, fn Guard[S, E]) TransitionOption[S, E] {
+	return func(e *edge[S, E]) {
+		e.guards = append(e.guards, fn)
+		e.guardNames = append(e.guardNames, name)
+	}
+}
+
+// allowed reports whether every guard on the edge passes, each wrapped in mw
+func (e *edge[S, E]) allowed(ctx context.Context, from S, event E, mw []GuardMiddleware[S, E]) bool {
+	for _, fn := range e.guards {
+		if !wrapGuard(fn, mw)(ctx, from, event) {
+			return false
+		}
+	}
+	return true
+}
+
+// wrapGuard wraps fn in mw, the first outermost
+func wrapGuard[S State, E Event](fn Guard[S, E], mw []GuardMiddleware[S, E]) Guard[S, E] {
+	for i := len(mw) - 1; i >= 0; i-- {
+		fn = mw[i](fn)
+	}
+	return fn
+}
@@ -0,0 +1,142 @@
+// Package guards builds statemachine guards out of smaller ones, so a
+// complex condition is made of reusable pieces that can each be tested on
+// their own:
+//
+//	shippable := guards.And(paymentCleared, guards.Or(inStock, backorderAllowed), guards.Not(fraudSuspected))
+//	sm.AddTransition(OrderStatePacked, OrderEventShip, OrderStateShipped, statemachine.WithGuard(shippable))
+//
+// A Registry names guards, simple and composite alike, so definitions loaded
+// with statemachine.LoadYAML or statemachine.FromDefinitionWithGuards can
+// refer to them:
+//
+//	reg := guards.NewRegistry[OrderState, OrderEvent]()
+//	reg.Register("paymentCleared", paymentCleared)
+//	reg.Register("inStock", inStock)
+//	reg.Register("shippable", guards.And(reg.Must("paymentCleared"), reg.Must("inStock")))
+//
+//	sm, err := statemachine.LoadYAML(f, allOrderStates, allOrderEvents, reg.Guards())
+package guards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// And returns a guard that passes when every one of gs passes. The guards
+// are evaluated in order, stopping at the first that fails, so put cheap
+// checks first. With no guards it always passes.
+func And[S statemachine.State, E statemachine.Event](gs ...statemachine.Guard[S, E]) statemachine.Guard[S, E] {
+	return func(ctx context.Context, from S, event E) bool {
+		for _, g := range gs {
+			if !g(ctx, from, event) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a guard that passes when any of gs passes. The guards are
+// evaluated in order, stopping at the first that passes. With no guards it
+// never passes.
+func Or[S statemachine.State, E statemachine.Event](gs ...statemachine.Guard[S, E]) statemachine.Guard[S, E] {
+	return func(ctx context.Context, from S, event E) bool {
+		for _, g := range gs {
+			if g(ctx, from, event) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a guard that passes when g does not
+func Not[S statemachine.State, E statemachine.Event](g statemachine.Guard[S, E]) statemachine.Guard[S, E] {
+	return func(ctx context.Context, from S, event E) bool {
+		return !g(ctx, from, event)
+	}
+}
+
+var (
+	// ErrDuplicateGuard is returned by Register for a name already taken
+	ErrDuplicateGuard = errors.New("guard already registered")
+
+	// ErrNilGuard is returned by Register for a nil guard
+	ErrNilGuard = errors.New("guard is nil")
+)
+
+// Registry holds guards under names. It is safe for concurrent use, though
+// guards are usually all registered at start up.
+type Registry[S statemachine.State, E statemachine.Event] struct {
+	mu     sync.RWMutex
+	guards map[string]statemachine.Guard[S, E]
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry[S statemachine.State, E statemachine.Event]() *Registry[S, E] {
+	return &Registry[S, E]{guards: make(map[string]statemachine.Guard[S, E])}
+}
+
+// Register adds g to the registry under name, failing with an error
+// matching ErrDuplicateGuard if the name is taken, or ErrNilGuard if g is
+// nil
+func (r *Registry[S, E]) Register(name string, g statemachine.Guard[S, E]) error {
+	if g == nil {
+		return fmt.Errorf("cannot register %q: %w", name, ErrNilGuard)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.guards[name]; exists {
+		return fmt.Errorf("cannot register %q: %w", name, ErrDuplicateGuard)
+	}
+	r.guards[name] = g
+	return nil
+}
+
+// Guard returns the guard registered under name
+func (r *Registry[S, E]) Guard(name string) (statemachine.Guard[S, E], bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	g, ok := r.guards[name]
+	return g, ok
+}
+
+// Must returns the guard registered under name, for building composite
+// guards out of those registered earlier. It panics if there is none.
+func (r *Registry[S, E]) Must(name string) statemachine.Guard[S, E] {
+	g, ok := r.Guard(name)
+	if !ok {
+		panic(fmt.Sprintf("guards: no guard registered as %q", name))
+	}
+	return g
+}
+
+// Names returns the names of the registered guards, sorted
+func (r *Registry[S, E]) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.guards))
+	for name := range r.guards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Guards returns a copy of the registered guards by name, as LoadYAML and
+// FromDefinitionWithGuards take them
+func (r *Registry[S, E]) Guards() map[string]statemachine.Guard[S, E] {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return maps.Clone(r.guards)
+}
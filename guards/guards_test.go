@@ -0,0 +1,118 @@
+package guards_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/guards"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+type guard = statemachine.Guard[state, event]
+
+// fixed returns a guard that always gives result, counting its calls
+func fixed(result bool, calls *int) guard {
+	return func(ctx context.Context, from state, ev event) bool {
+		*calls++
+		return result
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	tests := []struct {
+		name      string
+		build     func(yes, no guard) guard
+		want      bool
+		wantCalls int
+	}{
+		{"And all pass", func(yes, no guard) guard { return guards.And(yes, yes) }, true, 2},
+		{"And stops at failure", func(yes, no guard) guard { return guards.And(no, yes) }, false, 1},
+		{"And of nothing", func(yes, no guard) guard { return guards.And[state, event]() }, true, 0},
+		{"Or stops at pass", func(yes, no guard) guard { return guards.Or(yes, no) }, true, 1},
+		{"Or all fail", func(yes, no guard) guard { return guards.Or(no, no) }, false, 2},
+		{"Or of nothing", func(yes, no guard) guard { return guards.Or[state, event]() }, false, 0},
+		{"Not", func(yes, no guard) guard { return guards.Not(no) }, true, 1},
+		{"nested", func(yes, no guard) guard { return guards.And(yes, guards.Or(no, guards.Not(no))) }, true, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			g := tt.build(fixed(true, &calls), fixed(false, &calls))
+			if got := g(context.Background(), "Open", "Close"); got != tt.want {
+				t.Errorf("guard = %v, want %v", got, tt.want)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("guards called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	var calls int
+	reg := guards.NewRegistry[state, event]()
+	for name, g := range map[string]guard{
+		"paid":    fixed(true, &calls),
+		"inStock": fixed(false, &calls),
+		"flagged": fixed(false, &calls),
+	} {
+		if err := reg.Register(name, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := reg.Register("shippable", guards.And(reg.Must("paid"), guards.Not(reg.Must("flagged")))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reg.Register("paid", fixed(false, &calls)); !errors.Is(err, guards.ErrDuplicateGuard) {
+		t.Errorf("Register() of a taken name error = %v, want %v", err, guards.ErrDuplicateGuard)
+	}
+	if err := reg.Register("empty", nil); !errors.Is(err, guards.ErrNilGuard) {
+		t.Errorf("Register(nil) error = %v, want %v", err, guards.ErrNilGuard)
+	}
+	if want := []string{"flagged", "inStock", "paid", "shippable"}; !slices.Equal(reg.Names(), want) {
+		t.Errorf("Names() = %v, want %v", reg.Names(), want)
+	}
+	if _, ok := reg.Guard("missing"); ok {
+		t.Error("Guard() found an unregistered guard")
+	}
+
+	// a definition refers to the composite guard by name
+	def := `
+states:
+  - name: Packed
+  - name: Shipped
+  - name: Held
+events:
+  - name: Ship
+transitions:
+  - {from: Packed, event: Ship, to: Shipped, guards: [shippable]}
+  - {from: Packed, event: Ship, to: Held, guards: [inStock]}
+`
+	sm, err := statemachine.LoadYAML(strings.NewReader(def), []state{"Packed", "Shipped", "Held"}, []event{"Ship"}, reg.Guards())
+	if err != nil {
+		t.Fatalf("LoadYAML() error = %v", err)
+	}
+	if to, err := sm.Transition("Packed", "Ship"); err != nil || to != "Shipped" {
+		t.Errorf("Transition() = %v, %v, want Shipped", to, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Must() of an unregistered guard did not panic")
+		}
+	}()
+	reg.Must("missing")
+}
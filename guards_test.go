@@ -0,0 +1,62 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStateMachine_Guards(t *testing.T) {
+	verified := false
+	actionRan := false
+
+	sm := NewStateMachine[UserState, UserEvent]()
+	sm.AddTransition(UserStateEmailVerified, UserEventCompleteProfile, UserStateSignUpComplete,
+		WithGuard(func(ctx context.Context, from UserState, event UserEvent) bool {
+			return true
+		}),
+		WithGuard(func(ctx context.Context, from UserState, event UserEvent) bool {
+			return verified
+		}),
+		WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+			actionRan = true
+			return nil
+		}),
+	)
+
+	_, err := sm.Transition(UserStateEmailVerified, UserEventCompleteProfile)
+	if !errors.Is(err, ErrGuardRejected) || !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("Transition() error = %v, want ErrGuardRejected", err)
+	}
+	if actionRan {
+		t.Errorf("action ran although a guard rejected the transition")
+	}
+
+	var te *TransitionError[UserState, UserEvent]
+	if !errors.As(err, &te) || len(te.ValidEvents) != 1 {
+		t.Errorf("TransitionError = %+v, want one valid event", te)
+	}
+
+	verified = true
+	got, err := sm.Transition(UserStateEmailVerified, UserEventCompleteProfile)
+	if err != nil || got != UserStateSignUpComplete {
+		t.Errorf("Transition() = %v, %v, want %v", got, err, UserStateSignUpComplete)
+	}
+	if !actionRan {
+		t.Errorf("action did not run after guards passed")
+	}
+}
+
+func TestStateMachine_GuardsIgnoredByTableQueries(t *testing.T) {
+	sm := NewStateMachine[UserState, UserEvent]()
+	sm.AddTransition(UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification,
+		WithGuard(func(ctx context.Context, from UserState, event UserEvent) bool { return false }),
+	)
+
+	if !sm.CanTransition(UserStateInitial, UserEventSubmitSignUp) {
+		t.Errorf("CanTransition() = false, want true regardless of guards")
+	}
+	if _, err := sm.ValidateTransitionPath(UserStateInitial, []UserEvent{UserEventSubmitSignUp}); err != nil {
+		t.Errorf("ValidateTransitionPath() error = %v", err)
+	}
+}
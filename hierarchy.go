@@ -0,0 +1,99 @@
+package statemachine
+
+import "fmt"
+
+// SetParent nests child inside parent, making child a substate. A substate
+// responds to every event its parent (and its parent's ancestors) handles,
+// unless it declares its own transition for the event, so transitions shared
+// by a group of states only need declaring once:
+//
+//	sm.SetParent(OrderStatePacking, OrderStateProcessing)
+//	sm.SetParent(OrderStateAwaitingCourier, OrderStateProcessing)
+//	sm.AddTransition(OrderStateProcessing, OrderEventCancel, OrderStateCancelled)
+//
+// Moving between states runs the exit callbacks of every state left and the
+// entry callbacks of every state entered, up to their closest common
+// ancestor. An error is returned if the nesting would form a cycle.
+func (sm *StateMachine[S, E]) SetParent(child, parent S) error {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	for _, s := range sm.lineage(parent) {
+		if s == child {
+			return fmt.Errorf("cannot make '%s' a substate of '%s': states would be nested in a cycle", Name(child), Name(parent))
+		}
+	}
+	sm.parents[child] = parent
+	return nil
+}
+
+// Parent returns the parent of state, if it has one
+func (sm *StateMachine[S, E]) Parent(state S) (S, bool) {
+	sm.rlock()
+	defer sm.runlock()
+
+	parent, ok := sm.parents[state]
+	return parent, ok
+}
+
+// IsSubstateOf reports whether state is ancestor or nested anywhere inside it
+func (sm *StateMachine[S, E]) IsSubstateOf(state, ancestor S) bool {
+	sm.rlock()
+	defer sm.runlock()
+
+	return sm.isIn(state, ancestor)
+}
+
+func (sm *StateMachine[S, E]) isIn(state, ancestor S) bool {
+	for _, s := range sm.lineage(state) {
+		if s == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// lineage returns state followed by each of its ancestors, innermost first
+func (sm *StateMachine[S, E]) lineage(state S) []S {
+	states := []S{state}
+	for {
+		parent, ok := sm.parents[state]
+		if !ok {
+			return states
+		}
+		states = append(states, parent)
+		state = parent
+	}
+}
+
+// exitEnterPath returns the states left, innermost first, and the states
+// entered, outermost first, when moving from one state to another
+func (sm *StateMachine[S, E]) exitEnterPath(from, to S) (exited, entered []S) {
+	if from == to {
+		return []S{from}, []S{to}
+	}
+
+	toLineage := sm.lineage(to)
+	inTo := make(map[S]bool, len(toLineage))
+	for _, s := range toLineage {
+		inTo[s] = true
+	}
+
+	var common S
+	hasCommon := false
+	for _, s := range sm.lineage(from) {
+		if inTo[s] {
+			common, hasCommon = s, true
+			break
+		}
+		exited = append(exited, s)
+	}
+	for _, s := range toLineage {
+		if hasCommon && s == common {
+			break
+		}
+		entered = append([]S{s}, entered...)
+	}
+	return exited, entered
+}
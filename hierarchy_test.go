@@ -0,0 +1,152 @@
+package statemachine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestHierarchy_InheritedTransitions(t *testing.T) {
+	sm := NewOrderStateMachine()
+
+	tests := []struct {
+		name  string
+		from  OrderState
+		event OrderEvent
+		want  OrderState
+	}{
+		{"own transition", OrderStatePacking, OrderEventPack, OrderStateAwaiting},
+		{"inherited from parent", OrderStatePacking, OrderEventCancel, OrderStateCancelled},
+		{"inherited by sibling", OrderStateAwaiting, OrderEventCancel, OrderStateCancelled},
+		{"parent itself", OrderStateProcessing, OrderEventCancel, OrderStateCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sm.Transition(tt.from, tt.event)
+			if err != nil {
+				t.Fatalf("Transition() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Transition() = %v, want %v", got, tt.want)
+			}
+			if !sm.CanTransition(tt.from, tt.event) {
+				t.Errorf("CanTransition() = false, want true")
+			}
+		})
+	}
+
+	if _, err := sm.Transition(OrderStateShipped, OrderEventCancel); err == nil {
+		t.Errorf("Transition(Shipped, Cancel) expected error, Shipped is not in Processing")
+	}
+	if got := len(sm.GetValidEvents(OrderStateAwaiting)); got != 2 {
+		t.Errorf("GetValidEvents(AwaitingCourier) returned %d events, want 2", got)
+	}
+}
+
+func TestHierarchy_ChildOverridesParent(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.AddTransition(OrderStateAwaiting, OrderEventCancel, OrderStateRefunded)
+
+	got, err := sm.Transition(OrderStateAwaiting, OrderEventCancel)
+	if err != nil || got != OrderStateRefunded {
+		t.Errorf("Transition() = %v, %v, want %v", got, err, OrderStateRefunded)
+	}
+}
+
+func TestHierarchy_FallsBackToParentWhenGuardRejects(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.AddTransition(OrderStateAwaiting, OrderEventCancel, OrderStateRefunded,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }),
+	)
+
+	got, err := sm.Transition(OrderStateAwaiting, OrderEventCancel)
+	if err != nil || got != OrderStateCancelled {
+		t.Errorf("Transition() = %v, %v, want %v", got, err, OrderStateCancelled)
+	}
+}
+
+func TestHierarchy_Callbacks(t *testing.T) {
+	sm := NewOrderStateMachine()
+
+	var calls []string
+	for _, s := range []OrderState{OrderStatePending, OrderStateProcessing, OrderStatePacking, OrderStateAwaiting, OrderStateCancelled} {
+		sm.OnExit(s, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+			calls = append(calls, "exit "+string(s))
+		})
+		sm.OnEnter(s, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+			calls = append(calls, "enter "+string(s))
+		})
+	}
+
+	tests := []struct {
+		name  string
+		from  OrderState
+		event OrderEvent
+		want  []string
+	}{
+		{
+			name:  "into a substate enters its parent first",
+			from:  OrderStatePending,
+			event: OrderEventConfirm,
+			want:  []string{"exit Pending", "enter Processing", "enter Packing"},
+		},
+		{
+			name:  "between siblings stays in the parent",
+			from:  OrderStatePacking,
+			event: OrderEventPack,
+			want:  []string{"exit Packing", "enter AwaitingCourier"},
+		},
+		{
+			name:  "out of a substate exits its parent last",
+			from:  OrderStateAwaiting,
+			event: OrderEventCancel,
+			want:  []string{"exit AwaitingCourier", "exit Processing", "enter Cancelled"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			if _, err := sm.Transition(tt.from, tt.event); err != nil {
+				t.Fatalf("Transition() error = %v", err)
+			}
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Errorf("callbacks = %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestHierarchy_SetParentRejectsCycles(t *testing.T) {
+	sm := NewOrderStateMachine()
+
+	if err := sm.SetParent(OrderStateProcessing, OrderStatePacking); err == nil {
+		t.Errorf("SetParent() expected error for a cycle")
+	}
+	if err := sm.SetParent(OrderStatePending, OrderStatePending); err == nil {
+		t.Errorf("SetParent() expected error for a state nested in itself")
+	}
+}
+
+func TestHierarchy_IsIn(t *testing.T) {
+	sm := NewOrderStateMachine()
+	inst := sm.NewInstanceAt(OrderStatePending)
+
+	if inst.IsIn(OrderStateProcessing) {
+		t.Errorf("IsIn(Processing) = true while Pending")
+	}
+	if err := inst.Fire(context.Background(), OrderEventConfirm); err != nil {
+		t.Fatalf("Fire() error = %v", err)
+	}
+	if !inst.IsIn(OrderStateProcessing) || !inst.IsIn(OrderStatePacking) {
+		t.Errorf("IsIn() = false for Processing or Packing while %v", inst.Current())
+	}
+	if inst.Is(OrderStateProcessing) {
+		t.Errorf("Is(Processing) = true, Is should only match the exact state")
+	}
+
+	if parent, ok := sm.Parent(OrderStatePacking); !ok || parent != OrderStateProcessing {
+		t.Errorf("Parent(Packing) = %v, %v, want Processing", parent, ok)
+	}
+}
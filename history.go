@@ -0,0 +1,148 @@
+package statemachine
+
+import (
+	"fmt"
+	"maps"
+)
+
+// HistoryKind selects how a transition into a composite state picks the
+// substate to enter
+type HistoryKind int
+
+const (
+	// NoHistory enters a composite state at its initial substate
+	NoHistory HistoryKind = iota
+
+	// ShallowHistory re-enters the direct substate that was last active,
+	// then continues into that substate's own initial substate
+	ShallowHistory
+
+	// DeepHistory re-enters the innermost state that was last active
+	DeepHistory
+)
+
+func (k HistoryKind) String() string {
+	switch k {
+	case NoHistory:
+		return "none"
+	case ShallowHistory:
+		return "shallow"
+	case DeepHistory:
+		return "deep"
+	default:
+		return fmt.Sprintf("HistoryKind(%d)", int(k))
+	}
+}
+
+// WithHistory makes a transition into a composite state target its history
+// pseudo-state, so an instance resumes where it left off instead of at the
+// initial substate:
+//
+//	sm.AddTransition(DocumentStatePaused, DocumentEventResume, DocumentStateReview,
+//		statemachine.WithHistory[DocumentState, DocumentEvent](statemachine.DeepHistory))
+//
+// History is remembered per Instance. When there is no history yet, or when
+// transitioning with StateMachine.Transition, the initial substate is used.
+func WithHistory[S State, E Event](kind HistoryKind) TransitionOption[S, E] {
+	return func(e *edge[S, E]) {
+		e.history = kind
+	}
+}
+
+// SetInitialSubstate sets the substate entered when a transition targets
+// parent itself. child must already be a substate of parent.
+func (sm *StateMachine[S, E]) SetInitialSubstate(parent, child S) error {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	if p, ok := sm.parents[child]; !ok || p != parent {
+		return fmt.Errorf("cannot make '%s' the initial substate of '%s': it is not a substate of it", Name(child), Name(parent))
+	}
+	sm.initial[parent] = child
+	return nil
+}
+
+// InitialSubstate returns the initial substate of parent, if it has one
+func (sm *StateMachine[S, E]) InitialSubstate(parent S) (S, bool) {
+	sm.rlock()
+	defer sm.runlock()
+
+	child, ok := sm.initial[parent]
+	return child, ok
+}
+
+// resolveTarget returns the state actually entered when a transition targets
+// state: the remembered substate when entering via history, otherwise the
+// initial substate, repeated until a state with neither is reached
+func (sm *StateMachine[S, E]) resolveTarget(state S, kind HistoryKind, h *history[S]) S {
+	if h == nil || kind == NoHistory {
+		if to, ok := sm.indexedTarget(state); ok {
+			return to
+		}
+	}
+	if h != nil {
+		switch kind {
+		case DeepHistory:
+			if leaf, ok := h.deep[state]; ok {
+				return leaf
+			}
+		case ShallowHistory:
+			if child, ok := h.shallow[state]; ok {
+				return sm.resolveTarget(child, NoHistory, h)
+			}
+		}
+	}
+	if child, ok := sm.initial[state]; ok {
+		return sm.resolveTarget(child, NoHistory, h)
+	}
+	return state
+}
+
+// history remembers, for each composite state an instance has left, which
+// direct substate and which innermost state were active at the time
+type history[S State] struct {
+	shallow map[S]S
+	deep    map[S]S
+}
+
+// noteHistory records t in h, if h is not nil
+func (sm *StateMachine[S, E]) noteHistory(h *history[S], t taken[S, E]) {
+	if h == nil {
+		return
+	}
+	sm.rlock()
+	sm.recordHistory(h, t.from, t.to)
+	sm.runlock()
+}
+
+// clone returns a copy of h, or nil if h is nil
+func (h *history[S]) clone() *history[S] {
+	if h == nil {
+		return nil
+	}
+	return &history[S]{shallow: maps.Clone(h.shallow), deep: maps.Clone(h.deep)}
+}
+
+// recordHistory notes in h the substates active in every composite state
+// left by a transition from one state to another
+func (sm *StateMachine[S, E]) recordHistory(h *history[S], from, to S) {
+	lineage := sm.lineage(from)
+	inTo := make(map[S]bool)
+	for _, s := range sm.lineage(to) {
+		inTo[s] = true
+	}
+
+	for i := 1; i < len(lineage); i++ {
+		composite := lineage[i]
+		if inTo[composite] {
+			break
+		}
+		if h.shallow == nil {
+			h.shallow = make(map[S]S)
+			h.deep = make(map[S]S)
+		}
+		h.shallow[composite] = lineage[i-1]
+		h.deep[composite] = from
+	}
+}
@@ -0,0 +1,358 @@
+// Package history keeps an audit trail of a state machine's transitions:
+// which entity moved from which state to which, on what event, when and at
+// whose hands. A Recorder subscribes to a machine and appends a Record for
+// every transition to a Sink:
+//
+//	rec := history.NewRecorder(sm, sink)
+//	defer rec.Subscribe()()
+//
+//	ctx = statemachine.WithEntityID(ctx, doc.ID)
+//	ctx = statemachine.WithActor(ctx, user.ID)
+//	sm.TransitionContext(ctx, doc.State, DocumentEventApprove)
+//
+//	approvals, err := sink.Query(ctx, history.Query{To: "Approved"})
+//
+// Each entity's records form a hash chain: every record holds the hash of
+// the one before it, and its own hash covers its contents and that link.
+// Verify checks a trail against its hashes. On their own, the hashes only
+// detect accidental corruption, since anyone who can write to the sink can
+// recompute them. To detect deliberate changes, give the Recorder a Key,
+// which makes each hash an HMAC that cannot be forged without it, and keep
+// the Head of each entity's trail outside the sink in a HeadStore:
+//
+//	rec := history.NewRecorder(sm, sink)
+//	rec.Key = auditKey
+//	rec.Heads = heads
+//
+//	head, _, err := heads.LoadHead(ctx, doc.ID)
+//	err = history.Verify(trail, auditKey, &head)
+//
+// Verify then detects a record that is altered, removed or inserted in the
+// sink afterwards, including records removed from the end of the trail. The
+// Recorder chains each record onto the kept head rather than onto the sink,
+// and refuses to record for an entity whose trail in the sink no longer ends
+// at it, so a tampered trail cannot be made to verify by the transitions
+// that follow.
+package history
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// ErrTampered is returned by Verify for a trail that does not match its
+// hashes
+var ErrTampered = errors.New("history has been tampered with")
+
+// Record is a transition in the audit trail
+type Record struct {
+	EntityID string                `json:"entityId"`
+	From     string                `json:"from"`
+	To       string                `json:"to"`
+	Event    string                `json:"event"`
+	Actor    string                `json:"actor,omitempty"`
+	At       time.Time             `json:"at"`
+	Meta     statemachine.Metadata `json:"meta"`
+
+	// Seq is the record's position in the entity's trail, from 1
+	Seq int `json:"seq"`
+
+	// PrevHash is the Hash of the entity's previous record, empty for its
+	// first
+	PrevHash string `json:"prevHash"`
+
+	// Hash is the hex SHA-256 of the record's other fields, or their
+	// HMAC-SHA256 if the Recorder has a Key
+	Hash string `json:"hash"`
+}
+
+// sum returns the hash of the record's fields other than Hash, keyed with
+// key if it is not nil
+func (r Record) sum(key []byte) string {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		// a Record holds only strings, a time and string maps
+		panic("history: cannot encode record: " + err.Error())
+	}
+	if key == nil {
+		h := sha256.Sum256(b)
+		return hex.EncodeToString(h[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Head is the end of an entity's trail: how many records it has and the
+// Hash of the last. Kept outside the sink, it lets Verify detect records
+// removed from the end of the trail, which the chain alone cannot.
+type Head struct {
+	EntityID string `json:"entityId"`
+	Count    int    `json:"count"`
+	Hash     string `json:"hash"`
+}
+
+// HeadStore keeps the Head of each entity's trail, somewhere those who can
+// write to the sink cannot. Implementations must be safe for concurrent use.
+type HeadStore interface {
+	// LoadHead returns the head of an entity's trail, and whether it has
+	// one
+	LoadHead(ctx context.Context, entityID string) (Head, bool, error)
+
+	// SaveHead replaces the head of an entity's trail
+	SaveHead(ctx context.Context, head Head) error
+}
+
+// Sink stores records. Implementations must be safe for concurrent use.
+type Sink interface {
+	// Append stores a record after those already stored
+	Append(ctx context.Context, r Record) error
+
+	// Latest returns the last record stored for an entity, and whether
+	// there is one
+	Latest(ctx context.Context, entityID string) (Record, bool, error)
+
+	// Query returns the records matching q in the order they were stored
+	Query(ctx context.Context, q Query) ([]Record, error)
+}
+
+// Query selects records. Empty fields match every record.
+type Query struct {
+	EntityID string
+	Actor    string
+	Event    string
+
+	// From and To match the states a transition left and entered
+	From string
+	To   string
+
+	// Since and Until bound the time of the transition, inclusively
+	Since time.Time
+	Until time.Time
+}
+
+// Match reports whether r is selected by q
+func (q Query) Match(r Record) bool {
+	switch {
+	case q.EntityID != "" && r.EntityID != q.EntityID,
+		q.Actor != "" && r.Actor != q.Actor,
+		q.Event != "" && r.Event != q.Event,
+		q.From != "" && r.From != q.From,
+		q.To != "" && r.To != q.To,
+		!q.Since.IsZero() && r.At.Before(q.Since),
+		!q.Until.IsZero() && r.At.After(q.Until):
+		return false
+	}
+	return true
+}
+
+// Verify checks that records, an entity's trail in the order returned by
+// Query, are intact and complete from its first record, using the key the
+// Recorder hashed them with, or nil if it had none. If head is not nil, the
+// trail must also end at it. Verify returns an error matching ErrTampered
+// naming the first record that is not as recorded.
+func Verify(records []Record, key []byte, head *Head) error {
+	prev := ""
+	for i, r := range records {
+		if r.PrevHash != prev || r.Seq != i+1 {
+			return fmt.Errorf("record %d of entity %s does not follow the one before it: %w", i+1, r.EntityID, ErrTampered)
+		}
+		if !hmac.Equal([]byte(r.sum(key)), []byte(r.Hash)) {
+			return fmt.Errorf("record %d of entity %s does not match its hash: %w", i+1, r.EntityID, ErrTampered)
+		}
+		prev = r.Hash
+	}
+	if head != nil && (len(records) != head.Count || prev != head.Hash) {
+		return fmt.Errorf("trail of entity %s has %d records, not the %d recorded: %w", head.EntityID, len(records), head.Count, ErrTampered)
+	}
+	return nil
+}
+
+// Recorder appends the transitions of a machine to a Sink. Create one with
+// NewRecorder.
+type Recorder[S statemachine.State, E statemachine.Event] struct {
+	Machine *statemachine.StateMachine[S, E]
+	Sink    Sink
+
+	// Key, if set, makes each record's Hash an HMAC-SHA256 keyed with it,
+	// so that records cannot be rewritten with valid hashes without it.
+	// Verify must be given the same key.
+	Key []byte
+
+	// Heads, if set, keeps the Head of each entity's trail for Verify. Each
+	// record is then linked to the kept head, and a record is refused with
+	// an error matching ErrTampered if the trail in the sink does not end
+	// at it. A head that fails to save after its record was stored leaves
+	// the entity's trail refusing records until the two are reconciled.
+	Heads HeadStore
+
+	// OnError, if set, is called with each record that could not be
+	// stored and the reason, so that gaps in the trail can be acted on.
+	// If nil, they are written to ErrorLog.
+	OnError func(ctx context.Context, rec Record, err error)
+
+	// ErrorLog receives records that could not be stored when OnError is
+	// nil. If nil, the log package's standard logger is used.
+	ErrorLog *log.Logger
+
+	// mu keeps each record's link to the one before it from racing
+	mu sync.Mutex
+}
+
+// NewRecorder returns a recorder appending the transitions of sm to sink
+func NewRecorder[S statemachine.State, E statemachine.Event](sm *statemachine.StateMachine[S, E], sink Sink) *Recorder[S, E] {
+	return &Recorder[S, E]{Machine: sm, Sink: sink}
+}
+
+// Subscribe records every transition of the machine. The returned function
+// unsubscribes.
+func (r *Recorder[S, E]) Subscribe() (unsubscribe func()) {
+	return r.Machine.Subscribe(r.Notify)
+}
+
+// Notify records a transition. It is a statemachine.Listener, for use where
+// Subscribe does not fit. The entity and actor are read from ctx, and the
+// time is kept to the microsecond so it survives storage in most databases.
+func (r *Recorder[S, E]) Notify(ctx context.Context, from, to S, event E, at time.Time) {
+	rec := Record{
+		From:  statemachine.Name(from),
+		To:    statemachine.Name(to),
+		Event: statemachine.Name(event),
+		At:    at.UTC().Truncate(time.Microsecond),
+	}
+	rec.EntityID, _ = statemachine.EntityIDFrom(ctx)
+	rec.Actor, _ = statemachine.ActorFrom(ctx)
+	rec.Meta, _ = r.Machine.GetTransitionMeta(from, event)
+
+	if err := r.append(ctx, rec); err != nil {
+		if r.OnError != nil {
+			r.OnError(ctx, rec, err)
+			return
+		}
+		r.logf("history: cannot record %s --%s--> %s of entity %s: %v", rec.From, rec.Event, rec.To, rec.EntityID, err)
+	}
+}
+
+// append links rec to the end of the entity's trail and stores it
+func (r *Recorder[S, E]) append(ctx context.Context, rec Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last, ok, err := r.Sink.Latest(ctx, rec.EntityID)
+	if err != nil {
+		return err
+	}
+	end := Head{EntityID: rec.EntityID}
+	if ok {
+		end.Count, end.Hash = last.Seq, last.Hash
+	}
+	if r.Heads != nil {
+		head, known, err := r.Heads.LoadHead(ctx, rec.EntityID)
+		if err != nil {
+			return err
+		}
+		if !known {
+			head = Head{EntityID: rec.EntityID}
+		}
+		if head.Count != end.Count || head.Hash != end.Hash {
+			return fmt.Errorf("trail of entity %s ends at record %d, not at its head %d: %w", rec.EntityID, end.Count, head.Count, ErrTampered)
+		}
+	}
+	rec.Seq, rec.PrevHash = end.Count+1, end.Hash
+	rec.Hash = rec.sum(r.Key)
+	if err := r.Sink.Append(ctx, rec); err != nil {
+		return err
+	}
+	if r.Heads != nil {
+		return r.Heads.SaveHead(ctx, Head{EntityID: rec.EntityID, Count: rec.Seq, Hash: rec.Hash})
+	}
+	return nil
+}
+
+func (r *Recorder[S, E]) logf(format string, args ...any) {
+	if r.ErrorLog != nil {
+		r.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// MemoryHeads is a HeadStore holding heads in memory, for tests and
+// short-lived processes. The zero value is ready to use.
+type MemoryHeads struct {
+	mu    sync.Mutex
+	heads map[string]Head
+}
+
+// LoadHead returns the head of an entity's trail
+func (h *MemoryHeads) LoadHead(ctx context.Context, entityID string) (Head, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	head, ok := h.heads[entityID]
+	if !ok {
+		head.EntityID = entityID
+	}
+	return head, ok, nil
+}
+
+// SaveHead replaces the head of an entity's trail
+func (h *MemoryHeads) SaveHead(ctx context.Context, head Head) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.heads == nil {
+		h.heads = make(map[string]Head)
+	}
+	h.heads[head.EntityID] = head
+	return nil
+}
+
+// MemorySink is a Sink holding records in memory, for tests and short-lived
+// processes. The zero value is ready to use.
+type MemorySink struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// Append stores a record
+func (s *MemorySink) Append(ctx context.Context, r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, r)
+	return nil
+}
+
+// Latest returns the last record stored for an entity
+func (s *MemorySink) Latest(ctx context.Context, entityID string) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.records) - 1; i >= 0; i-- {
+		if s.records[i].EntityID == entityID {
+			return s.records[i], true, nil
+		}
+	}
+	return Record{}, false, nil
+}
+
+// Query returns the records matching q
+func (s *MemorySink) Query(ctx context.Context, q Query) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []Record
+	for _, r := range s.records {
+		if q.Match(r) {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,303 @@
+package history_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/history"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Draft", "Submit", "Review")
+	sm.AddTransition("Review", "Approve", "Approved",
+		statemachine.WithMeta[state, event](statemachine.Metadata{Label: "Approve document"}))
+	sm.AddTransition("Review", "Reject", "Draft")
+	return sm
+}
+
+var key = []byte("audit key")
+
+// record drives two documents through the machine, recording them with key,
+// and returns the sink and the head of each document's trail
+func record(t *testing.T, key []byte) (*history.MemorySink, *history.MemoryHeads) {
+	t.Helper()
+	sm := newMachine()
+	sink := &history.MemorySink{}
+	heads := &history.MemoryHeads{}
+	rec := history.NewRecorder(sm, sink)
+	rec.Key = key
+	rec.Heads = heads
+	defer rec.Subscribe()()
+
+	for _, step := range []struct {
+		doc, actor string
+		from       state
+		event      event
+	}{
+		{"doc-1", "alice", "Draft", "Submit"},
+		{"doc-2", "bob", "Draft", "Submit"},
+		{"doc-1", "carol", "Review", "Reject"},
+		{"doc-1", "alice", "Draft", "Submit"},
+		{"doc-1", "carol", "Review", "Approve"},
+	} {
+		ctx := statemachine.WithActor(statemachine.WithEntityID(context.Background(), step.doc), step.actor)
+		if _, err := sm.TransitionContext(ctx, step.from, step.event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sink, heads
+}
+
+func describe(records []history.Record) string {
+	var lines []string
+	for _, r := range records {
+		lines = append(lines, r.EntityID+" "+r.Actor+": "+r.From+" --"+r.Event+"--> "+r.To)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestRecorder(t *testing.T) {
+	sink, heads := record(t, key)
+	ctx := context.Background()
+
+	trail, err := sink.Query(ctx, history.Query{EntityID: "doc-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `doc-1 alice: Draft --Submit--> Review
+doc-1 carol: Review --Reject--> Draft
+doc-1 alice: Draft --Submit--> Review
+doc-1 carol: Review --Approve--> Approved`
+	if got := describe(trail); got != want {
+		t.Errorf("trail:\n%s\nwant:\n%s", got, want)
+	}
+	head, ok, _ := heads.LoadHead(ctx, "doc-1")
+	if !ok {
+		t.Fatal("LoadHead() found no head for doc-1")
+	}
+	if err := history.Verify(trail, key, &head); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if head.Count != 4 || head.Hash != trail[3].Hash {
+		t.Errorf("head = %+v, want the 4th record's hash", head)
+	}
+	if last := trail[len(trail)-1]; last.Meta.Label != "Approve document" || last.At.IsZero() || last.At.Location() != time.UTC {
+		t.Errorf("last record = %+v, want metadata and a UTC time", last)
+	}
+	if trail[0].PrevHash != "" || trail[1].PrevHash != trail[0].Hash || trail[1].Seq != 2 {
+		t.Errorf("records are not chained: %+v", trail[:2])
+	}
+}
+
+func TestVerify_Unkeyed(t *testing.T) {
+	sink, _ := record(t, nil)
+	trail, _ := sink.Query(context.Background(), history.Query{EntityID: "doc-1"})
+	if err := history.Verify(trail, nil, nil); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err := history.Verify(trail, key, nil); !errors.Is(err, history.ErrTampered) {
+		t.Errorf("Verify() with a key error = %v, want %v", err, history.ErrTampered)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	sink, _ := record(t, key)
+	all, _ := sink.Query(context.Background(), history.Query{})
+
+	tests := []struct {
+		name  string
+		query history.Query
+		want  string
+	}{
+		{"actor", history.Query{Actor: "carol"}, "doc-1 carol: Review --Reject--> Draft\ndoc-1 carol: Review --Approve--> Approved"},
+		{"to", history.Query{To: "Approved"}, "doc-1 carol: Review --Approve--> Approved"},
+		{"from and event", history.Query{From: "Draft", Event: "Submit", EntityID: "doc-2"}, "doc-2 bob: Draft --Submit--> Review"},
+		{"since", history.Query{Since: all[4].At, EntityID: "doc-1", Event: "Approve"}, "doc-1 carol: Review --Approve--> Approved"},
+		{"until", history.Query{Until: all[0].At.Add(-time.Second)}, ""},
+	}
+
+	for _, tt := range tests {
+		got, err := sink.Query(context.Background(), tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if describe(got) != tt.want {
+			t.Errorf("%s: Query() =\n%s\nwant:\n%s", tt.name, describe(got), tt.want)
+		}
+	}
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]history.Record) []history.Record
+		want   string
+	}{
+		{
+			name: "altered",
+			tamper: func(rs []history.Record) []history.Record {
+				rs[3].Actor = "mallory"
+				return rs
+			},
+			want: "record 4 of entity doc-1 does not match its hash",
+		},
+		{
+			name: "altered and rehashed",
+			tamper: func(rs []history.Record) []history.Record {
+				// without the chain, rewriting one record's hash would pass
+				rs[1].Actor = "mallory"
+				rs[1].Hash = rs[3].Hash
+				return rs
+			},
+			want: "record 2 of entity doc-1 does not match its hash",
+		},
+		{
+			name: "removed",
+			tamper: func(rs []history.Record) []history.Record {
+				return append(rs[:1], rs[2:]...)
+			},
+			want: "record 2 of entity doc-1 does not follow the one before it",
+		},
+		{
+			name: "first removed",
+			tamper: func(rs []history.Record) []history.Record {
+				return rs[1:]
+			},
+			want: "record 1 of entity doc-1 does not follow the one before it",
+		},
+		{
+			name: "last removed",
+			tamper: func(rs []history.Record) []history.Record {
+				return rs[:len(rs)-1]
+			},
+			want: "trail of entity doc-1 has 3 records, not the 4 recorded",
+		},
+		{
+			name: "rewritten without the key",
+			tamper: func(rs []history.Record) []history.Record {
+				// every hash recomputed, as anyone with the sink could
+				rs[1].Actor = "mallory"
+				rehash(rs, nil)
+				return rs
+			},
+			want: "record 1 of entity doc-1 does not match its hash",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, heads := record(t, key)
+			trail, _ := sink.Query(context.Background(), history.Query{EntityID: "doc-1"})
+			head, _, _ := heads.LoadHead(context.Background(), "doc-1")
+			err := history.Verify(tt.tamper(trail), key, &head)
+			if !errors.Is(err, history.ErrTampered) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Verify() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// rehash recomputes the hashes of records as a Recorder with key would
+func rehash(records []history.Record, key []byte) {
+	var sink history.MemorySink
+	rec := history.NewRecorder(newMachine(), &sink)
+	rec.Key = key
+	for i, r := range records {
+		ctx := statemachine.WithActor(statemachine.WithEntityID(context.Background(), r.EntityID), r.Actor)
+		rec.Notify(ctx, state(r.From), state(r.To), event(r.Event), r.At)
+		records[i], _, _ = sink.Latest(ctx, r.EntityID)
+	}
+}
+
+// failingSink refuses every record
+type failingSink struct {
+	history.MemorySink
+}
+
+func (*failingSink) Append(ctx context.Context, r history.Record) error {
+	return errors.New("disk full")
+}
+
+func TestRecorder_SinkFails(t *testing.T) {
+	sm := newMachine()
+	var logged bytes.Buffer
+	rec := history.NewRecorder(sm, &failingSink{})
+	rec.ErrorLog = log.New(&logged, "", 0)
+	defer rec.Subscribe()()
+
+	ctx := statemachine.WithEntityID(context.Background(), "doc-1")
+	if _, err := sm.TransitionContext(ctx, "Draft", "Submit"); err != nil {
+		t.Fatalf("Transition() error = %v, want the transition unaffected", err)
+	}
+	want := "history: cannot record Draft --Submit--> Review of entity doc-1: disk full"
+	if !strings.Contains(logged.String(), want) {
+		t.Errorf("logged %q, want %q", logged.String(), want)
+	}
+}
+
+func TestRecorder_SinkFailsOnError(t *testing.T) {
+	sm := newMachine()
+	var failed []string
+	rec := history.NewRecorder(sm, &failingSink{})
+	rec.OnError = func(ctx context.Context, r history.Record, err error) {
+		failed = append(failed, r.From+" --"+r.Event+"--> "+r.To+": "+err.Error())
+	}
+	defer rec.Subscribe()()
+
+	ctx := statemachine.WithEntityID(context.Background(), "doc-1")
+	if _, err := sm.TransitionContext(ctx, "Draft", "Submit"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Draft --Submit--> Review: disk full"; len(failed) != 1 || failed[0] != want {
+		t.Errorf("OnError got %q, want [%q]", failed, want)
+	}
+}
+
+func TestRecorder_ChainsFromHead(t *testing.T) {
+	sink, heads := record(t, key)
+	ctx := statemachine.WithEntityID(context.Background(), "doc-1")
+	trail, _ := sink.Query(ctx, history.Query{EntityID: "doc-1"})
+
+	// the last record is removed from the sink, then the entity moves on
+	truncated := &history.MemorySink{}
+	for _, r := range trail[:len(trail)-1] {
+		truncated.Append(ctx, r)
+	}
+	sm := newMachine()
+	var errs []error
+	rec := history.NewRecorder(sm, truncated)
+	rec.Key = key
+	rec.Heads = heads
+	rec.OnError = func(ctx context.Context, r history.Record, err error) { errs = append(errs, err) }
+	defer rec.Subscribe()()
+
+	if _, err := sm.TransitionContext(ctx, "Review", "Reject"); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], history.ErrTampered) {
+		t.Fatalf("OnError got %v, want %v", errs, history.ErrTampered)
+	}
+	after, _ := truncated.Query(ctx, history.Query{EntityID: "doc-1"})
+	if len(after) != len(trail)-1 {
+		t.Errorf("trail has %d records, want the transition refused", len(after))
+	}
+	head, _, _ := heads.LoadHead(ctx, "doc-1")
+	if err := history.Verify(after, key, &head); !errors.Is(err, history.ErrTampered) {
+		t.Errorf("Verify() error = %v, want %v", err, history.ErrTampered)
+	}
+}
@@ -0,0 +1,142 @@
+package statemachine
+
+import (
+	"context"
+	"testing"
+)
+
+type docState string
+
+func (s docState) String() string { return string(s) }
+
+type docEvent string
+
+func (e docEvent) String() string { return string(e) }
+
+const (
+	docDraft        docState = "Draft"
+	docReview       docState = "Review"
+	docFirstReview  docState = "FirstReview"
+	docLegal        docState = "Legal"
+	docLegalDraft   docState = "LegalDraft"
+	docLegalSignoff docState = "LegalSignoff"
+	docPaused       docState = "Paused"
+
+	docSubmit        docEvent = "Submit"
+	docAdvance       docEvent = "Advance"
+	docSign          docEvent = "Sign"
+	docPause         docEvent = "Pause"
+	docResume        docEvent = "Resume"
+	docResumeShallow docEvent = "ResumeShallow"
+	docRestart       docEvent = "Restart"
+)
+
+// newReviewMachine builds a document review where Review contains
+// FirstReview and Legal, and Legal contains LegalDraft and LegalSignoff
+func newReviewMachine(t *testing.T, opts ...Option) *StateMachine[docState, docEvent] {
+	t.Helper()
+	sm := NewStateMachine[docState, docEvent](opts...)
+
+	for _, nest := range [][2]docState{
+		{docFirstReview, docReview},
+		{docLegal, docReview},
+		{docLegalDraft, docLegal},
+		{docLegalSignoff, docLegal},
+	} {
+		if err := sm.SetParent(nest[0], nest[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sm.SetInitialSubstate(docReview, docFirstReview); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.SetInitialSubstate(docLegal, docLegalDraft); err != nil {
+		t.Fatal(err)
+	}
+
+	sm.AddTransition(docDraft, docSubmit, docReview)
+	sm.AddTransition(docFirstReview, docAdvance, docLegal)
+	sm.AddTransition(docLegalDraft, docSign, docLegalSignoff)
+	sm.AddTransition(docReview, docPause, docPaused)
+	sm.AddTransition(docPaused, docResume, docReview, WithHistory[docState, docEvent](DeepHistory))
+	sm.AddTransition(docPaused, docResumeShallow, docReview, WithHistory[docState, docEvent](ShallowHistory))
+	sm.AddTransition(docPaused, docRestart, docReview)
+	return sm
+}
+
+func fireAll(t *testing.T, inst *Instance[docState, docEvent], events ...docEvent) {
+	t.Helper()
+	for _, e := range events {
+		if err := inst.Fire(context.Background(), e); err != nil {
+			t.Fatalf("Fire(%v) from %v error = %v", e, inst.Current(), err)
+		}
+	}
+}
+
+func TestHistory_InitialSubstate(t *testing.T) {
+	sm := newReviewMachine(t)
+
+	got, err := sm.Transition(docDraft, docSubmit)
+	if err != nil || got != docFirstReview {
+		t.Errorf("Transition(Draft, Submit) = %v, %v, want %v", got, err, docFirstReview)
+	}
+	if next, _ := sm.GetNextState(docFirstReview, docAdvance); next != docLegalDraft {
+		t.Errorf("GetNextState(FirstReview, Advance) = %v, want %v", next, docLegalDraft)
+	}
+}
+
+func TestHistory_Resume(t *testing.T) {
+	tests := []struct {
+		name   string
+		resume docEvent
+		want   docState
+	}{
+		{"deep history resumes innermost state", docResume, docLegalSignoff},
+		{"shallow history resumes direct substate", docResumeShallow, docLegalDraft},
+		{"no history starts over", docRestart, docFirstReview},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := newReviewMachine(t).NewInstanceAt(docDraft)
+			fireAll(t, inst, docSubmit, docAdvance, docSign, docPause)
+			if !inst.Is(docPaused) {
+				t.Fatalf("Current() = %v, want %v", inst.Current(), docPaused)
+			}
+
+			fireAll(t, inst, tt.resume)
+			if !inst.Is(tt.want) {
+				t.Errorf("after %v Current() = %v, want %v", tt.resume, inst.Current(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHistory_FirstEntryUsesInitialSubstate(t *testing.T) {
+	inst := newReviewMachine(t).NewInstanceAt(docPaused)
+
+	fireAll(t, inst, docResume)
+	if !inst.Is(docFirstReview) {
+		t.Errorf("Current() = %v, want %v with no history recorded", inst.Current(), docFirstReview)
+	}
+}
+
+func TestHistory_StatelessTransitionIgnoresHistory(t *testing.T) {
+	sm := newReviewMachine(t)
+
+	got, err := sm.Transition(docPaused, docResume)
+	if err != nil || got != docFirstReview {
+		t.Errorf("Transition(Paused, Resume) = %v, %v, want %v", got, err, docFirstReview)
+	}
+}
+
+func TestHistory_SetInitialSubstateRequiresChild(t *testing.T) {
+	sm := newReviewMachine(t)
+
+	if err := sm.SetInitialSubstate(docReview, docLegalDraft); err == nil {
+		t.Errorf("SetInitialSubstate() expected error for a grandchild")
+	}
+	if child, ok := sm.InitialSubstate(docReview); !ok || child != docFirstReview {
+		t.Errorf("InitialSubstate(Review) = %v, %v, want %v", child, ok, docFirstReview)
+	}
+}
@@ -0,0 +1,120 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrVetoed is matched by the error returned for a transition prevented by
+// a BeforeTransition hook
+var ErrVetoed = errors.New("transition vetoed")
+
+// Callback is called when a transition exits or enters a state. It receives
+// the state being left, the state being entered and the triggering event.
+type Callback[S State, E Event] func(ctx context.Context, from, to S, event E)
+
+// OnEnter registers a callback to run whenever a transition enters state.
+// Callbacks for a state run in the order they were registered.
+func (sm *StateMachine[S, E]) OnEnter(state S, fn Callback[S, E]) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.onEnter[state] = append(sm.onEnter[state], fn)
+}
+
+// OnExit registers a callback to run whenever a transition leaves state.
+// Callbacks for a state run in the order they were registered.
+func (sm *StateMachine[S, E]) OnExit(state S, fn Callback[S, E]) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.onExit[state] = append(sm.onExit[state], fn)
+}
+
+// OnFinal registers a callback to run whenever a transition enters a state
+// declared with AddFinalState, after the state's entry callbacks. It gives
+// clean-up that applies however a process ends, such as releasing
+// reserved stock, a single place to live. Callbacks run in the order they
+// were registered.
+func (sm *StateMachine[S, E]) OnFinal(fn Callback[S, E]) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.onFinal = append(sm.onFinal, fn)
+}
+
+// Veto checks a transition about to be made, returning an error to prevent
+// it. Unlike a guard, it sees the state the transition leads to.
+type Veto[S State, E Event] func(ctx context.Context, from S, event E, to S) error
+
+// BeforeTransition registers a hook checking every transition once its
+// target is known, after its guards and permissions have passed but before
+// its actions run or the state changes. If the hook returns an error the
+// transition is aborted, and the error returned wraps it and matches
+// ErrVetoed. Hooks suit rules that apply across the whole machine, such as
+// refusing to move any order into a state its region does not support:
+//
+//	sm.BeforeTransition(func(ctx context.Context, from OrderState, event OrderEvent, to OrderState) error {
+//		if !regions.Supports(ctx, to) {
+//			return fmt.Errorf("%s not available in this region", to)
+//		}
+//		return nil
+//	})
+//
+// Hooks run in the order they were registered, stopping at the first error.
+func (sm *StateMachine[S, E]) BeforeTransition(fn Veto[S, E]) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.vetoes = append(sm.vetoes, fn)
+}
+
+// runCallbacks runs the exit callbacks of from followed by the entry callbacks
+// of to, and then the OnFinal callbacks if to is final. A transition back
+// into the same state exits and re-enters it. When states are nested, the
+// callbacks of every state left and entered on the way run too: exits
+// innermost first, entries outermost first.
+func (sm *StateMachine[S, E]) runCallbacks(ctx context.Context, from, to S, event E) {
+	sm.rlock()
+	exit, enter := sm.callbacksFor(from, to)
+	var final []Callback[S, E]
+	if sm.final[to] {
+		final = sm.onFinal
+	}
+	sm.runlock()
+
+	for _, fn := range exit {
+		fn(ctx, from, to, event)
+	}
+	for _, fn := range enter {
+		fn(ctx, from, to, event)
+	}
+	for _, fn := range final {
+		fn(ctx, from, to, event)
+	}
+}
+
+// callbacksFor returns the exit and entry callbacks runCallbacks runs moving
+// from one state to another. sm must be read locked.
+func (sm *StateMachine[S, E]) callbacksFor(from, to S) (exit, enter []Callback[S, E]) {
+	_, fromNested := sm.parents[from]
+	_, toNested := sm.parents[to]
+	if !fromNested && !toNested {
+		return sm.onExit[from], sm.onEnter[to]
+	}
+	if cb, ok := sm.cache.callbacks(from, to); ok {
+		return cb.exit, cb.enter
+	}
+	exited, entered := sm.exitEnterPath(from, to)
+	for _, s := range exited {
+		exit = append(exit, sm.onExit[s]...)
+	}
+	for _, s := range entered {
+		enter = append(enter, sm.onEnter[s]...)
+	}
+	return exit, enter
+}
@@ -0,0 +1,211 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestStateMachine_EnterExitCallbacks(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	var calls []string
+	record := func(name string) Callback[UserState, UserEvent] {
+		return func(ctx context.Context, from, to UserState, event UserEvent) {
+			calls = append(calls, fmt.Sprintf("%s %s->%s on %s", name, from, to, event))
+		}
+	}
+
+	sm.OnExit(UserStateInitial, record("exit"))
+	sm.OnEnter(UserStateEmailPendingVerification, record("enter1"))
+	sm.OnEnter(UserStateEmailPendingVerification, record("enter2"))
+	sm.OnEnter(UserStateSignUpComplete, record("complete"))
+
+	if _, err := sm.Transition(UserStateInitial, UserEventSubmitSignUp); err != nil {
+		t.Fatalf("Transition() error = %v", err)
+	}
+
+	want := []string{
+		"exit Initial->EmailPendingVerification on SubmitSignup",
+		"enter1 Initial->EmailPendingVerification on SubmitSignup",
+		"enter2 Initial->EmailPendingVerification on SubmitSignup",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("callbacks = %v, want %v", calls, want)
+	}
+}
+
+func TestStateMachine_CallbacksNotRunOnInvalidTransition(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	called := false
+	sm.OnExit(UserStateInitial, func(ctx context.Context, from, to UserState, event UserEvent) {
+		called = true
+	})
+
+	if _, err := sm.Transition(UserStateInitial, UserEventCompleteProfile); err == nil {
+		t.Fatalf("Transition() expected error for invalid transition")
+	}
+	if called {
+		t.Errorf("exit callback ran for a rejected transition")
+	}
+}
+
+func TestStateMachine_CallbacksReceiveContext(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	type key struct{}
+	var got any
+	sm.OnEnter(UserStateEmailVerified, func(ctx context.Context, from, to UserState, event UserEvent) {
+		got = ctx.Value(key{})
+	})
+
+	ctx := context.WithValue(context.Background(), key{}, "user-42")
+	if _, err := sm.TransitionContext(ctx, UserStateEmailPendingVerification, UserEventClickVerificationLink); err != nil {
+		t.Fatalf("TransitionContext() error = %v", err)
+	}
+	if got != "user-42" {
+		t.Errorf("callback context value = %v, want user-42", got)
+	}
+}
+
+func TestStateMachine_ValidateTransitionPathSkipsCallbacks(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	called := false
+	sm.OnEnter(UserStateEmailVerified, func(ctx context.Context, from, to UserState, event UserEvent) {
+		called = true
+	})
+
+	_, err := sm.ValidateTransitionPath(UserStateInitial, []UserEvent{UserEventSubmitSignUp, UserEventClickVerificationLink})
+	if err != nil {
+		t.Fatalf("ValidateTransitionPath() error = %v", err)
+	}
+	if called {
+		t.Errorf("ValidateTransitionPath() ran an entry callback")
+	}
+}
+
+func TestStateMachine_OnFinal(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  OrderState
+		event OrderEvent
+		want  []string
+	}{
+		{"entering a final state", OrderStateShipped, OrderEventDeliver, []string{"enter Delivered", "final 1 Delivered", "final 2 Delivered"}},
+		{"entering a final state from a nested one", OrderStatePacking, OrderEventCancel, []string{"final 1 Cancelled", "final 2 Cancelled"}},
+		{"entering another state", OrderStatePending, OrderEventConfirm, nil},
+	}
+
+	for _, frozen := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/frozen=%v", tt.name, frozen), func(t *testing.T) {
+				var calls []string
+				sm := NewOrderStateMachine()
+				sm.AddFinalState(OrderStateDelivered)
+				sm.AddFinalState(OrderStateCancelled)
+				sm.OnEnter(OrderStateDelivered, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+					calls = append(calls, "enter "+string(to))
+				})
+				for _, n := range []string{"1", "2"} {
+					sm.OnFinal(func(ctx context.Context, from, to OrderState, event OrderEvent) {
+						calls = append(calls, "final "+n+" "+string(to))
+					})
+				}
+				if frozen {
+					sm.Freeze()
+				}
+
+				if _, err := sm.Transition(tt.from, tt.event); err != nil {
+					t.Fatalf("Transition() error = %v", err)
+				}
+				if !reflect.DeepEqual(calls, tt.want) {
+					t.Errorf("callbacks = %v, want %v", calls, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestStateMachine_BeforeTransition(t *testing.T) {
+	errClosed := errors.New("warehouse closed")
+	tests := []struct {
+		name       string
+		from       OrderState
+		event      OrderEvent
+		want       OrderState
+		wantErr    error
+		wantChecks []string
+		wantRan    bool
+	}{
+		{
+			name:       "allowed",
+			from:       OrderStatePending,
+			event:      OrderEventConfirm,
+			want:       OrderStatePacking,
+			wantChecks: []string{"first Pending->Packing", "second Pending->Packing"},
+			wantRan:    true,
+		},
+		{
+			name:       "vetoed",
+			from:       OrderStateAwaiting,
+			event:      OrderEventShip,
+			wantErr:    errClosed,
+			wantChecks: []string{"first AwaitingCourier->Shipped"},
+		},
+		{
+			name:  "invalid transitions are not checked",
+			from:  OrderStatePending,
+			event: OrderEventShip,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checks []string
+			ran := false
+			b := NewBuilder[OrderState, OrderEvent]()
+			for _, tr := range NewOrderStateMachine().GetAllTransitions() {
+				b.From(tr.From).On(tr.Event).To(tr.To).WithAction(func(ctx context.Context, from, to OrderState, event OrderEvent) error {
+					ran = true
+					return nil
+				})
+			}
+			b.BeforeTransition(func(ctx context.Context, from OrderState, event OrderEvent, to OrderState) error {
+				checks = append(checks, fmt.Sprintf("first %s->%s", from, to))
+				if to == OrderStateShipped {
+					return errClosed
+				}
+				return nil
+			})
+			b.BeforeTransition(func(ctx context.Context, from OrderState, event OrderEvent, to OrderState) error {
+				checks = append(checks, fmt.Sprintf("second %s->%s", from, to))
+				return nil
+			})
+			sm := b.MustBuild()
+
+			got, err := sm.Transition(tt.from, tt.event)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) || !errors.Is(err, ErrVetoed) {
+					t.Fatalf("Transition() error = %v, want %v matching %v", err, tt.wantErr, ErrVetoed)
+				}
+			case tt.want == "":
+				if !errors.Is(err, ErrInvalidTransition) {
+					t.Fatalf("Transition() error = %v, want %v", err, ErrInvalidTransition)
+				}
+			case err != nil || got != tt.want:
+				t.Fatalf("Transition() = %v, %v, want %v", got, err, tt.want)
+			}
+			if !reflect.DeepEqual(checks, tt.wantChecks) {
+				t.Errorf("hooks saw %v, want %v", checks, tt.wantChecks)
+			}
+			if ran != tt.wantRan {
+				t.Errorf("action ran = %v, want %v", ran, tt.wantRan)
+			}
+		})
+	}
+}
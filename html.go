@@ -0,0 +1,500 @@
+package statemachine
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+)
+
+// HTMLOptions configures ExportHTML
+type HTMLOptions struct {
+	// Title is shown at the top of the page; it defaults to "State machine"
+	Title string
+}
+
+// ExportHTML writes the machine to w as a self-contained HTML page, with no
+// scripts or styles loaded from elsewhere, for sharing with people who do not
+// read Go. The page draws every state and transition; selecting a state
+// highlights the transitions valid from it, including inherited ones, and
+// the states they lead to, and lists its events and metadata alongside.
+//
+// States are laid out in columns by their distance from the initial state,
+// or from the states nothing leads to if there is none. Like the other
+// exporters, the output is stable: the same machine always produces the same
+// page.
+func (sm *StateMachine[S, E]) ExportHTML(w io.Writer, opts HTMLOptions) error {
+	if opts.Title == "" {
+		opts.Title = "State machine"
+	}
+
+	sm.rlock()
+	d := sm.diagram()
+	states := diagramOrder(d)
+	index := make(map[S]int, len(states))
+	for i, s := range states {
+		index[s] = i
+	}
+
+	page := htmlPage{Title: opts.Title}
+	htmlLayout(&page, d, states, htmlColumns(d, states))
+	edgeIndex := htmlEdges(&page, d, index)
+	for _, s := range states {
+		page.States = append(page.States, sm.htmlState(d, s, index, edgeIndex))
+	}
+	sm.runlock()
+
+	return htmlTemplate.Execute(w, page)
+}
+
+// htmlPage is the data the page template renders
+type htmlPage struct {
+	Title         string
+	Width, Height int
+	Nodes         []htmlNode
+	Edges         []htmlEdge
+
+	HasStart       bool
+	StartX, StartY int
+	StartPath      string
+
+	// States describes each node, by index, for the script
+	States []htmlState
+}
+
+type htmlNode struct {
+	X, Y, W, H int
+	Label      string
+	Final      bool
+	Composite  bool
+
+	// CX and CY are the centre of the node, where its label goes
+	CX, CY int
+}
+
+type htmlEdge struct {
+	Path           string
+	Label          string
+	LabelX, LabelY int
+	Guarded        bool
+}
+
+type htmlState struct {
+	Name        string      `json:"name"`
+	Label       string      `json:"label,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	Parent      string      `json:"parent,omitempty"`
+	Initial     bool        `json:"initial,omitempty"`
+	Final       bool        `json:"final,omitempty"`
+	Events      []htmlEvent `json:"events"`
+}
+
+type htmlEvent struct {
+	Event string `json:"event"`
+	Label string `json:"label,omitempty"`
+
+	// Guarded is set when every transition for the event is guarded, so
+	// it may be rejected
+	Guarded bool `json:"guarded,omitempty"`
+
+	// Targets are node indexes and Edges edge indexes
+	Targets []int `json:"targets"`
+	Edges   []int `json:"edges"`
+}
+
+const (
+	htmlMargin    = 60
+	htmlNodeH     = 44
+	htmlRowGap    = 40
+	htmlColumnGap = 150
+	htmlCharWidth = 8
+)
+
+// diagramOrder returns every state of the diagram, parents before their
+// children
+func diagramOrder[S State, E Event](d diagram[S, E]) []S {
+	var states []S
+	var add func(s S)
+	add = func(s S) {
+		states = append(states, s)
+		for _, child := range d.children[s] {
+			add(child)
+		}
+	}
+	for _, s := range d.roots {
+		add(s)
+	}
+	return states
+}
+
+// htmlColumns places each state in the column of its distance from the
+// initial state. Substates count as one step from their parent. States that
+// cannot be reached start again from the first column.
+func htmlColumns[S State, E Event](d diagram[S, E], states []S) map[S]int {
+	next := make(map[S][]S)
+	inbound := make(map[S]bool)
+	for _, e := range d.edges {
+		if e.from != e.to {
+			next[e.from] = append(next[e.from], e.to)
+			inbound[e.to] = true
+		}
+	}
+	for parent, children := range d.children {
+		next[parent] = append(next[parent], children...)
+		for _, child := range children {
+			inbound[child] = true
+		}
+	}
+
+	columns := make(map[S]int, len(states))
+	walk := func(starts []S) {
+		var queue []S
+		for _, s := range starts {
+			if _, seen := columns[s]; !seen {
+				columns[s] = 0
+				queue = append(queue, s)
+			}
+		}
+		for len(queue) > 0 {
+			s := queue[0]
+			queue = queue[1:]
+			for _, n := range next[s] {
+				if _, seen := columns[n]; !seen {
+					columns[n] = columns[s] + 1
+					queue = append(queue, n)
+				}
+			}
+		}
+	}
+
+	if d.hasInitial {
+		walk([]S{d.initial})
+	} else {
+		var starts []S
+		for _, s := range states {
+			if !inbound[s] {
+				starts = append(starts, s)
+			}
+		}
+		walk(starts)
+	}
+	for _, s := range states {
+		walk([]S{s})
+	}
+	return columns
+}
+
+// htmlLayout positions a node for each state, in order, and sizes the page
+func htmlLayout[S State, E Event](p *htmlPage, d diagram[S, E], states []S, columns map[S]int) {
+	var widths []int
+	rows := make(map[int]int)
+	p.Nodes = make([]htmlNode, len(states))
+	for i, s := range states {
+		label := d.label(s)
+		n := htmlNode{
+			W:         max(120, len([]rune(label))*htmlCharWidth+32),
+			H:         htmlNodeH,
+			Label:     label,
+			Final:     d.final[s],
+			Composite: len(d.children[s]) > 0,
+		}
+		c := columns[s]
+		for len(widths) <= c {
+			widths = append(widths, 0)
+		}
+		widths[c] = max(widths[c], n.W)
+		n.Y = htmlMargin + rows[c]*(htmlNodeH+htmlRowGap)
+		rows[c]++
+		p.Nodes[i] = n
+	}
+
+	x := make([]int, len(widths))
+	right := htmlMargin
+	for c, w := range widths {
+		x[c] = right
+		right += w + htmlColumnGap
+	}
+	for i, s := range states {
+		c := columns[s]
+		// centre each node in its column
+		p.Nodes[i].X = x[c] + (widths[c]-p.Nodes[i].W)/2
+		p.Nodes[i].CX, p.Nodes[i].CY = p.Nodes[i].X+p.Nodes[i].W/2, p.Nodes[i].Y+htmlNodeH/2
+		p.Height = max(p.Height, p.Nodes[i].Y+htmlNodeH+htmlMargin)
+	}
+	p.Width = right - htmlColumnGap + htmlMargin
+
+	if d.hasInitial {
+		for i, s := range states {
+			if s == d.initial {
+				n := p.Nodes[i]
+				p.HasStart = true
+				p.StartX, p.StartY = n.X-36, n.Y+n.H/2
+				p.StartPath = fmt.Sprintf("M %d %d L %d %d", p.StartX+6, p.StartY, n.X, p.StartY)
+			}
+		}
+	}
+}
+
+// htmlEdges draws each edge of the diagram between the nodes of its states,
+// returning the indexes of the edges drawn for each transition. Edges
+// between the same two states are curved apart so their labels do not
+// overlap.
+func htmlEdges[S State, E Event](p *htmlPage, d diagram[S, E], index map[S]int) map[*edge[S, E]][]int {
+	type pair struct{ a, b int }
+	pairOf := func(e diagramEdge[S, E]) pair {
+		a, b := index[e.from], index[e.to]
+		return pair{min(a, b), max(a, b)}
+	}
+	total := make(map[pair]int)
+	for _, e := range d.edges {
+		total[pairOf(e)]++
+	}
+
+	drawn := make(map[pair]int)
+	edgeIndex := make(map[*edge[S, E]][]int)
+	for _, e := range d.edges {
+		pr := pairOf(e)
+		k := drawn[pr]
+		drawn[pr]++
+
+		var he htmlEdge
+		if pr.a == pr.b {
+			he = htmlLoop(p.Nodes[pr.a], k)
+		} else {
+			offset := (float64(k) - float64(total[pr]-1)/2) * 56
+			he = htmlCurve(p.Nodes[index[e.from]], p.Nodes[index[e.to]], p.Nodes[pr.a], p.Nodes[pr.b], offset)
+		}
+		he.Label = e.eventLabel()
+		he.Guarded = e.guarded
+
+		edgeIndex[e.src] = append(edgeIndex[e.src], len(p.Edges))
+		p.Edges = append(p.Edges, he)
+		p.Height = max(p.Height, he.LabelY+htmlMargin)
+	}
+	return edgeIndex
+}
+
+// htmlCurve draws an edge from one node to another, bent by offset to the
+// side of the line from a to b
+func htmlCurve(from, to, a, b htmlNode, offset float64) htmlEdge {
+	fx, fy := nodeCentre(from)
+	tx, ty := nodeCentre(to)
+	ax, ay := nodeCentre(a)
+	bx, by := nodeCentre(b)
+	length := math.Hypot(bx-ax, by-ay)
+	// the control point sits off the midpoint, perpendicular to a to b
+	cx := (fx+tx)/2 - (by-ay)/length*offset
+	cy := (fy+ty)/2 + (bx-ax)/length*offset
+
+	sx, sy := nodeBorder(from, cx, cy)
+	ex, ey := nodeBorder(to, cx, cy)
+	return htmlEdge{
+		Path:   fmt.Sprintf("M %.0f %.0f Q %.0f %.0f %.0f %.0f", sx, sy, cx, cy, ex, ey),
+		LabelX: int(math.Round(sx/4 + cx/2 + ex/4)),
+		LabelY: int(math.Round(sy/4+cy/2+ey/4)) - 6,
+	}
+}
+
+// htmlLoop draws the k'th edge from a node to itself above the node
+func htmlLoop(n htmlNode, k int) htmlEdge {
+	cx, top := float64(n.X)+float64(n.W)/2, float64(n.Y)
+	height := 40 + 22*float64(k)
+	return htmlEdge{
+		Path: fmt.Sprintf("M %.0f %.0f C %.0f %.0f %.0f %.0f %.0f %.0f",
+			cx-16, top, cx-40, top-height, cx+40, top-height, cx+16, top),
+		LabelX: int(cx),
+		LabelY: int(top - height*0.75 - 6),
+	}
+}
+
+func nodeCentre(n htmlNode) (float64, float64) {
+	return float64(n.X) + float64(n.W)/2, float64(n.Y) + float64(n.H)/2
+}
+
+// nodeBorder returns where the line from the centre of n towards a point
+// crosses the border of n
+func nodeBorder(n htmlNode, x, y float64) (float64, float64) {
+	cx, cy := nodeCentre(n)
+	dx, dy := x-cx, y-cy
+	if dx == 0 && dy == 0 {
+		return cx, cy
+	}
+	scale := math.Inf(1)
+	if dx != 0 {
+		scale = float64(n.W) / 2 / math.Abs(dx)
+	}
+	if dy != 0 {
+		scale = math.Min(scale, float64(n.H)/2/math.Abs(dy))
+	}
+	return cx + dx*scale, cy + dy*scale
+}
+
+// htmlState describes a state and the events valid from it for the page's
+// script. Callers must hold the read lock.
+func (sm *StateMachine[S, E]) htmlState(d diagram[S, E], s S, index map[S]int, edgeIndex map[*edge[S, E]][]int) htmlState {
+	hs := htmlState{
+		Name:    Name(s),
+		Initial: d.hasInitial && d.initial == s,
+		Final:   d.final[s],
+		Events:  []htmlEvent{},
+	}
+	if m, ok := d.stateMeta[s]; ok {
+		hs.Label, hs.Description, hs.Tags = m.Label, m.Description, m.Tags
+	}
+	if parent, ok := sm.parents[s]; ok {
+		hs.Parent = Name(parent)
+	}
+
+	effective := sm.effectiveTransitions(s)
+	for _, event := range sm.validEvents(s) {
+		he := htmlEvent{Event: Name(event), Label: effective[event].meta.Label, Guarded: true}
+		seen := make(map[int]bool)
+		for _, e := range sm.edgesFor(s, event) {
+			if len(e.guards) == 0 {
+				he.Guarded = false
+			}
+			for _, target := range e.targets() {
+				i := index[sm.resolveTarget(target, NoHistory, nil)]
+				if !seen[i] {
+					seen[i] = true
+					he.Targets = append(he.Targets, i)
+				}
+			}
+			he.Edges = append(he.Edges, edgeIndex[e]...)
+		}
+		hs.Events = append(hs.Events, he)
+	}
+	return hs
+}
+
+var htmlTemplate = template.Must(template.New("html").Parse(htmlSource))
+
+const htmlSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; display: flex; height: 100vh; font-family: system-ui, sans-serif; color: #222; }
+main { flex: 1; overflow: auto; }
+aside { width: 320px; padding: 0 20px; border-left: 1px solid #ddd; overflow: auto; }
+h1 { margin: 16px 20px 0; font-size: 20px; }
+h2 { font-size: 18px; }
+h3 { font-size: 15px; }
+.muted { color: #666; }
+.node { cursor: pointer; }
+.node rect { fill: #fff; stroke: #555; stroke-width: 1.5; }
+.node.composite rect { fill: #f4f6fb; stroke-dasharray: 5 3; }
+.node text { font-size: 14px; text-anchor: middle; dominant-baseline: central; }
+.node.selected rect { stroke: #1a73e8; stroke-width: 3; }
+.node.target rect { fill: #e8f0fe; stroke: #1a73e8; }
+.node rect.inner { fill: none; }
+.edge path { fill: none; stroke: #999; stroke-width: 1.5; marker-end: url(#arrow); }
+.edge.guarded path { stroke-dasharray: 6 4; }
+.edge text { font-size: 12px; fill: #666; text-anchor: middle; }
+.edge.active path { stroke: #1a73e8; stroke-width: 2.5; marker-end: url(#arrow-active); }
+.edge.active text { fill: #1a73e8; font-weight: bold; }
+.dim { opacity: 0.25; }
+.start { fill: #222; }
+.start-line { stroke: #222; stroke-width: 1.5; marker-end: url(#arrow-start); }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<svg id="graph" width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}" role="img" aria-label="{{.Title}}">
+<defs>
+<marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="7" markerHeight="7" orient="auto"><path d="M 0 0 L 10 5 L 0 10 z" fill="#999"/></marker>
+<marker id="arrow-active" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto"><path d="M 0 0 L 10 5 L 0 10 z" fill="#1a73e8"/></marker>
+<marker id="arrow-start" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="7" markerHeight="7" orient="auto"><path d="M 0 0 L 10 5 L 0 10 z" fill="#222"/></marker>
+</defs>
+{{- if .HasStart}}
+<circle class="start" cx="{{.StartX}}" cy="{{.StartY}}" r="6"/>
+<path class="start-line" d="{{.StartPath}}"/>
+{{- end}}
+{{- range .Edges}}
+<g class="edge{{if .Guarded}} guarded{{end}}"><path d="{{.Path}}"/><text x="{{.LabelX}}" y="{{.LabelY}}">{{.Label}}</text></g>
+{{- end}}
+{{- range .Nodes}}
+<g class="node{{if .Composite}} composite{{end}}" tabindex="0">
+<rect x="{{.X}}" y="{{.Y}}" width="{{.W}}" height="{{.H}}" rx="8"/>
+{{- if .Final}}
+<rect class="inner" x="{{.X}}" y="{{.Y}}" width="{{.W}}" height="{{.H}}" rx="8" transform="translate({{.CX}} {{.CY}}) scale(0.92 0.8) translate(-{{.CX}} -{{.CY}})"/>
+{{- end}}
+<text x="{{.CX}}" y="{{.CY}}">{{.Label}}</text>
+</g>
+{{- end}}
+</svg>
+</main>
+<aside id="info"><p class="muted" id="hint">Select a state to see the events valid from it.</p></aside>
+<script>
+const states = {{.States}};
+const nodes = document.querySelectorAll(".node");
+const edges = document.querySelectorAll(".edge");
+const info = document.getElementById("info");
+const hint = document.getElementById("hint");
+
+function add(tag, text, parent) {
+  const el = document.createElement(tag);
+  el.textContent = text;
+  (parent || info).appendChild(el);
+  return el;
+}
+
+function clear() {
+  for (const el of [...nodes, ...edges]) {
+    el.classList.remove("selected", "target", "active", "dim");
+  }
+  info.replaceChildren(hint);
+}
+
+function select(i) {
+  clear();
+  const s = states[i];
+  const targets = new Set();
+  const active = new Set();
+  for (const e of s.events) {
+    e.targets.forEach(t => targets.add(t));
+    e.edges.forEach(x => active.add(x));
+  }
+  nodes.forEach((n, j) => n.classList.add(j === i ? "selected" : targets.has(j) ? "target" : "dim"));
+  edges.forEach((e, j) => e.classList.add(active.has(j) ? "active" : "dim"));
+
+  info.replaceChildren();
+  add("h2", s.label || s.name);
+  if (s.label) {
+    add("p", s.name).className = "muted";
+  }
+  if (s.description) {
+    add("p", s.description);
+  }
+  const facts = [];
+  if (s.initial) facts.push("initial state");
+  if (s.final) facts.push("final state");
+  if (s.parent) facts.push("inside " + s.parent);
+  for (const t of s.tags || []) facts.push("#" + t);
+  if (facts.length) {
+    add("p", facts.join(" · ")).className = "muted";
+  }
+  add("h3", "Valid events");
+  if (!s.events.length) {
+    add("p", "None, this state is terminal.").className = "muted";
+    return;
+  }
+  const list = add("ul", "");
+  for (const e of s.events) {
+    const to = e.targets.map(t => states[t].name).join(" or ");
+    add("li", (e.label || e.event) + " → " + to + (e.guarded ? " (guarded)" : ""), list);
+  }
+}
+
+nodes.forEach((n, i) => {
+  n.addEventListener("click", ev => { ev.stopPropagation(); select(i); });
+  n.addEventListener("keydown", ev => { if (ev.key === "Enter") select(i); });
+});
+document.getElementById("graph").addEventListener("click", clear);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,100 @@
+package statemachine
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestExportHTML(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.SetInitialState(OrderStatePending)
+	sm.AddFinalState(OrderStateRefunded)
+	sm.SetStateMeta(OrderStateCancelled, Metadata{Label: "Cancelled <by user>", Description: "The order was stopped."})
+
+	var buf bytes.Buffer
+	if err := sm.ExportHTML(&buf, HTMLOptions{Title: "Orders"}); err != nil {
+		t.Fatalf("ExportHTML() error = %v", err)
+	}
+	page := buf.String()
+
+	for _, want := range []string{
+		"<title>Orders</title>",
+		`<circle class="start"`,
+		`<rect class="inner"`,
+		">Cancelled &lt;by user&gt;</text>",
+		`<g class="node composite" tabindex="0">`,
+		">Confirm</text>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("ExportHTML() missing %q", want)
+		}
+	}
+	if strings.Contains(page, "<by user>") {
+		t.Error("ExportHTML() did not escape a state label")
+	}
+	for _, external := range []string{"src=", "href=", "@import"} {
+		if strings.Contains(page, external) {
+			t.Errorf("ExportHTML() loads %q, want a self-contained page", external)
+		}
+	}
+
+	var again bytes.Buffer
+	if err := sm.ExportHTML(&again, HTMLOptions{Title: "Orders"}); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != page {
+		t.Error("ExportHTML() is not stable between calls")
+	}
+}
+
+func TestExportHTML_States(t *testing.T) {
+	sm := NewOrderStateMachine()
+
+	var buf bytes.Buffer
+	if err := sm.ExportHTML(&buf, HTMLOptions{}); err != nil {
+		t.Fatalf("ExportHTML() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "<title>State machine</title>") {
+		t.Error("ExportHTML() did not default the title")
+	}
+
+	match := regexp.MustCompile(`const states = (.*);\n`).FindStringSubmatch(buf.String())
+	if match == nil {
+		t.Fatal("ExportHTML() has no state data")
+	}
+	var states []htmlState
+	if err := json.Unmarshal([]byte(match[1]), &states); err != nil {
+		t.Fatalf("state data is not JSON: %v", err)
+	}
+	byName := make(map[string]htmlState)
+	for _, s := range states {
+		byName[s.Name] = s
+	}
+
+	// Packing inherits Cancel from Processing
+	packing := byName["Packing"]
+	if packing.Parent != "Processing" {
+		t.Errorf("Packing parent = %q, want Processing", packing.Parent)
+	}
+	var events []string
+	for _, e := range packing.Events {
+		var targets []string
+		for _, i := range e.Targets {
+			targets = append(targets, states[i].Name)
+		}
+		events = append(events, e.Event+" -> "+strings.Join(targets, ","))
+		if len(e.Edges) == 0 {
+			t.Errorf("Packing event %s highlights no edges", e.Event)
+		}
+	}
+	want := []string{"Cancel -> Cancelled", "Pack -> AwaitingCourier"}
+	if strings.Join(events, "; ") != strings.Join(want, "; ") {
+		t.Errorf("Packing events = %v, want %v", events, want)
+	}
+	if len(byName["Refunded"].Events) != 0 {
+		t.Errorf("Refunded events = %v, want none", byName["Refunded"].Events)
+	}
+}
@@ -0,0 +1,102 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// idempotencyKeyKey is the context key idempotency keys are stored under
+type idempotencyKeyKey struct{}
+
+// WithIdempotencyKey returns a copy of ctx carrying a key that identifies the
+// event being processed, such as the delivery ID of a webhook. A Manager
+// with a DedupStore applies the event for each key once per entity, and
+// answers deliveries repeating the key with the state the first left the
+// entity in:
+//
+//	ctx = statemachine.WithIdempotencyKey(ctx, r.Header.Get("Idempotency-Key"))
+//	state, err := orders.Fire(ctx, orderID, OrderEventPay)
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// IdempotencyKeyFrom returns the idempotency key carried by ctx, if there is
+// a non-empty one
+func IdempotencyKeyFrom(ctx context.Context) (string, bool) {
+	key, _ := ctx.Value(idempotencyKeyKey{}).(string)
+	return key, key != ""
+}
+
+// DedupStore remembers the outcome of events processed with an idempotency
+// key, so that a Manager can answer repeats of them
+type DedupStore[S State] interface {
+	// Lookup returns the state the event with key left an entity in, and
+	// whether the key has been recorded for the entity
+	Lookup(ctx context.Context, id, key string) (state S, ok bool, err error)
+
+	// Record remembers the state the event with key left an entity in
+	Record(ctx context.Context, id, key string, state S) error
+}
+
+// replay returns the state recorded for the idempotency key carried by ctx,
+// if m has a DedupStore and the key has been recorded for the entity
+func (m *Manager[S, E]) replay(ctx context.Context, id string) (S, bool, error) {
+	key, ok := IdempotencyKeyFrom(ctx)
+	if !ok || m.Dedup == nil {
+		var zero S
+		return zero, false, nil
+	}
+	state, ok, err := m.Dedup.Lookup(ctx, id, key)
+	if err != nil {
+		return state, false, fmt.Errorf("cannot look up idempotency key %q of entity %s: %w", key, id, err)
+	}
+	return state, ok, nil
+}
+
+// record remembers the state an entity was left in by the event with the
+// idempotency key carried by ctx
+func (m *Manager[S, E]) record(ctx context.Context, id string, state S) error {
+	key, ok := IdempotencyKeyFrom(ctx)
+	if !ok || m.Dedup == nil {
+		return nil
+	}
+	if err := m.Dedup.Record(ctx, id, key, state); err != nil {
+		return fmt.Errorf("cannot record idempotency key %q of entity %s: %w", key, id, err)
+	}
+	return nil
+}
+
+// MemoryDedupStore is a DedupStore held in memory, for tests and as a
+// reference for other implementations. It keeps every key it is given;
+// stores for production use should let keys expire once repeats are no
+// longer expected. The zero value is ready to use.
+type MemoryDedupStore[S State] struct {
+	mu      sync.Mutex
+	results map[dedupKey]S
+}
+
+type dedupKey struct {
+	id, key string
+}
+
+// Lookup returns the state recorded for an entity's key
+func (s *MemoryDedupStore[S]) Lookup(ctx context.Context, id, key string) (S, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.results[dedupKey{id, key}]
+	return state, ok, nil
+}
+
+// Record stores the state for an entity's key
+func (s *MemoryDedupStore[S]) Record(ctx context.Context, id, key string, state S) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.results == nil {
+		s.results = make(map[dedupKey]S)
+	}
+	s.results[dedupKey{id, key}] = state
+	return nil
+}
@@ -0,0 +1,136 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIdempotencyKeyFrom(t *testing.T) {
+	ctx := context.Background()
+	if key, ok := IdempotencyKeyFrom(ctx); ok {
+		t.Errorf("IdempotencyKeyFrom() = %q, true without a key", key)
+	}
+	if key, ok := IdempotencyKeyFrom(WithIdempotencyKey(ctx, "")); ok {
+		t.Errorf("IdempotencyKeyFrom() = %q, true for an empty key", key)
+	}
+	if key, ok := IdempotencyKeyFrom(WithIdempotencyKey(ctx, "delivery-1")); !ok || key != "delivery-1" {
+		t.Errorf("IdempotencyKeyFrom() = %q, %v, want delivery-1", key, ok)
+	}
+}
+
+func TestManager_Dedup(t *testing.T) {
+	orders, store := newOrderManager()
+	orders.Dedup = &MemoryDedupStore[OrderState]{}
+	ctx := context.Background()
+	for _, id := range []string{"order-1", "order-2"} {
+		if err := orders.Create(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		id      string
+		key     string
+		event   OrderEvent
+		want    OrderState
+		wantErr error
+	}{
+		{"order-1", "k1", OrderEventConfirm, OrderStatePacking, nil},
+		{"order-1", "k1", OrderEventConfirm, OrderStatePacking, nil},
+		{"order-1", "k2", OrderEventDeliver, OrderStatePacking, ErrInvalidTransition},
+		{"order-1", "k2", OrderEventPack, OrderStateAwaiting, nil},
+		{"order-1", "k2", OrderEventPack, OrderStateAwaiting, nil},
+		{"order-1", "k1", OrderEventConfirm, OrderStatePacking, nil},
+		{"order-2", "k1", OrderEventConfirm, OrderStatePacking, nil},
+		{"order-2", "", OrderEventCancel, OrderStateCancelled, nil},
+		{"order-2", "", OrderEventCancel, OrderStateCancelled, ErrInvalidTransition},
+	}
+	for i, step := range steps {
+		got, err := orders.Fire(WithIdempotencyKey(ctx, step.key), step.id, step.event)
+		if !errors.Is(err, step.wantErr) || got != step.want {
+			t.Fatalf("step %d: Fire(%s, %s) = %v, %v, want %v, %v", i+1, step.id, step.event, got, err, step.want, step.wantErr)
+		}
+	}
+
+	for id, want := range map[string]int64{"order-1": 3, "order-2": 3} {
+		if _, version, _ := store.Load(ctx, id); version != want {
+			t.Errorf("%s saved at version %d, want %d", id, version, want)
+		}
+	}
+
+	// a repeat is answered even once the entity has moved on from the
+	// version it was sent for
+	got, err := orders.FireVersion(WithIdempotencyKey(ctx, "k1"), "order-1", 0, OrderEventConfirm)
+	if err != nil || got != OrderStatePacking {
+		t.Errorf("FireVersion() of repeat = %v, %v, want %v", got, err, OrderStatePacking)
+	}
+}
+
+// failingDedupStore fails every lookup or record
+type failingDedupStore struct {
+	lookupErr, recordErr error
+}
+
+func (s failingDedupStore) Lookup(ctx context.Context, id, key string) (OrderState, bool, error) {
+	return "", false, s.lookupErr
+}
+
+func (s failingDedupStore) Record(ctx context.Context, id, key string, state OrderState) error {
+	return s.recordErr
+}
+
+func TestManager_DedupFails(t *testing.T) {
+	errStore := errors.New("store unavailable")
+	tests := []struct {
+		name      string
+		dedup     failingDedupStore
+		want      OrderState
+		wantSaved bool
+	}{
+		{"lookup", failingDedupStore{lookupErr: errStore}, "", false},
+		{"record", failingDedupStore{recordErr: errStore}, OrderStatePacking, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, store := newOrderManager()
+			orders.Dedup = tt.dedup
+			ctx := context.Background()
+			if err := orders.Create(ctx, "order-1"); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := orders.Fire(WithIdempotencyKey(ctx, "k1"), "order-1", OrderEventConfirm)
+			if !errors.Is(err, errStore) || got != tt.want {
+				t.Errorf("Fire() = %v, %v, want %v, %v", got, err, tt.want, errStore)
+			}
+			if state, _, _ := store.Load(ctx, "order-1"); (state == OrderStatePacking) != tt.wantSaved {
+				t.Errorf("entity saved in %v", state)
+			}
+		})
+	}
+}
+
+func TestEngine_IdempotencyKey(t *testing.T) {
+	m := newCounterManager(t, 3)
+	m.Dedup = &MemoryDedupStore[fulfilState]{}
+	ctx := context.Background()
+	if err := m.Create(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine(m, 2)
+	defer engine.Close()
+
+	replies := make(chan Result[fulfilState, fulfilEvent], 3)
+	events := engine.Events()
+	defer close(events)
+	for _, key := range []string{"k1", "k1", "k2"} {
+		events <- EventEnvelope[fulfilState, fulfilEvent]{EntityID: "a", Event: "Next", IdempotencyKey: key, Reply: replies}
+	}
+	for i, want := range []fulfilState{"1", "1", "2"} {
+		if r := <-replies; r.Err != nil || r.State != want {
+			t.Errorf("reply %d = %+v, want %v", i+1, r, want)
+		}
+	}
+}
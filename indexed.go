@@ -0,0 +1,127 @@
+package statemachine
+
+// Integer is a constraint for the integer types NewIndexedStateMachine
+// takes as states and events
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// maxIndexSize is the most entries an index's transition table may hold.
+// Machines whose states and events span more are not indexed.
+const maxIndexSize = 1 << 16
+
+// NewIndexedStateMachine creates a state machine for states and events that
+// are small non-negative integers, such as iota enums:
+//
+//	type Light int
+//
+//	const (
+//		Red Light = iota
+//		Green
+//		Amber
+//	)
+//
+//	sm := statemachine.NewIndexedStateMachine[Light, Signal]()
+//	sm.AddTransition(Red, Go, Green)
+//	...
+//	sm.Freeze()
+//
+// It behaves like any other machine, but when it is frozen its transitions
+// are laid out in a dense table indexed by state and event, so transitioning
+// looks them up without hashing or walking the state hierarchy. The table
+// has an entry for every pair of state and event up to the largest of each,
+// so a machine whose values are negative, or span too many to be worth a
+// table, is left as it is.
+func NewIndexedStateMachine[S, E Integer](opts ...Option) *StateMachine[S, E] {
+	sm := NewStateMachine[S, E](opts...)
+	sm.stateIndex = func(s S) int { return int(s) }
+	sm.eventIndex = func(e E) int { return int(e) }
+	return sm
+}
+
+// transitionTable holds the transitions available from each state, as
+// edgesFor returns them, by state and event index
+type transitionTable[S State, E Event] struct {
+	states, events int
+	edges          [][]*edge[S, E]
+
+	// resolved is the state each state resolves to when entered without
+	// history, and declared whether the machine has the state at all, by
+	// state index. Indexes the machine has no state for are gaps.
+	resolved []S
+	declared []bool
+}
+
+// buildIndex lays out the machine's transitions in a table, if it was
+// created by NewIndexedStateMachine and its states and events fit one.
+// sm must be locked.
+func (sm *StateMachine[S, E]) buildIndex() {
+	if sm.stateIndex == nil {
+		return
+	}
+	states, events := sm.allStates(), sm.allEvents()
+	t := &transitionTable[S, E]{}
+	for _, s := range states {
+		i := sm.stateIndex(s)
+		if i < 0 || i >= maxIndexSize {
+			return
+		}
+		t.states = max(t.states, i+1)
+	}
+	for _, e := range events {
+		i := sm.eventIndex(e)
+		if i < 0 || i >= maxIndexSize {
+			return
+		}
+		t.events = max(t.events, i+1)
+	}
+	if t.states*t.events > maxIndexSize {
+		return
+	}
+
+	t.edges = make([][]*edge[S, E], t.states*t.events)
+	t.resolved = make([]S, t.states)
+	t.declared = make([]bool, t.states)
+	for _, s := range states {
+		i := sm.stateIndex(s)
+		t.resolved[i] = sm.resolveTarget(s, NoHistory, nil)
+		t.declared[i] = true
+		for _, e := range events {
+			t.edges[i*t.events+sm.eventIndex(e)] = sm.edgesFor(s, e)
+		}
+	}
+	sm.table = t
+}
+
+// indexedEdges returns the transitions edgesFor would, from the machine's
+// table. ok is false if the machine has no table, or from and event are
+// outside it or from is a gap in it.
+func (sm *StateMachine[S, E]) indexedEdges(from S, event E) (edges []*edge[S, E], ok bool) {
+	t := sm.table
+	if t == nil {
+		return nil, false
+	}
+	i, j := sm.stateIndex(from), sm.eventIndex(event)
+	if i < 0 || i >= t.states || j < 0 || j >= t.events || !t.declared[i] {
+		return nil, false
+	}
+	return t.edges[i*t.events+j], true
+}
+
+// indexedTarget returns the state state resolves to when entered without
+// history, from the machine's table. ok is false if the machine has no
+// table, or state is outside it or a gap in it.
+func (sm *StateMachine[S, E]) indexedTarget(state S) (S, bool) {
+	t := sm.table
+	if t == nil {
+		var zero S
+		return zero, false
+	}
+	i := sm.stateIndex(state)
+	if i < 0 || i >= t.states || !t.declared[i] {
+		var zero S
+		return zero, false
+	}
+	return t.resolved[i], true
+}
@@ -0,0 +1,160 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type phase uint8
+
+const (
+	phaseIdle phase = iota
+	phaseActive
+	phaseRunning
+	phasePaused
+	phaseDone
+)
+
+type signal int
+
+const (
+	signalStart signal = iota
+	signalPause
+	signalResume
+	signalStop
+	signalReset
+)
+
+// newPhaseMachine builds the same machine either way, with Active a
+// composite state and competing guarded transitions on Stop
+func newPhaseMachine(indexed bool) *StateMachine[phase, signal] {
+	sm := NewStateMachine[phase, signal]()
+	if indexed {
+		sm = NewIndexedStateMachine[phase, signal]()
+	}
+	sm.AddTransition(phaseIdle, signalStart, phaseActive)
+	sm.AddTransition(phaseRunning, signalPause, phasePaused)
+	sm.AddTransition(phasePaused, signalResume, phaseRunning)
+	sm.AddTransition(phaseActive, signalStop, phaseDone)
+	sm.AddTransition(phaseRunning, signalStop, phaseIdle,
+		WithGuard(func(ctx context.Context, from phase, event signal) bool { return ctx.Value(signalReset) != nil }))
+	sm.AddTransition(phaseDone, signalReset, phaseIdle)
+	sm.SetParent(phaseRunning, phaseActive)
+	sm.SetParent(phasePaused, phaseActive)
+	if err := sm.SetInitialSubstate(phaseActive, phaseRunning); err != nil {
+		panic(err)
+	}
+	return sm.Freeze()
+}
+
+func TestNewIndexedStateMachine(t *testing.T) {
+	plain, indexed := newPhaseMachine(false), newPhaseMachine(true)
+	if indexed.table == nil {
+		t.Fatal("frozen indexed machine has no table")
+	}
+	if plain.table != nil {
+		t.Fatal("plain machine has a table")
+	}
+
+	reset := context.WithValue(context.Background(), signalReset, true)
+	for _, ctx := range []context.Context{context.Background(), reset} {
+		for from := range phase(7) {
+			for event := signal(-1); event < 7; event++ {
+				want, wantErr := plain.TransitionContext(ctx, from, event)
+				got, err := indexed.TransitionContext(ctx, from, event)
+				if got != want || (err == nil) != (wantErr == nil) || (err != nil && err.Error() != wantErr.Error()) {
+					t.Errorf("TransitionContext(%d, %d) = %v, %v, want %v, %v", from, event, got, err, want, wantErr)
+				}
+				if indexed.CanTransition(from, event) != plain.CanTransition(from, event) {
+					t.Errorf("CanTransition(%d, %d) differs from the plain machine's", from, event)
+				}
+			}
+		}
+	}
+
+	if _, err := indexed.Transition(phase(200), signalStart); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("Transition() from a state outside the table error = %v, want %v", err, ErrUnknownState)
+	}
+	if clone := indexed.Clone(); clone.table != nil || clone.Freeze().table == nil {
+		t.Error("clone of an indexed machine is not indexed once frozen")
+	}
+}
+
+func TestNewIndexedStateMachine_Gaps(t *testing.T) {
+	plain, indexed := NewStateMachine[phase, signal](), NewIndexedStateMachine[phase, signal]()
+	for _, sm := range []*StateMachine[phase, signal]{plain, indexed} {
+		sm.AddTransition(phaseIdle, signalStart, phaseRunning)
+		sm.AddTransition(phaseRunning, signalStop, phaseIdle)
+		sm.Freeze()
+	}
+	if indexed.table == nil {
+		t.Fatal("frozen indexed machine has no table")
+	}
+
+	if got, ok := indexed.indexedTarget(phaseActive); ok {
+		t.Errorf("indexedTarget(Active) = %v, true, want the gap reported", got)
+	}
+	if _, ok := indexed.indexedEdges(phaseActive, signalStart); ok {
+		t.Error("indexedEdges(Active) ok = true, want the gap reported")
+	}
+	if got := indexed.resolveTarget(phaseActive, NoHistory, nil); got != phaseActive {
+		t.Errorf("resolveTarget(Active) = %v, want Active itself", got)
+	}
+	for event := range signal(5) {
+		want, wantErr := plain.Transition(phaseActive, event)
+		got, err := indexed.Transition(phaseActive, event)
+		if got != want || !errors.Is(err, ErrUnknownState) || err.Error() != wantErr.Error() {
+			t.Errorf("Transition(Active, %d) = %v, %v, want %v, %v", event, got, err, want, wantErr)
+		}
+	}
+}
+
+func TestNewIndexedStateMachine_NotIndexed(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(sm *StateMachine[int, int])
+	}{
+		{"negative state", func(sm *StateMachine[int, int]) { sm.AddTransition(-1, 0, 1) }},
+		{"negative event", func(sm *StateMachine[int, int]) { sm.AddTransition(0, -1, 1) }},
+		{"table too large", func(sm *StateMachine[int, int]) { sm.AddTransition(0, maxIndexSize, maxIndexSize) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewIndexedStateMachine[int, int]()
+			tt.build(sm)
+			sm.Freeze()
+			if sm.table != nil {
+				t.Error("machine was indexed")
+			}
+			if got, want := sm.CanTransition(0, 0), len(sm.transitions[0][0]) > 0; got != want {
+				t.Errorf("CanTransition() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func BenchmarkTransition_Indexed(b *testing.B) {
+	for _, tt := range []struct {
+		name    string
+		indexed bool
+	}{{"maps", false}, {"indexed", true}} {
+		for _, from := range []phase{phasePaused, phaseRunning} {
+			b.Run(fmt.Sprintf("%s/%d", tt.name, from), func(b *testing.B) {
+				benchmarkPhaseTransition(b, newPhaseMachine(tt.indexed), from)
+			})
+		}
+	}
+}
+
+// benchmarkPhaseTransition stops sm from a state, which goes straight to
+// Done from Paused and past a refusing guard from Running
+func benchmarkPhaseTransition(b *testing.B, sm *StateMachine[phase, signal], from phase) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := sm.Transition(from, signalStop); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrTransitioning is returned by the Instance methods that cannot be
+// called while the instance is transitioning, from one of its own guards,
+// actions, callbacks or subscribers
+var ErrTransitioning = errors.New("instance is transitioning")
+
+// Instance is a single entity moving through a state machine. It pairs a
+// shared machine definition with the entity's current state, so callers do
+// not have to track and reassign the state themselves.
+//
+// An Instance is not safe for concurrent use.
+type Instance[S State, E Event] struct {
+	sm      *StateMachine[S, E]
+	current S
+	history history[S]
+	data    map[string]any
+	version int64
+
+	// enteredAt is when the current state was entered, and dwell the time
+	// spent in each state on stays that have ended
+	enteredAt time.Time
+	dwell     map[S]time.Duration
+
+	// deadline is when the timeout of timed, the current state or the
+	// composite state it is in, fires
+	deadline time.Time
+	timed    S
+
+	// scheduled holds the events due to fire later, soonest first
+	scheduled []ScheduledEvent[E]
+	nextID    int64
+
+	// firing is set while a transition runs, and queue holds the events
+	// fired during it, to be processed once it completes
+	firing bool
+	queue  []queuedEvent[E]
+
+	// completions fire the completion events of NotifyCompletion
+	completions []func(ctx context.Context) error
+
+	// undo holds the transitions Undo can take back, latest last
+	undo []undoEntry[S, E]
+
+	// frames holds the instance's recorded history, oldest first
+	frames []Frame[S]
+
+	// deferred holds the events deferred until the instance reaches a state
+	// that does not defer them
+	deferred []queuedEvent[E]
+}
+
+// queuedEvent is an event fired while the instance was transitioning
+type queuedEvent[E Event] struct {
+	ctx   context.Context
+	event E
+}
+
+// NewInstanceAt creates an instance of the machine in the given state,
+// typically the state last stored for an entity
+func (sm *StateMachine[S, E]) NewInstanceAt(state S) *Instance[S, E] {
+	i := &Instance[S, E]{
+		sm:        sm,
+		current:   state,
+		enteredAt: sm.clock.Now(),
+	}
+	i.enter(state, state)
+	i.record("", false)
+	return i
+}
+
+// NewInstance creates an instance of the machine in its initial state. It
+// panics if no initial state has been set with SetInitialState.
+func (sm *StateMachine[S, E]) NewInstance() *Instance[S, E] {
+	return sm.NewInstanceAt(sm.startState())
+}
+
+func (sm *StateMachine[S, E]) startState() S {
+	sm.rlock()
+	defer sm.runlock()
+
+	if !sm.hasInitialState {
+		panic("statemachine: NewInstance called on a machine with no initial state")
+	}
+	return sm.resolveTarget(sm.initialState, NoHistory, nil)
+}
+
+// Machine returns the state machine the instance follows
+func (i *Instance[S, E]) Machine() *StateMachine[S, E] {
+	return i.sm
+}
+
+// Current returns the instance's current state
+func (i *Instance[S, E]) Current() S {
+	return i.current
+}
+
+// Version returns the instance's version, which starts at 0, or at the
+// version restored from a Snapshot, and increases by one with every
+// successful transition
+func (i *Instance[S, E]) Version() int64 {
+	return i.version
+}
+
+// Is reports whether the instance is currently in state
+func (i *Instance[S, E]) Is(state S) bool {
+	return i.current == state
+}
+
+// IsIn reports whether the instance is in state or in any state nested
+// inside it
+func (i *Instance[S, E]) IsIn(state S) bool {
+	return i.sm.IsSubstateOf(i.current, state)
+}
+
+// IsFinished reports whether the instance has reached a final state
+func (i *Instance[S, E]) IsFinished() bool {
+	return i.sm.IsFinalState(i.current)
+}
+
+// SetData stores a value with the instance under key, such as a retry
+// count or the ID of a document being reviewed, so it travels with the
+// instance's state and is kept by Snapshot
+func (i *Instance[S, E]) SetData(key string, value any) {
+	if i.data == nil {
+		i.data = make(map[string]any)
+	}
+	i.data[key] = value
+}
+
+// Data returns the value stored under key with SetData
+func (i *Instance[S, E]) Data(key string) (any, bool) {
+	v, ok := i.data[key]
+	return v, ok
+}
+
+// dataKey is the context key an instance's data is passed under
+type dataKey struct{}
+
+// DataFrom returns the data stored with SetData on the instance
+// transitioning with ctx, so guards, actions and callbacks written without
+// the instance to hand, such as those built from expressions, can read it.
+// The map must not be modified; use SetData.
+func DataFrom(ctx context.Context) (map[string]any, bool) {
+	data, ok := ctx.Value(dataKey{}).(map[string]any)
+	return data, ok
+}
+
+// CanFire reports whether event is valid from the current state
+func (i *Instance[S, E]) CanFire(event E) bool {
+	return i.sm.CanTransition(i.current, event)
+}
+
+// ValidEvents returns the events valid from the current state
+func (i *Instance[S, E]) ValidEvents() []E {
+	return i.sm.GetValidEvents(i.current)
+}
+
+// Fire transitions the instance via event. On error the instance stays in
+// its current state.
+//
+// Transitions run to completion: an event fired at the instance by one of
+// its own guards, actions, callbacks or subscribers while it transitions
+// is queued, and Fire returns nil for it straight away. Once the
+// transition completes, the queued events are processed in the order they
+// were fired. If the transition fails, the events it queued are dropped,
+// and the error of a queued event that fails is returned by the Fire that
+// began the run, after the rest of the queue has been processed.
+func (i *Instance[S, E]) Fire(ctx context.Context, event E) error {
+	if i.firing {
+		i.queue = append(i.queue, queuedEvent[E]{ctx: ctx, event: event})
+		return nil
+	}
+	return i.run(ctx, func() error { return i.step(ctx, event) })
+}
+
+// run makes a transition with first, then processes the events queued
+// during it
+func (i *Instance[S, E]) run(ctx context.Context, first func() error) error {
+	i.firing = true
+	defer func() {
+		i.firing = false
+		i.queue = nil
+	}()
+
+	if err := first(); err != nil {
+		return err
+	}
+	errs := i.complete(ctx, nil)
+	for len(i.queue) > 0 {
+		q := i.queue[0]
+		i.queue = i.queue[1:]
+		from := i.current
+		if err := i.step(q.ctx, q.event); err != nil {
+			errs = append(errs, fmt.Errorf("queued event '%s' from state '%s' failed: %w", Name(q.event), Name(from), err))
+			continue
+		}
+		errs = i.complete(q.ctx, errs)
+	}
+	return errors.Join(errs...)
+}
+
+// complete fires the instance's completion events if it is in a final
+// state, appending their errors to errs
+func (i *Instance[S, E]) complete(ctx context.Context, errs []error) []error {
+	if len(i.completions) == 0 || !i.IsFinished() {
+		return errs
+	}
+	for _, fn := range i.completions {
+		if err := fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("completion event from state '%s' failed: %w", Name(i.current), err))
+		}
+	}
+	return errs
+}
+
+// NotifyCompletion makes child fire event at parent whenever it reaches a
+// final state, so a process run within another, such as a payment within
+// an order, moves the outer one on when it completes:
+//
+//	payment := paymentMachine.NewInstance()
+//	statemachine.NotifyCompletion(payment, order, OrderEventPaid)
+//
+// The completion event is fired with the context of the event that brought
+// child to its final state, as soon as that transition completes and before
+// any events queued during it are processed. If it fails, its error is
+// returned by child's Fire, though child stays in the final state. The link
+// is not kept by Snapshot, so make it again for a restored instance.
+func NotifyCompletion[S State, E Event, PS State, PE Event](child *Instance[S, E], parent *Instance[PS, PE], event PE) {
+	child.completions = append(child.completions, func(ctx context.Context) error {
+		return parent.Fire(ctx, event)
+	})
+}
+
+// step performs a single transition, dropping the events queued during it
+// if it fails, or holds on to event if it is deferred
+func (i *Instance[S, E]) step(ctx context.Context, event E) error {
+	if i.deferring(event) {
+		i.hold(ctx, event)
+		return nil
+	}
+	queued := len(i.queue)
+	if i.data != nil {
+		ctx = context.WithValue(ctx, dataKey{}, i.data)
+	}
+	to, err := i.sm.transition(ctx, i.current, event, &i.history)
+	if err != nil {
+		i.queue = i.queue[:queued]
+		return err
+	}
+	i.remember(i.current, event)
+	now := i.sm.clock.Now()
+	i.leave(now)
+	i.enter(i.current, to)
+	i.current = to
+	i.enteredAt = now
+	i.version++
+	i.record(Name(event), false)
+	i.release()
+	return nil
+}
+
+// FireVersion transitions the instance via event, provided it is still at
+// version. Otherwise it fails with ErrConcurrentModification, without
+// running guards or actions, because the instance has moved on since the
+// caller read it.
+func (i *Instance[S, E]) FireVersion(ctx context.Context, version int64, event E) error {
+	if i.version != version {
+		return fmt.Errorf("instance is at version %d, not %d: %w", i.version, version, ErrConcurrentModification)
+	}
+	return i.Fire(ctx, event)
+}
+
+// FireAll transitions the instance via each event in turn, all or nothing:
+// either every transition succeeds, or the instance is put back as it was
+// before the first, with its state, data, history and version restored.
+// Use it for steps that only make sense together, such as packing and
+// shipping an order:
+//
+//	err := order.FireAll(ctx, OrderEventPack, OrderEventShip)
+//
+// The events are first checked with ValidateTransitionPath, so a sequence
+// the machine does not allow fails before any actions run. If a guard or
+// action then fails part way, the compensations attached with
+// WithCompensation to the transitions already made are run, most recent
+// first, to undo their actions; if one fails, its error is returned along
+// with that of the failed step. Callbacks and subscribers of the
+// transitions already made have run, and are not told of the rollback.
+//
+// Events fired at the instance while FireAll runs are processed once every
+// event has been applied, as with Fire. FireAll returns ErrTransitioning if
+// called while the instance is transitioning.
+func (i *Instance[S, E]) FireAll(ctx context.Context, events ...E) error {
+	if i.firing {
+		return ErrTransitioning
+	}
+	if _, err := i.sm.ValidateTransitionPath(i.current, events); err != nil {
+		return err
+	}
+	saved, undo, nextID, deferred := i.Snapshot(), slices.Clone(i.undo), i.nextID, slices.Clone(i.deferred)
+
+	return i.run(ctx, func() error {
+		var steps []sagaStep[S, E]
+		for n, event := range events {
+			from := i.current
+			var compensations []Action[S, E]
+			if err := i.step(context.WithValue(ctx, compensationsKey{}, &compensations), event); err != nil {
+				errs := []error{fmt.Errorf("step %d of %d: %w", n+1, len(events), err)}
+				for j := len(steps) - 1; j >= 0; j-- {
+					if err := steps[j].compensate(ctx); err != nil {
+						errs = append(errs, err)
+					}
+				}
+				if err := i.Restore(saved); err != nil {
+					errs = append(errs, fmt.Errorf("restoring state: %w", err))
+				}
+				i.undo, i.nextID, i.deferred = undo, nextID, deferred
+				return errors.Join(errs...)
+			}
+			steps = append(steps, sagaStep[S, E]{from: from, to: i.current, event: event, compensations: compensations})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,374 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestInstance_Fire(t *testing.T) {
+	sm := NewUserStateMachine()
+	inst := sm.NewInstanceAt(UserStateInitial)
+	ctx := context.Background()
+
+	steps := []struct {
+		event UserEvent
+		want  UserState
+	}{
+		{UserEventSubmitSignUp, UserStateEmailPendingVerification},
+		{UserEventClickVerificationLink, UserStateEmailVerified},
+		{UserEventCompleteProfile, UserStateSignUpComplete},
+	}
+
+	for _, step := range steps {
+		if err := inst.Fire(ctx, step.event); err != nil {
+			t.Fatalf("Fire(%v) error = %v", step.event, err)
+		}
+		if !inst.Is(step.want) {
+			t.Errorf("after Fire(%v) Current() = %v, want %v", step.event, inst.Current(), step.want)
+		}
+	}
+}
+
+func TestInstance_FireInvalidKeepsState(t *testing.T) {
+	inst := NewUserStateMachine().NewInstanceAt(UserStateInitial)
+
+	if err := inst.Fire(context.Background(), UserEventCompleteProfile); err == nil {
+		t.Fatalf("Fire() expected error for invalid transition")
+	}
+	if inst.Current() != UserStateInitial {
+		t.Errorf("Current() = %v after rejected event, want %v", inst.Current(), UserStateInitial)
+	}
+}
+
+func TestInstance_CanFireAndValidEvents(t *testing.T) {
+	inst := NewUserStateMachine().NewInstanceAt(UserStateEmailVerified)
+
+	if !inst.CanFire(UserEventCompleteProfile) {
+		t.Errorf("CanFire(CompleteProfile) = false, want true")
+	}
+	if inst.CanFire(UserEventSubmitSignUp) {
+		t.Errorf("CanFire(SubmitSignUp) = true, want false")
+	}
+	if got := len(inst.ValidEvents()); got != 2 {
+		t.Errorf("ValidEvents() returned %d events, want 2", got)
+	}
+}
+
+func TestInstance_RunsCallbacks(t *testing.T) {
+	sm := NewUserStateMachine()
+	entered := 0
+	sm.OnEnter(UserStateRejected, func(ctx context.Context, from, to UserState, event UserEvent) {
+		entered++
+	})
+
+	inst := sm.NewInstanceAt(UserStateEmailVerified)
+	if err := inst.Fire(context.Background(), UserEventSignupFailed); err != nil {
+		t.Fatalf("Fire() error = %v", err)
+	}
+	if entered != 1 {
+		t.Errorf("entry callback ran %d times, want 1", entered)
+	}
+}
+
+func TestInstance_FireVersion(t *testing.T) {
+	inst := NewUserStateMachine().NewInstanceAt(UserStateInitial)
+	ctx := context.Background()
+
+	steps := []struct {
+		version     int64
+		event       UserEvent
+		want        UserState
+		wantVersion int64
+		wantErr     error
+	}{
+		{0, UserEventSubmitSignUp, UserStateEmailPendingVerification, 1, nil},
+		{0, UserEventClickVerificationLink, UserStateEmailPendingVerification, 1, ErrConcurrentModification},
+		{1, UserEventCompleteProfile, UserStateEmailPendingVerification, 1, ErrInvalidTransition},
+		{1, UserEventClickVerificationLink, UserStateEmailVerified, 2, nil},
+		{5, UserEventCompleteProfile, UserStateEmailVerified, 2, ErrConcurrentModification},
+	}
+	for i, step := range steps {
+		err := inst.FireVersion(ctx, step.version, step.event)
+		if !errors.Is(err, step.wantErr) || step.wantErr == nil && err != nil {
+			t.Fatalf("step %d: FireVersion(%d, %v) error = %v, want %v", i+1, step.version, step.event, err, step.wantErr)
+		}
+		if !inst.Is(step.want) || inst.Version() != step.wantVersion {
+			t.Errorf("step %d: instance at %v, version %d, want %v, version %d", i+1, inst.Current(), inst.Version(), step.want, step.wantVersion)
+		}
+	}
+}
+
+func TestInstance_RunToCompletion(t *testing.T) {
+	errDeclined := errors.New("card declined")
+	var (
+		inst *Instance[fulfilState, fulfilEvent]
+		log  []string
+	)
+	raise := func(events ...fulfilEvent) Action[fulfilState, fulfilEvent] {
+		return func(ctx context.Context, from, to fulfilState, event fulfilEvent) error {
+			for _, e := range events {
+				if err := inst.Fire(ctx, e); err != nil {
+					t.Errorf("Fire(%v) during a transition error = %v", e, err)
+				}
+			}
+			return nil
+		}
+	}
+	sm := NewStateMachine[fulfilState, fulfilEvent]()
+	sm.AddTransition("Pending", "Pay", "Paid", WithAction(raise("Reserve", "Label")))
+	sm.AddTransition("Pending", "PayLater", "Invoiced", WithAction(raise("Reserve", "Charge")))
+	sm.AddTransition("Paid", "Reserve", "Reserved")
+	sm.AddTransition("Invoiced", "Reserve", "Reserved")
+	sm.AddTransition("Reserved", "Label", "Labelled")
+	sm.AddTransition("Reserved", "Charge", "Charged",
+		WithAction(raise("Label")),
+		WithAction(func(ctx context.Context, from, to fulfilState, event fulfilEvent) error {
+			return errDeclined
+		}))
+	sm.AddTransition("Labelled", "Ship", "Shipped")
+	sm.OnEnter("Paid", func(ctx context.Context, from, to fulfilState, event fulfilEvent) {
+		log = append(log, "entered Paid from "+from.String())
+		if err := inst.Fire(ctx, "Ship"); err != nil {
+			t.Errorf("Fire(Ship) in a callback error = %v", err)
+		}
+	})
+	sm.OnEnter("Reserved", func(ctx context.Context, from, to fulfilState, event fulfilEvent) {
+		log = append(log, "entered Reserved from "+from.String())
+	})
+
+	tests := []struct {
+		name        string
+		event       fulfilEvent
+		want        fulfilState
+		wantLog     []string
+		wantVersion int64
+		wantErr     error
+	}{
+		{
+			name:  "queued events run after the transition, in order",
+			event: "Pay",
+			want:  "Shipped",
+			wantLog: []string{
+				"entered Paid from Pending",
+				"entered Reserved from Paid",
+			},
+			wantVersion: 4,
+		},
+		{
+			name:        "a failed queued event drops the events it queued",
+			event:       "PayLater",
+			want:        "Reserved",
+			wantLog:     []string{"entered Reserved from Invoiced"},
+			wantVersion: 2,
+			wantErr:     errDeclined,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log = nil
+			inst = sm.NewInstanceAt("Pending")
+
+			err := inst.Fire(context.Background(), tt.event)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Fire() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Fire() error = %v, want %v", err, tt.wantErr)
+			}
+			if inst.Current() != tt.want || inst.Version() != tt.wantVersion {
+				t.Errorf("at %v, version %d, want %v, version %d", inst.Current(), inst.Version(), tt.want, tt.wantVersion)
+			}
+			if !slices.Equal(log, tt.wantLog) {
+				t.Errorf("callbacks saw %q, want %q", log, tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestNotifyCompletion(t *testing.T) {
+	payments := NewStateMachine[fulfilState, fulfilEvent]()
+	payments.AddTransition("Pending", "Authorize", "Authorized")
+	payments.AddTransition("Authorized", "Capture", "Captured")
+	payments.AddFinalState("Captured")
+
+	tests := []struct {
+		name       string
+		parentAt   OrderState
+		wantParent OrderState
+		wantErr    error
+	}{
+		{"completion moves the parent on", OrderStateShipped, OrderStateDelivered, nil},
+		{"completion rejected by the parent", OrderStatePending, OrderStatePending, ErrInvalidTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := NewOrderStateMachine().NewInstanceAt(tt.parentAt)
+			child := payments.NewInstanceAt("Pending")
+			NotifyCompletion(child, parent, OrderEventDeliver)
+			ctx := context.Background()
+
+			if err := child.Fire(ctx, "Authorize"); err != nil {
+				t.Fatalf("Fire(Authorize) error = %v", err)
+			}
+			if parent.Current() != tt.parentAt {
+				t.Fatalf("parent moved to %v before the child finished", parent.Current())
+			}
+			err := child.Fire(ctx, "Capture")
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Fire(Capture) error = %v, want %v", err, tt.wantErr)
+			}
+			if !child.IsFinished() {
+				t.Errorf("child in %v, want it finished", child.Current())
+			}
+			if parent.Current() != tt.wantParent {
+				t.Errorf("parent in %v, want %v", parent.Current(), tt.wantParent)
+			}
+		})
+	}
+}
+
+func TestInstance_FireAll(t *testing.T) {
+	errCourier := errors.New("no courier")
+	errRestock := errors.New("cannot restock")
+	tests := []struct {
+		name         string
+		events       []fulfilEvent
+		shipErr      error
+		restockErr   error
+		want         fulfilState
+		wantErr      []error
+		wantLog      []string
+		wantVersion  int64
+		wantReserved bool
+	}{
+		{
+			name:         "all succeed",
+			events:       []fulfilEvent{"Pack", "Ship"},
+			want:         "Shipped",
+			wantLog:      []string{"reserve", "ship"},
+			wantVersion:  2,
+			wantReserved: true,
+		},
+		{
+			name:    "invalid path",
+			events:  []fulfilEvent{"Pack", "Deliver"},
+			want:    "Open",
+			wantErr: []error{ErrInvalidTransition},
+		},
+		{
+			name:    "action fails",
+			events:  []fulfilEvent{"Pack", "Ship"},
+			shipErr: errCourier,
+			want:    "Open",
+			wantErr: []error{errCourier},
+			wantLog: []string{"reserve", "ship", "restock"},
+		},
+		{
+			name:       "compensation fails",
+			events:     []fulfilEvent{"Pack", "Ship"},
+			shipErr:    errCourier,
+			restockErr: errRestock,
+			want:       "Open",
+			wantErr:    []error{errCourier, errRestock},
+			wantLog:    []string{"reserve", "ship", "restock"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log []string
+			sm := NewStateMachine[fulfilState, fulfilEvent](WithReversible(5), WithTimeline(5))
+			sm.AddTransition("Open", "Pack", "Packed",
+				WithAction(func(ctx context.Context, from, to fulfilState, event fulfilEvent) error {
+					log = append(log, "reserve")
+					return nil
+				}),
+				WithCompensation(func(ctx context.Context, from, to fulfilState, event fulfilEvent) error {
+					log = append(log, "restock")
+					return tt.restockErr
+				}))
+			sm.AddTransition("Packed", "Ship", "Shipped",
+				WithAction(func(ctx context.Context, from, to fulfilState, event fulfilEvent) error {
+					log = append(log, "ship")
+					return tt.shipErr
+				}))
+			sm.AddTransition("Shipped", "Deliver", "Delivered")
+			inst := sm.NewInstanceAt("Open")
+			sm.OnEnter("Packed", func(ctx context.Context, from, to fulfilState, event fulfilEvent) {
+				inst.SetData("reserved", true)
+			})
+
+			err := inst.FireAll(context.Background(), tt.events...)
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("FireAll() error = %v, want %v", err, want)
+				}
+			}
+			if err != nil && tt.wantErr == nil {
+				t.Errorf("FireAll() error = %v", err)
+			}
+			if got := inst.Current(); got != tt.want {
+				t.Errorf("Current() = %v, want %v", got, tt.want)
+			}
+			if got := inst.Version(); got != tt.wantVersion {
+				t.Errorf("Version() = %d, want %d", got, tt.wantVersion)
+			}
+			if _, ok := inst.Data("reserved"); ok != tt.wantReserved {
+				t.Errorf("Data(reserved) set = %v, want %v", ok, tt.wantReserved)
+			}
+			if !slices.Equal(log, tt.wantLog) {
+				t.Errorf("actions = %v, want %v", log, tt.wantLog)
+			}
+			if got := inst.Timeline().Len(); got != int(tt.wantVersion)+1 {
+				t.Errorf("Timeline().Len() = %d, want %d", got, tt.wantVersion+1)
+			}
+			if inst.CanUndo() != (tt.wantVersion > 0) {
+				t.Errorf("CanUndo() = %v after FireAll", inst.CanUndo())
+			}
+		})
+	}
+}
+
+func TestInstance_FireAllWhileTransitioning(t *testing.T) {
+	sm := NewStateMachine[fulfilState, fulfilEvent]()
+	sm.AddTransition("Open", "Pack", "Packed")
+	sm.AddTransition("Packed", "Ship", "Shipped")
+	inst := sm.NewInstanceAt("Open")
+	var err error
+	sm.OnExit("Open", func(ctx context.Context, from, to fulfilState, event fulfilEvent) {
+		err = inst.FireAll(ctx, "Ship")
+	})
+
+	if err := inst.Fire(context.Background(), "Pack"); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(err, ErrTransitioning) {
+		t.Errorf("FireAll() during a transition error = %v, want %v", err, ErrTransitioning)
+	}
+}
+
+func TestDataFrom(t *testing.T) {
+	sm := NewStateMachine[fulfilState, fulfilEvent]()
+	sm.AddTransition("Open", "Ship", "Shipped",
+		WithGuard(func(ctx context.Context, from fulfilState, event fulfilEvent) bool {
+			data, _ := DataFrom(ctx)
+			return data["carrier"] != nil
+		}))
+	inst := sm.NewInstanceAt("Open")
+	ctx := context.Background()
+
+	if _, ok := DataFrom(ctx); ok {
+		t.Error("DataFrom() found data outside a transition")
+	}
+	if err := inst.Fire(ctx, "Ship"); !errors.Is(err, ErrGuardRejected) {
+		t.Errorf("Fire() without data error = %v, want %v", err, ErrGuardRejected)
+	}
+	inst.SetData("carrier", "DHL")
+	if err := inst.Fire(ctx, "Ship"); err != nil {
+		t.Errorf("Fire() with data error = %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package statemachine
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ToDefinition describes the machine as a Definition, the format read by
+// FromDefinition and LoadFS. States and events are named by Name and
+// sorted by name, as are transitions.
+//
+// Guards resolved by name, as FromDefinitionWithGuards and LoadYAML do, are
+// written by those names, along with the transitions competing with them in
+// the order they are tried. Other guards cannot be described, so where such
+// a transition competes for a state and event only the first tried is
+// included, without its guards. A choice is written with its target as
+// returned by GetNextState. Actions, callbacks and the state hierarchy are
+// left out.
+func (sm *StateMachine[S, E]) ToDefinition() *Definition {
+	sm.rlock()
+	defer sm.runlock()
+
+	def := &Definition{
+		Schema:      DefinitionSchemaID,
+		Version:     sm.version,
+		States:      []StateDefinition{},
+		Events:      []EventDefinition{},
+		Transitions: []TransitionDefinition{},
+	}
+	if sm.hasInitialState {
+		def.Initial = Name(sm.initialState)
+	}
+
+	states := sm.allStates()
+	sortStates(states)
+	for _, s := range states {
+		m := sm.stateMeta[s]
+		sd := StateDefinition{
+			Name:        Name(s),
+			Label:       m.Label,
+			Description: m.Description,
+			Tags:        m.Tags,
+			Final:       sm.final[s],
+		}
+		if t, ok := sm.timeouts[s]; ok {
+			sd.Timeout = &TimeoutDefinition{After: t.after.String(), Event: Name(t.event)}
+		}
+		def.States = append(def.States, sd)
+	}
+
+	for _, e := range sm.allEvents() {
+		def.Events = append(def.Events, EventDefinition{Name: Name(e)})
+	}
+
+	var transitions []Transition[S, E]
+	for k, to := range sm.declaredTargets() {
+		transitions = append(transitions, Transition[S, E]{From: k.from, Event: k.event, To: to})
+	}
+	sortTransitions(transitions)
+	for _, t := range transitions {
+		for _, e := range sm.transitions[t.From][t.Event] {
+			named := len(e.guardNames) == len(e.guards)
+			td := TransitionDefinition{
+				From:        Name(t.From),
+				Event:       Name(t.Event),
+				To:          Name(e.to),
+				Label:       e.meta.Label,
+				Description: e.meta.Description,
+				Tags:        e.meta.Tags,
+			}
+			if named {
+				td.Guards = e.guardNames
+			}
+			def.Transitions = append(def.Transitions, td)
+			if !named || len(e.guards) == 0 {
+				break
+			}
+		}
+	}
+	return def
+}
+
+// MarshalJSON encodes the machine as a definition document, described by
+// DefinitionSchema, so it can be stored or shared with services not written
+// in Go. See ToDefinition for what the document holds. Use LoadJSON to
+// decode it again.
+func (sm *StateMachine[S, E]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sm.ToDefinition())
+}
+
+// ExportJSON writes the machine to w as an indented definition document, as
+// encoded by MarshalJSON
+func (sm *StateMachine[S, E]) ExportJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sm.ToDefinition())
+}
+
+// LoadJSON builds a state machine from a definition document read from r,
+// such as one written by ExportJSON, resolving names against the given state
+// and event values as FromDefinition does
+func LoadJSON[S State, E Event](r io.Reader, states []S, events []E) (*StateMachine[S, E], error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	def, err := ParseDefinition("", data)
+	if err != nil {
+		return nil, err
+	}
+	return FromDefinition(def, states, events)
+}
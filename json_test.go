@@ -0,0 +1,153 @@
+package statemachine
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	sm := NewUserStateMachine()
+	sm.SetInitialState(UserStateInitial)
+	sm.AddFinalState(UserStateSignUpComplete)
+	sm.SetStateMeta(UserStateRejected, Metadata{Label: "Rejected", Tags: []string{"terminal"}})
+	sm.DeclareEvents(UserEvent("Suspend"))
+	sm.SetVersion(3)
+
+	data, err := json.Marshal(sm)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if err := ValidateDefinitionBytes(data); err != nil {
+		t.Fatalf("marshalled machine is not a valid definition: %v\n%s", err, data)
+	}
+
+	loaded, err := LoadJSON(strings.NewReader(string(data)), allUserStates, append(allUserEvents, "Suspend"))
+	if err != nil {
+		t.Fatalf("LoadJSON() error = %v", err)
+	}
+	if d := Diff(sm, loaded); !d.IsEmpty() {
+		t.Errorf("loaded machine differs:\n%s", d)
+	}
+	if err := Equivalent(sm, loaded); err != nil {
+		t.Errorf("loaded machine behaves differently: %v", err)
+	}
+	if m, _ := loaded.GetStateMeta(UserStateRejected); m.Label != "Rejected" || !m.HasTag("terminal") {
+		t.Errorf("GetStateMeta(Rejected) = %+v, want label and tag kept", m)
+	}
+	if got := loaded.Version(); got != 3 {
+		t.Errorf("Version() = %d, want 3", got)
+	}
+	if got := loaded.GetAllEvents(); len(got) != 5 {
+		t.Errorf("GetAllEvents() = %v, want the declared event kept", got)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking,
+		WithMeta[OrderState, OrderEvent](Metadata{Label: "Confirm order"}))
+	sm.SetInitialState(OrderStatePending)
+
+	var b strings.Builder
+	if err := sm.ExportJSON(&b); err != nil {
+		t.Fatalf("ExportJSON() error = %v", err)
+	}
+	want := `{
+  "$schema": "` + DefinitionSchemaID + `",
+  "initial": "Pending",
+  "states": [
+    {
+      "name": "Packing"
+    },
+    {
+      "name": "Pending"
+    }
+  ],
+  "events": [
+    {
+      "name": "Confirm"
+    }
+  ],
+  "transitions": [
+    {
+      "from": "Pending",
+      "event": "Confirm",
+      "to": "Packing",
+      "label": "Confirm order"
+    }
+  ]
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("ExportJSON() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLoadJSON_Invalid(t *testing.T) {
+	_, err := LoadJSON(strings.NewReader(`{"states": [{"name": "Initial"}], "events": [], "transitions": [
+		{"from": "Initial", "event": "SubmitSignup", "to": "Initial"}]}`), allUserStates, allUserEvents)
+	if err == nil || !strings.Contains(err.Error(), `undeclared event "SubmitSignup"`) {
+		t.Errorf("LoadJSON() error = %v, want an undeclared event error", err)
+	}
+}
+
+func TestToDefinition_Guards(t *testing.T) {
+	def := &Definition{
+		States: []StateDefinition{{Name: "Pending"}, {Name: "Packing"}, {Name: "Cancelled"}},
+		Events: []EventDefinition{{Name: "Confirm"}},
+		Transitions: []TransitionDefinition{
+			{From: "Pending", Event: "Confirm", To: "Packing", Guards: []string{"paid"}},
+			{From: "Pending", Event: "Confirm", To: "Cancelled"},
+		},
+	}
+	guards := map[string]Guard[OrderState, OrderEvent]{
+		"paid": func(ctx context.Context, from OrderState, event OrderEvent) bool { return false },
+	}
+	sm, err := FromDefinitionWithGuards(def, allOrderStates, allOrderEvents, guards)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := sm.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDefinition("order.json", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(parsed.Transitions), len(def.Transitions); got != want {
+		t.Fatalf("got %d transitions, want %d", got, want)
+	}
+	for i, tr := range parsed.Transitions {
+		want := def.Transitions[i]
+		if tr.From != want.From || tr.Event != want.Event || tr.To != want.To || !slices.Equal(tr.Guards, want.Guards) {
+			t.Errorf("Transitions[%d] = %+v, want %+v", i, tr, want)
+		}
+	}
+
+	loaded, err := FromDefinitionWithGuards(parsed, allOrderStates, allOrderEvents, guards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.Transition(OrderStatePending, OrderEventConfirm)
+	if err != nil || got != OrderStateCancelled {
+		t.Errorf("Transition() = %v, %v, want Cancelled past the failing guard", got, err)
+	}
+}
+
+func TestToDefinition_UnnamedGuards(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true }))
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateCancelled)
+
+	want := []TransitionDefinition{{From: "Pending", Event: "Confirm", To: "Packing"}}
+	if got := sm.ToDefinition().Transitions; !reflect.DeepEqual(got, want) {
+		t.Errorf("Transitions = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package statemachine
+
+// SetInitialState declares the state new instances start in. If it is a
+// composite state, instances start in its initial substate.
+func (sm *StateMachine[S, E]) SetInitialState(state S) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.initialState = state
+	sm.hasInitialState = true
+}
+
+// InitialState returns the state declared with SetInitialState, if any
+func (sm *StateMachine[S, E]) InitialState() (S, bool) {
+	sm.rlock()
+	defer sm.runlock()
+
+	return sm.initialState, sm.hasInitialState
+}
+
+// AddFinalState declares state as final: reaching it means the process the
+// machine models has completed. Unlike IsTerminalState, which only reports
+// that a state has no way out, finality is stated explicitly, so a state
+// missing its transitions by mistake is not mistaken for a finished one.
+func (sm *StateMachine[S, E]) AddFinalState(state S) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.final[state] = true
+}
+
+// IsFinalState reports whether state was declared with AddFinalState
+func (sm *StateMachine[S, E]) IsFinalState(state S) bool {
+	sm.rlock()
+	defer sm.runlock()
+
+	return sm.final[state]
+}
+
+// GetFinalStates returns every state declared with AddFinalState, sorted by
+// name
+func (sm *StateMachine[S, E]) GetFinalStates() []S {
+	sm.rlock()
+	defer sm.runlock()
+
+	states := make([]S, 0, len(sm.final))
+	for s := range sm.final {
+		states = append(states, s)
+	}
+	sortStates(states)
+	return states
+}
@@ -0,0 +1,133 @@
+package statemachine
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewInstance_StartsAtInitialState(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.SetInitialState(OrderStatePending)
+	sm.AddFinalState(OrderStateDelivered)
+
+	order := sm.NewInstance()
+	if !order.Is(OrderStatePending) {
+		t.Fatalf("NewInstance().Current() = %v, want %v", order.Current(), OrderStatePending)
+	}
+
+	ctx := context.Background()
+	for _, event := range []OrderEvent{OrderEventConfirm, OrderEventPack, OrderEventShip} {
+		if err := order.Fire(ctx, event); err != nil {
+			t.Fatalf("Fire(%v) error = %v", event, err)
+		}
+		if order.IsFinished() {
+			t.Errorf("IsFinished() = true in %v, want false", order.Current())
+		}
+	}
+	if err := order.Fire(ctx, OrderEventDeliver); err != nil {
+		t.Fatalf("Fire(Deliver) error = %v", err)
+	}
+	if !order.IsFinished() {
+		t.Errorf("IsFinished() = false in %v, want true", order.Current())
+	}
+}
+
+func TestNewInstance_EntersInitialSubstate(t *testing.T) {
+	sm := NewOrderStateMachine()
+	if err := sm.SetInitialSubstate(OrderStateProcessing, OrderStatePacking); err != nil {
+		t.Fatal(err)
+	}
+	sm.SetInitialState(OrderStateProcessing)
+
+	if got := sm.NewInstance().Current(); got != OrderStatePacking {
+		t.Errorf("NewInstance().Current() = %v, want %v", got, OrderStatePacking)
+	}
+}
+
+func TestNewInstance_PanicsWithoutInitialState(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("NewInstance() expected panic with no initial state")
+		}
+	}()
+	NewOrderStateMachine().NewInstance()
+}
+
+func TestFinalState_NotInferredFromTerminal(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.AddFinalState(OrderStateDelivered)
+
+	tests := []struct {
+		state        OrderState
+		wantFinal    bool
+		wantTerminal bool
+	}{
+		// refunds can still follow delivery
+		{OrderStateDelivered, true, false},
+		// Refunded has no way out but was never declared final
+		{OrderStateRefunded, false, true},
+		{OrderStatePending, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := sm.IsFinalState(tt.state); got != tt.wantFinal {
+			t.Errorf("IsFinalState(%v) = %v, want %v", tt.state, got, tt.wantFinal)
+		}
+		if got := sm.IsTerminalState(tt.state); got != tt.wantTerminal {
+			t.Errorf("IsTerminalState(%v) = %v, want %v", tt.state, got, tt.wantTerminal)
+		}
+	}
+	if got := sm.GetFinalStates(); len(got) != 1 || got[0] != OrderStateDelivered {
+		t.Errorf("GetFinalStates() = %v, want [%v]", got, OrderStateDelivered)
+	}
+}
+
+func TestGetFinalStates_Sorted(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.AddFinalState(OrderStateRefunded)
+	sm.AddFinalState(OrderStateCancelled)
+	sm.AddFinalState(OrderStateDelivered)
+
+	// sorted by name so repeated calls always agree
+	want := []OrderState{OrderStateCancelled, OrderStateDelivered, OrderStateRefunded}
+	for range 10 {
+		if got := sm.GetFinalStates(); !slices.Equal(got, want) {
+			t.Fatalf("GetFinalStates() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLoadFS_InitialAndFinal(t *testing.T) {
+	states := strings.Replace(userStatesFile, `"name": "signup",`, `"name": "signup",
+  "initial": "Initial",`, 1)
+	states = strings.Replace(states, `{"name": "SignUpComplete"}`, `{"name": "SignUpComplete", "final": true}`, 1)
+	fsys := fstest.MapFS{
+		"states.json":      {Data: []byte(states)},
+		"transitions.json": {Data: []byte(userTransitionsFile)},
+	}
+
+	sm, err := LoadFS(fsys, allUserStates, allUserEvents, "*.json")
+	if err != nil {
+		t.Fatalf("LoadFS() error = %v", err)
+	}
+	if initial, ok := sm.InitialState(); !ok || initial != UserStateInitial {
+		t.Errorf("InitialState() = %v, %v, want %v", initial, ok, UserStateInitial)
+	}
+	if !sm.IsFinalState(UserStateSignUpComplete) {
+		t.Errorf("IsFinalState(%v) = false, want true", UserStateSignUpComplete)
+	}
+
+	fsys["other.json"] = &fstest.MapFile{Data: []byte(`{"initial": "EmailVerified"}`)}
+	_, err = LoadFS(fsys, allUserStates, allUserEvents, "*.json")
+	if err == nil || !strings.Contains(err.Error(), `states.json: initial state "Initial" conflicts with "EmailVerified"`) {
+		t.Errorf("LoadFS() error = %v, want conflicting initial state", err)
+	}
+
+	_, err = ParseDefinition("bad.json", []byte(`{"initial": "Nowhere", "states": [{"name": "Draft"}]}`))
+	if err == nil || !strings.Contains(err.Error(), `bad.json: initial state "Nowhere" is not declared`) {
+		t.Errorf("ParseDefinition() error = %v, want undeclared initial state", err)
+	}
+}
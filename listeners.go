@@ -0,0 +1,130 @@
+package statemachine
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Listener is notified after a transition has completed successfully, with
+// the time it completed as told by the machine's Clock
+type Listener[S State, E Event] func(ctx context.Context, from, to S, event E, at time.Time)
+
+// listeners holds the machine's subscribers. It has its own lock, so that
+// listeners can come and go while the machine is in use, even once frozen.
+type listeners[S State, E Event] struct {
+	mu     sync.Mutex
+	nextID int
+	subs   []subscription[S, E]
+
+	// count is len(subs), so transitions need not take mu when there are
+	// no listeners
+	count atomic.Int32
+}
+
+type subscription[S State, E Event] struct {
+	id int
+	fn Listener[S, E]
+}
+
+// Subscribe registers fn to be notified after every successful transition,
+// whichever instance or caller performed it. Listeners run synchronously in
+// the order they subscribed, after the transition's callbacks.
+//
+// The returned function unsubscribes fn; calling it more than once is safe.
+func (sm *StateMachine[S, E]) Subscribe(fn Listener[S, E]) (unsubscribe func()) {
+	l := &sm.listeners
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.nextID++
+	id := l.nextID
+	l.subs = append(l.subs, subscription[S, E]{id: id, fn: fn})
+	l.count.Store(int32(len(l.subs)))
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		for i, sub := range l.subs {
+			if sub.id == id {
+				l.subs = append(l.subs[:i:i], l.subs[i+1:]...)
+				l.count.Store(int32(len(l.subs)))
+				return
+			}
+		}
+	}
+}
+
+// notify calls every listener subscribed when the transition completed,
+// telling them the time on clock. If ctx holds held notices for l, the
+// notification is kept in them instead, to be sent by their release.
+func (l *listeners[S, E]) notify(ctx context.Context, clock Clock, from, to S, event E) {
+	if l.count.Load() == 0 {
+		return
+	}
+	if h, ok := ctx.Value(heldNoticesKey{}).(*heldNotices[S, E]); ok && h.l == l {
+		h.held = append(h.held, notice[S, E]{ctx: ctx, clock: clock, from: from, to: to, event: event})
+		return
+	}
+	l.mu.Lock()
+	subs := l.subs
+	l.mu.Unlock()
+
+	if len(subs) == 0 {
+		return
+	}
+	at := clock.Now()
+	for _, sub := range subs {
+		sub.fn(ctx, from, to, event, at)
+	}
+}
+
+type heldNoticesKey struct{}
+
+// heldNotices keeps back the notifications of transitions made with a
+// context carrying it, until release sends them, so that listeners only
+// hear of a transition once it is known to stick
+type heldNotices[S State, E Event] struct {
+	l    *listeners[S, E]
+	held []notice[S, E]
+
+	// outer is what the context held before, which the notices are
+	// released to
+	outer any
+}
+
+type notice[S State, E Event] struct {
+	ctx      context.Context
+	clock    Clock
+	from, to S
+	event    E
+}
+
+// holdNotices returns a copy of ctx whose transitions on the machine with
+// listeners l are not notified until release is called on the notices
+func holdNotices[S State, E Event](ctx context.Context, l *listeners[S, E]) (context.Context, *heldNotices[S, E]) {
+	h := &heldNotices[S, E]{l: l, outer: ctx.Value(heldNoticesKey{})}
+	return context.WithValue(ctx, heldNoticesKey{}, h), h
+}
+
+// release sends the notifications held back, or passes them on to the
+// notices held by the context they were held from
+func (h *heldNotices[S, E]) release() {
+	held := h.held
+	h.held = nil
+	for _, n := range held {
+		h.l.notify(context.WithValue(n.ctx, heldNoticesKey{}, h.outer), n.clock, n.from, n.to, n.event)
+	}
+}
+
+// HoldNotices returns a copy of ctx whose transitions on sm are not told to
+// its listeners until release is called, for callers that save transitions
+// themselves and want listeners to hear of them only once they stick, such
+// as once a database transaction commits. If release is never called, the
+// notices are dropped.
+func HoldNotices[S State, E Event](ctx context.Context, sm *StateMachine[S, E]) (held context.Context, release func()) {
+	ctx, h := holdNotices(ctx, &sm.listeners)
+	return ctx, h.release
+}
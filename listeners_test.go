@@ -0,0 +1,156 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubscribe_NotifiesAfterTransition(t *testing.T) {
+	var got []string
+	var at time.Time
+	sm := NewOrderStateMachine()
+	sm.OnEnter(OrderStateShipped, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+		got = append(got, "enter")
+	})
+	unsubscribe := sm.Subscribe(func(ctx context.Context, from, to OrderState, event OrderEvent, when time.Time) {
+		got = append(got, fmt.Sprintf("%v --%v--> %v", from, event, to))
+		at = when
+	})
+
+	before := time.Now()
+	order := sm.NewInstanceAt(OrderStateAwaiting)
+	if err := order.Fire(context.Background(), OrderEventShip); err != nil {
+		t.Fatalf("Fire() error = %v", err)
+	}
+	if want := "enter,AwaitingCourier --Ship--> Shipped"; strings.Join(got, ",") != want {
+		t.Errorf("notifications = %v, want %v", got, want)
+	}
+	if at.Before(before) || at.After(time.Now()) {
+		t.Errorf("listener time = %v, want time of transition", at)
+	}
+
+	got = nil
+	if _, err := sm.Transition(OrderStatePending, OrderEventShip); err == nil {
+		t.Fatalf("Transition() expected error")
+	}
+	if len(got) != 0 {
+		t.Errorf("listener notified of failed transition: %v", got)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	sm.Transition(OrderStateShipped, OrderEventDeliver)
+	if len(got) != 0 {
+		t.Errorf("listener notified after unsubscribing: %v", got)
+	}
+}
+
+func TestSubscribe_NotNotifiedOnVeto(t *testing.T) {
+	notified := false
+	sm := NewOrderStateMachine()
+	sm.Use(func(next TransitionFunc[OrderState, OrderEvent]) TransitionFunc[OrderState, OrderEvent] {
+		return func(ctx context.Context, from OrderState, event OrderEvent) (OrderState, error) {
+			return from, errors.New("vetoed")
+		}
+	})
+	sm.Subscribe(func(ctx context.Context, from, to OrderState, event OrderEvent, at time.Time) {
+		notified = true
+	})
+
+	sm.Transition(OrderStatePending, OrderEventConfirm)
+	if notified {
+		t.Errorf("listener notified of vetoed transition")
+	}
+}
+
+func TestSubscribe_Unsubscribe(t *testing.T) {
+	var got []string
+	sm := NewOrderStateMachine()
+	listen := func(name string) Listener[OrderState, OrderEvent] {
+		return func(ctx context.Context, from, to OrderState, event OrderEvent, at time.Time) {
+			got = append(got, name)
+		}
+	}
+	sm.Subscribe(listen("a"))
+	unsubscribeB := sm.Subscribe(listen("b"))
+	sm.Subscribe(listen("c"))
+
+	unsubscribeB()
+	sm.Transition(OrderStatePending, OrderEventConfirm)
+	if strings.Join(got, ",") != "a,c" {
+		t.Errorf("notified = %v, want [a c]", got)
+	}
+}
+
+func TestSubscribe_FrozenMachineConcurrent(t *testing.T) {
+	sm := NewOrderStateMachine().Freeze()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				unsubscribe := sm.Subscribe(func(ctx context.Context, from, to OrderState, event OrderEvent, at time.Time) {})
+				sm.Transition(OrderStatePending, OrderEventConfirm)
+				unsubscribe()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSubscribe_UsesMachineClock(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := NewStateMachine[OrderState, OrderEvent](WithClock(clock))
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped)
+	var at []time.Time
+	sm.Subscribe(func(ctx context.Context, from, to OrderState, event OrderEvent, when time.Time) {
+		at = append(at, when)
+	})
+
+	if _, err := sm.Transition(OrderStateAwaiting, OrderEventShip); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(time.Hour)
+	if _, err := sm.Freeze().Transition(OrderStateAwaiting, OrderEventShip); err != nil {
+		t.Fatal(err)
+	}
+	if want := []time.Time{epoch, epoch.Add(time.Hour)}; !slices.Equal(at, want) {
+		t.Errorf("listener times = %v, want %v from the machine's clock", at, want)
+	}
+}
+
+func TestHoldNotices(t *testing.T) {
+	orders, _ := newOrderManager()
+	var heard []OrderEvent
+	orders.sm.Subscribe(func(ctx context.Context, from, to OrderState, event OrderEvent, at time.Time) {
+		heard = append(heard, event)
+	})
+	ctx := context.Background()
+	if err := orders.Create(ctx, "order-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	held, release := HoldNotices(ctx, orders.sm)
+	if _, err := orders.sm.TransitionContext(held, OrderStatePending, OrderEventCancel); err != nil {
+		t.Fatal(err)
+	}
+	// a Manager holding notices of its own passes them on once saved
+	if _, err := orders.Fire(held, "order-1", OrderEventConfirm); err != nil {
+		t.Fatal(err)
+	}
+	if len(heard) > 0 {
+		t.Fatalf("listeners told of %v before release", heard)
+	}
+	release()
+	if want := []OrderEvent{OrderEventCancel, OrderEventConfirm}; !slices.Equal(heard, want) {
+		t.Errorf("listeners told of %v, want %v", heard, want)
+	}
+}
@@ -0,0 +1,71 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// WithLogger makes the machine log its transitions to logger, with the from
+// state, event and, once known, to state as fields:
+//
+//   - each attempt, and the outcome of the guards of each transition tried,
+//     at Debug
+//   - completed transitions, and events rejected as invalid or by guards, at
+//     Info
+//   - transitions that failed for other reasons, such as an action
+//     returning an error, at Error
+//
+// The transition's context is passed to the logger's handler.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// logAttempt logs that an event is being processed from a state
+func (sm *StateMachine[S, E]) logAttempt(ctx context.Context, from S, event E) {
+	if sm.logger == nil {
+		return
+	}
+	sm.logger.LogAttrs(ctx, slog.LevelDebug, "transition attempted",
+		slog.String("from", Name(from)),
+		slog.String("event", Name(event)))
+}
+
+// logGuards logs whether the guards of a transition to a state passed
+func (sm *StateMachine[S, E]) logGuards(ctx context.Context, from S, event E, to S, passed bool) {
+	if sm.logger == nil {
+		return
+	}
+	sm.logger.LogAttrs(ctx, slog.LevelDebug, "guards checked",
+		slog.String("from", Name(from)),
+		slog.String("event", Name(event)),
+		slog.String("to", Name(to)),
+		slog.Bool("passed", passed))
+}
+
+// logResult logs the outcome of a transition
+func (sm *StateMachine[S, E]) logResult(ctx context.Context, from S, event E, to S, err error) {
+	if sm.logger == nil {
+		return
+	}
+	switch {
+	case err == nil:
+		sm.logger.LogAttrs(ctx, slog.LevelInfo, "transition completed",
+			slog.String("from", Name(from)),
+			slog.String("event", Name(event)),
+			slog.String("to", Name(to)))
+	case errors.Is(err, ErrInvalidTransition):
+		sm.logger.LogAttrs(ctx, slog.LevelInfo, "transition rejected",
+			slog.String("from", Name(from)),
+			slog.String("event", Name(event)),
+			slog.Bool("guardRejected", errors.Is(err, ErrGuardRejected)),
+			slog.String("error", err.Error()))
+	default:
+		sm.logger.LogAttrs(ctx, slog.LevelError, "transition failed",
+			slog.String("from", Name(from)),
+			slog.String("event", Name(event)),
+			slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,89 @@
+package statemachine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithLogger(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  OrderState
+		event OrderEvent
+		want  []string
+	}{
+		{
+			name:  "completed",
+			from:  OrderStatePending,
+			event: OrderEventConfirm,
+			want: []string{
+				"level=DEBUG msg=\"transition attempted\" from=Pending event=Confirm",
+				"level=DEBUG msg=\"guards checked\" from=Pending event=Confirm to=Packing passed=true",
+				"level=INFO msg=\"transition completed\" from=Pending event=Confirm to=Packing",
+			},
+		},
+		{
+			name:  "invalid",
+			from:  OrderStatePending,
+			event: OrderEventShip,
+			want: []string{
+				"level=DEBUG msg=\"transition attempted\" from=Pending event=Ship",
+				"level=INFO msg=\"transition rejected\" from=Pending event=Ship guardRejected=false error=\"invalid transition: cannot process event 'Ship' from state 'Pending'; valid events: Confirm\"",
+			},
+		},
+		{
+			name:  "guard rejected",
+			from:  OrderStateShipped,
+			event: OrderEventDeliver,
+			want: []string{
+				"level=DEBUG msg=\"transition attempted\" from=Shipped event=Deliver",
+				"level=DEBUG msg=\"guards checked\" from=Shipped event=Deliver to=Delivered passed=false",
+				"level=INFO msg=\"transition rejected\" from=Shipped event=Deliver guardRejected=true error=\"invalid transition: guard rejected event 'Deliver' from state 'Shipped'; valid events: Deliver\"",
+			},
+		},
+		{
+			name:  "action failed",
+			from:  OrderStateAwaiting,
+			event: OrderEventShip,
+			want: []string{
+				"level=DEBUG msg=\"transition attempted\" from=AwaitingCourier event=Ship",
+				"level=ERROR msg=\"transition failed\" from=AwaitingCourier event=Ship error=\"action for event 'Ship' from state 'AwaitingCourier' failed: courier unavailable\"",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+				Level: slog.LevelDebug,
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return a
+				},
+			}))
+			sm := NewStateMachine[OrderState, OrderEvent](WithLogger(logger))
+			sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking,
+				WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true }))
+			sm.AddTransition(OrderStateShipped, OrderEventDeliver, OrderStateDelivered,
+				WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }))
+			sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped,
+				WithAction(func(ctx context.Context, from, to OrderState, event OrderEvent) error {
+					return errors.New("courier unavailable")
+				}))
+
+			sm.Clone().Transition(tt.from, tt.event)
+
+			got, want := strings.TrimSpace(buf.String()), strings.Join(tt.want, "\n")
+			if got != want {
+				t.Errorf("logged:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
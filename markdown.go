@@ -0,0 +1,64 @@
+package statemachine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportMarkdownTable writes the machine's transitions to w as a Markdown
+// table, for runbooks and other documentation read by people:
+//
+//	| From | Event | To | Guarded | Description |
+//	| --- | --- | --- | --- | --- |
+//	| Pending | Confirm | Packing |  | Payment was taken |
+//
+// There is one row per transition, sorted by state and then event, with
+// competing transitions in the order they are tried. States and events are
+// shown by name, a choice lists each state it may lead to, and a transition
+// from a composite state notes that it applies to the state's substates too.
+// Guards have no names, so the Guarded column only says whether there are
+// any. The description comes from the transition's metadata. The output is
+// stable: the same machine always produces the same text.
+func (sm *StateMachine[S, E]) ExportMarkdownTable(w io.Writer) error {
+	sm.rlock()
+	d := sm.diagram()
+	sm.runlock()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("| From | Event | To | Guarded | Description |\n")
+	bw.WriteString("| --- | --- | --- | --- | --- |\n")
+	for i := 0; i < len(d.edges); {
+		e := d.edges[i]
+
+		// a choice has one diagram edge per target
+		targets := []string{Name(e.to)}
+		for i++; i < len(d.edges) && d.edges[i].src == e.src; i++ {
+			targets = append(targets, Name(d.edges[i].to))
+		}
+
+		from := Name(e.from)
+		if len(d.children[e.from]) > 0 {
+			from += " (and substates)"
+		}
+		to := strings.Join(targets, " or ")
+		if e.history != NoHistory {
+			to += fmt.Sprintf(" (%s history)", e.history)
+		}
+		guarded := ""
+		if e.guarded {
+			guarded = "yes"
+		}
+		fmt.Fprintf(bw, "| %s | %s | %s | %s | %s |\n",
+			markdownCell(from), markdownCell(Name(e.event)), markdownCell(to), guarded, markdownCell(e.meta.Description))
+	}
+	return bw.Flush()
+}
+
+// markdownCell makes text safe to put in a table cell, which must stay on
+// one line and cannot contain an unescaped pipe
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(strings.TrimSpace(s), "\n", "<br>")
+}
@@ -0,0 +1,41 @@
+package statemachine
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExportMarkdownTable(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking,
+		WithMeta[OrderState, OrderEvent](Metadata{Description: "Payment was taken"}))
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateCancelled,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }),
+		WithMeta[OrderState, OrderEvent](Metadata{Description: "Card declined |\nno stock"}))
+	sm.AddTransition(OrderStatePacking, OrderEventPack, OrderStateAwaiting)
+	sm.AddChoice(OrderStateAwaiting, OrderEventShip,
+		func(ctx context.Context, from OrderState, event OrderEvent) (OrderState, error) {
+			return OrderStateShipped, nil
+		},
+		[]OrderState{OrderStateShipped, OrderStateCancelled})
+	sm.AddTransition(OrderStateProcessing, OrderEventCancel, OrderStateCancelled)
+	sm.SetParent(OrderStatePacking, OrderStateProcessing)
+
+	var b strings.Builder
+	if err := sm.ExportMarkdownTable(&b); err != nil {
+		t.Fatalf("ExportMarkdownTable() error = %v", err)
+	}
+
+	want := `| From | Event | To | Guarded | Description |
+| --- | --- | --- | --- | --- |
+| AwaitingCourier | Ship | Shipped or Cancelled |  |  |
+| Packing | Pack | AwaitingCourier |  |  |
+| Pending | Confirm | Cancelled | yes | Card declined \|<br>no stock |
+| Pending | Confirm | Packing |  | Payment was taken |
+| Processing (and substates) | Cancel | Cancelled |  |  |
+`
+	if got := b.String(); got != want {
+		t.Errorf("ExportMarkdownTable() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Merge adds everything defined by other to the machine: its transitions,
+// hierarchy, declared events, declared, initial and final states, timeouts,
+// crons, metadata, deferred events, callbacks and middleware.
+// It is meant for composing a base workflow with extensions:
+//
+//	sm := baseMachine.Clone()
+//	if err := sm.Merge(tenantExtensions); err != nil {
+//		return err
+//	}
+//
+// Merge fails, leaving the machine unchanged, if the two machines disagree:
+// an unguarded transition for the same state and event leading somewhere
+// else, a state nested under a different parent, or a different initial
+// state or initial substate. A machine created WithAcyclic also fails to
+// merge transitions that would create a cycle. Guarded transitions never
+// conflict; they are added alongside the machine's own. Where both machines describe a state
+// with metadata or a timeout the machine's own is kept.
+//
+// Merge must not be called concurrently with a Merge in the opposite
+// direction.
+func (sm *StateMachine[S, E]) Merge(other *StateMachine[S, E]) error {
+	if other == sm {
+		return nil
+	}
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+	other.rlock()
+	defer other.runlock()
+
+	if err := sm.mergeConflicts(other); err != nil {
+		return fmt.Errorf("cannot merge state machines: %w", err)
+	}
+
+	for from, byEvent := range other.transitions {
+		if sm.transitions[from] == nil {
+			sm.transitions[from] = make(map[E][]*edge[S, E])
+		}
+		for event, edges := range byEvent {
+			for _, e := range edges {
+				if len(e.guards) == 0 && sm.unguardedEdge(from, event) != nil {
+					// the same transition, already checked not to conflict
+					continue
+				}
+				sm.transitions[from][event] = addEdge(sm.transitions[from][event], e.clone())
+				sm.noteAutomatic(from, event, e)
+			}
+		}
+	}
+	for child, parent := range other.parents {
+		sm.parents[child] = parent
+	}
+	for parent, child := range other.initial {
+		sm.initial[parent] = child
+	}
+	if other.hasInitialState {
+		sm.initialState = other.initialState
+		sm.hasInitialState = true
+	}
+	for s := range other.final {
+		sm.final[s] = true
+	}
+	for s := range other.declared {
+		sm.declared[s] = true
+	}
+	for e := range other.events {
+		sm.events[e] = true
+	}
+	for state, t := range other.timeouts {
+		if _, exists := sm.timeouts[state]; !exists {
+			sm.timeouts[state] = t
+		}
+	}
+	for state, m := range other.stateMeta {
+		if _, exists := sm.stateMeta[state]; !exists {
+			sm.stateMeta[state] = m.clone()
+		}
+	}
+	for state, fns := range other.onEnter {
+		sm.onEnter[state] = append(sm.onEnter[state], fns...)
+	}
+	for state, fns := range other.onExit {
+		sm.onExit[state] = append(sm.onExit[state], fns...)
+	}
+	sm.onFinal = append(sm.onFinal, other.onFinal...)
+	sm.vetoes = append(sm.vetoes, other.vetoes...)
+	for state, events := range other.deferred {
+		if sm.deferred == nil {
+			sm.deferred = make(map[S][]E)
+		}
+		for _, event := range events {
+			if !slices.Contains(sm.deferred[state], event) {
+				sm.deferred[state] = append(sm.deferred[state], event)
+			}
+		}
+	}
+	sm.crons = append(sm.crons, other.crons...)
+	sm.middleware = append(sm.middleware, other.middleware...)
+	sm.guardMW = append(sm.guardMW, other.guardMW...)
+	sm.actionMW = append(sm.actionMW, other.actionMW...)
+	return nil
+}
+
+// mergeConflicts returns every way in which other disagrees with the machine
+func (sm *StateMachine[S, E]) mergeConflicts(other *StateMachine[S, E]) error {
+	var errs []error
+	for from, byEvent := range other.transitions {
+		for event, edges := range byEvent {
+			mine := sm.unguardedEdge(from, event)
+			if mine == nil {
+				continue
+			}
+			for _, theirs := range edges {
+				if len(theirs.guards) == 0 && !slices.Equal(mine.targets(), theirs.targets()) {
+					errs = append(errs, fmt.Errorf("transition from '%s' on '%s' leads to %s here and %s in the other machine",
+						Name(from), Name(event), describeTargets(mine.targets()), describeTargets(theirs.targets())))
+				}
+			}
+		}
+	}
+
+	parents := make(map[S]S, len(sm.parents)+len(other.parents))
+	for child, parent := range sm.parents {
+		parents[child] = parent
+	}
+	for child, parent := range other.parents {
+		if mine, exists := sm.parents[child]; exists && mine != parent {
+			errs = append(errs, fmt.Errorf("state '%s' is a substate of '%s' here and of '%s' in the other machine",
+				Name(child), Name(mine), Name(parent)))
+			continue
+		}
+		parents[child] = parent
+	}
+	for child := range other.parents {
+		seen := map[S]bool{child: true}
+		for s, ok := parents[child]; ok; s, ok = parents[s] {
+			if seen[s] {
+				errs = append(errs, fmt.Errorf("state '%s' would be nested in a cycle", Name(child)))
+				break
+			}
+			seen[s] = true
+		}
+	}
+
+	for parent, child := range other.initial {
+		if mine, exists := sm.initial[parent]; exists && mine != child {
+			errs = append(errs, fmt.Errorf("initial substate of '%s' is '%s' here and '%s' in the other machine",
+				Name(parent), Name(mine), Name(child)))
+		}
+	}
+	if sm.hasInitialState && other.hasInitialState && sm.initialState != other.initialState {
+		errs = append(errs, fmt.Errorf("initial state is '%s' here and '%s' in the other machine",
+			Name(sm.initialState), Name(other.initialState)))
+	}
+
+	if sm.acyclic {
+		graph := sm.stateGraph()
+		for from, targets := range other.stateGraph() {
+			graph[from] = uniqueStates(append(graph[from], targets...))
+		}
+		for _, cycle := range findCycles(graph) {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrCycle, describeCycle(cycle)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// unguardedEdge returns the machine's own unguarded transition for event
+// from a state, if it has one
+func (sm *StateMachine[S, E]) unguardedEdge(from S, event E) *edge[S, E] {
+	for _, e := range sm.transitions[from][event] {
+		if len(e.guards) == 0 {
+			return e
+		}
+	}
+	return nil
+}
+
+func describeTargets[S State](targets []S) string {
+	if len(targets) == 1 {
+		return "'" + Name(targets[0]) + "'"
+	}
+	names := make([]string, len(targets))
+	for i, s := range targets {
+		names[i] = "'" + Name(s) + "'"
+	}
+	return "a choice of " + strings.Join(names, ", ")
+}
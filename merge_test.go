@@ -0,0 +1,103 @@
+package statemachine
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base := NewOrderStateMachine()
+	base.SetInitialState(OrderStatePending)
+
+	ext := NewStateMachine[OrderState, OrderEvent]()
+	// repeats a base transition exactly, which is not a conflict
+	ext.AddTransition(OrderStateShipped, OrderEventDeliver, OrderStateDelivered)
+	ext.AddTransition(OrderStateShipped, OrderEventCancel, OrderStateCancelled)
+	ext.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateCancelled,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true }),
+		WithPriority[OrderState, OrderEvent](1))
+	ext.AddFinalState(OrderStateRefunded)
+
+	if err := base.Merge(ext); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+
+	tests := []struct {
+		from  OrderState
+		event OrderEvent
+		want  OrderState
+	}{
+		{OrderStateShipped, OrderEventCancel, OrderStateCancelled},
+		{OrderStateShipped, OrderEventDeliver, OrderStateDelivered},
+		// the guarded transition competes with the base one and wins on priority
+		{OrderStatePending, OrderEventConfirm, OrderStateCancelled},
+		{OrderStateAwaiting, OrderEventShip, OrderStateShipped},
+	}
+	for _, tt := range tests {
+		if got, err := base.Transition(tt.from, tt.event); err != nil || got != tt.want {
+			t.Errorf("Transition(%v, %v) = %v, %v, want %v", tt.from, tt.event, got, err, tt.want)
+		}
+	}
+	if !base.IsFinalState(OrderStateRefunded) {
+		t.Errorf("IsFinalState(Refunded) = false after merge")
+	}
+	if ext.CanTransition(OrderStateAwaiting, OrderEventShip) {
+		t.Errorf("Merge() modified the other machine")
+	}
+}
+
+func TestMerge_Conflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(other *StateMachine[OrderState, OrderEvent])
+		wantErr string
+	}{
+		{
+			name: "same transition different target",
+			setup: func(other *StateMachine[OrderState, OrderEvent]) {
+				other.AddTransition(OrderStateShipped, OrderEventDeliver, OrderStateRefunded)
+			},
+			wantErr: "transition from 'Shipped' on 'Deliver' leads to 'Delivered' here and 'Refunded' in the other machine",
+		},
+		{
+			name: "different parent",
+			setup: func(other *StateMachine[OrderState, OrderEvent]) {
+				other.SetParent(OrderStatePacking, OrderStatePending)
+			},
+			wantErr: "state 'Packing' is a substate of 'Processing' here and of 'Pending' in the other machine",
+		},
+		{
+			name: "nesting cycle",
+			setup: func(other *StateMachine[OrderState, OrderEvent]) {
+				other.SetParent(OrderStateProcessing, OrderStatePacking)
+			},
+			wantErr: "would be nested in a cycle",
+		},
+		{
+			name: "different initial state",
+			setup: func(other *StateMachine[OrderState, OrderEvent]) {
+				other.SetInitialState(OrderStateShipped)
+			},
+			wantErr: "initial state is 'Pending' here and 'Shipped' in the other machine",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewOrderStateMachine()
+			sm.SetInitialState(OrderStatePending)
+			other := NewStateMachine[OrderState, OrderEvent]()
+			other.AddTransition(OrderStateShipped, OrderEventCancel, OrderStateCancelled)
+			tt.setup(other)
+
+			err := sm.Merge(other)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Merge() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if sm.CanTransition(OrderStateShipped, OrderEventCancel) {
+				t.Errorf("failed Merge() changed the machine")
+			}
+		})
+	}
+}
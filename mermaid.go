@@ -0,0 +1,94 @@
+package statemachine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportMermaid writes the machine to w as a Mermaid stateDiagram-v2, which
+// GitHub and many documentation tools render when embedded in Markdown:
+//
+//	```mermaid
+//	stateDiagram-v2
+//	    [*] --> Pending
+//	    Pending --> Packing: Confirm
+//	    ...
+//	```
+//
+// States and transitions are labelled as for ExportDOT, substates are drawn
+// inside their parent state, and guarded transitions are marked as such.
+// States whose names are not valid Mermaid identifiers are given one, with
+// their name as the label. The output is stable: the same machine always
+// produces the same text.
+func (sm *StateMachine[S, E]) ExportMermaid(w io.Writer) error {
+	sm.rlock()
+	d := sm.diagram()
+	sm.runlock()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("stateDiagram-v2\n")
+
+	var declare func(s S)
+	declare = func(s S) {
+		if id, label := diagramID(s), d.label(s); id != label {
+			fmt.Fprintf(bw, "    state \"%s\" as %s\n", strings.ReplaceAll(mermaidText(label), `"`, "'"), id)
+		}
+		for _, child := range d.children[s] {
+			declare(child)
+		}
+	}
+	for _, s := range d.roots {
+		declare(s)
+	}
+
+	for _, s := range d.roots {
+		writeMermaidState(bw, d, s, "    ")
+	}
+
+	if d.hasInitial {
+		fmt.Fprintf(bw, "    [*] --> %s\n", diagramID(d.initial))
+	}
+	for _, e := range d.edges {
+		label := e.eventLabel()
+		if e.guarded {
+			label += " (guarded)"
+		}
+		fmt.Fprintf(bw, "    %s --> %s: %s\n", diagramID(e.from), diagramID(e.to), mermaidText(label))
+	}
+	for _, s := range sortedFinal(d.final) {
+		fmt.Fprintf(bw, "    %s --> [*]\n", diagramID(s))
+	}
+	return bw.Flush()
+}
+
+// writeMermaidState writes a state, or the block for a composite state and
+// its substates
+func writeMermaidState[S State, E Event](w *bufio.Writer, d diagram[S, E], s S, indent string) {
+	children, composite := d.children[s]
+	if !composite {
+		fmt.Fprintf(w, "%s%s\n", indent, diagramID(s))
+		return
+	}
+	fmt.Fprintf(w, "%sstate %s {\n", indent, diagramID(s))
+	for _, child := range children {
+		writeMermaidState(w, d, child, indent+"    ")
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+// mermaidText makes a transition label safe to follow a colon on one line
+func mermaidText(s string) string {
+	return strings.NewReplacer("\n", " ", ";", ",").Replace(s)
+}
+
+// sortedFinal returns the final states sorted by name
+func sortedFinal[S State](final map[S]bool) []S {
+	states := make([]S, 0, len(final))
+	for s := range final {
+		states = append(states, s)
+	}
+	sortStates(states)
+	return states
+}
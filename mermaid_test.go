@@ -0,0 +1,50 @@
+package statemachine
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExportMermaid(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateCancelled,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }))
+	sm.AddTransition(OrderStatePacking, OrderEventPack, OrderStateAwaiting,
+		WithMeta[OrderState, OrderEvent](Metadata{Label: "Packed"}))
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderState("Out for delivery"))
+	sm.AddTransition(OrderStateProcessing, OrderEventCancel, OrderStateCancelled)
+	sm.SetParent(OrderStatePacking, OrderStateProcessing)
+	sm.SetParent(OrderStateAwaiting, OrderStateProcessing)
+	sm.SetStateMeta(OrderStateAwaiting, Metadata{Label: "Awaiting courier"})
+	sm.SetInitialState(OrderStatePending)
+	sm.AddFinalState(OrderStateCancelled)
+
+	var b strings.Builder
+	if err := sm.ExportMermaid(&b); err != nil {
+		t.Fatalf("ExportMermaid() error = %v", err)
+	}
+
+	want := `stateDiagram-v2
+    state "Out for delivery" as Out_for_delivery
+    state "Awaiting courier" as AwaitingCourier
+    Cancelled
+    Out_for_delivery
+    Pending
+    state Processing {
+        AwaitingCourier
+        Packing
+    }
+    [*] --> Pending
+    AwaitingCourier --> Out_for_delivery: Ship
+    Packing --> AwaitingCourier: Packed
+    Pending --> Cancelled: Confirm (guarded)
+    Pending --> Packing: Confirm
+    Processing --> Cancelled: Cancel
+    Cancelled --> [*]
+`
+	if got := b.String(); got != want {
+		t.Errorf("ExportMermaid() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package statemachine
+
+// Metadata describes a state or transition for people rather than code, for
+// example to label the actions a front end offers for GetValidEvents
+type Metadata struct {
+	Label       string
+	Description string
+	Tags        []string
+	Attributes  map[string]string
+}
+
+// clone returns a copy of m that shares no slices or maps with it
+func (m Metadata) clone() Metadata {
+	c := m
+	if m.Tags != nil {
+		c.Tags = append([]string(nil), m.Tags...)
+	}
+	if m.Attributes != nil {
+		c.Attributes = make(map[string]string, len(m.Attributes))
+		for k, v := range m.Attributes {
+			c.Attributes[k] = v
+		}
+	}
+	return c
+}
+
+// HasTag reports whether m is tagged with tag
+func (m Metadata) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// WithMeta attaches metadata to a transition, retrievable with
+// GetTransitionMeta
+func WithMeta[S State, E Event](m Metadata) TransitionOption[S, E] {
+	return func(e *edge[S, E]) {
+		e.meta = m.clone()
+		e.hasMeta = true
+	}
+}
+
+// GetTransitionMeta returns the metadata of the transition taken for event
+// from a state, including one inherited from a parent state. Where several
+// transitions compete for the event, the metadata of the first tried is
+// returned.
+func (sm *StateMachine[S, E]) GetTransitionMeta(from S, event E) (Metadata, bool) {
+	sm.rlock()
+	defer sm.runlock()
+
+	e, ok := sm.effectiveTransitions(from)[event]
+	if !ok || !e.hasMeta {
+		return Metadata{}, false
+	}
+	return e.meta.clone(), true
+}
+
+// SetStateMeta attaches metadata to a state, replacing any set before
+func (sm *StateMachine[S, E]) SetStateMeta(state S, m Metadata) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.stateMeta[state] = m.clone()
+}
+
+// GetStateMeta returns the metadata attached to a state with SetStateMeta
+func (sm *StateMachine[S, E]) GetStateMeta(state S) (Metadata, bool) {
+	sm.rlock()
+	defer sm.runlock()
+
+	m, ok := sm.stateMeta[state]
+	if !ok {
+		return Metadata{}, false
+	}
+	return m.clone(), true
+}
@@ -0,0 +1,96 @@
+package statemachine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGetTransitionMeta(t *testing.T) {
+	sm := NewOrderStateMachine()
+	cancel := Metadata{Label: "Cancel order", Tags: []string{"destructive"}}
+	sm.AddTransition(OrderStateProcessing, OrderEventCancel, OrderStateCancelled, WithMeta[OrderState, OrderEvent](cancel))
+
+	tests := []struct {
+		name   string
+		from   OrderState
+		event  OrderEvent
+		want   Metadata
+		wantOK bool
+	}{
+		{"own transition", OrderStateProcessing, OrderEventCancel, cancel, true},
+		{"inherited from parent", OrderStatePacking, OrderEventCancel, cancel, true},
+		{"no metadata", OrderStatePending, OrderEventCancel, Metadata{}, false},
+		{"no transition", OrderStateShipped, OrderEventCancel, Metadata{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sm.GetTransitionMeta(tt.from, tt.event)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetTransitionMeta(%v, %v) = %+v, %v, want %+v, %v", tt.from, tt.event, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMetadata_IsCopied(t *testing.T) {
+	sm := NewOrderStateMachine()
+	tags := []string{"customer-visible"}
+	sm.SetStateMeta(OrderStateShipped, Metadata{Label: "On its way", Tags: tags})
+	tags[0] = "changed"
+
+	got, ok := sm.GetStateMeta(OrderStateShipped)
+	if !ok || got.Label != "On its way" || !got.HasTag("customer-visible") {
+		t.Fatalf("GetStateMeta() = %+v, %v", got, ok)
+	}
+	got.Tags[0] = "changed"
+	if again, _ := sm.GetStateMeta(OrderStateShipped); !again.HasTag("customer-visible") {
+		t.Errorf("GetStateMeta() returned metadata shared with the machine")
+	}
+}
+
+func TestBuilder_Meta(t *testing.T) {
+	b := NewBuilder[OrderState, OrderEvent]()
+	b.From(OrderStatePending).On(OrderEventConfirm).To(OrderStateProcessing).WithMeta(Metadata{Label: "Confirm order"})
+	b.StateMeta(OrderStatePending, Metadata{Description: "Awaiting payment"})
+	sm, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if m, _ := sm.GetTransitionMeta(OrderStatePending, OrderEventConfirm); m.Label != "Confirm order" {
+		t.Errorf("GetTransitionMeta() label = %q, want %q", m.Label, "Confirm order")
+	}
+	if m, _ := sm.GetStateMeta(OrderStatePending); m.Description != "Awaiting payment" {
+		t.Errorf("GetStateMeta() description = %q, want %q", m.Description, "Awaiting payment")
+	}
+}
+
+func TestLoadFS_Meta(t *testing.T) {
+	states := strings.Replace(userStatesFile, `{"name": "EmailVerified"}`,
+		`{"name": "EmailVerified", "label": "Verified", "tags": ["milestone"]}`, 1)
+	transitions := strings.Replace(userTransitionsFile, `"to": "EmailVerified"}`,
+		`"to": "EmailVerified", "label": "Verify email", "description": "User clicked the emailed link"}`, 1)
+	fsys := fstest.MapFS{
+		"states.json":      {Data: []byte(states)},
+		"transitions.json": {Data: []byte(transitions)},
+	}
+
+	sm, err := LoadFS(fsys, allUserStates, allUserEvents, "*.json")
+	if err != nil {
+		t.Fatalf("LoadFS() error = %v", err)
+	}
+
+	want := Metadata{Label: "Verify email", Description: "User clicked the emailed link"}
+	if got, ok := sm.GetTransitionMeta(UserStateEmailPendingVerification, UserEventClickVerificationLink); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTransitionMeta() = %+v, %v, want %+v", got, ok, want)
+	}
+	if got, _ := sm.GetStateMeta(UserStateEmailVerified); got.Label != "Verified" || !got.HasTag("milestone") {
+		t.Errorf("GetStateMeta() = %+v, want label and tag from the definition", got)
+	}
+	if _, ok := sm.GetStateMeta(UserStateInitial); ok {
+		t.Errorf("GetStateMeta(Initial) reported metadata for a state declaring none")
+	}
+}
@@ -0,0 +1,78 @@
+package statemachine
+
+import "context"
+
+// TransitionFunc performs a transition and returns the new state
+type TransitionFunc[S State, E Event] func(ctx context.Context, from S, event E) (S, error)
+
+// Middleware wraps every transition the machine performs, in the same way
+// as HTTP middleware wraps handlers. It can act before and after calling
+// next, or veto the transition by returning an error without calling it.
+//
+//	sm.Use(func(next statemachine.TransitionFunc[OrderState, OrderEvent]) statemachine.TransitionFunc[OrderState, OrderEvent] {
+//		return func(ctx context.Context, from OrderState, event OrderEvent) (OrderState, error) {
+//			start := time.Now()
+//			to, err := next(ctx, from, event)
+//			log.Printf("%s --%s--> %s in %s (err: %v)", from, event, to, time.Since(start), err)
+//			return to, err
+//		}
+//	})
+type Middleware[S State, E Event] func(next TransitionFunc[S, E]) TransitionFunc[S, E]
+
+// Use adds middleware run around every transition. Middleware added first
+// runs outermost. Guards, actions and callbacks all run inside next.
+func (sm *StateMachine[S, E]) Use(mw ...Middleware[S, E]) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.middleware = append(sm.middleware, mw...)
+}
+
+// hasMiddleware reports whether the machine has any middleware to wrap
+// transitions in
+func (sm *StateMachine[S, E]) hasMiddleware() bool {
+	sm.rlock()
+	defer sm.runlock()
+	return len(sm.middleware) > 0
+}
+
+// wrap applies the machine's middleware to fn
+func (sm *StateMachine[S, E]) wrap(fn TransitionFunc[S, E]) TransitionFunc[S, E] {
+	sm.rlock()
+	mw := sm.middleware
+	sm.runlock()
+
+	for i := len(mw) - 1; i >= 0; i-- {
+		fn = mw[i](fn)
+	}
+	return fn
+}
+
+// GuardMiddleware wraps every guard the machine checks, for example to time
+// or trace them
+type GuardMiddleware[S State, E Event] func(next Guard[S, E]) Guard[S, E]
+
+// UseGuard adds middleware run around each guard checked when
+// transitioning or simulating. Middleware added first runs outermost.
+func (sm *StateMachine[S, E]) UseGuard(mw ...GuardMiddleware[S, E]) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.guardMW = append(sm.guardMW, mw...)
+}
+
+// ActionMiddleware wraps every action the machine runs, for example to time
+// or trace them
+type ActionMiddleware[S State, E Event] func(next Action[S, E]) Action[S, E]
+
+// UseAction adds middleware run around each action of every transition.
+// Middleware added first runs outermost.
+func (sm *StateMachine[S, E]) UseAction(mw ...ActionMiddleware[S, E]) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.actionMW = append(sm.actionMW, mw...)
+}
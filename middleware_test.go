@@ -0,0 +1,155 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type orderFunc = TransitionFunc[OrderState, OrderEvent]
+
+func recordingMiddleware(name string, calls *[]string) Middleware[OrderState, OrderEvent] {
+	return func(next orderFunc) orderFunc {
+		return func(ctx context.Context, from OrderState, event OrderEvent) (OrderState, error) {
+			*calls = append(*calls, name+" before")
+			to, err := next(ctx, from, event)
+			*calls = append(*calls, fmt.Sprintf("%s after %v %v", name, to, err != nil))
+			return to, err
+		}
+	}
+}
+
+func TestUse_WrapsTransitions(t *testing.T) {
+	var calls []string
+	sm := NewOrderStateMachine()
+	sm.Use(recordingMiddleware("outer", &calls), recordingMiddleware("inner", &calls))
+	sm.OnEnter(OrderStateShipped, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+		calls = append(calls, "enter")
+	})
+
+	if _, err := sm.Transition(OrderStateAwaiting, OrderEventShip); err != nil {
+		t.Fatalf("Transition() error = %v", err)
+	}
+	want := "outer before,inner before,enter,inner after Shipped false,outer after Shipped false"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+
+	calls = nil
+	sm.Transition(OrderStatePending, OrderEventShip)
+	want = "outer before,inner before,inner after  true,outer after  true"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("calls on invalid transition = %s, want %s", got, want)
+	}
+}
+
+func TestUse_Veto(t *testing.T) {
+	errFrozenAccount := errors.New("account frozen")
+	var entered bool
+	sm := NewOrderStateMachine()
+	sm.Use(func(next orderFunc) orderFunc {
+		return func(ctx context.Context, from OrderState, event OrderEvent) (OrderState, error) {
+			if event == OrderEventShip {
+				return from, errFrozenAccount
+			}
+			return next(ctx, from, event)
+		}
+	})
+	sm.OnEnter(OrderStateShipped, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+		entered = true
+	})
+
+	order := sm.NewInstanceAt(OrderStateAwaiting)
+	if err := order.Fire(context.Background(), OrderEventShip); !errors.Is(err, errFrozenAccount) {
+		t.Errorf("Fire() error = %v, want %v", err, errFrozenAccount)
+	}
+	if entered || !order.Is(OrderStateAwaiting) {
+		t.Errorf("vetoed transition ran: entered = %v, Current() = %v", entered, order.Current())
+	}
+	if err := order.Fire(context.Background(), OrderEventCancel); err != nil {
+		t.Errorf("Fire(Cancel) error = %v", err)
+	}
+}
+
+func TestBuilder_Use(t *testing.T) {
+	var calls []string
+	b := NewBuilder[OrderState, OrderEvent]()
+	b.From(OrderStatePending).On(OrderEventConfirm).To(OrderStateProcessing)
+	b.Use(recordingMiddleware("mw", &calls))
+
+	sm, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	sm.Transition(OrderStatePending, OrderEventConfirm)
+	if len(calls) != 2 {
+		t.Errorf("calls = %v, want middleware to run", calls)
+	}
+
+	if _, err := NewBuilder[OrderState, OrderEvent]().Use(nil).Build(); err == nil || !strings.Contains(err.Error(), "middleware 1 is nil") {
+		t.Errorf("Build() error = %v, want nil middleware reported", err)
+	}
+}
+
+func TestUseAction(t *testing.T) {
+	var calls []string
+	record := func(name string) Action[OrderState, OrderEvent] {
+		return func(ctx context.Context, from, to OrderState, event OrderEvent) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	wrap := func(name string) ActionMiddleware[OrderState, OrderEvent] {
+		return func(next Action[OrderState, OrderEvent]) Action[OrderState, OrderEvent] {
+			return func(ctx context.Context, from, to OrderState, event OrderEvent) error {
+				calls = append(calls, name+" before")
+				err := next(ctx, from, to, event)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateProcessing,
+		WithAction(record("first")), WithAction(record("second")))
+	sm.UseAction(wrap("outer"), wrap("inner"))
+
+	if _, err := sm.Transition(OrderStatePending, OrderEventConfirm); err != nil {
+		t.Fatalf("Transition() error = %v", err)
+	}
+	want := "outer before,inner before,first,inner after,outer after," +
+		"outer before,inner before,second,inner after,outer after"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+
+	calls = nil
+	sm.Clone().Transition(OrderStatePending, OrderEventConfirm)
+	if len(calls) != 10 {
+		t.Errorf("calls on clone = %v, want the middleware copied", calls)
+	}
+}
+
+func TestUseGuard(t *testing.T) {
+	var checked []string
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateProcessing,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true }),
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }))
+	sm.UseGuard(func(next Guard[OrderState, OrderEvent]) Guard[OrderState, OrderEvent] {
+		return func(ctx context.Context, from OrderState, event OrderEvent) bool {
+			ok := next(ctx, from, event)
+			checked = append(checked, fmt.Sprint(ok))
+			return ok
+		}
+	})
+
+	if _, err := sm.Transition(OrderStatePending, OrderEventConfirm); !errors.Is(err, ErrGuardRejected) {
+		t.Fatalf("Transition() error = %v, want %v", err, ErrGuardRejected)
+	}
+	if got := strings.Join(checked, ","); got != "true,false" {
+		t.Errorf("guards checked = %s, want true,false", got)
+	}
+}
@@ -0,0 +1,122 @@
+package statemachine
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// Minimize returns a reduced copy of the machine in which states that behave
+// the same are merged, along with a mapping from every original state to
+// the state that stands for it in the copy. Two states behave the same when
+// they are both final or both not, and accept the same events leading to
+// states that in turn behave the same. Each group of merged states is
+// represented by the one with the lowest name.
+//
+//	reduced, mapping := imported.Minimize()
+//	current = mapping[current]
+//
+// Transitions are followed as GetNextState would follow them, so the copy
+// is Equivalent to the original. It holds plain transitions only: guards,
+// actions, choices, callbacks and the state hierarchy are not carried over,
+// since states merged together may have had different ones. The initial and
+// final states, declared events, metadata of the remaining states, version
+// and locking option are kept.
+func (sm *StateMachine[S, E]) Minimize() (*StateMachine[S, E], map[S]S) {
+	sm.rlock()
+	defer sm.runlock()
+
+	states := sm.allStates()
+	sortStates(states)
+
+	next := make(map[S]map[E]S, len(states))
+	for _, s := range states {
+		next[s] = make(map[E]S)
+		for event, e := range sm.effectiveTransitions(s) {
+			next[s][event] = sm.resolveTarget(e.to, NoHistory, nil)
+		}
+	}
+
+	// Start with states grouped by whether they are final and the events
+	// they accept, then split groups until every state in a group leads to
+	// the same groups
+	class := make(map[S]int, len(states))
+	classes := 0
+	group := func(signature func(S) string) int {
+		ids := make(map[string]int)
+		for _, s := range states {
+			key := signature(s)
+			id, ok := ids[key]
+			if !ok {
+				id = len(ids)
+				ids[key] = id
+			}
+			class[s] = id
+		}
+		return len(ids)
+	}
+	classes = group(func(s S) string {
+		return fmt.Sprint(sm.final[s], " ", eventNames(sm.validEvents(s)))
+	})
+	for {
+		prev := maps.Clone(class)
+		n := group(func(s S) string {
+			var b strings.Builder
+			fmt.Fprint(&b, prev[s])
+			for _, event := range sm.validEvents(s) {
+				fmt.Fprint(&b, " ", prev[next[s][event]])
+			}
+			return b.String()
+		})
+		if n == classes {
+			break
+		}
+		classes = n
+	}
+
+	mapping := make(map[S]S, len(states))
+	reps := make(map[int]S, classes)
+	for _, s := range states {
+		rep, ok := reps[class[s]]
+		if !ok {
+			rep = s
+			reps[class[s]] = s
+		}
+		mapping[s] = rep
+	}
+
+	reduced := NewStateMachine[S, E]()
+	reduced.locking = sm.locking
+	reduced.version = sm.version
+	for _, rep := range reps {
+		reduced.declared[rep] = true
+		for event, to := range next[rep] {
+			if reduced.transitions[rep] == nil {
+				reduced.transitions[rep] = make(map[E][]*edge[S, E])
+			}
+			reduced.transitions[rep][event] = []*edge[S, E]{{to: mapping[to]}}
+		}
+		if m, ok := sm.stateMeta[rep]; ok {
+			reduced.stateMeta[rep] = m.clone()
+		}
+	}
+	for s := range sm.final {
+		reduced.final[mapping[s]] = true
+	}
+	if sm.hasInitialState {
+		reduced.initialState = mapping[sm.resolveTarget(sm.initialState, NoHistory, nil)]
+		reduced.hasInitialState = true
+	}
+	for e := range sm.events {
+		reduced.events[e] = true
+	}
+	return reduced, mapping
+}
+
+func eventNames[E Event](events []E) string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = Name(e)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,66 @@
+package statemachine
+
+import (
+	"testing"
+)
+
+func TestMinimize(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(sm *StateMachine[OrderState, OrderEvent])
+		wantMerged map[OrderState]OrderState
+	}{
+		{
+			name:       "merges states with the same behaviour",
+			setup:      func(sm *StateMachine[OrderState, OrderEvent]) {},
+			wantMerged: map[OrderState]OrderState{OrderStateDelivered: OrderStateCancelled},
+		},
+		{
+			name: "final states stay apart",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.AddFinalState(OrderStateDelivered)
+			},
+		},
+		{
+			name: "merges through targets",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				// Packing now behaves like AwaitingCourier, as Pack and
+				// Ship both lead on to Shipped
+				sm.AddTransition(OrderStatePacking, OrderEventShip, OrderStateShipped)
+				sm.AddTransition(OrderStatePacking, OrderEventPack, OrderStateShipped)
+				sm.AddTransition(OrderStateAwaiting, OrderEventPack, OrderStateShipped)
+			},
+			wantMerged: map[OrderState]OrderState{
+				OrderStatePacking:   OrderStateAwaiting,
+				OrderStateDelivered: OrderStateCancelled,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewOrderStateMachine()
+			sm.SetInitialState(OrderStatePending)
+			tt.setup(sm)
+
+			reduced, mapping := sm.Minimize()
+			for _, s := range sm.GetAllStates() {
+				want, merged := tt.wantMerged[s]
+				if !merged {
+					want = s
+				}
+				if mapping[s] != want {
+					t.Errorf("mapping[%v] = %v, want %v", s, mapping[s], want)
+				}
+			}
+			if got, want := len(reduced.GetAllStates()), len(sm.GetAllStates())-len(tt.wantMerged); got != want {
+				t.Errorf("reduced machine has %d states, want %d", got, want)
+			}
+			if err := Equivalent(sm, reduced); err != nil {
+				t.Errorf("reduced machine is not equivalent: %v", err)
+			}
+			if got, _ := reduced.InitialState(); got != OrderStatePending {
+				t.Errorf("InitialState() = %v, want %v", got, OrderStatePending)
+			}
+		})
+	}
+}
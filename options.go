@@ -0,0 +1,84 @@
+package statemachine
+
+import "log/slog"
+
+// Option configures a StateMachine created with NewStateMachine
+type Option func(*config)
+
+// config collects the settings applied by Options
+type config struct {
+	locking  bool
+	strict   bool
+	acyclic  bool
+	coverage bool
+	dwell    bool
+	stats    bool
+	undo     int
+	timeline int
+	logger   *slog.Logger
+	clock    Clock
+
+	maxAutomatic int
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{clock: systemClock{}, maxAutomatic: DefaultMaxAutomatic}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithLocking guards the machine's transition table and callbacks with a
+// read/write mutex, so transitions and callbacks can be added while other
+// goroutines are transitioning. Without it a machine must be fully built
+// before it is shared.
+func WithLocking() Option {
+	return func(c *config) {
+		c.locking = true
+	}
+}
+
+// WithStrict makes AddTransition and AddTransitions panic, instead of
+// silently replacing the earlier transition, when an unguarded transition is
+// added for a state and event that already lead somewhere else. Use
+// AddTransitionStrict to get an error instead.
+func WithStrict() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// rlock takes a read lock when the machine was created WithLocking and has
+// not been frozen. Freezing needs the write lock, so frozen cannot change
+// between a matched rlock and runlock; the second check covers a reader that
+// was waiting on the lock while the machine was frozen.
+func (sm *StateMachine[S, E]) rlock() {
+	if !sm.locking || sm.frozen.Load() {
+		return
+	}
+	sm.mu.RLock()
+	if sm.frozen.Load() {
+		sm.mu.RUnlock()
+	}
+}
+
+func (sm *StateMachine[S, E]) runlock() {
+	if !sm.locking || sm.frozen.Load() {
+		return
+	}
+	sm.mu.RUnlock()
+}
+
+// lock takes a write lock when the machine was created WithLocking
+func (sm *StateMachine[S, E]) lock() {
+	if sm.locking {
+		sm.mu.Lock()
+	}
+}
+
+func (sm *StateMachine[S, E]) unlock() {
+	if sm.locking {
+		sm.mu.Unlock()
+	}
+}
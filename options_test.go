@@ -0,0 +1,45 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestWithLocking_ConcurrentUse exercises building and using a machine from
+// many goroutines at once; run with -race to detect unguarded access.
+func TestWithLocking_ConcurrentUse(t *testing.T) {
+	sm := NewStateMachine[UserState, UserEvent](WithLocking())
+	sm.AddTransition(UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				from := UserState(fmt.Sprintf("State%d-%d", i, j))
+				sm.AddTransition(from, UserEventSignupFailed, UserStateRejected)
+				sm.OnEnter(UserStateRejected, func(ctx context.Context, from, to UserState, event UserEvent) {})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := sm.Transition(UserStateInitial, UserEventSubmitSignUp); err != nil {
+					t.Errorf("Transition() error = %v", err)
+					return
+				}
+				sm.GetValidEvents(UserStateInitial)
+				sm.GetAllStates()
+				sm.Transition(UserStateRejected, UserEventSubmitSignUp)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(sm.GetAllStates()); got != 8*100+3 {
+		t.Errorf("GetAllStates() returned %d states, want %d", got, 8*100+3)
+	}
+}
@@ -0,0 +1,100 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoPath is returned by FindPath when no sequence of events leads from
+// one state to the other
+var ErrNoPath = errors.New("no path")
+
+// FindPath returns the shortest sequence of events leading from one state to
+// another, for example to show a user what remains before their sign-up is
+// complete:
+//
+//	events, err := sm.FindPath(UserStateEmailPendingVerification, UserStateSignUpComplete)
+//
+// Reaching a state nested inside to counts as reaching to. Transitions are
+// followed as Transition would follow them, including inherited ones and
+// those into initial substates; guards are assumed to pass and every target
+// of a choice is considered. Where several paths are equally short, the one
+// whose events come first by name is returned. The path from a state to
+// itself is empty.
+func (sm *StateMachine[S, E]) FindPath(from, to S) ([]E, error) {
+	sm.rlock()
+	defer sm.runlock()
+
+	return sm.findPath(from, to)
+}
+
+func (sm *StateMachine[S, E]) findPath(from, to S) ([]E, error) {
+	type step struct {
+		prev  S
+		event E
+	}
+	steps := make(map[S]step)
+	seen := map[S]bool{from: true}
+	queue := []S{from}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if sm.isIn(s, to) {
+			var events []E
+			for s != from {
+				events = append(events, steps[s].event)
+				s = steps[s].prev
+			}
+			for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+				events[i], events[j] = events[j], events[i]
+			}
+			return events, nil
+		}
+
+		for _, event := range sm.validEvents(s) {
+			for _, e := range sm.edgesFor(s, event) {
+				for _, target := range e.targets() {
+					next := sm.resolveTarget(target, NoHistory, nil)
+					if !seen[next] {
+						seen[next] = true
+						steps[next] = step{prev: s, event: event}
+						queue = append(queue, next)
+					}
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w from state '%s' to '%s'", ErrNoPath, Name(from), Name(to))
+}
+
+// CanReach reports whether some sequence of events leads from one state to
+// another, following transitions the same way as FindPath
+func (sm *StateMachine[S, E]) CanReach(from, to S) bool {
+	sm.rlock()
+	defer sm.runlock()
+
+	_, err := sm.findPath(from, to)
+	return err == nil
+}
+
+// GetSourcesOf returns the other states from which state can still be
+// reached, sorted by name, for example to find the orders that could yet be
+// refunded:
+//
+//	refundable := sm.GetSourcesOf(OrderStateRefunded)
+func (sm *StateMachine[S, E]) GetSourcesOf(state S) []S {
+	sm.rlock()
+	defer sm.runlock()
+
+	var sources []S
+	for _, s := range sm.allStates() {
+		if s == state {
+			continue
+		}
+		if _, err := sm.findPath(s, state); err == nil {
+			sources = append(sources, s)
+		}
+	}
+	sortStates(sources)
+	return sources
+}
@@ -0,0 +1,103 @@
+package statemachine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFindPath(t *testing.T) {
+	sm := NewOrderStateMachine()
+
+	tests := []struct {
+		name    string
+		from    OrderState
+		to      OrderState
+		want    []OrderEvent
+		wantErr bool
+	}{
+		{"same state", OrderStatePending, OrderStatePending, []OrderEvent{}, false},
+		{"forward", OrderStatePending, OrderStateShipped,
+			[]OrderEvent{OrderEventConfirm, OrderEventPack, OrderEventShip}, false},
+		{"shortest", OrderStatePending, OrderStateRefunded,
+			[]OrderEvent{OrderEventCancel, OrderEventRefund}, false},
+		{"inherited transition", OrderStateAwaiting, OrderStateCancelled,
+			[]OrderEvent{OrderEventCancel}, false},
+		{"into composite state", OrderStatePending, OrderStateProcessing,
+			[]OrderEvent{OrderEventConfirm}, false},
+		{"unreachable", OrderStateRefunded, OrderStatePending, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sm.FindPath(tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrNoPath) {
+					t.Errorf("FindPath() error = %v, want ErrNoPath", err)
+				}
+				return
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("FindPath() = %v, want %v", got, tt.want)
+			}
+			if end, err := sm.ValidateTransitionPath(tt.from, got); err != nil || !sm.IsSubstateOf(end, tt.to) {
+				t.Errorf("path %v ends in %v, %v, want %v", got, end, err, tt.to)
+			}
+		})
+	}
+}
+
+func TestFindPath_FollowsInitialSubstate(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.AddTransition(OrderStateCancelled, OrderEventConfirm, OrderStateProcessing)
+	if err := sm.SetInitialSubstate(OrderStateProcessing, OrderStatePacking); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := sm.FindPath(OrderStateCancelled, OrderStateAwaiting)
+	want := []OrderEvent{OrderEventConfirm, OrderEventPack}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("FindPath() = %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestCanReach(t *testing.T) {
+	sm := NewOrderStateMachine()
+
+	tests := []struct {
+		from OrderState
+		to   OrderState
+		want bool
+	}{
+		{OrderStatePending, OrderStateRefunded, true},
+		{OrderStatePacking, OrderStateCancelled, true},
+		{OrderStateShipped, OrderStateCancelled, false},
+		{OrderStateRefunded, OrderStatePending, false},
+		{OrderStateShipped, OrderStateShipped, true},
+	}
+	for _, tt := range tests {
+		if got := sm.CanReach(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanReach(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestGetSourcesOf(t *testing.T) {
+	sm := NewOrderStateMachine()
+
+	tests := []struct {
+		state OrderState
+		want  []OrderState
+	}{
+		{OrderStateCancelled, []OrderState{OrderStateAwaiting, OrderStatePacking, OrderStatePending, OrderStateProcessing}},
+		{OrderStateDelivered, []OrderState{OrderStateAwaiting, OrderStatePacking, OrderStatePending, OrderStateShipped}},
+		{OrderStatePending, nil},
+	}
+	for _, tt := range tests {
+		if got := sm.GetSourcesOf(tt.state); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetSourcesOf(%v) = %v, want %v", tt.state, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// payloadKey is the context key event payloads are stored under
+type payloadKey struct{}
+
+// WithPayload returns a copy of ctx carrying payload, the data that
+// accompanies an event. Guards, actions and callbacks of a transition run
+// with ctx can read it with PayloadFrom.
+func WithPayload(ctx context.Context, payload any) context.Context {
+	return context.WithValue(ctx, payloadKey{}, payload)
+}
+
+// PayloadFrom returns the payload carried by ctx if there is one of type P
+func PayloadFrom[P any](ctx context.Context) (P, bool) {
+	p, ok := ctx.Value(payloadKey{}).(P)
+	return p, ok
+}
+
+// PayloadGuard adapts a guard taking a typed payload. The guard rejects the
+// transition when the event carries no payload of type P.
+//
+//	statemachine.WithGuard(statemachine.PayloadGuard(
+//		func(ctx context.Context, from OrderState, event OrderEvent, info ShipmentInfo) bool {
+//			return info.Carrier != ""
+//		}))
+func PayloadGuard[S State, E Event, P any](fn func(ctx context.Context, from S, event E, payload P) bool) Guard[S, E] {
+	return func(ctx context.Context, from S, event E) bool {
+		p, ok := PayloadFrom[P](ctx)
+		if !ok {
+			return false
+		}
+		return fn(ctx, from, event, p)
+	}
+}
+
+// PayloadAction adapts an action taking a typed payload. The action fails,
+// aborting the transition, when the event carries no payload of type P.
+func PayloadAction[S State, E Event, P any](fn func(ctx context.Context, from, to S, event E, payload P) error) Action[S, E] {
+	return func(ctx context.Context, from, to S, event E) error {
+		p, ok := PayloadFrom[P](ctx)
+		if !ok {
+			return fmt.Errorf("event '%s' has payload %T, want %v", Name(event), ctx.Value(payloadKey{}), reflect.TypeFor[P]())
+		}
+		return fn(ctx, from, to, event, p)
+	}
+}
+
+// FireWith transitions the instance via event, passing payload to the
+// transition's guards, actions and callbacks:
+//
+//	err := order.FireWith(ctx, OrderEventShip, ShipmentInfo{Carrier: "DHL"})
+func (i *Instance[S, E]) FireWith(ctx context.Context, event E, payload any) error {
+	return i.Fire(WithPayload(ctx, payload), event)
+}
+
+// PayloadStateMachine is a state machine whose events carry payloads of
+// type P, so that the payload given when firing an event and the one guards
+// and actions receive are checked at compile time:
+//
+//	sm := statemachine.NewPayloadStateMachine[OrderState, OrderEvent, ShipmentInfo]()
+//	sm.AddTransition(OrderStateProcessing, OrderEventShip, OrderStateShipped,
+//		sm.Guard(func(ctx context.Context, from OrderState, event OrderEvent, info ShipmentInfo) bool {
+//			return info.Carrier != ""
+//		}))
+//	order := sm.NewInstanceAt(OrderStateProcessing)
+//	err := order.Fire(ctx, OrderEventShip, ShipmentInfo{Carrier: "DHL"})
+//
+// Everything else is done through the embedded StateMachine, which fires
+// events without a payload as usual.
+type PayloadStateMachine[S State, E Event, P any] struct {
+	*StateMachine[S, E]
+}
+
+// NewPayloadStateMachine creates a state machine whose events carry
+// payloads of type P
+func NewPayloadStateMachine[S State, E Event, P any](opts ...Option) *PayloadStateMachine[S, E, P] {
+	return &PayloadStateMachine[S, E, P]{StateMachine: NewStateMachine[S, E](opts...)}
+}
+
+// Guard attaches a guard taking the event's payload to a transition, as
+// WithGuard and PayloadGuard would
+func (sm *PayloadStateMachine[S, E, P]) Guard(fn func(ctx context.Context, from S, event E, payload P) bool) TransitionOption[S, E] {
+	return WithGuard(PayloadGuard(fn))
+}
+
+// Action attaches an action taking the event's payload to a transition, as
+// WithAction and PayloadAction would
+func (sm *PayloadStateMachine[S, E, P]) Action(fn func(ctx context.Context, from, to S, event E, payload P) error) TransitionOption[S, E] {
+	return WithAction(PayloadAction(fn))
+}
+
+// TransitionWith is like TransitionContext, passing payload to the
+// transition's guards, actions and callbacks
+func (sm *PayloadStateMachine[S, E, P]) TransitionWith(ctx context.Context, from S, event E, payload P) (S, error) {
+	return sm.TransitionContext(WithPayload(ctx, payload), from, event)
+}
+
+// NewInstance creates an instance in the machine's initial state, as
+// StateMachine.NewInstance does, whose events carry payloads of type P
+func (sm *PayloadStateMachine[S, E, P]) NewInstance() *PayloadInstance[S, E, P] {
+	return &PayloadInstance[S, E, P]{Instance: sm.StateMachine.NewInstance()}
+}
+
+// NewInstanceAt creates an instance in state, as StateMachine.NewInstanceAt
+// does, whose events carry payloads of type P
+func (sm *PayloadStateMachine[S, E, P]) NewInstanceAt(state S) *PayloadInstance[S, E, P] {
+	return &PayloadInstance[S, E, P]{Instance: sm.StateMachine.NewInstanceAt(state)}
+}
+
+// PayloadInstance is an instance of a PayloadStateMachine. Fire takes the
+// event's payload; the embedded Instance fires events without one.
+type PayloadInstance[S State, E Event, P any] struct {
+	*Instance[S, E]
+}
+
+// Fire transitions the instance via event, passing payload to the
+// transition's guards, actions and callbacks
+func (i *PayloadInstance[S, E, P]) Fire(ctx context.Context, event E, payload P) error {
+	return i.Instance.FireWith(ctx, event, payload)
+}
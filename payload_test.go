@@ -0,0 +1,133 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type shipmentInfo struct {
+	Carrier  string
+	Tracking string
+}
+
+func newShippingMachine(shipped *shipmentInfo) *StateMachine[OrderState, OrderEvent] {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped,
+		WithGuard(PayloadGuard(func(ctx context.Context, from OrderState, event OrderEvent, info shipmentInfo) bool {
+			return info.Carrier != ""
+		})),
+		WithAction(PayloadAction(func(ctx context.Context, from, to OrderState, event OrderEvent, info shipmentInfo) error {
+			*shipped = info
+			return nil
+		})),
+	)
+	return sm
+}
+
+func TestFireWith_PassesPayload(t *testing.T) {
+	var shipped shipmentInfo
+	var entered string
+	sm := newShippingMachine(&shipped)
+	sm.OnEnter(OrderStateShipped, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+		info, _ := PayloadFrom[shipmentInfo](ctx)
+		entered = info.Tracking
+	})
+
+	order := sm.NewInstanceAt(OrderStateAwaiting)
+	err := order.FireWith(context.Background(), OrderEventShip, shipmentInfo{Carrier: "DHL", Tracking: "JD0001"})
+	if err != nil {
+		t.Fatalf("FireWith() error = %v", err)
+	}
+	if shipped.Carrier != "DHL" {
+		t.Errorf("action payload = %+v, want carrier DHL", shipped)
+	}
+	if entered != "JD0001" {
+		t.Errorf("callback payload tracking = %q, want %q", entered, "JD0001")
+	}
+}
+
+func TestPayloadGuard_Rejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload any
+	}{
+		{"guard fails", shipmentInfo{}},
+		{"wrong payload type", "DHL"},
+		{"no payload", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var shipped shipmentInfo
+			order := newShippingMachine(&shipped).NewInstanceAt(OrderStateAwaiting)
+
+			ctx := context.Background()
+			if tt.payload != nil {
+				ctx = WithPayload(ctx, tt.payload)
+			}
+			if err := order.Fire(ctx, OrderEventShip); !errors.Is(err, ErrGuardRejected) {
+				t.Errorf("Fire() error = %v, want ErrGuardRejected", err)
+			}
+			if !order.Is(OrderStateAwaiting) {
+				t.Errorf("Current() = %v, want %v", order.Current(), OrderStateAwaiting)
+			}
+		})
+	}
+}
+
+func TestPayloadAction_WrongType(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped,
+		WithAction(PayloadAction(func(ctx context.Context, from, to OrderState, event OrderEvent, info shipmentInfo) error {
+			return nil
+		})),
+	)
+
+	_, err := sm.TransitionContext(WithPayload(context.Background(), 42), OrderStateAwaiting, OrderEventShip)
+	if err == nil || !strings.Contains(err.Error(), "event 'Ship' has payload int, want statemachine.shipmentInfo") {
+		t.Errorf("TransitionContext() error = %v, want payload type error", err)
+	}
+}
+
+func TestPayloadStateMachine(t *testing.T) {
+	ctx := context.Background()
+	var shipped []string
+	sm := NewPayloadStateMachine[OrderState, OrderEvent, shipmentInfo]()
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped,
+		sm.Guard(func(ctx context.Context, from OrderState, event OrderEvent, info shipmentInfo) bool {
+			return info.Carrier != ""
+		}),
+		sm.Action(func(ctx context.Context, from, to OrderState, event OrderEvent, info shipmentInfo) error {
+			shipped = append(shipped, info.Tracking)
+			return nil
+		}))
+	sm.AddTransition(OrderStateShipped, OrderEventDeliver, OrderStateDelivered)
+
+	order := sm.NewInstanceAt(OrderStateAwaiting)
+	if err := order.Fire(ctx, OrderEventShip, shipmentInfo{}); !errors.Is(err, ErrGuardRejected) {
+		t.Errorf("Fire() without a carrier error = %v, want %v", err, ErrGuardRejected)
+	}
+	if err := order.Fire(ctx, OrderEventShip, shipmentInfo{Carrier: "DHL", Tracking: "JD0001"}); err != nil {
+		t.Fatalf("Fire() error = %v", err)
+	}
+	if err := order.Instance.Fire(ctx, OrderEventDeliver); err != nil {
+		t.Fatalf("Fire() without a payload error = %v", err)
+	}
+	if !order.Is(OrderStateDelivered) {
+		t.Errorf("Current() = %v, want Delivered", order.Current())
+	}
+
+	to, err := sm.Freeze().TransitionContext(ctx, OrderStateAwaiting, OrderEventShip)
+	if !errors.Is(err, ErrGuardRejected) {
+		t.Errorf("TransitionContext() without a payload = %v, %v, want %v", to, err, ErrGuardRejected)
+	}
+	if to, err := sm.TransitionWith(ctx, OrderStateAwaiting, OrderEventShip, shipmentInfo{Carrier: "UPS", Tracking: "1Z"}); err != nil || to != OrderStateShipped {
+		t.Errorf("TransitionWith() = %v, %v, want Shipped", to, err)
+	}
+	if want := []string{"JD0001", "1Z"}; !slices.Equal(shipped, want) {
+		t.Errorf("actions received %v, want %v", shipped, want)
+	}
+}
@@ -0,0 +1,81 @@
+package statemachine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportPlantUML writes the machine to w as a PlantUML state diagram:
+//
+//	@startuml
+//	[*] --> Pending
+//	Pending --> Packing : Confirm
+//	...
+//	@enduml
+//
+// States and transitions are labelled as for ExportDOT, substates are drawn
+// inside their parent state and guarded transitions are dashed. A transition
+// with a metadata description gets a note on its arrow holding the
+// description. The output is stable: the same machine always produces the
+// same text.
+func (sm *StateMachine[S, E]) ExportPlantUML(w io.Writer) error {
+	sm.rlock()
+	d := sm.diagram()
+	sm.runlock()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("@startuml\n")
+	for _, s := range d.roots {
+		writePlantUMLState(bw, d, s, "")
+	}
+
+	if d.hasInitial {
+		fmt.Fprintf(bw, "[*] --> %s\n", diagramID(d.initial))
+	}
+	for _, e := range d.edges {
+		arrow := "-->"
+		if e.guarded {
+			arrow = "-[dashed]->"
+		}
+		fmt.Fprintf(bw, "%s %s %s : %s\n", diagramID(e.from), arrow, diagramID(e.to), plantUMLText(e.eventLabel()))
+		if e.meta.Description != "" {
+			bw.WriteString("note on link\n")
+			for _, line := range strings.Split(e.meta.Description, "\n") {
+				fmt.Fprintf(bw, "  %s\n", line)
+			}
+			bw.WriteString("end note\n")
+		}
+	}
+	for _, s := range sortedFinal(d.final) {
+		fmt.Fprintf(bw, "%s --> [*]\n", diagramID(s))
+	}
+	bw.WriteString("@enduml\n")
+	return bw.Flush()
+}
+
+// writePlantUMLState declares a state, along with the substates of a
+// composite state
+func writePlantUMLState[S State, E Event](w *bufio.Writer, d diagram[S, E], s S, indent string) {
+	decl := "state " + diagramID(s)
+	if label := d.label(s); label != diagramID(s) {
+		decl = fmt.Sprintf("state \"%s\" as %s", strings.ReplaceAll(plantUMLText(label), `"`, "'"), diagramID(s))
+	}
+
+	children, composite := d.children[s]
+	if !composite {
+		fmt.Fprintf(w, "%s%s\n", indent, decl)
+		return
+	}
+	fmt.Fprintf(w, "%s%s {\n", indent, decl)
+	for _, child := range children {
+		writePlantUMLState(w, d, child, indent+"  ")
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+// plantUMLText makes a label safe to use on one line
+func plantUMLText(s string) string {
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,54 @@
+package statemachine
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExportPlantUML(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking,
+		WithMeta[OrderState, OrderEvent](Metadata{Description: "Payment has cleared\nand stock is reserved"}))
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateCancelled,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }))
+	sm.AddTransition(OrderStatePacking, OrderEventPack, OrderStateAwaiting,
+		WithMeta[OrderState, OrderEvent](Metadata{Label: "Packed"}))
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped)
+	sm.AddTransition(OrderStateProcessing, OrderEventCancel, OrderStateCancelled)
+	sm.SetParent(OrderStatePacking, OrderStateProcessing)
+	sm.SetParent(OrderStateAwaiting, OrderStateProcessing)
+	sm.SetStateMeta(OrderStateAwaiting, Metadata{Label: "Awaiting courier"})
+	sm.SetInitialState(OrderStatePending)
+	sm.AddFinalState(OrderStateShipped)
+
+	var b strings.Builder
+	if err := sm.ExportPlantUML(&b); err != nil {
+		t.Fatalf("ExportPlantUML() error = %v", err)
+	}
+
+	want := `@startuml
+state Cancelled
+state Pending
+state Processing {
+  state "Awaiting courier" as AwaitingCourier
+  state Packing
+}
+state Shipped
+[*] --> Pending
+AwaitingCourier --> Shipped : Ship
+Packing --> AwaitingCourier : Packed
+Pending -[dashed]-> Cancelled : Confirm
+Pending --> Packing : Confirm
+note on link
+  Payment has cleared
+  and stock is reserved
+end note
+Processing --> Cancelled : Cancel
+Shipped --> [*]
+@enduml
+`
+	if got := b.String(); got != want {
+		t.Errorf("ExportPlantUML() =\n%s\nwant\n%s", got, want)
+	}
+}
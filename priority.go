@@ -0,0 +1,49 @@
+package statemachine
+
+// WithPriority sets the priority of a transition competing with others for
+// the same state and event. When an event fires, the transitions are tried
+// highest priority first and the first whose guards pass is taken. Among
+// those of equal priority, guarded transitions are tried before an unguarded
+// one, and otherwise in the order they were added. The default priority is 0.
+//
+//	sm.AddTransition(Reviewing, Decide, Escalated,
+//		statemachine.WithGuard(isHighValue),
+//		statemachine.WithPriority[ReviewState, ReviewEvent](10))
+//	sm.AddTransition(Reviewing, Decide, Approved, statemachine.WithGuard(hasTwoReviews))
+//	sm.AddTransition(Reviewing, Decide, Rejected)
+func WithPriority[S State, E Event](n int) TransitionOption[S, E] {
+	return func(e *edge[S, E]) {
+		e.priority = n
+	}
+}
+
+// addEdge adds e to the transitions competing for an event, keeping them in
+// the order they are tried. An unguarded transition replaces any existing
+// unguarded one, as only one of them could ever be taken.
+func addEdge[S State, E Event](edges []*edge[S, E], e *edge[S, E]) []*edge[S, E] {
+	if len(e.guards) == 0 {
+		for i, existing := range edges {
+			if len(existing.guards) == 0 {
+				edges = append(edges[:i:i], edges[i+1:]...)
+				break
+			}
+		}
+	}
+
+	i := 0
+	for i < len(edges) && edges[i].triedBefore(e) {
+		i++
+	}
+	edges = append(edges, nil)
+	copy(edges[i+1:], edges[i:])
+	edges[i] = e
+	return edges
+}
+
+// triedBefore reports whether e is tried before other, which was added later
+func (e *edge[S, E]) triedBefore(other *edge[S, E]) bool {
+	if e.priority != other.priority {
+		return e.priority > other.priority
+	}
+	return len(e.guards) > 0 || len(other.guards) == 0
+}
@@ -0,0 +1,117 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const docEscalated docState = "Escalated"
+
+type reviewKey struct{}
+
+type reviewInfo struct {
+	score int
+	value int
+}
+
+func reviewFrom(ctx context.Context) reviewInfo {
+	r, _ := ctx.Value(reviewKey{}).(reviewInfo)
+	return r
+}
+
+func isHighValue(ctx context.Context, from docState, event docEvent) bool {
+	return reviewFrom(ctx).value > 1000
+}
+
+func scoresWell(ctx context.Context, from docState, event docEvent) bool {
+	return reviewFrom(ctx).score >= 7
+}
+
+func TestWithPriority_CompetingTransitions(t *testing.T) {
+	sm := NewStateMachine[docState, docEvent]()
+	// added lowest priority first to show order of registration does not matter
+	sm.AddTransition(docReview, docScore, docRejected)
+	sm.AddTransition(docReview, docScore, docApproved, WithGuard(scoresWell))
+	sm.AddTransition(docReview, docScore, docEscalated, WithGuard(isHighValue), WithPriority[docState, docEvent](10))
+
+	tests := []struct {
+		name   string
+		review reviewInfo
+		want   docState
+	}{
+		{"highest priority passing guard wins", reviewInfo{score: 9, value: 5000}, docEscalated},
+		{"next guard tried when first fails", reviewInfo{score: 9, value: 10}, docApproved},
+		{"unguarded transition as fallback", reviewInfo{score: 2, value: 10}, docRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), reviewKey{}, tt.review)
+			got, err := sm.TransitionContext(ctx, docReview, docScore)
+			if err != nil || got != tt.want {
+				t.Errorf("TransitionContext() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithPriority_TiesInRegistrationOrder(t *testing.T) {
+	sm := NewStateMachine[docState, docEvent]()
+	always := func(ctx context.Context, from docState, event docEvent) bool { return true }
+	sm.AddTransition(docReview, docScore, docApproved, WithGuard(always))
+	sm.AddTransition(docReview, docScore, docRejected, WithGuard(always))
+
+	for range 10 {
+		if got, _ := sm.Transition(docReview, docScore); got != docApproved {
+			t.Fatalf("Transition() = %v, want first registered %v", got, docApproved)
+		}
+	}
+}
+
+func TestAddTransition_UnguardedReplacesUnguarded(t *testing.T) {
+	sm := NewStateMachine[docState, docEvent]()
+	sm.AddTransition(docReview, docScore, docApproved, WithGuard(scoresWell))
+	sm.AddTransition(docReview, docScore, docRejected)
+	sm.AddTransition(docReview, docScore, docEscalated)
+
+	got, err := sm.Transition(docReview, docScore)
+	if err != nil || got != docEscalated {
+		t.Errorf("Transition() = %v, %v, want %v", got, err, docEscalated)
+	}
+	if next, _ := sm.GetNextState(docReview, docScore); next != docApproved {
+		t.Errorf("GetNextState() = %v, want first tried %v", next, docApproved)
+	}
+}
+
+func TestWithPriority_AllGuardsReject(t *testing.T) {
+	sm := NewStateMachine[docState, docEvent]()
+	sm.AddTransition(docReview, docScore, docApproved, WithGuard(scoresWell))
+	sm.AddTransition(docReview, docScore, docEscalated, WithGuard(isHighValue))
+
+	if _, err := sm.Transition(docReview, docScore); !errors.Is(err, ErrGuardRejected) {
+		t.Errorf("Transition() error = %v, want ErrGuardRejected", err)
+	}
+}
+
+func TestBuilder_WithPriority(t *testing.T) {
+	b := NewBuilder[docState, docEvent]()
+	b.From(docReview).On(docScore).To(docRejected)
+	b.From(docReview).On(docScore).To(docApproved).WithGuard(scoresWell)
+	b.From(docReview).On(docScore).To(docEscalated).WithGuard(isHighValue).WithPriority(1)
+	sm, err := b.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), reviewKey{}, reviewInfo{score: 8, value: 2000})
+	if got, err := sm.TransitionContext(ctx, docReview, docScore); err != nil || got != docEscalated {
+		t.Errorf("TransitionContext() = %v, %v, want %v", got, err, docEscalated)
+	}
+
+	b.From(docReview).On(docScore).To(docDraft)
+	if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), "transition 4 (event 'Score' from 'Review'): already defined by transition 1") {
+		t.Errorf("Build() error = %v, want second unguarded transition rejected", err)
+	}
+}
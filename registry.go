@@ -0,0 +1,180 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ErrDuplicateMachine is returned by Register for a name already taken
+var ErrDuplicateMachine = errors.New("machine already registered")
+
+// Registry holds machines under names, so an application hosting many
+// workflows can find them from configuration or a request's route rather
+// than wiring each one by hand:
+//
+//	var machines statemachine.Registry
+//	statemachine.Register(&machines, "users", NewUserStateMachine())
+//	statemachine.Register(&machines, "orders", NewOrderStateMachine())
+//	...
+//	m, ok := machines.Machine(r.PathValue("workflow"))
+//
+// Machines are found by name either with their types, through Lookup, or
+// without them, as a Machine naming states and events by their String
+// values. The zero Registry is empty and ready to use, and is safe for
+// concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	machines map[string]registered
+}
+
+type registered struct {
+	typed  any
+	erased Machine
+}
+
+// Machine is a StateMachine of any state and event types, with states and
+// events named by Name. Registry.Machine returns one.
+type Machine interface {
+	// States returns every state of the machine, sorted by name
+	States() []string
+
+	// Events returns every event of the machine, sorted by name
+	Events() []string
+
+	// ValidEvents returns the events valid from a state, sorted by name. It
+	// fails with an error matching ErrUnknownState if the machine has no
+	// such state.
+	ValidEvents(from string) ([]string, error)
+
+	// Transition is the machine's TransitionContext. It fails with an error
+	// matching ErrUnknownState or ErrUnknownEvent if the machine has no
+	// state or event with the given name.
+	Transition(ctx context.Context, from, event string) (string, error)
+
+	// Definition describes the machine, as ToDefinition does
+	Definition() *Definition
+}
+
+// Register adds sm to the registry under name, failing with an error
+// matching ErrDuplicateMachine if the name is taken
+func Register[S State, E Event](r *Registry, name string, sm *StateMachine[S, E]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.machines[name]; exists {
+		return fmt.Errorf("cannot register %q: %w", name, ErrDuplicateMachine)
+	}
+	if r.machines == nil {
+		r.machines = make(map[string]registered)
+	}
+	r.machines[name] = registered{typed: sm, erased: erasedMachine[S, E]{sm}}
+	return nil
+}
+
+// Lookup returns the machine registered under name, if there is one with
+// the given state and event types
+func Lookup[S State, E Event](r *Registry, name string) (*StateMachine[S, E], bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sm, ok := r.machines[name].typed.(*StateMachine[S, E])
+	return sm, ok
+}
+
+// Machine returns the machine registered under name, whatever its types
+func (r *Registry) Machine(name string) (Machine, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := r.machines[name]
+	return m.erased, ok
+}
+
+// Unregister removes the machine registered under name, reporting whether
+// there was one
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.machines[name]
+	delete(r.machines, name)
+	return ok
+}
+
+// Names returns the names machines are registered under, sorted
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.machines))
+	for name := range r.machines {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// erasedMachine is the Machine of a StateMachine
+type erasedMachine[S State, E Event] struct {
+	sm *StateMachine[S, E]
+}
+
+func (m erasedMachine[S, E]) States() []string {
+	return stringsOf(m.sm.GetAllStates())
+}
+
+func (m erasedMachine[S, E]) Events() []string {
+	return stringsOf(m.sm.GetAllEvents())
+}
+
+func (m erasedMachine[S, E]) ValidEvents(from string) ([]string, error) {
+	state, err := m.state(from)
+	if err != nil {
+		return nil, err
+	}
+	return stringsOf(m.sm.GetValidEvents(state)), nil
+}
+
+func (m erasedMachine[S, E]) Transition(ctx context.Context, from, event string) (string, error) {
+	state, err := m.state(from)
+	if err != nil {
+		return "", err
+	}
+	events := m.sm.GetAllEvents()
+	i := slices.IndexFunc(events, func(e E) bool { return Name(e) == event })
+	if i < 0 {
+		return "", fmt.Errorf("no event %q: %w", event, ErrUnknownEvent)
+	}
+	to, err := m.sm.TransitionContext(ctx, state, events[i])
+	if err != nil {
+		return "", err
+	}
+	return Name(to), nil
+}
+
+func (m erasedMachine[S, E]) Definition() *Definition {
+	return m.sm.ToDefinition()
+}
+
+// state finds the machine's state with the given name
+func (m erasedMachine[S, E]) state(name string) (S, error) {
+	for _, s := range m.sm.GetAllStates() {
+		if Name(s) == name {
+			return s, nil
+		}
+	}
+	var zero S
+	return zero, fmt.Errorf("no state %q: %w", name, ErrUnknownState)
+}
+
+// stringsOf returns the name of each of values
+func stringsOf[T comparable](values []T) []string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = Name(v)
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	var r Registry
+	users, orders := NewUserStateMachine(), NewOrderStateMachine()
+	if err := Register(&r, "users", users); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(&r, "orders", orders); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(&r, "users", NewUserStateMachine()); !errors.Is(err, ErrDuplicateMachine) {
+		t.Errorf("Register() of a taken name error = %v, want %v", err, ErrDuplicateMachine)
+	}
+
+	if got, want := r.Names(), []string{"orders", "users"}; !slices.Equal(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+	if sm, ok := Lookup[UserState, UserEvent](&r, "users"); !ok || sm != users {
+		t.Errorf("Lookup() = %p, %v, want the users machine", sm, ok)
+	}
+	if _, ok := Lookup[UserState, UserEvent](&r, "orders"); ok {
+		t.Error("Lookup() found the orders machine with the users machine's types")
+	}
+	if _, ok := r.Machine("documents"); ok {
+		t.Error("Machine() found an unregistered machine")
+	}
+
+	if !r.Unregister("users") || r.Unregister("users") {
+		t.Error("Unregister() did not report removing the machine once")
+	}
+	if _, ok := r.Machine("users"); ok {
+		t.Error("Machine() found an unregistered machine")
+	}
+}
+
+func TestRegistry_Machine(t *testing.T) {
+	var r Registry
+	if err := Register(&r, "orders", NewOrderStateMachine()); err != nil {
+		t.Fatal(err)
+	}
+	m, ok := r.Machine("orders")
+	if !ok {
+		t.Fatal("Machine() did not find the orders machine")
+	}
+	ctx := context.Background()
+
+	if got := m.States(); len(got) != 8 || got[0] != "AwaitingCourier" {
+		t.Errorf("States() = %v", got)
+	}
+	if got := m.Events(); !slices.Contains(got, "Ship") {
+		t.Errorf("Events() = %v, want Ship among them", got)
+	}
+	if got, err := m.ValidEvents("Pending"); err != nil || !slices.Equal(got, []string{"Cancel", "Confirm"}) {
+		t.Errorf("ValidEvents() = %v, %v, want [Cancel Confirm]", got, err)
+	}
+	if got, err := m.Transition(ctx, "Pending", "Confirm"); err != nil || got != "Packing" {
+		t.Errorf("Transition() = %v, %v, want Packing", got, err)
+	}
+	if _, err := m.Transition(ctx, "Shipped", "Confirm"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Transition() error = %v, want %v", err, ErrInvalidTransition)
+	}
+	if _, err := m.Transition(ctx, "Lost", "Confirm"); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("Transition() from an unknown state error = %v, want %v", err, ErrUnknownState)
+	}
+	if _, err := m.ValidEvents("Lost"); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("ValidEvents() of an unknown state error = %v, want %v", err, ErrUnknownState)
+	}
+	if _, err := m.Transition(ctx, "Pending", "Teleport"); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Transition() of an unknown event error = %v, want %v", err, ErrUnknownEvent)
+	}
+	if def := m.Definition(); len(def.Transitions) != 8 {
+		t.Errorf("Definition() has %d transitions, want 8", len(def.Transitions))
+	}
+}
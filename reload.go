@@ -0,0 +1,178 @@
+package statemachine
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrReloadRejected is matched by the error Reload returns when the
+// Reloader's Check rejects a reloaded machine
+var ErrReloadRejected = errors.New("reload rejected")
+
+// Reloader keeps a machine built from definition files up to date as the
+// files change, so a workflow's guards and timeouts can be adjusted without
+// redeploying:
+//
+//	orders, err := statemachine.NewReloader(os.DirFS("/etc/workflows"),
+//		func(def *statemachine.Definition) (*statemachine.StateMachine[OrderState, OrderEvent], error) {
+//			return statemachine.FromDefinitionWithGuards(def, allOrderStates, allOrderEvents, orderGuards)
+//		}, "order/*.yaml")
+//	...
+//	go orders.Watch(ctx, 10*time.Second)
+//	...
+//	newState, err := orders.Machine().TransitionContext(ctx, order.State, OrderEventShip)
+//
+// A reloaded machine is validated as the definition is parsed and built,
+// and by Check if it is set; one failing either is not used, and the
+// current machine stays in place. Otherwise it replaces the current machine
+// at once. Instances, Managers and anything else given a machine keep the
+// one they were given, so fetch it with Machine for each use instead.
+type Reloader[S State, E Event] struct {
+	// Check, if set, is called with the current machine and a reloaded one
+	// before it replaces the current one. Returning an error rejects it,
+	// for example if it drops states entities may be stored in:
+	//
+	//	reloader.Check = func(current, next *statemachine.StateMachine[OrderState, OrderEvent]) error {
+	//		if removed := statemachine.Diff(current, next).RemovedStates; len(removed) > 0 {
+	//			return fmt.Errorf("states %v removed", removed)
+	//		}
+	//		return nil
+	//	}
+	Check func(current, next *StateMachine[S, E]) error
+
+	// OnReload, if set, is called by Watch with each machine it swaps in
+	OnReload func(sm *StateMachine[S, E])
+
+	// OnError, if set, is called by Watch with the error of each reload
+	// that fails or is rejected
+	OnError func(err error)
+
+	fsys     fs.FS
+	patterns []string
+	build    func(def *Definition) (*StateMachine[S, E], error)
+
+	// mu serializes reloads, and guards digest, the digest of the files
+	// the current machine was built from
+	mu      sync.Mutex
+	digest  [sha256.Size]byte
+	current atomic.Pointer[StateMachine[S, E]]
+}
+
+// NewReloader builds a machine from the definition files in fsys matching
+// the given glob patterns, parsed with ParseDefinitionFS and passed to
+// build, and returns a Reloader holding it
+func NewReloader[S State, E Event](fsys fs.FS, build func(def *Definition) (*StateMachine[S, E], error), patterns ...string) (*Reloader[S, E], error) {
+	r := &Reloader[S, E]{fsys: fsys, patterns: patterns, build: build}
+	digest, err := r.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	sm, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.digest = digest
+	r.current.Store(sm)
+	return r, nil
+}
+
+// Machine returns the current machine
+func (r *Reloader[S, E]) Machine() *StateMachine[S, E] {
+	return r.current.Load()
+}
+
+// Reload rebuilds the machine if its definition files have changed since it
+// was last built, reporting whether it replaced the current machine. If the
+// files cannot be read or parsed, build fails, or Check rejects the new
+// machine, the current machine is kept and the error returned; rejections
+// match ErrReloadRejected.
+func (r *Reloader[S, E]) Reload() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	digest, err := r.fingerprint()
+	if err != nil {
+		return false, err
+	}
+	if digest == r.digest {
+		return false, nil
+	}
+	sm, err := r.load()
+	if err != nil {
+		return false, err
+	}
+	if r.Check != nil {
+		if err := r.Check(r.current.Load(), sm); err != nil {
+			return false, fmt.Errorf("%w: %w", ErrReloadRejected, err)
+		}
+	}
+	r.digest = digest
+	r.current.Store(sm)
+	return true, nil
+}
+
+// Watch calls Reload every interval until ctx is done, reporting the
+// outcome to OnReload and OnError. A rejected version of the files is
+// reported each time it is checked until they change again.
+func (r *Reloader[S, E]) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := r.Reload()
+		switch {
+		case err != nil && r.OnError != nil:
+			r.OnError(err)
+		case reloaded && r.OnReload != nil:
+			r.OnReload(r.Machine())
+		}
+	}
+}
+
+// load builds a machine from the definition files
+func (r *Reloader[S, E]) load() (*StateMachine[S, E], error) {
+	def, err := ParseDefinitionFS(r.fsys, r.patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return r.build(def)
+}
+
+// fingerprint returns a digest of the names and contents of the definition
+// files
+func (r *Reloader[S, E]) fingerprint() ([sha256.Size]byte, error) {
+	var files []string
+	for _, pattern := range r.patterns {
+		matches, err := fs.Glob(r.fsys, pattern)
+		if err != nil {
+			return [sha256.Size]byte{}, fmt.Errorf("invalid definition pattern %q: %w", pattern, err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, file := range files {
+		data, err := fs.ReadFile(r.fsys, file)
+		if err != nil {
+			return [sha256.Size]byte{}, &DefinitionError{File: file, Err: err}
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", file, len(data))
+		h.Write(data)
+	}
+	var digest [sha256.Size]byte
+	h.Sum(digest[:0])
+	return digest, nil
+}
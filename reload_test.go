@@ -0,0 +1,147 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"signup/states.json":      {Data: []byte(userStatesFile)},
+		"signup/transitions.json": {Data: []byte(userTransitionsFile)},
+	}
+	reloader, err := NewReloader(fsys, func(def *Definition) (*StateMachine[UserState, UserEvent], error) {
+		return FromDefinition(def, allUserStates, allUserEvents)
+	}, "signup/*.json")
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	reloader.Check = func(current, next *StateMachine[UserState, UserEvent]) error {
+		if removed := Diff(current, next).RemovedTransitions; len(removed) > 0 {
+			return errors.New("transitions removed")
+		}
+		return nil
+	}
+	if reloaded, err := reloader.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() of unchanged files = %v, %v, want false", reloaded, err)
+	}
+
+	tests := []struct {
+		name         string
+		transitions  string
+		wantReloaded bool
+		wantErr      error
+		wantErrAgain bool
+	}{
+		{
+			name: "adds a transition",
+			transitions: `{"transitions": [
+  {"from": "Initial", "event": "SubmitSignup", "to": "EmailPendingVerification"},
+  {"from": "EmailPendingVerification", "event": "ClickVerificationLink", "to": "EmailVerified"},
+  {"from": "EmailVerified", "event": "CompleteProfile", "to": "SignUpComplete"},
+  {"from": "EmailPendingVerification", "event": "SignUpFailed", "to": "SignupRejected"}
+]}`,
+			wantReloaded: true,
+		},
+		{
+			name:         "fails to parse",
+			transitions:  `{"transitions": [`,
+			wantErr:      &DefinitionError{},
+			wantErrAgain: true,
+		},
+		{
+			name:         "rejected by Check",
+			transitions:  userTransitionsFile,
+			wantErr:      ErrReloadRejected,
+			wantErrAgain: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := reloader.Machine()
+			fsys["signup/transitions.json"] = &fstest.MapFile{Data: []byte(tt.transitions)}
+
+			reloaded, err := reloader.Reload()
+			if reloaded != tt.wantReloaded || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("Reload() = %v, %v, want %v, %v", reloaded, err, tt.wantReloaded, tt.wantErr)
+			}
+			if tt.wantErr == ErrReloadRejected && !errors.Is(err, ErrReloadRejected) {
+				t.Errorf("Reload() error = %v, want %v", err, ErrReloadRejected)
+			}
+			if swapped := reloader.Machine() != before; swapped != tt.wantReloaded {
+				t.Errorf("machine swapped = %v, want %v", swapped, tt.wantReloaded)
+			}
+			if _, err := reloader.Reload(); (err != nil) != tt.wantErrAgain {
+				t.Errorf("Reload() again error = %v, want an error %v", err, tt.wantErrAgain)
+			}
+		})
+	}
+
+	if !reloader.Machine().CanTransition(UserStateEmailPendingVerification, UserEventSignupFailed) {
+		t.Error("current machine lacks the added transition")
+	}
+}
+
+// syncFS is a MapFS that files can be written to while it is read
+type syncFS struct {
+	mu    sync.Mutex
+	files fstest.MapFS
+}
+
+func (f *syncFS) Open(name string) (fs.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.files.Open(name)
+}
+
+func (f *syncFS) write(name, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[name] = &fstest.MapFile{Data: []byte(data)}
+}
+
+func TestReloader_Watch(t *testing.T) {
+	fsys := &syncFS{files: fstest.MapFS{
+		"signup.json": {Data: []byte(userStatesFile)},
+	}}
+	reloader, err := NewReloader(fsys, func(def *Definition) (*StateMachine[UserState, UserEvent], error) {
+		return FromDefinition(def, allUserStates, allUserEvents)
+	}, "*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan *StateMachine[UserState, UserEvent], 1)
+	reloader.OnReload = func(sm *StateMachine[UserState, UserEvent]) { reloads <- sm }
+	errs := make(chan error, 1)
+	reloader.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reloader.Watch(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	fsys.write("transitions.json", userTransitionsFile)
+	if sm := <-reloads; !sm.CanTransition(UserStateInitial, UserEventSubmitSignUp) {
+		t.Error("OnReload got a machine without the added transitions")
+	}
+	fsys.write("transitions.json", "{")
+	var de *DefinitionError
+	if err := <-errs; !errors.As(err, &de) || de.File != "transitions.json" {
+		t.Errorf("OnError got %v, want a DefinitionError for transitions.json", err)
+	}
+	cancel()
+	<-done
+}
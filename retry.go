@@ -0,0 +1,118 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy says how an action that fails is retried before its
+// transition fails. The zero value makes three attempts, waiting 100ms and
+// then 200ms between them, and retries every error.
+type RetryPolicy struct {
+	// MaxAttempts is how many times the action is run in all, including
+	// the first. If zero, it is 3.
+	MaxAttempts int
+
+	// InitialDelay is the wait before the first retry. If zero, it is
+	// 100ms.
+	InitialDelay time.Duration
+
+	// Multiplier scales the wait after each retry. If zero, it is 2.
+	Multiplier float64
+
+	// MaxDelay caps the wait between attempts. If zero, it is not capped.
+	MaxDelay time.Duration
+
+	// Jitter randomly shortens each wait by up to this fraction of it, so
+	// that many failing actions do not all retry at once. It is between 0
+	// and 1.
+	Jitter float64
+
+	// Retryable reports whether an error is worth retrying. If nil, every
+	// error is. Errors it rejects fail the transition straight away.
+	Retryable func(error) bool
+}
+
+// sleep waits for d or until ctx is done. Tests replace it.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryAction wraps fn so that it is retried under p when it fails:
+//
+//	sm.AddTransition(UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification,
+//		statemachine.WithAction(statemachine.RetryAction(statemachine.RetryPolicy{
+//			MaxAttempts: 5,
+//			Jitter:      0.2,
+//			Retryable:   isTemporary,
+//		}, sendVerificationEmail)))
+//
+// If every attempt fails, or the context is done while waiting, the last
+// error is returned.
+func RetryAction[S State, E Event](p RetryPolicy, fn Action[S, E]) Action[S, E] {
+	attempts := p.MaxAttempts
+	if attempts == 0 {
+		attempts = 3
+	}
+	return func(ctx context.Context, from, to S, event E) error {
+		var err error
+		attempt := 1
+		for ; ; attempt++ {
+			if err = fn(ctx, from, to, event); err == nil {
+				return nil
+			}
+			if attempt >= attempts || p.Retryable != nil && !p.Retryable(err) {
+				break
+			}
+			if sleep(ctx, p.delay(attempt)) != nil {
+				break
+			}
+		}
+		if attempt == 1 {
+			return err
+		}
+		return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+	}
+}
+
+// RetryActions returns middleware retrying every action under p. Add it
+// with UseAction.
+func RetryActions[S State, E Event](p RetryPolicy) ActionMiddleware[S, E] {
+	return func(next Action[S, E]) Action[S, E] {
+		return RetryAction(p, next)
+	}
+}
+
+// delay returns how long to wait after the given failed attempt
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := float64(p.InitialDelay)
+	if d == 0 {
+		d = float64(100 * time.Millisecond)
+	}
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	for range attempt - 1 {
+		d *= multiplier
+		if p.MaxDelay > 0 && d >= float64(p.MaxDelay) {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
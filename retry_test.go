@@ -0,0 +1,144 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTemporary = errors.New("mail server busy")
+
+// recordSleeps replaces sleep for the test, recording each wait
+func recordSleeps(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	orig := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { sleep = orig })
+	return &waits
+}
+
+// flakyAction fails with the given errors in turn, then succeeds
+func flakyAction(calls *int, errs ...error) Action[UserState, UserEvent] {
+	return func(ctx context.Context, from, to UserState, event UserEvent) error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestRetryAction(t *testing.T) {
+	errPermanent := errors.New("no such mailbox")
+
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		errs      []error
+		wantCalls int
+		wantWaits []time.Duration
+		wantErr   string
+	}{
+		{
+			name:      "succeeds after retries",
+			errs:      []error{errTemporary, errTemporary},
+			wantCalls: 3,
+			wantWaits: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:      "gives up",
+			errs:      []error{errTemporary, errTemporary, errTemporary},
+			wantCalls: 3,
+			wantWaits: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+			wantErr:   "giving up after 3 attempts: mail server busy",
+		},
+		{
+			name:      "backoff capped",
+			policy:    RetryPolicy{MaxAttempts: 5, InitialDelay: time.Second, Multiplier: 3, MaxDelay: 5 * time.Second},
+			errs:      []error{errTemporary, errTemporary, errTemporary, errTemporary},
+			wantCalls: 5,
+			wantWaits: []time.Duration{time.Second, 3 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:      "not retryable",
+			policy:    RetryPolicy{Retryable: func(err error) bool { return errors.Is(err, errTemporary) }},
+			errs:      []error{errTemporary, errPermanent},
+			wantCalls: 2,
+			wantWaits: []time.Duration{100 * time.Millisecond},
+			wantErr:   "giving up after 2 attempts: no such mailbox",
+		},
+		{
+			name:      "single attempt",
+			policy:    RetryPolicy{MaxAttempts: 1},
+			errs:      []error{errTemporary},
+			wantCalls: 1,
+			wantErr:   "mail server busy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits := recordSleeps(t)
+			var calls int
+			err := RetryAction(tt.policy, flakyAction(&calls, tt.errs...))(context.Background(), UserStateInitial, UserStateEmailPendingVerification, UserEventSubmitSignUp)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("called %d times, want %d", calls, tt.wantCalls)
+			}
+			if len(*waits) != len(tt.wantWaits) {
+				t.Fatalf("waited %v, want %v", *waits, tt.wantWaits)
+			}
+			for i := range tt.wantWaits {
+				if (*waits)[i] != tt.wantWaits[i] {
+					t.Errorf("waited %v, want %v", *waits, tt.wantWaits)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAction_Jitter(t *testing.T) {
+	p := RetryPolicy{InitialDelay: time.Second, Jitter: 0.5}
+	for range 100 {
+		if d := p.delay(1); d < 500*time.Millisecond || d > time.Second {
+			t.Fatalf("delay() = %v, want between 500ms and 1s", d)
+		}
+	}
+}
+
+func TestRetryAction_ContextDone(t *testing.T) {
+	recordSleeps(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls int
+	err := RetryAction(RetryPolicy{MaxAttempts: 5}, flakyAction(&calls, errTemporary, errTemporary))(ctx, UserStateInitial, UserStateEmailPendingVerification, UserEventSubmitSignUp)
+	if !errors.Is(err, errTemporary) || calls != 1 {
+		t.Errorf("error = %v after %d calls, want the first error after 1", err, calls)
+	}
+}
+
+func TestRetryActions(t *testing.T) {
+	waits := recordSleeps(t)
+	var calls int
+	sm := NewUserStateMachine()
+	sm.AddTransition(UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification,
+		WithAction(flakyAction(&calls, errTemporary)))
+	sm.UseAction(RetryActions[UserState, UserEvent](RetryPolicy{InitialDelay: time.Millisecond}))
+
+	got, err := sm.Transition(UserStateInitial, UserEventSubmitSignUp)
+	if err != nil || got != UserStateEmailPendingVerification {
+		t.Errorf("Transition() = %v, %v, want %v", got, err, UserStateEmailPendingVerification)
+	}
+	if calls != 2 || len(*waits) != 1 {
+		t.Errorf("called %d times with waits %v, want 2 calls and one wait", calls, *waits)
+	}
+}
@@ -0,0 +1,174 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+)
+
+// WithCompensation attaches an action that undoes a transition's actions,
+// such as refunding a payment the transition took. A Saga runs it, with the
+// transition's original states and event, if a later step fails.
+func WithCompensation[S State, E Event](fn Action[S, E]) TransitionOption[S, E] {
+	return func(e *edge[S, E]) {
+		e.compensations = append(e.compensations, fn)
+	}
+}
+
+type compensationsKey struct{}
+
+// noteCompensations tells a Saga firing through ctx which compensations the
+// transition it fired declared, along with those of any transitions its
+// actions queued
+func noteCompensations[S State, E Event](ctx context.Context, e *edge[S, E]) {
+	if c, ok := ctx.Value(compensationsKey{}).(*[]Action[S, E]); ok {
+		*c = append(*c, e.compensations...)
+	}
+}
+
+// Saga drives an Instance through a multi-step process, such as order
+// fulfilment, remembering the compensations of each transition it
+// completes. If a step fails, the saga walks back through the completed
+// steps, most recent first, running their compensations:
+//
+//	sm.AddTransition(OrderStatePending, OrderEventCharge, OrderStateCharged,
+//		statemachine.WithAction(chargeCard),
+//		statemachine.WithCompensation(refundCard))
+//	...
+//	saga := statemachine.NewSaga(sm.NewInstance())
+//	err := saga.Run(ctx, OrderEventCharge, OrderEventReserve, OrderEventShip)
+//
+// Once every step is compensated the instance is back in the state it was
+// in before the first. A Saga, like its Instance, is not safe for concurrent
+// use.
+type Saga[S State, E Event] struct {
+	inst  *Instance[S, E]
+	steps []sagaStep[S, E]
+}
+
+// sagaStep is a completed transition and what undoes it
+type sagaStep[S State, E Event] struct {
+	from, to      S
+	event         E
+	compensations []Action[S, E]
+}
+
+// NewSaga returns a saga driving inst
+func NewSaga[S State, E Event](inst *Instance[S, E]) *Saga[S, E] {
+	return &Saga[S, E]{inst: inst}
+}
+
+// Instance returns the instance the saga drives
+func (s *Saga[S, E]) Instance() *Instance[S, E] {
+	return s.inst
+}
+
+// Steps returns how many completed steps the saga would compensate
+func (s *Saga[S, E]) Steps() int {
+	return len(s.steps)
+}
+
+// Fire transitions the instance via event as the saga's next step. If the
+// transition fails, the completed steps are compensated and a *SagaError
+// is returned.
+func (s *Saga[S, E]) Fire(ctx context.Context, event E) error {
+	from := s.inst.current
+	var compensations []Action[S, E]
+	if err := s.inst.Fire(context.WithValue(ctx, compensationsKey{}, &compensations), event); err != nil {
+		return &SagaError[S, E]{
+			From:            from,
+			Event:           event,
+			Err:             err,
+			CompensationErr: s.Compensate(ctx),
+		}
+	}
+	s.steps = append(s.steps, sagaStep[S, E]{from: from, to: s.inst.current, event: event, compensations: compensations})
+	return nil
+}
+
+// Run fires each event in turn, stopping at the first that fails
+func (s *Saga[S, E]) Run(ctx context.Context, events ...E) error {
+	for _, event := range events {
+		if err := s.Fire(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compensate walks back through the completed steps, most recent first,
+// running each one's compensations and returning the instance to the state
+// the step started from. Call it when something outside the machine fails
+// after the saga's steps succeeded.
+//
+// Each step is taken back as Undo takes back a transition: the exit and
+// enter callbacks run and subscribers are notified, with the step's event
+// and a context for which IsUndo reports true, and the move is recorded in
+// the instance's history, dwell times and timeline.
+//
+// If a compensation fails, Compensate stops there, leaving the instance in
+// the state that step led to. The steps not yet compensated, including the
+// failed one, are kept, so calling Compensate again retries them; a step's
+// compensations that had already run then run again, so they should be safe
+// to repeat.
+func (s *Saga[S, E]) Compensate(ctx context.Context) error {
+	if s.inst.firing {
+		return ErrTransitioning
+	}
+	for len(s.steps) > 0 {
+		step := s.steps[len(s.steps)-1]
+		if err := step.compensate(ctx); err != nil {
+			return err
+		}
+		s.steps = s.steps[:len(s.steps)-1]
+		if n := len(s.inst.undo); n > 0 && s.inst.undo[n-1] == (undoEntry[S, E]{from: step.from, event: step.event}) {
+			// the step is taken back, so Undo must not take it back again
+			s.inst.undo = s.inst.undo[:n-1]
+		}
+		if err := s.inst.rewind(ctx, step.from, step.event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compensate runs the step's compensations, last first, stopping at the
+// first that fails
+func (step sagaStep[S, E]) compensate(ctx context.Context) error {
+	for i := len(step.compensations) - 1; i >= 0; i-- {
+		if err := step.compensations[i](ctx, step.from, step.to, step.event); err != nil {
+			return fmt.Errorf("compensation for event '%s' from state '%s' failed: %w", Name(step.event), Name(step.from), err)
+		}
+	}
+	return nil
+}
+
+// SagaError reports a saga step that failed, and whether compensating the
+// steps before it succeeded
+type SagaError[S State, E Event] struct {
+	From  S
+	Event E
+
+	// Err is why the step failed
+	Err error
+
+	// CompensationErr is why compensating the earlier steps failed, or nil
+	// if they were all compensated
+	CompensationErr error
+}
+
+func (e *SagaError[S, E]) Error() string {
+	msg := fmt.Sprintf("saga step '%s' from state '%s' failed: %v", Name(e.Event), Name(e.From), e.Err)
+	if e.CompensationErr != nil {
+		return msg + "; " + e.CompensationErr.Error()
+	}
+	return msg + "; earlier steps compensated"
+}
+
+// Unwrap returns Err and CompensationErr, so errors.Is and errors.As match
+// either
+func (e *SagaError[S, E]) Unwrap() []error {
+	if e.CompensationErr == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.CompensationErr}
+}
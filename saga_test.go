@@ -0,0 +1,232 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fulfilState string
+
+func (s fulfilState) String() string { return string(s) }
+
+type fulfilEvent string
+
+func (e fulfilEvent) String() string { return string(e) }
+
+var (
+	errOutOfStock  = errors.New("out of stock")
+	errRefundLimit = errors.New("refund limit reached")
+)
+
+// newFulfilmentMachine charges, reserves and ships an order, logging every
+// action and compensation. failing names the step whose action fails, and
+// failingComp the step whose compensation does.
+func newFulfilmentMachine(log *[]string, failing, failingComp fulfilEvent) *StateMachine[fulfilState, fulfilEvent] {
+	step := func(name string, event fulfilEvent, fail fulfilEvent, err error) Action[fulfilState, fulfilEvent] {
+		return func(ctx context.Context, from, to fulfilState, e fulfilEvent) error {
+			if event == fail {
+				return err
+			}
+			*log = append(*log, name)
+			return nil
+		}
+	}
+
+	sm := NewStateMachine[fulfilState, fulfilEvent]()
+	sm.SetInitialState("Pending")
+	sm.AddTransition("Pending", "Charge", "Charged",
+		WithAction(step("charge", "Charge", failing, errors.New("card declined"))),
+		WithCompensation(step("refund", "Charge", failingComp, errRefundLimit)))
+	sm.AddTransition("Charged", "Reserve", "Reserved",
+		WithAction(step("reserve", "Reserve", failing, errOutOfStock)),
+		WithCompensation(step("release", "Reserve", failingComp, errors.New("warehouse offline"))),
+		WithCompensation(step("notify", "Reserve", "", nil)))
+	sm.AddTransition("Reserved", "Label", "Labelled")
+	sm.AddTransition("Labelled", "Ship", "Shipped",
+		WithAction(step("ship", "Ship", failing, errors.New("courier unavailable"))))
+	return sm
+}
+
+func TestSaga_Run(t *testing.T) {
+	tests := []struct {
+		name        string
+		failing     fulfilEvent
+		failingComp fulfilEvent
+		want        fulfilState
+		wantLog     string
+		wantErr     error
+		wantSteps   int
+	}{
+		{
+			name:    "completes",
+			want:    "Shipped",
+			wantLog: "charge reserve ship",
+		},
+		{
+			name:    "fails first step",
+			failing: "Charge",
+			want:    "Pending",
+		},
+		{
+			name:    "compensates",
+			failing: "Ship",
+			want:    "Pending",
+			wantLog: "charge reserve notify release refund",
+		},
+		{
+			name:    "fails second step",
+			failing: "Reserve",
+			want:    "Pending",
+			wantLog: "charge refund",
+			wantErr: errOutOfStock,
+		},
+		{
+			name:        "compensation fails",
+			failing:     "Ship",
+			failingComp: "Charge",
+			want:        "Charged",
+			wantLog:     "charge reserve notify release",
+			wantErr:     errRefundLimit,
+			wantSteps:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log []string
+			sm := newFulfilmentMachine(&log, tt.failing, tt.failingComp)
+			saga := NewSaga(sm.NewInstance())
+
+			err := saga.Run(context.Background(), "Charge", "Reserve", "Label", "Ship")
+			if tt.failing == "" && err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if tt.failing != "" {
+				var sagaErr *SagaError[fulfilState, fulfilEvent]
+				if !errors.As(err, &sagaErr) || sagaErr.Event != tt.failing {
+					t.Fatalf("Run() error = %v, want a SagaError for %s", err, tt.failing)
+				}
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Run() error = %v, want it to match %v", err, tt.wantErr)
+			}
+			if got := saga.Instance().Current(); got != tt.want {
+				t.Errorf("Current() = %v, want %v", got, tt.want)
+			}
+			if got := strings.Join(log, " "); got != tt.wantLog {
+				t.Errorf("ran %q, want %q", got, tt.wantLog)
+			}
+			if tt.failing != "" && saga.Steps() != tt.wantSteps {
+				t.Errorf("Steps() = %d, want %d", saga.Steps(), tt.wantSteps)
+			}
+		})
+	}
+}
+
+func TestSaga_CompensateRetry(t *testing.T) {
+	var log []string
+	failingComp := fulfilEvent("Reserve")
+	sm := NewStateMachine[fulfilState, fulfilEvent]()
+	sm.AddTransition("Pending", "Charge", "Charged",
+		WithCompensation(func(ctx context.Context, from, to fulfilState, e fulfilEvent) error {
+			log = append(log, "refund")
+			return nil
+		}))
+	sm.AddTransition("Charged", "Reserve", "Reserved",
+		WithCompensation(func(ctx context.Context, from, to fulfilState, e fulfilEvent) error {
+			if failingComp == e {
+				return errors.New("warehouse offline")
+			}
+			log = append(log, "release "+from.String()+" -> "+to.String())
+			return nil
+		}))
+	saga := NewSaga(sm.NewInstanceAt("Pending"))
+	ctx := context.Background()
+
+	if err := saga.Run(ctx, "Charge", "Reserve"); err != nil {
+		t.Fatal(err)
+	}
+	// something outside the machine fails after the saga's steps succeeded
+	err := saga.Compensate(ctx)
+	if err == nil || err.Error() != "compensation for event 'Reserve' from state 'Charged' failed: warehouse offline" {
+		t.Fatalf("Compensate() error = %v", err)
+	}
+	if !saga.Instance().Is("Reserved") || saga.Steps() != 2 {
+		t.Errorf("after failed Compensate(), at %v with %d steps, want Reserved with 2", saga.Instance().Current(), saga.Steps())
+	}
+
+	failingComp = ""
+	if err := saga.Compensate(ctx); err != nil {
+		t.Fatalf("retried Compensate() error = %v", err)
+	}
+	if !saga.Instance().Is("Pending") || saga.Steps() != 0 {
+		t.Errorf("after Compensate(), at %v with %d steps, want Pending with none", saga.Instance().Current(), saga.Steps())
+	}
+	if got := strings.Join(log, ", "); got != "release Charged -> Reserved, refund" {
+		t.Errorf("ran %q", got)
+	}
+	if saga.Instance().Version() != 4 {
+		t.Errorf("Version() = %d, want 4 after two steps and two compensations", saga.Instance().Version())
+	}
+}
+
+func TestSagaError(t *testing.T) {
+	err := &SagaError[fulfilState, fulfilEvent]{From: "Labelled", Event: "Ship", Err: errOutOfStock}
+	if want := "saga step 'Ship' from state 'Labelled' failed: out of stock; earlier steps compensated"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	err.CompensationErr = errRefundLimit
+	if want := "saga step 'Ship' from state 'Labelled' failed: out of stock; refund limit reached"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if !errors.Is(err, errOutOfStock) || !errors.Is(err, errRefundLimit) {
+		t.Error("SagaError does not match both of its errors")
+	}
+}
+
+func TestSaga_CompensateKeepsBookkeeping(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := NewStateMachine[fulfilState, fulfilEvent](WithTimeline(8), WithClock(clock))
+	sm.SetInitialState("Pending")
+	sm.AddTransition("Pending", "Charge", "Charged",
+		WithCompensation(func(ctx context.Context, from, to fulfilState, e fulfilEvent) error { return nil }))
+	sm.AddTransition("Charged", "Reserve", "Reserved",
+		WithAction(func(ctx context.Context, from, to fulfilState, e fulfilEvent) error { return errOutOfStock }))
+	var log []string
+	sm.OnExit("Charged", func(ctx context.Context, from, to fulfilState, e fulfilEvent) {
+		log = append(log, "exit Charged")
+	})
+	sm.OnEnter("Pending", func(ctx context.Context, from, to fulfilState, e fulfilEvent) {
+		log = append(log, "enter Pending")
+	})
+	sm.Subscribe(func(ctx context.Context, from, to fulfilState, e fulfilEvent, at time.Time) {
+		log = append(log, fmt.Sprintf("notified %s -> %s undo=%v", from, to, IsUndo(ctx)))
+	})
+
+	saga := NewSaga(sm.NewInstance())
+	if err := saga.Fire(context.Background(), "Charge"); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(time.Minute)
+	if err := saga.Fire(context.Background(), "Reserve"); !errors.Is(err, errOutOfStock) {
+		t.Fatalf("Fire(Reserve) error = %v, want %v", err, errOutOfStock)
+	}
+
+	want := "notified Pending -> Charged undo=false\nexit Charged\nenter Pending\nnotified Charged -> Pending undo=true"
+	if got := strings.Join(log, "\n"); got != want {
+		t.Errorf("ran:\n%s\nwant:\n%s", got, want)
+	}
+	snap := saga.Instance().Snapshot()
+	last := snap.Timeline[len(snap.Timeline)-1]
+	if snap.State != "Pending" || last.State != "Pending" || !last.Undo || last.Event != "Charge" || last.Version != snap.Version {
+		t.Errorf("snapshot in %v at v%d with last frame %+v, want the compensation recorded", snap.State, snap.Version, last)
+	}
+	if !slices.Contains(snap.Dwell, DwellEntry[fulfilState]{State: "Charged", Duration: time.Minute}) {
+		t.Errorf("dwell = %v, want a minute in Charged", snap.Dwell)
+	}
+}
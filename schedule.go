@@ -0,0 +1,72 @@
+package statemachine
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// ScheduledEvent is an event an instance will fire later, scheduled with
+// FireAt or FireAfter
+type ScheduledEvent[E Event] struct {
+	// ID identifies the event to Cancel
+	ID    int64
+	At    time.Time
+	Event E
+}
+
+// FireAt schedules event to be fired at t, for example to send a reminder,
+// returning an ID that cancels it. Scheduled events stay pending whatever
+// state the instance moves to, and fail like any other event if they are
+// not valid when they fall due. Timers fires them on time, or FireDue does
+// when called; snapshots keep them.
+func (i *Instance[S, E]) FireAt(t time.Time, event E) int64 {
+	i.nextID++
+	s := ScheduledEvent[E]{ID: i.nextID, At: t, Event: event}
+	n, _ := slices.BinarySearchFunc(i.scheduled, s, compareScheduled)
+	i.scheduled = slices.Insert(i.scheduled, n, s)
+	return s.ID
+}
+
+// FireAfter schedules event to be fired once d has passed, returning an ID
+// that cancels it
+func (i *Instance[S, E]) FireAfter(d time.Duration, event E) int64 {
+	return i.FireAt(i.sm.clock.Now().Add(d), event)
+}
+
+// Cancel removes a scheduled event, reporting whether it was still pending
+func (i *Instance[S, E]) Cancel(id int64) bool {
+	n := slices.IndexFunc(i.scheduled, func(s ScheduledEvent[E]) bool { return s.ID == id })
+	if n < 0 {
+		return false
+	}
+	i.scheduled = slices.Delete(i.scheduled, n, n+1)
+	return true
+}
+
+// Scheduled returns the instance's pending scheduled events, soonest first
+func (i *Instance[S, E]) Scheduled() []ScheduledEvent[E] {
+	return slices.Clone(i.scheduled)
+}
+
+// nextDue returns when the instance's next timeout or scheduled event falls
+// due
+func (i *Instance[S, E]) nextDue() (time.Time, bool) {
+	switch {
+	case len(i.scheduled) == 0:
+		return i.deadline, !i.deadline.IsZero()
+	case i.deadline.IsZero() || !i.scheduled[0].At.After(i.deadline):
+		return i.scheduled[0].At, true
+	default:
+		return i.deadline, true
+	}
+}
+
+// compareScheduled orders scheduled events by time, then by when they were
+// scheduled
+func compareScheduled[E Event](a, b ScheduledEvent[E]) int {
+	if c := a.At.Compare(b.At); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
@@ -0,0 +1,176 @@
+package statemachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newReminderMachine reminds a user to verify their email after 24 hours,
+// and rejects the signup after 48
+func newReminderMachine(clock Clock, reminders *int) *StateMachine[UserState, UserEvent] {
+	sm := NewStateMachine[UserState, UserEvent](WithClock(clock))
+	sm.AddTransition(UserStateEmailPendingVerification, UserEventClickVerificationLink, UserStateEmailVerified)
+	sm.AddTransition(UserStateEmailPendingVerification, UserEventSignupFailed, UserStateRejected)
+	sm.AddTransition(UserStateEmailPendingVerification, "Remind", UserStateEmailPendingVerification,
+		WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+			*reminders++
+			return nil
+		}))
+	return sm
+}
+
+func TestInstance_FireAfter(t *testing.T) {
+	tests := []struct {
+		name          string
+		wait          time.Duration
+		cancel        bool
+		want          UserState
+		wantReminders int
+		wantPending   int
+	}{
+		{
+			name:        "not yet due",
+			wait:        12 * time.Hour,
+			want:        UserStateEmailPendingVerification,
+			wantPending: 2,
+		},
+		{
+			name:          "reminded",
+			wait:          24 * time.Hour,
+			want:          UserStateEmailPendingVerification,
+			wantReminders: 1,
+			wantPending:   1,
+		},
+		{
+			name:          "reminded then expired",
+			wait:          72 * time.Hour,
+			want:          UserStateRejected,
+			wantReminders: 1,
+		},
+		{
+			name:        "cancelled reminder",
+			wait:        36 * time.Hour,
+			cancel:      true,
+			want:        UserStateEmailPendingVerification,
+			wantPending: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: epoch}
+			var reminders int
+			inst := newReminderMachine(clock, &reminders).NewInstanceAt(UserStateEmailPendingVerification)
+			inst.FireAt(epoch.Add(48*time.Hour), UserEventSignupFailed)
+			reminder := inst.FireAfter(24*time.Hour, "Remind")
+			if tt.cancel && !inst.Cancel(reminder) {
+				t.Fatal("Cancel() = false for a pending event")
+			}
+
+			clock.advance(tt.wait)
+			if _, err := inst.FireDue(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if inst.Current() != tt.want {
+				t.Errorf("Current() = %v, want %v", inst.Current(), tt.want)
+			}
+			if reminders != tt.wantReminders {
+				t.Errorf("sent %d reminders, want %d", reminders, tt.wantReminders)
+			}
+			if got := len(inst.Scheduled()); got != tt.wantPending {
+				t.Errorf("%d events pending, want %d", got, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestInstance_FireDueFailure(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	var reminders int
+	inst := newReminderMachine(clock, &reminders).NewInstanceAt(UserStateEmailPendingVerification)
+	inst.FireAfter(time.Hour, UserEventClickVerificationLink)
+	inst.FireAfter(2*time.Hour, "Remind") // no longer valid once verified
+	inst.FireAfter(3*time.Hour, UserEventSignupFailed)
+
+	clock.advance(3 * time.Hour)
+	fired, err := inst.FireDue(context.Background())
+	if !fired || !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("FireDue() = %v, %v, want the reminder to fail after verifying", fired, err)
+	}
+	if !inst.Is(UserStateEmailVerified) || len(inst.Scheduled()) != 1 {
+		t.Errorf("at %v with %v pending, want %v with one", inst.Current(), inst.Scheduled(), UserStateEmailVerified)
+	}
+}
+
+func TestInstance_ScheduledSnapshot(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	var reminders int
+	sm := newReminderMachine(clock, &reminders)
+	inst := sm.NewInstanceAt(UserStateEmailPendingVerification)
+	inst.FireAfter(48*time.Hour, UserEventSignupFailed)
+	inst.FireAfter(24*time.Hour, "Remind")
+
+	data, err := json.Marshal(inst.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap Snapshot[UserState]
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := sm.NewInstanceAt(UserStateEmailPendingVerification)
+	if err := restored.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	want := []ScheduledEvent[UserEvent]{
+		{ID: 2, At: epoch.Add(24 * time.Hour), Event: "Remind"},
+		{ID: 1, At: epoch.Add(48 * time.Hour), Event: UserEventSignupFailed},
+	}
+	got := restored.Scheduled()
+	if len(got) != len(want) {
+		t.Fatalf("Scheduled() = %v, want %v", got, want)
+	}
+	for n := range want {
+		if got[n].ID != want[n].ID || !got[n].At.Equal(want[n].At) || got[n].Event != want[n].Event {
+			t.Errorf("Scheduled()[%d] = %v, want %v", n, got[n], want[n])
+		}
+	}
+	if id := restored.FireAfter(time.Hour, "Remind"); id != 3 {
+		t.Errorf("FireAfter() after Restore() = %d, want a fresh ID of 3", id)
+	}
+
+	snap.Scheduled = append(snap.Scheduled, ScheduledEntry{ID: 9, At: epoch, Event: "Unsubscribe"})
+	if err := restored.Restore(snap); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Restore() with an unknown event error = %v, want %v", err, ErrUnknownEvent)
+	}
+}
+
+func TestTimers_Scheduled(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	var reminders int
+	sm := newReminderMachine(clock, &reminders)
+	sm.SetTimeout(UserStateEmailPendingVerification, 48*time.Hour, UserEventSignupFailed)
+	timers := NewTimers(sm)
+	defer timers.Stop()
+
+	inst := sm.NewInstanceAt(UserStateEmailPendingVerification)
+	timers.Watch(inst)
+	timers.Do(inst, func(inst *Instance[UserState, UserEvent]) {
+		inst.FireAfter(24*time.Hour, "Remind")
+	})
+
+	// the reminder re-enters the state, restarting its timeout
+	clock.advance(48 * time.Hour)
+	if reminders != 1 || !inst.Is(UserStateEmailPendingVerification) {
+		t.Fatalf("after 48h, %d reminders and at %v", reminders, inst.Current())
+	}
+	clock.advance(24 * time.Hour)
+	if !inst.Is(UserStateRejected) {
+		t.Errorf("Current() = %v, want %v", inst.Current(), UserStateRejected)
+	}
+}
@@ -0,0 +1,33 @@
+package statemachine
+
+import (
+	"bytes"
+	_ "embed"
+)
+
+// DefinitionSchemaVersion is the version of the definition file format
+// understood by this package. It changes whenever the format changes in a way
+// older loaders cannot read.
+const DefinitionSchemaVersion = 1
+
+// DefinitionSchemaID is the $id of the JSON Schema for the current definition
+// format. Definition files may reference it from a "$schema" key so editors
+// can offer completion and validation.
+const DefinitionSchemaID = "https://github.com/richardbowden/statemachine/schema/definition.v1.json"
+
+//go:embed schema/definition.v1.json
+var definitionSchema []byte
+
+// DefinitionSchema returns the JSON Schema describing the definition file format
+func DefinitionSchema() []byte {
+	return bytes.Clone(definitionSchema)
+}
+
+// ValidateDefinitionBytes checks that data is a well formed, internally
+// consistent definition document without building a state machine. It
+// applies the same rules as the loader, so a document that passes here will
+// load as long as its names match the Go states and events.
+func ValidateDefinitionBytes(data []byte) error {
+	_, err := ParseDefinition("", data)
+	return err
+}
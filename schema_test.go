@@ -0,0 +1,113 @@
+package statemachine
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDefinitionSchema_MatchesDefinitionTypes(t *testing.T) {
+	var schema struct {
+		ID         string                     `json:"$id"`
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(DefinitionSchema(), &schema); err != nil {
+		t.Fatalf("DefinitionSchema() is not valid JSON: %v", err)
+	}
+
+	if schema.ID != DefinitionSchemaID {
+		t.Errorf("schema $id = %q, want %q", schema.ID, DefinitionSchemaID)
+	}
+
+	tests := []struct {
+		name  string
+		props map[string]json.RawMessage
+		typ   any
+	}{
+		{"definition", schema.Properties, Definition{}},
+		{"state", schema.Defs["state"].Properties, StateDefinition{}},
+		{"event", schema.Defs["event"].Properties, EventDefinition{}},
+		{"transition", schema.Defs["transition"].Properties, TransitionDefinition{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0, len(tt.props))
+			for k := range tt.props {
+				got = append(got, k)
+			}
+			sort.Strings(got)
+
+			want := jsonFieldNames(reflect.TypeOf(tt.typ))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("schema properties = %v, Go fields = %v", got, want)
+			}
+		})
+	}
+}
+
+func jsonFieldNames(typ reflect.Type) []string {
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		tag := typ.Field(i).Tag.Get("json")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		names = append(names, strings.Split(tag, ",")[0])
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestValidateDefinitionBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "valid with schema reference",
+			data: `{
+  "$schema": "` + DefinitionSchemaID + `",
+  "states": [{"name": "Draft"}, {"name": "Submitted"}],
+  "events": [{"name": "Submit"}],
+  "transitions": [{"from": "Draft", "event": "Submit", "to": "Submitted"}]
+}`,
+		},
+		{
+			name:    "not json",
+			data:    "states:\n  - Draft",
+			wantErr: "line 1: invalid character",
+		},
+		{
+			name:    "unnamed state",
+			data:    "{\n  \"states\": [\n    {\"description\": \"oops\"}\n  ]\n}",
+			wantErr: "line 3: state has no name",
+		},
+		{
+			name:    "undeclared event",
+			data:    "{\n  \"states\": [{\"name\": \"Draft\"}],\n  \"transitions\": [\n    {\"from\": \"Draft\", \"event\": \"Submit\", \"to\": \"Draft\"}\n  ]\n}",
+			wantErr: `line 4: transition on undeclared event "Submit"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDefinitionBytes([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateDefinitionBytes() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateDefinitionBytes() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
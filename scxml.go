@@ -0,0 +1,300 @@
+package statemachine
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// scxmlNamespace is the namespace of SCXML documents
+const scxmlNamespace = "http://www.w3.org/2005/07/scxml"
+
+// scxmlNode is any element of an SCXML document this package understands:
+// the scxml root, state, final, parallel, initial and transition elements.
+// Attributes that do not apply to an element are left empty.
+type scxmlNode struct {
+	XMLName xml.Name
+	Xmlns   string `xml:"xmlns,attr,omitempty"`
+	Version string `xml:"version,attr,omitempty"`
+	ID      string `xml:"id,attr,omitempty"`
+	Initial string `xml:"initial,attr,omitempty"`
+	Event   string `xml:"event,attr,omitempty"`
+	Cond    string `xml:"cond,attr,omitempty"`
+	Target  string `xml:"target,attr,omitempty"`
+
+	Children []*scxmlNode `xml:",any"`
+}
+
+// ExportSCXML writes the machine to w as a W3C SCXML document, for use with
+// statechart tooling. Substates are nested inside their parent state, which
+// names its initial substate if it has one, and final states without
+// outgoing transitions are written as final elements.
+//
+// Transitions competing for a state and event are written in the order they
+// are tried. A guard resolved by name, as LoadSCXML and
+// FromDefinitionWithGuards resolve them, is written as a cond naming it,
+// several guards joined with " && ", so the document loads back with the
+// same guards map.
+//
+// SCXML cannot express everything a machine can: a final state with
+// outgoing transitions is written as an ordinary state, a guard added with
+// WithGuard is written as a cond of "guarded" since it has no name, a choice
+// leads to its first target and history transitions target the composite
+// state itself.
+func (sm *StateMachine[S, E]) ExportSCXML(w io.Writer) error {
+	sm.rlock()
+	d := sm.diagram()
+	sm.runlock()
+
+	byState := make(map[S][]diagramEdge[S, E])
+	for _, e := range d.edges {
+		if e.choice && len(byState[e.from]) > 0 {
+			last := byState[e.from][len(byState[e.from])-1]
+			if last.choice && last.event == e.event {
+				// only the first target of a choice is written
+				continue
+			}
+		}
+		byState[e.from] = append(byState[e.from], e)
+	}
+
+	var node func(s S) *scxmlNode
+	node = func(s S) *scxmlNode {
+		n := &scxmlNode{ID: Name(s)}
+		n.XMLName.Local = "state"
+		if d.final[s] && len(byState[s]) == 0 && len(d.children[s]) == 0 {
+			n.XMLName.Local = "final"
+		}
+		if child, ok := d.initialSubstate[s]; ok {
+			n.Initial = Name(child)
+		}
+		for _, child := range d.children[s] {
+			n.Children = append(n.Children, node(child))
+		}
+		for _, e := range byState[s] {
+			t := &scxmlNode{Event: Name(e.event), Target: Name(e.to)}
+			t.XMLName.Local = "transition"
+			if e.guarded {
+				t.Cond = scxmlCond(e.src)
+			}
+			n.Children = append(n.Children, t)
+		}
+		return n
+	}
+
+	root := &scxmlNode{Xmlns: scxmlNamespace, Version: "1.0"}
+	root.XMLName.Local = "scxml"
+	if d.hasInitial {
+		root.Initial = Name(d.initial)
+	}
+	for _, s := range d.roots {
+		root.Children = append(root.Children, node(s))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// scxmlCond returns the cond written for a guarded transition: the names of
+// its guards, or "guarded" if any has no name
+func scxmlCond[S State, E Event](e *edge[S, E]) string {
+	if len(e.guardNames) != len(e.guards) {
+		return "guarded"
+	}
+	return strings.Join(e.guardNames, " && ")
+}
+
+// LoadSCXML builds a state machine from a W3C SCXML document read from r,
+// resolving state ids and event names against the given values by their
+// names, as Name gives them. A transition's cond names a guard to look up in
+// guards, or several joined with "&&" that must all pass; guards may be nil
+// if no transition has one. A cond of "guarded", written by ExportSCXML for
+// a guard without a name, must be given in guards like any other.
+//
+// As SCXML specifies, the transitions of a state for an event are tried in
+// document order, and any listed after one without a cond can never be taken
+// and are left out.
+//
+// Nested states become substates, initial attributes and initial elements
+// set the initial state and initial substates, and final elements become
+// final states. Where the document names no initial state, its first state
+// is used, as SCXML specifies. Parallel states, eventless and targetless
+// transitions, and transitions with several targets have no equivalent and
+// are reported as errors. Executable content, such as onentry and onexit
+// elements, is ignored.
+func LoadSCXML[S State, E Event](r io.Reader, states []S, events []E, guards map[string]Guard[S, E]) (*StateMachine[S, E], error) {
+	var root scxmlNode
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid SCXML document: %w", err)
+	}
+	if root.XMLName.Local != "scxml" {
+		return nil, fmt.Errorf("invalid SCXML document: root element is <%s>, not <scxml>", root.XMLName.Local)
+	}
+
+	l := &scxmlLoader[S, E]{
+		sm:          NewStateMachine[S, E](),
+		stateByName: make(map[string]S, len(states)),
+		eventByName: make(map[string]E, len(events)),
+		guards:      guards,
+		tried:       make(map[transitionKey[S, E]]int),
+		unguarded:   make(map[transitionKey[S, E]]bool),
+	}
+	for _, s := range states {
+		l.stateByName[Name(s)] = s
+	}
+	for _, e := range events {
+		l.eventByName[Name(e)] = e
+	}
+
+	var zero S
+	initial, err := l.load(&root, zero, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load SCXML document: %w", err)
+	}
+	if initial != "" {
+		s, err := l.state(initial)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load SCXML document: %w", err)
+		}
+		l.sm.SetInitialState(s)
+	}
+	return l.sm, nil
+}
+
+// scxmlLoader builds a machine from the elements of an SCXML document
+type scxmlLoader[S State, E Event] struct {
+	sm          *StateMachine[S, E]
+	stateByName map[string]S
+	eventByName map[string]E
+	guards      map[string]Guard[S, E]
+
+	// tried counts the transitions loaded for each state and event, which
+	// take decreasing priorities so they are tried in document order, and
+	// unguarded holds those with a transition without a cond
+	tried     map[transitionKey[S, E]]int
+	unguarded map[transitionKey[S, E]]bool
+}
+
+// load adds the states and transitions nested in n, whose own state is
+// parent unless n is the root, and returns the id of n's initial child
+func (l *scxmlLoader[S, E]) load(n *scxmlNode, parent S, hasParent bool) (string, error) {
+	initial := n.Initial
+	hasStates := false
+	for _, child := range n.Children {
+		switch child.XMLName.Local {
+		case "state", "final":
+			s, err := l.state(child.ID)
+			if err != nil {
+				return "", err
+			}
+			l.sm.DeclareStates(s)
+			if hasParent {
+				if err := l.sm.SetParent(s, parent); err != nil {
+					return "", err
+				}
+			}
+			if child.XMLName.Local == "final" {
+				l.sm.AddFinalState(s)
+			}
+			hasStates = true
+			if initial == "" {
+				initial = child.ID
+			}
+			childInitial, err := l.load(child, s, true)
+			if err != nil {
+				return "", err
+			}
+			if childInitial != "" {
+				sub, err := l.state(childInitial)
+				if err != nil {
+					return "", err
+				}
+				if err := l.sm.SetInitialSubstate(s, sub); err != nil {
+					return "", err
+				}
+			}
+		case "initial":
+			for _, t := range child.Children {
+				if t.XMLName.Local == "transition" {
+					initial = t.Target
+				}
+			}
+		case "transition":
+			if !hasParent {
+				return "", errors.New("transition outside of a state")
+			}
+			if err := l.transition(parent, child); err != nil {
+				return "", err
+			}
+		case "parallel":
+			return "", fmt.Errorf("parallel state %q is not supported", child.ID)
+		}
+	}
+	if !hasStates {
+		return "", nil
+	}
+	return initial, nil
+}
+
+// transition adds the transitions described by a transition element of from
+func (l *scxmlLoader[S, E]) transition(from S, t *scxmlNode) error {
+	if t.Event == "" {
+		return fmt.Errorf("eventless transition from %q is not supported", Name(from))
+	}
+	targets := strings.Fields(t.Target)
+	switch len(targets) {
+	case 0:
+		return fmt.Errorf("targetless transition from %q on %q is not supported", Name(from), t.Event)
+	case 1:
+	default:
+		return fmt.Errorf("transition from %q on %q has several targets, which is not supported", Name(from), t.Event)
+	}
+	to, err := l.state(targets[0])
+	if err != nil {
+		return err
+	}
+
+	var opts []TransitionOption[S, E]
+	if t.Cond != "" {
+		for name := range strings.SplitSeq(t.Cond, "&&") {
+			name = strings.TrimSpace(name)
+			guard, ok := l.guards[name]
+			if !ok {
+				return fmt.Errorf("unknown guard %q", name)
+			}
+			opts = append(opts, withNamedGuard(name, guard))
+		}
+	}
+	for _, name := range strings.Fields(t.Event) {
+		event, ok := l.eventByName[name]
+		if !ok {
+			return fmt.Errorf("unknown event %q", name)
+		}
+		k := transitionKey[S, E]{from, event}
+		if l.unguarded[k] {
+			continue
+		}
+		l.unguarded[k] = t.Cond == ""
+		l.sm.AddTransition(from, event, to, append(opts, WithPriority[S, E](-l.tried[k]))...)
+		l.tried[k]++
+	}
+	return nil
+}
+
+func (l *scxmlLoader[S, E]) state(id string) (S, error) {
+	s, ok := l.stateByName[id]
+	if !ok {
+		return s, fmt.Errorf("unknown state %q", id)
+	}
+	return s, nil
+}
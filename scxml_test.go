@@ -0,0 +1,221 @@
+package statemachine
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExportSCXML(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateProcessing)
+	sm.AddTransition(OrderStatePacking, OrderEventPack, OrderStateAwaiting)
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped)
+	sm.AddTransition(OrderStateProcessing, OrderEventCancel, OrderStateCancelled,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true }))
+	sm.SetParent(OrderStatePacking, OrderStateProcessing)
+	sm.SetParent(OrderStateAwaiting, OrderStateProcessing)
+	sm.SetInitialSubstate(OrderStateProcessing, OrderStatePacking)
+	sm.SetInitialState(OrderStatePending)
+	sm.AddFinalState(OrderStateShipped)
+
+	var b strings.Builder
+	if err := sm.ExportSCXML(&b); err != nil {
+		t.Fatalf("ExportSCXML() error = %v", err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" initial="Pending">
+  <state id="Cancelled"></state>
+  <state id="Pending">
+    <transition event="Confirm" target="Processing"></transition>
+  </state>
+  <state id="Processing" initial="Packing">
+    <state id="AwaitingCourier">
+      <transition event="Ship" target="Shipped"></transition>
+    </state>
+    <state id="Packing">
+      <transition event="Pack" target="AwaitingCourier"></transition>
+    </state>
+    <transition event="Cancel" cond="guarded" target="Cancelled"></transition>
+  </state>
+  <final id="Shipped"></final>
+</scxml>
+`
+	if got := b.String(); got != want {
+		t.Errorf("ExportSCXML() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+var allOrderStates = []OrderState{
+	OrderStatePending, OrderStateProcessing, OrderStatePacking, OrderStateAwaiting,
+	OrderStateShipped, OrderStateDelivered, OrderStateCancelled, OrderStateRefunded,
+}
+
+var allOrderEvents = []OrderEvent{
+	OrderEventConfirm, OrderEventPack, OrderEventShip, OrderEventDeliver, OrderEventCancel, OrderEventRefund,
+}
+
+func TestLoadSCXML_RoundTrip(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.SetInitialState(OrderStatePending)
+	sm.AddFinalState(OrderStateRefunded)
+
+	var b strings.Builder
+	if err := sm.ExportSCXML(&b); err != nil {
+		t.Fatalf("ExportSCXML() error = %v", err)
+	}
+	loaded, err := LoadSCXML(strings.NewReader(b.String()), allOrderStates, allOrderEvents, nil)
+	if err != nil {
+		t.Fatalf("LoadSCXML() error = %v", err)
+	}
+
+	if d := Diff(sm, loaded); !d.IsEmpty() {
+		t.Errorf("loaded machine differs:\n%s", d)
+	}
+	if err := Equivalent(sm, loaded); err != nil {
+		t.Errorf("loaded machine behaves differently: %v", err)
+	}
+	if parent, _ := loaded.Parent(OrderStatePacking); parent != OrderStateProcessing {
+		t.Errorf("Parent(Packing) = %v, want Processing", parent)
+	}
+}
+
+func TestLoadSCXML_GuardsRoundTrip(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0" initial="Pending">
+  <state id="Cancelled"></state>
+  <state id="Pending">
+    <transition event="Cancel" target="Cancelled"></transition>
+    <transition event="Confirm" cond="paid &amp;&amp; stocked" target="Packing"></transition>
+    <transition event="Confirm" target="Shipped"></transition>
+  </state>
+  <state id="Processing" initial="Packing">
+    <state id="Packing"></state>
+  </state>
+  <final id="Refunded"></final>
+  <state id="Shipped">
+    <transition event="Refund" target="Refunded"></transition>
+    <transition event="Refund" cond="paid" target="Cancelled"></transition>
+  </state>
+</scxml>
+`
+	paid, stocked := true, true
+	guards := map[string]Guard[OrderState, OrderEvent]{
+		"paid":    func(ctx context.Context, from OrderState, event OrderEvent) bool { return paid },
+		"stocked": func(ctx context.Context, from OrderState, event OrderEvent) bool { return stocked },
+	}
+
+	sm, err := LoadSCXML(strings.NewReader(doc), allOrderStates, allOrderEvents, guards)
+	if err != nil {
+		t.Fatalf("LoadSCXML() error = %v", err)
+	}
+	var b strings.Builder
+	if err := sm.ExportSCXML(&b); err != nil {
+		t.Fatalf("ExportSCXML() error = %v", err)
+	}
+	want := strings.Replace(doc, `
+    <transition event="Refund" cond="paid" target="Cancelled"></transition>`, "", 1)
+	if got := b.String(); got != want {
+		t.Errorf("ExportSCXML() of the loaded document =\n%s\nwant\n%s", got, want)
+	}
+
+	tests := []struct {
+		name          string
+		paid, stocked bool
+		from          OrderState
+		event         OrderEvent
+		want          OrderState
+	}{
+		{"first in document order", true, true, OrderStatePending, OrderEventConfirm, OrderStatePacking},
+		{"every guard must pass", true, false, OrderStatePending, OrderEventConfirm, OrderStateShipped},
+		{"unguarded after guarded", false, true, OrderStatePending, OrderEventConfirm, OrderStateShipped},
+		{"unguarded before guarded", true, true, OrderStateShipped, OrderEventRefund, OrderStateRefunded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paid, stocked = tt.paid, tt.stocked
+			if got, err := sm.Transition(tt.from, tt.event); err != nil || got != tt.want {
+				t.Errorf("Transition(%v, %v) = %v, %v, want %v", tt.from, tt.event, got, err, tt.want)
+			}
+		})
+	}
+
+	unnamed := NewStateMachine[OrderState, OrderEvent]()
+	unnamed.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateProcessing,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true }))
+	b.Reset()
+	if err := unnamed.ExportSCXML(&b); err != nil {
+		t.Fatalf("ExportSCXML() error = %v", err)
+	}
+	if _, err := LoadSCXML(strings.NewReader(b.String()), allOrderStates, allOrderEvents, guards); err == nil || !strings.Contains(err.Error(), `unknown guard "guarded"`) {
+		t.Errorf("LoadSCXML() of an unnamed guard error = %v, want it reported", err)
+	}
+}
+
+func TestLoadSCXML(t *testing.T) {
+	doc := `<scxml xmlns="http://www.w3.org/2005/07/scxml" version="1.0">
+  <state id="Pending">
+    <onentry><log expr="'pending'"/></onentry>
+    <transition event="Confirm" target="Processing" cond="paid"/>
+    <transition event="Cancel Confirm" target="Cancelled"/>
+  </state>
+  <state id="Processing">
+    <initial><transition target="AwaitingCourier"/></initial>
+    <state id="Packing"/>
+    <state id="AwaitingCourier"/>
+  </state>
+  <final id="Cancelled"/>
+</scxml>`
+	paid := false
+	guards := map[string]Guard[OrderState, OrderEvent]{
+		"paid": func(ctx context.Context, from OrderState, event OrderEvent) bool { return paid },
+	}
+
+	sm, err := LoadSCXML(strings.NewReader(doc), allOrderStates, allOrderEvents, guards)
+	if err != nil {
+		t.Fatalf("LoadSCXML() error = %v", err)
+	}
+
+	if got, _ := sm.InitialState(); got != OrderStatePending {
+		t.Errorf("InitialState() = %v, want the first state", got)
+	}
+	if got, err := sm.Transition(OrderStatePending, OrderEventConfirm); err != nil || got != OrderStateCancelled {
+		t.Errorf("Transition(Confirm) = %v, %v, want Cancelled", got, err)
+	}
+	paid = true
+	if got, err := sm.Transition(OrderStatePending, OrderEventConfirm); err != nil || got != OrderStateAwaiting {
+		t.Errorf("Transition(Confirm) when paid = %v, %v, want AwaitingCourier", got, err)
+	}
+	if !sm.IsFinalState(OrderStateCancelled) {
+		t.Errorf("IsFinalState(Cancelled) = false")
+	}
+	if !sm.CanTransition(OrderStatePending, OrderEventCancel) {
+		t.Errorf("CanTransition(Cancel) = false, want every event of the transition registered")
+	}
+}
+
+func TestLoadSCXML_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{"not scxml", `<statechart/>`, "root element is <statechart>"},
+		{"malformed", `<scxml>`, "invalid SCXML document"},
+		{"unknown state", `<scxml><state id="Lost"/></scxml>`, `unknown state "Lost"`},
+		{"unknown event", `<scxml><state id="Pending"><transition event="Hold" target="Pending"/></state></scxml>`, `unknown event "Hold"`},
+		{"unknown guard", `<scxml><state id="Pending"><transition event="Confirm" cond="paid" target="Pending"/></state></scxml>`, `unknown guard "paid"`},
+		{"eventless", `<scxml><state id="Pending"><transition target="Shipped"/></state></scxml>`, "eventless transition"},
+		{"several targets", `<scxml><state id="Pending"><transition event="Ship" target="Shipped Packing"/></state></scxml>`, "several targets"},
+		{"parallel", `<scxml><parallel id="Pending"/></scxml>`, `parallel state "Pending" is not supported`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadSCXML(strings.NewReader(tt.doc), allOrderStates, allOrderEvents, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadSCXML() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+)
+
+// Trace records what a simulated sequence of events would do, one step per
+// event processed
+type Trace[S State, E Event] []TraceStep[S, E]
+
+// TraceStep is a single event in a Trace
+type TraceStep[S State, E Event] struct {
+	From  S
+	Event E
+
+	// To is the state the event leads to, or the zero value if it was
+	// rejected
+	To S
+
+	// Candidates are the transitions tried for the event, in order, up to
+	// and including the one taken
+	Candidates []TraceCandidate[S]
+
+	// Automatic holds the events of the automatic transitions that follow
+	// the event's, in the order they are taken. To is the state the last of
+	// them enters.
+	Automatic []E
+
+	// Exited holds the states the step leaves, innermost first, and
+	// Entered those it enters, outermost first, including the composite
+	// states around From and To. Where automatic transitions follow, the
+	// states each of them leaves and enters come after those of the one
+	// before. Both are nil if the event was rejected.
+	Exited, Entered []S
+}
+
+// TraceCandidate is a transition tried during a simulated step
+type TraceCandidate[S State] struct {
+	// Targets are the states the transition leads to; a choice has several
+	Targets []S
+
+	// Guards holds the result of each of the transition's guards in the
+	// order they ran. Guards stop running at the first that fails, so a
+	// rejected transition ends in false.
+	Guards []bool
+
+	// GuardNames names each of the transition's guards, in the order they
+	// run, when they were resolved by name as a definition or SCXML
+	// document was loaded, and is nil otherwise
+	GuardNames []string
+}
+
+// Passed reports whether every guard of the candidate passed
+func (c TraceCandidate[S]) Passed() bool {
+	return len(c.Guards) == 0 || c.Guards[len(c.Guards)-1]
+}
+
+// Simulate works out what processing events from start would do without
+// doing it, recording every step in a Trace. Guards, with the machine's
+// guard middleware, permissions, BeforeTransition hooks, choice resolvers
+// and automatic transitions are checked as they would be for Transition, but
+// actions, callbacks, middleware and subscribers do not run.
+//
+// If an event cannot be processed, Simulate returns the trace up to and
+// including the rejected step along with an error like that returned by
+// ValidateTransitionPath.
+func (sm *StateMachine[S, E]) Simulate(start S, events []E) (Trace[S, E], error) {
+	return sm.SimulateContext(context.Background(), start, events)
+}
+
+// SimulateContext is like Simulate but passes ctx to guards and resolvers
+func (sm *StateMachine[S, E]) SimulateContext(ctx context.Context, start S, events []E) (Trace[S, E], error) {
+	trace := make(Trace[S, E], 0, len(events))
+	current := start
+	for i, event := range events {
+		step, err := sm.simulateStep(ctx, current, event)
+		trace = append(trace, step)
+		if err != nil {
+			return trace, fmt.Errorf("invalid path at step %d: %w", i+1, err)
+		}
+		current = step.To
+	}
+	return trace, nil
+}
+
+// simulateStep is fire without the actions and callbacks
+func (sm *StateMachine[S, E]) simulateStep(ctx context.Context, from S, event E) (TraceStep[S, E], error) {
+	step := TraceStep[S, E]{From: from, Event: event}
+	if err := checkContext(ctx, from, event); err != nil {
+		return step, err
+	}
+
+	sm.rlock()
+	edges := sm.edgesFor(from, event)
+	if len(edges) == 0 {
+		err := sm.transitionError(from, event)
+		sm.runlock()
+		return step, err
+	}
+	guardMW := sm.guardMW
+	sm.runlock()
+
+	var chosen *edge[S, E]
+	for _, e := range edges {
+		candidate := TraceCandidate[S]{Targets: e.targets()}
+		if len(e.guardNames) == len(e.guards) {
+			candidate.GuardNames = e.guardNames
+		}
+		for _, fn := range e.guards {
+			passed := wrapGuard(fn, guardMW)(ctx, from, event)
+			candidate.Guards = append(candidate.Guards, passed)
+			if !passed {
+				break
+			}
+		}
+		step.Candidates = append(step.Candidates, candidate)
+		if candidate.Passed() {
+			chosen = e
+			break
+		}
+	}
+	if err := checkContext(ctx, from, event); err != nil {
+		return step, err
+	}
+	if chosen == nil {
+		sm.rlock()
+		defer sm.runlock()
+		return step, &TransitionError[S, E]{
+			From:          from,
+			Event:         event,
+			ValidEvents:   sm.validEvents(from),
+			guardRejected: true,
+		}
+	}
+
+	to, err := sm.target(ctx, chosen, from, event, nil)
+	if err != nil {
+		return step, err
+	}
+	first := taken[S, E]{e: chosen, from: from, to: to, event: event}
+	if err := sm.checkVetoes(ctx, first); err != nil {
+		return step, err
+	}
+	chain, err := sm.follow(ctx, first, nil, sm.checkVetoes, false)
+	if err != nil {
+		return step, err
+	}
+
+	sm.rlock()
+	defer sm.runlock()
+	step.Exited, step.Entered = sm.exitEnterPath(from, to)
+	for _, t := range chain[1:] {
+		exited, entered := sm.exitEnterPath(t.from, t.to)
+		step.Exited = append(step.Exited, exited...)
+		step.Entered = append(step.Entered, entered...)
+		step.Automatic = append(step.Automatic, t.event)
+	}
+	step.To = chain[len(chain)-1].to
+	return step, nil
+}
@@ -0,0 +1,194 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	var acted, entered bool
+	sm := NewOrderStateMachine()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateCancelled,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true }),
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }),
+		WithAction(func(ctx context.Context, from, to OrderState, event OrderEvent) error {
+			acted = true
+			return nil
+		}))
+	sm.OnEnter(OrderStatePacking, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+		entered = true
+	})
+
+	trace, err := sm.Simulate(OrderStatePending, []OrderEvent{OrderEventConfirm, OrderEventPack})
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+
+	want := Trace[OrderState, OrderEvent]{
+		{
+			From:  OrderStatePending,
+			Event: OrderEventConfirm,
+			To:    OrderStatePacking,
+			Candidates: []TraceCandidate[OrderState]{
+				{Targets: []OrderState{OrderStateCancelled}, Guards: []bool{true, false}},
+				{Targets: []OrderState{OrderStatePacking}},
+			},
+			Exited:  []OrderState{OrderStatePending},
+			Entered: []OrderState{OrderStateProcessing, OrderStatePacking},
+		},
+		{
+			From:       OrderStatePacking,
+			Event:      OrderEventPack,
+			To:         OrderStateAwaiting,
+			Candidates: []TraceCandidate[OrderState]{{Targets: []OrderState{OrderStateAwaiting}}},
+			Exited:     []OrderState{OrderStatePacking},
+			Entered:    []OrderState{OrderStateAwaiting},
+		},
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("Simulate() = %+v, want %+v", trace, want)
+	}
+	if acted || entered {
+		t.Errorf("Simulate() ran actions or callbacks")
+	}
+}
+
+func TestSimulate_Rejected(t *testing.T) {
+	sm := NewOrderStateMachine()
+
+	tests := []struct {
+		name    string
+		events  []OrderEvent
+		wantLen int
+		wantErr error
+	}{
+		{"invalid event", []OrderEvent{OrderEventConfirm, OrderEventDeliver}, 2, ErrInvalidTransition},
+		{"first step", []OrderEvent{OrderEventShip}, 1, ErrInvalidTransition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace, err := sm.Simulate(OrderStatePending, tt.events)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Simulate() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(trace) != tt.wantLen {
+				t.Fatalf("Simulate() returned %d steps, want %d", len(trace), tt.wantLen)
+			}
+			if last := trace[len(trace)-1]; last.To != "" {
+				t.Errorf("rejected step To = %v, want zero value", last.To)
+			}
+		})
+	}
+}
+
+func TestSimulate_GuardRejected(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }))
+
+	trace, err := sm.Simulate(OrderStatePending, []OrderEvent{OrderEventConfirm})
+	if !errors.Is(err, ErrGuardRejected) {
+		t.Fatalf("Simulate() error = %v, want ErrGuardRejected", err)
+	}
+	if c := trace[0].Candidates; len(c) != 1 || c[0].Passed() {
+		t.Errorf("Candidates = %+v, want one that failed", c)
+	}
+}
+
+func TestSimulate_GuardNames(t *testing.T) {
+	def := &Definition{
+		States: []StateDefinition{{Name: "Pending"}, {Name: "Cancelled"}},
+		Events: []EventDefinition{{Name: "Cancel"}},
+		Transitions: []TransitionDefinition{
+			{From: "Pending", Event: "Cancel", To: "Cancelled", Guards: []string{"unpaid", "unshipped"}},
+		},
+	}
+	guards := map[string]Guard[OrderState, OrderEvent]{
+		"unpaid":    func(ctx context.Context, from OrderState, event OrderEvent) bool { return true },
+		"unshipped": func(ctx context.Context, from OrderState, event OrderEvent) bool { return false },
+	}
+	sm, err := FromDefinitionWithGuards(def, allOrderStates, allOrderEvents, guards)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trace, _ := sm.Simulate(OrderStatePending, []OrderEvent{OrderEventCancel})
+	want := []TraceCandidate[OrderState]{
+		{Targets: []OrderState{OrderStateCancelled}, Guards: []bool{true, false}, GuardNames: []string{"unpaid", "unshipped"}},
+	}
+	if got := trace[0].Candidates; !reflect.DeepEqual(got, want) {
+		t.Errorf("Candidates = %+v, want %+v", got, want)
+	}
+}
+
+func TestSimulate_MatchesTransition(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent]()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+	sm.AddTransition(OrderStatePending, OrderEventCancel, OrderStateCancelled)
+	sm.AddTransition(OrderStatePacking, OrderEventPack, OrderStateAwaiting, WithAutomatic[OrderState, OrderEvent]())
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped, RequirePermission[OrderState, OrderEvent]("ship"))
+	sm.AddTransition(OrderStateShipped, OrderEventDeliver, OrderStateDelivered,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true }))
+	sm.UseGuard(func(next Guard[OrderState, OrderEvent]) Guard[OrderState, OrderEvent] {
+		return func(ctx context.Context, from OrderState, event OrderEvent) bool {
+			return event != OrderEventDeliver && next(ctx, from, event)
+		}
+	})
+	sm.BeforeTransition(func(ctx context.Context, from OrderState, event OrderEvent, to OrderState) error {
+		if to == OrderStateCancelled {
+			return errors.New("orders are not cancelled")
+		}
+		return nil
+	})
+
+	shipper := WithPrincipal(context.Background(), Permissions{"ship"})
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		from          OrderState
+		event         OrderEvent
+		wantAutomatic []OrderEvent
+		wantErr       error
+	}{
+		{"automatic", context.Background(), OrderStatePending, OrderEventConfirm, []OrderEvent{OrderEventPack}, nil},
+		{"permitted", shipper, OrderStateAwaiting, OrderEventShip, nil, nil},
+		{"not permitted", context.Background(), OrderStateAwaiting, OrderEventShip, nil, ErrNotAuthorized},
+		{"guard middleware", context.Background(), OrderStateShipped, OrderEventDeliver, nil, ErrGuardRejected},
+		{"vetoed", context.Background(), OrderStatePending, OrderEventCancel, nil, ErrVetoed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace, err := sm.SimulateContext(tt.ctx, tt.from, []OrderEvent{tt.event})
+			to, wantErr := sm.TransitionContext(tt.ctx, tt.from, tt.event)
+			if !errors.Is(wantErr, tt.wantErr) {
+				t.Fatalf("Transition() error = %v, want %v", wantErr, tt.wantErr)
+			}
+			if wantErr != nil {
+				if err == nil || errors.Unwrap(err).Error() != wantErr.Error() {
+					t.Errorf("Simulate() error = %v, want it to wrap %v", err, wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Simulate() error = %v", err)
+			}
+			if got := trace[0]; got.To != to || !reflect.DeepEqual(got.Automatic, tt.wantAutomatic) {
+				t.Errorf("Simulate() = %+v, want it to end in %v after %v", got, to, tt.wantAutomatic)
+			}
+		})
+	}
+
+	trace, err := sm.Simulate(OrderStatePending, []OrderEvent{OrderEventConfirm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := trace[0]
+	if want := []OrderState{OrderStatePending, OrderStatePacking}; !reflect.DeepEqual(step.Exited, want) {
+		t.Errorf("Exited = %v, want %v", step.Exited, want)
+	}
+	if want := []OrderState{OrderStatePacking, OrderStateAwaiting}; !reflect.DeepEqual(step.Entered, want) {
+		t.Errorf("Entered = %v, want %v", step.Entered, want)
+	}
+}
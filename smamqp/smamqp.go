@@ -0,0 +1,198 @@
+// Package smamqp drives a state machine from an AMQP queue, so systems built
+// around RabbitMQ can move their workflows onto the package. Each message is
+// JSON naming the machine, the entity and the event, with an optional
+// payload passed to guards and actions as a json.RawMessage:
+//
+//	{"machine": "orders", "entityId": "42", "event": "Ship", "payload": {"carrier": "dhl"}}
+//
+// The consumer fires the event on the entity through a statemachine.Manager,
+// which saves its new state in a statemachine.StateStore, before
+// acknowledging the message:
+//
+//	deliveries, err := ch.Consume("order-events", "", false, false, false, false, nil)
+//	c := smamqp.NewConsumer("orders", NewOrderStateMachine(), orderStore)
+//	err = c.Run(ctx, deliveries)
+//
+// A message that can never be handled, such as one for another machine or
+// an unknown entity, or an event the entity's state does not accept or that
+// is refused for lack of permission or vetoed, is rejected without
+// requeueing, which sends it to the queue's dead letter exchange if it has
+// one:
+//
+//	ch.QueueDeclare("order-events", true, false, false, false, amqp.Table{
+//		"x-dead-letter-exchange":    "",
+//		"x-dead-letter-routing-key": "order-events.dead",
+//	})
+//
+// A message that fails on the store or an action is requeued to be tried
+// again. If another writer saves the entity first, the event is fired again
+// on its new state. Listeners subscribed to the machine hear of a
+// transition once it is saved. A message's ID, if it has one, is its
+// event's idempotency key, so with the Manager's Dedup set, a message
+// delivered again after being applied is acknowledged without firing it a
+// second time.
+package smamqp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// Command is the body of a message
+type Command struct {
+	Machine  string          `json:"machine"`
+	EntityID string          `json:"entityId"`
+	Event    string          `json:"event"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// Consumer applies the messages for a machine. Create one with NewConsumer.
+type Consumer[S statemachine.State, E statemachine.Event] struct {
+	// Name is the machine messages must name
+	Name string
+
+	Machine *statemachine.StateMachine[S, E]
+
+	// Manager fires events on the machine's entities and saves them
+	Manager *statemachine.Manager[S, E]
+
+	// ErrorLog receives the messages that could not be handled. If nil,
+	// the log package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// NewConsumer returns a consumer applying the messages for the machine
+// called name to the entities in store
+func NewConsumer[S statemachine.State, E statemachine.Event](name string, sm *statemachine.StateMachine[S, E], store statemachine.StateStore[S]) *Consumer[S, E] {
+	return &Consumer[S, E]{Name: name, Machine: sm, Manager: statemachine.NewManager(sm, store)}
+}
+
+// Run handles deliveries, which must not be automatically acknowledged,
+// until ctx is done or the deliveries are closed. It returns ctx's error, or
+// amqp.ErrClosed when the channel closes so the caller can reconnect.
+func (c *Consumer[S, E]) Run(ctx context.Context, deliveries <-chan amqp.Delivery) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case d, ok := <-deliveries:
+			if !ok {
+				return amqp.ErrClosed
+			}
+			c.Handle(ctx, d)
+		}
+	}
+}
+
+// Handle applies one delivery and settles it: acknowledged once applied,
+// rejected for dead-lettering if it can never be, or requeued
+func (c *Consumer[S, E]) Handle(ctx context.Context, d amqp.Delivery) {
+	if d.MessageId != "" {
+		ctx = statemachine.WithIdempotencyKey(ctx, d.MessageId)
+	}
+	err := c.apply(ctx, d.Body)
+
+	var r *rejection
+	switch {
+	case err == nil:
+		err = d.Ack(false)
+	case errors.As(err, &r):
+		c.logf("smamqp: dead-lettering message %d: %v", d.DeliveryTag, err)
+		err = d.Reject(false)
+	default:
+		c.logf("smamqp: requeueing message %d: %v", d.DeliveryTag, err)
+		err = d.Nack(false, true)
+	}
+	if err != nil {
+		c.logf("smamqp: cannot settle message %d: %v", d.DeliveryTag, err)
+	}
+}
+
+// rejection is an error for a message that can never be handled
+type rejection struct {
+	err error
+}
+
+func (r *rejection) Error() string { return r.err.Error() }
+func (r *rejection) Unwrap() error { return r.err }
+
+func reject(format string, args ...any) error {
+	return &rejection{err: fmt.Errorf(format, args...)}
+}
+
+// permanent reports whether err is one that handling the message again
+// cannot avoid
+func permanent(err error) bool {
+	return errors.Is(err, statemachine.ErrNotFound) || errors.Is(err, statemachine.ErrInvalidTransition) ||
+		errors.Is(err, statemachine.ErrNotAuthorized) || errors.Is(err, statemachine.ErrVetoed)
+}
+
+// apply fires the event a message carries, saving the entity's new state
+func (c *Consumer[S, E]) apply(ctx context.Context, body []byte) error {
+	var cmd Command
+	if err := json.Unmarshal(body, &cmd); err != nil {
+		return reject("cannot decode message: %v", err)
+	}
+	if cmd.Machine != c.Name {
+		return reject("message is for machine %q, not %q", cmd.Machine, c.Name)
+	}
+	if cmd.EntityID == "" {
+		return reject("message names no entity")
+	}
+	event, ok := c.event(cmd.Event)
+	if !ok {
+		return reject("unknown event %q", cmd.Event)
+	}
+
+	if cmd.Payload != nil {
+		ctx = statemachine.WithPayload(ctx, cmd.Payload)
+	}
+	err := c.fire(ctx, cmd.EntityID, event)
+	if errors.Is(err, statemachine.ErrNotFound) {
+		return &rejection{err: err}
+	}
+	if permanent(err) {
+		return &rejection{err: fmt.Errorf("entity %s: %w", cmd.EntityID, err)}
+	}
+	if err != nil {
+		return fmt.Errorf("entity %s: %w", cmd.EntityID, err)
+	}
+	return nil
+}
+
+// fire fires event on the entity with the given ID, loading it again and
+// retrying while other writers save it first
+func (c *Consumer[S, E]) fire(ctx context.Context, id string, event E) error {
+	for {
+		_, err := c.Manager.Fire(ctx, id, event)
+		if !errors.Is(err, statemachine.ErrConcurrentModification) || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// event finds the machine's event with the given name
+func (c *Consumer[S, E]) event(name string) (E, bool) {
+	for _, e := range c.Machine.GetAllEvents() {
+		if statemachine.Name(e) == name {
+			return e, true
+		}
+	}
+	var zero E
+	return zero, false
+}
+
+func (c *Consumer[S, E]) logf(format string, args ...any) {
+	if c.ErrorLog != nil {
+		c.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
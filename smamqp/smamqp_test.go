@@ -0,0 +1,275 @@
+package smamqp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smamqp"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+// store is a MemoryStore whose saves fail with saveErr, if set, after
+// failing the first conflicts of them as another writer's saves would
+type store struct {
+	*statemachine.MemoryStore[state]
+	saveErr   error
+	conflicts int
+}
+
+// newStore returns a store holding the given entities
+func newStore(t *testing.T, entities map[string]state) *store {
+	t.Helper()
+	s := &store{MemoryStore: &statemachine.MemoryStore[state]{}}
+	for id, st := range entities {
+		if err := s.MemoryStore.Save(context.Background(), id, "", st, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func (s *store) Save(ctx context.Context, id string, from, to state, version int64) error {
+	if s.conflicts > 0 {
+		s.conflicts--
+		return fmt.Errorf("entity %s: %w", id, statemachine.ErrConcurrentModification)
+	}
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	return s.MemoryStore.Save(ctx, id, from, to, version)
+}
+
+func (s *store) state(id string) state {
+	st, _, _ := s.Load(context.Background(), id)
+	return st
+}
+
+// acknowledger records how each delivery was settled
+type acknowledger struct {
+	settled []string
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.settled = append(a.settled, fmt.Sprintf("%d: ack", tag))
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.settled = append(a.settled, fmt.Sprintf("%d: nack requeue=%v", tag, requeue))
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	a.settled = append(a.settled, fmt.Sprintf("%d: reject requeue=%v", tag, requeue))
+	return nil
+}
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Pending", "Pay", "Paid",
+		statemachine.WithGuard(statemachine.PayloadGuard(func(ctx context.Context, from state, e event, payload json.RawMessage) bool {
+			return string(payload) == `"card"`
+		})))
+	sm.AddTransition("Pending", "Cancel", "Cancelled")
+	sm.AddTransition("Pending", "Refund", "Refunded", statemachine.RequirePermission[state, event]("refund"))
+	sm.AddTransition("Pending", "Lose", "Lost")
+	sm.AddTransition("Paid", "Ship", "Shipped")
+	sm.BeforeTransition(func(ctx context.Context, from state, e event, to state) error {
+		if to == "Lost" {
+			return errors.New("orders are never lost")
+		}
+		return nil
+	})
+	return sm
+}
+
+func TestConsumer_Handle(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		saveErr    error
+		conflicts  int
+		want       state
+		wantSettle string
+		wantLogged string
+	}{
+		{
+			name:       "valid",
+			body:       `{"machine":"orders","entityId":"1","event":"Cancel"}`,
+			want:       "Cancelled",
+			wantSettle: "1: ack",
+		},
+		{
+			name:       "payload",
+			body:       `{"machine":"orders","entityId":"1","event":"Pay","payload":"card"}`,
+			want:       "Paid",
+			wantSettle: "1: ack",
+		},
+		{
+			name:       "invalid transition",
+			body:       `{"machine":"orders","entityId":"1","event":"Ship"}`,
+			want:       "Pending",
+			wantSettle: "1: reject requeue=false",
+			wantLogged: "dead-lettering message 1: entity 1: invalid transition",
+		},
+		{
+			name:       "guard rejected",
+			body:       `{"machine":"orders","entityId":"1","event":"Pay","payload":"cash"}`,
+			want:       "Pending",
+			wantSettle: "1: reject requeue=false",
+			wantLogged: "guard rejected event 'Pay'",
+		},
+		{
+			name:       "other machine",
+			body:       `{"machine":"users","entityId":"1","event":"Cancel"}`,
+			want:       "Pending",
+			wantSettle: "1: reject requeue=false",
+			wantLogged: `message is for machine "users", not "orders"`,
+		},
+		{
+			name:       "unknown entity",
+			body:       `{"machine":"orders","entityId":"9","event":"Cancel"}`,
+			want:       "Pending",
+			wantSettle: "1: reject requeue=false",
+			wantLogged: "dead-lettering message 1: entity 9: entity not found",
+		},
+		{
+			name:       "not authorized",
+			body:       `{"machine":"orders","entityId":"1","event":"Refund"}`,
+			want:       "Pending",
+			wantSettle: "1: reject requeue=false",
+			wantLogged: "dead-lettering message 1: entity 1: not authorized",
+		},
+		{
+			name:       "vetoed",
+			body:       `{"machine":"orders","entityId":"1","event":"Lose"}`,
+			want:       "Pending",
+			wantSettle: "1: reject requeue=false",
+			wantLogged: "dead-lettering message 1: entity 1: event 'Lose' from state 'Pending' to 'Lost': transition vetoed",
+		},
+		{
+			name:       "unknown event",
+			body:       `{"machine":"orders","entityId":"1","event":"Explode"}`,
+			want:       "Pending",
+			wantSettle: "1: reject requeue=false",
+			wantLogged: `unknown event "Explode"`,
+		},
+		{
+			name:       "not json",
+			body:       `Cancel`,
+			want:       "Pending",
+			wantSettle: "1: reject requeue=false",
+			wantLogged: "cannot decode message",
+		},
+		{
+			name:       "save fails",
+			body:       `{"machine":"orders","entityId":"1","event":"Cancel"}`,
+			saveErr:    errors.New("database is down"),
+			want:       "Pending",
+			wantSettle: "1: nack requeue=true",
+			wantLogged: "requeueing message 1: entity 1: database is down",
+		},
+		{
+			name:       "another writer saves first",
+			body:       `{"machine":"orders","entityId":"1","event":"Cancel"}`,
+			conflicts:  2,
+			want:       "Cancelled",
+			wantSettle: "1: ack",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newStore(t, map[string]state{"1": "Pending"})
+			st.saveErr, st.conflicts = tt.saveErr, tt.conflicts
+			sm := newMachine()
+			heard := 0
+			sm.Subscribe(func(ctx context.Context, from, to state, e event, at time.Time) {
+				heard++
+			})
+			var logged bytes.Buffer
+			c := smamqp.NewConsumer("orders", sm, st)
+			c.ErrorLog = log.New(&logged, "", 0)
+			ack := &acknowledger{}
+
+			c.Handle(context.Background(), amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, Body: []byte(tt.body)})
+
+			if got := st.state("1"); got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+			if len(ack.settled) != 1 || ack.settled[0] != tt.wantSettle {
+				t.Errorf("settled = %q, want %q", ack.settled, tt.wantSettle)
+			}
+			if tt.wantLogged == "" && logged.Len() > 0 || !strings.Contains(logged.String(), tt.wantLogged) {
+				t.Errorf("logged %q, want %q", logged.String(), tt.wantLogged)
+			}
+			wantHeard := 0
+			if tt.want != "Pending" {
+				wantHeard = 1
+			}
+			if heard != wantHeard {
+				t.Errorf("listeners told of %d transitions, want %d", heard, wantHeard)
+			}
+		})
+	}
+}
+
+func TestConsumer_HandleRepeat(t *testing.T) {
+	st := newStore(t, map[string]state{"1": "Pending"})
+	c := smamqp.NewConsumer("orders", newMachine(), st)
+	c.Manager.Dedup = &statemachine.MemoryDedupStore[state]{}
+	ack := &acknowledger{}
+
+	for tag := uint64(1); tag <= 2; tag++ {
+		c.Handle(context.Background(), amqp.Delivery{
+			Acknowledger: ack,
+			DeliveryTag:  tag,
+			MessageId:    "order-1-pay",
+			Body:         []byte(`{"machine":"orders","entityId":"1","event":"Pay","payload":"card"}`),
+		})
+	}
+	if _, version, _ := st.Load(context.Background(), "1"); version != 2 || strings.Join(ack.settled, ", ") != "1: ack, 2: ack" {
+		t.Errorf("entity at version %d, settled = %q, want version 2 with both acknowledged", version, ack.settled)
+	}
+}
+
+func TestConsumer_Run(t *testing.T) {
+	st := newStore(t, map[string]state{"1": "Pending"})
+	c := smamqp.NewConsumer("orders", newMachine(), st)
+	ack := &acknowledger{}
+
+	deliveries := make(chan amqp.Delivery, 2)
+	deliveries <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, Body: []byte(`{"machine":"orders","entityId":"1","event":"Pay","payload":"card"}`)}
+	deliveries <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 2, Body: []byte(`{"machine":"orders","entityId":"1","event":"Ship"}`)}
+	close(deliveries)
+
+	if err := c.Run(context.Background(), deliveries); !errors.Is(err, amqp.ErrClosed) {
+		t.Errorf("Run() error = %v, want %v", err, amqp.ErrClosed)
+	}
+	if st.state("1") != "Shipped" || strings.Join(ack.settled, ", ") != "1: ack, 2: ack" {
+		t.Errorf("state = %s, settled = %q", st.state("1"), ack.settled)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Run(ctx, make(chan amqp.Delivery)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,113 @@
+// Package smbolt keeps the state of entities in a bbolt database file,
+// implementing statemachine.StateStore for single-binary deployments and
+// command line tools that need workflow state to survive restarts without an
+// external database:
+//
+//	db, err := bolt.Open("workflows.db", 0o600, nil)
+//	store := smbolt.NewStore(db, "orders", NewOrderStateMachine())
+//	orders := statemachine.NewManager(NewOrderStateMachine(), store)
+//
+// Each entity is a key in the store's bucket, created on first save, holding
+// its state and version as JSON. bbolt serializes writes, so comparing the
+// version and saving the new state happen atomically.
+package smbolt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// Store is a statemachine.StateStore keeping entities in a bbolt bucket.
+// Create one with NewStore.
+type Store[S statemachine.State] struct {
+	DB     *bolt.DB
+	Bucket string
+
+	// state finds the machine's state with a stored name
+	state func(name string) (S, bool)
+}
+
+// record is an entity's stored value
+type record struct {
+	State   string `json:"state"`
+	Version int64  `json:"version"`
+}
+
+// NewStore returns a store keeping the entities of sm in bucket. Stored
+// states are read back by name, so they must be states of sm.
+func NewStore[S statemachine.State, E statemachine.Event](db *bolt.DB, bucket string, sm *statemachine.StateMachine[S, E]) *Store[S] {
+	return &Store[S]{DB: db, Bucket: bucket, state: sm.StateByName}
+}
+
+// Load returns an entity's state and version
+func (s *Store[S]) Load(ctx context.Context, id string) (S, int64, error) {
+	var (
+		zero  S
+		rec   record
+		found bool
+	)
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		rec, found, err = s.get(tx, id)
+		return err
+	})
+	if err != nil {
+		return zero, 0, err
+	}
+	if !found {
+		return zero, 0, fmt.Errorf("entity %s: %w", id, statemachine.ErrNotFound)
+	}
+	state, ok := s.state(rec.State)
+	if !ok {
+		return zero, 0, fmt.Errorf("entity %s is in state %q: %w", id, rec.State, statemachine.ErrUnknownState)
+	}
+	return state, rec.Version, nil
+}
+
+// Save stores an entity's new state if it is still at version
+func (s *Store[S]) Save(ctx context.Context, id string, from, to S, version int64) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		current, _, err := s.get(tx, id)
+		if err != nil {
+			return err
+		}
+		if current.Version != version {
+			return fmt.Errorf("entity %s is at version %d, not %d: %w", id, current.Version, version, statemachine.ErrConcurrentModification)
+		}
+
+		b, err := tx.CreateBucketIfNotExists([]byte(s.Bucket))
+		if err != nil {
+			return fmt.Errorf("smbolt: cannot create bucket %s: %w", s.Bucket, err)
+		}
+		value, err := json.Marshal(record{State: statemachine.Name(to), Version: version + 1})
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(id), value); err != nil {
+			return fmt.Errorf("smbolt: cannot save entity %s: %w", id, err)
+		}
+		return nil
+	})
+}
+
+// get reads an entity's record, reporting whether it exists
+func (s *Store[S]) get(tx *bolt.Tx, id string) (record, bool, error) {
+	var rec record
+	b := tx.Bucket([]byte(s.Bucket))
+	if b == nil {
+		return rec, false, nil
+	}
+	value := b.Get([]byte(id))
+	if value == nil {
+		return rec, false, nil
+	}
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return rec, false, fmt.Errorf("smbolt: entity %s has a corrupt record: %w", id, err)
+	}
+	return rec, true, nil
+}
@@ -0,0 +1,166 @@
+package smbolt_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smbolt"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.SetInitialState("Pending")
+	sm.AddTransition("Pending", "Confirm", "Confirmed")
+	sm.AddTransition("Confirmed", "Ship", "Shipped")
+	return sm
+}
+
+func openDB(t *testing.T, path string) *bolt.DB {
+	t.Helper()
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestStore(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "workflows.db"))
+	defer db.Close()
+	store := smbolt.NewStore(db, "orders", newMachine())
+	ctx := context.Background()
+
+	if _, _, err := store.Load(ctx, "42"); !errors.Is(err, statemachine.ErrNotFound) {
+		t.Fatalf("Load() before the bucket exists error = %v, want %v", err, statemachine.ErrNotFound)
+	}
+
+	steps := []struct {
+		to      state
+		version int64
+		wantErr error
+	}{
+		{"Pending", 0, nil},
+		{"Pending", 0, statemachine.ErrConcurrentModification},
+		{"Confirmed", 1, nil},
+		{"Shipped", 1, statemachine.ErrConcurrentModification},
+		{"Shipped", 2, nil},
+	}
+	for i, step := range steps {
+		if err := store.Save(ctx, "42", "", step.to, step.version); !errors.Is(err, step.wantErr) || step.wantErr == nil && err != nil {
+			t.Fatalf("step %d: Save(%s, %d) error = %v, want %v", i+1, step.to, step.version, err, step.wantErr)
+		}
+	}
+
+	if _, _, err := store.Load(ctx, "43"); !errors.Is(err, statemachine.ErrNotFound) {
+		t.Errorf("Load() of unknown entity error = %v, want %v", err, statemachine.ErrNotFound)
+	}
+	got, version, err := store.Load(ctx, "42")
+	if err != nil || got != "Shipped" || version != 3 {
+		t.Errorf("Load() = %v, %d, %v, want Shipped at version 3", got, version, err)
+	}
+}
+
+func TestStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workflows.db")
+	ctx := context.Background()
+
+	db := openDB(t, path)
+	orders := statemachine.NewManager(newMachine(), smbolt.NewStore(db, "orders", newMachine()))
+	if err := orders.Create(ctx, "42"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.Fire(ctx, "42", "Confirm"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db = openDB(t, path)
+	defer db.Close()
+	orders = statemachine.NewManager(newMachine(), smbolt.NewStore(db, "orders", newMachine()))
+	if got, err := orders.Fire(ctx, "42", "Ship"); err != nil || got != "Shipped" {
+		t.Errorf("Fire() after reopening = %v, %v, want Shipped", got, err)
+	}
+}
+
+func TestStore_Concurrent(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "workflows.db"))
+	defer db.Close()
+	store := smbolt.NewStore(db, "orders", newMachine())
+	ctx := context.Background()
+	if err := store.Save(ctx, "42", "", "Pending", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		saved     int
+		conflicts int
+	)
+	for range 10 {
+		wg.Go(func() {
+			err := store.Save(ctx, "42", "Pending", "Confirmed", 1)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				saved++
+			case errors.Is(err, statemachine.ErrConcurrentModification):
+				conflicts++
+			default:
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if saved != 1 || conflicts != 9 {
+		t.Errorf("saved %d times with %d conflicts, want 1 and 9", saved, conflicts)
+	}
+}
+
+func TestLoad_Corrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{"unknown state", `{"state":"Lost","version":1}`, `entity 42 is in state "Lost": unknown state`},
+		{"not JSON", `Pending`, "smbolt: entity 42 has a corrupt record: invalid character 'P' looking for beginning of value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openDB(t, filepath.Join(t.TempDir(), "workflows.db"))
+			defer db.Close()
+			err := db.Update(func(tx *bolt.Tx) error {
+				b, err := tx.CreateBucket([]byte("orders"))
+				if err != nil {
+					return err
+				}
+				return b.Put([]byte("42"), []byte(tt.value))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			store := smbolt.NewStore(db, "orders", newMachine())
+			if _, _, err := store.Load(context.Background(), "42"); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
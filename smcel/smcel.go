@@ -0,0 +1,180 @@
+// Package smcel writes statemachine guards as CEL expressions, so machines
+// loaded from YAML or JSON definitions can carry conditional logic without
+// any Go being compiled for it. A definition gives an expression in place
+// of a guard name:
+//
+//	transitions:
+//	  - {from: Pending, event: Confirm, to: Approved, guards: ["payload.total < 1000"]}
+//	  - {from: Pending, event: Confirm, to: Review, guards: ["payload.total >= 1000 && data.region != 'EU'"]}
+//
+// and is loaded with LoadYAML, or with Guards and
+// statemachine.FromDefinitionWithGuards:
+//
+//	sm, err := smcel.LoadYAML(f, allOrderStates, allOrderEvents, nil)
+//
+// Expressions can use these variables:
+//
+//	from     the state transitioned from, named as statemachine.Name names it
+//	event    the event, named likewise
+//	payload  the event's payload, given with statemachine.WithPayload or FireWith
+//	data     the data stored with SetData on the instance transitioning
+//
+// A payload or data value that is a struct is seen as encoding/json would
+// encode it, so its fields go by their JSON names. Numbers compare with
+// each other whatever their types. data is empty when transitioning a
+// machine directly rather than through an Instance, and payload is null
+// without one.
+package smcel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"cel.dev/cel-go/cel"
+	"cel.dev/cel-go/common/types"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// env is the environment every expression is compiled in
+var env = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("from", cel.StringType),
+		cel.Variable("event", cel.StringType),
+		cel.Variable("payload", cel.DynType),
+		cel.Variable("data", cel.MapType(cel.StringType, cel.DynType)),
+		cel.CrossTypeNumericComparisons(true),
+	)
+})
+
+// Guard compiles expr into a guard that passes when expr evaluates to true.
+// It fails if expr does not compile or does not evaluate to a bool.
+//
+// A guard whose expression fails when evaluated, for instance by reading a
+// field the payload does not have, rejects the transition. Use has() to
+// test for optional fields: has(payload.coupon) && payload.coupon != "".
+func Guard[S statemachine.State, E statemachine.Event](expr string) (statemachine.Guard[S, E], error) {
+	e, err := env()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := e.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("guard %q: %w", expr, issues.Err())
+	}
+	if t := ast.OutputType(); !t.IsExactType(cel.BoolType) && !t.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("guard %q: evaluates to %s, not bool", expr, t)
+	}
+	prg, err := e.Program(ast, cel.InterruptCheckFrequency(100))
+	if err != nil {
+		return nil, fmt.Errorf("guard %q: %w", expr, err)
+	}
+
+	return func(ctx context.Context, from S, event E) bool {
+		vars := map[string]any{
+			"from":    statemachine.Name(from),
+			"event":   statemachine.Name(event),
+			"payload": payload(ctx),
+			"data":    instanceData(ctx),
+		}
+		out, _, err := prg.ContextEval(ctx, vars)
+		return err == nil && out == types.True
+	}, nil
+}
+
+// MustGuard is like Guard, but panics if expr does not compile
+func MustGuard[S statemachine.State, E statemachine.Event](expr string) statemachine.Guard[S, E] {
+	g, err := Guard[S, E](expr)
+	if err != nil {
+		panic("smcel: " + err.Error())
+	}
+	return g
+}
+
+// Guards returns named along with a guard compiled from every guard of
+// def's transitions that is not in named, keyed by its expression, ready
+// for statemachine.FromDefinitionWithGuards. named may be nil. A guard that
+// is neither named nor a valid expression fails, identifying its
+// transition.
+func Guards[S statemachine.State, E statemachine.Event](def *statemachine.Definition, named map[string]statemachine.Guard[S, E]) (map[string]statemachine.Guard[S, E], error) {
+	guards := make(map[string]statemachine.Guard[S, E], len(named))
+	for name, g := range named {
+		guards[name] = g
+	}
+	for _, t := range def.Transitions {
+		for _, expr := range t.Guards {
+			if _, ok := guards[expr]; ok {
+				continue
+			}
+			g, err := Guard[S, E](expr)
+			if err != nil {
+				return nil, fmt.Errorf("transition from %q on %q: %w", t.From, t.Event, err)
+			}
+			guards[expr] = g
+		}
+	}
+	return guards, nil
+}
+
+// LoadYAML is statemachine.LoadYAML, with the definition's guards compiled
+// by Guards
+func LoadYAML[S statemachine.State, E statemachine.Event](r io.Reader, states []S, events []E, named map[string]statemachine.Guard[S, E]) (*statemachine.StateMachine[S, E], error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	def, err := statemachine.ParseYAMLDefinition("", data)
+	if err != nil {
+		return nil, err
+	}
+	guards, err := Guards(def, named)
+	if err != nil {
+		return nil, err
+	}
+	return statemachine.FromDefinitionWithGuards(def, states, events, guards)
+}
+
+// payload returns the payload carried by ctx as CEL sees it, or null
+func payload(ctx context.Context) any {
+	p, ok := statemachine.PayloadFrom[any](ctx)
+	if !ok || p == nil {
+		return types.NullValue
+	}
+	return value(p)
+}
+
+// instanceData returns the instance data carried by ctx as CEL sees it,
+// or an empty map
+func instanceData(ctx context.Context) map[string]any {
+	d, _ := statemachine.DataFrom(ctx)
+	values := make(map[string]any, len(d))
+	for k, v := range d {
+		values[k] = value(v)
+	}
+	return values
+}
+
+// value returns v as CEL should see it: maps, slices and scalars as they
+// are, and anything else, such as a struct, as encoding/json encodes it
+func value(v any) any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return v
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return types.NullValue
+	}
+	var decoded any
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return types.NullValue
+	}
+	return decoded
+}
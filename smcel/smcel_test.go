@@ -0,0 +1,128 @@
+package smcel_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smcel"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+type order struct {
+	Total  int    `json:"total"`
+	Coupon string `json:"coupon,omitempty"`
+}
+
+func TestGuard(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		payload any
+		data    map[string]any
+		want    bool
+	}{
+		{"struct payload", "payload.total < 1000", order{Total: 250}, nil, true},
+		{"pointer payload", "payload.total < 1000", &order{Total: 2500}, nil, false},
+		{"map payload", "payload.total >= 1000.5", map[string]any{"total": 2000}, nil, true},
+		{"optional field", `has(payload.coupon) && payload.coupon != ""`, order{Total: 1}, nil, false},
+		{"missing payload", "payload.total < 1000", nil, nil, false},
+		{"names", "from == 'Pending' && event == 'Confirm'", nil, nil, true},
+		{"data", "data.region == 'EU' && data.order.total > 10", nil, map[string]any{"region": "EU", "order": order{Total: 20}}, true},
+		{"no data", "'region' in data", nil, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := statemachine.NewStateMachine[state, event]()
+			sm.AddTransition("Pending", "Confirm", "Approved",
+				statemachine.WithGuard(smcel.MustGuard[state, event](tt.expr)))
+			inst := sm.NewInstanceAt("Pending")
+			for k, v := range tt.data {
+				inst.SetData(k, v)
+			}
+
+			ctx := context.Background()
+			if tt.payload != nil {
+				ctx = statemachine.WithPayload(ctx, tt.payload)
+			}
+			err := inst.Fire(ctx, "Confirm")
+			if got := err == nil; got != tt.want {
+				t.Errorf("Fire() error = %v, want guard to pass: %v", err, tt.want)
+			}
+			if err != nil && !errors.Is(err, statemachine.ErrGuardRejected) {
+				t.Errorf("Fire() error = %v, want %v", err, statemachine.ErrGuardRejected)
+			}
+		})
+	}
+}
+
+func TestGuard_Invalid(t *testing.T) {
+	for _, expr := range []string{"payload.total <", "payload.total + 1", "missing == 1"} {
+		if _, err := smcel.Guard[state, event](expr); err == nil {
+			t.Errorf("Guard(%q) succeeded", expr)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustGuard() of an invalid expression did not panic")
+		}
+	}()
+	smcel.MustGuard[state, event]("(")
+}
+
+const orderYAML = `
+states:
+  - name: Pending
+  - name: Approved
+  - name: Review
+events:
+  - name: Confirm
+transitions:
+  - {from: Pending, event: Confirm, to: Approved, guards: ["payload.total < 1000", notFlagged]}
+  - {from: Pending, event: Confirm, to: Review, guards: ["payload.total >= 1000"]}
+`
+
+func TestLoadYAML(t *testing.T) {
+	flagged := false
+	named := map[string]statemachine.Guard[state, event]{
+		"notFlagged": func(ctx context.Context, from state, ev event) bool { return !flagged },
+	}
+	sm, err := smcel.LoadYAML(strings.NewReader(orderYAML), []state{"Pending", "Approved", "Review"}, []event{"Confirm"}, named)
+	if err != nil {
+		t.Fatalf("LoadYAML() error = %v", err)
+	}
+
+	tests := []struct {
+		total   int
+		flagged bool
+		want    state
+	}{
+		{250, false, "Approved"},
+		{250, true, ""},
+		{2500, false, "Review"},
+	}
+	for _, tt := range tests {
+		flagged = tt.flagged
+		ctx := statemachine.WithPayload(context.Background(), order{Total: tt.total})
+		got, err := sm.TransitionContext(ctx, "Pending", "Confirm")
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Errorf("TransitionContext() with total %d, flagged %v = %q, %v, want %q", tt.total, tt.flagged, got, err, tt.want)
+		}
+	}
+
+	_, err = smcel.LoadYAML(strings.NewReader(orderYAML), []state{"Pending", "Approved", "Review"}, []event{"Confirm"}, nil)
+	if err == nil || !strings.Contains(err.Error(), `transition from "Pending" on "Confirm"`) {
+		t.Errorf("LoadYAML() without the named guard error = %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+// Package smdynamo keeps the state of entities in an Amazon DynamoDB table,
+// implementing statemachine.StateStore for workflows running on serverless
+// infrastructure:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	store := smdynamo.NewStore(dynamodb.NewFromConfig(cfg), "signups", NewUserStateMachine())
+//	users := statemachine.NewManager(NewUserStateMachine(), store)
+//
+// The table's partition key is a string attribute named id. Each item holds
+// an entity's state, its version and when it was last updated. Saves are
+// conditional writes on the version, so of two writers transitioning the
+// same entity at once only the first succeeds and the second gets
+// statemachine.ErrConcurrentModification. Loads are strongly consistent.
+package smdynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// API is the part of the DynamoDB client the store uses. *dynamodb.Client
+// implements it.
+type API interface {
+	GetItem(ctx context.Context, in *dynamodb.GetItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, in *dynamodb.PutItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// Store is a statemachine.StateStore keeping entities in a DynamoDB table.
+// Create one with NewStore.
+type Store[S statemachine.State] struct {
+	Client API
+	Table  string
+
+	// state finds the machine's state with a stored name
+	state func(name string) (S, bool)
+}
+
+// NewStore returns a store keeping the entities of sm in table. Stored
+// states are read back by name, so they must be states of sm.
+func NewStore[S statemachine.State, E statemachine.Event](client API, table string, sm *statemachine.StateMachine[S, E]) *Store[S] {
+	return &Store[S]{Client: client, Table: table, state: sm.StateByName}
+}
+
+// Load returns an entity's state and version
+func (s *Store[S]) Load(ctx context.Context, id string) (S, int64, error) {
+	var zero S
+	out, err := s.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.Table),
+		Key:            map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return zero, 0, fmt.Errorf("smdynamo: cannot load entity %s: %w", id, err)
+	}
+	if out.Item == nil {
+		return zero, 0, fmt.Errorf("entity %s: %w", id, statemachine.ErrNotFound)
+	}
+
+	name, ok := out.Item["state"].(*types.AttributeValueMemberS)
+	if !ok {
+		return zero, 0, fmt.Errorf("smdynamo: entity %s has no state", id)
+	}
+	n, ok := out.Item["version"].(*types.AttributeValueMemberN)
+	if !ok {
+		return zero, 0, fmt.Errorf("smdynamo: entity %s has no version", id)
+	}
+	version, err := strconv.ParseInt(n.Value, 10, 64)
+	if err != nil {
+		return zero, 0, fmt.Errorf("smdynamo: entity %s has version %q: %w", id, n.Value, err)
+	}
+	state, ok := s.state(name.Value)
+	if !ok {
+		return zero, 0, fmt.Errorf("entity %s is in state %q: %w", id, name.Value, statemachine.ErrUnknownState)
+	}
+	return state, version, nil
+}
+
+// Save stores an entity's new state if it is still at version, creating
+// the item when version is 0
+func (s *Store[S]) Save(ctx context.Context, id string, from, to S, version int64) error {
+	in := &dynamodb.PutItemInput{
+		TableName: aws.String(s.Table),
+		Item: map[string]types.AttributeValue{
+			"id":         &types.AttributeValueMemberS{Value: id},
+			"state":      &types.AttributeValueMemberS{Value: statemachine.Name(to)},
+			"version":    &types.AttributeValueMemberN{Value: strconv.FormatInt(version+1, 10)},
+			"updated_at": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339Nano)},
+		},
+	}
+	if version == 0 {
+		in.ConditionExpression = aws.String("attribute_not_exists(#id)")
+		in.ExpressionAttributeNames = map[string]string{"#id": "id"}
+	} else {
+		in.ConditionExpression = aws.String("#version = :version")
+		in.ExpressionAttributeNames = map[string]string{"#version": "version"}
+		in.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+		}
+	}
+
+	if _, err := s.Client.PutItem(ctx, in); err != nil {
+		var failed *types.ConditionalCheckFailedException
+		if errors.As(err, &failed) {
+			return fmt.Errorf("entity %s is no longer at version %d: %w", id, version, statemachine.ErrConcurrentModification)
+		}
+		return fmt.Errorf("smdynamo: cannot save entity %s: %w", id, err)
+	}
+	return nil
+}
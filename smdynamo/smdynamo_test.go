@@ -0,0 +1,178 @@
+package smdynamo_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smdynamo"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.SetInitialState("Unverified")
+	sm.AddTransition("Unverified", "Verify", "Active")
+	sm.AddTransition("Active", "Suspend", "Suspended")
+	return sm
+}
+
+// fakeDynamo is a table in memory, evaluating the two conditions the store
+// writes with
+type fakeDynamo struct {
+	mu    sync.Mutex
+	table string
+	items map[string]map[string]types.AttributeValue
+	err   error
+}
+
+func (f *fakeDynamo) GetItem(ctx context.Context, in *dynamodb.GetItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	if aws.ToString(in.TableName) != f.table || !aws.ToBool(in.ConsistentRead) {
+		return nil, errors.New("unexpected GetItem input")
+	}
+	id := in.Key["id"].(*types.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: f.items[id]}, nil
+}
+
+func (f *fakeDynamo) PutItem(ctx context.Context, in *dynamodb.PutItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	id := in.Item["id"].(*types.AttributeValueMemberS).Value
+	existing, exists := f.items[id]
+
+	var ok bool
+	switch cond := aws.ToString(in.ConditionExpression); cond {
+	case "attribute_not_exists(#id)":
+		ok = !exists && in.ExpressionAttributeNames["#id"] == "id"
+	case "#version = :version":
+		want := in.ExpressionAttributeValues[":version"].(*types.AttributeValueMemberN).Value
+		ok = exists && in.ExpressionAttributeNames["#version"] == "version" &&
+			existing["version"].(*types.AttributeValueMemberN).Value == want
+	default:
+		return nil, errors.New("unexpected condition " + cond)
+	}
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	if f.items == nil {
+		f.items = make(map[string]map[string]types.AttributeValue)
+	}
+	f.items[id] = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestStore(t *testing.T) {
+	db := &fakeDynamo{table: "signups"}
+	store := smdynamo.NewStore(db, "signups", newMachine())
+	ctx := context.Background()
+
+	if _, _, err := store.Load(ctx, "u1"); !errors.Is(err, statemachine.ErrNotFound) {
+		t.Fatalf("Load() of unknown entity error = %v, want %v", err, statemachine.ErrNotFound)
+	}
+
+	steps := []struct {
+		to      state
+		version int64
+		wantErr error
+	}{
+		{"Unverified", 0, nil},
+		{"Unverified", 0, statemachine.ErrConcurrentModification},
+		{"Active", 1, nil},
+		{"Suspended", 1, statemachine.ErrConcurrentModification},
+		{"Suspended", 2, nil},
+		{"Active", 7, statemachine.ErrConcurrentModification},
+	}
+	for i, step := range steps {
+		if err := store.Save(ctx, "u1", "", step.to, step.version); !errors.Is(err, step.wantErr) || step.wantErr == nil && err != nil {
+			t.Fatalf("step %d: Save(%s, %d) error = %v, want %v", i+1, step.to, step.version, err, step.wantErr)
+		}
+	}
+
+	got, version, err := store.Load(ctx, "u1")
+	if err != nil || got != "Suspended" || version != 3 {
+		t.Errorf("Load() = %v, %d, %v, want Suspended at version 3", got, version, err)
+	}
+	if at, ok := db.items["u1"]["updated_at"].(*types.AttributeValueMemberS); !ok || at.Value == "" {
+		t.Errorf("item = %v, want an updated_at time", db.items["u1"])
+	}
+}
+
+func TestStore_Manager(t *testing.T) {
+	users := statemachine.NewManager(newMachine(), smdynamo.NewStore(&fakeDynamo{table: "signups"}, "signups", newMachine()))
+	ctx := context.Background()
+
+	if err := users.Create(ctx, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := users.Fire(ctx, "u1", "Verify"); err != nil || got != "Active" {
+		t.Errorf("Fire() = %v, %v, want Active", got, err)
+	}
+}
+
+func TestStore_Fails(t *testing.T) {
+	db := &fakeDynamo{table: "signups", err: errors.New("throttled")}
+	store := smdynamo.NewStore(db, "signups", newMachine())
+	ctx := context.Background()
+
+	if _, _, err := store.Load(ctx, "u1"); err == nil || err.Error() != "smdynamo: cannot load entity u1: throttled" {
+		t.Errorf("Load() error = %v", err)
+	}
+	if err := store.Save(ctx, "u1", "", "Active", 1); err == nil || err.Error() != "smdynamo: cannot save entity u1: throttled" {
+		t.Errorf("Save() error = %v", err)
+	}
+}
+
+func TestLoad_Corrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    map[string]types.AttributeValue
+		wantErr string
+	}{
+		{
+			name:    "unknown state",
+			item:    map[string]types.AttributeValue{"state": &types.AttributeValueMemberS{Value: "Lost"}, "version": &types.AttributeValueMemberN{Value: "1"}},
+			wantErr: `entity u1 is in state "Lost": unknown state`,
+		},
+		{
+			name:    "no state",
+			item:    map[string]types.AttributeValue{"version": &types.AttributeValueMemberN{Value: "1"}},
+			wantErr: "smdynamo: entity u1 has no state",
+		},
+		{
+			name:    "no version",
+			item:    map[string]types.AttributeValue{"state": &types.AttributeValueMemberS{Value: "Active"}},
+			wantErr: "smdynamo: entity u1 has no version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDynamo{table: "signups", items: map[string]map[string]types.AttributeValue{"u1": tt.item}}
+			store := smdynamo.NewStore(db, "signups", newMachine())
+			if _, _, err := store.Load(context.Background(), "u1"); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
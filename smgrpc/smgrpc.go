@@ -0,0 +1,257 @@
+// Package smgrpc serves a state machine over gRPC, so services written in
+// any language can drive workflows owned by a Go service. The service is
+// defined in smgrpcpb/statemachine.proto; Server implements it for a
+// StateMachine, loading and saving entities through a Store:
+//
+//	srv := grpc.NewServer()
+//	smgrpcpb.RegisterStateMachineServiceServer(srv, smgrpc.NewServer(NewOrderStateMachine(), orderStore))
+//
+// Failures are reported with gRPC status codes: NotFound for an unknown
+// entity, InvalidArgument for an unknown event, FailedPrecondition for an
+// event that cannot be processed from the entity's state or a transition
+// vetoed by a BeforeTransition hook, PermissionDenied for a transition its
+// principal may not make, Aborted when the Store refuses a save with
+// statemachine.ErrConcurrentModification, and Canceled or DeadlineExceeded
+// when the call's context ends first. Other errors from the Store or
+// actions are logged and answered with Internal, without their details.
+package smgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smgrpc/smgrpcpb"
+)
+
+// ErrNotFound is returned by a Store for an entity that does not exist.
+// statemachine.ErrNotFound is treated the same way.
+var ErrNotFound = errors.New("entity not found")
+
+// Store loads and saves the state of entities by ID
+type Store[S statemachine.State] interface {
+	// Load returns the current state of an entity, or ErrNotFound
+	Load(ctx context.Context, id string) (S, error)
+
+	// Save records that an entity moved from one state to another. It is
+	// given the state the transition started from so it can refuse the
+	// update if the entity changed in the meantime.
+	Save(ctx context.Context, id string, from, to S) error
+}
+
+// changeBuffer is how many changes a StreamChanges client may fall behind
+// before it is disconnected
+const changeBuffer = 64
+
+// Server implements smgrpcpb.StateMachineServiceServer for a StateMachine
+type Server[S statemachine.State, E statemachine.Event] struct {
+	smgrpcpb.UnimplementedStateMachineServiceServer
+
+	// ErrorLog receives the errors answered with Internal without their
+	// details. If nil, the log package's standard logger is used.
+	ErrorLog *log.Logger
+
+	sm    *statemachine.StateMachine[S, E]
+	store Store[S]
+
+	mu       sync.Mutex
+	watchers map[*watcher]bool
+}
+
+// watcher is a StreamChanges client
+type watcher struct {
+	entityID string
+	changes  chan *smgrpcpb.StateChange
+
+	// behind is closed when the client falls too far behind
+	behind chan struct{}
+}
+
+// NewServer returns a server for sm, keeping entity states in store
+func NewServer[S statemachine.State, E statemachine.Event](sm *statemachine.StateMachine[S, E], store Store[S]) *Server[S, E] {
+	return &Server[S, E]{sm: sm, store: store, watchers: make(map[*watcher]bool)}
+}
+
+// Transition fires an event for an entity and saves its new state. The
+// request's payload, if any, is passed to guards and actions as a []byte
+// payload, and the entity's ID is available from statemachine.EntityIDFrom.
+func (s *Server[S, E]) Transition(ctx context.Context, req *smgrpcpb.TransitionRequest) (*smgrpcpb.TransitionResponse, error) {
+	event, err := s.event(req.GetEvent())
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.load(ctx, req.GetEntityId())
+	if err != nil {
+		return nil, err
+	}
+
+	fireCtx := statemachine.WithEntityID(ctx, req.GetEntityId())
+	if len(req.GetPayload()) > 0 {
+		fireCtx = statemachine.WithPayload(fireCtx, req.GetPayload())
+	}
+	to, err := s.sm.TransitionContext(fireCtx, from, event)
+	if errors.Is(err, statemachine.ErrInvalidTransition) || errors.Is(err, statemachine.ErrVetoed) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if errors.Is(err, statemachine.ErrNotAuthorized) {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err != nil {
+		return nil, s.internal(fmt.Errorf("entity %s: %w", req.GetEntityId(), err))
+	}
+	err = s.store.Save(ctx, req.GetEntityId(), from, to)
+	if errors.Is(err, statemachine.ErrConcurrentModification) {
+		return nil, status.Errorf(codes.Aborted, "entity %s was modified concurrently", req.GetEntityId())
+	}
+	if err != nil {
+		return nil, s.internal(fmt.Errorf("cannot save entity %s: %w", req.GetEntityId(), err))
+	}
+
+	s.publish(&smgrpcpb.StateChange{
+		EntityId: req.GetEntityId(),
+		From:     statemachine.Name(from),
+		Event:    statemachine.Name(event),
+		To:       statemachine.Name(to),
+		Time:     timestamppb.New(s.sm.Clock().Now()),
+	})
+	return &smgrpcpb.TransitionResponse{
+		EntityId: req.GetEntityId(),
+		From:     statemachine.Name(from),
+		Event:    statemachine.Name(event),
+		State:    statemachine.Name(to),
+	}, nil
+}
+
+// CanTransition reports whether an event is valid for an entity's state,
+// without running guards
+func (s *Server[S, E]) CanTransition(ctx context.Context, req *smgrpcpb.CanTransitionRequest) (*smgrpcpb.CanTransitionResponse, error) {
+	event, err := s.event(req.GetEvent())
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.load(ctx, req.GetEntityId())
+	if err != nil {
+		return nil, err
+	}
+	return &smgrpcpb.CanTransitionResponse{
+		State:   statemachine.Name(state),
+		Allowed: s.sm.CanTransition(state, event),
+	}, nil
+}
+
+// GetValidEvents lists the events valid for an entity's state, sorted by
+// name
+func (s *Server[S, E]) GetValidEvents(ctx context.Context, req *smgrpcpb.GetValidEventsRequest) (*smgrpcpb.GetValidEventsResponse, error) {
+	state, err := s.load(ctx, req.GetEntityId())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &smgrpcpb.GetValidEventsResponse{EntityId: req.GetEntityId(), State: statemachine.Name(state)}
+	for _, e := range s.sm.GetValidEvents(state) {
+		to, _ := s.sm.GetNextState(state, e)
+		ve := &smgrpcpb.ValidEvent{Event: statemachine.Name(e), To: statemachine.Name(to)}
+		if m, ok := s.sm.GetTransitionMeta(state, e); ok {
+			ve.Label = m.Label
+		}
+		resp.Events = append(resp.Events, ve)
+	}
+	return resp, nil
+}
+
+// StreamChanges sends every transition made through the server until the
+// client goes away. Transitions made elsewhere, such as by another server
+// sharing the store, are not seen. A client that falls too far behind is
+// disconnected with ResourceExhausted rather than holding up transitions.
+func (s *Server[S, E]) StreamChanges(req *smgrpcpb.StreamChangesRequest, stream grpc.ServerStreamingServer[smgrpcpb.StateChange]) error {
+	w := &watcher{
+		entityID: req.GetEntityId(),
+		changes:  make(chan *smgrpcpb.StateChange, changeBuffer),
+		behind:   make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.watchers[w] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers, w)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-w.behind:
+			return status.Error(codes.ResourceExhausted, "client fell too far behind the changes")
+		case change := <-w.changes:
+			if err := stream.Send(change); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// publish sends a change to every client watching its entity
+func (s *Server[S, E]) publish(change *smgrpcpb.StateChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for w := range s.watchers {
+		if w.entityID != "" && w.entityID != change.GetEntityId() {
+			continue
+		}
+		select {
+		case w.changes <- change:
+		default:
+			close(w.behind)
+			delete(s.watchers, w)
+		}
+	}
+}
+
+// load returns the state of an entity as a gRPC status error if it cannot
+func (s *Server[S, E]) load(ctx context.Context, id string) (S, error) {
+	state, err := s.store.Load(ctx, id)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, statemachine.ErrNotFound) {
+		return state, status.Errorf(codes.NotFound, "entity %s not found", id)
+	}
+	if err != nil {
+		return state, s.internal(fmt.Errorf("cannot load entity %s: %w", id, err))
+	}
+	return state, nil
+}
+
+// internal returns the status for an error whose details are not the
+// client's business: Canceled or DeadlineExceeded if the context ended, or
+// else Internal, once the error is logged
+func (s *Server[S, E]) internal(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf("smgrpc: %v", err)
+	} else {
+		log.Printf("smgrpc: %v", err)
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+// event finds the machine's event with the given name
+func (s *Server[S, E]) event(name string) (E, error) {
+	for _, e := range s.sm.GetAllEvents() {
+		if statemachine.Name(e) == name {
+			return e, nil
+		}
+	}
+	var zero E
+	return zero, status.Error(codes.InvalidArgument, fmt.Sprintf("unknown event %q", name))
+}
@@ -0,0 +1,347 @@
+package smgrpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smgrpc"
+	"github.com/richardbowden/statemachine/smgrpc/smgrpcpb"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+// store is an in-memory Store
+type store struct {
+	mu      sync.Mutex
+	states  map[string]state
+	saveErr error
+}
+
+func (s *store) Load(ctx context.Context, id string) (state, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[id]
+	if !ok {
+		return "", smgrpc.ErrNotFound
+	}
+	return st, nil
+}
+
+func (s *store) Save(ctx context.Context, id string, from, to state) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	s.states[id] = to
+	return nil
+}
+
+// clock is a statemachine.Clock stopped at a fixed time
+type clock struct {
+	now time.Time
+}
+
+func (c clock) Now() time.Time { return c.now }
+
+func (c clock) AfterFunc(d time.Duration, f func()) statemachine.Timer { return time.AfterFunc(d, f) }
+
+var epoch = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newClient(t *testing.T) (smgrpcpb.StateMachineServiceClient, *store) {
+	t.Helper()
+	sm := statemachine.NewStateMachine[state, event](statemachine.WithClock(clock{epoch}))
+	sm.AddTransition("Pending", "Pay", "Paid",
+		statemachine.WithGuard(statemachine.PayloadGuard(func(ctx context.Context, from state, e event, payload []byte) bool {
+			return string(payload) == "card"
+		})),
+		statemachine.WithMeta[state, event](statemachine.Metadata{Label: "Pay now"}))
+	sm.AddTransition("Pending", "Cancel", "Cancelled")
+	sm.AddTransition("Paid", "Ship", "Shipped")
+	sm.AddTransition("Paid", "Touch", "Paid")
+
+	st := &store{states: map[string]state{"1": "Pending", "2": "Paid"}}
+	return dial(t, smgrpc.NewServer(sm, st)), st
+}
+
+// dial serves s over an in-memory connection and returns a client for it
+func dial(t *testing.T, s smgrpcpb.StateMachineServiceServer) smgrpcpb.StateMachineServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	smgrpcpb.RegisterStateMachineServiceServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return smgrpcpb.NewStateMachineServiceClient(conn)
+}
+
+func TestServer_Transition(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       *smgrpcpb.TransitionRequest
+		wantCode  codes.Code
+		wantState string
+	}{
+		{name: "valid", req: &smgrpcpb.TransitionRequest{EntityId: "1", Event: "Cancel"}, wantState: "Cancelled"},
+		{name: "payload", req: &smgrpcpb.TransitionRequest{EntityId: "1", Event: "Pay", Payload: []byte("card")}, wantState: "Paid"},
+		{name: "guard rejected", req: &smgrpcpb.TransitionRequest{EntityId: "1", Event: "Pay"}, wantCode: codes.FailedPrecondition},
+		{name: "invalid transition", req: &smgrpcpb.TransitionRequest{EntityId: "1", Event: "Ship"}, wantCode: codes.FailedPrecondition},
+		{name: "unknown event", req: &smgrpcpb.TransitionRequest{EntityId: "1", Event: "Explode"}, wantCode: codes.InvalidArgument},
+		{name: "unknown entity", req: &smgrpcpb.TransitionRequest{EntityId: "9", Event: "Cancel"}, wantCode: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, st := newClient(t)
+
+			resp, err := client.Transition(context.Background(), tt.req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("Transition() code = %v, want %v (%v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != codes.OK {
+				return
+			}
+			if resp.GetState() != tt.wantState || resp.GetFrom() != "Pending" {
+				t.Errorf("Transition() = %v, want Pending to %s", resp, tt.wantState)
+			}
+			if got, _ := st.Load(context.Background(), "1"); string(got) != tt.wantState {
+				t.Errorf("saved state = %s, want %s", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestServer_TransitionErrors(t *testing.T) {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Paid", "Refund", "Refunded", statemachine.RequirePermission[state, event]("refund"))
+	sm.AddTransition("Paid", "Ship", "Shipped")
+	sm.AddTransition("Paid", "Pack", "Packed")
+	sm.BeforeTransition(func(ctx context.Context, from state, e event, to state) error {
+		if to == "Shipped" {
+			return errors.New("address not verified")
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name       string
+		req        *smgrpcpb.TransitionRequest
+		saveErr    error
+		wantCode   codes.Code
+		wantLogged string
+	}{
+		{name: "not authorized", req: &smgrpcpb.TransitionRequest{EntityId: "1", Event: "Refund"}, wantCode: codes.PermissionDenied},
+		{name: "vetoed", req: &smgrpcpb.TransitionRequest{EntityId: "1", Event: "Ship"}, wantCode: codes.FailedPrecondition},
+		{
+			name:     "concurrent modification",
+			req:      &smgrpcpb.TransitionRequest{EntityId: "1", Event: "Pack"},
+			saveErr:  fmt.Errorf("order 1: %w", statemachine.ErrConcurrentModification),
+			wantCode: codes.Aborted,
+		},
+		{
+			name:       "save fails",
+			req:        &smgrpcpb.TransitionRequest{EntityId: "1", Event: "Pack"},
+			saveErr:    errors.New("database is down"),
+			wantCode:   codes.Internal,
+			wantLogged: "smgrpc: cannot save entity 1: database is down",
+		},
+		{
+			name:     "save times out",
+			req:      &smgrpcpb.TransitionRequest{EntityId: "1", Event: "Pack"},
+			saveErr:  fmt.Errorf("update order 1: %w", context.DeadlineExceeded),
+			wantCode: codes.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &store{states: map[string]state{"1": "Paid"}, saveErr: tt.saveErr}
+			srv := smgrpc.NewServer(sm, st)
+			var logged bytes.Buffer
+			srv.ErrorLog = log.New(&logged, "", 0)
+			client := dial(t, srv)
+
+			_, err := client.Transition(context.Background(), tt.req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("Transition() code = %v, want %v (%v)", code, tt.wantCode, err)
+			}
+			if tt.saveErr != nil && strings.Contains(status.Convert(err).Message(), "database") {
+				t.Errorf("Transition() error = %v, want the store's error kept from the client", err)
+			}
+			if !strings.Contains(logged.String(), tt.wantLogged) {
+				t.Errorf("logged %q, want %q", logged.String(), tt.wantLogged)
+			}
+			if got, _ := st.Load(context.Background(), "1"); got != "Paid" {
+				t.Errorf("saved state = %s, want Paid", got)
+			}
+		})
+	}
+}
+
+// missing is a Store holding no entities, reporting them as a StateStore
+// would
+type missing struct{}
+
+func (missing) Load(ctx context.Context, id string) (state, error) {
+	return "", fmt.Errorf("order %s: %w", id, statemachine.ErrNotFound)
+}
+
+func (missing) Save(ctx context.Context, id string, from, to state) error {
+	return statemachine.ErrNotFound
+}
+
+func TestServer_StateStoreNotFound(t *testing.T) {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Pending", "Cancel", "Cancelled")
+	client := dial(t, smgrpc.NewServer(sm, missing{}))
+
+	_, err := client.Transition(context.Background(), &smgrpcpb.TransitionRequest{EntityId: "9", Event: "Cancel"})
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("Transition() code = %v, want %v (%v)", code, codes.NotFound, err)
+	}
+}
+
+func TestServer_Queries(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := context.Background()
+
+	can, err := client.CanTransition(ctx, &smgrpcpb.CanTransitionRequest{EntityId: "2", Event: "Ship"})
+	if err != nil || !can.GetAllowed() || can.GetState() != "Paid" {
+		t.Errorf("CanTransition(2, Ship) = %v, %v, want allowed from Paid", can, err)
+	}
+	can, err = client.CanTransition(ctx, &smgrpcpb.CanTransitionRequest{EntityId: "2", Event: "Pay"})
+	if err != nil || can.GetAllowed() {
+		t.Errorf("CanTransition(2, Pay) = %v, %v, want not allowed", can, err)
+	}
+
+	valid, err := client.GetValidEvents(ctx, &smgrpcpb.GetValidEventsRequest{EntityId: "1"})
+	if err != nil {
+		t.Fatalf("GetValidEvents() error = %v", err)
+	}
+	var got []string
+	for _, e := range valid.GetEvents() {
+		got = append(got, e.GetEvent()+"->"+e.GetTo()+":"+e.GetLabel())
+	}
+	if len(got) != 2 || got[0] != "Cancel->Cancelled:" || got[1] != "Pay->Paid:Pay now" {
+		t.Errorf("GetValidEvents() = %v", got)
+	}
+
+	if _, err := client.GetValidEvents(ctx, &smgrpcpb.GetValidEventsRequest{EntityId: "9"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetValidEvents(9) error = %v, want NotFound", err)
+	}
+}
+
+func TestServer_StreamChanges(t *testing.T) {
+	client, _ := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	all := streamChanges(t, ctx, client, "")
+	one := streamChanges(t, ctx, client, "2")
+
+	// streams start once the server has handled the request, which the
+	// client cannot see, so touch entity 2 until both have heard of it
+	for heardAll, heardOne := false, false; !heardAll || !heardOne; {
+		if _, err := client.Transition(ctx, &smgrpcpb.TransitionRequest{EntityId: "2", Event: "Touch"}); err != nil {
+			t.Fatal(err)
+		}
+		timeout := time.After(20 * time.Millisecond)
+	drain:
+		for {
+			select {
+			case <-all:
+				heardAll = true
+			case <-one:
+				heardOne = true
+			case <-timeout:
+				break drain
+			}
+		}
+	}
+
+	for _, req := range []*smgrpcpb.TransitionRequest{
+		{EntityId: "1", Event: "Cancel"},
+		{EntityId: "2", Event: "Ship"},
+	} {
+		if _, err := client.Transition(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := untilShipped(t, all), "1:Pending-Cancel->Cancelled 2:Paid-Ship->Shipped"; got != want {
+		t.Errorf("unfiltered stream = %s, want %s", got, want)
+	}
+	if got, want := untilShipped(t, one), "2:Paid-Ship->Shipped"; got != want {
+		t.Errorf("stream of entity 2 = %s, want %s", got, want)
+	}
+}
+
+// streamChanges opens a stream and returns a channel of what it receives
+func streamChanges(t *testing.T, ctx context.Context, client smgrpcpb.StateMachineServiceClient, id string) <-chan *smgrpcpb.StateChange {
+	t.Helper()
+	stream, err := client.StreamChanges(ctx, &smgrpcpb.StreamChangesRequest{EntityId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan *smgrpcpb.StateChange, 100)
+	go func() {
+		defer close(changes)
+		for {
+			change, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			changes <- change
+		}
+	}()
+	return changes
+}
+
+// untilShipped describes the changes received, other than touches, up to
+// entity 2 being shipped
+func untilShipped(t *testing.T, changes <-chan *smgrpcpb.StateChange) string {
+	t.Helper()
+	var got []string
+	for change := range changes {
+		if change.GetEvent() == "Touch" {
+			continue
+		}
+		if !change.GetTime().AsTime().Equal(epoch) {
+			t.Errorf("change %v not at the machine's time", change)
+		}
+		got = append(got, change.GetEntityId()+":"+change.GetFrom()+"-"+change.GetEvent()+"->"+change.GetTo())
+		if change.GetEvent() == "Ship" {
+			break
+		}
+	}
+	return strings.Join(got, " ")
+}
@@ -0,0 +1,6 @@
+// Package smgrpcpb holds the protobuf messages and gRPC service generated
+// from statemachine.proto, for clients in any language to use. Package
+// smgrpc implements the service in Go.
+package smgrpcpb
+
+//go:generate buf generate
@@ -0,0 +1,656 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: statemachine.proto
+
+package smgrpcpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type TransitionRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	EntityId string                 `protobuf:"bytes,1,opt,name=entity_id,json=entityId,proto3" json:"entity_id,omitempty"`
+	Event    string                 `protobuf:"bytes,2,opt,name=event,proto3" json:"event,omitempty"`
+	// payload is passed to guards and actions as the event's payload
+	Payload       []byte `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransitionRequest) Reset() {
+	*x = TransitionRequest{}
+	mi := &file_statemachine_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransitionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransitionRequest) ProtoMessage() {}
+
+func (x *TransitionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statemachine_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransitionRequest.ProtoReflect.Descriptor instead.
+func (*TransitionRequest) Descriptor() ([]byte, []int) {
+	return file_statemachine_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *TransitionRequest) GetEntityId() string {
+	if x != nil {
+		return x.EntityId
+	}
+	return ""
+}
+
+func (x *TransitionRequest) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+func (x *TransitionRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+type TransitionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntityId      string                 `protobuf:"bytes,1,opt,name=entity_id,json=entityId,proto3" json:"entity_id,omitempty"`
+	From          string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	Event         string                 `protobuf:"bytes,3,opt,name=event,proto3" json:"event,omitempty"`
+	State         string                 `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransitionResponse) Reset() {
+	*x = TransitionResponse{}
+	mi := &file_statemachine_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransitionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransitionResponse) ProtoMessage() {}
+
+func (x *TransitionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_statemachine_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransitionResponse.ProtoReflect.Descriptor instead.
+func (*TransitionResponse) Descriptor() ([]byte, []int) {
+	return file_statemachine_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TransitionResponse) GetEntityId() string {
+	if x != nil {
+		return x.EntityId
+	}
+	return ""
+}
+
+func (x *TransitionResponse) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *TransitionResponse) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+func (x *TransitionResponse) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+type CanTransitionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntityId      string                 `protobuf:"bytes,1,opt,name=entity_id,json=entityId,proto3" json:"entity_id,omitempty"`
+	Event         string                 `protobuf:"bytes,2,opt,name=event,proto3" json:"event,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CanTransitionRequest) Reset() {
+	*x = CanTransitionRequest{}
+	mi := &file_statemachine_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CanTransitionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CanTransitionRequest) ProtoMessage() {}
+
+func (x *CanTransitionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statemachine_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CanTransitionRequest.ProtoReflect.Descriptor instead.
+func (*CanTransitionRequest) Descriptor() ([]byte, []int) {
+	return file_statemachine_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CanTransitionRequest) GetEntityId() string {
+	if x != nil {
+		return x.EntityId
+	}
+	return ""
+}
+
+func (x *CanTransitionRequest) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+type CanTransitionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	State         string                 `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Allowed       bool                   `protobuf:"varint,2,opt,name=allowed,proto3" json:"allowed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CanTransitionResponse) Reset() {
+	*x = CanTransitionResponse{}
+	mi := &file_statemachine_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CanTransitionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CanTransitionResponse) ProtoMessage() {}
+
+func (x *CanTransitionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_statemachine_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CanTransitionResponse.ProtoReflect.Descriptor instead.
+func (*CanTransitionResponse) Descriptor() ([]byte, []int) {
+	return file_statemachine_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CanTransitionResponse) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *CanTransitionResponse) GetAllowed() bool {
+	if x != nil {
+		return x.Allowed
+	}
+	return false
+}
+
+type GetValidEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntityId      string                 `protobuf:"bytes,1,opt,name=entity_id,json=entityId,proto3" json:"entity_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetValidEventsRequest) Reset() {
+	*x = GetValidEventsRequest{}
+	mi := &file_statemachine_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetValidEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetValidEventsRequest) ProtoMessage() {}
+
+func (x *GetValidEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statemachine_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetValidEventsRequest.ProtoReflect.Descriptor instead.
+func (*GetValidEventsRequest) Descriptor() ([]byte, []int) {
+	return file_statemachine_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetValidEventsRequest) GetEntityId() string {
+	if x != nil {
+		return x.EntityId
+	}
+	return ""
+}
+
+type GetValidEventsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntityId      string                 `protobuf:"bytes,1,opt,name=entity_id,json=entityId,proto3" json:"entity_id,omitempty"`
+	State         string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Events        []*ValidEvent          `protobuf:"bytes,3,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetValidEventsResponse) Reset() {
+	*x = GetValidEventsResponse{}
+	mi := &file_statemachine_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetValidEventsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetValidEventsResponse) ProtoMessage() {}
+
+func (x *GetValidEventsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_statemachine_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetValidEventsResponse.ProtoReflect.Descriptor instead.
+func (*GetValidEventsResponse) Descriptor() ([]byte, []int) {
+	return file_statemachine_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetValidEventsResponse) GetEntityId() string {
+	if x != nil {
+		return x.EntityId
+	}
+	return ""
+}
+
+func (x *GetValidEventsResponse) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *GetValidEventsResponse) GetEvents() []*ValidEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+type ValidEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Event string                 `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	// to is the state the event leads to, or the first tried where guarded
+	// transitions compete for the event
+	To            string `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	Label         string `protobuf:"bytes,3,opt,name=label,proto3" json:"label,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidEvent) Reset() {
+	*x = ValidEvent{}
+	mi := &file_statemachine_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidEvent) ProtoMessage() {}
+
+func (x *ValidEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_statemachine_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidEvent.ProtoReflect.Descriptor instead.
+func (*ValidEvent) Descriptor() ([]byte, []int) {
+	return file_statemachine_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ValidEvent) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+func (x *ValidEvent) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *ValidEvent) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+type StreamChangesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// entity_id limits the stream to one entity; empty streams every entity
+	EntityId      string `protobuf:"bytes,1,opt,name=entity_id,json=entityId,proto3" json:"entity_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamChangesRequest) Reset() {
+	*x = StreamChangesRequest{}
+	mi := &file_statemachine_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamChangesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamChangesRequest) ProtoMessage() {}
+
+func (x *StreamChangesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_statemachine_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamChangesRequest.ProtoReflect.Descriptor instead.
+func (*StreamChangesRequest) Descriptor() ([]byte, []int) {
+	return file_statemachine_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StreamChangesRequest) GetEntityId() string {
+	if x != nil {
+		return x.EntityId
+	}
+	return ""
+}
+
+type StateChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EntityId      string                 `protobuf:"bytes,1,opt,name=entity_id,json=entityId,proto3" json:"entity_id,omitempty"`
+	From          string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	Event         string                 `protobuf:"bytes,3,opt,name=event,proto3" json:"event,omitempty"`
+	To            string                 `protobuf:"bytes,4,opt,name=to,proto3" json:"to,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StateChange) Reset() {
+	*x = StateChange{}
+	mi := &file_statemachine_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StateChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StateChange) ProtoMessage() {}
+
+func (x *StateChange) ProtoReflect() protoreflect.Message {
+	mi := &file_statemachine_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StateChange.ProtoReflect.Descriptor instead.
+func (*StateChange) Descriptor() ([]byte, []int) {
+	return file_statemachine_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StateChange) GetEntityId() string {
+	if x != nil {
+		return x.EntityId
+	}
+	return ""
+}
+
+func (x *StateChange) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *StateChange) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+func (x *StateChange) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *StateChange) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+var File_statemachine_proto protoreflect.FileDescriptor
+
+const file_statemachine_proto_rawDesc = "" +
+	"\n" +
+	"\x12statemachine.proto\x12\x0fstatemachine.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"`\n" +
+	"\x11TransitionRequest\x12\x1b\n" +
+	"\tentity_id\x18\x01 \x01(\tR\bentityId\x12\x14\n" +
+	"\x05event\x18\x02 \x01(\tR\x05event\x12\x18\n" +
+	"\apayload\x18\x03 \x01(\fR\apayload\"q\n" +
+	"\x12TransitionResponse\x12\x1b\n" +
+	"\tentity_id\x18\x01 \x01(\tR\bentityId\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\tR\x04from\x12\x14\n" +
+	"\x05event\x18\x03 \x01(\tR\x05event\x12\x14\n" +
+	"\x05state\x18\x04 \x01(\tR\x05state\"I\n" +
+	"\x14CanTransitionRequest\x12\x1b\n" +
+	"\tentity_id\x18\x01 \x01(\tR\bentityId\x12\x14\n" +
+	"\x05event\x18\x02 \x01(\tR\x05event\"G\n" +
+	"\x15CanTransitionResponse\x12\x14\n" +
+	"\x05state\x18\x01 \x01(\tR\x05state\x12\x18\n" +
+	"\aallowed\x18\x02 \x01(\bR\aallowed\"4\n" +
+	"\x15GetValidEventsRequest\x12\x1b\n" +
+	"\tentity_id\x18\x01 \x01(\tR\bentityId\"\x80\x01\n" +
+	"\x16GetValidEventsResponse\x12\x1b\n" +
+	"\tentity_id\x18\x01 \x01(\tR\bentityId\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x123\n" +
+	"\x06events\x18\x03 \x03(\v2\x1b.statemachine.v1.ValidEventR\x06events\"H\n" +
+	"\n" +
+	"ValidEvent\x12\x14\n" +
+	"\x05event\x18\x01 \x01(\tR\x05event\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to\x12\x14\n" +
+	"\x05label\x18\x03 \x01(\tR\x05label\"3\n" +
+	"\x14StreamChangesRequest\x12\x1b\n" +
+	"\tentity_id\x18\x01 \x01(\tR\bentityId\"\x94\x01\n" +
+	"\vStateChange\x12\x1b\n" +
+	"\tentity_id\x18\x01 \x01(\tR\bentityId\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\tR\x04from\x12\x14\n" +
+	"\x05event\x18\x03 \x01(\tR\x05event\x12\x0e\n" +
+	"\x02to\x18\x04 \x01(\tR\x02to\x12.\n" +
+	"\x04time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x04time2\x87\x03\n" +
+	"\x13StateMachineService\x12U\n" +
+	"\n" +
+	"Transition\x12\".statemachine.v1.TransitionRequest\x1a#.statemachine.v1.TransitionResponse\x12^\n" +
+	"\rCanTransition\x12%.statemachine.v1.CanTransitionRequest\x1a&.statemachine.v1.CanTransitionResponse\x12a\n" +
+	"\x0eGetValidEvents\x12&.statemachine.v1.GetValidEventsRequest\x1a'.statemachine.v1.GetValidEventsResponse\x12V\n" +
+	"\rStreamChanges\x12%.statemachine.v1.StreamChangesRequest\x1a\x1c.statemachine.v1.StateChange0\x01B7Z5github.com/richardbowden/statemachine/smgrpc/smgrpcpbb\x06proto3"
+
+var (
+	file_statemachine_proto_rawDescOnce sync.Once
+	file_statemachine_proto_rawDescData []byte
+)
+
+func file_statemachine_proto_rawDescGZIP() []byte {
+	file_statemachine_proto_rawDescOnce.Do(func() {
+		file_statemachine_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_statemachine_proto_rawDesc), len(file_statemachine_proto_rawDesc)))
+	})
+	return file_statemachine_proto_rawDescData
+}
+
+var file_statemachine_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_statemachine_proto_goTypes = []any{
+	(*TransitionRequest)(nil),      // 0: statemachine.v1.TransitionRequest
+	(*TransitionResponse)(nil),     // 1: statemachine.v1.TransitionResponse
+	(*CanTransitionRequest)(nil),   // 2: statemachine.v1.CanTransitionRequest
+	(*CanTransitionResponse)(nil),  // 3: statemachine.v1.CanTransitionResponse
+	(*GetValidEventsRequest)(nil),  // 4: statemachine.v1.GetValidEventsRequest
+	(*GetValidEventsResponse)(nil), // 5: statemachine.v1.GetValidEventsResponse
+	(*ValidEvent)(nil),             // 6: statemachine.v1.ValidEvent
+	(*StreamChangesRequest)(nil),   // 7: statemachine.v1.StreamChangesRequest
+	(*StateChange)(nil),            // 8: statemachine.v1.StateChange
+	(*timestamppb.Timestamp)(nil),  // 9: google.protobuf.Timestamp
+}
+var file_statemachine_proto_depIdxs = []int32{
+	6, // 0: statemachine.v1.GetValidEventsResponse.events:type_name -> statemachine.v1.ValidEvent
+	9, // 1: statemachine.v1.StateChange.time:type_name -> google.protobuf.Timestamp
+	0, // 2: statemachine.v1.StateMachineService.Transition:input_type -> statemachine.v1.TransitionRequest
+	2, // 3: statemachine.v1.StateMachineService.CanTransition:input_type -> statemachine.v1.CanTransitionRequest
+	4, // 4: statemachine.v1.StateMachineService.GetValidEvents:input_type -> statemachine.v1.GetValidEventsRequest
+	7, // 5: statemachine.v1.StateMachineService.StreamChanges:input_type -> statemachine.v1.StreamChangesRequest
+	1, // 6: statemachine.v1.StateMachineService.Transition:output_type -> statemachine.v1.TransitionResponse
+	3, // 7: statemachine.v1.StateMachineService.CanTransition:output_type -> statemachine.v1.CanTransitionResponse
+	5, // 8: statemachine.v1.StateMachineService.GetValidEvents:output_type -> statemachine.v1.GetValidEventsResponse
+	8, // 9: statemachine.v1.StateMachineService.StreamChanges:output_type -> statemachine.v1.StateChange
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_statemachine_proto_init() }
+func file_statemachine_proto_init() {
+	if File_statemachine_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_statemachine_proto_rawDesc), len(file_statemachine_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_statemachine_proto_goTypes,
+		DependencyIndexes: file_statemachine_proto_depIdxs,
+		MessageInfos:      file_statemachine_proto_msgTypes,
+	}.Build()
+	File_statemachine_proto = out.File
+	file_statemachine_proto_goTypes = nil
+	file_statemachine_proto_depIdxs = nil
+}
@@ -0,0 +1,259 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: statemachine.proto
+
+package smgrpcpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	StateMachineService_Transition_FullMethodName     = "/statemachine.v1.StateMachineService/Transition"
+	StateMachineService_CanTransition_FullMethodName  = "/statemachine.v1.StateMachineService/CanTransition"
+	StateMachineService_GetValidEvents_FullMethodName = "/statemachine.v1.StateMachineService/GetValidEvents"
+	StateMachineService_StreamChanges_FullMethodName  = "/statemachine.v1.StateMachineService/StreamChanges"
+)
+
+// StateMachineServiceClient is the client API for StateMachineService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// StateMachineService drives the entities of one state machine. Entities
+// are identified by an ID the server's store understands, and states and
+// events by name.
+type StateMachineServiceClient interface {
+	// Transition fires an event for an entity and saves its new state
+	Transition(ctx context.Context, in *TransitionRequest, opts ...grpc.CallOption) (*TransitionResponse, error)
+	// CanTransition reports whether an event is valid for an entity's state,
+	// without running guards
+	CanTransition(ctx context.Context, in *CanTransitionRequest, opts ...grpc.CallOption) (*CanTransitionResponse, error)
+	// GetValidEvents lists the events valid for an entity's state
+	GetValidEvents(ctx context.Context, in *GetValidEventsRequest, opts ...grpc.CallOption) (*GetValidEventsResponse, error)
+	// StreamChanges sends every transition made through the server from now
+	// on, optionally only those of one entity
+	StreamChanges(ctx context.Context, in *StreamChangesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StateChange], error)
+}
+
+type stateMachineServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStateMachineServiceClient(cc grpc.ClientConnInterface) StateMachineServiceClient {
+	return &stateMachineServiceClient{cc}
+}
+
+func (c *stateMachineServiceClient) Transition(ctx context.Context, in *TransitionRequest, opts ...grpc.CallOption) (*TransitionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransitionResponse)
+	err := c.cc.Invoke(ctx, StateMachineService_Transition_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stateMachineServiceClient) CanTransition(ctx context.Context, in *CanTransitionRequest, opts ...grpc.CallOption) (*CanTransitionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CanTransitionResponse)
+	err := c.cc.Invoke(ctx, StateMachineService_CanTransition_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stateMachineServiceClient) GetValidEvents(ctx context.Context, in *GetValidEventsRequest, opts ...grpc.CallOption) (*GetValidEventsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetValidEventsResponse)
+	err := c.cc.Invoke(ctx, StateMachineService_GetValidEvents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stateMachineServiceClient) StreamChanges(ctx context.Context, in *StreamChangesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StateChange], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StateMachineService_ServiceDesc.Streams[0], StateMachineService_StreamChanges_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamChangesRequest, StateChange]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StateMachineService_StreamChangesClient = grpc.ServerStreamingClient[StateChange]
+
+// StateMachineServiceServer is the server API for StateMachineService service.
+// All implementations must embed UnimplementedStateMachineServiceServer
+// for forward compatibility.
+//
+// StateMachineService drives the entities of one state machine. Entities
+// are identified by an ID the server's store understands, and states and
+// events by name.
+type StateMachineServiceServer interface {
+	// Transition fires an event for an entity and saves its new state
+	Transition(context.Context, *TransitionRequest) (*TransitionResponse, error)
+	// CanTransition reports whether an event is valid for an entity's state,
+	// without running guards
+	CanTransition(context.Context, *CanTransitionRequest) (*CanTransitionResponse, error)
+	// GetValidEvents lists the events valid for an entity's state
+	GetValidEvents(context.Context, *GetValidEventsRequest) (*GetValidEventsResponse, error)
+	// StreamChanges sends every transition made through the server from now
+	// on, optionally only those of one entity
+	StreamChanges(*StreamChangesRequest, grpc.ServerStreamingServer[StateChange]) error
+	mustEmbedUnimplementedStateMachineServiceServer()
+}
+
+// UnimplementedStateMachineServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedStateMachineServiceServer struct{}
+
+func (UnimplementedStateMachineServiceServer) Transition(context.Context, *TransitionRequest) (*TransitionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Transition not implemented")
+}
+func (UnimplementedStateMachineServiceServer) CanTransition(context.Context, *CanTransitionRequest) (*CanTransitionResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CanTransition not implemented")
+}
+func (UnimplementedStateMachineServiceServer) GetValidEvents(context.Context, *GetValidEventsRequest) (*GetValidEventsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetValidEvents not implemented")
+}
+func (UnimplementedStateMachineServiceServer) StreamChanges(*StreamChangesRequest, grpc.ServerStreamingServer[StateChange]) error {
+	return status.Error(codes.Unimplemented, "method StreamChanges not implemented")
+}
+func (UnimplementedStateMachineServiceServer) mustEmbedUnimplementedStateMachineServiceServer() {}
+func (UnimplementedStateMachineServiceServer) testEmbeddedByValue()                             {}
+
+// UnsafeStateMachineServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StateMachineServiceServer will
+// result in compilation errors.
+type UnsafeStateMachineServiceServer interface {
+	mustEmbedUnimplementedStateMachineServiceServer()
+}
+
+func RegisterStateMachineServiceServer(s grpc.ServiceRegistrar, srv StateMachineServiceServer) {
+	// If the following call panics, it indicates UnimplementedStateMachineServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&StateMachineService_ServiceDesc, srv)
+}
+
+func _StateMachineService_Transition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransitionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StateMachineServiceServer).Transition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StateMachineService_Transition_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StateMachineServiceServer).Transition(ctx, req.(*TransitionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StateMachineService_CanTransition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CanTransitionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StateMachineServiceServer).CanTransition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StateMachineService_CanTransition_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StateMachineServiceServer).CanTransition(ctx, req.(*CanTransitionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StateMachineService_GetValidEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetValidEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StateMachineServiceServer).GetValidEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StateMachineService_GetValidEvents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StateMachineServiceServer).GetValidEvents(ctx, req.(*GetValidEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StateMachineService_StreamChanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamChangesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StateMachineServiceServer).StreamChanges(m, &grpc.GenericServerStream[StreamChangesRequest, StateChange]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StateMachineService_StreamChangesServer = grpc.ServerStreamingServer[StateChange]
+
+// StateMachineService_ServiceDesc is the grpc.ServiceDesc for StateMachineService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StateMachineService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "statemachine.v1.StateMachineService",
+	HandlerType: (*StateMachineServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Transition",
+			Handler:    _StateMachineService_Transition_Handler,
+		},
+		{
+			MethodName: "CanTransition",
+			Handler:    _StateMachineService_CanTransition_Handler,
+		},
+		{
+			MethodName: "GetValidEvents",
+			Handler:    _StateMachineService_GetValidEvents_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamChanges",
+			Handler:       _StateMachineService_StreamChanges_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "statemachine.proto",
+}
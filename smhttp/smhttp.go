@@ -0,0 +1,273 @@
+// Package smhttp exposes a state machine over HTTP, so every service offers
+// its workflow the same way instead of hand-writing handlers for each one.
+// The handler delegates loading and saving entities to the service:
+//
+//	h := smhttp.NewHandler(NewUserStateMachine(),
+//		func(ctx context.Context, id string) (UserState, error) {
+//			user, err := repo.GetByID(ctx, id)
+//			if errors.Is(err, sql.ErrNoRows) {
+//				return "", smhttp.ErrNotFound
+//			}
+//			return user.State, err
+//		},
+//		func(ctx context.Context, id string, from, to UserState) error {
+//			return repo.UpdateState(ctx, id, to)
+//		})
+//	mux.Handle("/users/", http.StripPrefix("/users", h))
+//
+// It serves three routes, each answering with JSON:
+//
+//	GET  /states                  every state of the machine
+//	GET  /{entity}/valid-events   the events valid for an entity's state
+//	POST /{entity}/events/{event} fire an event for an entity
+package smhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// ErrNotFound is returned by a LoadFunc for an entity that does not exist.
+// The handler answers 404 Not Found for it, and for
+// statemachine.ErrNotFound, so a LoadFunc wrapping a StateStore can pass the
+// store's error on.
+var ErrNotFound = errors.New("entity not found")
+
+// maxBodySize limits the payload accepted with an event
+const maxBodySize = 1 << 20
+
+// LoadFunc returns the current state of an entity
+type LoadFunc[S statemachine.State] func(ctx context.Context, id string) (S, error)
+
+// SaveFunc records that an entity moved from one state to another. It is
+// given the state the transition started from so it can refuse the update
+// if the entity changed in the meantime, returning an error matching
+// statemachine.ErrConcurrentModification, which the handler answers with
+// 409 Conflict.
+type SaveFunc[S statemachine.State] func(ctx context.Context, id string, from, to S) error
+
+// Handler serves a state machine's operations over HTTP. Create one with
+// NewHandler.
+type Handler[S statemachine.State, E statemachine.Event] struct {
+	Machine *statemachine.StateMachine[S, E]
+	Load    LoadFunc[S]
+	Save    SaveFunc[S]
+
+	// ErrorLog receives the errors returned by Load and Save that are
+	// answered with 500 Internal Server Error without their details. If
+	// nil, the log package's standard logger is used.
+	ErrorLog *log.Logger
+
+	once sync.Once
+	mux  *http.ServeMux
+}
+
+// NewHandler returns a handler serving sm, loading and saving entities with
+// the given functions
+func NewHandler[S statemachine.State, E statemachine.Event](sm *statemachine.StateMachine[S, E], load LoadFunc[S], save SaveFunc[S]) *Handler[S, E] {
+	return &Handler[S, E]{Machine: sm, Load: load, Save: save}
+}
+
+func (h *Handler[S, E]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		h.mux = http.NewServeMux()
+		h.mux.HandleFunc("GET /states", h.states)
+		h.mux.HandleFunc("GET /{entity}/valid-events", h.validEvents)
+		h.mux.HandleFunc("POST /{entity}/events/{event}", h.fire)
+	})
+	h.mux.ServeHTTP(w, r)
+}
+
+// State describes a state in the response to GET /states
+type State struct {
+	Name        string   `json:"name"`
+	Label       string   `json:"label,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Initial     bool     `json:"initial,omitempty"`
+	Final       bool     `json:"final,omitempty"`
+}
+
+// ValidEvent describes an event in the response to GET /{entity}/valid-events
+type ValidEvent struct {
+	Event string `json:"event"`
+	Label string `json:"label,omitempty"`
+
+	// To is the state the event leads to, or the first tried where
+	// guarded transitions compete for the event
+	To string `json:"to"`
+}
+
+// ValidEvents is the response to GET /{entity}/valid-events
+type ValidEvents struct {
+	ID     string       `json:"id"`
+	State  string       `json:"state"`
+	Events []ValidEvent `json:"events"`
+}
+
+// Fired is the response to POST /{entity}/events/{event}
+type Fired struct {
+	ID    string `json:"id"`
+	From  string `json:"from"`
+	Event string `json:"event"`
+	State string `json:"state"`
+}
+
+// Error is the body of every error response. ValidEvents is set when an
+// event cannot be processed from the entity's state.
+type Error struct {
+	Error       string   `json:"error"`
+	ValidEvents []string `json:"validEvents,omitempty"`
+}
+
+func (h *Handler[S, E]) states(w http.ResponseWriter, r *http.Request) {
+	initial, hasInitial := h.Machine.InitialState()
+	states := []State{}
+	for _, s := range h.Machine.GetAllStates() {
+		st := State{
+			Name:    statemachine.Name(s),
+			Initial: hasInitial && s == initial,
+			Final:   h.Machine.IsFinalState(s),
+		}
+		if m, ok := h.Machine.GetStateMeta(s); ok {
+			st.Label, st.Description, st.Tags = m.Label, m.Description, m.Tags
+		}
+		states = append(states, st)
+	}
+	writeJSON(w, http.StatusOK, states)
+}
+
+func (h *Handler[S, E]) validEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("entity")
+	state, ok := h.load(w, r, id)
+	if !ok {
+		return
+	}
+
+	resp := ValidEvents{ID: id, State: statemachine.Name(state), Events: []ValidEvent{}}
+	for _, e := range h.Machine.GetValidEvents(state) {
+		to, _ := h.Machine.GetNextState(state, e)
+		ve := ValidEvent{Event: statemachine.Name(e), To: statemachine.Name(to)}
+		if m, ok := h.Machine.GetTransitionMeta(state, e); ok {
+			ve.Label = m.Label
+		}
+		resp.Events = append(resp.Events, ve)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// fire processes an event for an entity. A request body is passed to the
+// machine's guards and actions as the event's payload, a json.RawMessage,
+// and the entity's ID is available from statemachine.EntityIDFrom.
+func (h *Handler[S, E]) fire(w http.ResponseWriter, r *http.Request) {
+	id, name := r.PathValue("entity"), r.PathValue("event")
+	event, ok := h.event(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, Error{Error: "unknown event " + name})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, Error{Error: "request body too large"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Error{Error: "cannot read request body"})
+		return
+	}
+	ctx := statemachine.WithEntityID(r.Context(), id)
+	if len(body) > 0 {
+		if !json.Valid(body) {
+			writeJSON(w, http.StatusBadRequest, Error{Error: "request body is not valid JSON"})
+			return
+		}
+		ctx = statemachine.WithPayload(ctx, json.RawMessage(body))
+	}
+
+	from, ok := h.load(w, r, id)
+	if !ok {
+		return
+	}
+	to, err := h.Machine.TransitionContext(ctx, from, event)
+	var terr *statemachine.TransitionError[S, E]
+	if errors.As(err, &terr) {
+		resp := Error{Error: terr.Error(), ValidEvents: []string{}}
+		for _, e := range terr.ValidEvents {
+			resp.ValidEvents = append(resp.ValidEvents, statemachine.Name(e))
+		}
+		writeJSON(w, http.StatusConflict, resp)
+		return
+	}
+	if errors.Is(err, statemachine.ErrNotAuthorized) {
+		writeJSON(w, http.StatusForbidden, Error{Error: err.Error()})
+		return
+	}
+	if errors.Is(err, statemachine.ErrVetoed) {
+		writeJSON(w, http.StatusConflict, Error{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		h.internalError(w, err)
+		return
+	}
+	err = h.Save(ctx, id, from, to)
+	if errors.Is(err, statemachine.ErrConcurrentModification) {
+		writeJSON(w, http.StatusConflict, Error{Error: "entity " + id + " was modified concurrently"})
+		return
+	}
+	if err != nil {
+		h.internalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, Fired{ID: id, From: statemachine.Name(from), Event: statemachine.Name(event), State: statemachine.Name(to)})
+}
+
+// load returns the state of an entity, writing the error response if it
+// cannot be loaded
+func (h *Handler[S, E]) load(w http.ResponseWriter, r *http.Request, id string) (S, bool) {
+	state, err := h.Load(r.Context(), id)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, statemachine.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, Error{Error: "entity " + id + " not found"})
+		return state, false
+	}
+	if err != nil {
+		h.internalError(w, err)
+		return state, false
+	}
+	return state, true
+}
+
+// event finds the machine's event with the given name
+func (h *Handler[S, E]) event(name string) (E, bool) {
+	for _, e := range h.Machine.GetAllEvents() {
+		if statemachine.Name(e) == name {
+			return e, true
+		}
+	}
+	var zero E
+	return zero, false
+}
+
+func (h *Handler[S, E]) internalError(w http.ResponseWriter, err error) {
+	if h.ErrorLog != nil {
+		h.ErrorLog.Printf("smhttp: %v", err)
+	} else {
+		log.Printf("smhttp: %v", err)
+	}
+	writeJSON(w, http.StatusInternalServerError, Error{Error: "internal server error"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
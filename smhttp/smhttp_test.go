@@ -0,0 +1,329 @@
+package smhttp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smhttp"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+type payment struct {
+	Amount int `json:"amount"`
+}
+
+// store is an in-memory store of order states
+type store struct {
+	states  map[string]state
+	saveErr error
+}
+
+func (s *store) load(ctx context.Context, id string) (state, error) {
+	st, ok := s.states[id]
+	if !ok {
+		return "", smhttp.ErrNotFound
+	}
+	return st, nil
+}
+
+func (s *store) save(ctx context.Context, id string, from, to state) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	if s.states[id] != from {
+		return errors.New("state changed")
+	}
+	s.states[id] = to
+	return nil
+}
+
+func newServer(t *testing.T) (*httptest.Server, *store) {
+	t.Helper()
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Pending", "Pay", "Paid",
+		statemachine.WithGuard(statemachine.PayloadGuard(func(ctx context.Context, from state, e event, body json.RawMessage) bool {
+			var p payment
+			return json.Unmarshal(body, &p) == nil && p.Amount > 0
+		})),
+		statemachine.WithMeta[state, event](statemachine.Metadata{Label: "Pay now"}))
+	sm.AddTransition("Pending", "Cancel", "Cancelled")
+	sm.AddTransition("Paid", "Ship", "Shipped")
+	sm.SetInitialState("Pending")
+	sm.AddFinalState("Shipped")
+	sm.SetStateMeta("Paid", statemachine.Metadata{Description: "Payment received"})
+
+	st := &store{states: map[string]state{"1": "Pending", "2": "Paid"}}
+	h := smhttp.NewHandler(sm, st.load, st.save)
+	h.ErrorLog = log.New(io.Discard, "", 0)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv, st
+}
+
+func do(t *testing.T, method, url, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s %s Content-Type = %q, want application/json", method, url, ct)
+	}
+	return resp.StatusCode, strings.TrimSpace(string(data))
+}
+
+func TestHandler_States(t *testing.T) {
+	srv, _ := newServer(t)
+
+	code, body := do(t, http.MethodGet, srv.URL+"/states", "")
+	want := `[{"name":"Cancelled"},{"name":"Paid","description":"Payment received"},{"name":"Pending","initial":true},{"name":"Shipped","final":true}]`
+	if code != http.StatusOK || body != want {
+		t.Errorf("GET /states = %d %s, want 200 %s", code, body, want)
+	}
+}
+
+func TestHandler_ValidEvents(t *testing.T) {
+	srv, _ := newServer(t)
+
+	tests := []struct {
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{
+			url:      "/1/valid-events",
+			wantCode: http.StatusOK,
+			wantBody: `{"id":"1","state":"Pending","events":[{"event":"Cancel","to":"Cancelled"},{"event":"Pay","label":"Pay now","to":"Paid"}]}`,
+		},
+		{
+			url:      "/2/valid-events",
+			wantCode: http.StatusOK,
+			wantBody: `{"id":"2","state":"Paid","events":[{"event":"Ship","to":"Shipped"}]}`,
+		},
+		{
+			url:      "/9/valid-events",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"entity 9 not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		code, body := do(t, http.MethodGet, srv.URL+tt.url, "")
+		if code != tt.wantCode || body != tt.wantBody {
+			t.Errorf("GET %s = %d %s, want %d %s", tt.url, code, body, tt.wantCode, tt.wantBody)
+		}
+	}
+}
+
+func TestHandler_Fire(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		body      string
+		wantCode  int
+		wantBody  string
+		wantState state
+	}{
+		{
+			name:      "transition",
+			url:       "/1/events/Cancel",
+			wantCode:  http.StatusOK,
+			wantBody:  `{"id":"1","from":"Pending","event":"Cancel","state":"Cancelled"}`,
+			wantState: "Cancelled",
+		},
+		{
+			name:      "payload passed to guards",
+			url:       "/1/events/Pay",
+			body:      `{"amount": 10}`,
+			wantCode:  http.StatusOK,
+			wantBody:  `{"id":"1","from":"Pending","event":"Pay","state":"Paid"}`,
+			wantState: "Paid",
+		},
+		{
+			name:      "guard rejected",
+			url:       "/1/events/Pay",
+			body:      `{"amount": 0}`,
+			wantCode:  http.StatusConflict,
+			wantBody:  `{"error":"invalid transition: guard rejected event 'Pay' from state 'Pending'; valid events: Cancel, Pay","validEvents":["Cancel","Pay"]}`,
+			wantState: "Pending",
+		},
+		{
+			name:      "invalid transition",
+			url:       "/1/events/Ship",
+			wantCode:  http.StatusConflict,
+			wantBody:  `{"error":"invalid transition: cannot process event 'Ship' from state 'Pending'; valid events: Cancel, Pay","validEvents":["Cancel","Pay"]}`,
+			wantState: "Pending",
+		},
+		{
+			name:      "unknown event",
+			url:       "/1/events/Explode",
+			wantCode:  http.StatusNotFound,
+			wantBody:  `{"error":"unknown event Explode"}`,
+			wantState: "Pending",
+		},
+		{
+			name:      "invalid body",
+			url:       "/1/events/Pay",
+			body:      `{`,
+			wantCode:  http.StatusBadRequest,
+			wantBody:  `{"error":"request body is not valid JSON"}`,
+			wantState: "Pending",
+		},
+		{
+			name:     "unknown entity",
+			url:      "/9/events/Cancel",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"entity 9 not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, st := newServer(t)
+
+			code, body := do(t, http.MethodPost, srv.URL+tt.url, tt.body)
+			if code != tt.wantCode || body != tt.wantBody {
+				t.Errorf("POST %s = %d %s, want %d %s", tt.url, code, body, tt.wantCode, tt.wantBody)
+			}
+			if tt.wantState != "" && st.states["1"] != tt.wantState {
+				t.Errorf("entity 1 state = %s, want %s", st.states["1"], tt.wantState)
+			}
+		})
+	}
+}
+
+func TestHandler_FireErrors(t *testing.T) {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Paid", "Refund", "Refunded", statemachine.RequirePermission[state, event]("refund"))
+	sm.AddTransition("Paid", "Ship", "Shipped")
+	sm.AddTransition("Paid", "Pack", "Packed")
+	sm.BeforeTransition(func(ctx context.Context, from state, e event, to state) error {
+		if to == "Shipped" {
+			return errors.New("address not verified")
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name     string
+		url      string
+		saveErr  error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "not authorized",
+			url:      "/1/events/Refund",
+			wantCode: http.StatusForbidden,
+			wantBody: `{"error":"not authorized: event 'Refund' from state 'Paid' requires permission 'refund'"}`,
+		},
+		{
+			name:     "vetoed",
+			url:      "/1/events/Ship",
+			wantCode: http.StatusConflict,
+			wantBody: `{"error":"event 'Ship' from state 'Paid' to 'Shipped': transition vetoed: address not verified"}`,
+		},
+		{
+			name:     "concurrent modification",
+			url:      "/1/events/Pack",
+			saveErr:  fmt.Errorf("order 1: %w", statemachine.ErrConcurrentModification),
+			wantCode: http.StatusConflict,
+			wantBody: `{"error":"entity 1 was modified concurrently"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &store{states: map[string]state{"1": "Paid"}, saveErr: tt.saveErr}
+			h := smhttp.NewHandler(sm, st.load, st.save)
+			h.ErrorLog = log.New(io.Discard, "", 0)
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+
+			code, body := do(t, http.MethodPost, srv.URL+tt.url, "")
+			if code != tt.wantCode || body != tt.wantBody {
+				t.Errorf("POST %s = %d %s, want %d %s", tt.url, code, body, tt.wantCode, tt.wantBody)
+			}
+			if st.states["1"] != "Paid" {
+				t.Errorf("entity 1 state = %s, want Paid", st.states["1"])
+			}
+		})
+	}
+}
+
+func TestHandler_StateStoreNotFound(t *testing.T) {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Pending", "Cancel", "Cancelled")
+	load := func(ctx context.Context, id string) (state, error) {
+		return "", fmt.Errorf("order %s: %w", id, statemachine.ErrNotFound)
+	}
+	srv := httptest.NewServer(smhttp.NewHandler(sm, load, nil))
+	defer srv.Close()
+
+	code, body := do(t, http.MethodPost, srv.URL+"/9/events/Cancel", "")
+	if code != http.StatusNotFound || body != `{"error":"entity 9 not found"}` {
+		t.Errorf("POST = %d %s, want 404", code, body)
+	}
+}
+
+func TestHandler_SaveError(t *testing.T) {
+	st := &store{states: map[string]state{"1": "Pending"}, saveErr: errors.New("database is down")}
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Pending", "Cancel", "Cancelled")
+
+	var logged bytes.Buffer
+	h := smhttp.NewHandler(sm, st.load, st.save)
+	h.ErrorLog = log.New(&logged, "", 0)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	code, body := do(t, http.MethodPost, srv.URL+"/1/events/Cancel", "")
+	if code != http.StatusInternalServerError || body != `{"error":"internal server error"}` {
+		t.Errorf("POST = %d %s, want 500 without details", code, body)
+	}
+	if !strings.Contains(logged.String(), "database is down") {
+		t.Errorf("ErrorLog = %q, want the save error", logged.String())
+	}
+	if st.states["1"] != "Pending" {
+		t.Errorf("entity 1 state = %s, want Pending", st.states["1"])
+	}
+}
+
+func TestHandler_MethodNotAllowed(t *testing.T) {
+	srv, _ := newServer(t)
+
+	resp, err := http.Get(srv.URL + "/1/events/Cancel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /1/events/Cancel = %d, want 405", resp.StatusCode)
+	}
+}
@@ -0,0 +1,253 @@
+// Package smkafka drives a state machine from a Kafka topic. Each message
+// names an entity by its key and an event by its value; the adapter fires
+// the event on the entity through a statemachine.Manager, which saves its
+// new state in a statemachine.StateStore, and then produces the change to an
+// output topic for other services to follow:
+//
+//	a := smkafka.NewAdapter(NewOrderStateMachine(), orderStore,
+//		kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, GroupID: "orders", Topic: "order-events"}),
+//		&kafka.Writer{Addr: kafka.TCP(brokers...), Topic: "order-changes"})
+//	err := a.Run(ctx)
+//
+// By default a message's value is JSON naming the event, with an optional
+// payload passed to guards and actions as a json.RawMessage:
+//
+//	{"event": "Ship", "payload": {"carrier": "dhl"}}
+//
+// Each change is produced keyed by the entity ID, so the changes for one
+// entity stay in order, and carries the version the entity is saved at:
+//
+//	{"entityId":"42","from":"Packing","to":"Shipped","event":"Ship","version":3,"timestamp":"2024-05-01T12:00:00Z"}
+//
+// Messages are committed once handled. One that can never be handled, such
+// as an unknown event or entity, or an event the entity's state does not
+// accept or that is refused for lack of permission or vetoed, is logged and
+// committed so it does not block the partition. If another writer saves the
+// entity first, the event is fired again on its new state. Other errors
+// from the store, actions or the Writer stop Run without committing, so the
+// message is fetched again when the adapter restarts.
+//
+// A change is only produced once its state is saved, so it never describes
+// a transition the store refused, and listeners subscribed to the machine
+// hear of it at the same point. A change that cannot be produced stops Run
+// with the entity already saved. Set the Manager's Dedup so that the
+// message, fetched again, is recognised by its partition and offset rather
+// than fired a second time; its change is then not produced again.
+package smkafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// Reader fetches messages and commits them once handled. *kafka.Reader
+// implements it when created with a GroupID.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Writer produces messages. *kafka.Writer implements it.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// DecodeFunc reads the name of the event a message carries, and the payload
+// to pass with it, if any
+type DecodeFunc func(msg kafka.Message) (event string, payload any, err error)
+
+// Command is the default encoding of a message's value
+type Command struct {
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Change is the value of the messages produced for transitions
+type Change struct {
+	EntityID string `json:"entityId"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Event    string `json:"event"`
+
+	// Version is the version the entity is saved at after the transition
+	Version int64 `json:"version"`
+
+	// Timestamp is when the transition was made, by the machine's Clock
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Adapter consumes events from a Reader and applies them to entities.
+// Create one with NewAdapter.
+type Adapter[S statemachine.State, E statemachine.Event] struct {
+	Machine *statemachine.StateMachine[S, E]
+
+	// Manager fires events on the machine's entities and saves them
+	Manager *statemachine.Manager[S, E]
+
+	Reader Reader
+
+	// Writer receives a Change for every transition. If nil, changes are
+	// not produced.
+	Writer Writer
+
+	// Decode reads the event from a message. If nil, the value is decoded
+	// as a Command.
+	Decode DecodeFunc
+
+	// ErrorLog receives the messages skipped because they cannot be
+	// handled. If nil, the log package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// NewAdapter returns an adapter applying the events read from r to the
+// entities in store, producing their changes to w
+func NewAdapter[S statemachine.State, E statemachine.Event](sm *statemachine.StateMachine[S, E], store statemachine.StateStore[S], r Reader, w Writer) *Adapter[S, E] {
+	return &Adapter[S, E]{Machine: sm, Manager: statemachine.NewManager(sm, store), Reader: r, Writer: w}
+}
+
+// Run handles messages until ctx is done or a message fails, returning the
+// error that stopped it
+func (a *Adapter[S, E]) Run(ctx context.Context) error {
+	for {
+		msg, err := a.Reader.FetchMessage(ctx)
+		if err != nil {
+			return err
+		}
+		if err := a.Handle(ctx, msg); err != nil {
+			return err
+		}
+		if err := a.Reader.CommitMessages(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Handle applies one message, without committing it. Messages that can never
+// be handled are logged and skipped; the error returned is one that may
+// succeed if the message is tried again.
+func (a *Adapter[S, E]) Handle(ctx context.Context, msg kafka.Message) error {
+	id := string(msg.Key)
+	if id == "" {
+		a.skip(msg, errors.New("message has no key"))
+		return nil
+	}
+	decode := a.Decode
+	if decode == nil {
+		decode = decodeCommand
+	}
+	name, payload, err := decode(msg)
+	if err != nil {
+		a.skip(msg, err)
+		return nil
+	}
+	event, ok := a.event(name)
+	if !ok {
+		a.skip(msg, fmt.Errorf("unknown event %q", name))
+		return nil
+	}
+
+	fireCtx := statemachine.WithIdempotencyKey(ctx, fmt.Sprintf("%s/%d@%d", msg.Topic, msg.Partition, msg.Offset))
+	if payload != nil {
+		fireCtx = statemachine.WithPayload(fireCtx, payload)
+	}
+	change, repeat, err := a.fire(fireCtx, id, event)
+	if errors.Is(err, statemachine.ErrNotFound) {
+		a.skip(msg, err)
+		return nil
+	}
+	if permanent(err) {
+		a.skip(msg, fmt.Errorf("entity %s: %w", id, err))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("entity %s: %w", id, err)
+	}
+	if repeat {
+		return nil
+	}
+	return a.produce(ctx, msg.Key, change, event)
+}
+
+// fire fires event on the entity with the given ID, loading it again and
+// retrying while other writers save it first
+func (a *Adapter[S, E]) fire(ctx context.Context, id string, event E) (statemachine.Change[S], bool, error) {
+	for {
+		change, repeat, err := a.Manager.FireChange(ctx, id, event)
+		if !errors.Is(err, statemachine.ErrConcurrentModification) || ctx.Err() != nil {
+			return change, repeat, err
+		}
+	}
+}
+
+// produce writes the saved change of the entity keyed by key to the Writer,
+// if there is one
+func (a *Adapter[S, E]) produce(ctx context.Context, key []byte, saved statemachine.Change[S], event E) error {
+	if a.Writer == nil {
+		return nil
+	}
+	change, err := json.Marshal(Change{
+		EntityID:  string(key),
+		From:      statemachine.Name(saved.From),
+		To:        statemachine.Name(saved.To),
+		Event:     statemachine.Name(event),
+		Version:   saved.Version + 1,
+		Timestamp: a.Machine.Clock().Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := a.Writer.WriteMessages(ctx, kafka.Message{Key: key, Value: change}); err != nil {
+		return fmt.Errorf("cannot produce change of entity %s: %w", key, err)
+	}
+	return nil
+}
+
+// permanent reports whether err is one that handling the message again
+// cannot avoid
+func permanent(err error) bool {
+	return errors.Is(err, statemachine.ErrNotFound) || errors.Is(err, statemachine.ErrInvalidTransition) ||
+		errors.Is(err, statemachine.ErrNotAuthorized) || errors.Is(err, statemachine.ErrVetoed)
+}
+
+// event finds the machine's event with the given name
+func (a *Adapter[S, E]) event(name string) (E, bool) {
+	for _, e := range a.Machine.GetAllEvents() {
+		if statemachine.Name(e) == name {
+			return e, true
+		}
+	}
+	var zero E
+	return zero, false
+}
+
+func (a *Adapter[S, E]) skip(msg kafka.Message, err error) {
+	format := "smkafka: skipping message %s/%d@%d: %v"
+	if a.ErrorLog != nil {
+		a.ErrorLog.Printf(format, msg.Topic, msg.Partition, msg.Offset, err)
+		return
+	}
+	log.Printf(format, msg.Topic, msg.Partition, msg.Offset, err)
+}
+
+// decodeCommand is the default DecodeFunc
+func decodeCommand(msg kafka.Message) (string, any, error) {
+	var c Command
+	if err := json.Unmarshal(msg.Value, &c); err != nil {
+		return "", nil, fmt.Errorf("cannot decode message: %w", err)
+	}
+	if c.Event == "" {
+		return "", nil, errors.New("message names no event")
+	}
+	if c.Payload == nil {
+		return c.Event, nil, nil
+	}
+	return c.Event, c.Payload, nil
+}
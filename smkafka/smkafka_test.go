@@ -0,0 +1,295 @@
+package smkafka_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smkafka"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+// failingStore is a MemoryStore whose next saves fail with errs, in turn
+type failingStore struct {
+	*statemachine.MemoryStore[state]
+	errs []error
+}
+
+func (s *failingStore) Save(ctx context.Context, id string, from, to state, version int64) error {
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return err
+	}
+	return s.MemoryStore.Save(ctx, id, from, to, version)
+}
+
+// newStore returns a store holding the given entities
+func newStore(t *testing.T, entities map[string]state) *statemachine.MemoryStore[state] {
+	t.Helper()
+	st := &statemachine.MemoryStore[state]{}
+	for id, s := range entities {
+		if err := st.Save(context.Background(), id, "", s, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return st
+}
+
+// stateOf returns the state an entity is stored in
+func stateOf(t *testing.T, st statemachine.StateStore[state], id string) state {
+	t.Helper()
+	s, _, err := st.Load(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// reader serves its messages in order, then io.EOF
+type reader struct {
+	msgs      []kafka.Message
+	committed []int64
+}
+
+func (r *reader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.msgs) == 0 {
+		return kafka.Message{}, io.EOF
+	}
+	msg := r.msgs[0]
+	r.msgs = r.msgs[1:]
+	return msg, nil
+}
+
+func (r *reader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, m := range msgs {
+		r.committed = append(r.committed, m.Offset)
+	}
+	return nil
+}
+
+// writer records the messages it is given, or fails with err
+type writer struct {
+	msgs []kafka.Message
+	err  error
+}
+
+func (w *writer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+// clock is a statemachine.Clock stopped at a fixed time
+type clock struct {
+	now time.Time
+}
+
+func (c clock) Now() time.Time { return c.now }
+
+func (c clock) AfterFunc(d time.Duration, f func()) statemachine.Timer { return time.AfterFunc(d, f) }
+
+var epoch = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event](statemachine.WithClock(clock{epoch}))
+	sm.AddTransition("Pending", "Pay", "Paid",
+		statemachine.WithGuard(statemachine.PayloadGuard(func(ctx context.Context, from state, e event, payload json.RawMessage) bool {
+			return string(payload) == `"card"`
+		})))
+	sm.AddTransition("Pending", "Cancel", "Cancelled")
+	sm.AddTransition("Paid", "Ship", "Shipped")
+	sm.AddTransition("Paid", "Refund", "Refunded", statemachine.RequirePermission[state, event]("refund"))
+	sm.AddTransition("Shipped", "Lose", "Lost")
+	sm.BeforeTransition(func(ctx context.Context, from state, e event, to state) error {
+		if to == "Lost" {
+			return errors.New("shipments are never lost")
+		}
+		return nil
+	})
+	return sm
+}
+
+func message(offset int64, key, value string) kafka.Message {
+	return kafka.Message{Topic: "orders", Offset: offset, Key: []byte(key), Value: []byte(value)}
+}
+
+func TestAdapter_Run(t *testing.T) {
+	st := newStore(t, map[string]state{"1": "Pending", "2": "Pending", "3": "Paid", "4": "Shipped"})
+	r := &reader{msgs: []kafka.Message{
+		message(0, "1", `{"event":"Pay","payload":"card"}`),
+		message(1, "1", `{"event":"Ship"}`),
+		message(2, "2", `{"event":"Ship"}`),
+		message(3, "2", `{"event":"Explode"}`),
+		message(4, "9", `{"event":"Cancel"}`),
+		message(5, "", `{"event":"Cancel"}`),
+		message(6, "2", `not json`),
+		message(7, "2", `{"event":"Cancel"}`),
+		message(8, "3", `{"event":"Refund"}`),
+		message(9, "4", `{"event":"Lose"}`),
+	}}
+	w := &writer{}
+	var logged bytes.Buffer
+	a := smkafka.NewAdapter(newMachine(), st, r, w)
+	a.ErrorLog = log.New(&logged, "", 0)
+
+	if err := a.Run(context.Background()); !errors.Is(err, io.EOF) {
+		t.Fatalf("Run() error = %v, want the reader's", err)
+	}
+
+	if len(r.committed) != 10 {
+		t.Errorf("committed %v, want every message", r.committed)
+	}
+	for id, want := range map[string]state{"1": "Shipped", "2": "Cancelled", "3": "Paid", "4": "Shipped"} {
+		if got := stateOf(t, st, id); got != want {
+			t.Errorf("entity %s is %s, want %s", id, got, want)
+		}
+	}
+	var changes []string
+	for _, m := range w.msgs {
+		var c smkafka.Change
+		if err := json.Unmarshal(m.Value, &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.EntityID != string(m.Key) || !c.Timestamp.Equal(epoch) {
+			t.Errorf("change %+v produced with key %s", c, m.Key)
+		}
+		changes = append(changes, fmt.Sprintf("%s@%d: %s --%s--> %s", c.EntityID, c.Version, c.From, c.Event, c.To))
+	}
+	want := []string{"1@2: Pending --Pay--> Paid", "1@3: Paid --Ship--> Shipped", "2@2: Pending --Cancel--> Cancelled"}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes = %q, want %q", changes, want)
+	}
+	for _, skipped := range []string{
+		"orders/0@2: entity 2: invalid transition",
+		`orders/0@3: unknown event "Explode"`,
+		"orders/0@4: entity 9: entity not found",
+		"orders/0@5: message has no key",
+		"orders/0@6: cannot decode message",
+		"orders/0@8: entity 3: not authorized",
+		"orders/0@9: entity 4: event 'Lose' from state 'Shipped' to 'Lost': transition vetoed",
+	} {
+		if !strings.Contains(logged.String(), skipped) {
+			t.Errorf("log missing %q:\n%s", skipped, logged.String())
+		}
+	}
+}
+
+func TestAdapter_Handle(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     kafka.Message
+		saveErr error
+		decode  smkafka.DecodeFunc
+		want    state
+		wantErr string
+	}{
+		{
+			name: "custom decoder",
+			msg:  message(0, "1", "Cancel"),
+			decode: func(msg kafka.Message) (string, any, error) {
+				return string(msg.Value), nil, nil
+			},
+			want: "Cancelled",
+		},
+		{
+			name: "guard rejected",
+			msg:  message(0, "1", `{"event":"Pay","payload":"cash"}`),
+			want: "Pending",
+		},
+		{
+			name:    "save fails",
+			msg:     message(0, "1", `{"event":"Cancel"}`),
+			saveErr: errors.New("database is down"),
+			want:    "Pending",
+			wantErr: "entity 1: database is down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var st statemachine.StateStore[state] = newStore(t, map[string]state{"1": "Pending"})
+			if tt.saveErr != nil {
+				st = &failingStore{MemoryStore: newStore(t, map[string]state{"1": "Pending"}), errs: []error{tt.saveErr}}
+			}
+			a := smkafka.NewAdapter(newMachine(), st, &reader{}, nil)
+			a.Decode = tt.decode
+			a.ErrorLog = log.New(io.Discard, "", 0)
+
+			err := a.Handle(context.Background(), tt.msg)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Handle() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := stateOf(t, st, "1"); got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdapter_HandleConcurrentModification(t *testing.T) {
+	st := &failingStore{
+		MemoryStore: newStore(t, map[string]state{"1": "Pending"}),
+		errs:        []error{fmt.Errorf("entity 1 was saved by another writer: %w", statemachine.ErrConcurrentModification)},
+	}
+	w := &writer{}
+	sm := newMachine()
+	heard := 0
+	sm.Subscribe(func(ctx context.Context, from, to state, e event, at time.Time) {
+		heard++
+		if got := stateOf(t, st, "1"); got != to {
+			t.Errorf("listener told of %s --%s--> %s while entity 1 is saved in %s", from, e, to, got)
+		}
+	})
+	a := smkafka.NewAdapter(sm, st, &reader{}, w)
+
+	if err := a.Handle(context.Background(), message(0, "1", `{"event":"Cancel"}`)); err != nil {
+		t.Fatalf("Handle() error = %v, want the event fired again", err)
+	}
+	if got := stateOf(t, st, "1"); got != "Cancelled" || len(w.msgs) != 1 || heard != 1 {
+		t.Errorf("state = %s with %d changes produced and %d heard, want Cancelled with 1 of each", got, len(w.msgs), heard)
+	}
+}
+
+func TestAdapter_HandleProduceFails(t *testing.T) {
+	st := newStore(t, map[string]state{"1": "Pending"})
+	w := &writer{err: errors.New("broker unavailable")}
+	a := smkafka.NewAdapter(newMachine(), st, &reader{}, w)
+	a.Manager.Dedup = &statemachine.MemoryDedupStore[state]{}
+	msg := message(0, "1", `{"event":"Cancel"}`)
+
+	err := a.Handle(context.Background(), msg)
+	if err == nil || err.Error() != "cannot produce change of entity 1: broker unavailable" {
+		t.Fatalf("Handle() error = %v, want the writer's", err)
+	}
+	if got := stateOf(t, st, "1"); got != "Cancelled" {
+		t.Fatalf("state = %s, want Cancelled, saved before the change is produced", got)
+	}
+
+	w.err = nil
+	if err := a.Handle(context.Background(), msg); err != nil {
+		t.Fatalf("Handle() retried error = %v", err)
+	}
+	if _, version, _ := st.Load(context.Background(), "1"); version != 2 || len(w.msgs) != 0 {
+		t.Errorf("entity at version %d with %d changes produced, want the retry recognised and nothing more saved or produced", version, len(w.msgs))
+	}
+}
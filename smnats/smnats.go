@@ -0,0 +1,303 @@
+// Package smnats drives a state machine from NATS, the counterpart of
+// smkafka for services that use NATS. Events for a machine are published on
+// workflow.<machine>.<event> subjects with JSON naming the entity and,
+// optionally, a payload passed to guards and actions as a json.RawMessage:
+//
+//	nc.Publish("workflow.orders.Ship", []byte(`{"entityId": "42", "payload": {"carrier": "dhl"}}`))
+//
+// The adapter fires the event on the entity through a statemachine.Manager,
+// which saves its new state in a statemachine.StateStore, and then publishes
+// the change on workflow.<machine>.state.changed:
+//
+//	{"entityId":"42","from":"Packing","to":"Shipped","event":"Ship","version":3,"timestamp":"2024-05-01T12:00:00Z"}
+//
+// Subscribe handles plain NATS messages, answering requests with the change
+// or an Error. Consume handles a JetStream consumer, acknowledging each
+// message once its state is saved and its change published: a message that
+// can never be handled, such as an unknown entity, or an event its state does
+// not accept or that is refused for lack of permission or vetoed, is
+// terminated, and one that fails on the store, an action or publishing is
+// redelivered. If another writer saves the entity first, the event is fired
+// again on its new state.
+//
+// A change is only published once its state is saved, so it never describes
+// a transition the store refused, and listeners subscribed to the machine
+// hear of it at the same point. A message carrying a Nats-Msg-Id header
+// uses it as its idempotency key, so with the Manager's Dedup set, a message
+// redelivered after its change failed to publish is recognised rather than
+// fired a second time; its change is then not published again.
+package smnats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// Subject returns the subject on which an event for a machine is published
+func Subject(machine, event string) string {
+	return "workflow." + machine + "." + event
+}
+
+// Command is the body of an event message
+type Command struct {
+	EntityID string          `json:"entityId"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// Change is the body of the messages published for transitions, and the
+// reply to a request that succeeds. The reply to a repeat of a message
+// already applied only has the entity's ID, the event and the state it was
+// left in.
+type Change struct {
+	EntityID string `json:"entityId"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Event    string `json:"event"`
+
+	// Version is the version the entity is saved at after the transition
+	Version int64 `json:"version"`
+
+	// Timestamp is when the transition was made, by the machine's Clock
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Error is the reply to a request that fails. ValidEvents lists the events
+// the entity's state accepts when the event was rejected.
+type Error struct {
+	Error       string   `json:"error"`
+	ValidEvents []string `json:"validEvents,omitempty"`
+}
+
+// Adapter applies the events published for a machine. Create one with
+// NewAdapter.
+type Adapter[S statemachine.State, E statemachine.Event] struct {
+	// Name is the machine's part of the subjects
+	Name string
+
+	Machine *statemachine.StateMachine[S, E]
+
+	// Manager fires events on the machine's entities and saves them
+	Manager *statemachine.Manager[S, E]
+
+	// Conn publishes changes and replies
+	Conn *nats.Conn
+
+	// ChangeSubject is where changes are published; it defaults to
+	// workflow.<Name>.state.changed. If it is "-", changes are not
+	// published.
+	ChangeSubject string
+
+	// ErrorLog receives the messages that could not be handled. If nil,
+	// the log package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// NewAdapter returns an adapter applying the events for the machine called
+// name to the entities in store
+func NewAdapter[S statemachine.State, E statemachine.Event](name string, sm *statemachine.StateMachine[S, E], store statemachine.StateStore[S], nc *nats.Conn) *Adapter[S, E] {
+	return &Adapter[S, E]{Name: name, Machine: sm, Manager: statemachine.NewManager(sm, store), Conn: nc}
+}
+
+// Subscribe handles the machine's events as they are published. Adapters
+// subscribed with the same non-empty queue share the events between them.
+func (a *Adapter[S, E]) Subscribe(queue string) (*nats.Subscription, error) {
+	handler := func(msg *nats.Msg) {
+		change, err := a.apply(context.Background(), msg.Subject, msg.Header, msg.Data)
+		if err != nil {
+			a.logf("smnats: cannot handle message on %s: %v", msg.Subject, err)
+		}
+		if msg.Reply != "" {
+			a.reply(msg, change, err)
+		}
+	}
+	subject := Subject(a.Name, "*")
+	if queue == "" {
+		return a.Conn.Subscribe(subject, handler)
+	}
+	return a.Conn.QueueSubscribe(subject, queue, handler)
+}
+
+// Consume handles the messages of a JetStream consumer, which should
+// require explicit acknowledgement and filter on the machine's subjects.
+// Stop the returned context to stop consuming.
+func (a *Adapter[S, E]) Consume(c jetstream.Consumer, opts ...jetstream.PullConsumeOpt) (jetstream.ConsumeContext, error) {
+	return c.Consume(func(msg jetstream.Msg) {
+		_, err := a.apply(context.Background(), msg.Subject(), msg.Headers(), msg.Data())
+
+		var r *rejection
+		switch {
+		case err == nil:
+			err = msg.Ack()
+		case errors.As(err, &r):
+			a.logf("smnats: dropping message on %s: %v", msg.Subject(), err)
+			err = msg.TermWithReason(err.Error())
+		default:
+			a.logf("smnats: cannot handle message on %s, will retry: %v", msg.Subject(), err)
+			err = msg.Nak()
+		}
+		if err != nil {
+			a.logf("smnats: cannot acknowledge message on %s: %v", msg.Subject(), err)
+		}
+	}, opts...)
+}
+
+// rejection is an error for a message that can never be handled
+type rejection struct {
+	err         error
+	validEvents []string
+}
+
+func (r *rejection) Error() string { return r.err.Error() }
+func (r *rejection) Unwrap() error { return r.err }
+
+func reject(format string, args ...any) error {
+	return &rejection{err: fmt.Errorf(format, args...)}
+}
+
+// permanent reports whether err is one that handling the message again
+// cannot avoid
+func permanent(err error) bool {
+	return errors.Is(err, statemachine.ErrNotFound) || errors.Is(err, statemachine.ErrInvalidTransition) ||
+		errors.Is(err, statemachine.ErrNotAuthorized) || errors.Is(err, statemachine.ErrVetoed)
+}
+
+// apply fires the event a message carries, saving the entity's new state
+// and then publishing the change
+func (a *Adapter[S, E]) apply(ctx context.Context, subject string, header nats.Header, data []byte) (Change, error) {
+	name, ok := strings.CutPrefix(subject, Subject(a.Name, ""))
+	if !ok {
+		return Change{}, reject("subject is not for machine %s", a.Name)
+	}
+	event, ok := a.event(name)
+	if !ok {
+		return Change{}, reject("unknown event %q", name)
+	}
+	var cmd Command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return Change{}, reject("cannot decode message: %v", err)
+	}
+	if cmd.EntityID == "" {
+		return Change{}, reject("message names no entity")
+	}
+
+	if key := header.Get(nats.MsgIdHdr); key != "" {
+		ctx = statemachine.WithIdempotencyKey(ctx, key)
+	}
+	if cmd.Payload != nil {
+		ctx = statemachine.WithPayload(ctx, cmd.Payload)
+	}
+	saved, repeat, err := a.fire(ctx, cmd.EntityID, event)
+	if errors.Is(err, statemachine.ErrNotFound) {
+		return Change{}, &rejection{err: err}
+	}
+	if permanent(err) {
+		r := &rejection{err: err}
+		var terr *statemachine.TransitionError[S, E]
+		if errors.As(err, &terr) {
+			r.validEvents = []string{}
+			for _, e := range terr.ValidEvents {
+				r.validEvents = append(r.validEvents, statemachine.Name(e))
+			}
+		}
+		return Change{}, r
+	}
+	if err != nil {
+		return Change{}, fmt.Errorf("entity %s: %w", cmd.EntityID, err)
+	}
+
+	if repeat {
+		return Change{EntityID: cmd.EntityID, To: statemachine.Name(saved.To), Event: statemachine.Name(event)}, nil
+	}
+
+	change := Change{
+		EntityID:  cmd.EntityID,
+		From:      statemachine.Name(saved.From),
+		To:        statemachine.Name(saved.To),
+		Event:     statemachine.Name(event),
+		Version:   saved.Version + 1,
+		Timestamp: a.Machine.Clock().Now().UTC(),
+	}
+	if err := a.publish(change); err != nil {
+		return Change{}, err
+	}
+	return change, nil
+}
+
+// fire fires event on the entity with the given ID, loading it again and
+// retrying while other writers save it first
+func (a *Adapter[S, E]) fire(ctx context.Context, id string, event E) (statemachine.Change[S], bool, error) {
+	for {
+		change, repeat, err := a.Manager.FireChange(ctx, id, event)
+		if !errors.Is(err, statemachine.ErrConcurrentModification) || ctx.Err() != nil {
+			return change, repeat, err
+		}
+	}
+}
+
+// publish sends a change to the change subject
+func (a *Adapter[S, E]) publish(change Change) error {
+	subject := a.ChangeSubject
+	if subject == "-" {
+		return nil
+	}
+	if subject == "" {
+		subject = Subject(a.Name, "state.changed")
+	}
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	if err := a.Conn.Publish(subject, data); err != nil {
+		return fmt.Errorf("cannot publish change of entity %s: %w", change.EntityID, err)
+	}
+	return nil
+}
+
+// reply answers a request with its change, or an Error
+func (a *Adapter[S, E]) reply(msg *nats.Msg, change Change, err error) {
+	var v any = change
+	if err != nil {
+		resp := Error{Error: "internal error"}
+		var r *rejection
+		if errors.As(err, &r) {
+			resp = Error{Error: r.Error(), ValidEvents: r.validEvents}
+		}
+		v = resp
+	}
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = msg.Respond(data)
+	}
+	if err != nil {
+		a.logf("smnats: cannot reply to message on %s: %v", msg.Subject, err)
+	}
+}
+
+// event finds the machine's event with the given name
+func (a *Adapter[S, E]) event(name string) (E, bool) {
+	for _, e := range a.Machine.GetAllEvents() {
+		if statemachine.Name(e) == name {
+			return e, true
+		}
+	}
+	var zero E
+	return zero, false
+}
+
+func (a *Adapter[S, E]) logf(format string, args ...any) {
+	if a.ErrorLog != nil {
+		a.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
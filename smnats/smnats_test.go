@@ -0,0 +1,393 @@
+package smnats_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smnats"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+// store is a MemoryStore failing the first saves with saveErr
+type store struct {
+	*statemachine.MemoryStore[state]
+
+	mu        sync.Mutex
+	saveErr   error
+	saveFails int
+}
+
+// newStore returns a store holding the given entities
+func newStore(t *testing.T, entities map[string]state) *store {
+	t.Helper()
+	s := &store{MemoryStore: &statemachine.MemoryStore[state]{}}
+	for id, st := range entities {
+		if err := s.MemoryStore.Save(context.Background(), id, "", st, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func (s *store) Save(ctx context.Context, id string, from, to state, version int64) error {
+	s.mu.Lock()
+	if s.saveFails > 0 {
+		s.saveFails--
+		s.mu.Unlock()
+		return s.saveErr
+	}
+	s.mu.Unlock()
+	return s.MemoryStore.Save(ctx, id, from, to, version)
+}
+
+func (s *store) state(id string) state {
+	st, _, _ := s.Load(context.Background(), id)
+	return st
+}
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event](statemachine.WithClock(clock{epoch}))
+	sm.AddTransition("Pending", "Pay", "Paid",
+		statemachine.WithGuard(statemachine.PayloadGuard(func(ctx context.Context, from state, e event, payload json.RawMessage) bool {
+			return string(payload) == `"card"`
+		})))
+	sm.AddTransition("Pending", "Cancel", "Cancelled")
+	sm.AddTransition("Pending", "Refund", "Refunded", statemachine.RequirePermission[state, event]("refund"))
+	sm.AddTransition("Paid", "Ship", "Shipped")
+	sm.AddTransition("Pending", "Lose", "Lost")
+	sm.BeforeTransition(func(ctx context.Context, from state, e event, to state) error {
+		if to == "Lost" {
+			return errors.New("orders are never lost")
+		}
+		return nil
+	})
+	return sm
+}
+
+// clock is a statemachine.Clock stopped at a fixed time
+type clock struct {
+	now time.Time
+}
+
+func (c clock) Now() time.Time { return c.now }
+
+func (c clock) AfterFunc(d time.Duration, f func()) statemachine.Timer { return time.AfterFunc(d, f) }
+
+var epoch = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// connect starts an embedded NATS server with JetStream and connects to it
+func connect(t *testing.T) *nats.Conn {
+	t.Helper()
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir(), NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	t.Cleanup(srv.Shutdown)
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+// changes collects the changes published for the orders machine
+func changes(t *testing.T, nc *nats.Conn) chan smnats.Change {
+	t.Helper()
+	ch := make(chan smnats.Change, 16)
+	_, err := nc.Subscribe("workflow.orders.state.changed", func(msg *nats.Msg) {
+		var c smnats.Change
+		if err := json.Unmarshal(msg.Data, &c); err != nil {
+			t.Error(err)
+		}
+		ch <- c
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+func TestAdapter_Subscribe(t *testing.T) {
+	tests := []struct {
+		name      string
+		subject   string
+		data      string
+		wantReply string
+		want      state
+	}{
+		{
+			name:      "valid",
+			subject:   "workflow.orders.Cancel",
+			data:      `{"entityId":"1"}`,
+			wantReply: `"from":"Pending","to":"Cancelled","event":"Cancel"`,
+			want:      "Cancelled",
+		},
+		{
+			name:      "payload",
+			subject:   "workflow.orders.Pay",
+			data:      `{"entityId":"1","payload":"card"}`,
+			wantReply: `"to":"Paid"`,
+			want:      "Paid",
+		},
+		{
+			name:      "rejected",
+			subject:   "workflow.orders.Ship",
+			data:      `{"entityId":"1"}`,
+			wantReply: `{"error":"invalid transition: cannot process event 'Ship' from state 'Pending'; valid events: Cancel, Lose, Pay, Refund","validEvents":["Cancel","Lose","Pay","Refund"]}`,
+			want:      "Pending",
+		},
+		{
+			name:      "unknown event",
+			subject:   "workflow.orders.Explode",
+			data:      `{"entityId":"1"}`,
+			wantReply: `{"error":"unknown event \"Explode\""}`,
+			want:      "Pending",
+		},
+		{
+			name:      "unknown entity",
+			subject:   "workflow.orders.Cancel",
+			data:      `{"entityId":"9"}`,
+			wantReply: `{"error":"entity 9: entity not found"}`,
+			want:      "Pending",
+		},
+		{
+			name:      "not authorized",
+			subject:   "workflow.orders.Refund",
+			data:      `{"entityId":"1"}`,
+			wantReply: `{"error":"not authorized: event 'Refund' from state 'Pending' requires permission 'refund'"}`,
+			want:      "Pending",
+		},
+		{
+			name:      "vetoed",
+			subject:   "workflow.orders.Lose",
+			data:      `{"entityId":"1"}`,
+			wantReply: `{"error":"event 'Lose' from state 'Pending' to 'Lost': transition vetoed: orders are never lost"}`,
+			want:      "Pending",
+		},
+		{
+			name:      "no entity",
+			subject:   "workflow.orders.Cancel",
+			data:      `{}`,
+			wantReply: `{"error":"message names no entity"}`,
+			want:      "Pending",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nc := connect(t)
+			st := newStore(t, map[string]state{"1": "Pending"})
+			a := smnats.NewAdapter("orders", newMachine(), st, nc)
+			a.ErrorLog = log.New(&bytes.Buffer{}, "", 0)
+			if _, err := a.Subscribe("workers"); err != nil {
+				t.Fatal(err)
+			}
+
+			reply, err := nc.Request(tt.subject, []byte(tt.data), 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(reply.Data), tt.wantReply) {
+				t.Errorf("reply = %s, want %s", reply.Data, tt.wantReply)
+			}
+			if got := st.state("1"); got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdapter_SubscribePublishesChanges(t *testing.T) {
+	nc := connect(t)
+	ch := changes(t, nc)
+	st := newStore(t, map[string]state{"1": "Pending"})
+	if _, err := smnats.NewAdapter("orders", newMachine(), st, nc).Subscribe(""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nc.Publish(smnats.Subject("orders", "Cancel"), []byte(`{"entityId":"1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-ch:
+		if c.EntityID != "1" || c.From != "Pending" || c.To != "Cancelled" || c.Event != "Cancel" || c.Version != 2 || !c.Timestamp.Equal(epoch) {
+			t.Errorf("change = %+v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change published")
+	}
+}
+
+func TestAdapter_SubscribeConcurrentModification(t *testing.T) {
+	nc := connect(t)
+	st := newStore(t, map[string]state{"1": "Pending"})
+	st.saveErr, st.saveFails = statemachine.ErrConcurrentModification, 1
+	sm := newMachine()
+	var heard []string
+	sm.Subscribe(func(ctx context.Context, from, to state, e event, at time.Time) {
+		heard = append(heard, string(from)+" --"+string(e)+"--> "+string(to))
+	})
+	if _, err := smnats.NewAdapter("orders", sm, st, nc).Subscribe(""); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := nc.Request(smnats.Subject("orders", "Cancel"), []byte(`{"entityId":"1"}`), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"from":"Pending","to":"Cancelled","event":"Cancel","version":2`; !strings.Contains(string(reply.Data), want) {
+		t.Errorf("reply = %s, want %s", reply.Data, want)
+	}
+	if got := st.state("1"); got != "Cancelled" || len(heard) != 1 {
+		t.Errorf("state = %s with listeners told of %q, want Cancelled told once", got, heard)
+	}
+}
+
+func TestAdapter_SubscribeRepeat(t *testing.T) {
+	nc := connect(t)
+	st := newStore(t, map[string]state{"1": "Pending"})
+	a := smnats.NewAdapter("orders", newMachine(), st, nc)
+	a.Manager.Dedup = &statemachine.MemoryDedupStore[state]{}
+	if _, err := a.Subscribe(""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`"from":"Pending","to":"Cancelled"`, `"from":"","to":"Cancelled"`} {
+		msg := nats.NewMsg(smnats.Subject("orders", "Cancel"))
+		msg.Header.Set(nats.MsgIdHdr, "order-1-cancel")
+		msg.Data = []byte(`{"entityId":"1"}`)
+		reply, err := nc.RequestMsg(msg, 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(reply.Data), want) {
+			t.Errorf("reply = %s, want %s", reply.Data, want)
+		}
+	}
+	if _, version, _ := st.Load(context.Background(), "1"); version != 2 {
+		t.Errorf("entity at version %d, want 2, the repeat not saved", version)
+	}
+}
+
+func TestAdapter_Consume(t *testing.T) {
+	nc := connect(t)
+	ch := changes(t, nc)
+	ctx := context.Background()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "ORDERS", Subjects: []string{"workflow.orders.*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:   "orders",
+		AckPolicy: jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first save fails, so the event is redelivered
+	st := newStore(t, map[string]state{"1": "Pending", "2": "Pending", "3": "Pending", "4": "Pending"})
+	st.saveErr, st.saveFails = errors.New("database is down"), 1
+	var logged syncBuffer
+	a := smnats.NewAdapter("orders", newMachine(), st, nc)
+	a.ErrorLog = log.New(&logged, "", 0)
+	cc, err := a.Consume(consumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Stop()
+
+	for _, m := range []struct{ event, data string }{
+		{"Ship", `{"entityId":"2"}`},
+		{"Refund", `{"entityId":"3"}`},
+		{"Lose", `{"entityId":"4"}`},
+		{"Cancel", `{"entityId":"9"}`},
+		{"Cancel", `{"entityId":"1"}`},
+	} {
+		if _, err := js.Publish(ctx, smnats.Subject("orders", m.event), []byte(m.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case c := <-ch:
+		if c.EntityID != "1" || c.To != "Cancelled" {
+			t.Errorf("change = %+v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change published")
+	}
+
+	// every message is settled, so none is left for redelivery
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := consumer.Info(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.NumAckPending == 0 && info.NumPending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("consumer has %d messages awaiting acknowledgement", info.NumAckPending)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, want := range []string{
+		"dropping message on workflow.orders.Ship: invalid transition",
+		"dropping message on workflow.orders.Refund: not authorized",
+		"dropping message on workflow.orders.Lose: event 'Lose' from state 'Pending' to 'Lost': transition vetoed",
+		"dropping message on workflow.orders.Cancel: entity 9: entity not found",
+		"cannot handle message on workflow.orders.Cancel, will retry: entity 1: database is down",
+	} {
+		if !strings.Contains(logged.String(), want) {
+			t.Errorf("log missing %q:\n%s", want, logged.String())
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe to log to from the consumer's goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
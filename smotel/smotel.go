@@ -0,0 +1,99 @@
+// Package smotel traces state machine transitions with OpenTelemetry. Each
+// transition, whether made with Transition, TransitionContext or an
+// Instance's Fire, gets a span that is a child of the span in its context,
+// and each guard checked and action run gets a span beneath it:
+//
+//	smotel.Instrument(nil, "orders", sm)
+//	...
+//	ctx, span := tracer.Start(ctx, "ProcessEvent")
+//	defer span.End()
+//	to, err := sm.TransitionContext(ctx, from, event)
+//
+// Spans carry the machine's name and the transition's states and event as
+// attributes. Failed transitions and actions are recorded as errors.
+package smotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// tracerName identifies the spans created by this package
+const tracerName = "github.com/richardbowden/statemachine/smotel"
+
+// Attribute keys set on spans
+const (
+	MachineKey     = attribute.Key("statemachine.machine")
+	FromKey        = attribute.Key("statemachine.from")
+	EventKey       = attribute.Key("statemachine.event")
+	ToKey          = attribute.Key("statemachine.to")
+	GuardPassedKey = attribute.Key("statemachine.guard.passed")
+)
+
+// Instrument traces the transitions, guards and actions of sm, naming it
+// machine. Spans are created with tp, or the global TracerProvider if tp is
+// nil. It adds middleware to sm, so sm must not be frozen.
+func Instrument[S statemachine.State, E statemachine.Event](tp trace.TracerProvider, machine string, sm *statemachine.StateMachine[S, E]) {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	tracer := tp.Tracer(tracerName)
+
+	sm.Use(func(next statemachine.TransitionFunc[S, E]) statemachine.TransitionFunc[S, E] {
+		return func(ctx context.Context, from S, event E) (S, error) {
+			ctx, span := tracer.Start(ctx, "statemachine.Transition", trace.WithAttributes(
+				MachineKey.String(machine),
+				FromKey.String(statemachine.Name(from)),
+				EventKey.String(statemachine.Name(event)),
+			))
+			defer span.End()
+
+			to, err := next(ctx, from, event)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return to, err
+			}
+			span.SetAttributes(ToKey.String(statemachine.Name(to)))
+			return to, nil
+		}
+	})
+	sm.UseGuard(func(next statemachine.Guard[S, E]) statemachine.Guard[S, E] {
+		return func(ctx context.Context, from S, event E) bool {
+			ctx, span := tracer.Start(ctx, "statemachine.Guard", trace.WithAttributes(
+				MachineKey.String(machine),
+				FromKey.String(statemachine.Name(from)),
+				EventKey.String(statemachine.Name(event)),
+			))
+			defer span.End()
+
+			ok := next(ctx, from, event)
+			span.SetAttributes(GuardPassedKey.Bool(ok))
+			return ok
+		}
+	})
+	sm.UseAction(func(next statemachine.Action[S, E]) statemachine.Action[S, E] {
+		return func(ctx context.Context, from, to S, event E) error {
+			ctx, span := tracer.Start(ctx, "statemachine.Action", trace.WithAttributes(
+				MachineKey.String(machine),
+				FromKey.String(statemachine.Name(from)),
+				EventKey.String(statemachine.Name(event)),
+				ToKey.String(statemachine.Name(to)),
+			))
+			defer span.End()
+
+			err := next(ctx, from, to, event)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	})
+}
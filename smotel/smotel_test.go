@@ -0,0 +1,121 @@
+package smotel_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smotel"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Pending", "Pay", "Paid",
+		statemachine.WithGuard(func(ctx context.Context, from state, e event) bool { return true }),
+		statemachine.WithAction(func(ctx context.Context, from, to state, e event) error { return nil }))
+	sm.AddTransition("Pending", "Cancel", "Cancelled",
+		statemachine.WithAction(func(ctx context.Context, from, to state, e event) error { return errors.New("refund failed") }))
+	return sm
+}
+
+// describe renders spans as their names, attributes and statuses, each
+// indented beneath its parent and after the siblings that started before it
+func describe(spans []sdktrace.ReadOnlySpan) string {
+	children := map[string][]sdktrace.ReadOnlySpan{}
+	for _, s := range spans {
+		parent := ""
+		if s.Parent().IsValid() {
+			parent = s.Parent().SpanID().String()
+		}
+		children[parent] = append(children[parent], s)
+	}
+
+	var lines []string
+	var walk func(parent string, depth int)
+	walk = func(parent string, depth int) {
+		siblings := children[parent]
+		slices.SortStableFunc(siblings, func(a, b sdktrace.ReadOnlySpan) int {
+			return a.StartTime().Compare(b.StartTime())
+		})
+		for _, s := range siblings {
+			line := strings.Repeat("  ", depth) + s.Name()
+			for _, kv := range s.Attributes() {
+				line += fmt.Sprintf(" %s=%s", kv.Key, kv.Value.Emit())
+			}
+			if s.Status().Code == codes.Error {
+				line += " error: " + s.Status().Description
+			}
+			lines = append(lines, line)
+			walk(s.SpanContext().SpanID().String(), depth+1)
+		}
+	}
+	walk("", 0)
+	return strings.Join(lines, "\n")
+}
+
+func TestInstrument(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  state
+		event event
+		want  string
+	}{
+		{
+			name:  "valid",
+			from:  "Pending",
+			event: "Pay",
+			want: `ProcessEvent
+  statemachine.Transition statemachine.machine=orders statemachine.from=Pending statemachine.event=Pay statemachine.to=Paid
+    statemachine.Guard statemachine.machine=orders statemachine.from=Pending statemachine.event=Pay statemachine.guard.passed=true
+    statemachine.Action statemachine.machine=orders statemachine.from=Pending statemachine.event=Pay statemachine.to=Paid`,
+		},
+		{
+			name:  "action fails",
+			from:  "Pending",
+			event: "Cancel",
+			want: `ProcessEvent
+  statemachine.Transition statemachine.machine=orders statemachine.from=Pending statemachine.event=Cancel error: action for event 'Cancel' from state 'Pending' failed: refund failed
+    statemachine.Action statemachine.machine=orders statemachine.from=Pending statemachine.event=Cancel statemachine.to=Cancelled error: refund failed`,
+		},
+		{
+			name:  "invalid transition",
+			from:  "Paid",
+			event: "Pay",
+			want: `ProcessEvent
+  statemachine.Transition statemachine.machine=orders statemachine.from=Paid statemachine.event=Pay error: invalid transition: cannot process event 'Pay' from state 'Paid'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			sm := newMachine()
+			smotel.Instrument(tp, "orders", sm)
+
+			ctx, span := tp.Tracer("test").Start(context.Background(), "ProcessEvent")
+			sm.NewInstanceAt(tt.from).Fire(ctx, tt.event)
+			span.End()
+
+			if got := describe(recorder.Ended()); got != tt.want {
+				t.Errorf("spans:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package smpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// Message is a state change written to an outbox table, for a Relay to
+// publish
+type Message struct {
+	// Seq orders the messages in the outbox
+	Seq int64 `json:"seq"`
+
+	EntityID string `json:"entityId"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Event    string `json:"event"`
+
+	// Version is the entity's version after the change. A message may be
+	// published more than once, so consumers can use the entity ID and
+	// version to recognise one they have already handled.
+	Version int64 `json:"version"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// outboxSchema returns the statements creating an outbox table and its
+// index of unpublished messages
+func outboxSchema(name string) []string {
+	table := quoteIdent(name)
+	index := quoteIdent(tableName(name) + "_unpublished_idx")
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + table + ` (
+    seq          BIGSERIAL PRIMARY KEY,
+    entity_id    TEXT NOT NULL,
+    from_state   TEXT NOT NULL,
+    to_state     TEXT NOT NULL,
+    event        TEXT NOT NULL,
+    version      BIGINT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at TIMESTAMPTZ
+)`,
+		`CREATE INDEX IF NOT EXISTS ` + index + ` ON ` + table + ` (seq) WHERE published_at IS NULL`,
+	}
+}
+
+// writeOutbox records a state change in the store's outbox table
+func (s *Store[S]) writeOutbox(ctx context.Context, id string, from, to S, event string, version int64) error {
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO `+quoteIdent(s.Outbox)+` (entity_id, from_state, to_state, event, version) VALUES ($1, $2, $3, $4, $5)`,
+		id, statemachine.Name(from), statemachine.Name(to), event, version)
+	if err != nil {
+		return fmt.Errorf("smpostgres: cannot write outbox message for entity %s: %w", id, err)
+	}
+	return nil
+}
+
+// Relay publishes the messages in an outbox table, oldest first, marking
+// each as published once Publish accepts it. A message is published at
+// least once: if the relay stops between publishing a message and marking
+// it, the message is published again. Several relays may run against one
+// outbox, each claiming different messages. Create one with NewRelay.
+type Relay struct {
+	DB *sql.DB
+
+	// Table is the outbox table's name
+	Table string
+
+	// Publish sends a message on, such as to a message broker
+	Publish func(ctx context.Context, m Message) error
+
+	// BatchSize is how many messages are claimed at a time. If zero, 100
+	// are.
+	BatchSize int
+
+	// Interval is how long Run waits before looking again once the outbox
+	// is empty or publishing fails. If zero, it waits a second.
+	Interval time.Duration
+
+	// ErrorLog receives the errors Run recovers from. If nil, the log
+	// package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// NewRelay returns a relay passing the messages in the outbox table to
+// publish
+func NewRelay(db *sql.DB, table string, publish func(ctx context.Context, m Message) error) *Relay {
+	return &Relay{DB: db, Table: table, Publish: publish}
+}
+
+// Run publishes messages as they are written until ctx is done, returning
+// ctx's error. Failures are logged and retried after Interval.
+func (r *Relay) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+	for {
+		n, err := r.Flush(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logf("smpostgres: cannot relay outbox %s: %v", r.Table, err)
+		}
+		if err == nil && n == r.batchSize() {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Flush publishes one batch of unpublished messages, returning how many it
+// published. If Publish fails, the messages before it are still marked as
+// published, and the rest are left for the next call.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	msgs, err := r.claim(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	var published int
+	var publishErr error
+	for _, m := range msgs {
+		if publishErr = r.Publish(ctx, m); publishErr != nil {
+			publishErr = fmt.Errorf("cannot publish message %d: %w", m.Seq, publishErr)
+			break
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE `+quoteIdent(r.Table)+` SET published_at = now() WHERE seq = $1`, m.Seq); err != nil {
+			return 0, err
+		}
+		published++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return published, publishErr
+}
+
+// claim locks the oldest unpublished messages, skipping any another relay
+// has locked
+func (r *Relay) claim(ctx context.Context, tx *sql.Tx) ([]Message, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT seq, entity_id, from_state, to_state, event, version, created_at FROM `+quoteIdent(r.Table)+
+			` WHERE published_at IS NULL ORDER BY seq LIMIT $1 FOR UPDATE SKIP LOCKED`, r.batchSize())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.Seq, &m.EntityID, &m.From, &m.To, &m.Event, &m.Version, &m.Timestamp); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+func (r *Relay) batchSize() int {
+	if r.BatchSize == 0 {
+		return 100
+	}
+	return r.BatchSize
+}
+
+func (r *Relay) logf(format string, args ...any) {
+	if r.ErrorLog != nil {
+		r.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,158 @@
+package smpostgres_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/richardbowden/statemachine/smpostgres"
+)
+
+const (
+	outboxInsert = `INSERT INTO "orders_outbox" (entity_id, from_state, to_state, event, version) VALUES ($1, $2, $3, $4, $5)`
+	outboxClaim  = `SELECT seq, entity_id, from_state, to_state, event, version, created_at FROM "orders_outbox" WHERE published_at IS NULL ORDER BY seq LIMIT $1 FOR UPDATE SKIP LOCKED`
+	outboxMark   = `UPDATE "orders_outbox" SET published_at = now() WHERE seq = $1`
+)
+
+var outboxColumns = []string{"seq", "entity_id", "from_state", "to_state", "event", "version", "created_at"}
+
+func TestTransitionInTx_Outbox(t *testing.T) {
+	store, mock := newStore(t, "orders")
+	store.Outbox = "orders_outbox"
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockQuery).WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"state", "version"}).AddRow("Pending", 1))
+	mock.ExpectExec(updateQuery).WithArgs("Confirmed", "42", int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(outboxInsert).WithArgs("42", "Pending", "Confirmed", "Confirm", int64(2)).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := store.DB.(*sql.DB).BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := smpostgres.TransitionInTx(ctx, tx, store, newMachine(), "42", "Confirm"); err != nil {
+		t.Fatalf("TransitionInTx() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchema_Outbox(t *testing.T) {
+	store, _ := newStore(t, "orders")
+	store.Outbox = "billing.orders_outbox"
+
+	stmts := store.Schema()
+	if len(stmts) != 4 {
+		t.Fatalf("Schema() returned %d statements, want 4", len(stmts))
+	}
+	if !strings.HasPrefix(stmts[2], `CREATE TABLE IF NOT EXISTS "billing"."orders_outbox" (`) {
+		t.Errorf("Schema()[2] = %s", stmts[2])
+	}
+	if want := `CREATE INDEX IF NOT EXISTS "orders_outbox_unpublished_idx" ON "billing"."orders_outbox" (seq) WHERE published_at IS NULL`; stmts[3] != want {
+		t.Errorf("Schema()[3] = %s, want %s", stmts[3], want)
+	}
+}
+
+func newRelay(t *testing.T, publish func(ctx context.Context, m smpostgres.Message) error) (*smpostgres.Relay, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return smpostgres.NewRelay(db, "orders_outbox", publish), mock
+}
+
+func TestRelay_Flush(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var (
+		published []smpostgres.Message
+		failed    bool
+	)
+	relay, mock := newRelay(t, func(ctx context.Context, m smpostgres.Message) error {
+		if m.Seq == 8 && !failed {
+			failed = true
+			return errors.New("broker unavailable")
+		}
+		published = append(published, m)
+		return nil
+	})
+	ctx := context.Background()
+
+	// the second message fails to publish, so only the first is marked
+	mock.ExpectBegin()
+	mock.ExpectQuery(outboxClaim).WithArgs(100).WillReturnRows(sqlmock.NewRows(outboxColumns).
+		AddRow(7, "42", "Pending", "Confirmed", "Confirm", 2, at).
+		AddRow(8, "42", "Confirmed", "Shipped", "Ship", 3, at))
+	mock.ExpectExec(outboxMark).WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := relay.Flush(ctx)
+	if n != 1 || err == nil || err.Error() != "cannot publish message 8: broker unavailable" {
+		t.Fatalf("Flush() = %d, %v, want 1 and the publish error", n, err)
+	}
+
+	// and the next flush picks up where it left off
+	mock.ExpectBegin()
+	mock.ExpectQuery(outboxClaim).WithArgs(100).WillReturnRows(sqlmock.NewRows(outboxColumns).
+		AddRow(8, "42", "Confirmed", "Shipped", "Ship", 3, at))
+	mock.ExpectExec(outboxMark).WithArgs(int64(8)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if n, err := relay.Flush(ctx); n != 1 || err != nil {
+		t.Fatalf("Flush() = %d, %v, want 1", n, err)
+	}
+	want := smpostgres.Message{Seq: 8, EntityID: "42", From: "Confirmed", To: "Shipped", Event: "Ship", Version: 3, Timestamp: at}
+	if len(published) != 2 || published[0].Seq != 7 || published[1] != want {
+		t.Errorf("published %+v, want messages 7 and 8 in order", published)
+	}
+}
+
+func TestRelay_Run(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var published []int64
+	relay, mock := newRelay(t, func(ctx context.Context, m smpostgres.Message) error {
+		published = append(published, m.Seq)
+		return nil
+	})
+	relay.BatchSize = 1
+	relay.Interval = time.Hour
+	var logged bytes.Buffer
+	relay.ErrorLog = log.New(&logged, "", 0)
+
+	// a full batch is followed straight away by another, a failure is
+	// logged, and then the relay waits for new messages
+	mock.ExpectBegin()
+	mock.ExpectQuery(outboxClaim).WithArgs(1).WillReturnRows(sqlmock.NewRows(outboxColumns).
+		AddRow(1, "42", "Pending", "Confirmed", "Confirm", 2, at))
+	mock.ExpectExec(outboxMark).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin().WillReturnError(errors.New("too many connections"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := relay.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(published) != 1 {
+		t.Errorf("published %v, want message 1", published)
+	}
+	if want := "smpostgres: cannot relay outbox orders_outbox: too many connections"; !strings.Contains(logged.String(), want) {
+		t.Errorf("logged %q, want %q", logged.String(), want)
+	}
+}
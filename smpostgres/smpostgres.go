@@ -0,0 +1,205 @@
+// Package smpostgres keeps the state of entities in a PostgreSQL table,
+// implementing statemachine.StateStore over database/sql with any driver,
+// such as github.com/jackc/pgx/v5/stdlib or github.com/lib/pq:
+//
+//	db, err := sql.Open("pgx", dsn)
+//	store := smpostgres.NewStore(db, "orders", NewOrderStateMachine())
+//	err = store.Migrate(ctx)
+//	orders := statemachine.NewManager(NewOrderStateMachine(), store)
+//
+// The table holds one row per entity:
+//
+//	CREATE TABLE IF NOT EXISTS "orders" (
+//	    id         TEXT PRIMARY KEY,
+//	    state      TEXT NOT NULL,
+//	    version    BIGINT NOT NULL,
+//	    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+//	);
+//	CREATE INDEX IF NOT EXISTS "orders_state_idx" ON "orders" (state);
+//
+// Saves are compare-and-swap updates on the version column, so of two
+// writers transitioning the same entity at once only the first succeeds and
+// the second gets statemachine.ErrConcurrentModification, whatever the
+// transaction isolation level.
+package smpostgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// DB runs queries. *sql.DB, *sql.Conn and *sql.Tx implement it, so a Store
+// can take part in a transaction.
+type DB interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Store is a statemachine.StateStore keeping entities in a PostgreSQL table.
+// Create one with NewStore.
+type Store[S statemachine.State] struct {
+	DB DB
+
+	// Table is the table's name, optionally qualified by its schema as in
+	// "billing.invoices"
+	Table string
+
+	// Outbox, if set, names a table that TransitionInTx writes each state
+	// change to as a Message, in the same transaction, for a Relay to
+	// publish
+	Outbox string
+
+	// state finds the machine's state with a stored name
+	state func(name string) (S, bool)
+}
+
+// NewStore returns a store keeping the entities of sm in table. Stored
+// states are read back by name, so they must be states of sm.
+func NewStore[S statemachine.State, E statemachine.Event](db DB, table string, sm *statemachine.StateMachine[S, E]) *Store[S] {
+	return &Store[S]{DB: db, Table: table, state: sm.StateByName}
+}
+
+// Schema returns the statements creating the store's table and its index on
+// state, and its outbox table if it has one, if they do not already exist
+func (s *Store[S]) Schema() []string {
+	table := quoteIdent(s.Table)
+	index := quoteIdent(tableName(s.Table) + "_state_idx")
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS ` + table + ` (
+    id         TEXT PRIMARY KEY,
+    state      TEXT NOT NULL,
+    version    BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`,
+		`CREATE INDEX IF NOT EXISTS ` + index + ` ON ` + table + ` (state)`,
+	}
+	if s.Outbox != "" {
+		stmts = append(stmts, outboxSchema(s.Outbox)...)
+	}
+	return stmts
+}
+
+// Migrate runs the statements returned by Schema. Running it again once
+// the table exists does nothing.
+func (s *Store[S]) Migrate(ctx context.Context) error {
+	for _, stmt := range s.Schema() {
+		if _, err := s.DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("smpostgres: cannot migrate %s: %w", s.Table, err)
+		}
+	}
+	return nil
+}
+
+// Load returns an entity's state and version
+func (s *Store[S]) Load(ctx context.Context, id string) (S, int64, error) {
+	return s.load(ctx, id, "")
+}
+
+// load reads an entity's row, appending suffix, such as a locking clause,
+// to the query
+func (s *Store[S]) load(ctx context.Context, id, suffix string) (S, int64, error) {
+	var (
+		zero    S
+		name    string
+		version int64
+	)
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT state, version FROM `+quoteIdent(s.Table)+` WHERE id = $1`+suffix, id).Scan(&name, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return zero, 0, fmt.Errorf("entity %s: %w", id, statemachine.ErrNotFound)
+	}
+	if err != nil {
+		return zero, 0, fmt.Errorf("smpostgres: cannot load entity %s: %w", id, err)
+	}
+	state, ok := s.state(name)
+	if !ok {
+		return zero, 0, fmt.Errorf("entity %s is in state %q: %w", id, name, statemachine.ErrUnknownState)
+	}
+	return state, version, nil
+}
+
+// Save stores an entity's new state if its row is still at version,
+// inserting the row when version is 0
+func (s *Store[S]) Save(ctx context.Context, id string, from, to S, version int64) error {
+	var (
+		res sql.Result
+		err error
+	)
+	if version == 0 {
+		res, err = s.DB.ExecContext(ctx,
+			`INSERT INTO `+quoteIdent(s.Table)+` (id, state, version) VALUES ($1, $2, 1) ON CONFLICT (id) DO NOTHING`,
+			id, statemachine.Name(to))
+	} else {
+		res, err = s.DB.ExecContext(ctx,
+			`UPDATE `+quoteIdent(s.Table)+` SET state = $1, version = version + 1, updated_at = now() WHERE id = $2 AND version = $3`,
+			statemachine.Name(to), id, version)
+	}
+	if err != nil {
+		return fmt.Errorf("smpostgres: cannot save entity %s: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("smpostgres: cannot save entity %s: %w", id, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("entity %s is no longer at version %d: %w", id, version, statemachine.ErrConcurrentModification)
+	}
+	return nil
+}
+
+// SaveAll saves every change in a single transaction, so that either all
+// of them are saved or none are, for statemachine.Atomic batches. If the
+// store's DB is a *sql.Tx, or cannot begin a transaction, the changes are
+// saved through it as they stand, to be committed or rolled back with it.
+func (s *Store[S]) SaveAll(ctx context.Context, changes []statemachine.Change[S]) error {
+	db, ok := s.DB.(interface {
+		BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	})
+	if !ok {
+		return s.saveAll(ctx, changes)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("smpostgres: cannot begin batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	bound := *s
+	bound.DB = tx
+	if err := bound.saveAll(ctx, changes); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("smpostgres: cannot commit batch: %w", err)
+	}
+	return nil
+}
+
+func (s *Store[S]) saveAll(ctx context.Context, changes []statemachine.Change[S]) error {
+	for _, c := range changes {
+		if err := s.Save(ctx, c.EntityID, c.From, c.To, c.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoteIdent quotes each part of a possibly schema-qualified name so it is
+// used as written, whatever characters it contains
+func quoteIdent(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = `"` + strings.ReplaceAll(p, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+// tableName returns name without its schema
+func tableName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
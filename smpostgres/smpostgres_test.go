@@ -0,0 +1,289 @@
+package smpostgres_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smpostgres"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+const (
+	selectQuery = `SELECT state, version FROM "orders" WHERE id = $1`
+	insertQuery = `INSERT INTO "orders" (id, state, version) VALUES ($1, $2, 1) ON CONFLICT (id) DO NOTHING`
+	updateQuery = `UPDATE "orders" SET state = $1, version = version + 1, updated_at = now() WHERE id = $2 AND version = $3`
+)
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.SetInitialState("Pending")
+	sm.AddTransition("Pending", "Confirm", "Confirmed")
+	sm.AddTransition("Confirmed", "Ship", "Shipped")
+	return sm
+}
+
+func newStore(t *testing.T, table string) (*smpostgres.Store[state], sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return smpostgres.NewStore(db, table, newMachine()), mock
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		table     string
+		wantTable string
+		wantIndex string
+	}{
+		{"orders", `"orders"`, `"orders_state_idx"`},
+		{"billing.invoices", `"billing"."invoices"`, `"invoices_state_idx"`},
+		{`odd"name`, `"odd""name"`, `"odd""name_state_idx"`},
+	}
+
+	for _, tt := range tests {
+		store, mock := newStore(t, tt.table)
+		stmts := store.Schema()
+		if !strings.HasPrefix(stmts[0], "CREATE TABLE IF NOT EXISTS "+tt.wantTable+" (") {
+			t.Errorf("%s: Schema()[0] = %s", tt.table, stmts[0])
+		}
+		if want := "CREATE INDEX IF NOT EXISTS " + tt.wantIndex + " ON " + tt.wantTable + " (state)"; stmts[1] != want {
+			t.Errorf("%s: Schema()[1] = %s, want %s", tt.table, stmts[1], want)
+		}
+
+		for _, stmt := range stmts {
+			mock.ExpectExec(stmt).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		if err := store.Migrate(context.Background()); err != nil {
+			t.Errorf("%s: Migrate() error = %v", tt.table, err)
+		}
+	}
+}
+
+func TestMigrate_Fails(t *testing.T) {
+	store, mock := newStore(t, "orders")
+	mock.ExpectExec(store.Schema()[0]).WillReturnError(errors.New("permission denied"))
+
+	err := store.Migrate(context.Background())
+	if err == nil || err.Error() != "smpostgres: cannot migrate orders: permission denied" {
+		t.Errorf("Migrate() error = %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		expect      func(*sqlmock.ExpectedQuery)
+		want        state
+		wantVersion int64
+		wantErr     error
+	}{
+		{
+			name: "found",
+			expect: func(q *sqlmock.ExpectedQuery) {
+				q.WillReturnRows(sqlmock.NewRows([]string{"state", "version"}).AddRow("Confirmed", 3))
+			},
+			want:        "Confirmed",
+			wantVersion: 3,
+		},
+		{
+			name:    "not found",
+			expect:  func(q *sqlmock.ExpectedQuery) { q.WillReturnError(sql.ErrNoRows) },
+			wantErr: statemachine.ErrNotFound,
+		},
+		{
+			name: "unknown state",
+			expect: func(q *sqlmock.ExpectedQuery) {
+				q.WillReturnRows(sqlmock.NewRows([]string{"state", "version"}).AddRow("Lost", 1))
+			},
+			wantErr: statemachine.ErrUnknownState,
+		},
+		{
+			name:    "connection lost",
+			expect:  func(q *sqlmock.ExpectedQuery) { q.WillReturnError(sql.ErrConnDone) },
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, mock := newStore(t, "orders")
+			tt.expect(mock.ExpectQuery(selectQuery).WithArgs("42"))
+
+			got, version, err := store.Load(context.Background(), "42")
+			if !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Fatalf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want || version != tt.wantVersion {
+				t.Errorf("Load() = %v, %d, want %v, %d", got, version, tt.want, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int64
+		expect  func(sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "create",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insertQuery).WithArgs("42", "Pending").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "create existing",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insertQuery).WithArgs("42", "Pending").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: statemachine.ErrConcurrentModification,
+		},
+		{
+			name:    "update",
+			version: 3,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(updateQuery).WithArgs("Pending", "42", int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:    "stale version",
+			version: 3,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(updateQuery).WithArgs("Pending", "42", int64(3)).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: statemachine.ErrConcurrentModification,
+		},
+		{
+			name:    "connection lost",
+			version: 3,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(updateQuery).WithArgs("Pending", "42", int64(3)).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, mock := newStore(t, "orders")
+			tt.expect(mock)
+
+			err := store.Save(context.Background(), "42", "", "Pending", tt.version)
+			if !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Errorf("Save() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStore_Manager(t *testing.T) {
+	store, mock := newStore(t, "orders")
+	orders := statemachine.NewManager(newMachine(), store)
+	ctx := context.Background()
+
+	mock.ExpectExec(insertQuery).WithArgs("42", "Pending").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(selectQuery).WithArgs("42").
+		WillReturnRows(sqlmock.NewRows([]string{"state", "version"}).AddRow("Pending", 1))
+	mock.ExpectExec(updateQuery).WithArgs("Confirmed", "42", int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := orders.Create(ctx, "42"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := orders.Fire(ctx, "42", "Confirm"); err != nil || got != "Confirmed" {
+		t.Errorf("Fire() = %v, %v, want Confirmed", got, err)
+	}
+}
+
+func TestSaveAll(t *testing.T) {
+	changes := []statemachine.Change[state]{
+		{EntityID: "1", From: "Pending", To: "Confirmed", Version: 1},
+		{EntityID: "2", From: "Pending", To: "Confirmed", Version: 3},
+	}
+	tests := []struct {
+		name    string
+		expect  func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "commits",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(updateQuery).WithArgs("Confirmed", "1", int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(updateQuery).WithArgs("Confirmed", "2", int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "rolls back on a conflict",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(updateQuery).WithArgs("Confirmed", "1", int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(updateQuery).WithArgs("Confirmed", "2", int64(3)).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: statemachine.ErrConcurrentModification,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, mock := newStore(t, "orders")
+			tt.expect(mock)
+
+			err := store.SaveAll(context.Background(), changes)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SaveAll() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStore_TransitionBatch(t *testing.T) {
+	store, mock := newStore(t, "orders")
+	orders := statemachine.NewManager(newMachine(), store)
+
+	mock.ExpectQuery(selectQuery).WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"state", "version"}).AddRow("Pending", 1))
+	mock.ExpectQuery(selectQuery).WithArgs("2").
+		WillReturnRows(sqlmock.NewRows([]string{"state", "version"}).AddRow("Pending", 4))
+	mock.ExpectBegin()
+	mock.ExpectExec(updateQuery).WithArgs("Confirmed", "1", int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(updateQuery).WithArgs("Confirmed", "2", int64(4)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	results, err := orders.TransitionBatch(context.Background(), []statemachine.EntityEvent[event]{
+		{EntityID: "1", Event: "Confirm"},
+		{EntityID: "2", Event: "Confirm"},
+	}, statemachine.Atomic())
+	if err != nil {
+		t.Fatalf("TransitionBatch() error = %v", err)
+	}
+	for _, r := range results {
+		if r.Err != nil || r.State != "Confirmed" {
+			t.Errorf("result %+v, want Confirmed", r)
+		}
+	}
+}
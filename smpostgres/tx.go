@@ -0,0 +1,67 @@
+package smpostgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/richardbowden/statemachine"
+)
+
+type txKey struct{}
+
+// TxFrom returns the transaction a transition started by TransitionInTx is
+// running in, so guards and actions can read and write through it
+func TxFrom(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// TransitionInTx transitions an entity via event entirely within tx: its row
+// is loaded and locked with SELECT ... FOR UPDATE, guards and actions run
+// with tx available through TxFrom, and the new state is saved. Committing
+// tx then commits the state change together with whatever the actions
+// wrote, and rolling it back undoes both. The machine's listeners are not
+// told of the transition until notify is called, which should be once tx
+// commits, so they never hear of a change that was rolled back:
+//
+//	tx, err := db.BeginTx(ctx, nil)
+//	...
+//	defer tx.Rollback()
+//	state, notify, err := smpostgres.TransitionInTx(ctx, tx, store, sm, orderID, OrderEventShip)
+//	if err != nil {
+//		return err
+//	}
+//	if err := tx.Commit(); err != nil {
+//		return err
+//	}
+//	notify()
+//
+// If the store has an Outbox, the change is also written to it as a
+// Message, so it is published if and only if tx commits. The store's own DB
+// is not used. Since the row is locked, concurrent callers wait for tx to
+// finish rather than failing with statemachine.ErrConcurrentModification.
+func TransitionInTx[S statemachine.State, E statemachine.Event](ctx context.Context, tx *sql.Tx, store *Store[S], sm *statemachine.StateMachine[S, E], id string, event E) (state S, notify func(), err error) {
+	bound := *store
+	bound.DB = tx
+	notify = func() {}
+
+	from, version, err := bound.load(ctx, id, " FOR UPDATE")
+	if err != nil {
+		return from, notify, err
+	}
+	ctx, release := statemachine.HoldNotices(ctx, sm)
+	ctx = context.WithValue(statemachine.WithEntityID(ctx, id), txKey{}, tx)
+	to, err := sm.TransitionContext(ctx, from, event)
+	if err != nil {
+		return from, notify, err
+	}
+	if err := bound.Save(ctx, id, from, to, version); err != nil {
+		return from, notify, err
+	}
+	if bound.Outbox != "" {
+		if err := bound.writeOutbox(ctx, id, from, to, statemachine.Name(event), version+1); err != nil {
+			return from, notify, err
+		}
+	}
+	return to, release, nil
+}
@@ -0,0 +1,138 @@
+package smpostgres_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smpostgres"
+)
+
+var (
+	errDuplicate     = errors.New("duplicate shipment")
+	errSerialization = errors.New("could not serialize access")
+)
+
+const (
+	lockQuery     = selectQuery + ` FOR UPDATE`
+	shipmentQuery = `INSERT INTO shipments (order_id) VALUES ($1)`
+)
+
+// newShippingMachine records a shipment through the transition's
+// transaction when an order ships
+func newShippingMachine() *statemachine.StateMachine[state, event] {
+	sm := newMachine()
+	sm.AddTransition("Confirmed", "Ship", "Shipped",
+		statemachine.WithAction(func(ctx context.Context, from, to state, e event) error {
+			tx, ok := smpostgres.TxFrom(ctx)
+			if !ok {
+				return errors.New("no transaction")
+			}
+			id, _ := statemachine.EntityIDFrom(ctx)
+			_, err := tx.ExecContext(ctx, shipmentQuery, id)
+			return err
+		}))
+	return sm
+}
+
+func TestTransitionInTx(t *testing.T) {
+	tests := []struct {
+		name      string
+		expect    func(sqlmock.Sqlmock)
+		commitErr error
+		want      state
+		wantErr   error
+		wantHeard []string
+	}{
+		{
+			name: "committed",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lockQuery).WithArgs("42").
+					WillReturnRows(sqlmock.NewRows([]string{"state", "version"}).AddRow("Confirmed", 2))
+				mock.ExpectExec(shipmentQuery).WithArgs("42").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(updateQuery).WithArgs("Shipped", "42", int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			want:      "Shipped",
+			wantHeard: []string{"Confirmed --Ship--> Shipped"},
+		},
+		{
+			name: "commit fails",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lockQuery).WithArgs("42").
+					WillReturnRows(sqlmock.NewRows([]string{"state", "version"}).AddRow("Confirmed", 2))
+				mock.ExpectExec(shipmentQuery).WithArgs("42").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(updateQuery).WithArgs("Shipped", "42", int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit().WillReturnError(errSerialization)
+			},
+			want:    "Shipped",
+			wantErr: errSerialization,
+		},
+		{
+			name: "action fails",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lockQuery).WithArgs("42").
+					WillReturnRows(sqlmock.NewRows([]string{"state", "version"}).AddRow("Confirmed", 2))
+				mock.ExpectExec(shipmentQuery).WithArgs("42").WillReturnError(errDuplicate)
+				mock.ExpectRollback()
+			},
+			want:    "Confirmed",
+			wantErr: errDuplicate,
+		},
+		{
+			name: "rejected",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lockQuery).WithArgs("42").
+					WillReturnRows(sqlmock.NewRows([]string{"state", "version"}).AddRow("Pending", 1))
+				mock.ExpectRollback()
+			},
+			want:    "Pending",
+			wantErr: statemachine.ErrInvalidTransition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, mock := newStore(t, "orders")
+			ctx := context.Background()
+			mock.ExpectBegin()
+			tt.expect(mock)
+
+			tx, err := store.DB.(*sql.DB).BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sm := newShippingMachine()
+			var heard []string
+			sm.Subscribe(func(ctx context.Context, from, to state, e event, at time.Time) {
+				heard = append(heard, string(from)+" --"+string(e)+"--> "+string(to))
+			})
+			got, notify, err := smpostgres.TransitionInTx(ctx, tx, store, sm, "42", "Ship")
+			if err == nil {
+				if len(heard) > 0 {
+					t.Errorf("listeners told of %q before the transaction committed", heard)
+				}
+				if err = tx.Commit(); err == nil {
+					notify()
+				}
+			} else {
+				tx.Rollback()
+			}
+			if !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Errorf("TransitionInTx() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("TransitionInTx() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(heard, tt.wantHeard) {
+				t.Errorf("listeners told of %q, want %q", heard, tt.wantHeard)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Package smprometheus exposes Prometheus metrics for state machines:
+//
+//	statemachine_transitions_total{machine, from, event, to}
+//	statemachine_invalid_transitions_total{machine, from, event}
+//	statemachine_action_duration_seconds{machine, from, event, to}
+//
+// Create the metrics once, registering them, and instrument each machine
+// under its own name before it is frozen:
+//
+//	m, err := smprometheus.NewMetrics(prometheus.DefaultRegisterer)
+//	smprometheus.Instrument(m, "orders", sm)
+//
+// Invalid transitions include those rejected by guards, and are counted
+// whether the event is unknown for the state or its guards failed.
+package smprometheus
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// Metrics holds the collectors shared by every instrumented machine. Create
+// them with NewMetrics.
+type Metrics struct {
+	transitions *prometheus.CounterVec
+	invalid     *prometheus.CounterVec
+	actions     *prometheus.HistogramVec
+}
+
+// NewMetrics creates the collectors and registers them with reg
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "statemachine_transitions_total",
+			Help: "Transitions completed, by machine, from state, event and to state.",
+		}, []string{"machine", "from", "event", "to"}),
+		invalid: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "statemachine_invalid_transitions_total",
+			Help: "Events that could not be processed from a state, by machine, from state and event.",
+		}, []string{"machine", "from", "event"}),
+		actions: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "statemachine_action_duration_seconds",
+			Help:    "Time taken by transition actions, by machine, from state, event and to state.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"machine", "from", "event", "to"}),
+	}
+	for _, c := range []prometheus.Collector{m.transitions, m.invalid, m.actions} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Instrument records the transitions and actions of sm in m, labelled with
+// machine. It adds middleware to sm, so sm must not be frozen.
+func Instrument[S statemachine.State, E statemachine.Event](m *Metrics, machine string, sm *statemachine.StateMachine[S, E]) {
+	sm.Use(func(next statemachine.TransitionFunc[S, E]) statemachine.TransitionFunc[S, E] {
+		return func(ctx context.Context, from S, event E) (S, error) {
+			to, err := next(ctx, from, event)
+			switch {
+			case err == nil:
+				m.transitions.WithLabelValues(machine, statemachine.Name(from), statemachine.Name(event), statemachine.Name(to)).Inc()
+			case errors.Is(err, statemachine.ErrInvalidTransition):
+				m.invalid.WithLabelValues(machine, statemachine.Name(from), statemachine.Name(event)).Inc()
+			}
+			return to, err
+		}
+	})
+	sm.UseAction(func(next statemachine.Action[S, E]) statemachine.Action[S, E] {
+		return func(ctx context.Context, from, to S, event E) error {
+			start := time.Now()
+			err := next(ctx, from, to, event)
+			m.actions.WithLabelValues(machine, statemachine.Name(from), statemachine.Name(event), statemachine.Name(to)).Observe(time.Since(start).Seconds())
+			return err
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package smprometheus_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smprometheus"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Pending", "Pay", "Paid",
+		statemachine.WithGuard(func(ctx context.Context, from state, e event) bool { return false }))
+	sm.AddTransition("Pending", "Cancel", "Cancelled",
+		statemachine.WithAction(func(ctx context.Context, from, to state, e event) error { return nil }),
+		statemachine.WithAction(func(ctx context.Context, from, to state, e event) error { return nil }))
+	return sm
+}
+
+func TestInstrument(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := smprometheus.NewMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, users := newMachine(), newMachine()
+	smprometheus.Instrument(m, "orders", orders)
+	smprometheus.Instrument(m, "users", users)
+
+	orders.Transition("Pending", "Cancel")
+	orders.Transition("Pending", "Cancel")
+	orders.Transition("Pending", "Pay")
+	orders.Transition("Cancelled", "Pay")
+	users.Transition("Pending", "Cancel")
+
+	want := `
+# HELP statemachine_invalid_transitions_total Events that could not be processed from a state, by machine, from state and event.
+# TYPE statemachine_invalid_transitions_total counter
+statemachine_invalid_transitions_total{event="Pay",from="Cancelled",machine="orders"} 1
+statemachine_invalid_transitions_total{event="Pay",from="Pending",machine="orders"} 1
+# HELP statemachine_transitions_total Transitions completed, by machine, from state, event and to state.
+# TYPE statemachine_transitions_total counter
+statemachine_transitions_total{event="Cancel",from="Pending",machine="orders",to="Cancelled"} 2
+statemachine_transitions_total{event="Cancel",from="Pending",machine="users",to="Cancelled"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want),
+		"statemachine_transitions_total", "statemachine_invalid_transitions_total"); err != nil {
+		t.Error(err)
+	}
+
+	// two actions ran for each of the three cancellations
+	count, err := testutil.GatherAndCount(reg, "statemachine_action_duration_seconds")
+	if err != nil || count != 2 {
+		t.Errorf("action duration series = %d, %v, want one per machine", count, err)
+	}
+	metrics, _ := reg.Gather()
+	var observed uint64
+	for _, mf := range metrics {
+		if mf.GetName() != "statemachine_action_duration_seconds" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			observed += metric.GetHistogram().GetSampleCount()
+		}
+	}
+	if observed != 6 {
+		t.Errorf("action durations observed = %d, want 6", observed)
+	}
+}
+
+func TestNewMetrics_RegisterTwice(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := smprometheus.NewMetrics(reg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := smprometheus.NewMetrics(reg); err == nil {
+		t.Error("NewMetrics() on the same registry again succeeded, want already registered error")
+	}
+}
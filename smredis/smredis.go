@@ -0,0 +1,101 @@
+// Package smredis keeps the state of entities in Redis, implementing
+// statemachine.StateStore for high-throughput, short-lived workflows such as
+// checkout sessions:
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	store := smredis.NewStore(rdb, "checkout:", NewCheckoutStateMachine())
+//	store.TTL = 30 * time.Minute
+//	checkouts := statemachine.NewManager(NewCheckoutStateMachine(), store)
+//
+// Each entity is a hash at its ID behind the store's prefix, holding its
+// state and version. Saves run as a Lua script that compares the version and
+// writes the new state atomically, so of two writers transitioning the same
+// entity at once only the first succeeds and the second gets
+// statemachine.ErrConcurrentModification.
+package smredis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// save sets an entity's state if its version is still ARGV[2], then applies
+// or removes its expiry. It returns 1 if the entity was saved and 0 if its
+// version had moved on.
+var save = redis.NewScript(`
+local version = redis.call('HGET', KEYS[1], 'version') or '0'
+if version ~= ARGV[2] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'state', ARGV[1], 'version', tonumber(ARGV[2]) + 1)
+if tonumber(ARGV[3]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+else
+	redis.call('PERSIST', KEYS[1])
+end
+return 1
+`)
+
+// Store is a statemachine.StateStore keeping entities in Redis. Create one
+// with NewStore.
+type Store[S statemachine.State] struct {
+	Client redis.Cmdable
+
+	// Prefix is put before each entity's ID to form its key
+	Prefix string
+
+	// TTL, if set, expires an entity once it has gone that long without a
+	// transition, after which it is no longer found
+	TTL time.Duration
+
+	// state finds the machine's state with a stored name
+	state func(name string) (S, bool)
+}
+
+// NewStore returns a store keeping the entities of sm under prefix. Stored
+// states are read back by name, so they must be states of sm.
+func NewStore[S statemachine.State, E statemachine.Event](client redis.Cmdable, prefix string, sm *statemachine.StateMachine[S, E]) *Store[S] {
+	return &Store[S]{Client: client, Prefix: prefix, state: sm.StateByName}
+}
+
+// Load returns an entity's state and version
+func (s *Store[S]) Load(ctx context.Context, id string) (S, int64, error) {
+	var zero S
+	fields, err := s.Client.HMGet(ctx, s.Prefix+id, "state", "version").Result()
+	if err != nil {
+		return zero, 0, fmt.Errorf("smredis: cannot load entity %s: %w", id, err)
+	}
+	name, _ := fields[0].(string)
+	v, _ := fields[1].(string)
+	if name == "" || v == "" {
+		return zero, 0, fmt.Errorf("entity %s: %w", id, statemachine.ErrNotFound)
+	}
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return zero, 0, fmt.Errorf("smredis: entity %s has version %q: %w", id, v, err)
+	}
+	state, ok := s.state(name)
+	if !ok {
+		return zero, 0, fmt.Errorf("entity %s is in state %q: %w", id, name, statemachine.ErrUnknownState)
+	}
+	return state, version, nil
+}
+
+// Save stores an entity's new state if it is still at version, and resets
+// its expiry
+func (s *Store[S]) Save(ctx context.Context, id string, from, to S, version int64) error {
+	saved, err := save.Run(ctx, s.Client, []string{s.Prefix + id}, statemachine.Name(to), version, s.TTL.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("smredis: cannot save entity %s: %w", id, err)
+	}
+	if saved == 0 {
+		return fmt.Errorf("entity %s is no longer at version %d: %w", id, version, statemachine.ErrConcurrentModification)
+	}
+	return nil
+}
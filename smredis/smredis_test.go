@@ -0,0 +1,136 @@
+package smredis_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smredis"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.SetInitialState("Cart")
+	sm.AddTransition("Cart", "Checkout", "Payment")
+	sm.AddTransition("Payment", "Pay", "Paid")
+	return sm
+}
+
+func newStore(t *testing.T) (*smredis.Store[state], *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return smredis.NewStore(rdb, "checkout:", newMachine()), mr
+}
+
+func TestStore(t *testing.T) {
+	store, mr := newStore(t)
+	ctx := context.Background()
+
+	if _, _, err := store.Load(ctx, "s1"); !errors.Is(err, statemachine.ErrNotFound) {
+		t.Fatalf("Load() of unknown entity error = %v, want %v", err, statemachine.ErrNotFound)
+	}
+
+	steps := []struct {
+		to      state
+		version int64
+		wantErr error
+	}{
+		{"Cart", 0, nil},
+		{"Cart", 0, statemachine.ErrConcurrentModification},
+		{"Payment", 1, nil},
+		{"Paid", 1, statemachine.ErrConcurrentModification},
+		{"Paid", 2, nil},
+	}
+	for i, step := range steps {
+		if err := store.Save(ctx, "s1", "", step.to, step.version); !errors.Is(err, step.wantErr) || step.wantErr == nil && err != nil {
+			t.Fatalf("step %d: Save(%s, %d) error = %v, want %v", i+1, step.to, step.version, err, step.wantErr)
+		}
+	}
+
+	got, version, err := store.Load(ctx, "s1")
+	if err != nil || got != "Paid" || version != 3 {
+		t.Errorf("Load() = %v, %d, %v, want Paid at version 3", got, version, err)
+	}
+	if v := mr.HGet("checkout:s1", "state"); v != "Paid" {
+		t.Errorf("stored state = %q, want Paid under the prefixed key", v)
+	}
+	if ttl := mr.TTL("checkout:s1"); ttl != 0 {
+		t.Errorf("TTL = %v, want no expiry", ttl)
+	}
+}
+
+func TestStore_TTL(t *testing.T) {
+	store, mr := newStore(t)
+	store.TTL = 30 * time.Minute
+	orders := statemachine.NewManager(newMachine(), store)
+	ctx := context.Background()
+
+	if err := orders.Create(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(20 * time.Minute)
+	if _, err := orders.Fire(ctx, "s1", "Checkout"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL("checkout:s1"); ttl != 30*time.Minute {
+		t.Errorf("TTL after transition = %v, want it reset to 30m", ttl)
+	}
+
+	mr.FastForward(31 * time.Minute)
+	if _, err := orders.State(ctx, "s1"); !errors.Is(err, statemachine.ErrNotFound) {
+		t.Errorf("State() of expired entity error = %v, want %v", err, statemachine.ErrNotFound)
+	}
+	if err := store.Save(ctx, "s1", "Payment", "Paid", 2); !errors.Is(err, statemachine.ErrConcurrentModification) {
+		t.Errorf("Save() of expired entity error = %v, want %v", err, statemachine.ErrConcurrentModification)
+	}
+}
+
+func TestLoad_Corrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   string
+		version string
+		wantErr string
+	}{
+		{"unknown state", "Lost", "1", `entity s1 is in state "Lost": unknown state`},
+		{"bad version", "Cart", "one", `smredis: entity s1 has version "one": strconv.ParseInt: parsing "one": invalid syntax`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, mr := newStore(t)
+			mr.HSet("checkout:s1", "state", tt.state, "version", tt.version)
+			if _, _, err := store.Load(context.Background(), "s1"); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStore_Unavailable(t *testing.T) {
+	store, mr := newStore(t)
+	mr.Close()
+	ctx := context.Background()
+
+	if _, _, err := store.Load(ctx, "s1"); err == nil || errors.Is(err, statemachine.ErrNotFound) {
+		t.Errorf("Load() error = %v, want a connection error", err)
+	}
+	if err := store.Save(ctx, "s1", "", "Cart", 0); err == nil || errors.Is(err, statemachine.ErrConcurrentModification) {
+		t.Errorf("Save() error = %v, want a connection error", err)
+	}
+}
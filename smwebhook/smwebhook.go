@@ -0,0 +1,274 @@
+// Package smwebhook notifies other systems of transitions by POSTing a
+// signed JSON payload to their URLs, so they can react to state changes
+// without polling:
+//
+//	n := smwebhook.New[OrderState, OrderEvent](smwebhook.Config{
+//		URLs:   []string{"https://billing.example.com/hooks/orders"},
+//		Secret: []byte(os.Getenv("WEBHOOK_SECRET")),
+//	})
+//	defer n.Close(context.Background())
+//	n.Subscribe(sm)
+//
+// Deliveries run in the background, so a slow or failing receiver never
+// holds up a transition. Each is retried with exponential backoff while the
+// receiver is unreachable or answers with a 5xx or 429 status.
+//
+// The payload names the entity that changed when the transition was made
+// with a context from statemachine.WithEntityID:
+//
+//	{"entityId":"42","from":"Pending","to":"Packing","event":"Confirm","timestamp":"2024-05-01T12:00:00Z"}
+//
+// The X-Statemachine-Signature header holds "sha256=" and the hex HMAC-SHA256
+// of the body keyed with the secret, which receivers check with Verify. The
+// X-Statemachine-Delivery header identifies the delivery, and is the same
+// for every attempt, so receivers can ignore repeats.
+package smwebhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richardbowden/statemachine"
+)
+
+const (
+	// SignatureHeader carries the signature of the body
+	SignatureHeader = "X-Statemachine-Signature"
+
+	// DeliveryHeader carries the ID of the delivery, the same for every
+	// attempt
+	DeliveryHeader = "X-Statemachine-Delivery"
+)
+
+// Payload is the JSON body POSTed for a transition
+type Payload struct {
+	EntityID  string    `json:"entityId,omitempty"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Config configures a Notifier
+type Config struct {
+	// URLs receive every transition
+	URLs []string
+
+	// Secret keys the signature of each payload
+	Secret []byte
+
+	// Client sends the requests; it defaults to a client with a 10 second
+	// timeout
+	Client *http.Client
+
+	// MaxAttempts limits how many times a delivery is tried; it defaults
+	// to 5
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, doubled for each retry
+	// after it up to MaxBackoff; they default to 1 second and 1 minute
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// ErrorLog receives deliveries that failed for good. If nil, the log
+	// package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// Notifier posts transitions to webhooks. Create one with New.
+type Notifier[S statemachine.State, E statemachine.Event] struct {
+	cfg Config
+
+	// stop is closed by Close to abandon retries, and ctx, which requests
+	// are made with, cancelled if Close gives up waiting for them. mu
+	// guards closing stop against deliveries starting.
+	mu     sync.Mutex
+	closed bool
+	stop   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a Notifier, filling in defaults for unset fields of cfg
+func New[S statemachine.State, E statemachine.Event](cfg Config) *Notifier[S, E] {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+	n := &Notifier[S, E]{cfg: cfg, stop: make(chan struct{})}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	return n
+}
+
+// Subscribe notifies the webhooks of every transition of sm. The returned
+// function unsubscribes.
+func (n *Notifier[S, E]) Subscribe(sm *statemachine.StateMachine[S, E]) (unsubscribe func()) {
+	return sm.Subscribe(n.Notify)
+}
+
+// Notify starts delivering a transition to every webhook. It is a
+// statemachine.Listener, for use where Subscribe does not fit.
+func (n *Notifier[S, E]) Notify(ctx context.Context, from, to S, event E, at time.Time) {
+	p := Payload{From: statemachine.Name(from), To: statemachine.Name(to), Event: statemachine.Name(event), Timestamp: at.UTC()}
+	p.EntityID, _ = statemachine.EntityIDFrom(ctx)
+	body, err := json.Marshal(p)
+	if err != nil {
+		n.logf("smwebhook: cannot encode payload: %v", err)
+		return
+	}
+	signature := Sign(n.cfg.Secret, body)
+	delivery := newDeliveryID()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		n.logf("smwebhook: notifier closed, dropping %s --%s--> %s", p.From, p.Event, p.To)
+		return
+	}
+	for _, url := range n.cfg.URLs {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			if err := n.deliver(url, delivery, signature, body); err != nil {
+				n.logf("smwebhook: delivery %s to %s failed: %v", delivery, url, err)
+			}
+		}()
+	}
+}
+
+// Close stops retrying deliveries and waits for the attempts in flight to
+// finish. If ctx is done first, it cancels their requests and returns
+// ctx's error.
+func (n *Notifier[S, E]) Close(ctx context.Context) error {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.stop)
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		n.cancel()
+		return ctx.Err()
+	}
+}
+
+// deliver posts body to url, retrying with backoff
+func (n *Notifier[S, E]) deliver(url, delivery, signature string, body []byte) error {
+	wait := n.cfg.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = n.post(url, delivery, signature, body)
+		if err == nil || !retry {
+			return err
+		}
+		if attempt == n.cfg.MaxAttempts {
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-n.stop:
+			timer.Stop()
+			return fmt.Errorf("notifier closed after %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+		wait = min(wait*2, n.cfg.MaxBackoff)
+	}
+}
+
+// post makes one attempt at a delivery, reporting whether a failure is
+// worth retrying
+func (n *Notifier[S, E]) post(url, delivery, signature string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	req.Header.Set(DeliveryHeader, delivery)
+
+	resp, err := n.cfg.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("receiver answered %s", resp.Status)
+	default:
+		return false, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+}
+
+func (n *Notifier[S, E]) logf(format string, args ...any) {
+	if n.cfg.ErrorLog != nil {
+		n.cfg.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// Sign returns the signature header value for body
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature, the value of the signature header, was
+// made for body with secret. Receivers should call it before trusting a
+// payload.
+func Verify(secret, body []byte, signature string) bool {
+	got, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	mac, err := hex.DecodeString(got)
+	if err != nil {
+		return false
+	}
+	want := hmac.New(sha256.New, secret)
+	want.Write(body)
+	return hmac.Equal(mac, want.Sum(nil))
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,253 @@
+package smwebhook_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/smwebhook"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+var secret = []byte("s3cret")
+
+// receiver records the deliveries it is sent, answering each attempt with
+// the next of its statuses and then 200
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header.Clone())
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) attempts() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.bodies)
+}
+
+func newMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Pending", "Confirm", "Packing")
+	return sm
+}
+
+func transition(t *testing.T, sm *statemachine.StateMachine[state, event]) {
+	t.Helper()
+	ctx := statemachine.WithEntityID(context.Background(), "42")
+	if _, err := sm.TransitionContext(ctx, "Pending", "Confirm"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNotifier_Delivers(t *testing.T) {
+	rec := &receiver{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	sm := newMachine()
+	n := smwebhook.New[state, event](smwebhook.Config{URLs: []string{srv.URL, srv.URL}, Secret: secret})
+	n.Subscribe(sm)
+	transition(t, sm)
+	if err := n.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.attempts() != 2 {
+		t.Fatalf("received %d deliveries, want one per URL", rec.attempts())
+	}
+	body, header := rec.bodies[0], rec.headers[0]
+	if !smwebhook.Verify(secret, body, header.Get(smwebhook.SignatureHeader)) {
+		t.Errorf("signature %q does not verify", header.Get(smwebhook.SignatureHeader))
+	}
+	if header.Get("Content-Type") != "application/json" || header.Get(smwebhook.DeliveryHeader) == "" {
+		t.Errorf("headers = %v", header)
+	}
+	var p smwebhook.Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.EntityID != "42" || p.From != "Pending" || p.To != "Packing" || p.Event != "Confirm" || p.Timestamp.IsZero() {
+		t.Errorf("payload = %+v", p)
+	}
+}
+
+func TestNotifier_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantLogged   string
+	}{
+		{name: "server error then success", statuses: []int{500, 503}, wantAttempts: 3},
+		{name: "rate limited", statuses: []int{429}, wantAttempts: 2},
+		{name: "client error is not retried", statuses: []int{400}, wantAttempts: 1, wantLogged: "400 Bad Request"},
+		{name: "gives up", statuses: []int{500, 500, 500, 500}, wantAttempts: 3, wantLogged: "gave up after 3 attempts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &receiver{statuses: tt.statuses}
+			srv := httptest.NewServer(rec)
+			defer srv.Close()
+
+			var logged bytes.Buffer
+			sm := newMachine()
+			n := smwebhook.New[state, event](smwebhook.Config{
+				URLs:        []string{srv.URL},
+				Secret:      secret,
+				MaxAttempts: 3,
+				Backoff:     time.Millisecond,
+				ErrorLog:    log.New(&logged, "", 0),
+			})
+			n.Subscribe(sm)
+			transition(t, sm)
+
+			// wait for the deliveries to finish before closing, which
+			// would abandon retries
+			deadline := time.Now().Add(5 * time.Second)
+			for rec.attempts() < tt.wantAttempts && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if err := n.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if rec.attempts() != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", rec.attempts(), tt.wantAttempts)
+			}
+			ids := map[string]bool{}
+			for _, h := range rec.headers {
+				ids[h.Get(smwebhook.DeliveryHeader)] = true
+			}
+			if len(ids) != 1 {
+				t.Errorf("delivery IDs = %v, want the same for every attempt", ids)
+			}
+			if tt.wantLogged == "" && logged.Len() > 0 || !strings.Contains(logged.String(), tt.wantLogged) {
+				t.Errorf("logged %q, want %q", logged.String(), tt.wantLogged)
+			}
+		})
+	}
+}
+
+func TestNotifier_CloseAbandonsRetries(t *testing.T) {
+	rec := &receiver{statuses: []int{500}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	var logged bytes.Buffer
+	sm := newMachine()
+	n := smwebhook.New[state, event](smwebhook.Config{
+		URLs:     []string{srv.URL},
+		Backoff:  time.Hour,
+		ErrorLog: log.New(&logged, "", 0),
+	})
+	n.Subscribe(sm)
+	transition(t, sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for rec.attempts() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := n.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v, want retries abandoned", err)
+	}
+	if !strings.Contains(logged.String(), "notifier closed after 1 attempts") {
+		t.Errorf("logged %q, want the delivery abandoned", logged.String())
+	}
+
+	// transitions after Close are dropped
+	n.Notify(context.Background(), "Pending", "Packing", "Confirm", time.Now())
+	if rec.attempts() != 1 {
+		t.Errorf("attempts = %d after Close, want 1", rec.attempts())
+	}
+}
+
+func TestNotifier_CloseCancelsAttemptsInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	var logged bytes.Buffer
+	sm := newMachine()
+	n := smwebhook.New[state, event](smwebhook.Config{
+		URLs:     []string{srv.URL},
+		Client:   &http.Client{Timeout: time.Hour},
+		ErrorLog: log.New(&logged, "", 0),
+	})
+	n.Subscribe(sm)
+	transition(t, sm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	<-started
+	if err := n.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// once cancelled, the attempt finishes without being retried
+	if err := n.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v after cancelling", err)
+	}
+	if got := logged.String(); !strings.Contains(got, "notifier closed after 1 attempts") || !strings.Contains(got, context.Canceled.Error()) {
+		t.Errorf("logged %q, want the attempt cancelled", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"from":"Pending"}`)
+	sig := smwebhook.Sign(secret, body)
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid", secret, body, sig, true},
+		{"wrong secret", []byte("other"), body, sig, false},
+		{"tampered body", secret, []byte(`{"from":"Shipped"}`), sig, false},
+		{"missing prefix", secret, body, strings.TrimPrefix(sig, "sha256="), false},
+		{"not hex", secret, body, "sha256=zz", false},
+	}
+	for _, tt := range tests {
+		if got := smwebhook.Verify(tt.secret, tt.body, tt.signature); got != tt.want {
+			t.Errorf("%s: Verify() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
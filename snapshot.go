@@ -0,0 +1,233 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Snapshot captures an Instance so it can be stored, for example as JSON,
+// and restored later, letting a long-running workflow survive a restart
+type Snapshot[S State] struct {
+	// State is the instance's current state
+	State S `json:"state"`
+
+	// History holds, for each composite state the instance has left, the
+	// substates it will resume through a history transition, sorted by
+	// composite state
+	History []HistoryEntry[S] `json:"history,omitempty"`
+
+	// Data holds the values stored with SetData. Values restored from
+	// JSON are decoded as the encoding/json package decodes into an any:
+	// numbers become float64, objects map[string]any and so on.
+	Data map[string]any `json:"data,omitempty"`
+
+	// Version is the instance's version
+	Version int64 `json:"version,omitempty"`
+
+	// Deadline is when the timeout of the instance's state fires, if it
+	// has one
+	Deadline time.Time `json:"deadline,omitzero"`
+
+	// Scheduled holds the events scheduled with FireAt and FireAfter,
+	// soonest first
+	Scheduled []ScheduledEntry `json:"scheduled,omitempty"`
+
+	// EnteredAt is when the instance entered its state
+	EnteredAt time.Time `json:"enteredAt,omitzero"`
+
+	// Dwell holds the time the instance has spent in each state it has
+	// left, sorted by state
+	Dwell []DwellEntry[S] `json:"dwell,omitempty"`
+
+	// Timeline holds the frames recorded by an instance of a machine
+	// created WithTimeline, oldest first
+	Timeline []Frame[S] `json:"timeline,omitempty"`
+
+	// Deferred holds the events the instance is holding on to, named as
+	// Name names them, in the order they were fired
+	Deferred []string `json:"deferred,omitempty"`
+}
+
+// DwellEntry is the time an instance has spent in a state
+type DwellEntry[S State] struct {
+	State    S             `json:"state"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ScheduledEntry is a scheduled event, named as Name names it
+type ScheduledEntry struct {
+	ID    int64     `json:"id"`
+	At    time.Time `json:"at"`
+	Event string    `json:"event"`
+}
+
+// HistoryEntry is the remembered history of a composite state
+type HistoryEntry[S State] struct {
+	State S `json:"state"`
+
+	// Substate is the direct substate last active, resumed by
+	// ShallowHistory
+	Substate S `json:"substate"`
+
+	// Leaf is the innermost state last active, resumed by DeepHistory
+	Leaf S `json:"leaf"`
+}
+
+// Snapshot captures the instance's current state, history, data, version,
+// timeout deadline, scheduled events, dwell times, timeline and deferred
+// events
+func (i *Instance[S, E]) Snapshot() Snapshot[S] {
+	snap := Snapshot[S]{State: i.current, Data: maps.Clone(i.data), Version: i.version, Deadline: i.deadline, EnteredAt: i.enteredAt}
+	snap.Timeline = append(snap.Timeline, i.frames...)
+	for _, d := range i.deferred {
+		snap.Deferred = append(snap.Deferred, Name(d.event))
+	}
+	for state, d := range i.dwell {
+		snap.Dwell = append(snap.Dwell, DwellEntry[S]{State: state, Duration: d})
+	}
+	slices.SortFunc(snap.Dwell, func(a, b DwellEntry[S]) int {
+		return strings.Compare(Name(a.State), Name(b.State))
+	})
+	for _, s := range i.scheduled {
+		snap.Scheduled = append(snap.Scheduled, ScheduledEntry{ID: s.ID, At: s.At, Event: Name(s.Event)})
+	}
+	for composite, child := range i.history.shallow {
+		snap.History = append(snap.History, HistoryEntry[S]{
+			State:    composite,
+			Substate: child,
+			Leaf:     i.history.deep[composite],
+		})
+	}
+	slices.SortFunc(snap.History, func(a, b HistoryEntry[S]) int {
+		return strings.Compare(Name(a.State), Name(b.State))
+	})
+	return snap
+}
+
+// Restore replaces the instance's current state, history, data, version,
+// deadline, scheduled events, dwell times, timeline and deferred events
+// with those captured in snap. A Snapshot holding only a State and Version
+// resumes an entity loaded from a database row; if its state has a timeout,
+// the deadline is counted from the restore, as is the time in its state.
+// Call FireDue after restoring to fire the timeouts and scheduled events
+// that fell due while the instance was stored.
+//
+// Restore returns an error matching ErrUnknownState or ErrUnknownEvent,
+// leaving the instance unchanged, if snap names a state or schedules or
+// defers an event the machine does not have. Restored deferred events are
+// delivered with an empty context.
+func (i *Instance[S, E]) Restore(snap Snapshot[S]) error {
+	i.sm.rlock()
+	defer i.sm.runlock()
+
+	states := []S{snap.State}
+	for _, h := range snap.History {
+		states = append(states, h.State, h.Substate, h.Leaf)
+	}
+	for _, f := range snap.Timeline {
+		states = append(states, f.State)
+	}
+	for _, s := range states {
+		if !i.sm.hasState(s) {
+			return fmt.Errorf("cannot restore snapshot: state '%s': %w", Name(s), ErrUnknownState)
+		}
+	}
+	scheduled, err := i.sm.resolveScheduled(snap.Scheduled)
+	if err != nil {
+		return err
+	}
+	deferred, err := i.sm.resolveDeferred(snap.Deferred)
+	if err != nil {
+		return err
+	}
+
+	i.current = snap.State
+	i.history = history[S]{}
+	for _, h := range snap.History {
+		if i.history.shallow == nil {
+			i.history.shallow = make(map[S]S)
+			i.history.deep = make(map[S]S)
+		}
+		i.history.shallow[h.State] = h.Substate
+		i.history.deep[h.State] = h.Leaf
+	}
+	i.data = maps.Clone(snap.Data)
+	i.version = snap.Version
+	i.scheduled = scheduled
+	i.nextID = 0
+	for _, s := range scheduled {
+		i.nextID = max(i.nextID, s.ID)
+	}
+	i.enteredAt = snap.EnteredAt
+	if i.enteredAt.IsZero() {
+		i.enteredAt = i.sm.clock.Now()
+	}
+	i.dwell = nil
+	for _, d := range snap.Dwell {
+		if i.dwell == nil {
+			i.dwell = make(map[S]time.Duration, len(snap.Dwell))
+		}
+		i.dwell[d.State] = d.Duration
+	}
+	i.frames = append([]Frame[S](nil), snap.Timeline...)
+	if len(i.frames) == 0 {
+		i.record("", false)
+	}
+	i.deferred = deferred
+	i.deadline = time.Time{}
+	if timed, t, ok := i.sm.findTimeout(snap.State); ok {
+		i.timed = timed
+		i.deadline = snap.Deadline
+		if i.deadline.IsZero() {
+			i.deadline = i.sm.clock.Now().Add(t.after)
+		}
+	}
+	return nil
+}
+
+// resolveScheduled finds the events of scheduled entries by name, sorting
+// them soonest first. sm must be locked.
+func (sm *StateMachine[S, E]) resolveScheduled(entries []ScheduledEntry) ([]ScheduledEvent[E], error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	byName := make(map[string]E)
+	for _, e := range sm.allEvents() {
+		byName[Name(e)] = e
+	}
+	scheduled := make([]ScheduledEvent[E], 0, len(entries))
+	for _, entry := range entries {
+		event, ok := byName[entry.Event]
+		if !ok {
+			return nil, fmt.Errorf("cannot restore snapshot: event '%s': %w", entry.Event, ErrUnknownEvent)
+		}
+		scheduled = append(scheduled, ScheduledEvent[E]{ID: entry.ID, At: entry.At, Event: event})
+	}
+	slices.SortFunc(scheduled, compareScheduled)
+	return scheduled, nil
+}
+
+// resolveDeferred finds the deferred events named by names. sm must be
+// locked.
+func (sm *StateMachine[S, E]) resolveDeferred(names []string) ([]queuedEvent[E], error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	byName := make(map[string]E)
+	for _, e := range sm.allEvents() {
+		byName[Name(e)] = e
+	}
+	deferred := make([]queuedEvent[E], 0, len(names))
+	for _, name := range names {
+		event, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot restore snapshot: event '%s': %w", name, ErrUnknownEvent)
+		}
+		deferred = append(deferred, queuedEvent[E]{ctx: context.Background(), event: event})
+	}
+	return deferred, nil
+}
@@ -0,0 +1,103 @@
+package statemachine
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := newReviewMachine(t, WithClock(clock))
+	inst := sm.NewInstanceAt(docDraft)
+	for _, e := range []docEvent{docSubmit, docAdvance, docSign, docPause} {
+		clock.advance(time.Minute)
+		fireAll(t, inst, e)
+	}
+	inst.SetData("reviewer", "alice")
+	inst.SetData("attempts", 2)
+
+	b, err := json.Marshal(inst.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"state":"Paused","history":[` +
+		`{"state":"Legal","substate":"LegalSignoff","leaf":"LegalSignoff"},` +
+		`{"state":"Review","substate":"Legal","leaf":"LegalSignoff"}],` +
+		`"data":{"attempts":2,"reviewer":"alice"},"version":4,"enteredAt":"2024-05-01T12:04:00Z",` +
+		`"dwell":[{"state":"Draft","duration":60000000000},{"state":"FirstReview","duration":60000000000},` +
+		`{"state":"LegalDraft","duration":60000000000},{"state":"LegalSignoff","duration":60000000000}]}`
+	if string(b) != want {
+		t.Errorf("snapshot JSON =\n%s\nwant\n%s", b, want)
+	}
+
+	// a new process restores the instance and resumes where it left off
+	var snap Snapshot[docState]
+	if err := json.Unmarshal(b, &snap); err != nil {
+		t.Fatal(err)
+	}
+	restored := sm.NewInstanceAt(docDraft)
+	if err := restored.Restore(snap); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if !restored.Is(docPaused) {
+		t.Errorf("Current() = %v, want %v", restored.Current(), docPaused)
+	}
+	if v, _ := restored.Data("reviewer"); v != "alice" {
+		t.Errorf("Data(reviewer) = %v, want alice", v)
+	}
+	if v, _ := restored.Data("attempts"); v != float64(2) {
+		t.Errorf("Data(attempts) = %#v, want the JSON number", v)
+	}
+	if restored.Version() != 4 {
+		t.Errorf("Version() = %d, want 4", restored.Version())
+	}
+	clock.advance(time.Minute)
+	if d := restored.TimeInCurrentState(); d != time.Minute {
+		t.Errorf("TimeInCurrentState() = %v, want 1m", d)
+	}
+	if d := restored.TimeInState(docDraft); d != time.Minute {
+		t.Errorf("TimeInState(%v) = %v, want 1m", docDraft, d)
+	}
+	fireAll(t, restored, docResume)
+	if !restored.Is(docLegalSignoff) {
+		t.Errorf("after Resume, Current() = %v, want deep history %v", restored.Current(), docLegalSignoff)
+	}
+}
+
+func TestSnapshot_Independent(t *testing.T) {
+	sm := newReviewMachine(t)
+	inst := sm.NewInstanceAt(docDraft)
+	inst.SetData("reviewer", "alice")
+	snap := inst.Snapshot()
+
+	inst.SetData("reviewer", "bob")
+	if snap.Data["reviewer"] != "alice" {
+		t.Errorf("snapshot data changed with the instance: %v", snap.Data)
+	}
+	if _, ok := sm.NewInstanceAt(docDraft).Data("reviewer"); ok {
+		t.Error("Data() found a value on a new instance")
+	}
+}
+
+func TestRestore_UnknownState(t *testing.T) {
+	tests := []struct {
+		name string
+		snap Snapshot[docState]
+	}{
+		{"state", Snapshot[docState]{State: "Archived"}},
+		{"history", Snapshot[docState]{State: docPaused, History: []HistoryEntry[docState]{{State: docReview, Substate: "Gone", Leaf: "Gone"}}}},
+	}
+
+	for _, tt := range tests {
+		inst := newReviewMachine(t).NewInstanceAt(docDraft)
+		inst.SetData("reviewer", "alice")
+		if err := inst.Restore(tt.snap); !errors.Is(err, ErrUnknownState) {
+			t.Errorf("%s: Restore() error = %v, want %v", tt.name, err, ErrUnknownState)
+		}
+		if v, _ := inst.Data("reviewer"); !inst.Is(docDraft) || v != "alice" {
+			t.Errorf("%s: failed Restore() changed the instance", tt.name)
+		}
+	}
+}
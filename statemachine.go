@@ -1,37 +1,205 @@
 package statemachine
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
 
-// State is a constraint for types that can be used as states
+// State is a constraint for types that can be used as states. Any
+// comparable type will do, such as a string or int enum, with or without a
+// String method; see Name for how states are named.
 type State interface {
 	comparable
-	String() string
 }
 
-// Event is a constraint for types that can be used as events
+// Event is a constraint for types that can be used as events. Like states,
+// events may be of any comparable type.
 type Event interface {
 	comparable
-	String() string
+}
+
+// Name returns the name of a state or event, used in errors, diagrams,
+// definitions and stores: its String value if it has a String method, or
+// its value as fmt formats it otherwise, so a state of a plain int type
+// is named "3"
+func Name[T comparable](v T) string {
+	if s, ok := any(v).(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v)
 }
 
 // StateMachine is a generic state machine that works with any State and Event types
 type StateMachine[S State, E Event] struct {
-	transitions map[S]map[E]S
+	transitions map[S]map[E][]*edge[S, E]
+	onEnter     map[S][]Callback[S, E]
+	onExit      map[S][]Callback[S, E]
+	onFinal     []Callback[S, E]
+	vetoes      []Veto[S, E]
+	parents     map[S]S
+	initial     map[S]S
+	final       map[S]bool
+	stateMeta   map[S]Metadata
+	declared    map[S]bool
+	events      map[E]bool
+	middleware  []Middleware[S, E]
+	guardMW     []GuardMiddleware[S, E]
+	actionMW    []ActionMiddleware[S, E]
+	listeners   listeners[S, E]
+	coverage    *coverage[S, E]
+	dwell       *dwellStats[S]
+	stats       *stats[S, E]
+	logger      *slog.Logger
+	timeouts    map[S]timeout[E]
+	crons       []cronTrigger[S, E]
+	clock       Clock
+
+	// stateIndex and eventIndex number states and events for a machine
+	// created by NewIndexedStateMachine, whose table is built when it is
+	// frozen
+	stateIndex func(S) int
+	eventIndex func(E) int
+	table      *transitionTable[S, E]
+
+	// undoDepth and timelineDepth are how many transitions instances
+	// remember for Undo and record for Timeline
+	undoDepth     int
+	timelineDepth int
+
+	// automatic lists the events with automatic transitions from each
+	// state, and maxAutomatic how many may follow one transition
+	automatic    map[S][]E
+	maxAutomatic int
+
+	// deferred lists the events deferred in each state
+	deferred map[S][]E
+
+	// deadLetters receives the events rejected as invalid
+	deadLetters DeadLetterSink[S, E]
+
+	// cache is built when the machine is frozen
+	cache *readCache[S, E]
+
+	initialState    S
+	hasInitialState bool
+	version         int
+
+	mu      sync.RWMutex
+	locking bool
+	strict  bool
+	acyclic bool
+	frozen  atomic.Bool
 }
 
 // NewStateMachine creates a new generic state machine
-func NewStateMachine[S State, E Event]() *StateMachine[S, E] {
-	return &StateMachine[S, E]{
-		transitions: make(map[S]map[E]S),
+func NewStateMachine[S State, E Event](opts ...Option) *StateMachine[S, E] {
+	cfg := newConfig(opts)
+	sm := &StateMachine[S, E]{
+		transitions: make(map[S]map[E][]*edge[S, E]),
+		onEnter:     make(map[S][]Callback[S, E]),
+		onExit:      make(map[S][]Callback[S, E]),
+		parents:     make(map[S]S),
+		initial:     make(map[S]S),
+		final:       make(map[S]bool),
+		stateMeta:   make(map[S]Metadata),
+		declared:    make(map[S]bool),
+		events:      make(map[E]bool),
+		timeouts:    make(map[S]timeout[E]),
+		locking:     cfg.locking,
+		strict:      cfg.strict,
+		acyclic:     cfg.acyclic,
+		logger:      cfg.logger,
+		clock:       cfg.clock,
+	}
+	sm.undoDepth, sm.timelineDepth = cfg.undo, cfg.timeline
+	sm.maxAutomatic = cfg.maxAutomatic
+	if cfg.coverage {
+		sm.coverage = &coverage[S, E]{taken: make(map[*edge[S, E]]bool)}
+	}
+	if cfg.dwell {
+		sm.dwell = &dwellStats[S]{stats: make(map[S]*DwellStat[S])}
+	}
+	if cfg.stats {
+		sm.stats = newStats[S, E]()
+	}
+	return sm
+}
+
+// edge is a registered transition along with anything attached to it
+type edge[S State, E Event] struct {
+	to       S
+	history  HistoryKind
+	priority int
+	guards   []Guard[S, E]
+	actions  []Action[S, E]
+
+	// guardNames names the guards, when they were resolved by name as a
+	// document was loaded
+	guardNames []string
+
+	// permissions are required of the transition's principal
+	permissions []string
+
+	// compensations undo the actions, for a Saga
+	compensations []Action[S, E]
+
+	// resolve picks the target of a choice transition among choices
+	resolve Resolver[S, E]
+	choices []S
+
+	meta    Metadata
+	hasMeta bool
+
+	// automatic transitions fire by themselves once their state is entered
+	automatic bool
+}
+
+// AddTransition adds a valid transition to the state machine.
+// Options attach behaviour such as actions to the transition.
+//
+// Several guarded transitions may be added for the same state and event; see
+// WithPriority for the order they are tried in. Adding a transition without
+// guards replaces any existing unguarded one for the state and event, unless
+// the machine was created WithStrict, in which case replacing one with a
+// different target panics. On a machine created WithAcyclic, adding a
+// transition that would create a cycle panics too.
+func (sm *StateMachine[S, E]) AddTransition(from S, event E, to S, opts ...TransitionOption[S, E]) {
+	if err := sm.addTransition(from, event, to, opts, sm.strict); err != nil {
+		panic("statemachine: " + err.Error())
 	}
 }
 
-// AddTransition adds a valid transition to the state machine
-func (sm *StateMachine[S, E]) AddTransition(from S, event E, to S) {
+// addTransition adds a transition, first checking it does not conflict with
+// an existing one when strict is set, or create a cycle on an acyclic machine
+func (sm *StateMachine[S, E]) addTransition(from S, event E, to S, opts []TransitionOption[S, E], strict bool) error {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	e := &edge[S, E]{to: to}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if strict {
+		if err := sm.checkConflict(from, event, e); err != nil {
+			return err
+		}
+	}
+	if sm.acyclic {
+		if err := sm.checkAcyclic(from, event, e); err != nil {
+			return err
+		}
+	}
 	if sm.transitions[from] == nil {
-		sm.transitions[from] = make(map[E]S)
+		sm.transitions[from] = make(map[E][]*edge[S, E])
 	}
-	sm.transitions[from][event] = to
+	sm.transitions[from][event] = addEdge(sm.transitions[from][event], e)
+	sm.noteAutomatic(from, event, e)
+	return nil
 }
 
 // AddTransitions adds multiple transitions at once
@@ -50,58 +218,382 @@ type Transition[S State, E Event] struct {
 
 // CanTransition checks if a transition is valid
 func (sm *StateMachine[S, E]) CanTransition(from S, event E) bool {
-	if transitions, exists := sm.transitions[from]; exists {
-		_, allowed := transitions[event]
-		return allowed
-	}
-	return false
+	sm.rlock()
+	defer sm.runlock()
+
+	return len(sm.edgesFor(from, event)) > 0
 }
 
 // Transition attempts to transition from current state via event
 // Returns the new state or an error if transition is invalid
 func (sm *StateMachine[S, E]) Transition(from S, event E) (S, error) {
-	if transitions, exists := sm.transitions[from]; exists {
-		if newState, allowed := transitions[event]; allowed {
-			return newState, nil
+	return sm.TransitionContext(context.Background(), from, event)
+}
+
+// MustTransition is like Transition but panics if the transition fails. It
+// is meant for tests and for paths known to be valid.
+func (sm *StateMachine[S, E]) MustTransition(from S, event E) S {
+	to, err := sm.Transition(from, event)
+	if err != nil {
+		panic("statemachine: " + err.Error())
+	}
+	return to
+}
+
+// TryTransition is like Transition but reports whether the transition was
+// made instead of returning an error. An event with no transition from the
+// state is turned down before any error describing it is built, so
+// TryTransition suits hot loops where events are often invalid. Use
+// Transition to learn why a transition failed. On a machine with middleware
+// or a logger, which see every attempt, the event is tried as Transition
+// would try it.
+func (sm *StateMachine[S, E]) TryTransition(from S, event E) (S, bool) {
+	return sm.TryTransitionContext(context.Background(), from, event)
+}
+
+// TryTransitionContext is like TryTransition but passes ctx to the
+// transition, and to the dead-letter sink if the event is turned down
+func (sm *StateMachine[S, E]) TryTransitionContext(ctx context.Context, from S, event E) (S, bool) {
+	if !sm.CanTransition(from, event) && sm.logger == nil && !sm.hasMiddleware() {
+		var zero S
+		sm.stats.record(from, event, zero, ErrInvalidTransition)
+		sm.deadLetterRejected(ctx, from, event)
+		return zero, false
+	}
+	to, err := sm.TransitionContext(ctx, from, event)
+	return to, err == nil
+}
+
+// TransitionContext is like Transition but passes ctx to the guards, actions
+// and callbacks that run as part of the transition. Guards are checked first,
+// then BeforeTransition hooks, then actions run; if a hook or action fails
+// the transition is aborted, no callbacks run and the error is returned.
+//
+// The transition is abandoned, with an error wrapping the context's, if ctx
+// is done before it starts, by the time its guards have been checked, or
+// before any of its actions. Actions that already ran are not undone, as
+// when an action fails, so they should check ctx themselves before doing
+// anything they cannot take back. Once every action has run the transition
+// completes, running its callbacks whatever the state of ctx.
+//
+// Transitions declared on a state take precedence over those inherited from
+// its parents. If the guards of a state's own transition reject the event,
+// the parent's transition for the same event is tried next.
+func (sm *StateMachine[S, E]) TransitionContext(ctx context.Context, from S, event E) (S, error) {
+	return sm.transition(ctx, from, event, nil)
+}
+
+// transition performs a transition through the machine's middleware, using h
+// to resolve history transitions and recording the states left into it.
+// h may be nil.
+func (sm *StateMachine[S, E]) transition(ctx context.Context, from S, event E, h *history[S]) (S, error) {
+	if d, ok := sm.directTransition(from, event); ok && (h == nil || !d.leaves) {
+		return sm.transitionDirect(ctx, from, event, d)
+	}
+	sm.logAttempt(ctx, from, event)
+	var to S
+	var err error
+	if sm.hasMiddleware() {
+		fire := sm.wrap(func(ctx context.Context, from S, event E) (S, error) {
+			return sm.fire(ctx, from, event, h)
+		})
+		to, err = fire(ctx, from, event)
+	} else {
+		// without middleware to wrap, fire is called directly rather than
+		// through a closure, which would be allocated
+		to, err = sm.fire(ctx, from, event, h)
+	}
+	sm.logResult(ctx, from, event, to, err)
+	sm.stats.record(from, event, to, err)
+	if err != nil {
+		sm.deadLetter(ctx, from, event, err)
+		return to, err
+	}
+	sm.listeners.notify(ctx, sm.clock, from, to, event)
+	return to, nil
+}
+
+// transitionDirect makes a transition found in the read cache of a frozen
+// machine, which has nothing to run but its callbacks
+func (sm *StateMachine[S, E]) transitionDirect(ctx context.Context, from S, event E, d *directTransition[S, E]) (S, error) {
+	if err := checkContext(ctx, from, event); err != nil {
+		var zero S
+		return zero, err
+	}
+	for _, fn := range d.exit {
+		fn(ctx, from, d.to, event)
+	}
+	for _, fn := range d.enter {
+		fn(ctx, from, d.to, event)
+	}
+	for _, fn := range d.final {
+		fn(ctx, from, d.to, event)
+	}
+	sm.listeners.notify(ctx, sm.clock, from, d.to, event)
+	return d.to, nil
+}
+
+// fire performs a transition: guards, then actions, then callbacks
+func (sm *StateMachine[S, E]) fire(ctx context.Context, from S, event E, h *history[S]) (S, error) {
+	var zero S
+	if err := checkContext(ctx, from, event); err != nil {
+		return zero, err
+	}
+	sm.rlock()
+	edges := sm.edgesFor(from, event)
+	if len(edges) == 0 {
+		err := sm.transitionError(from, event)
+		sm.runlock()
+		return zero, err
+	}
+	guardMW := sm.guardMW
+	sm.runlock()
+
+	var e *edge[S, E]
+	for _, candidate := range edges {
+		ok := candidate.allowed(ctx, from, event, guardMW)
+		if len(candidate.guards) > 0 {
+			sm.logGuards(ctx, from, event, candidate.to, ok)
+		}
+		if ok {
+			e = candidate
+			break
+		}
+	}
+	if err := checkContext(ctx, from, event); err != nil {
+		// a guard may have refused because ctx was done
+		return zero, err
+	}
+	if e == nil {
+		if err, ok := sm.cache.guardRejection(from, event); ok {
+			return zero, err
+		}
+		sm.rlock()
+		defer sm.runlock()
+		return zero, &TransitionError[S, E]{
+			From:          from,
+			Event:         event,
+			ValidEvents:   sm.validEvents(from),
+			guardRejected: true,
 		}
 	}
+
+	to, err := sm.target(ctx, e, from, event, h)
+	if err != nil {
+		return zero, err
+	}
+	t := taken[S, E]{e: e, from: from, to: to, event: event}
+	if err := sm.prepare(ctx, t); err != nil {
+		return zero, err
+	}
+	return sm.settle(ctx, t, h)
+}
+
+// target checks the principal may make the transition e chosen for event
+// from a state, and resolves the state it leads to
+func (sm *StateMachine[S, E]) target(ctx context.Context, e *edge[S, E], from S, event E, h *history[S]) (S, error) {
 	var zero S
-	return zero, fmt.Errorf("invalid transition: cannot process event '%s' from state '%s'", event.String(), from.String())
+	if err := e.authorize(ctx, from, event); err != nil {
+		return zero, err
+	}
+	target, err := e.choose(ctx, from, event)
+	if err != nil {
+		return zero, err
+	}
+	sm.rlock()
+	defer sm.runlock()
+	return sm.resolveTarget(target, e.history, h), nil
+}
+
+// taken is a transition chosen and resolved to the state it leads to
+type taken[S State, E Event] struct {
+	e        *edge[S, E]
+	from, to S
+	event    E
+}
+
+// prepare runs the vetoes and then the actions of t
+func (sm *StateMachine[S, E]) prepare(ctx context.Context, t taken[S, E]) error {
+	if err := sm.checkVetoes(ctx, t); err != nil {
+		return err
+	}
+	sm.rlock()
+	actionMW := sm.actionMW
+	sm.runlock()
+	return t.e.runActions(ctx, t.from, t.to, t.event, actionMW)
+}
+
+// checkVetoes runs the machine's BeforeTransition hooks on t
+func (sm *StateMachine[S, E]) checkVetoes(ctx context.Context, t taken[S, E]) error {
+	sm.rlock()
+	vetoes := sm.vetoes
+	sm.runlock()
+
+	for _, veto := range vetoes {
+		if err := veto(ctx, t.from, t.event, t.to); err != nil {
+			return fmt.Errorf("event '%s' from state '%s' to '%s': %w: %w", Name(t.event), Name(t.from), Name(t.to), ErrVetoed, err)
+		}
+	}
+	return nil
+}
+
+// commit completes t once it has been prepared: its callbacks run, and it
+// is recorded for coverage and in h
+func (sm *StateMachine[S, E]) commit(ctx context.Context, t taken[S, E], h *history[S]) {
+	noteCompensations(ctx, t.e)
+	sm.runCallbacks(ctx, t.from, t.to, t.event)
+	sm.coverage.record(t.e)
+	sm.noteHistory(h, t)
+}
+
+// lookup resolves the target of a transition without running any guards,
+// actions or callbacks
+func (sm *StateMachine[S, E]) lookup(from S, event E) (S, error) {
+	edges := sm.edgesFor(from, event)
+	if len(edges) == 0 {
+		var zero S
+		return zero, sm.transitionError(from, event)
+	}
+	return sm.resolveTarget(edges[0].to, NoHistory, nil), nil
+}
+
+// edgesFor returns the transitions that could handle event from the given
+// state in the order they are tried: the state's own by priority, followed by
+// those inherited from each ancestor in turn
+func (sm *StateMachine[S, E]) edgesFor(from S, event E) []*edge[S, E] {
+	if edges, ok := sm.indexedEdges(from, event); ok {
+		return edges
+	}
+	edges := sm.transitions[from][event]
+	for state, ok := sm.parents[from]; ok; state, ok = sm.parents[state] {
+		inherited := sm.transitions[state][event]
+		switch {
+		case len(inherited) == 0:
+		case len(edges) == 0:
+			edges = inherited
+		default:
+			if merged, ok := sm.cache.mergedEdges(from, event); ok {
+				return merged
+			}
+			// clipped so the stored transitions are never appended to
+			edges = append(slices.Clip(edges), inherited...)
+		}
+	}
+	return edges
+}
+
+// effectiveTransitions returns every transition available from a state,
+// including inherited ones, with a state's own transitions overriding its
+// ancestors'. Where transitions compete for an event, the first tried is
+// returned.
+func (sm *StateMachine[S, E]) effectiveTransitions(from S) map[E]*edge[S, E] {
+	result := make(map[E]*edge[S, E])
+	for _, state := range sm.lineage(from) {
+		for event, edges := range sm.transitions[state] {
+			if _, exists := result[event]; !exists {
+				result[event] = edges[0]
+			}
+		}
+	}
+	return result
+}
+
+// transitionError describes why event cannot be processed from a state
+func (sm *StateMachine[S, E]) transitionError(from S, event E) error {
+	if err, ok := sm.cache.rejection(from, event); ok {
+		return err
+	}
+	return &TransitionError[S, E]{
+		From:         from,
+		Event:        event,
+		ValidEvents:  sm.validEvents(from),
+		unknownState: !sm.hasState(from),
+	}
+}
+
+// hasState reports whether state is the source or target of any transition,
+// or part of a state hierarchy
+func (sm *StateMachine[S, E]) hasState(state S) bool {
+	if _, exists := sm.transitions[state]; exists {
+		return true
+	}
+	if _, exists := sm.parents[state]; exists {
+		return true
+	}
+	if sm.final[state] || sm.declared[state] || (sm.hasInitialState && sm.initialState == state) {
+		return true
+	}
+	for _, parent := range sm.parents {
+		if parent == state {
+			return true
+		}
+	}
+	for _, transitions := range sm.transitions {
+		for _, edges := range transitions {
+			for _, e := range edges {
+				for _, to := range e.targets() {
+					if to == state {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
 }
 
-// GetValidEvents returns all valid events for a given state
+// GetValidEvents returns all valid events for a given state, sorted by name
 func (sm *StateMachine[S, E]) GetValidEvents(from S) []E {
+	sm.rlock()
+	defer sm.runlock()
+
+	return sm.validEvents(from)
+}
+
+func (sm *StateMachine[S, E]) validEvents(from S) []E {
 	events := []E{}
-	if transitions, exists := sm.transitions[from]; exists {
-		for event := range transitions {
-			events = append(events, event)
-		}
+	for event := range sm.effectiveTransitions(from) {
+		events = append(events, event)
 	}
+	sortEvents(events)
 	return events
 }
 
 // GetNextState returns the state that would result from an event, without validation
 func (sm *StateMachine[S, E]) GetNextState(from S, event E) (S, bool) {
-	if transitions, exists := sm.transitions[from]; exists {
-		if newState, allowed := transitions[event]; allowed {
-			return newState, true
-		}
+	sm.rlock()
+	defer sm.runlock()
+
+	if edges := sm.edgesFor(from, event); len(edges) > 0 {
+		return sm.resolveTarget(edges[0].to, NoHistory, nil), true
 	}
 	var zero S
 	return zero, false
 }
 
-// IsTerminalState checks if a state is terminal (no outgoing transitions)
+// IsTerminalState checks if a state is terminal (no outgoing transitions,
+// including inherited ones). A terminal state is not necessarily final; use
+// IsFinalState to check states declared with AddFinalState.
 func (sm *StateMachine[S, E]) IsTerminalState(state S) bool {
-	transitions, exists := sm.transitions[state]
-	return !exists || len(transitions) == 0
+	sm.rlock()
+	defer sm.runlock()
+
+	return len(sm.effectiveTransitions(state)) == 0
 }
 
-// ValidateTransitionPath checks if a sequence of events is valid from a starting state
+// ValidateTransitionPath checks if a sequence of events is valid from a starting state.
+// No callbacks are run. Automatic transitions are followed from each state
+// an event leads to, as if their guards pass.
 func (sm *StateMachine[S, E]) ValidateTransitionPath(start S, events []E) (S, error) {
+	sm.rlock()
+	defer sm.runlock()
+
 	currentState := start
 	for i, event := range events {
-		newState, err := sm.Transition(currentState, event)
+		newState, err := sm.lookup(currentState, event)
+		if err == nil {
+			newState, err = sm.lookupAutomatic(newState)
+		}
 		if err != nil {
 			return currentState, fmt.Errorf("invalid path at step %d: %w", i+1, err)
 		}
@@ -110,36 +602,194 @@ func (sm *StateMachine[S, E]) ValidateTransitionPath(start S, events []E) (S, er
 	return currentState, nil
 }
 
-// GetAllStates returns all states that have been registered in the state machine
+// GetAllStates returns all states that have been registered in the state
+// machine, sorted by name
 func (sm *StateMachine[S, E]) GetAllStates() []S {
+	sm.rlock()
+	defer sm.runlock()
+
+	states := sm.allStates()
+	sortStates(states)
+	return states
+}
+
+// StateByName returns the machine's state whose name, as Name gives it, is
+// name. It is how stores read back the states they persist by name.
+func (sm *StateMachine[S, E]) StateByName(name string) (S, bool) {
+	sm.rlock()
+	defer sm.runlock()
+
+	for _, s := range sm.allStates() {
+		if Name(s) == name {
+			return s, true
+		}
+	}
+	var zero S
+	return zero, false
+}
+
+func (sm *StateMachine[S, E]) allStates() []S {
 	states := make([]S, 0, len(sm.transitions))
 	seen := make(map[S]bool)
+	add := func(s S) {
+		if !seen[s] {
+			states = append(states, s)
+			seen[s] = true
+		}
+	}
 
 	for from := range sm.transitions {
-		if !seen[from] {
-			states = append(states, from)
-			seen[from] = true
-		}
-		for _, to := range sm.transitions[from] {
-			if !seen[to] {
-				states = append(states, to)
-				seen[to] = true
+		add(from)
+		for _, edges := range sm.transitions[from] {
+			for _, e := range edges {
+				for _, to := range e.targets() {
+					add(to)
+				}
 			}
 		}
 	}
+	for child, parent := range sm.parents {
+		add(child)
+		add(parent)
+	}
+	if sm.hasInitialState {
+		add(sm.initialState)
+	}
+	for s := range sm.final {
+		add(s)
+	}
+	for s := range sm.declared {
+		add(s)
+	}
 
 	return states
 }
 
-// GetTransitions returns all transitions from a given state
+// GetAllEvents returns all events that trigger a transition anywhere in the
+// state machine, along with those declared with DeclareEvents, sorted by name
+func (sm *StateMachine[S, E]) GetAllEvents() []E {
+	sm.rlock()
+	defer sm.runlock()
+
+	return sm.allEvents()
+}
+
+func (sm *StateMachine[S, E]) allEvents() []E {
+	events := make([]E, 0, len(sm.events))
+	seen := make(map[E]bool)
+	add := func(e E) {
+		if !seen[e] {
+			events = append(events, e)
+			seen[e] = true
+		}
+	}
+
+	for _, byEvent := range sm.transitions {
+		for event := range byEvent {
+			add(event)
+		}
+	}
+	for e := range sm.events {
+		add(e)
+	}
+
+	sortEvents(events)
+	return events
+}
+
+// GetTransitions returns all transitions from a given state, including those
+// inherited from its parents
 func (sm *StateMachine[S, E]) GetTransitions(from S) map[E]S {
-	if transitions, exists := sm.transitions[from]; exists {
-		// Return a copy to prevent external modification
-		result := make(map[E]S, len(transitions))
-		for k, v := range transitions {
-			result[k] = v
+	sm.rlock()
+	defer sm.runlock()
+
+	// Return a copy to prevent external modification
+	transitions := sm.effectiveTransitions(from)
+	result := make(map[E]S, len(transitions))
+	for k, v := range transitions {
+		result[k] = v.to
+	}
+	return result
+}
+
+// GetAllTransitions returns every declared transition as the Transition
+// rules accepted by AddTransitions, sorted by source state and event name, so
+// a machine can be serialized or re-created:
+//
+//	recreated := statemachine.NewStateMachine[OrderState, OrderEvent]()
+//	recreated.AddTransitions(sm.GetAllTransitions())
+//
+// Where transitions compete for a state and event the first tried is
+// returned, as with GetTransitions. Guards, actions, choices and the state
+// hierarchy are not part of a Transition; use Clone to copy them.
+func (sm *StateMachine[S, E]) GetAllTransitions() []Transition[S, E] {
+	sm.rlock()
+	defer sm.runlock()
+
+	targets := sm.declaredTargets()
+	result := make([]Transition[S, E], 0, len(targets))
+	for k, to := range targets {
+		result = append(result, Transition[S, E]{From: k.from, Event: k.event, To: to})
+	}
+	sortTransitions(result)
+	return result
+}
+
+// GetTransitionsTo returns every declared transition leading into state,
+// sorted by source state and event name. Guarded transitions and choices
+// that may lead to state are included; transitions into an ancestor of state
+// are not.
+func (sm *StateMachine[S, E]) GetTransitionsTo(state S) []Transition[S, E] {
+	sm.rlock()
+	defer sm.runlock()
+
+	var result []Transition[S, E]
+	for from, byEvent := range sm.transitions {
+		for event, edges := range byEvent {
+			if slices.ContainsFunc(edges, func(e *edge[S, E]) bool {
+				return slices.Contains(e.targets(), state)
+			}) {
+				result = append(result, Transition[S, E]{From: from, Event: event, To: state})
+			}
+		}
+	}
+	sortTransitions(result)
+	return result
+}
+
+// GetTargets returns every state event can lead to from a state, sorted,
+// whatever the guards and choice resolvers decide: the targets of each
+// transition that might be taken, entered as Simulate enters them and
+// followed through any automatic transitions that might fire from there.
+// A state with automatic transitions is included, since their guards may
+// all refuse. There are none if the machine does not accept the event.
+func (sm *StateMachine[S, E]) GetTargets(from S, event E) []S {
+	sm.rlock()
+	defer sm.runlock()
+
+	var result []S
+	seen := make(map[S]bool)
+	var visit func(state S, depth int)
+	visit = func(state S, depth int) {
+		if seen[state] {
+			return
+		}
+		seen[state] = true
+		result = append(result, state)
+		if depth == sm.maxAutomatic {
+			return
+		}
+		for _, c := range sm.automaticCandidates(state) {
+			for _, to := range c.e.targets() {
+				visit(sm.resolveTarget(to, NoHistory, nil), depth+1)
+			}
+		}
+	}
+	for _, e := range sm.edgesFor(from, event) {
+		for _, to := range e.targets() {
+			visit(sm.resolveTarget(to, NoHistory, nil), 0)
 		}
-		return result
 	}
-	return make(map[E]S)
+	sortStates(result)
+	return result
 }
@@ -1,6 +1,11 @@
 package statemachine
 
 import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -138,6 +143,106 @@ func TestGenericUserStateMachine_InvalidTransitions(t *testing.T) {
 	}
 }
 
+func TestStateMachine_MustTransition(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	if got := sm.MustTransition(UserStateInitial, UserEventSubmitSignUp); got != UserStateEmailPendingVerification {
+		t.Errorf("MustTransition() = %v, want %v", got, UserStateEmailPendingVerification)
+	}
+
+	defer func() {
+		r := recover()
+		if msg, _ := r.(string); !strings.HasPrefix(msg, "statemachine: invalid transition") {
+			t.Errorf("MustTransition() panicked with %v, want an invalid transition", r)
+		}
+	}()
+	sm.MustTransition(UserStateInitial, UserEventCompleteProfile)
+}
+
+func TestStateMachine_TryTransition(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.AddTransition(OrderStateShipped, OrderEventRefund, OrderStateRefunded,
+		WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return false }))
+
+	tests := []struct {
+		name   string
+		from   OrderState
+		event  OrderEvent
+		want   OrderState
+		wantOK bool
+	}{
+		{"valid", OrderStatePending, OrderEventConfirm, OrderStatePacking, true},
+		{"inherited", OrderStateAwaiting, OrderEventCancel, OrderStateCancelled, true},
+		{"invalid", OrderStatePending, OrderEventShip, "", false},
+		{"unknown state", "Lost", OrderEventShip, "", false},
+		{"guard rejected", OrderStateShipped, OrderEventRefund, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sm.TryTransition(tt.from, tt.event)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("TryTransition() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { sm.TryTransition(OrderStatePending, OrderEventShip) }); allocs != 0 {
+		t.Errorf("TryTransition() of an invalid event allocated %v times", allocs)
+	}
+}
+
+func TestStateMachine_TryTransition_Observed(t *testing.T) {
+	tests := []struct {
+		name  string
+		from  OrderState
+		event OrderEvent
+	}{
+		{"valid", OrderStatePending, OrderEventConfirm},
+		{"invalid", OrderStatePending, OrderEventShip},
+		{"unknown state", "Lost", OrderEventShip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// run makes a transition with fn on a machine with a counting
+			// middleware and a logger, returning what each saw
+			run := func(fn func(sm *StateMachine[OrderState, OrderEvent])) (int, string) {
+				var buf bytes.Buffer
+				logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+					Level: slog.LevelDebug,
+					ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+						if a.Key == slog.TimeKey {
+							return slog.Attr{}
+						}
+						return a
+					},
+				}))
+				sm := NewStateMachine[OrderState, OrderEvent](WithLogger(logger))
+				sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+				calls := 0
+				sm.Use(func(next TransitionFunc[OrderState, OrderEvent]) TransitionFunc[OrderState, OrderEvent] {
+					return func(ctx context.Context, from OrderState, event OrderEvent) (OrderState, error) {
+						calls++
+						return next(ctx, from, event)
+					}
+				})
+				fn(sm)
+				return calls, buf.String()
+			}
+
+			tryCalls, tryLogged := run(func(sm *StateMachine[OrderState, OrderEvent]) { sm.TryTransition(tt.from, tt.event) })
+			calls, logged := run(func(sm *StateMachine[OrderState, OrderEvent]) { sm.Transition(tt.from, tt.event) })
+			if tryCalls != 1 || calls != 1 {
+				t.Errorf("middleware called %d times by TryTransition() and %d by Transition(), want 1", tryCalls, calls)
+			}
+			if tryLogged == "" || tryLogged != logged {
+				t.Errorf("TryTransition() logged:\n%s\nTransition() logged:\n%s", tryLogged, logged)
+			}
+		})
+	}
+}
+
 // ==================== GENERIC FUNCTIONALITY TESTS ====================
 
 func TestGenericStateMachine_GetAllStates(t *testing.T) {
@@ -172,6 +277,86 @@ func TestGenericStateMachine_GetAllStates(t *testing.T) {
 	}
 }
 
+func TestGenericStateMachine_GetAllEvents(t *testing.T) {
+	sm := NewUserStateMachine()
+	sm.DeclareEvents(UserEvent("Suspend"))
+
+	events := sm.GetAllEvents()
+
+	// Events used by several transitions should only appear once
+	expectedEvents := []UserEvent{
+		UserEventSubmitSignUp,
+		UserEventClickVerificationLink,
+		UserEventSignupFailed,
+		UserEventCompleteProfile,
+		UserEvent("Suspend"),
+	}
+	if len(events) != len(expectedEvents) {
+		t.Errorf("GetAllEvents() returned %d events, want %d", len(events), len(expectedEvents))
+	}
+
+	eventMap := make(map[UserEvent]bool)
+	for _, e := range events {
+		eventMap[e] = true
+	}
+	for _, expected := range expectedEvents {
+		if !eventMap[expected] {
+			t.Errorf("GetAllEvents() missing expected event %v", expected)
+		}
+	}
+}
+
+func TestGenericStateMachine_SortedResults(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	// Results are sorted by name so repeated calls always agree
+	for i := 0; i < 10; i++ {
+		if got, want := sm.GetAllStates(), []UserState{
+			UserStateEmailPendingVerification,
+			UserStateEmailVerified,
+			UserStateInitial,
+			UserStateSignUpComplete,
+			UserStateRejected,
+		}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("GetAllStates() = %v, want %v", got, want)
+		}
+		if got, want := sm.GetAllEvents(), []UserEvent{
+			UserEventClickVerificationLink,
+			UserEventCompleteProfile,
+			UserEventSignupFailed,
+			UserEventSubmitSignUp,
+		}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("GetAllEvents() = %v, want %v", got, want)
+		}
+		if got, want := sm.GetValidEvents(UserStateInitial), []UserEvent{
+			UserEventSignupFailed,
+			UserEventSubmitSignUp,
+		}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("GetValidEvents(Initial) = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestGenericStateMachine_StateByName(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	tests := []struct {
+		name   string
+		want   UserState
+		wantOK bool
+	}{
+		{"EmailVerified", UserStateEmailVerified, true},
+		{"Initial", UserStateInitial, true},
+		{"Deleted", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := sm.StateByName(tt.name); got != tt.want || ok != tt.wantOK {
+			t.Errorf("StateByName(%q) = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
 func TestGenericStateMachine_GetTransitions(t *testing.T) {
 	sm := NewUserStateMachine()
 
@@ -191,3 +376,193 @@ func TestGenericStateMachine_GetTransitions(t *testing.T) {
 		t.Errorf("Expected SignupFailed to lead to Rejected")
 	}
 }
+
+func TestGenericStateMachine_GetAllTransitions(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	transitions := sm.GetAllTransitions()
+	if len(transitions) != 6 {
+		t.Errorf("GetAllTransitions() returned %d transitions, want 6", len(transitions))
+	}
+	for i := 1; i < len(transitions); i++ {
+		prev, cur := transitions[i-1], transitions[i]
+		if !transitionLess(prev.From, prev.Event, cur.From, cur.Event) {
+			t.Errorf("GetAllTransitions() not sorted: %v before %v", prev, cur)
+		}
+	}
+
+	// Re-creating the machine from its transitions should change nothing
+	recreated := NewStateMachine[UserState, UserEvent]()
+	recreated.AddTransitions(transitions)
+	if d := Diff(sm, recreated); !d.IsEmpty() {
+		t.Errorf("machine re-created from GetAllTransitions() differs:\n%s", d)
+	}
+}
+
+func TestGenericStateMachine_GetTransitionsTo(t *testing.T) {
+	sm := NewUserStateMachine()
+
+	tests := []struct {
+		state UserState
+		want  []Transition[UserState, UserEvent]
+	}{
+		{UserStateRejected, []Transition[UserState, UserEvent]{
+			{UserStateEmailPendingVerification, UserEventSignupFailed, UserStateRejected},
+			{UserStateEmailVerified, UserEventSignupFailed, UserStateRejected},
+			{UserStateInitial, UserEventSignupFailed, UserStateRejected},
+		}},
+		{UserStateEmailVerified, []Transition[UserState, UserEvent]{
+			{UserStateEmailPendingVerification, UserEventClickVerificationLink, UserStateEmailVerified},
+		}},
+		{UserStateInitial, nil},
+	}
+	for _, tt := range tests {
+		if got := sm.GetTransitionsTo(tt.state); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetTransitionsTo(%v) = %v, want %v", tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestStateMachine_GetTargets(t *testing.T) {
+	sm := NewStateMachine[fulfilState, fulfilEvent]()
+	refuse := func(ctx context.Context, from fulfilState, event fulfilEvent) bool { return false }
+	sm.AddTransition("Reviewing", "Approve", "Approved", WithGuard(refuse))
+	sm.AddTransition("Reviewing", "Approve", "Escalated")
+	sm.AddTransition("Approved", "Publish", "Published", WithAutomatic[fulfilState, fulfilEvent](), WithGuard(refuse))
+	sm.AddChoice("Reviewing", "Score", func(ctx context.Context, from fulfilState, event fulfilEvent) (fulfilState, error) {
+		return "Rejected", nil
+	}, []fulfilState{"Rejected", "Shipping"})
+	for _, child := range []fulfilState{"Packing", "Shipped"} {
+		if err := sm.SetParent(child, "Shipping"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sm.SetInitialSubstate("Shipping", "Packing"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from  fulfilState
+		event fulfilEvent
+		want  []fulfilState
+	}{
+		{"Reviewing", "Approve", []fulfilState{"Approved", "Escalated", "Published"}},
+		{"Reviewing", "Score", []fulfilState{"Packing", "Rejected"}},
+		{"Approved", "Approve", nil},
+	}
+	for _, tt := range tests {
+		if got := sm.GetTargets(tt.from, tt.event); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetTargets(%v, %v) = %v, want %v", tt.from, tt.event, got, tt.want)
+		}
+	}
+}
+
+// light is a state without a String method
+type light int
+
+const (
+	red light = iota
+	green
+	amber
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"Stringer", Name(UserStateInitial), "Initial"},
+		{"int", Name(amber), "2"},
+		{"string", Name("go"), "go"},
+		{"struct", Name(struct{ A, B int }{1, 2}), "{1 2}"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("Name() of a %s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestStateMachine_PlainTypes(t *testing.T) {
+	sm := NewStateMachine[light, string]()
+	sm.AddTransition(red, "go", green)
+	sm.AddTransition(green, "slow", amber)
+	sm.AddTransition(amber, "stop", red)
+
+	if got, err := sm.Transition(red, "go"); err != nil || got != green {
+		t.Errorf("Transition() = %v, %v, want %v", got, err, green)
+	}
+	_, err := sm.Transition(amber, "go")
+	if want := "invalid transition: cannot process event 'go' from state '2'; valid events: stop"; err == nil || err.Error() != want {
+		t.Errorf("Transition() error = %v, want %s", err, want)
+	}
+
+	var mermaid strings.Builder
+	if err := sm.ExportMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(mermaid.String(), "0 --> 1: go") {
+		t.Errorf("ExportMermaid() =\n%s\nwant the transition named by its values", mermaid.String())
+	}
+
+	loaded, err := FromDefinition(sm.ToDefinition(), []light{red, green, amber}, []string{"go", "slow", "stop"})
+	if err != nil {
+		t.Fatalf("FromDefinition() error = %v", err)
+	}
+	if d := Diff(sm, loaded); !d.IsEmpty() {
+		t.Errorf("machine loaded from ToDefinition() differs:\n%s", d)
+	}
+}
+
+func BenchmarkStateMachine(b *testing.B) {
+	ctx := context.Background()
+	benchmarks := []struct {
+		name string
+		fn   func(sm *StateMachine[OrderState, OrderEvent])
+	}{
+		{"Transition", func(sm *StateMachine[OrderState, OrderEvent]) {
+			sm.Transition(OrderStatePending, OrderEventConfirm)
+		}},
+		{"TransitionNested", func(sm *StateMachine[OrderState, OrderEvent]) {
+			sm.Transition(OrderStatePacking, OrderEventCancel)
+		}},
+		{"TransitionRejected", func(sm *StateMachine[OrderState, OrderEvent]) {
+			sm.Transition(OrderStateShipped, OrderEventConfirm)
+		}},
+		{"TransitionGuarded", func(sm *StateMachine[OrderState, OrderEvent]) {
+			sm.Transition(OrderStateCancelled, OrderEventConfirm)
+		}},
+		{"TransitionContext", func(sm *StateMachine[OrderState, OrderEvent]) {
+			sm.TransitionContext(ctx, OrderStateShipped, OrderEventDeliver)
+		}},
+		{"CanTransition", func(sm *StateMachine[OrderState, OrderEvent]) {
+			sm.CanTransition(OrderStateAwaiting, OrderEventShip)
+		}},
+		{"GetNextState", func(sm *StateMachine[OrderState, OrderEvent]) {
+			sm.GetNextState(OrderStateAwaiting, OrderEventShip)
+		}},
+	}
+	for _, bm := range benchmarks {
+		for _, frozen := range []bool{false, true} {
+			name := bm.name
+			if frozen {
+				name += "/frozen"
+			}
+			b.Run(name, func(b *testing.B) {
+				sm := NewOrderStateMachine()
+				sm.OnEnter(OrderStateShipped, func(ctx context.Context, from, to OrderState, event OrderEvent) {})
+				sm.AddTransition(OrderStateCancelled, OrderEventConfirm, OrderStatePending,
+					WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true }),
+					WithAction(func(ctx context.Context, from, to OrderState, event OrderEvent) error { return nil }))
+				if frozen {
+					sm.Freeze()
+				}
+				b.ReportAllocs()
+				for b.Loop() {
+					bm.fn(sm)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package statemachinetest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// Clock is a statemachine.Clock whose time moves only when Advance is
+// called, for testing timeouts without waiting for them:
+//
+//	clock := statemachinetest.NewClock(time.Now())
+//	sm := statemachine.NewStateMachine[UserState, UserEvent](statemachine.WithClock(clock))
+//	...
+//	clock.Advance(48 * time.Hour)
+//
+// It is safe for concurrent use.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*clockTimer
+}
+
+// NewClock returns a clock reading now
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the clock's time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// AfterFunc schedules f for when the clock has been advanced by d. A call
+// that is already due runs on the next Advance, which may be Advance(0).
+func (c *Clock) AfterFunc(d time.Duration, f func()) statemachine.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &clockTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, running the calls that fall due on
+// the way in the order they are due, each with the clock reading its time.
+// The calls run on the calling goroutine, so their effects are visible when
+// Advance returns.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	until := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		i := slices.IndexFunc(c.timers, func(t *clockTimer) bool { return !t.at.After(until) })
+		if i < 0 {
+			c.now = until
+			c.mu.Unlock()
+			return
+		}
+		for j, t := range c.timers {
+			if t.at.Before(c.timers[i].at) {
+				i = j
+			}
+		}
+		t := c.timers[i]
+		c.timers = slices.Delete(c.timers, i, i+1)
+		if t.at.After(c.now) {
+			c.now = t.at
+		}
+		c.mu.Unlock()
+
+		t.f()
+	}
+}
+
+// Pending returns how many calls are scheduled and not yet run or stopped
+func (c *Clock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+type clockTimer struct {
+	clock *Clock
+	at    time.Time
+	f     func()
+}
+
+func (t *clockTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := slices.Index(c.timers, t)
+	if i < 0 {
+		return false
+	}
+	c.timers = slices.Delete(c.timers, i, i+1)
+	return true
+}
@@ -0,0 +1,61 @@
+package statemachinetest_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/statemachinetest"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := statemachinetest.NewClock(start)
+
+	var ran []time.Duration
+	schedule := func(d time.Duration) statemachine.Timer {
+		return clock.AfterFunc(d, func() { ran = append(ran, clock.Now().Sub(start)) })
+	}
+	schedule(3 * time.Hour)
+	schedule(time.Hour)
+	stopped := schedule(2 * time.Hour)
+	schedule(5 * time.Hour)
+
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Stop() should report true only the first time")
+	}
+	clock.Advance(4 * time.Hour)
+
+	if want := []time.Duration{time.Hour, 3 * time.Hour}; !slices.Equal(ran, want) {
+		t.Errorf("ran at %v, want %v", ran, want)
+	}
+	if got := clock.Now(); !got.Equal(start.Add(4 * time.Hour)) {
+		t.Errorf("Now() = %v, want %v", got, start.Add(4*time.Hour))
+	}
+	if clock.Pending() != 1 {
+		t.Errorf("Pending() = %d, want 1", clock.Pending())
+	}
+}
+
+func TestClock_Timeouts(t *testing.T) {
+	clock := statemachinetest.NewClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	sm := statemachine.NewStateMachine[state, event](statemachine.WithClock(clock))
+	sm.AddTransition("Pending", "Confirm", "Processing")
+	sm.AddTransition("Pending", "Cancel", "Cancelled")
+	sm.SetTimeout("Pending", time.Hour, "Cancel")
+
+	timers := statemachine.NewTimers(sm)
+	defer timers.Stop()
+	inst := sm.NewInstanceAt("Pending")
+	timers.Watch(inst)
+
+	clock.Advance(time.Hour)
+	if !inst.Is("Cancelled") {
+		t.Errorf("Current() = %v, want Cancelled", inst.Current())
+	}
+	if fired, err := inst.FireDue(context.Background()); fired || err != nil {
+		t.Errorf("FireDue() = %v, %v after the timeout fired", fired, err)
+	}
+}
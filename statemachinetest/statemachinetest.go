@@ -0,0 +1,148 @@
+// Package statemachinetest provides helpers for property-based testing of
+// state machines: a seeded random walk generator, and invariants to check
+// every walk against. Its Clock lets tests move time forward by hand to
+// fire timeouts.
+//
+// A walk is reproducible from its seed, which makes it a good fit for Go
+// fuzzing:
+//
+//	func FuzzOrderMachine(f *testing.F) {
+//		f.Add(uint64(1))
+//		f.Fuzz(func(t *testing.T, seed uint64) {
+//			walk := statemachinetest.RandomWalk(NewOrderStateMachine(), OrderStatePending, 20, seed)
+//			statemachinetest.Check(t, walk,
+//				statemachinetest.NeverAfter(OrderEventCancel, OrderStateShipped))
+//		})
+//	}
+package statemachinetest
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/richardbowden/statemachine"
+)
+
+// Step is a single transition taken during a walk
+type Step[S statemachine.State, E statemachine.Event] struct {
+	From  S
+	Event E
+	To    S
+}
+
+// Walk is the sequence of transitions taken from a start state
+type Walk[S statemachine.State, E statemachine.Event] struct {
+	Start S
+	Steps []Step[S, E]
+}
+
+// String formats the walk as Start --Event--> State --Event--> State
+func (w Walk[S, E]) String() string {
+	var b strings.Builder
+	b.WriteString(statemachine.Name(w.Start))
+	for _, s := range w.Steps {
+		fmt.Fprintf(&b, " --%s--> %s", statemachine.Name(s.Event), statemachine.Name(s.To))
+	}
+	return b.String()
+}
+
+// RandomWalk performs up to n transitions on sm from start, each time firing
+// an event picked at random among those valid from the current state. Guards,
+// actions and callbacks run as they would for Transition; an event whose
+// guards reject it is skipped in favour of another. The walk stops early at
+// a state from which no event succeeds.
+//
+// The same machine, start, n and seed always produce the same walk, provided
+// the machine's guards and resolvers are themselves deterministic.
+func RandomWalk[S statemachine.State, E statemachine.Event](sm *statemachine.StateMachine[S, E], start S, n int, seed uint64) Walk[S, E] {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	walk := Walk[S, E]{Start: start}
+	current := start
+	for len(walk.Steps) < n {
+		events := sm.GetValidEvents(current)
+		rng.Shuffle(len(events), func(i, j int) { events[i], events[j] = events[j], events[i] })
+
+		moved := false
+		for _, event := range events {
+			to, err := sm.TransitionContext(context.Background(), current, event)
+			if err != nil {
+				continue
+			}
+			walk.Steps = append(walk.Steps, Step[S, E]{From: current, Event: event, To: to})
+			current = to
+			moved = true
+			break
+		}
+		if !moved {
+			break
+		}
+	}
+	return walk
+}
+
+// Invariant checks a property that must hold for every walk, returning an
+// error describing the first violation
+type Invariant[S statemachine.State, E statemachine.Event] func(walk Walk[S, E]) error
+
+// Check reports, as test errors, every invariant the walk violates. Each
+// error includes the walk so that it can be reproduced.
+func Check[S statemachine.State, E statemachine.Event](t testing.TB, walk Walk[S, E], invariants ...Invariant[S, E]) {
+	t.Helper()
+	for _, inv := range invariants {
+		if err := inv(walk); err != nil {
+			t.Errorf("%v\nwalk: %s", err, walk)
+		}
+	}
+}
+
+// Never is violated by a walk that reaches state
+func Never[S statemachine.State, E statemachine.Event](state S) Invariant[S, E] {
+	return func(walk Walk[S, E]) error {
+		if walk.Start == state {
+			return fmt.Errorf("walk starts in '%s'", statemachine.Name(state))
+		}
+		for i, s := range walk.Steps {
+			if s.To == state {
+				return fmt.Errorf("step %d reached '%s'", i+1, statemachine.Name(state))
+			}
+		}
+		return nil
+	}
+}
+
+// NeverAfter is violated by a walk that reaches state at any point after
+// event has been fired, for example an order shipped after being cancelled
+func NeverAfter[S statemachine.State, E statemachine.Event](event E, state S) Invariant[S, E] {
+	return func(walk Walk[S, E]) error {
+		fired := 0
+		for i, s := range walk.Steps {
+			if fired == 0 && s.Event == event {
+				fired = i + 1
+			}
+			if fired > 0 && s.To == state {
+				return fmt.Errorf("step %d reached '%s' after '%s' at step %d", i+1, statemachine.Name(state), statemachine.Name(event), fired)
+			}
+		}
+		return nil
+	}
+}
+
+// Always is violated by the first step whose transition does not satisfy
+// fn. fn is also given the start of the walk as a step with no event, to
+// check the state the walk starts in.
+func Always[S statemachine.State, E statemachine.Event](desc string, fn func(Step[S, E]) bool) Invariant[S, E] {
+	return func(walk Walk[S, E]) error {
+		if !fn(Step[S, E]{From: walk.Start, To: walk.Start}) {
+			return fmt.Errorf("start state '%s' breaks %s", statemachine.Name(walk.Start), desc)
+		}
+		for i, s := range walk.Steps {
+			if !fn(s) {
+				return fmt.Errorf("step %d, '%s' --%s--> '%s', breaks %s", i+1, statemachine.Name(s.From), statemachine.Name(s.Event), statemachine.Name(s.To), desc)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,119 @@
+package statemachinetest_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/richardbowden/statemachine"
+	"github.com/richardbowden/statemachine/statemachinetest"
+)
+
+type state string
+
+func (s state) String() string { return string(s) }
+
+type event string
+
+func (e event) String() string { return string(e) }
+
+func newOrderMachine() *statemachine.StateMachine[state, event] {
+	sm := statemachine.NewStateMachine[state, event]()
+	sm.AddTransition("Pending", "Confirm", "Processing")
+	sm.AddTransition("Pending", "Cancel", "Cancelled")
+	sm.AddTransition("Processing", "Ship", "Shipped")
+	sm.AddTransition("Processing", "Cancel", "Cancelled")
+	sm.AddTransition("Shipped", "Deliver", "Delivered")
+	sm.AddTransition("Delivered", "Return", "Processing")
+	return sm
+}
+
+func TestRandomWalk(t *testing.T) {
+	sm := newOrderMachine()
+
+	for seed := uint64(0); seed < 50; seed++ {
+		walk := statemachinetest.RandomWalk(sm, "Pending", 10, seed)
+		if again := statemachinetest.RandomWalk(sm, "Pending", 10, seed); !reflect.DeepEqual(walk, again) {
+			t.Fatalf("seed %d gave %s, then %s", seed, walk, again)
+		}
+
+		current := walk.Start
+		for i, step := range walk.Steps {
+			if step.From != current {
+				t.Fatalf("seed %d step %d starts at %v, want %v", seed, i+1, step.From, current)
+			}
+			if to, ok := sm.GetNextState(step.From, step.Event); !ok || to != step.To {
+				t.Fatalf("seed %d step %d %v --%v--> %v is not a transition", seed, i+1, step.From, step.Event, step.To)
+			}
+			current = step.To
+		}
+		if len(walk.Steps) < 10 && current != "Cancelled" {
+			t.Errorf("seed %d stopped early at %v", seed, current)
+		}
+	}
+}
+
+func TestInvariants(t *testing.T) {
+	walk := statemachinetest.Walk[state, event]{
+		Start: "Pending",
+		Steps: []statemachinetest.Step[state, event]{
+			{"Pending", "Confirm", "Processing"},
+			{"Processing", "Ship", "Shipped"},
+			{"Shipped", "Deliver", "Delivered"},
+			{"Delivered", "Return", "Processing"},
+			{"Processing", "Ship", "Shipped"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		inv     statemachinetest.Invariant[state, event]
+		wantErr string
+	}{
+		{"never holds", statemachinetest.Never[state, event]("Cancelled"), ""},
+		{"never broken", statemachinetest.Never[state, event]("Delivered"), "step 3 reached 'Delivered'"},
+		{"never after holds", statemachinetest.NeverAfter[state, event]("Return", "Delivered"), ""},
+		{"never after broken", statemachinetest.NeverAfter[state, event]("Return", "Shipped"), "step 5 reached 'Shipped' after 'Return' at step 4"},
+		{"always holds", statemachinetest.Always("no self loops", func(s statemachinetest.Step[state, event]) bool {
+			return s.Event == "" || s.From != s.To
+		}), ""},
+		{"always broken", statemachinetest.Always("forward only", func(s statemachinetest.Step[state, event]) bool {
+			return s.Event != "Return"
+		}), "step 4, 'Delivered' --Return--> 'Processing', breaks forward only"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.inv(walk)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("invariant error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("invariant error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWalk_String(t *testing.T) {
+	walk := statemachinetest.Walk[state, event]{
+		Start: "Pending",
+		Steps: []statemachinetest.Step[state, event]{{"Pending", "Confirm", "Processing"}},
+	}
+	if got, want := walk.String(), "Pending --Confirm--> Processing"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func FuzzOrderMachine(f *testing.F) {
+	sm := newOrderMachine()
+	f.Add(uint64(1))
+	f.Fuzz(func(t *testing.T, seed uint64) {
+		walk := statemachinetest.RandomWalk(sm, "Pending", 20, seed)
+		statemachinetest.Check(t, walk,
+			statemachinetest.NeverAfter[state, event]("Cancel", "Shipped"),
+			statemachinetest.Never[state, event]("Unknown"))
+	})
+}
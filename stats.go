@@ -0,0 +1,136 @@
+package statemachine
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// WithStats makes the machine count the transitions made on it and the
+// attempts that failed, for Stats. It gives a little operational insight
+// without a metrics stack; for dashboards and alerting, see the
+// smprometheus and smotel packages.
+func WithStats() Option {
+	return func(c *config) {
+		c.stats = true
+	}
+}
+
+// Stats counts the transitions made on a machine created WithStats since it
+// was created
+type Stats[S State, E Event] struct {
+	// Transitions counts the transitions made, by state, event and the
+	// state entered, sorted by state and event name
+	Transitions []TransitionCount[S, E]
+
+	// Rejected counts the attempts failing with ErrInvalidTransition,
+	// because the event was not valid from the state or its guards
+	// refused, and Failed those failing otherwise, such as when an action
+	// failed. Both are sorted by state and event name.
+	Rejected []AttemptCount[S, E]
+	Failed   []AttemptCount[S, E]
+}
+
+// TransitionCount is the number of times a transition was made
+type TransitionCount[S State, E Event] struct {
+	From  S
+	Event E
+	To    S
+	Count int64
+}
+
+// AttemptCount is the number of times an event failed from a state
+type AttemptCount[S State, E Event] struct {
+	From  S
+	Event E
+	Count int64
+}
+
+// Total returns the number of transitions made
+func (s Stats[S, E]) Total() int64 {
+	var n int64
+	for _, t := range s.Transitions {
+		n += t.Count
+	}
+	return n
+}
+
+// stats counts the transitions made on a machine created WithStats. It has
+// its own lock, so that transitions are counted even once frozen.
+type stats[S State, E Event] struct {
+	mu          sync.Mutex
+	transitions map[Transition[S, E]]int64
+	rejected    map[transitionKey[S, E]]int64
+	failed      map[transitionKey[S, E]]int64
+}
+
+func newStats[S State, E Event]() *stats[S, E] {
+	return &stats[S, E]{
+		transitions: make(map[Transition[S, E]]int64),
+		rejected:    make(map[transitionKey[S, E]]int64),
+		failed:      make(map[transitionKey[S, E]]int64),
+	}
+}
+
+// record counts the outcome of a transition. It does nothing on a nil
+// stats.
+func (s *stats[S, E]) record(from S, event E, to S, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case err == nil:
+		s.transitions[Transition[S, E]{From: from, Event: event, To: to}]++
+	case errors.Is(err, ErrInvalidTransition):
+		s.rejected[transitionKey[S, E]{from, event}]++
+	default:
+		s.failed[transitionKey[S, E]{from, event}]++
+	}
+}
+
+// Stats returns the transitions made on a machine created WithStats, and
+// the attempts that failed:
+//
+//	for _, t := range orderMachine.Stats().Transitions {
+//		log.Printf("%s --%s--> %s: %d", t.From, t.Event, t.To, t.Count)
+//	}
+//
+// A machine created without WithStats has nothing to report.
+func (sm *StateMachine[S, E]) Stats() Stats[S, E] {
+	var st Stats[S, E]
+	s := sm.stats
+	if s == nil {
+		return st
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for t, n := range s.transitions {
+		st.Transitions = append(st.Transitions, TransitionCount[S, E]{From: t.From, Event: t.Event, To: t.To, Count: n})
+	}
+	sort.Slice(st.Transitions, func(i, j int) bool {
+		a, b := st.Transitions[i], st.Transitions[j]
+		if a.From == b.From && a.Event == b.Event {
+			return Name(a.To) < Name(b.To)
+		}
+		return transitionLess(a.From, a.Event, b.From, b.Event)
+	})
+	st.Rejected = attemptCounts(s.rejected)
+	st.Failed = attemptCounts(s.failed)
+	return st
+}
+
+// attemptCounts lists counts by state and event, sorted by their names
+func attemptCounts[S State, E Event](counts map[transitionKey[S, E]]int64) []AttemptCount[S, E] {
+	var list []AttemptCount[S, E]
+	for k, n := range counts {
+		list = append(list, AttemptCount[S, E]{From: k.from, Event: k.event, Count: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return transitionLess(list[i].From, list[i].Event, list[j].From, list[j].Event)
+	})
+	return list
+}
@@ -0,0 +1,71 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStateMachine_Stats(t *testing.T) {
+	errCourier := errors.New("no courier")
+	sm := NewStateMachine[OrderState, OrderEvent](WithStats())
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+	sm.AddTransition(OrderStatePending, OrderEventCancel, OrderStateCancelled)
+	sm.AddTransition(OrderStatePacking, OrderEventPack, OrderStateAwaiting)
+	sm.AddTransition(OrderStateAwaiting, OrderEventShip, OrderStateShipped,
+		WithAction(func(ctx context.Context, from, to OrderState, event OrderEvent) error { return errCourier }))
+	sm.Freeze()
+
+	for _, a := range []struct {
+		from  OrderState
+		event OrderEvent
+	}{
+		{OrderStatePending, OrderEventConfirm},
+		{OrderStatePending, OrderEventConfirm},
+		{OrderStatePending, OrderEventCancel},
+		{OrderStatePacking, OrderEventPack},
+		{OrderStatePending, OrderEventShip},
+		{OrderStatePending, OrderEventShip},
+		{"Lost", OrderEventShip},
+		{OrderStateAwaiting, OrderEventShip},
+	} {
+		sm.Transition(a.from, a.event)
+	}
+	// rejected without an error being built, but counted all the same
+	sm.TryTransition(OrderStatePending, OrderEventShip)
+
+	want := Stats[OrderState, OrderEvent]{
+		Transitions: []TransitionCount[OrderState, OrderEvent]{
+			{OrderStatePacking, OrderEventPack, OrderStateAwaiting, 1},
+			{OrderStatePending, OrderEventCancel, OrderStateCancelled, 1},
+			{OrderStatePending, OrderEventConfirm, OrderStatePacking, 2},
+		},
+		Rejected: []AttemptCount[OrderState, OrderEvent]{
+			{"Lost", OrderEventShip, 1},
+			{OrderStatePending, OrderEventShip, 3},
+		},
+		Failed: []AttemptCount[OrderState, OrderEvent]{
+			{OrderStateAwaiting, OrderEventShip, 1},
+		},
+	}
+	got := sm.Stats()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if got.Total() != 4 {
+		t.Errorf("Total() = %d, want 4", got.Total())
+	}
+	if got := sm.Clone().Stats(); got.Total() != 0 || len(got.Rejected) != 0 {
+		t.Errorf("Clone().Stats() = %+v, want it empty", got)
+	}
+}
+
+func TestStateMachine_StatsDisabled(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.Transition(OrderStatePending, OrderEventConfirm)
+
+	if got := sm.Stats(); !reflect.DeepEqual(got, Stats[OrderState, OrderEvent]{}) {
+		t.Errorf("Stats() without WithStats = %+v, want nothing", got)
+	}
+}
@@ -0,0 +1,191 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned by a StateStore for an entity it does not
+	// hold
+	ErrNotFound = errors.New("entity not found")
+
+	// ErrConcurrentModification is returned by a StateStore when an entity
+	// has moved on from the version a transition started from, because
+	// another writer saved it in the meantime
+	ErrConcurrentModification = errors.New("entity was modified concurrently")
+)
+
+// StateStore keeps the current state of entities by ID, along with a
+// version that increases with every save so that concurrent writers cannot
+// overwrite each other's transitions
+type StateStore[S State] interface {
+	// Load returns an entity's state and version, or an error matching
+	// ErrNotFound
+	Load(ctx context.Context, id string) (state S, version int64, err error)
+
+	// Save records that an entity moved from one state to another, provided
+	// it is still at version, and returns an error matching
+	// ErrConcurrentModification otherwise. Saving at version 0 creates the
+	// entity, failing the same way if it already exists. Each save
+	// increments the version by one.
+	Save(ctx context.Context, id string, from, to S, version int64) error
+}
+
+// Manager drives entities kept in a StateStore through a machine, loading
+// each one's state, transitioning it and saving the result:
+//
+//	users := statemachine.NewManager(NewUserStateMachine(), store)
+//	err := users.Create(ctx, userID)
+//	state, err := users.Fire(ctx, userID, UserEventVerifyEmail)
+type Manager[S State, E Event] struct {
+	// Dedup, if set, makes events carrying an idempotency key, added with
+	// WithIdempotencyKey, apply once per entity. Fire and FireVersion answer
+	// a key already recorded for the entity with the state its event left
+	// the entity in, without loading or transitioning it again. Only events
+	// that succeed are recorded, so a failed one can be retried with the
+	// same key. Repeats processed at the same time as the original may
+	// both be applied; an Engine processes an entity's events one at a
+	// time, so it does not.
+	Dedup DedupStore[S]
+
+	sm    *StateMachine[S, E]
+	store StateStore[S]
+}
+
+// NewManager returns a manager keeping the entities of sm in store
+func NewManager[S State, E Event](sm *StateMachine[S, E], store StateStore[S]) *Manager[S, E] {
+	return &Manager[S, E]{sm: sm, store: store}
+}
+
+// Create stores a new entity in the machine's initial state, entering its
+// initial substates if it is composite. It panics if the machine has no
+// initial state, and fails with ErrConcurrentModification if the entity
+// already exists.
+func (m *Manager[S, E]) Create(ctx context.Context, id string) error {
+	var zero S
+	return m.store.Save(ctx, id, zero, m.sm.NewInstance().Current(), 0)
+}
+
+// State returns an entity's current state
+func (m *Manager[S, E]) State(ctx context.Context, id string) (S, error) {
+	state, _, err := m.store.Load(ctx, id)
+	return state, err
+}
+
+// Fire transitions an entity via event and saves its new state. The
+// entity's ID is available to guards, actions and listeners through
+// EntityIDFrom. If another writer saved the entity after it was loaded, the
+// save fails with ErrConcurrentModification; the transition's actions have
+// run by then, so they should be safe to repeat when the caller retries.
+// Listeners subscribed to the machine are only notified once the new state
+// is saved, so they never hear of a transition the store refused; the
+// transition's callbacks, like its actions, have run by then.
+func (m *Manager[S, E]) Fire(ctx context.Context, id string, event E) (S, error) {
+	if state, ok, err := m.replay(ctx, id); ok || err != nil {
+		return state, err
+	}
+	from, version, err := m.store.Load(ctx, id)
+	if err != nil {
+		return from, err
+	}
+	return m.fire(ctx, id, from, version, event)
+}
+
+// FireVersion is like Fire, but only transitions the entity if it is still
+// at version, such as one a client read earlier and sent back with its
+// request. Otherwise it fails with ErrConcurrentModification before any
+// guards or actions run.
+func (m *Manager[S, E]) FireVersion(ctx context.Context, id string, version int64, event E) (S, error) {
+	if state, ok, err := m.replay(ctx, id); ok || err != nil {
+		return state, err
+	}
+	from, current, err := m.store.Load(ctx, id)
+	if err != nil {
+		return from, err
+	}
+	if current != version {
+		return from, fmt.Errorf("entity %s is at version %d, not %d: %w", id, current, version, ErrConcurrentModification)
+	}
+	return m.fire(ctx, id, from, version, event)
+}
+
+// FireChange is like Fire, but returns the change it saved, for callers
+// that pass transitions on, such as to a message broker once they are
+// stored. The change's Version is the one the entity was loaded at, so it
+// is saved at the next. If the event's idempotency key was already
+// recorded by Dedup, nothing is saved and repeat is true; the change then
+// only has the entity's ID and, as To, the state recorded for the key.
+func (m *Manager[S, E]) FireChange(ctx context.Context, id string, event E) (change Change[S], repeat bool, err error) {
+	change.EntityID = id
+	if state, ok, err := m.replay(ctx, id); ok || err != nil {
+		change.To = state
+		return change, ok, err
+	}
+	from, version, err := m.store.Load(ctx, id)
+	if err != nil {
+		change.From, change.To = from, from
+		return change, false, err
+	}
+	change.From, change.Version = from, version
+	change.To, err = m.fire(ctx, id, from, version, event)
+	return change, false, err
+}
+
+// fire transitions an entity loaded at version and saves its new state. If
+// the event's idempotency key cannot be recorded once it is saved, the new
+// state is returned along with the error.
+func (m *Manager[S, E]) fire(ctx context.Context, id string, from S, version int64, event E) (S, error) {
+	fireCtx, notices := holdNotices(WithEntityID(ctx, id), &m.sm.listeners)
+	to, err := m.sm.TransitionContext(fireCtx, from, event)
+	if err != nil {
+		return from, err
+	}
+	if err := m.store.Save(ctx, id, from, to, version); err != nil {
+		return from, err
+	}
+	notices.release()
+	return to, m.record(ctx, id, to)
+}
+
+// MemoryStore is a StateStore held in memory, for tests and as a reference
+// for other implementations. The zero value is ready to use.
+type MemoryStore[S State] struct {
+	mu       sync.Mutex
+	entities map[string]storedState[S]
+}
+
+type storedState[S State] struct {
+	state   S
+	version int64
+}
+
+// Load returns an entity's state and version
+func (s *MemoryStore[S]) Load(ctx context.Context, id string) (S, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entities[id]
+	if !ok {
+		var zero S
+		return zero, 0, fmt.Errorf("entity %s: %w", id, ErrNotFound)
+	}
+	return e.state, e.version, nil
+}
+
+// Save stores an entity's new state if it is still at version
+func (s *MemoryStore[S]) Save(ctx context.Context, id string, from, to S, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current := s.entities[id].version; current != version {
+		return fmt.Errorf("entity %s is at version %d, not %d: %w", id, current, version, ErrConcurrentModification)
+	}
+	if s.entities == nil {
+		s.entities = make(map[string]storedState[S])
+	}
+	s.entities[id] = storedState[S]{state: to, version: version + 1}
+	return nil
+}
@@ -0,0 +1,188 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newOrderManager() (*Manager[OrderState, OrderEvent], *MemoryStore[OrderState]) {
+	sm := NewOrderStateMachine()
+	sm.SetInitialState(OrderStatePending)
+	store := &MemoryStore[OrderState]{}
+	return NewManager(sm, store), store
+}
+
+func TestManager(t *testing.T) {
+	orders, store := newOrderManager()
+	ctx := context.Background()
+
+	if _, err := orders.State(ctx, "order-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("State() of unknown entity error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := orders.Fire(ctx, "order-1", OrderEventConfirm); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Fire() on unknown entity error = %v, want %v", err, ErrNotFound)
+	}
+	if err := orders.Create(ctx, "order-1"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := orders.Create(ctx, "order-1"); !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("Create() of existing entity error = %v, want %v", err, ErrConcurrentModification)
+	}
+
+	steps := []struct {
+		event   OrderEvent
+		want    OrderState
+		wantErr error
+	}{
+		{OrderEventConfirm, OrderStatePacking, nil},
+		{OrderEventDeliver, OrderStatePacking, ErrInvalidTransition},
+		{OrderEventPack, OrderStateAwaiting, nil},
+		{OrderEventCancel, OrderStateCancelled, nil},
+	}
+	for i, step := range steps {
+		got, err := orders.Fire(ctx, "order-1", step.event)
+		if !errors.Is(err, step.wantErr) || got != step.want {
+			t.Fatalf("step %d: Fire(%s) = %v, %v, want %v, %v", i+1, step.event, got, err, step.want, step.wantErr)
+		}
+	}
+
+	state, version, err := store.Load(ctx, "order-1")
+	if err != nil || state != OrderStateCancelled || version != 4 {
+		t.Errorf("Load() = %v, %d, %v, want %v at version 4", state, version, err, OrderStateCancelled)
+	}
+}
+
+func TestManager_EntityID(t *testing.T) {
+	orders, _ := newOrderManager()
+	var got string
+	orders.sm.OnEnter(OrderStatePacking, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+		got, _ = EntityIDFrom(ctx)
+	})
+
+	ctx := context.Background()
+	if err := orders.Create(ctx, "order-42"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.Fire(ctx, "order-42", OrderEventConfirm); err != nil {
+		t.Fatal(err)
+	}
+	if got != "order-42" {
+		t.Errorf("entity ID seen by callback = %q, want order-42", got)
+	}
+}
+
+// racingStore lets another writer save an entity between a Load and a Save
+type racingStore struct {
+	*MemoryStore[OrderState]
+	race func()
+}
+
+func (s racingStore) Load(ctx context.Context, id string) (OrderState, int64, error) {
+	state, version, err := s.MemoryStore.Load(ctx, id)
+	s.race()
+	return state, version, err
+}
+
+func TestManager_ConcurrentModification(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.SetInitialState(OrderStatePending)
+	mem := &MemoryStore[OrderState]{}
+	ctx := context.Background()
+
+	other := NewManager(sm, mem)
+	if err := other.Create(ctx, "order-1"); err != nil {
+		t.Fatal(err)
+	}
+	var notified []OrderState
+	sm.Subscribe(func(ctx context.Context, from, to OrderState, event OrderEvent, at time.Time) {
+		if stored, _, _ := mem.Load(ctx, "order-1"); stored != to {
+			t.Errorf("listener notified of %v with %v stored, want it notified once saved", to, stored)
+		}
+		notified = append(notified, to)
+	})
+	orders := NewManager(sm, racingStore{mem, func() {
+		if _, err := other.Fire(ctx, "order-1", OrderEventCancel); err != nil {
+			t.Fatal(err)
+		}
+	}})
+
+	got, err := orders.Fire(ctx, "order-1", OrderEventConfirm)
+	if !errors.Is(err, ErrConcurrentModification) || got != OrderStatePending {
+		t.Errorf("Fire() = %v, %v, want %v, %v", got, err, OrderStatePending, ErrConcurrentModification)
+	}
+	if len(notified) != 1 || notified[0] != OrderStateCancelled {
+		t.Errorf("listener notified of %v, want only the saved %v", notified, OrderStateCancelled)
+	}
+	if state, _ := other.State(ctx, "order-1"); state != OrderStateCancelled {
+		t.Errorf("State() = %v, want the other writer's %v kept", state, OrderStateCancelled)
+	}
+}
+
+func TestManager_FireVersion(t *testing.T) {
+	orders, _ := newOrderManager()
+	var confirmed int
+	orders.sm.OnEnter(OrderStatePacking, func(ctx context.Context, from, to OrderState, event OrderEvent) {
+		confirmed++
+	})
+	ctx := context.Background()
+	if err := orders.Create(ctx, "order-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// two clients read the order at version 1 and both try to act on it
+	if got, err := orders.FireVersion(ctx, "order-1", 1, OrderEventConfirm); err != nil || got != OrderStatePacking {
+		t.Fatalf("FireVersion() = %v, %v, want %v", got, err, OrderStatePacking)
+	}
+	got, err := orders.FireVersion(ctx, "order-1", 1, OrderEventCancel)
+	if !errors.Is(err, ErrConcurrentModification) || got != OrderStatePacking {
+		t.Errorf("stale FireVersion() = %v, %v, want %v, %v", got, err, OrderStatePacking, ErrConcurrentModification)
+	}
+	if confirmed != 1 {
+		t.Errorf("callback ran %d times, want once", confirmed)
+	}
+	if _, err := orders.FireVersion(ctx, "order-2", 1, OrderEventConfirm); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FireVersion() on unknown entity error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestManager_FireChange(t *testing.T) {
+	orders, _ := newOrderManager()
+	orders.Dedup = &MemoryDedupStore[OrderState]{}
+	ctx := context.Background()
+	if err := orders.Create(ctx, "order-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	keyed := WithIdempotencyKey(ctx, "delivery-1")
+	want := Change[OrderState]{EntityID: "order-1", From: OrderStatePending, To: OrderStatePacking, Version: 1}
+	if got, repeat, err := orders.FireChange(keyed, "order-1", OrderEventConfirm); err != nil || repeat || got != want {
+		t.Fatalf("FireChange() = %+v, %v, %v, want %+v", got, repeat, err, want)
+	}
+	want = Change[OrderState]{EntityID: "order-1", To: OrderStatePacking}
+	if got, repeat, err := orders.FireChange(keyed, "order-1", OrderEventConfirm); err != nil || !repeat || got != want {
+		t.Errorf("repeated FireChange() = %+v, %v, %v, want %+v, a repeat", got, repeat, err, want)
+	}
+	want = Change[OrderState]{EntityID: "order-1", From: OrderStatePacking, To: OrderStatePacking, Version: 2}
+	if got, _, err := orders.FireChange(ctx, "order-1", OrderEventConfirm); !errors.Is(err, ErrInvalidTransition) || got != want {
+		t.Errorf("invalid FireChange() = %+v, %v, want %+v, %v", got, err, want, ErrInvalidTransition)
+	}
+}
+
+func TestManager_CreateComposite(t *testing.T) {
+	sm := NewOrderStateMachine()
+	sm.SetInitialState(OrderStateProcessing)
+	if err := sm.SetInitialSubstate(OrderStateProcessing, OrderStatePacking); err != nil {
+		t.Fatal(err)
+	}
+	orders := NewManager(sm, &MemoryStore[OrderState]{})
+
+	ctx := context.Background()
+	if err := orders.Create(ctx, "order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := orders.State(ctx, "order-1"); got != OrderStatePacking {
+		t.Errorf("State() = %v, want initial substate %v", got, OrderStatePacking)
+	}
+}
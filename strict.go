@@ -0,0 +1,32 @@
+package statemachine
+
+import (
+	"fmt"
+	"slices"
+)
+
+// AddTransitionStrict is like AddTransition but returns an error matching
+// ErrConflictingTransition, leaving the machine unchanged, instead of
+// replacing an unguarded transition for the same state and event that leads
+// somewhere else. Adding the same transition twice, or competing guarded
+// transitions, is not a conflict. On a machine created WithAcyclic it also
+// returns an error matching ErrCycle for a transition that would create a
+// cycle.
+func (sm *StateMachine[S, E]) AddTransitionStrict(from S, event E, to S, opts ...TransitionOption[S, E]) error {
+	return sm.addTransition(from, event, to, opts, true)
+}
+
+// checkConflict returns an error if adding e for event from a state would
+// replace an unguarded transition with a different target. Callers must hold
+// the write lock.
+func (sm *StateMachine[S, E]) checkConflict(from S, event E, e *edge[S, E]) error {
+	if len(e.guards) > 0 {
+		return nil
+	}
+	existing := sm.unguardedEdge(from, event)
+	if existing == nil || slices.Equal(existing.targets(), e.targets()) {
+		return nil
+	}
+	return fmt.Errorf("%w: event '%s' from state '%s' already leads to %s, cannot also lead to %s",
+		ErrConflictingTransition, Name(event), Name(from), describeTargets(existing.targets()), describeTargets(e.targets()))
+}
@@ -0,0 +1,63 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAddTransitionStrict(t *testing.T) {
+	alwaysTrue := WithGuard(func(ctx context.Context, from OrderState, event OrderEvent) bool { return true })
+
+	tests := []struct {
+		name    string
+		to      OrderState
+		opts    []TransitionOption[OrderState, OrderEvent]
+		wantErr bool
+		want    OrderState
+	}{
+		{"same target", OrderStatePacking, nil, false, OrderStatePacking},
+		{"different target", OrderStateShipped, nil, true, OrderStatePacking},
+		{"guarded", OrderStateCancelled, []TransitionOption[OrderState, OrderEvent]{alwaysTrue}, false, OrderStateCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewOrderStateMachine()
+			err := sm.AddTransitionStrict(OrderStatePending, OrderEventConfirm, tt.to, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddTransitionStrict() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrConflictingTransition) {
+				t.Errorf("AddTransitionStrict() error = %v, want ErrConflictingTransition", err)
+			}
+			if got, err := sm.Transition(OrderStatePending, OrderEventConfirm); err != nil || got != tt.want {
+				t.Errorf("Transition() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddTransitionStrict_Message(t *testing.T) {
+	sm := NewOrderStateMachine()
+	err := sm.AddTransitionStrict(OrderStatePending, OrderEventConfirm, OrderStateShipped)
+	want := "conflicting transition: event 'Confirm' from state 'Pending' already leads to 'Packing', cannot also lead to 'Shipped'"
+	if err == nil || err.Error() != want {
+		t.Errorf("AddTransitionStrict() error = %v, want %q", err, want)
+	}
+}
+
+func TestWithStrict(t *testing.T) {
+	sm := NewStateMachine[OrderState, OrderEvent](WithStrict())
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStatePacking)
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("AddTransition() with a conflicting target did not panic")
+		}
+		if got, _ := sm.GetNextState(OrderStatePending, OrderEventConfirm); got != OrderStatePacking {
+			t.Errorf("GetNextState() = %v after rejected transition, want %v", got, OrderStatePacking)
+		}
+	}()
+	sm.AddTransition(OrderStatePending, OrderEventConfirm, OrderStateShipped)
+}
@@ -0,0 +1,165 @@
+package statemachine
+
+import (
+	"maps"
+	"time"
+)
+
+// WithTimeline makes instances of the machine record a Frame for their
+// last depth transitions, which Timeline steps through. Snapshots keep the
+// frames, so the path an entity took to a production incident can be
+// examined from its stored snapshot. It panics if depth is not positive.
+func WithTimeline(depth int) Option {
+	if depth <= 0 {
+		panic("statemachine: WithTimeline needs a positive depth")
+	}
+	return func(c *config) {
+		c.timeline = depth
+	}
+}
+
+// Frame is an instance as it was just after a transition
+type Frame[S State] struct {
+	// Version is the instance's version after the transition
+	Version int64 `json:"version"`
+
+	// At is when the transition was made, by the machine's clock
+	At time.Time `json:"at"`
+
+	// Event names the event of the transition, as Name names it. It is
+	// empty for the frame recorded when the instance was created or
+	// restored, and for a transition taken back by Undo it names the event
+	// taken back.
+	Event string `json:"event,omitempty"`
+	Undo  bool   `json:"undo,omitempty"`
+
+	// State is the state entered
+	State S `json:"state"`
+
+	// Data holds the values stored with SetData at the time. It is a
+	// shallow copy: values the instance changes in place change here too.
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// record adds a frame for the instance as it is now, forgetting the oldest
+// once the machine's depth is reached. It does nothing unless the machine
+// was created WithTimeline.
+func (i *Instance[S, E]) record(event string, undo bool) {
+	depth := i.sm.timelineDepth
+	if depth == 0 {
+		return
+	}
+	if len(i.frames) == depth {
+		i.frames = append(i.frames[:0], i.frames[1:]...)
+	}
+	i.frames = append(i.frames, Frame[S]{
+		Version: i.version,
+		At:      i.enteredAt,
+		Event:   event,
+		Undo:    undo,
+		State:   i.current,
+		Data:    maps.Clone(i.data),
+	})
+}
+
+// Timeline is a cursor over the frames an instance has recorded, for
+// stepping back and forth through its history while debugging:
+//
+//	tl := statemachine.NewTimeline(snap.Timeline)
+//	for ok := tl.Last(); ok; ok = tl.Back() {
+//		f := tl.Frame()
+//		log.Printf("v%d %s: %s -> %v %v", f.Version, f.At, f.Event, f.State, f.Data)
+//	}
+//
+// A Timeline starts on its latest frame.
+type Timeline[S State] struct {
+	frames []Frame[S]
+	pos    int
+}
+
+// NewTimeline returns a Timeline over frames, oldest first, such as those
+// of a stored Snapshot
+func NewTimeline[S State](frames []Frame[S]) *Timeline[S] {
+	return &Timeline[S]{frames: frames, pos: len(frames) - 1}
+}
+
+// Timeline returns a Timeline over the frames the instance has recorded so
+// far. It is empty unless the machine was created WithTimeline.
+func (i *Instance[S, E]) Timeline() *Timeline[S] {
+	return NewTimeline(append([]Frame[S](nil), i.frames...))
+}
+
+// Len returns the number of frames
+func (t *Timeline[S]) Len() int {
+	return len(t.frames)
+}
+
+// Frames returns every frame, oldest first
+func (t *Timeline[S]) Frames() []Frame[S] {
+	return t.frames
+}
+
+// Frame returns the frame the timeline is on. It returns the zero Frame for
+// an empty timeline.
+func (t *Timeline[S]) Frame() Frame[S] {
+	if len(t.frames) == 0 {
+		return Frame[S]{}
+	}
+	return t.frames[t.pos]
+}
+
+// Back steps to the previous frame, reporting false if already on the
+// first
+func (t *Timeline[S]) Back() bool {
+	if t.pos <= 0 {
+		return false
+	}
+	t.pos--
+	return true
+}
+
+// Forward steps to the next frame, reporting false if already on the last
+func (t *Timeline[S]) Forward() bool {
+	if t.pos >= len(t.frames)-1 {
+		return false
+	}
+	t.pos++
+	return true
+}
+
+// First and Last move to the oldest and latest frames, reporting false for
+// an empty timeline
+func (t *Timeline[S]) First() bool {
+	t.pos = 0
+	return len(t.frames) > 0
+}
+
+func (t *Timeline[S]) Last() bool {
+	t.pos = max(len(t.frames)-1, 0)
+	return len(t.frames) > 0
+}
+
+// ToVersion moves to the frame recorded at version, reporting false, without
+// moving, if there is none
+func (t *Timeline[S]) ToVersion(version int64) bool {
+	for n, f := range t.frames {
+		if f.Version == version {
+			t.pos = n
+			return true
+		}
+	}
+	return false
+}
+
+// At moves to the frame the instance was on at time at: the latest
+// recorded no later than it. It reports false, without moving, if every
+// frame is later.
+func (t *Timeline[S]) At(at time.Time) bool {
+	for n := len(t.frames) - 1; n >= 0; n-- {
+		if !t.frames[n].At.After(at) {
+			t.pos = n
+			return true
+		}
+	}
+	return false
+}
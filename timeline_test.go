@@ -0,0 +1,112 @@
+package statemachine
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInstance_Timeline(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := newReviewMachine(t, WithClock(clock), WithTimeline(3), WithReversible(1))
+	inst := sm.NewInstanceAt(docDraft)
+	ctx := context.Background()
+
+	clock.advance(time.Minute)
+	inst.SetData("reviewer", "alice")
+	fireAll(t, inst, docSubmit)
+	clock.advance(time.Minute)
+	inst.SetData("reviewer", "bob")
+	fireAll(t, inst, docAdvance)
+	clock.advance(time.Minute)
+	if err := inst.Undo(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Frame[docState]{
+		{Version: 1, At: epoch.Add(time.Minute), Event: "Submit", State: docFirstReview, Data: map[string]any{"reviewer": "alice"}},
+		{Version: 2, At: epoch.Add(2 * time.Minute), Event: "Advance", State: docLegalDraft, Data: map[string]any{"reviewer": "bob"}},
+		{Version: 3, At: epoch.Add(3 * time.Minute), Event: "Advance", Undo: true, State: docFirstReview, Data: map[string]any{"reviewer": "bob"}},
+	}
+	tl := inst.Timeline()
+	if got := tl.Frames(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Frames() = %+v, want %+v", got, want)
+	}
+
+	// step back from the latest frame, then forward again
+	var versions []int64
+	for ok := true; ok; ok = tl.Back() {
+		versions = append(versions, tl.Frame().Version)
+	}
+	for tl.Forward() {
+		versions = append(versions, tl.Frame().Version)
+	}
+	if want := []int64{3, 2, 1, 2, 3}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("visited versions %v, want %v", versions, want)
+	}
+
+	tests := []struct {
+		name string
+		move func() bool
+		ok   bool
+		want int64
+	}{
+		{"First", tl.First, true, 1},
+		{"Last", tl.Last, true, 3},
+		{"ToVersion", func() bool { return tl.ToVersion(2) }, true, 2},
+		{"ToVersion missing", func() bool { return tl.ToVersion(0) }, false, 2},
+		{"At", func() bool { return tl.At(epoch.Add(90 * time.Second)) }, true, 1},
+		{"At too early", func() bool { return tl.At(epoch) }, false, 1},
+	}
+	for _, tt := range tests {
+		if ok := tt.move(); ok != tt.ok || tl.Frame().Version != tt.want {
+			t.Errorf("%s() = %v on version %d, want %v on %d", tt.name, ok, tl.Frame().Version, tt.ok, tt.want)
+		}
+	}
+
+	// the frames survive a snapshot stored as JSON
+	b, err := json.Marshal(inst.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap Snapshot[docState]
+	if err := json.Unmarshal(b, &snap); err != nil {
+		t.Fatal(err)
+	}
+	if got := NewTimeline(snap.Timeline).Frames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Frames() from stored snapshot = %+v, want %+v", got, want)
+	}
+}
+
+func TestInstance_TimelineStarts(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := newReviewMachine(t, WithClock(clock), WithTimeline(5))
+	start := []Frame[docState]{{At: epoch, State: docDraft}}
+
+	if got := sm.NewInstanceAt(docDraft).Timeline().Frames(); !reflect.DeepEqual(got, start) {
+		t.Errorf("Frames() of new instance = %+v, want %+v", got, start)
+	}
+
+	inst := sm.NewInstanceAt(docDraft)
+	if err := inst.Restore(Snapshot[docState]{State: docPaused, Version: 7, EnteredAt: epoch}); err != nil {
+		t.Fatal(err)
+	}
+	want := []Frame[docState]{{Version: 7, At: epoch, State: docPaused}}
+	if got := inst.Timeline().Frames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Frames() of restored instance = %+v, want %+v", got, want)
+	}
+
+	if err := inst.Restore(Snapshot[docState]{State: docDraft, Timeline: []Frame[docState]{{State: "Archived"}}}); err == nil {
+		t.Error("Restore() of a timeline with an unknown state succeeded")
+	}
+
+	empty := newReviewMachine(t).NewInstanceAt(docDraft).Timeline()
+	if empty.Len() != 0 || empty.First() || empty.Back() || empty.Forward() {
+		t.Errorf("Timeline() without WithTimeline has %d frames", empty.Len())
+	}
+	if f := empty.Frame(); !reflect.DeepEqual(f, Frame[docState]{}) {
+		t.Errorf("Frame() of empty timeline = %+v", f)
+	}
+}
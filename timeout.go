@@ -0,0 +1,338 @@
+package statemachine
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules timers for timeouts. The system clock
+// is used unless the machine is created WithClock, which lets tests move
+// time forward by hand; statemachinetest.Clock is one for that purpose.
+type Clock interface {
+	Now() time.Time
+
+	// AfterFunc calls f in its own goroutine once d has passed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a call scheduled by a Clock. *time.Timer implements it.
+type Timer interface {
+	// Stop prevents the call, reporting whether it was still pending
+	Stop() bool
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// WithClock makes the machine read the time and schedule timeouts with c
+func WithClock(c Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
+// Clock returns the clock the machine uses for timeouts
+func (sm *StateMachine[S, E]) Clock() Clock {
+	return sm.clock
+}
+
+// timeout is an event fired once a state has been occupied for a while
+type timeout[E Event] struct {
+	after time.Duration
+	event E
+}
+
+// SetTimeout makes an instance fire event once it has spent after in
+// state, for example rejecting a signup whose email is not verified within
+// 48 hours:
+//
+//	sm.SetTimeout(UserStateEmailPendingVerification, 48*time.Hour, UserEventSignupFailed)
+//
+// A timeout on a composite state runs while the instance is in any of its
+// substates, and is not restarted by moves between them. Instances record
+// their deadline when they enter the state; Timers fires it when it passes,
+// or Instance.FireDue does when called.
+func (sm *StateMachine[S, E]) SetTimeout(state S, after time.Duration, event E) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.timeouts[state] = timeout[E]{after: after, event: event}
+}
+
+// GetTimeout returns the timeout set on a state with SetTimeout
+func (sm *StateMachine[S, E]) GetTimeout(state S) (after time.Duration, event E, ok bool) {
+	sm.rlock()
+	defer sm.runlock()
+
+	t, ok := sm.timeouts[state]
+	return t.after, t.event, ok
+}
+
+// timedState returns the nearest state in state's lineage with a timeout
+func (sm *StateMachine[S, E]) timedState(state S) (S, timeout[E], bool) {
+	sm.rlock()
+	defer sm.runlock()
+
+	return sm.findTimeout(state)
+}
+
+// findTimeout is timedState for callers holding the lock
+func (sm *StateMachine[S, E]) findTimeout(state S) (S, timeout[E], bool) {
+	for _, s := range sm.lineage(state) {
+		if t, ok := sm.timeouts[s]; ok {
+			return s, t, true
+		}
+	}
+	var zero S
+	return zero, timeout[E]{}, false
+}
+
+// enter updates the instance's deadline for a move from one state to
+// another, keeping the deadline of a composite state it moves within
+func (i *Instance[S, E]) enter(from, to S) {
+	timed, t, ok := i.sm.timedState(to)
+	if !ok {
+		i.deadline = time.Time{}
+		return
+	}
+	if !i.deadline.IsZero() && timed == i.timed && from != timed && to != timed && i.sm.IsSubstateOf(from, timed) {
+		return
+	}
+	i.timed = timed
+	i.deadline = i.sm.clock.Now().Add(t.after)
+}
+
+// Deadline returns when the timeout of the instance's current state fires,
+// if the state has one
+func (i *Instance[S, E]) Deadline() (time.Time, bool) {
+	return i.deadline, !i.deadline.IsZero()
+}
+
+// FireDue fires the instance's timeouts and scheduled events that have
+// fallen due, in the order they fell due, reporting whether it fired any.
+// Call it to catch up on those that passed while an instance was stored,
+// after restoring it.
+//
+// FireDue stops at the first event that fails and returns its error. A
+// failed event is not tried again: a failed timeout only runs again once
+// the instance re-enters its state.
+func (i *Instance[S, E]) FireDue(ctx context.Context) (fired bool, err error) {
+	for {
+		at, ok := i.nextDue()
+		if !ok || i.sm.clock.Now().Before(at) {
+			return fired, nil
+		}
+		var event E
+		if len(i.scheduled) > 0 && i.scheduled[0].At.Equal(at) {
+			event = i.scheduled[0].Event
+			i.scheduled = i.scheduled[1:]
+		} else {
+			_, t, _ := i.sm.timedState(i.timed)
+			event = t.event
+			i.deadline = time.Time{}
+		}
+		if err := i.Fire(ctx, event); err != nil {
+			return fired, err
+		}
+		fired = true
+	}
+}
+
+// Timers fires the timeouts, scheduled events and crons of instances as
+// they fall due, using the machine's clock:
+//
+//	timers := statemachine.NewTimers(sm)
+//	defer timers.Stop()
+//	user := sm.NewInstance()
+//	timers.Watch(user)
+//	...
+//	err := timers.Fire(ctx, user, UserEventClickVerificationLink)
+//
+// Events fire on the clock's goroutines, so once an instance is watched it
+// must only be fired, scheduled and read through Timers, which serializes
+// access to it:
+//
+//	timers.Do(user, func(user *statemachine.Instance[UserState, UserEvent]) {
+//		user.FireAfter(24*time.Hour, UserEventSendReminder)
+//	})
+type Timers[S State, E Event] struct {
+	// OnError, if set, is called when a timeout or scheduled event fails.
+	// The event is not tried again.
+	OnError func(inst *Instance[S, E], err error)
+
+	// Context, if set, is passed to the events the timers fire. Once it is
+	// done no more events fire, and those pending stay with their
+	// instances, for FireDue or another Timers to fire later.
+	Context context.Context
+
+	sm      *StateMachine[S, E]
+	mu      sync.Mutex
+	watched map[*Instance[S, E]]Timer
+
+	// crons holds the timer of each of the machine's crons, once the first
+	// instance is watched
+	crons []Timer
+}
+
+// NewTimers returns timers for instances of sm
+func NewTimers[S State, E Event](sm *StateMachine[S, E]) *Timers[S, E] {
+	return &Timers[S, E]{sm: sm, watched: make(map[*Instance[S, E]]Timer)}
+}
+
+// Watch schedules the instance's timeouts and scheduled events, firing any
+// already due
+func (t *Timers[S, E]) Watch(inst *Instance[S, E]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.watched[inst] = nil
+	t.arm(inst)
+	if t.crons == nil {
+		t.sm.rlock()
+		crons := slices.Clone(t.sm.crons)
+		t.sm.runlock()
+		t.crons = make([]Timer, len(crons))
+		for n, c := range crons {
+			t.armCron(n, c)
+		}
+	}
+}
+
+// Unwatch stops firing the instance's timeouts and scheduled events
+func (t *Timers[S, E]) Unwatch(inst *Instance[S, E]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if timer := t.watched[inst]; timer != nil {
+		timer.Stop()
+	}
+	delete(t.watched, inst)
+}
+
+// Fire transitions a watched instance via event, rescheduling its timeout
+// for the state it moves to
+func (t *Timers[S, E]) Fire(ctx context.Context, inst *Instance[S, E], event E) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err := inst.Fire(ctx, event)
+	if _, ok := t.watched[inst]; ok {
+		t.arm(inst)
+	}
+	return err
+}
+
+// Do calls fn with the instance while none of its events can fire, for
+// reading it, scheduling events or firing it in ways Fire does not cover
+func (t *Timers[S, E]) Do(inst *Instance[S, E], fn func(*Instance[S, E])) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fn(inst)
+	if _, ok := t.watched[inst]; ok {
+		t.arm(inst)
+	}
+}
+
+// Stop stops firing the events of every watched instance
+func (t *Timers[S, E]) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for inst, timer := range t.watched {
+		if timer != nil {
+			timer.Stop()
+		}
+		delete(t.watched, inst)
+	}
+	for _, timer := range t.crons {
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	t.crons = nil
+}
+
+// arm replaces the instance's timer with one for its next timeout or
+// scheduled event. Those already due fire straight away. t.mu must be held.
+func (t *Timers[S, E]) arm(inst *Instance[S, E]) {
+	if timer := t.watched[inst]; timer != nil {
+		timer.Stop()
+		t.watched[inst] = nil
+	}
+	at, ok := inst.nextDue()
+	if !ok {
+		return
+	}
+	var timer Timer
+	timer = t.sm.clock.AfterFunc(at.Sub(t.sm.clock.Now()), func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if t.watched[inst] != timer {
+			// unwatched or rescheduled while this call waited for t.mu
+			return
+		}
+		t.watched[inst] = nil
+		ctx := t.context()
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := inst.FireDue(ctx); err != nil {
+			if t.OnError != nil {
+				t.OnError(inst, err)
+			}
+		}
+		t.arm(inst)
+	})
+	t.watched[inst] = timer
+}
+
+// armCron schedules the nth of the machine's crons for the next time it
+// matches. When it does, it fires on every watched instance in its state.
+// t.mu must be held.
+func (t *Timers[S, E]) armCron(n int, c cronTrigger[S, E]) {
+	now := t.sm.clock.Now()
+	next := c.schedule.Next(now)
+	if next.IsZero() {
+		return
+	}
+	var timer Timer
+	timer = t.sm.clock.AfterFunc(next.Sub(now), func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if n >= len(t.crons) || t.crons[n] != timer {
+			return
+		}
+		ctx := t.context()
+		if ctx.Err() != nil {
+			t.crons[n] = nil
+			return
+		}
+		for inst := range t.watched {
+			if !inst.IsIn(c.state) {
+				continue
+			}
+			if err := inst.Fire(ctx, c.event); err != nil && t.OnError != nil {
+				t.OnError(inst, err)
+			}
+			t.arm(inst)
+		}
+		t.armCron(n, c)
+	})
+	t.crons[n] = timer
+}
+
+func (t *Timers[S, E]) context() context.Context {
+	if t.Context == nil {
+		return context.Background()
+	}
+	return t.Context
+}
@@ -0,0 +1,333 @@
+package statemachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock moves only when advanced, running due calls as it goes
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	c  *fakeClock
+	at time.Time
+	f  func()
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{c: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	i := slices.Index(t.c.timers, t)
+	if i >= 0 {
+		t.c.timers = slices.Delete(t.c.timers, i, i+1)
+	}
+	return i >= 0
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	until := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		i := slices.IndexFunc(c.timers, func(t *fakeTimer) bool { return !t.at.After(until) })
+		if i < 0 {
+			c.now = until
+			c.mu.Unlock()
+			return
+		}
+		t := c.timers[i]
+		c.timers = slices.Delete(c.timers, i, i+1)
+		c.now = t.at
+		c.mu.Unlock()
+		t.f()
+	}
+}
+
+var epoch = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newSignupMachine rejects signups not verified within 48 hours, and
+// reviews not finished within a week, however they move between review
+// stages
+func newSignupMachine(clock Clock) *StateMachine[UserState, UserEvent] {
+	sm := NewStateMachine[UserState, UserEvent](WithClock(clock))
+	sm.SetInitialState(UserStateInitial)
+	sm.AddTransition(UserStateInitial, UserEventSubmitSignUp, UserStateEmailPendingVerification)
+	sm.AddTransition(UserStateEmailPendingVerification, UserEventClickVerificationLink, UserStateEmailVerified)
+	sm.AddTransition(UserStateEmailPendingVerification, UserEventSignupFailed, UserStateRejected)
+	sm.AddTransition(UserStateEmailPendingVerification, "Resend", UserStateEmailPendingVerification)
+	sm.AddTransition(UserStateEmailVerified, "Review", "Screening")
+	sm.AddTransition("Screening", "Escalate", "Interview")
+	sm.AddTransition("Reviewing", UserEventSignupFailed, UserStateRejected)
+	sm.AddTransition("Interview", UserEventCompleteProfile, UserStateSignUpComplete)
+	if err := sm.SetParent("Screening", "Reviewing"); err != nil {
+		panic(err)
+	}
+	if err := sm.SetParent("Interview", "Reviewing"); err != nil {
+		panic(err)
+	}
+	sm.SetTimeout(UserStateEmailPendingVerification, 48*time.Hour, UserEventSignupFailed)
+	sm.SetTimeout("Reviewing", 7*24*time.Hour, UserEventSignupFailed)
+	return sm
+}
+
+func TestInstance_Deadline(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []UserEvent
+		wait   time.Duration
+		want   time.Duration // from epoch, or 0 for no deadline
+	}{
+		{
+			name: "untimed state",
+		},
+		{
+			name:   "entering a timed state",
+			events: []UserEvent{UserEventSubmitSignUp},
+			want:   48 * time.Hour,
+		},
+		{
+			name:   "re-entering a timed state restarts it",
+			events: []UserEvent{UserEventSubmitSignUp, "Resend"},
+			wait:   time.Hour,
+			want:   49 * time.Hour,
+		},
+		{
+			name:   "leaving a timed state",
+			events: []UserEvent{UserEventSubmitSignUp, UserEventClickVerificationLink},
+		},
+		{
+			name:   "entering a timed composite state",
+			events: []UserEvent{UserEventSubmitSignUp, UserEventClickVerificationLink, "Review"},
+			want:   7 * 24 * time.Hour,
+		},
+		{
+			name:   "moving within a timed composite state",
+			events: []UserEvent{UserEventSubmitSignUp, UserEventClickVerificationLink, "Review", "Escalate"},
+			wait:   time.Hour,
+			want:   7 * 24 * time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: epoch}
+			inst := newSignupMachine(clock).NewInstance()
+			for n, event := range tt.events {
+				if n == len(tt.events)-1 {
+					clock.advance(tt.wait)
+				}
+				if err := inst.Fire(context.Background(), event); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			deadline, ok := inst.Deadline()
+			if tt.want == 0 {
+				if ok {
+					t.Errorf("Deadline() = %v, want none", deadline)
+				}
+				return
+			}
+			if want := epoch.Add(tt.want); !ok || !deadline.Equal(want) {
+				t.Errorf("Deadline() = %v, %v, want %v", deadline, ok, want)
+			}
+		})
+	}
+}
+
+func TestInstance_FireDue(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	inst := newSignupMachine(clock).NewInstanceAt(UserStateEmailPendingVerification)
+	ctx := context.Background()
+
+	clock.advance(47 * time.Hour)
+	if fired, err := inst.FireDue(ctx); fired || err != nil {
+		t.Fatalf("FireDue() before the deadline = %v, %v", fired, err)
+	}
+	clock.advance(time.Hour)
+	if fired, err := inst.FireDue(ctx); !fired || err != nil {
+		t.Fatalf("FireDue() at the deadline = %v, %v", fired, err)
+	}
+	if !inst.Is(UserStateRejected) {
+		t.Errorf("Current() = %v, want %v", inst.Current(), UserStateRejected)
+	}
+	if fired, err := inst.FireDue(ctx); fired || err != nil {
+		t.Errorf("FireDue() in an untimed state = %v, %v", fired, err)
+	}
+}
+
+func TestInstance_DeadlineSnapshot(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := newSignupMachine(clock)
+	inst := sm.NewInstanceAt(UserStateEmailPendingVerification)
+
+	data, err := json.Marshal(inst.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap Snapshot[UserState]
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+
+	// the service is down past the deadline, and catches up on restart
+	clock.advance(72 * time.Hour)
+	restored := sm.NewInstanceAt(UserStateInitial)
+	if err := restored.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if deadline, _ := restored.Deadline(); !deadline.Equal(epoch.Add(48 * time.Hour)) {
+		t.Errorf("restored Deadline() = %v, want %v", deadline, epoch.Add(48*time.Hour))
+	}
+	if fired, err := restored.FireDue(context.Background()); !fired || err != nil {
+		t.Fatalf("FireDue() = %v, %v", fired, err)
+	}
+
+	// a bare state loaded from a database row starts its timeout afresh
+	if err := restored.Restore(Snapshot[UserState]{State: UserStateEmailPendingVerification}); err != nil {
+		t.Fatal(err)
+	}
+	if deadline, _ := restored.Deadline(); !deadline.Equal(epoch.Add(120 * time.Hour)) {
+		t.Errorf("Deadline() restored without one = %v, want %v", deadline, epoch.Add(120*time.Hour))
+	}
+}
+
+func TestTimers(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := newSignupMachine(clock)
+	timers := NewTimers(sm)
+	defer timers.Stop()
+	ctx := context.Background()
+
+	verified := sm.NewInstance()
+	abandoned := sm.NewInstance()
+	unwatched := sm.NewInstance()
+	for _, inst := range []*Instance[UserState, UserEvent]{verified, abandoned, unwatched} {
+		timers.Watch(inst)
+		if err := timers.Fire(ctx, inst, UserEventSubmitSignUp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	timers.Unwatch(unwatched)
+
+	clock.advance(24 * time.Hour)
+	if err := timers.Fire(ctx, verified, UserEventClickVerificationLink); err != nil {
+		t.Fatal(err)
+	}
+	clock.advance(24 * time.Hour)
+
+	timers.Do(verified, func(inst *Instance[UserState, UserEvent]) {
+		if !inst.Is(UserStateEmailVerified) {
+			t.Errorf("verified signup is at %v, want %v", inst.Current(), UserStateEmailVerified)
+		}
+	})
+	timers.Do(abandoned, func(inst *Instance[UserState, UserEvent]) {
+		if !inst.Is(UserStateRejected) {
+			t.Errorf("abandoned signup is at %v, want %v", inst.Current(), UserStateRejected)
+		}
+	})
+	if !unwatched.Is(UserStateEmailPendingVerification) {
+		t.Errorf("unwatched signup is at %v, want %v", unwatched.Current(), UserStateEmailPendingVerification)
+	}
+	if n := len(clock.timers); n != 0 {
+		t.Errorf("%d timers left scheduled, want none", n)
+	}
+}
+
+func TestTimers_OnError(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := newSignupMachine(clock)
+	errMailer := errors.New("mailer unavailable")
+	sm.AddTransition(UserStateEmailPendingVerification, UserEventSignupFailed, UserStateRejected,
+		WithAction(func(ctx context.Context, from, to UserState, event UserEvent) error {
+			return errMailer
+		}))
+	timers := NewTimers(sm)
+	defer timers.Stop()
+	var failed []error
+	timers.OnError = func(inst *Instance[UserState, UserEvent], err error) {
+		failed = append(failed, err)
+	}
+
+	inst := sm.NewInstanceAt(UserStateEmailPendingVerification)
+	timers.Watch(inst)
+	clock.advance(96 * time.Hour)
+
+	if len(failed) != 1 || !errors.Is(failed[0], errMailer) {
+		t.Errorf("OnError got %v, want one %v", failed, errMailer)
+	}
+	if !inst.Is(UserStateEmailPendingVerification) {
+		t.Errorf("Current() = %v, want %v", inst.Current(), UserStateEmailPendingVerification)
+	}
+}
+
+func TestTimers_Context(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := newSignupMachine(clock)
+	timers := NewTimers(sm)
+	defer timers.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	timers.Context = ctx
+
+	inst := sm.NewInstanceAt(UserStateEmailPendingVerification)
+	timers.Watch(inst)
+	cancel()
+	clock.advance(96 * time.Hour)
+
+	if !inst.Is(UserStateEmailPendingVerification) {
+		t.Errorf("Current() = %v after the context was cancelled, want %v", inst.Current(), UserStateEmailPendingVerification)
+	}
+	if fired, err := inst.FireDue(context.Background()); !fired || err != nil || !inst.Is(UserStateRejected) {
+		t.Errorf("FireDue() = %v, %v, at %v, want the pending timeout to fire", fired, err, inst.Current())
+	}
+}
+
+func TestTimeout_CloneMerge(t *testing.T) {
+	clock := &fakeClock{now: epoch}
+	sm := newSignupMachine(clock)
+
+	clone := sm.Clone()
+	clone.SetTimeout(UserStateEmailPendingVerification, time.Hour, UserEventSignupFailed)
+	if after, _, _ := sm.GetTimeout(UserStateEmailPendingVerification); after != 48*time.Hour {
+		t.Errorf("changing the clone's timeout changed the original's to %v", after)
+	}
+	if clone.Clock() != Clock(clock) {
+		t.Error("Clone() did not keep the clock")
+	}
+
+	merged := NewStateMachine[UserState, UserEvent]()
+	merged.SetTimeout(UserStateEmailPendingVerification, 24*time.Hour, UserEventSignupFailed)
+	if err := merged.Merge(sm); err != nil {
+		t.Fatal(err)
+	}
+	if after, _, _ := merged.GetTimeout(UserStateEmailPendingVerification); after != 24*time.Hour {
+		t.Errorf("Merge() replaced the machine's own timeout with %v", after)
+	}
+	if after, event, ok := merged.GetTimeout("Reviewing"); !ok || after != 7*24*time.Hour || event != UserEventSignupFailed {
+		t.Errorf("GetTimeout(Reviewing) after Merge() = %v, %v, %v", after, event, ok)
+	}
+}
@@ -0,0 +1,115 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNothingToUndo is returned by Undo when the instance has no transition
+// it can take back
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// WithReversible makes instances of the machine remember their last depth
+// transitions, so Undo can take them back, as admin tooling often needs
+// to: "take the document back to Reviewing". It panics if depth is not
+// positive.
+func WithReversible(depth int) Option {
+	if depth <= 0 {
+		panic("statemachine: WithReversible needs a positive depth")
+	}
+	return func(c *config) {
+		c.undo = depth
+	}
+}
+
+// undoEntry is a transition Undo can take back: the state left and the
+// event that left it
+type undoEntry[S State, E Event] struct {
+	from  S
+	event E
+}
+
+// remember notes a transition from a state via event for Undo, forgetting
+// the oldest once the machine's depth is reached. It does nothing unless
+// the machine was created WithReversible.
+func (i *Instance[S, E]) remember(from S, event E) {
+	depth := i.sm.undoDepth
+	if depth == 0 {
+		return
+	}
+	if len(i.undo) == depth {
+		i.undo = append(i.undo[:0], i.undo[1:]...)
+	}
+	i.undo = append(i.undo, undoEntry[S, E]{from: from, event: event})
+}
+
+// CanUndo reports whether Undo has a transition to take back
+func (i *Instance[S, E]) CanUndo() bool {
+	return len(i.undo) > 0
+}
+
+// Undo takes back the instance's last transition, returning it to the
+// state it was in before:
+//
+//	if err := doc.Undo(ctx); err != nil {
+//		return err
+//	}
+//
+// Guards, actions and BeforeTransition hooks are not run, but the exit
+// callbacks of the states left and the enter callbacks of those entered
+// are, as are subscribers, with the event of the transition taken back and
+// a context for which IsUndo reports true. The version increases as for
+// any other transition, and undoing into a state with a timeout starts it
+// afresh. Undo can be called repeatedly to take back earlier transitions.
+//
+// Undo returns ErrNothingToUndo if the machine was not created
+// WithReversible, or the instance has no transitions left to take back;
+// those made before it was restored from a Snapshot are not remembered.
+func (i *Instance[S, E]) Undo(ctx context.Context) error {
+	if i.firing {
+		return ErrTransitioning
+	}
+	if len(i.undo) == 0 {
+		return ErrNothingToUndo
+	}
+	last := i.undo[len(i.undo)-1]
+	i.undo = i.undo[:len(i.undo)-1]
+	return i.rewind(ctx, last.from, last.event)
+}
+
+// rewind moves the instance back to a state it left via event, as Undo and
+// Saga.Compensate do: the callbacks of the states left and entered run, and
+// subscribers are notified, with a context for which IsUndo reports true,
+// and the move is kept in the instance's history, dwell times and timeline
+// like any other transition
+func (i *Instance[S, E]) rewind(ctx context.Context, to S, event E) error {
+	ctx = context.WithValue(ctx, undoKey{}, true)
+	return i.run(ctx, func() error {
+		from := i.current
+		i.sm.runCallbacks(ctx, from, to, event)
+		i.sm.rlock()
+		i.sm.recordHistory(&i.history, from, to)
+		i.sm.runlock()
+
+		now := i.sm.clock.Now()
+		i.leave(now)
+		i.enter(from, to)
+		i.current = to
+		i.enteredAt = now
+		i.version++
+		i.record(Name(event), true)
+		i.sm.listeners.notify(ctx, i.sm.clock, from, to, event)
+		i.release()
+		return nil
+	})
+}
+
+type undoKey struct{}
+
+// IsUndo reports whether ctx is that of a transition being taken back by
+// Undo, so callbacks and subscribers can tell it apart from the event
+// they are given
+func IsUndo(ctx context.Context) bool {
+	undo, _ := ctx.Value(undoKey{}).(bool)
+	return undo
+}
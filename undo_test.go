@@ -0,0 +1,100 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInstance_Undo(t *testing.T) {
+	sm := newReviewMachine(t, WithReversible(2))
+	var calls []string
+	for _, s := range []docState{docDraft, docFirstReview, docLegal, docLegalDraft} {
+		sm.OnEnter(s, func(ctx context.Context, from, to docState, event docEvent) {
+			calls = append(calls, fmt.Sprintf("enter %s via %s undo=%v", s, event, IsUndo(ctx)))
+		})
+		sm.OnExit(s, func(ctx context.Context, from, to docState, event docEvent) {
+			calls = append(calls, fmt.Sprintf("exit %s", s))
+		})
+	}
+	var notified []docState
+	sm.Subscribe(func(ctx context.Context, from, to docState, event docEvent, _ time.Time) {
+		notified = append(notified, to)
+	})
+	inst := sm.NewInstanceAt(docDraft)
+	ctx := context.Background()
+	fireAll(t, inst, docSubmit, docAdvance, docSign)
+
+	calls, notified = nil, nil
+	if err := inst.Undo(ctx); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if err := inst.Undo(ctx); err != nil {
+		t.Fatalf("second Undo() error = %v", err)
+	}
+	if got := inst.Current(); got != docFirstReview {
+		t.Errorf("Current() = %v, want %v", got, docFirstReview)
+	}
+	if got := inst.Version(); got != 5 {
+		t.Errorf("Version() = %d, want 5", got)
+	}
+	want := []string{
+		"enter LegalDraft via Sign undo=true",
+		"exit LegalDraft",
+		"exit Legal",
+		"enter FirstReview via Advance undo=true",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("callbacks = %q, want %q", calls, want)
+	}
+	if want := []docState{docLegalDraft, docFirstReview}; !reflect.DeepEqual(notified, want) {
+		t.Errorf("subscribers notified of %v, want %v", notified, want)
+	}
+
+	// only the last two transitions are remembered
+	if inst.CanUndo() {
+		t.Error("CanUndo() = true after undoing the remembered transitions")
+	}
+	if err := inst.Undo(ctx); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("third Undo() error = %v, want %v", err, ErrNothingToUndo)
+	}
+
+	// the composite state left by an undo keeps its history
+	fireAll(t, inst, docPause, docResume)
+	if got := inst.Current(); got != docFirstReview {
+		t.Errorf("Current() after resuming = %v, want %v", got, docFirstReview)
+	}
+}
+
+func TestInstance_UndoErrors(t *testing.T) {
+	ctx := context.Background()
+	inst := newReviewMachine(t).NewInstanceAt(docDraft)
+	fireAll(t, inst, docSubmit)
+	if err := inst.Undo(ctx); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undo() without WithReversible error = %v, want %v", err, ErrNothingToUndo)
+	}
+
+	sm := newReviewMachine(t, WithReversible(5))
+	inst = sm.NewInstanceAt(docDraft)
+	var undoErr error
+	sm.OnEnter(docLegalSignoff, func(ctx context.Context, from, to docState, event docEvent) {
+		undoErr = inst.Undo(ctx)
+	})
+	fireAll(t, inst, docSubmit, docAdvance, docSign)
+	if !errors.Is(undoErr, ErrTransitioning) {
+		t.Errorf("Undo() from a callback error = %v, want %v", undoErr, ErrTransitioning)
+	}
+	if got := inst.Current(); got != docLegalSignoff {
+		t.Errorf("Current() = %v, want %v", got, docLegalSignoff)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithReversible(0) did not panic")
+		}
+	}()
+	WithReversible(0)
+}
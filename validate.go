@@ -0,0 +1,156 @@
+package statemachine
+
+import "strings"
+
+// ValidationError lists the problems Validate found in a machine. Each list
+// is sorted by state name.
+type ValidationError[S State] struct {
+	// Unreachable states cannot be reached from the initial state by any
+	// sequence of events
+	Unreachable []S
+
+	// Unused states are known to the machine, for example through
+	// DeclareStates or AddFinalState, but take part in no transition
+	Unused []S
+}
+
+func (e *ValidationError[S]) Error() string {
+	var problems []string
+	if len(e.Unreachable) > 0 {
+		problems = append(problems, "unreachable states: "+joinStates(e.Unreachable))
+	}
+	if len(e.Unused) > 0 {
+		problems = append(problems, "unused states: "+joinStates(e.Unused))
+	}
+	return "invalid state machine: " + strings.Join(problems, "; ")
+}
+
+func joinStates[S State](states []S) string {
+	names := make([]string, len(states))
+	for i, s := range states {
+		names[i] = Name(s)
+	}
+	return strings.Join(names, ", ")
+}
+
+// DeclareStates records states as part of the machine even if no transition
+// uses them yet, so Validate can report those that are never wired up.
+// FromDefinition declares every state in the definition.
+func (sm *StateMachine[S, E]) DeclareStates(states ...S) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	for _, s := range states {
+		sm.declared[s] = true
+	}
+}
+
+// Validate checks the machine for mistakes that are otherwise easy to miss in
+// a large definition, such as a typo leaving a state with no way in. It
+// returns a *ValidationError describing:
+//
+//   - states that cannot be reached from the initial state, and
+//   - states that take part in no transition.
+//
+// Reachability is only checked once an initial state has been set with
+// SetInitialState. Guards are assumed to pass and every target of a choice
+// is considered reachable.
+func (sm *StateMachine[S, E]) Validate() error {
+	sm.rlock()
+	defer sm.runlock()
+
+	verr := sm.validate()
+	if len(verr.Unreachable) == 0 && len(verr.Unused) == 0 {
+		return nil
+	}
+	return verr
+}
+
+// validate collects the problems reported by Validate, sorted
+func (sm *StateMachine[S, E]) validate() *ValidationError[S] {
+	verr := &ValidationError[S]{}
+	if sm.hasInitialState {
+		reachable := sm.reachableFrom(sm.initialState)
+		for _, s := range sm.allStates() {
+			if !reachable[s] {
+				verr.Unreachable = append(verr.Unreachable, s)
+			}
+		}
+	}
+
+	used := sm.usedStates()
+	for _, s := range sm.allStates() {
+		if !used[s] {
+			verr.Unused = append(verr.Unused, s)
+		}
+	}
+
+	sortStates(verr.Unreachable)
+	sortStates(verr.Unused)
+	return verr
+}
+
+// reachableFrom returns every state that can be active, or an ancestor of
+// the active state, after any sequence of events starting at start
+func (sm *StateMachine[S, E]) reachableFrom(start S) map[S]bool {
+	reachable := make(map[S]bool)
+	queued := make(map[S]bool)
+	var queue []S
+	visit := func(target S) {
+		leaf := sm.resolveTarget(target, NoHistory, nil)
+		for _, s := range sm.lineage(leaf) {
+			reachable[s] = true
+		}
+		if !queued[leaf] {
+			queued[leaf] = true
+			queue = append(queue, leaf)
+		}
+	}
+
+	visit(start)
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for _, e := range sm.allEdgesFrom(s) {
+			for _, to := range e.targets() {
+				visit(to)
+			}
+		}
+	}
+	return reachable
+}
+
+// allEdgesFrom returns every transition that can fire from a state, including
+// inherited and competing ones
+func (sm *StateMachine[S, E]) allEdgesFrom(state S) []*edge[S, E] {
+	var edges []*edge[S, E]
+	for _, s := range sm.lineage(state) {
+		for _, byEvent := range sm.transitions[s] {
+			edges = append(edges, byEvent...)
+		}
+	}
+	return edges
+}
+
+// usedStates returns the states that are the source or target of a
+// transition, along with their ancestors
+func (sm *StateMachine[S, E]) usedStates() map[S]bool {
+	used := make(map[S]bool)
+	mark := func(state S) {
+		for _, s := range sm.lineage(state) {
+			used[s] = true
+		}
+	}
+	for from, byEvent := range sm.transitions {
+		mark(from)
+		for _, edges := range byEvent {
+			for _, e := range edges {
+				for _, to := range e.targets() {
+					mark(to)
+				}
+			}
+		}
+	}
+	return used
+}
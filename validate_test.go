@@ -0,0 +1,123 @@
+package statemachine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidate(t *testing.T) {
+	const (
+		lost     OrderState = "Lost"
+		archived OrderState = "Archived"
+	)
+
+	tests := []struct {
+		name            string
+		setup           func(sm *StateMachine[OrderState, OrderEvent])
+		wantUnreachable []OrderState
+		wantUnused      []OrderState
+	}{
+		{
+			name: "valid",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.SetInitialState(OrderStatePending)
+			},
+		},
+		{
+			name:  "reachability not checked without initial state",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {},
+		},
+		{
+			name: "target of a transition from an unreachable state",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.SetInitialState(OrderStatePending)
+				// a typo: Lost is never entered, so nothing can leave it
+				sm.AddTransition(lost, OrderEventRefund, OrderStateRefunded)
+			},
+			wantUnreachable: []OrderState{lost},
+		},
+		{
+			name: "later start makes earlier states unreachable",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.SetInitialState(OrderStateShipped)
+			},
+			wantUnreachable: []OrderState{OrderStateAwaiting, OrderStateCancelled, OrderStatePacking, OrderStatePending, OrderStateProcessing},
+		},
+		{
+			name: "declared but never used",
+			setup: func(sm *StateMachine[OrderState, OrderEvent]) {
+				sm.SetInitialState(OrderStatePending)
+				sm.DeclareStates(archived, OrderStateShipped)
+			},
+			wantUnreachable: []OrderState{archived},
+			wantUnused:      []OrderState{archived},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewOrderStateMachine()
+			tt.setup(sm)
+
+			err := sm.Validate()
+			if tt.wantUnreachable == nil && tt.wantUnused == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError[OrderState]
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() error = %v, want *ValidationError", err)
+			}
+			if !reflect.DeepEqual(verr.Unreachable, tt.wantUnreachable) {
+				t.Errorf("Unreachable = %v, want %v", verr.Unreachable, tt.wantUnreachable)
+			}
+			if !reflect.DeepEqual(verr.Unused, tt.wantUnused) {
+				t.Errorf("Unused = %v, want %v", verr.Unused, tt.wantUnused)
+			}
+		})
+	}
+}
+
+func TestValidate_EntersInitialSubstate(t *testing.T) {
+	sm := NewStateMachine[docState, docEvent]()
+	sm.SetParent(docFirstReview, docReview)
+	sm.SetParent(docLegal, docReview)
+	sm.AddTransition(docDraft, docSubmit, docReview)
+	sm.AddTransition(docFirstReview, docAdvance, docLegal)
+	sm.SetInitialState(docDraft)
+
+	// without an initial substate the machine sits in Review itself
+	err := sm.Validate()
+	if err == nil || !strings.Contains(err.Error(), "unreachable states: FirstReview, Legal") {
+		t.Errorf("Validate() error = %v, want substates unreachable", err)
+	}
+
+	if err := sm.SetInitialSubstate(docReview, docFirstReview); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestValidate_DefinitionStates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"states.json":      {Data: []byte(strings.Replace(userStatesFile, `"name": "signup",`, `"name": "signup", "initial": "Initial",`, 1))},
+		"transitions.json": {Data: []byte(userTransitionsFile)},
+	}
+	sm, err := LoadFS(fsys, allUserStates, allUserEvents, "*.json")
+	if err != nil {
+		t.Fatalf("LoadFS() error = %v", err)
+	}
+
+	want := "invalid state machine: unreachable states: SignupRejected; unused states: SignupRejected"
+	if err := sm.Validate(); err == nil || err.Error() != want {
+		t.Errorf("Validate() error = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,162 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrStateRemoved is returned when migrating a state that was removed from
+// a definition without being mapped to a replacement
+var ErrStateRemoved = errors.New("state removed")
+
+// SetVersion records the version of the machine's definition
+func (sm *StateMachine[S, E]) SetVersion(v int) {
+	sm.lock()
+	defer sm.unlock()
+	sm.checkMutable()
+
+	sm.version = v
+}
+
+// Version returns the version set with SetVersion, or 0
+func (sm *StateMachine[S, E]) Version() int {
+	sm.rlock()
+	defer sm.runlock()
+
+	return sm.version
+}
+
+// Migration describes how states stored under one version of a definition
+// map onto the next, as built by MigrationPlan
+type Migration struct {
+	FromVersion int
+	ToVersion   int
+
+	// Added are states new in the later definition
+	Added []string
+
+	// Renamed maps old state names to the new names declared with
+	// renamedFrom
+	Renamed map[string]string
+
+	// Removed are states of the earlier definition with no counterpart in
+	// the later one. Entities in them cannot be migrated until they are
+	// given a replacement with Map.
+	Removed []string
+
+	mapped map[string]string
+	states map[string]bool
+}
+
+// MigrationPlan compares two versions of a definition and reports the states
+// added, renamed and removed. Renames are never guessed; the later
+// definition declares them on the renamed state:
+//
+//	{"name": "AwaitingVerification", "renamedFrom": ["EmailPendingVerification"]}
+//
+// An error is returned if both definitions carry a version and newDef's is
+// not later, or if newDef claims to rename a state oldDef does not declare.
+func MigrationPlan(oldDef, newDef *Definition) (*Migration, error) {
+	if oldDef.Version != 0 && newDef.Version != 0 && newDef.Version <= oldDef.Version {
+		return nil, fmt.Errorf("cannot migrate from version %d to version %d", oldDef.Version, newDef.Version)
+	}
+
+	old := make(map[string]bool, len(oldDef.States))
+	for _, s := range oldDef.States {
+		old[s.Name] = true
+	}
+
+	m := &Migration{
+		FromVersion: oldDef.Version,
+		ToVersion:   newDef.Version,
+		Renamed:     make(map[string]string),
+		mapped:      make(map[string]string),
+		states:      make(map[string]bool, len(newDef.States)),
+	}
+	for _, s := range newDef.States {
+		m.states[s.Name] = true
+		for _, from := range s.RenamedFrom {
+			if !old[from] {
+				return nil, definitionErrorf(s.pos, "state %q renamed from %q, which version %d does not declare", s.Name, from, oldDef.Version)
+			}
+			m.Renamed[from] = s.Name
+		}
+		if !old[s.Name] && len(s.RenamedFrom) == 0 {
+			m.Added = append(m.Added, s.Name)
+		}
+	}
+	for _, s := range oldDef.States {
+		if _, renamed := m.Renamed[s.Name]; !renamed && !m.states[s.Name] {
+			m.Removed = append(m.Removed, s.Name)
+		}
+	}
+	sort.Strings(m.Added)
+	sort.Strings(m.Removed)
+	return m, nil
+}
+
+// Map sends entities in a removed state to a state of the later definition,
+// for example moving everything left in a dropped "OnHold" state back to
+// "Pending"
+func (m *Migration) Map(removed, to string) error {
+	if !m.states[to] {
+		return fmt.Errorf("cannot map %q to %q: not a state in version %d", removed, to, m.ToVersion)
+	}
+	i := sort.SearchStrings(m.Removed, removed)
+	if i == len(m.Removed) || m.Removed[i] != removed {
+		return fmt.Errorf("cannot map %q: it was not removed in version %d", removed, m.ToVersion)
+	}
+	m.mapped[removed] = to
+	return nil
+}
+
+// Unmapped returns the removed states not yet given a replacement with Map.
+// Migrating is only safe for every stored entity once it is empty.
+func (m *Migration) Unmapped() []string {
+	var unmapped []string
+	for _, s := range m.Removed {
+		if _, ok := m.mapped[s]; !ok {
+			unmapped = append(unmapped, s)
+		}
+	}
+	return unmapped
+}
+
+// Migrate returns the name a stored state has in the later definition. It
+// returns an error matching ErrStateRemoved for a removed state that has not
+// been mapped, and ErrUnknownState for a name neither definition declares.
+func (m *Migration) Migrate(state string) (string, error) {
+	if to, ok := m.Renamed[state]; ok {
+		return to, nil
+	}
+	if to, ok := m.mapped[state]; ok {
+		return to, nil
+	}
+	if m.states[state] {
+		return state, nil
+	}
+	i := sort.SearchStrings(m.Removed, state)
+	if i < len(m.Removed) && m.Removed[i] == state {
+		return "", fmt.Errorf("cannot migrate state %q to version %d: %w", state, m.ToVersion, ErrStateRemoved)
+	}
+	return "", fmt.Errorf("cannot migrate state %q: %w", state, ErrUnknownState)
+}
+
+// MigrateState is Migrate for typed states, resolving the new name against
+// the given state values by name:
+//
+//	user.State, err = statemachine.MigrateState(plan, user.State, allUserStates)
+func MigrateState[S State](m *Migration, state S, states []S) (S, error) {
+	var zero S
+	name, err := m.Migrate(Name(state))
+	if err != nil {
+		return zero, err
+	}
+	for _, s := range states {
+		if Name(s) == name {
+			return s, nil
+		}
+	}
+	return zero, fmt.Errorf("cannot migrate state %q: %q is not one of the given states", Name(state), name)
+}
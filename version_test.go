@@ -0,0 +1,132 @@
+package statemachine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const signupV1 = `{
+  "version": 1,
+  "states": [
+    {"name": "Initial"},
+    {"name": "EmailPending"},
+    {"name": "OnHold"},
+    {"name": "SignUpComplete"}
+  ]
+}`
+
+const signupV2 = `{
+  "version": 2,
+  "states": [
+    {"name": "Initial"},
+    {"name": "EmailPendingVerification", "renamedFrom": ["EmailPending"]},
+    {"name": "EmailVerified"},
+    {"name": "SignUpComplete"}
+  ]
+}`
+
+func mustParse(t *testing.T, name, data string) *Definition {
+	t.Helper()
+	def, err := ParseDefinition(name, []byte(data))
+	if err != nil {
+		t.Fatalf("ParseDefinition(%s) error = %v", name, err)
+	}
+	return def
+}
+
+func TestMigrationPlan(t *testing.T) {
+	plan, err := MigrationPlan(mustParse(t, "v1.json", signupV1), mustParse(t, "v2.json", signupV2))
+	if err != nil {
+		t.Fatalf("MigrationPlan() error = %v", err)
+	}
+
+	if plan.FromVersion != 1 || plan.ToVersion != 2 {
+		t.Errorf("versions = %d -> %d, want 1 -> 2", plan.FromVersion, plan.ToVersion)
+	}
+	if want := []string{"EmailVerified"}; !reflect.DeepEqual(plan.Added, want) {
+		t.Errorf("Added = %v, want %v", plan.Added, want)
+	}
+	if want := map[string]string{"EmailPending": "EmailPendingVerification"}; !reflect.DeepEqual(plan.Renamed, want) {
+		t.Errorf("Renamed = %v, want %v", plan.Renamed, want)
+	}
+	if want := []string{"OnHold"}; !reflect.DeepEqual(plan.Removed, want) || !reflect.DeepEqual(plan.Unmapped(), want) {
+		t.Errorf("Removed = %v, Unmapped() = %v, want %v", plan.Removed, plan.Unmapped(), want)
+	}
+
+	if _, err := plan.Migrate("OnHold"); !errors.Is(err, ErrStateRemoved) {
+		t.Errorf("Migrate(OnHold) error = %v, want ErrStateRemoved", err)
+	}
+	if err := plan.Map("OnHold", "Nowhere"); err == nil {
+		t.Errorf("Map() to an undeclared state expected error")
+	}
+	if err := plan.Map("Initial", "SignUpComplete"); err == nil {
+		t.Errorf("Map() of a state that was not removed expected error")
+	}
+	if err := plan.Map("OnHold", "Initial"); err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	if got := plan.Unmapped(); len(got) != 0 {
+		t.Errorf("Unmapped() = %v after mapping, want none", got)
+	}
+
+	tests := []struct {
+		state string
+		want  string
+	}{
+		{"Initial", "Initial"},
+		{"EmailPending", "EmailPendingVerification"},
+		{"OnHold", "Initial"},
+	}
+	for _, tt := range tests {
+		if got, err := plan.Migrate(tt.state); err != nil || got != tt.want {
+			t.Errorf("Migrate(%q) = %q, %v, want %q", tt.state, got, err, tt.want)
+		}
+	}
+	if _, err := plan.Migrate("Bogus"); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("Migrate(Bogus) error = %v, want ErrUnknownState", err)
+	}
+}
+
+func TestMigrateState(t *testing.T) {
+	plan, err := MigrationPlan(mustParse(t, "v1.json", signupV1), mustParse(t, "v2.json", signupV2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := MigrateState(plan, UserState("EmailPending"), allUserStates)
+	if err != nil || got != UserStateEmailPendingVerification {
+		t.Errorf("MigrateState() = %v, %v, want %v", got, err, UserStateEmailPendingVerification)
+	}
+}
+
+func TestMigrationPlan_Errors(t *testing.T) {
+	v1 := mustParse(t, "v1.json", signupV1)
+
+	if _, err := MigrationPlan(mustParse(t, "v2.json", signupV2), v1); err == nil || !strings.Contains(err.Error(), "from version 2 to version 1") {
+		t.Errorf("MigrationPlan() backwards error = %v", err)
+	}
+
+	bad := mustParse(t, "v2.json", `{"version": 2, "states": [{"name": "Complete", "renamedFrom": ["Finished"]}]}`)
+	if _, err := MigrationPlan(v1, bad); err == nil || !strings.Contains(err.Error(), `renamed from "Finished", which version 1 does not declare`) {
+		t.Errorf("MigrationPlan() error = %v, want unknown rename source", err)
+	}
+
+	_, err := ParseDefinition("v2.json", []byte(`{"states": [{"name": "Initial"}, {"name": "Start", "renamedFrom": ["Initial"]}]}`))
+	if err == nil || !strings.Contains(err.Error(), `state "Start" renamed from "Initial", which is still declared`) {
+		t.Errorf("ParseDefinition() error = %v, want rename of a declared state rejected", err)
+	}
+}
+
+func TestFromDefinition_Version(t *testing.T) {
+	sm, err := FromDefinition(mustParse(t, "v2.json", signupV2), []UserState{
+		UserStateInitial, UserStateEmailPendingVerification, UserStateEmailVerified, UserStateSignUpComplete,
+	}, allUserEvents)
+	if err != nil {
+		t.Fatalf("FromDefinition() error = %v", err)
+	}
+	if sm.Version() != 2 {
+		t.Errorf("Version() = %d, want 2", sm.Version())
+	}
+}
@@ -0,0 +1,116 @@
+package statemachine
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseYAMLDefinition parses and validates a definition written in YAML
+// rather than JSON. The document has the same structure as a JSON definition
+// file:
+//
+//	initial: Pending
+//	states:
+//	  - name: Pending
+//	  - name: Processing
+//	  - name: Shipped
+//	    final: true
+//	events:
+//	  - name: Confirm
+//	  - name: Ship
+//	transitions:
+//	  - {from: Pending, event: Confirm, to: Processing, guards: [paymentCleared]}
+//	  - {from: Processing, event: Ship, to: Shipped}
+//
+// The name is only used to give errors file context.
+func ParseYAMLDefinition(name string, data []byte) (*Definition, error) {
+	def, err := decodeYAMLDefinition(name, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := def.validate(); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// decodeYAMLDefinition decodes one YAML definition document and records the
+// line each state, event and transition starts on
+func decodeYAMLDefinition(name string, data []byte) (*Definition, error) {
+	def := &Definition{initialPos: position{file: name}}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(def); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("empty document")
+		}
+		return nil, &DefinitionError{File: name, Err: err}
+	}
+
+	// decode again, keeping positions, to find the line of each element
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, &DefinitionError{File: name, Err: err}
+	}
+
+	lines := yamlElementLines(&root, "states", "events", "transitions")
+	for i := range def.States {
+		def.States[i].pos = position{file: name, line: lines["states"][i]}
+	}
+	for i := range def.Events {
+		def.Events[i].pos = position{file: name, line: lines["events"][i]}
+	}
+	for i := range def.Transitions {
+		def.Transitions[i].pos = position{file: name, line: lines["transitions"][i]}
+	}
+	return def, nil
+}
+
+// yamlElementLines returns, for each of the given top level keys holding a
+// sequence, the line each element starts on
+func yamlElementLines(root *yaml.Node, keys ...string) map[string][]int {
+	lines := make(map[string][]int, len(keys))
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return lines
+	}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i].Value, doc.Content[i+1]
+		for _, k := range keys {
+			if k == key && value.Kind == yaml.SequenceNode {
+				for _, elem := range value.Content {
+					lines[key] = append(lines[key], elem.Line)
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// LoadYAML builds a state machine from a YAML definition read from r,
+// resolving names against the given state and event values by their
+// names, as Name gives them, and guard names against guards, which may be
+// nil if no transition is guarded. It lets a workflow be adjusted without
+// changing code:
+//
+//	sm, err := statemachine.LoadYAML(f, allOrderStates, allOrderEvents,
+//		map[string]statemachine.Guard[OrderState, OrderEvent]{
+//			"paymentCleared": paymentCleared,
+//		})
+func LoadYAML[S State, E Event](r io.Reader, states []S, events []E, guards map[string]Guard[S, E]) (*StateMachine[S, E], error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	def, err := ParseYAMLDefinition("", data)
+	if err != nil {
+		return nil, err
+	}
+	return FromDefinitionWithGuards(def, states, events, guards)
+}
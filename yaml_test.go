@@ -0,0 +1,88 @@
+package statemachine
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const userYAML = `name: signup
+initial: Initial
+states:
+  - name: Initial
+  - name: EmailPendingVerification
+    label: Awaiting verification
+  - name: SignUpComplete
+    final: true
+  - name: SignupRejected
+events:
+  - name: SubmitSignup
+  - name: SignUpFailed
+transitions:
+  - {from: Initial, event: SubmitSignup, to: SignUpComplete, guards: [trusted]}
+  - {from: Initial, event: SubmitSignup, to: EmailPendingVerification}
+  - from: EmailPendingVerification
+    event: SignUpFailed
+    to: SignupRejected
+    tags: [alert]
+`
+
+func TestLoadYAML(t *testing.T) {
+	trusted := false
+	guards := map[string]Guard[UserState, UserEvent]{
+		"trusted": func(ctx context.Context, from UserState, event UserEvent) bool { return trusted },
+	}
+
+	sm, err := LoadYAML(strings.NewReader(userYAML), allUserStates, allUserEvents, guards)
+	if err != nil {
+		t.Fatalf("LoadYAML() error = %v", err)
+	}
+
+	if got, err := sm.Transition(UserStateInitial, UserEventSubmitSignUp); err != nil || got != UserStateEmailPendingVerification {
+		t.Errorf("Transition() = %v, %v, want EmailPendingVerification", got, err)
+	}
+	trusted = true
+	if got, err := sm.Transition(UserStateInitial, UserEventSubmitSignUp); err != nil || got != UserStateSignUpComplete {
+		t.Errorf("Transition() with guard passing = %v, %v, want SignUpComplete", got, err)
+	}
+
+	if got, _ := sm.InitialState(); got != UserStateInitial {
+		t.Errorf("InitialState() = %v, want Initial", got)
+	}
+	if !sm.IsFinalState(UserStateSignUpComplete) {
+		t.Errorf("IsFinalState(SignUpComplete) = false")
+	}
+	if m, _ := sm.GetStateMeta(UserStateEmailPendingVerification); m.Label != "Awaiting verification" {
+		t.Errorf("GetStateMeta() label = %q", m.Label)
+	}
+	if m, _ := sm.GetTransitionMeta(UserStateEmailPendingVerification, UserEventSignupFailed); !m.HasTag("alert") {
+		t.Errorf("GetTransitionMeta() = %+v, want tag alert", m)
+	}
+}
+
+func TestLoadYAML_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		guards  map[string]Guard[UserState, UserEvent]
+		wantErr string
+	}{
+		{"empty", "", nil, "empty document"},
+		{"unknown field", "states: []\nevents: []\ntransitions: []\nstate: x\n", nil, "field state not found"},
+		{"unknown guard", userYAML, nil, `line 14: unknown guard "trusted"`},
+		{
+			"duplicate transition",
+			"states: [{name: Initial}]\nevents: [{name: SubmitSignup}]\ntransitions:\n  - {from: Initial, event: SubmitSignup, to: Initial}\n  - {from: Initial, event: SubmitSignup, to: Initial}\n",
+			nil,
+			`line 5: transition from "Initial" on "SubmitSignup" already declared at line 4`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadYAML(strings.NewReader(tt.doc), allUserStates, allUserEvents, tt.guards)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadYAML() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}